@@ -2,6 +2,7 @@ package chroot
 
 import (
 	"fmt"
+	"github.com/hashicorp/packer/common/chroot"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
 	"log"
@@ -11,7 +12,7 @@ import (
 type StepEarlyUnflock struct{}
 
 func (s *StepEarlyUnflock) Run(state multistep.StateBag) multistep.StepAction {
-	cleanup := state.Get("flock_cleanup").(Cleanup)
+	cleanup := state.Get("flock_cleanup").(chroot.Cleanup)
 	ui := state.Get("ui").(packer.Ui)
 
 	log.Println("Unlocking file lock...")
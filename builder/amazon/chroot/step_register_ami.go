@@ -88,7 +88,7 @@ func (s *StepRegisterAMI) Run(state multistep.StateBag) multistep.StepAction {
 		registerOpts.EnaSupport = aws.Bool(true)
 	}
 
-	registerResp, err := ec2conn.RegisterImage(registerOpts)
+	registerResp, err := awscommon.RegisterImage(ec2conn, registerOpts, &config.AMIConfig)
 	if err != nil {
 		state.Put("error", fmt.Errorf("Error registering AMI: %s", err))
 		ui.Error(state.Get("error").(error).Error())
@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/packer/template/interpolate"
 )
@@ -19,6 +20,9 @@ type AMIConfig struct {
 	AMITags                 map[string]string `mapstructure:"tags"`
 	AMIENASupport           bool              `mapstructure:"ena_support"`
 	AMISriovNetSupport      bool              `mapstructure:"sriov_support"`
+	AMIBootMode             string            `mapstructure:"boot_mode"`
+	AMITpmSupport           string            `mapstructure:"tpm_support"`
+	AMIIMDSSupport          string            `mapstructure:"imds_support"`
 	AMIForceDeregister      bool              `mapstructure:"force_deregister"`
 	AMIForceDeleteSnapshot  bool              `mapstructure:"force_delete_snapshot"`
 	AMIEncryptBootVolume    bool              `mapstructure:"encrypt_boot"`
@@ -105,6 +109,23 @@ func (c *AMIConfig) Prepare(ctx *interpolate.Context) []error {
 		}
 	}
 
+	if c.AMIBootMode != "" && !stringInSlice(validBootModes, c.AMIBootMode) {
+		errs = append(errs, fmt.Errorf("boot_mode must be one of %s", strings.Join(validBootModes, ", ")))
+	}
+
+	if c.AMITpmSupport != "" {
+		if !stringInSlice(validTpmSupport, c.AMITpmSupport) {
+			errs = append(errs, fmt.Errorf("tpm_support must be one of %s", strings.Join(validTpmSupport, ", ")))
+		}
+		if c.AMIBootMode != "uefi" && c.AMIBootMode != "uefi-preferred" {
+			errs = append(errs, fmt.Errorf("tpm_support requires boot_mode uefi or uefi-preferred"))
+		}
+	}
+
+	if c.AMIIMDSSupport != "" && !stringInSlice(validIMDSSupport, c.AMIIMDSSupport) {
+		errs = append(errs, fmt.Errorf("imds_support must be one of %s", strings.Join(validIMDSSupport, ", ")))
+	}
+
 	if len(c.AMIName) < 3 || len(c.AMIName) > 128 {
 		errs = append(errs, fmt.Errorf("ami_name must be between 3 and 128 characters long"))
 	}
@@ -136,6 +136,35 @@ func TestAMIConfigPrepare_Share_EncryptedBoot(t *testing.T) {
 	}
 }
 
+func TestAMIConfigPrepare_imageAttributes(t *testing.T) {
+	cases := []struct {
+		BootMode    string
+		TpmSupport  string
+		IMDSSupport string
+		Err         bool
+	}{
+		{"", "", "", false},
+		{"uefi", "v2.0", "v2.0", false},
+		{"uefi-preferred", "v2.0", "", false},
+		{"legacy-bios", "", "", false},
+		{"bios", "", "", true},
+		{"legacy-bios", "v2.0", "", true},
+		{"", "v2.0", "", true},
+		{"uefi", "v1.2", "", true},
+		{"", "", "v1.0", true},
+	}
+
+	for _, tc := range cases {
+		c := testAMIConfig()
+		c.AMIBootMode = tc.BootMode
+		c.AMITpmSupport = tc.TpmSupport
+		c.AMIIMDSSupport = tc.IMDSSupport
+		if err := c.Prepare(nil); (err != nil) != tc.Err {
+			t.Fatalf("%#v: bad err: %s", tc, err)
+		}
+	}
+}
+
 func TestAMINameValidation(t *testing.T) {
 	c := testAMIConfig()
 
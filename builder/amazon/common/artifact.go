@@ -22,6 +22,10 @@ type Artifact struct {
 
 	// EC2 connection for performing API stuff.
 	Conn *ec2.EC2
+
+	// GuestFacts are the facts about the operating system of the AMIs,
+	// if they are known.
+	GuestFacts *packer.GuestFacts
 }
 
 func (a *Artifact) BuilderId() string {
@@ -63,6 +67,34 @@ func (a *Artifact) State(name string) interface{} {
 	}
 }
 
+// Metadata returns the regions and the IDs of the AMIs, in the same order,
+// and what is known about their operating system.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	regions := make([]string, 0, len(a.Amis))
+	for region := range a.Amis {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	m := make(packer.ArtifactMetadata)
+	for _, region := range regions {
+		m.Add(packer.MetadataRegion, region)
+		m.Add(packer.MetadataImageId, a.Amis[region])
+	}
+	if a.GuestFacts != nil {
+		for k, v := range map[string]string{
+			packer.MetadataOSFamily:  a.GuestFacts.OSFamily,
+			packer.MetadataOSVersion: a.GuestFacts.OSVersion,
+			packer.MetadataArch:      a.GuestFacts.Arch,
+		} {
+			if v != "" {
+				m.Set(k, v)
+			}
+		}
+	}
+	return m
+}
+
 func (a *Artifact) Destroy() error {
 	errors := make([]error, 0)
 
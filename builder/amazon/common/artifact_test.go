@@ -46,6 +46,26 @@ func TestArtifactState_atlasMetadata(t *testing.T) {
 	}
 }
 
+func TestArtifactMetadata(t *testing.T) {
+	a := &Artifact{
+		Amis: map[string]string{
+			"west": "bar",
+			"east": "foo",
+		},
+		GuestFacts: &packer.GuestFacts{OSFamily: "linux", Arch: "amd64"},
+	}
+
+	expected := packer.ArtifactMetadata{
+		packer.MetadataRegion:   {"east", "west"},
+		packer.MetadataImageId:  {"foo", "bar"},
+		packer.MetadataOSFamily: {"linux"},
+		packer.MetadataArch:     {"amd64"},
+	}
+	if actual := a.Metadata(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestArtifactString(t *testing.T) {
 	expected := `AMIs were created:
 east: foo
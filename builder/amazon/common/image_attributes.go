@@ -0,0 +1,86 @@
+package common
+
+import (
+	"io/ioutil"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// The values of the boot_mode, tpm_support and imds_support options.
+var (
+	validBootModes   = []string{"legacy-bios", "uefi", "uefi-preferred"}
+	validTpmSupport  = []string{"v2.0"}
+	validIMDSSupport = []string{"v2.0"}
+)
+
+// The version of the AWS SDK we use doesn't know the boot mode, TPM and
+// IMDS attributes of images yet. withQueryParams adds them to the query of
+// a request by hand, after the SDK built it.
+func withQueryParams(params url.Values) request.Option {
+	return func(r *request.Request) {
+		if len(params) == 0 {
+			return
+		}
+
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.GetBody())
+			if err != nil {
+				r.Error = err
+				return
+			}
+			query, err := url.ParseQuery(string(body))
+			if err != nil {
+				r.Error = err
+				return
+			}
+			for k, v := range params {
+				query[k] = v
+			}
+			r.SetBufferBody([]byte(query.Encode()))
+		})
+	}
+}
+
+// registerParams returns the attributes of the AMI config that are set
+// when the AMI is registered.
+func (c *AMIConfig) registerParams() url.Values {
+	params := url.Values{}
+	if c.AMIBootMode != "" {
+		params.Set("BootMode", c.AMIBootMode)
+	}
+	if c.AMITpmSupport != "" {
+		params.Set("TpmSupport", c.AMITpmSupport)
+	}
+	if c.AMIIMDSSupport != "" {
+		params.Set("ImdsSupport", c.AMIIMDSSupport)
+	}
+	return params
+}
+
+// RegisterImage registers an AMI with the boot mode, TPM and IMDS support
+// of the AMI config.
+func RegisterImage(ec2conn *ec2.EC2, input *ec2.RegisterImageInput, c *AMIConfig) (*ec2.RegisterImageOutput, error) {
+	return ec2conn.RegisterImageWithContext(aws.BackgroundContext(), input, withQueryParams(c.registerParams()))
+}
+
+// SetImageIMDSSupport sets the IMDS support of an AMI that was created
+// from an instance, which can't be set on creation.
+func SetImageIMDSSupport(ec2conn *ec2.EC2, imageId string, c *AMIConfig) error {
+	if c.AMIIMDSSupport == "" {
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("ImdsSupport.Value", c.AMIIMDSSupport)
+	_, err := ec2conn.ModifyImageAttributeWithContext(aws.BackgroundContext(), &ec2.ModifyImageAttributeInput{
+		ImageId: aws.String(imageId),
+	}, withQueryParams(params))
+	return err
+}
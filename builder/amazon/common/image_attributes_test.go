@@ -0,0 +1,50 @@
+package common
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestWithQueryParams(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	c := &AMIConfig{AMIBootMode: "uefi", AMITpmSupport: "v2.0", AMIIMDSSupport: "v2.0"}
+
+	req, _ := ec2.New(sess).RegisterImageRequest(&ec2.RegisterImageInput{
+		Name: aws.String("foo"),
+	})
+	req.ApplyOptions(withQueryParams(c.registerParams()))
+	if err := req.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	body, err := ioutil.ReadAll(req.GetBody())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	query, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"Action":      "RegisterImage",
+		"Name":        "foo",
+		"BootMode":    "uefi",
+		"TpmSupport":  "v2.0",
+		"ImdsSupport": "v2.0",
+	}
+	for k, v := range expected {
+		if query.Get(k) != v {
+			t.Fatalf("bad %s: %s", k, body)
+		}
+	}
+}
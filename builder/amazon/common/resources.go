@@ -0,0 +1,82 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/packer/common/resources"
+)
+
+// The kinds of the temporary resources the amazon builders track, see the
+// resources package.
+const (
+	ResourceInstance      = "instance"
+	ResourceKeyPair       = "keypair"
+	ResourceSecurityGroup = "security-group"
+)
+
+// tempResource returns the record of a temporary resource in the region of
+// the connection.
+func tempResource(ec2conn *ec2.EC2, kind, id string) resources.Resource {
+	return resources.Resource{
+		Builder: "amazon",
+		Kind:    kind,
+		ID:      id,
+		Region:  aws.StringValue(ec2conn.Config.Region),
+	}
+}
+
+// CleanupResource deletes a temporary resource left behind by a build. It
+// uses the default credentials, so the environment or shared configuration
+// must give access to the account the build ran in.
+func CleanupResource(r resources.Resource) error {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Config:            *aws.NewConfig().WithRegion(r.Region).WithMaxRetries(11),
+	})
+	if err != nil {
+		return err
+	}
+	ec2conn := ec2.New(sess)
+
+	switch r.Kind {
+	case ResourceInstance:
+		_, err := ec2conn.TerminateInstances(&ec2.TerminateInstancesInput{
+			InstanceIds: []*string{aws.String(r.ID)},
+		})
+		if isNotFound(err, "InvalidInstanceID.NotFound") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		stateChange := StateChangeConf{
+			Pending: []string{"pending", "running", "shutting-down", "stopped", "stopping"},
+			Refresh: InstanceStateRefreshFunc(ec2conn, r.ID),
+			Target:  "terminated",
+		}
+		_, err = WaitForState(&stateChange)
+		return err
+	case ResourceKeyPair:
+		_, err := ec2conn.DeleteKeyPair(&ec2.DeleteKeyPairInput{KeyName: aws.String(r.ID)})
+		return err
+	case ResourceSecurityGroup:
+		_, err := ec2conn.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: aws.String(r.ID)})
+		if isNotFound(err, "InvalidGroup.NotFound") {
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("Unknown resource kind: %s", r.Kind)
+	}
+}
+
+// isNotFound returns true if the error is the given not found error, the
+// resource is already gone then.
+func isNotFound(err error, code string) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == code
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/packer/common/uuid"
@@ -37,6 +38,10 @@ type RunConfig struct {
 	SourceAmiFilter                   AmiFilterOptions  `mapstructure:"source_ami_filter"`
 	SpotPrice                         string            `mapstructure:"spot_price"`
 	SpotPriceAutoProduct              string            `mapstructure:"spot_price_auto_product"`
+	SpotPriceMax                      string            `mapstructure:"spot_price_max"`
+	SpotFallbackOnDemand              bool              `mapstructure:"spot_fallback_on_demand"`
+	SpotRequestTimeout                time.Duration     `mapstructure:"spot_request_timeout"`
+	SpotInterruptionRetries           int               `mapstructure:"spot_interruption_retries"`
 	DisableStopInstance               bool              `mapstructure:"disable_stop_instance"`
 	SecurityGroupId                   string            `mapstructure:"security_group_id"`
 	SecurityGroupIds                  []string          `mapstructure:"security_group_ids"`
@@ -98,6 +103,35 @@ func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
 		}
 	}
 
+	if c.SpotPriceMax != "" {
+		if c.SpotPrice != "auto" {
+			errs = append(errs, errors.New(
+				"spot_price_max can only be specified when spot_price is auto"))
+		} else if _, err := strconv.ParseFloat(c.SpotPriceMax, 64); err != nil {
+			errs = append(errs, fmt.Errorf("spot_price_max must be a price: %s", err))
+		}
+	}
+
+	if c.SpotPrice == "" || c.SpotPrice == "0" {
+		if c.SpotFallbackOnDemand || c.SpotRequestTimeout != 0 || c.SpotInterruptionRetries != 0 {
+			errs = append(errs, errors.New(
+				"spot_fallback_on_demand, spot_request_timeout and spot_interruption_retries "+
+					"can only be specified with a spot_price"))
+		}
+	}
+
+	if c.SpotFallbackOnDemand && c.SpotRequestTimeout == 0 {
+		c.SpotRequestTimeout = 10 * time.Minute
+	}
+
+	if c.SpotRequestTimeout < 0 {
+		errs = append(errs, errors.New("spot_request_timeout can't be negative"))
+	}
+
+	if c.SpotInterruptionRetries < 0 {
+		errs = append(errs, errors.New("spot_interruption_retries can't be negative"))
+	}
+
 	if c.UserData != "" && c.UserDataFile != "" {
 		errs = append(errs, fmt.Errorf("Only one of user_data or user_data_file can be specified."))
 	} else if c.UserDataFile != "" {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/helper/communicator"
 )
@@ -92,6 +93,48 @@ func TestRunConfigPrepare_SpotAuto(t *testing.T) {
 	}
 }
 
+func TestRunConfigPrepare_SpotPriceMax(t *testing.T) {
+	c := testConfig()
+	c.SpotPriceMax = "0.5"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPrice = "auto"
+	c.SpotPriceAutoProduct = "foo"
+	c.SpotPriceMax = "cheap"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPriceMax = "0.5"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_SpotFallback(t *testing.T) {
+	c := testConfig()
+	c.SpotFallbackOnDemand = true
+	c.SpotInterruptionRetries = 2
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPrice = "0.2"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+	if c.SpotRequestTimeout != 10*time.Minute {
+		t.Fatalf("bad spot_request_timeout: %s", c.SpotRequestTimeout)
+	}
+
+	c.SpotInterruptionRetries = -1
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestRunConfigPrepare_SSHPort(t *testing.T) {
 	c := testConfig()
 	c.Comm.SSHPort = 0
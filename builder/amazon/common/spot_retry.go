@@ -0,0 +1,29 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// RunWithSpotRetry runs the steps of a build with run, in a new state bag
+// holding the given values, and runs them again from the start, up to
+// retries times, while they fail because the source spot instance was
+// interrupted. It returns the state bag of the last run.
+func RunWithSpotRetry(ui packer.Ui, retries int, values map[string]interface{}, run func(multistep.StateBag)) multistep.StateBag {
+	for attempt := 0; ; attempt++ {
+		state := new(multistep.BasicStateBag)
+		for k, v := range values {
+			state.Put(k, v)
+		}
+
+		run(state)
+
+		if !SpotInterrupted(state) || attempt >= retries {
+			return state
+		}
+		ui.Say(fmt.Sprintf(
+			"Retrying the build, attempt %d of %d...", attempt+2, retries+1))
+	}
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+func TestRunWithSpotRetry(t *testing.T) {
+	cases := []struct {
+		retries     int
+		interrupted int
+		cancelled   bool
+		runs        int
+	}{
+		{0, 0, false, 1},
+		{0, 1, false, 1},
+		{3, 0, false, 1},
+		{3, 2, false, 3},
+		{1, 2, false, 2},
+		{3, 1, true, 1},
+	}
+
+	for _, tc := range cases {
+		runs := 0
+		state := RunWithSpotRetry(packer.TestUi(t), tc.retries, map[string]interface{}{
+			"config": "foo",
+		}, func(state multistep.StateBag) {
+			if _, ok := state.GetOk("run"); ok {
+				t.Fatal("state should be new")
+			}
+			if state.Get("config") != "foo" {
+				t.Fatalf("bad: %#v", state.Get("config"))
+			}
+
+			runs++
+			state.Put("run", runs)
+			if runs <= tc.interrupted {
+				state.Put("spot_interrupted", true)
+			}
+			if tc.cancelled {
+				state.Put(multistep.StateCancelled, true)
+			}
+		})
+
+		if runs != tc.runs {
+			t.Fatalf("%#v: bad runs: %d", tc, runs)
+		}
+		if state.Get("run") != runs {
+			t.Fatalf("%#v: should return the last state", tc)
+		}
+	}
+}
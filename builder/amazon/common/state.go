@@ -33,6 +33,10 @@ type StateChangeConf struct {
 	Refresh   StateRefreshFunc
 	StepState multistep.StateBag
 	Target    string
+
+	// Timeout is how long to wait for the target state. Zero waits for as
+	// long as the object stays in a pending state.
+	Timeout time.Duration
 }
 
 // AMIStateRefreshFunc returns a StateRefreshFunc that is used to watch
@@ -166,6 +170,11 @@ func WaitForState(conf *StateChangeConf) (i interface{}, err error) {
 	maxTicks := TimeoutSeconds()/sleepSeconds + 1
 	notfoundTick := 0
 
+	var deadline time.Time
+	if conf.Timeout > 0 {
+		deadline = time.Now().Add(conf.Timeout)
+	}
+
 	for {
 		var currentState string
 		i, currentState, err = conf.Refresh()
@@ -208,6 +217,10 @@ func WaitForState(conf *StateChangeConf) (i interface{}, err error) {
 			}
 		}
 
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout after %s waiting for state '%s'", conf.Timeout, conf.Target)
+		}
+
 		time.Sleep(time.Duration(sleepSeconds) * time.Second)
 	}
 }
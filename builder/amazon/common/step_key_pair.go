@@ -7,6 +7,7 @@ import (
 	"runtime"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/packer/common/resources"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
 )
@@ -68,6 +69,7 @@ func (s *StepKeyPair) Run(state multistep.StateBag) multistep.StepAction {
 	}
 
 	s.doCleanup = true
+	resources.Track(tempResource(ec2conn, ResourceKeyPair, s.TemporaryKeyPairName))
 
 	// Set some state data for use in future steps
 	state.Put("keyPair", s.TemporaryKeyPairName)
@@ -116,6 +118,8 @@ func (s *StepKeyPair) Cleanup(state multistep.StateBag) {
 	if err != nil {
 		ui.Error(fmt.Sprintf(
 			"Error cleaning up keypair. Please delete the key manually: %s", s.TemporaryKeyPairName))
+	} else {
+		resources.Release(tempResource(ec2conn, ResourceKeyPair, s.TemporaryKeyPairName))
 	}
 
 	// Also remove the physical key if we're debugging.
@@ -13,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 
 	retry "github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/resources"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
@@ -30,7 +31,10 @@ type StepRunSourceInstance struct {
 	InstanceType                      string
 	SourceAMI                         string
 	SpotPrice                         string
+	SpotPriceMax                      string
 	SpotPriceProduct                  string
+	SpotFallbackOnDemand              bool
+	SpotRequestTimeout                time.Duration
 	SubnetId                          string
 	Tags                              map[string]string
 	UserData                          string
@@ -42,6 +46,11 @@ type StepRunSourceInstance struct {
 }
 
 func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepAction {
+	// The step runs again when a build is retried after its spot instance
+	// was interrupted
+	s.instanceId = ""
+	s.spotRequest = nil
+
 	ec2conn := state.Get("ec2").(*ec2.EC2)
 	var keyName string
 	if name, ok := state.GetOk("keyPair"); ok {
@@ -132,6 +141,19 @@ func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepActi
 		}
 
 		spotPrice = strconv.FormatFloat(price, 'f', -1, 64)
+
+		// Don't bid more than the maximum price
+		if max, err := strconv.ParseFloat(s.SpotPriceMax, 64); err == nil && price > max {
+			err := fmt.Errorf("The spot price %s is higher than spot_price_max %s", spotPrice, s.SpotPriceMax)
+			if !s.SpotFallbackOnDemand {
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			ui.Message(fmt.Sprintf("%s, falling back to an on-demand instance", err))
+			spotPrice = ""
+		}
 	}
 
 	var instanceId string
@@ -151,141 +173,50 @@ func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepActi
 	}
 	ReportTags(ui, ec2Tags)
 
-	if spotPrice == "" || spotPrice == "0" {
-
-		runOpts := &ec2.RunInstancesInput{
-			ImageId:             &s.SourceAMI,
-			InstanceType:        &s.InstanceType,
-			UserData:            &userData,
-			MaxCount:            aws.Int64(1),
-			MinCount:            aws.Int64(1),
-			IamInstanceProfile:  &ec2.IamInstanceProfileSpecification{Name: &s.IamInstanceProfile},
-			BlockDeviceMappings: s.BlockDevices.BuildLaunchDevices(),
-			Placement:           &ec2.Placement{AvailabilityZone: &s.AvailabilityZone},
-			EbsOptimized:        &s.EbsOptimized,
-		}
-
-		if len(ec2Tags) > 0 {
-			runTags := &ec2.TagSpecification{
-				ResourceType: aws.String("instance"),
-				Tags:         ec2Tags,
-			}
-
-			runOpts.SetTagSpecifications([]*ec2.TagSpecification{runTags})
-			createTagsAfterInstanceStarts = false
-		}
-
-		if keyName != "" {
-			runOpts.KeyName = &keyName
-		}
-
-		if s.SubnetId != "" && s.AssociatePublicIpAddress {
-			runOpts.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{
-				{
-					DeviceIndex:              aws.Int64(0),
-					AssociatePublicIpAddress: &s.AssociatePublicIpAddress,
-					SubnetId:                 &s.SubnetId,
-					Groups:                   securityGroupIds,
-					DeleteOnTermination:      aws.Bool(true),
-				},
-			}
-		} else {
-			runOpts.SubnetId = &s.SubnetId
-			runOpts.SecurityGroupIds = securityGroupIds
-		}
-
-		if s.ExpectedRootDevice == "ebs" {
-			runOpts.InstanceInitiatedShutdownBehavior = &s.InstanceInitiatedShutdownBehavior
-		}
-
-		runResp, err := ec2conn.RunInstances(runOpts)
-		if err != nil {
-			err := fmt.Errorf("Error launching source instance: %s", err)
-			state.Put("error", err)
+	if spotPrice != "" && spotPrice != "0" {
+		instanceId, err = s.runSpot(state, ec2conn, ui, spotPrice, availabilityZone, userData, keyName, securityGroupIds)
+		_, cancelled := state.GetOk(multistep.StateCancelled)
+		if err != nil && s.SpotFallbackOnDemand && !cancelled {
 			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-		instanceId = *runResp.Instances[0].InstanceId
-	} else {
-		ui.Message(fmt.Sprintf(
-			"Requesting spot instance '%s' for: %s",
-			s.InstanceType, spotPrice))
-
-		runOpts := &ec2.RequestSpotLaunchSpecification{
-			ImageId:            &s.SourceAMI,
-			InstanceType:       &s.InstanceType,
-			UserData:           &userData,
-			IamInstanceProfile: &ec2.IamInstanceProfileSpecification{Name: &s.IamInstanceProfile},
-			Placement: &ec2.SpotPlacement{
-				AvailabilityZone: &availabilityZone,
-			},
-			BlockDeviceMappings: s.BlockDevices.BuildLaunchDevices(),
-			EbsOptimized:        &s.EbsOptimized,
-		}
 
-		if s.SubnetId != "" && s.AssociatePublicIpAddress {
-			runOpts.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{
-				{
-					DeviceIndex:              aws.Int64(0),
-					AssociatePublicIpAddress: &s.AssociatePublicIpAddress,
-					SubnetId:                 &s.SubnetId,
-					Groups:                   securityGroupIds,
-					DeleteOnTermination:      aws.Bool(true),
-				},
+			// Cancel the request, so it can't be fulfilled once we've
+			// moved on. If it was fulfilled in the meantime, use its
+			// instance.
+			if s.spotRequest != nil {
+				instanceId, err = s.cancelSpotRequest(ec2conn)
+				if err != nil {
+					err := fmt.Errorf("Error cancelling the spot request: %s", err)
+					state.Put("error", err)
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
 			}
-		} else {
-			runOpts.SubnetId = &s.SubnetId
-			runOpts.SecurityGroupIds = securityGroupIds
-		}
-
-		if keyName != "" {
-			runOpts.KeyName = &keyName
-		}
-
-		runSpotResp, err := ec2conn.RequestSpotInstances(&ec2.RequestSpotInstancesInput{
-			SpotPrice:           &spotPrice,
-			LaunchSpecification: runOpts,
-		})
-		if err != nil {
-			err := fmt.Errorf("Error launching source spot instance: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		s.spotRequest = runSpotResp.SpotInstanceRequests[0]
-
-		spotRequestId := s.spotRequest.SpotInstanceRequestId
-		ui.Message(fmt.Sprintf("Waiting for spot request (%s) to become active...", *spotRequestId))
-		stateChange := StateChangeConf{
-			Pending:   []string{"open"},
-			Target:    "active",
-			Refresh:   SpotRequestStateRefreshFunc(ec2conn, *spotRequestId),
-			StepState: state,
-		}
-		_, err = WaitForState(&stateChange)
-		if err != nil {
-			err := fmt.Errorf("Error waiting for spot request (%s) to become ready: %s", *spotRequestId, err)
+			if instanceId == "" {
+				ui.Say("Falling back to an on-demand instance...")
+				spotPrice = ""
+			}
+		} else if err != nil {
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+	}
 
-		spotResp, err := ec2conn.DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
-			SpotInstanceRequestIds: []*string{spotRequestId},
-		})
+	if spotPrice == "" || spotPrice == "0" {
+		instanceId, err = s.runOnDemand(ec2conn, userData, keyName, securityGroupIds, ec2Tags)
 		if err != nil {
-			err := fmt.Errorf("Error finding spot request (%s): %s", *spotRequestId, err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
-		instanceId = *spotResp.SpotInstanceRequests[0].InstanceId
 
+		// On-demand instances are tagged when they are launched
+		createTagsAfterInstanceStarts = len(ec2Tags) == 0
 	}
 
 	// Set the instance ID so that the cleanup works properly
 	s.instanceId = instanceId
+	resources.Track(tempResource(ec2conn, ResourceInstance, instanceId))
 
 	ui.Message(fmt.Sprintf("Instance ID: %s", instanceId))
 	ui.Say(fmt.Sprintf("Waiting for instance (%v) to become ready...", instanceId))
@@ -380,6 +311,11 @@ func (s *StepRunSourceInstance) Cleanup(state multistep.StateBag) {
 
 	// Terminate the source instance if it exists
 	if s.instanceId != "" {
+		if _, ok := state.GetOk("error"); ok && s.spotInterrupted(ec2conn) {
+			ui.Error("The source spot instance was interrupted by EC2.")
+			state.Put("spot_interrupted", true)
+		}
+
 		ui.Say("Terminating the source AWS instance...")
 		if _, err := ec2conn.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{&s.instanceId}}); err != nil {
 			ui.Error(fmt.Sprintf("Error terminating instance, may still be around: %s", err))
@@ -394,6 +330,181 @@ func (s *StepRunSourceInstance) Cleanup(state multistep.StateBag) {
 		_, err := WaitForState(&stateChange)
 		if err != nil {
 			ui.Error(err.Error())
+			return
+		}
+		resources.Release(tempResource(ec2conn, ResourceInstance, s.instanceId))
+	}
+}
+
+// runOnDemand launches the source instance as an on-demand instance and
+// returns its ID.
+func (s *StepRunSourceInstance) runOnDemand(ec2conn *ec2.EC2, userData, keyName string, securityGroupIds []*string, ec2Tags []*ec2.Tag) (string, error) {
+	runOpts := &ec2.RunInstancesInput{
+		ImageId:             &s.SourceAMI,
+		InstanceType:        &s.InstanceType,
+		UserData:            &userData,
+		MaxCount:            aws.Int64(1),
+		MinCount:            aws.Int64(1),
+		IamInstanceProfile:  &ec2.IamInstanceProfileSpecification{Name: &s.IamInstanceProfile},
+		BlockDeviceMappings: s.BlockDevices.BuildLaunchDevices(),
+		Placement:           &ec2.Placement{AvailabilityZone: &s.AvailabilityZone},
+		EbsOptimized:        &s.EbsOptimized,
+	}
+
+	if len(ec2Tags) > 0 {
+		runTags := &ec2.TagSpecification{
+			ResourceType: aws.String("instance"),
+			Tags:         ec2Tags,
+		}
+
+		runOpts.SetTagSpecifications([]*ec2.TagSpecification{runTags})
+	}
+
+	if keyName != "" {
+		runOpts.KeyName = &keyName
+	}
+
+	if s.SubnetId != "" && s.AssociatePublicIpAddress {
+		runOpts.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{
+			{
+				DeviceIndex:              aws.Int64(0),
+				AssociatePublicIpAddress: &s.AssociatePublicIpAddress,
+				SubnetId:                 &s.SubnetId,
+				Groups:                   securityGroupIds,
+				DeleteOnTermination:      aws.Bool(true),
+			},
+		}
+	} else {
+		runOpts.SubnetId = &s.SubnetId
+		runOpts.SecurityGroupIds = securityGroupIds
+	}
+
+	if s.ExpectedRootDevice == "ebs" {
+		runOpts.InstanceInitiatedShutdownBehavior = &s.InstanceInitiatedShutdownBehavior
+	}
+
+	runResp, err := ec2conn.RunInstances(runOpts)
+	if err != nil {
+		return "", fmt.Errorf("Error launching source instance: %s", err)
+	}
+	return *runResp.Instances[0].InstanceId, nil
+}
+
+// runSpot requests the source instance as a spot instance and returns its
+// ID once the request is fulfilled.
+func (s *StepRunSourceInstance) runSpot(state multistep.StateBag, ec2conn *ec2.EC2, ui packer.Ui, spotPrice, availabilityZone, userData, keyName string, securityGroupIds []*string) (string, error) {
+	ui.Message(fmt.Sprintf(
+		"Requesting spot instance '%s' for: %s",
+		s.InstanceType, spotPrice))
+
+	runOpts := &ec2.RequestSpotLaunchSpecification{
+		ImageId:            &s.SourceAMI,
+		InstanceType:       &s.InstanceType,
+		UserData:           &userData,
+		IamInstanceProfile: &ec2.IamInstanceProfileSpecification{Name: &s.IamInstanceProfile},
+		Placement: &ec2.SpotPlacement{
+			AvailabilityZone: &availabilityZone,
+		},
+		BlockDeviceMappings: s.BlockDevices.BuildLaunchDevices(),
+		EbsOptimized:        &s.EbsOptimized,
+	}
+
+	if s.SubnetId != "" && s.AssociatePublicIpAddress {
+		runOpts.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{
+			{
+				DeviceIndex:              aws.Int64(0),
+				AssociatePublicIpAddress: &s.AssociatePublicIpAddress,
+				SubnetId:                 &s.SubnetId,
+				Groups:                   securityGroupIds,
+				DeleteOnTermination:      aws.Bool(true),
+			},
 		}
+	} else {
+		runOpts.SubnetId = &s.SubnetId
+		runOpts.SecurityGroupIds = securityGroupIds
 	}
+
+	if keyName != "" {
+		runOpts.KeyName = &keyName
+	}
+
+	runSpotResp, err := ec2conn.RequestSpotInstances(&ec2.RequestSpotInstancesInput{
+		SpotPrice:           &spotPrice,
+		LaunchSpecification: runOpts,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error launching source spot instance: %s", err)
+	}
+
+	s.spotRequest = runSpotResp.SpotInstanceRequests[0]
+
+	spotRequestId := s.spotRequest.SpotInstanceRequestId
+	ui.Message(fmt.Sprintf("Waiting for spot request (%s) to become active...", *spotRequestId))
+	stateChange := StateChangeConf{
+		Pending:   []string{"open"},
+		Target:    "active",
+		Refresh:   SpotRequestStateRefreshFunc(ec2conn, *spotRequestId),
+		StepState: state,
+		Timeout:   s.SpotRequestTimeout,
+	}
+	_, err = WaitForState(&stateChange)
+	if err != nil {
+		return "", fmt.Errorf("Error waiting for spot request (%s) to become ready: %s", *spotRequestId, err)
+	}
+
+	spotResp, err := ec2conn.DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{spotRequestId},
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error finding spot request (%s): %s", *spotRequestId, err)
+	}
+	return *spotResp.SpotInstanceRequests[0].InstanceId, nil
+}
+
+// cancelSpotRequest cancels the spot request and returns the ID of the
+// instance it launched, if it was fulfilled after all.
+func (s *StepRunSourceInstance) cancelSpotRequest(ec2conn *ec2.EC2) (string, error) {
+	spotRequestId := s.spotRequest.SpotInstanceRequestId
+	_, err := ec2conn.CancelSpotInstanceRequests(&ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{spotRequestId},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := ec2conn.DescribeSpotInstanceRequests(&ec2.DescribeSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{spotRequestId},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.spotRequest = nil
+	if len(resp.SpotInstanceRequests) > 0 && resp.SpotInstanceRequests[0].InstanceId != nil {
+		return *resp.SpotInstanceRequests[0].InstanceId, nil
+	}
+	return "", nil
+}
+
+// spotInterrupted returns true if the source instance is a spot instance
+// that EC2 terminated, because of the price or capacity.
+func (s *StepRunSourceInstance) spotInterrupted(ec2conn *ec2.EC2) bool {
+	resp, err := ec2conn.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{&s.instanceId},
+	})
+	if err != nil || len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
+		return false
+	}
+
+	reason := resp.Reservations[0].Instances[0].StateReason
+	return reason != nil && aws.StringValue(reason.Code) == "Server.SpotInstanceTermination"
+}
+
+// SpotInterrupted returns true if the steps failed because EC2 interrupted
+// the source spot instance, and weren't cancelled. Builders retry these
+// builds.
+func SpotInterrupted(state multistep.StateBag) bool {
+	_, interrupted := state.GetOk("spot_interrupted")
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	return interrupted && !cancelled
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/packer/common/resources"
 	"github.com/hashicorp/packer/common/uuid"
 	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/packer"
@@ -71,6 +72,7 @@ func (s *StepSecurityGroup) Run(state multistep.StateBag) multistep.StepAction {
 
 	// Set the group ID so we can delete it later
 	s.createdGroupId = *groupResp.GroupId
+	resources.Track(tempResource(ec2conn, ResourceSecurityGroup, s.createdGroupId))
 
 	// Authorize the SSH access for the security group
 	req := &ec2.AuthorizeSecurityGroupIngressInput{
@@ -149,7 +151,9 @@ func (s *StepSecurityGroup) Cleanup(state multistep.StateBag) {
 	if err != nil {
 		ui.Error(fmt.Sprintf(
 			"Error cleaning up security group. Please delete the group manually: %s", s.createdGroupId))
+		return
 	}
+	resources.Release(tempResource(ec2conn, ResourceSecurityGroup, s.createdGroupId))
 }
 
 func waitUntilSecurityGroupExists(c *ec2.EC2, input *ec2.DescribeSecurityGroupsInput) error {
@@ -35,6 +35,25 @@ func buildAmiFilters(input map[*string]*string) []*ec2.Filter {
 	return filters
 }
 
+// Build the query for the source AMI, either by ID or with filters.
+func sourceAmiQuery(sourceAmi string, amiFilters AmiFilterOptions) *ec2.DescribeImagesInput {
+	params := &ec2.DescribeImagesInput{}
+
+	if sourceAmi != "" {
+		params.ImageIds = []*string{&sourceAmi}
+	}
+
+	// We have filters to apply
+	if len(amiFilters.Filters) > 0 {
+		params.Filters = buildAmiFilters(amiFilters.Filters)
+	}
+	if len(amiFilters.Owners) > 0 {
+		params.Owners = amiFilters.Owners
+	}
+
+	return params
+}
+
 type imageSort []*ec2.Image
 
 func (a imageSort) Len() int      { return len(a) }
@@ -56,19 +75,7 @@ func (s *StepSourceAMIInfo) Run(state multistep.StateBag) multistep.StepAction {
 	ec2conn := state.Get("ec2").(*ec2.EC2)
 	ui := state.Get("ui").(packer.Ui)
 
-	params := &ec2.DescribeImagesInput{}
-
-	if s.SourceAmi != "" {
-		params.ImageIds = []*string{&s.SourceAmi}
-	}
-
-	// We have filters to apply
-	if len(s.AmiFilters.Filters) > 0 {
-		params.Filters = buildAmiFilters(s.AmiFilters.Filters)
-	}
-	if len(s.AmiFilters.Owners) > 0 {
-		params.Owners = s.AmiFilters.Owners
-	}
+	params := sourceAmiQuery(s.SourceAmi, s.AmiFilters)
 
 	log.Printf("Using AMI Filters %v", params)
 	imageResp, err := ec2conn.DescribeImages(params)
@@ -112,7 +119,28 @@ func (s *StepSourceAMIInfo) Run(state multistep.StateBag) multistep.StepAction {
 	}
 
 	state.Put("source_image", image)
+	state.Put("guest_facts", imageGuestFacts(image))
 	return multistep.ActionContinue
 }
 
+// imageGuestFacts returns what the image tells about its guest OS.
+func imageGuestFacts(image *ec2.Image) *packer.GuestFacts {
+	facts := new(packer.GuestFacts)
+	if image.Platform != nil && *image.Platform == "windows" {
+		facts.OSFamily = "windows"
+	}
+
+	if image.Architecture != nil {
+		switch *image.Architecture {
+		case "x86_64":
+			facts.Arch = "amd64"
+		case "i386":
+			facts.Arch = "386"
+		case "arm64":
+			facts.Arch = "arm64"
+		}
+	}
+	return facts
+}
+
 func (s *StepSourceAMIInfo) Cleanup(multistep.StateBag) {}
@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/hashicorp/packer/common"
@@ -11,7 +12,6 @@ import (
 )
 
 type StepStopEBSBackedInstance struct {
-	SpotPrice           string
 	DisableStopInstance bool
 }
 
@@ -20,8 +20,9 @@ func (s *StepStopEBSBackedInstance) Run(state multistep.StateBag) multistep.Step
 	instance := state.Get("instance").(*ec2.Instance)
 	ui := state.Get("ui").(packer.Ui)
 
-	// Skip when it is a spot instance
-	if s.SpotPrice != "" && s.SpotPrice != "0" {
+	// Skip when it is a spot instance, these can't be stopped. With
+	// spot_fallback_on_demand the instance may be on-demand though.
+	if aws.StringValue(instance.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot {
 		return multistep.ActionContinue
 	}
 
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/packer/packer"
+)
+
+// ValidateRemote checks that the AWS credentials can be resolved and that
+// the source AMI exists. It makes API calls, so builders only call it from
+// Validate when remote checks are enabled.
+func ValidateRemote(ctx *packer.ValidateContext, access *AccessConfig, run *RunConfig) error {
+	if !ctx.CheckRemote {
+		return nil
+	}
+
+	session, err := access.Session()
+	if err != nil {
+		return fmt.Errorf("Error creating AWS session: %s", err)
+	}
+
+	if _, err := session.Config.Credentials.Get(); err != nil {
+		return fmt.Errorf("Error resolving AWS credentials: %s", err)
+	}
+
+	ec2conn := ec2.New(session)
+	params := sourceAmiQuery(run.SourceAmi, run.SourceAmiFilter)
+	imageResp, err := ec2conn.DescribeImages(params)
+	if err != nil {
+		return fmt.Errorf("Error querying AMI: %s", err)
+	}
+
+	if len(imageResp.Images) == 0 {
+		return fmt.Errorf("No AMI was found matching filters: %v", params)
+	}
+
+	if len(imageResp.Images) > 1 && !run.SourceAmiFilter.MostRecent {
+		return fmt.Errorf("Your query returned more than one result. Please try a more specific search, or set most_recent to true.")
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@
 package ebs
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -68,6 +69,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.AMIConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.RunConfig.Prepare(&b.config.ctx)...)
 
+	// The AMI is created from the instance, which it takes these from
+	if b.config.AMIBootMode != "" || b.config.AMITpmSupport != "" {
+		errs = packer.MultiErrorAppend(errs, errors.New(
+			"boot_mode and tpm_support can't be set, the AMI has the ones of the source AMI"))
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
 	}
@@ -76,6 +83,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	return nil, nil
 }
 
+func (b *Builder) Validate(ctx *packer.ValidateContext) error {
+	return awscommon.ValidateRemote(ctx, &b.config.AccessConfig, &b.config.RunConfig)
+}
+
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
 
 	session, err := b.config.Session()
@@ -101,13 +112,6 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		}
 	}
 
-	// Setup the state bag and initial state for the steps
-	state := new(multistep.BasicStateBag)
-	state.Put("config", b.config)
-	state.Put("ec2", ec2conn)
-	state.Put("hook", hook)
-	state.Put("ui", ui)
-
 	// Build the steps
 	steps := []multistep.Step{
 		&awscommon.StepPreValidate{
@@ -140,7 +144,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Debug:                    b.config.PackerDebug,
 			ExpectedRootDevice:       "ebs",
 			SpotPrice:                b.config.SpotPrice,
+			SpotPriceMax:             b.config.SpotPriceMax,
 			SpotPriceProduct:         b.config.SpotPriceAutoProduct,
+			SpotFallbackOnDemand:     b.config.SpotFallbackOnDemand,
+			SpotRequestTimeout:       b.config.SpotRequestTimeout,
 			InstanceType:             b.config.InstanceType,
 			UserData:                 b.config.UserData,
 			UserDataFile:             b.config.UserDataFile,
@@ -176,7 +183,6 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 		&common.StepProvision{},
 		&awscommon.StepStopEBSBackedInstance{
-			SpotPrice:           b.config.SpotPrice,
 			DisableStopInstance: b.config.DisableStopInstance,
 		},
 		&awscommon.StepModifyEBSBackedInstance{
@@ -220,9 +226,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 	}
 
-	// Run!
-	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
-	b.runner.Run(state)
+	// Run! Builds are retried from the start when their spot instance is
+	// interrupted.
+	state := awscommon.RunWithSpotRetry(ui, b.config.SpotInterruptionRetries, map[string]interface{}{
+		"config": b.config,
+		"ec2":    ec2conn,
+		"hook":   hook,
+		"ui":     ui,
+	}, func(state multistep.StateBag) {
+		b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+		b.runner.Run(state)
+	})
 
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk("error"); ok {
@@ -240,6 +254,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		BuilderIdValue: BuilderId,
 		Conn:           ec2conn,
 	}
+	if facts, ok := state.GetOk("guest_facts"); ok {
+		artifact.GuestFacts = facts.(*packer.GuestFacts)
+	}
 
 	return artifact, nil
 }
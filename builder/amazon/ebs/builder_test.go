@@ -26,6 +26,26 @@ func TestBuilder_ImplementsBuilder(t *testing.T) {
 	}
 }
 
+func TestBuilder_ImplementsValidator(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Validator); !ok {
+		t.Fatalf("Builder should be a validator")
+	}
+}
+
+func TestBuilder_Validate_Local(t *testing.T) {
+	b := &Builder{}
+	if _, err := b.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Without remote checks no API calls are made
+	if err := b.Validate(&packer.ValidateContext{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestBuilder_Prepare_BadType(t *testing.T) {
 	b := &Builder{}
 	c := map[string]interface{}{
@@ -91,6 +91,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	return nil, nil
 }
 
+func (b *Builder) Validate(ctx *packer.ValidateContext) error {
+	return awscommon.ValidateRemote(ctx, &b.config.AccessConfig, &b.config.RunConfig)
+}
+
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
 	session, err := b.config.Session()
 	if err != nil {
@@ -115,13 +119,6 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		}
 	}
 
-	// Setup the state bag and initial state for the steps
-	state := new(multistep.BasicStateBag)
-	state.Put("config", &b.config)
-	state.Put("ec2", ec2conn)
-	state.Put("hook", hook)
-	state.Put("ui", ui)
-
 	// Build the steps
 	steps := []multistep.Step{
 		&awscommon.StepPreValidate{
@@ -151,7 +148,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Debug:                    b.config.PackerDebug,
 			ExpectedRootDevice:       "ebs",
 			SpotPrice:                b.config.SpotPrice,
+			SpotPriceMax:             b.config.SpotPriceMax,
 			SpotPriceProduct:         b.config.SpotPriceAutoProduct,
+			SpotFallbackOnDemand:     b.config.SpotFallbackOnDemand,
+			SpotRequestTimeout:       b.config.SpotRequestTimeout,
 			InstanceType:             b.config.InstanceType,
 			UserData:                 b.config.UserData,
 			UserDataFile:             b.config.UserDataFile,
@@ -186,7 +186,6 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 		&common.StepProvision{},
 		&awscommon.StepStopEBSBackedInstance{
-			SpotPrice:           b.config.SpotPrice,
 			DisableStopInstance: b.config.DisableStopInstance,
 		},
 		&awscommon.StepModifyEBSBackedInstance{
@@ -237,9 +236,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 	}
 
-	// Run!
-	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
-	b.runner.Run(state)
+	// Run! Builds are retried from the start when their spot instance is
+	// interrupted.
+	state := awscommon.RunWithSpotRetry(ui, b.config.SpotInterruptionRetries, map[string]interface{}{
+		"config": &b.config,
+		"ec2":    ec2conn,
+		"hook":   hook,
+		"ui":     ui,
+	}, func(state multistep.StateBag) {
+		b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+		b.runner.Run(state)
+	})
 
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk("error"); ok {
@@ -253,6 +260,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			BuilderIdValue: BuilderId,
 			Conn:           ec2conn,
 		}
+		if facts, ok := state.GetOk("guest_facts"); ok {
+			artifact.GuestFacts = facts.(*packer.GuestFacts)
+		}
 
 		return artifact, nil
 	}
@@ -47,7 +47,7 @@ func (s *StepRegisterAMI) Run(state multistep.StateBag) multistep.StepAction {
 		// As of February 2017, this applies to C5, I3, P2, R4, X1, and m4.16xlarge
 		registerOpts.EnaSupport = aws.Bool(true)
 	}
-	registerResp, err := ec2conn.RegisterImage(registerOpts)
+	registerResp, err := awscommon.RegisterImage(ec2conn, registerOpts, &config.AMIConfig)
 	if err != nil {
 		state.Put("error", fmt.Errorf("Error registering AMI: %s", err))
 		ui.Error(state.Get("error").(error).Error())
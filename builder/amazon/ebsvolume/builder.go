@@ -70,6 +70,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	return nil, nil
 }
 
+func (b *Builder) Validate(ctx *packer.ValidateContext) error {
+	return awscommon.ValidateRemote(ctx, &b.config.AccessConfig, &b.config.RunConfig)
+}
+
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
 	session, err := b.config.Session()
 	if err != nil {
@@ -94,13 +98,6 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		}
 	}
 
-	// Setup the state bag and initial state for the steps
-	state := new(multistep.BasicStateBag)
-	state.Put("config", b.config)
-	state.Put("ec2", ec2conn)
-	state.Put("hook", hook)
-	state.Put("ui", ui)
-
 	// Build the steps
 	steps := []multistep.Step{
 		&awscommon.StepSourceAMIInfo{
@@ -126,7 +123,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Debug:                    b.config.PackerDebug,
 			ExpectedRootDevice:       "ebs",
 			SpotPrice:                b.config.SpotPrice,
+			SpotPriceMax:             b.config.SpotPriceMax,
 			SpotPriceProduct:         b.config.SpotPriceAutoProduct,
+			SpotFallbackOnDemand:     b.config.SpotFallbackOnDemand,
+			SpotRequestTimeout:       b.config.SpotRequestTimeout,
 			InstanceType:             b.config.InstanceType,
 			UserData:                 b.config.UserData,
 			UserDataFile:             b.config.UserDataFile,
@@ -162,7 +162,6 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 		&common.StepProvision{},
 		&awscommon.StepStopEBSBackedInstance{
-			SpotPrice:           b.config.SpotPrice,
 			DisableStopInstance: b.config.DisableStopInstance,
 		},
 		&awscommon.StepModifyEBSBackedInstance{
@@ -171,9 +170,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 	}
 
-	// Run!
-	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
-	b.runner.Run(state)
+	// Run! Builds are retried from the start when their spot instance is
+	// interrupted.
+	state := awscommon.RunWithSpotRetry(ui, b.config.SpotInterruptionRetries, map[string]interface{}{
+		"config": b.config,
+		"ec2":    ec2conn,
+		"hook":   hook,
+		"ui":     ui,
+	}, func(state multistep.StateBag) {
+		b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+		b.runner.Run(state)
+	})
 
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk("error"); ok {
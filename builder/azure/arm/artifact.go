@@ -109,6 +109,12 @@ func (a *Artifact) State(name string) interface{} {
 	switch name {
 	case "atlas.artifact.metadata":
 		return a.stateAtlasMetadata()
+	case "ManagedImageResourceGroupName":
+		return a.ManagedImageResourceGroupName
+	case "ManagedImageName":
+		return a.ManagedImageName
+	case "ManagedImageLocation":
+		return a.ManagedImageLocation
 	default:
 		return nil
 	}
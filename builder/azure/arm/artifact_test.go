@@ -82,6 +82,24 @@ func TestArtifactString(t *testing.T) {
 	}
 }
 
+func TestArtifactState_managedImage(t *testing.T) {
+	artifact, err := NewManagedImageArtifact("fakeResourceGroup", "fakeName", "fakeLocation")
+	if err != nil {
+		t.Fatalf("err=%s", err)
+	}
+
+	expected := map[string]string{
+		"ManagedImageResourceGroupName": "fakeResourceGroup",
+		"ManagedImageName":              "fakeName",
+		"ManagedImageLocation":          "fakeLocation",
+	}
+	for name, value := range expected {
+		if artifact.State(name) != value {
+			t.Errorf("Expected State(%q) to be %q, but got %v", name, value, artifact.State(name))
+		}
+	}
+}
+
 func TestArtifactProperties(t *testing.T) {
 	template := CaptureTemplate{
 		Resources: []CaptureResources{
@@ -123,6 +123,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	b.setRuntimeParameters(b.stateBag)
+	b.setGuestFacts(b.stateBag)
 	b.setTemplateParameters(b.stateBag)
 	b.setImageParameters(b.stateBag)
 	var steps []multistep.Step
@@ -300,6 +301,17 @@ func (b *Builder) setRuntimeParameters(stateBag multistep.StateBag) {
 	stateBag.Put(constants.ArmManagedImageLocation, b.config.manageImageLocation)
 }
 
+func (b *Builder) setGuestFacts(stateBag multistep.StateBag) {
+	facts := new(packer.GuestFacts)
+	switch b.config.OSType {
+	case constants.Target_Linux:
+		facts.OSFamily = "linux"
+	case constants.Target_Windows:
+		facts.OSFamily = "windows"
+	}
+	stateBag.Put("guest_facts", facts)
+}
+
 func (b *Builder) setTemplateParameters(stateBag multistep.StateBag) {
 	stateBag.Put(constants.ArmVirtualMachineCaptureParameters, b.config.toVirtualMachineCaptureParameters())
 }
@@ -38,6 +38,14 @@ const (
 	DefaultVMSize                            = "Standard_A1"
 )
 
+// ManagedImageCreatedTag is the tag of the managed images with the time
+// they were built, in RFC 3339 format, since Azure doesn't report when
+// images are created.
+const ManagedImageCreatedTag = "packer_created"
+
+// maxTags is the number of tags Azure supports on a resource.
+const maxTags = 15
+
 var (
 	reCaptureContainerName = regexp.MustCompile("^[a-z0-9][a-z0-9\\-]{2,62}$")
 	reCaptureNamePrefix    = regexp.MustCompile("^[A-Za-z0-9][A-Za-z0-9_\\-\\.]{0,23}$")
@@ -145,6 +153,16 @@ func (c *Config) toVirtualMachineCaptureParameters() *compute.VirtualMachineCapt
 }
 
 func (c *Config) toImageParameters() *compute.Image {
+	// The creation time is added to a copy, the tags are shared with the
+	// other resources of the build. It is left out if there is no room.
+	tags := make(map[string]*string, len(c.AzureTags)+1)
+	for k, v := range c.AzureTags {
+		tags[k] = v
+	}
+	if len(tags) < maxTags {
+		tags[ManagedImageCreatedTag] = to.StringPtr(time.Now().UTC().Format(time.RFC3339))
+	}
+
 	return &compute.Image{
 		ImageProperties: &compute.ImageProperties{
 			SourceVirtualMachine: &compute.SubResource{
@@ -152,7 +170,7 @@ func (c *Config) toImageParameters() *compute.Image {
 			},
 		},
 		Location: to.StringPtr(c.Location),
-		Tags:     &c.AzureTags,
+		Tags:     &tags,
 	}
 }
 
@@ -352,6 +370,18 @@ func setUserNamePassword(c *Config) {
 }
 
 func setCloudEnvironment(c *Config) error {
+	env, err := CloudEnvironment(c.CloudEnvironmentName)
+	if err != nil {
+		return err
+	}
+
+	c.cloudEnvironment = env
+	return nil
+}
+
+// CloudEnvironment returns the Azure cloud with the name, such as Public
+// or China, like the cloud_environment_name of the builder.
+func CloudEnvironment(cloudEnvironmentName string) (*azure.Environment, error) {
 	lookup := map[string]string{
 		"CHINA":           "AzureChinaCloud",
 		"CHINACLOUD":      "AzureChinaCloud",
@@ -374,15 +404,14 @@ func setCloudEnvironment(c *Config) error {
 		"AZUREUSGOVERNMENTCLOUD": "AzureUSGovernmentCloud",
 	}
 
-	name := strings.ToUpper(c.CloudEnvironmentName)
+	name := strings.ToUpper(cloudEnvironmentName)
 	envName, ok := lookup[name]
 	if !ok {
-		return fmt.Errorf("There is no cloud envionment matching the name '%s'!", c.CloudEnvironmentName)
+		return nil, fmt.Errorf("There is no cloud envionment matching the name '%s'!", cloudEnvironmentName)
 	}
 
 	env, err := azure.EnvironmentFromName(envName)
-	c.cloudEnvironment = &env
-	return err
+	return &env, err
 }
 
 func setCustomData(c *Config) error {
@@ -418,8 +447,8 @@ func provideDefaultValues(c *Config) {
 }
 
 func assertTagProperties(c *Config, errs *packer.MultiError) {
-	if len(c.AzureTags) > 15 {
-		errs = packer.MultiErrorAppend(errs, fmt.Errorf("a max of 15 tags are supported, but %d were provided", len(c.AzureTags)))
+	if len(c.AzureTags) > maxTags {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("a max of %d tags are supported, but %d were provided", maxTags, len(c.AzureTags)))
 	}
 
 	for k, v := range c.AzureTags {
@@ -593,6 +622,9 @@ func assertRequiredParametersSet(c *Config, errs *packer.MultiError) {
 	if c.VirtualNetworkName == "" && c.VirtualNetworkSubnetName != "" {
 		errs = packer.MultiErrorAppend(errs, fmt.Errorf("If virtual_network_subnet_name is specified, so must virtual_network_name"))
 	}
+	if c.VirtualNetworkName == "" && c.PrivateVirtualNetworkWithPublicIp {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("If private_virtual_network_with_public_ip is specified, so must virtual_network_name"))
+	}
 
 	/////////////////////////////////////////////
 	// OS
@@ -263,6 +263,28 @@ func TestConfigVirtualNetworkSubnetNameMustBeSetWithVirtualNetworkName(t *testin
 	}
 }
 
+// A public IP is only optional in a pre-existing virtual network, the
+// builder always creates one in the virtual network it creates itself.
+func TestConfigPrivateVirtualNetworkWithPublicIpMustBeSetWithVirtualNetworkName(t *testing.T) {
+	config := map[string]string{
+		"capture_name_prefix":                    "ignore",
+		"capture_container_name":                 "ignore",
+		"location":                               "ignore",
+		"image_url":                              "ignore",
+		"storage_account":                        "ignore",
+		"resource_group_name":                    "ignore",
+		"subscription_id":                        "ignore",
+		"os_type":                                constants.Target_Linux,
+		"communicator":                           "none",
+		"private_virtual_network_with_public_ip": "true",
+	}
+
+	_, _, err := newConfig(config, getPackerConfiguration())
+	if err == nil {
+		t.Error("Expected Config to reject private_virtual_network_with_public_ip, if virtual_network_name is not set.")
+	}
+}
+
 func TestConfigShouldDefaultToPublicCloud(t *testing.T) {
 	c, _, _ := newConfig(getArmBuilderConfiguration(), getPackerConfiguration())
 
@@ -630,6 +652,40 @@ func TestConfigShouldAcceptTags(t *testing.T) {
 	}
 }
 
+func TestConfigImageParametersShouldTagCreationTime(t *testing.T) {
+	tag := "value01"
+	c := &Config{
+		AzureTags: map[string]*string{"tag01": &tag},
+	}
+
+	before := time.Now().Add(-time.Second)
+	tags := *c.toImageParameters().Tags
+	if len(tags) != 2 || *tags["tag01"] != "value01" {
+		t.Fatalf("bad: %#v", tags)
+	}
+	created, err := time.Parse(time.RFC3339, *tags[ManagedImageCreatedTag])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if created.Before(before.Truncate(time.Second)) {
+		t.Fatalf("bad: %s", created)
+	}
+
+	// The tags of the other resources are left as is
+	if len(c.AzureTags) != 1 {
+		t.Fatalf("bad: %#v", c.AzureTags)
+	}
+
+	// There is no room for the tag
+	for i := 0; i < 14; i++ {
+		c.AzureTags[fmt.Sprintf("tag%.2d", i+2)] = &tag
+	}
+	tags = *c.toImageParameters().Tags
+	if _, ok := tags[ManagedImageCreatedTag]; ok || len(tags) != 15 {
+		t.Fatalf("bad: %#v", tags)
+	}
+}
+
 func TestConfigShouldRejectTagsInExcessOf15AcceptTags(t *testing.T) {
 	tooManyTags := map[string]string{}
 	for i := 0; i < 16; i++ {
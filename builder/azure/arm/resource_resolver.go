@@ -35,12 +35,15 @@ func (s *resourceResolver) Resolve(c *Config) error {
 			return err
 		}
 
-		subnetName, err := s.findVirtualNetworkSubnet(s.client, resourceGroupName, c.VirtualNetworkName)
+		c.VirtualNetworkResourceGroupName = resourceGroupName
+	}
+
+	if s.shouldResolveSubnet(c) {
+		subnetName, err := s.findVirtualNetworkSubnet(s.client, c.VirtualNetworkResourceGroupName, c.VirtualNetworkName)
 		if err != nil {
 			return err
 		}
 
-		c.VirtualNetworkResourceGroupName = resourceGroupName
 		c.VirtualNetworkSubnetName = subnetName
 	}
 
@@ -60,6 +63,10 @@ func (s *resourceResolver) shouldResolveResourceGroup(c *Config) bool {
 	return c.VirtualNetworkName != "" && c.VirtualNetworkResourceGroupName == ""
 }
 
+func (s *resourceResolver) shouldResolveSubnet(c *Config) bool {
+	return c.VirtualNetworkName != "" && c.VirtualNetworkSubnetName == ""
+}
+
 func (s *resourceResolver) shouldResolveManagedImageName(c *Config) bool {
 	return c.CustomManagedImageName != ""
 }
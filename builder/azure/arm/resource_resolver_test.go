@@ -63,6 +63,50 @@ func TestResourceResolverSetVirtualNetworkResourceGroupName(t *testing.T) {
 	}
 }
 
+// If the user set the virtual network name and resource group but not the
+// subnet, only the subnet should be resolved, in that resource group.
+func TestResourceResolverSetVirtualNetworkSubnetName(t *testing.T) {
+	c, _, _ := newConfig(getArmBuilderConfiguration(), getPackerConfiguration())
+	c.VirtualNetworkName = "--virtual-network-name--"
+	c.VirtualNetworkResourceGroupName = "--virtual-network-resource-group-name--"
+
+	sut := newTestResourceResolver()
+	sut.findVirtualNetworkResourceGroup = nil // assert that this is not even called
+	sut.findVirtualNetworkSubnet = func(_ *AzureClient, resourceGroupName string, _ string) (string, error) {
+		if resourceGroupName != "--virtual-network-resource-group-name--" {
+			t.Fatalf("Expected the subnet to be looked up in --virtual-network-resource-group-name--, but got %q", resourceGroupName)
+		}
+		return "findVirtualNetworkSubnet is mocked", nil
+	}
+	sut.Resolve(c)
+
+	if c.VirtualNetworkResourceGroupName != "--virtual-network-resource-group-name--" {
+		t.Fatalf("Expected VirtualNetworkResourceGroupName to be --virtual-network-resource-group-name--")
+	}
+	if c.VirtualNetworkSubnetName != "findVirtualNetworkSubnet is mocked" {
+		t.Fatalf("Expected VirtualNetworkSubnetName to be 'findVirtualNetworkSubnet is mocked'")
+	}
+}
+
+// If the user set the virtual network name and subnet but not the resource
+// group, the subnet should be kept.
+func TestResourceResolverKeepsVirtualNetworkSubnetName(t *testing.T) {
+	c, _, _ := newConfig(getArmBuilderConfiguration(), getPackerConfiguration())
+	c.VirtualNetworkName = "--virtual-network-name--"
+	c.VirtualNetworkSubnetName = "--virtual-network-subnet-name--"
+
+	sut := newTestResourceResolver()
+	sut.findVirtualNetworkSubnet = nil // assert that this is not even called
+	sut.Resolve(c)
+
+	if c.VirtualNetworkResourceGroupName != "findVirtualNetworkResourceGroup is mocked" {
+		t.Fatalf("Expected VirtualNetworkResourceGroupName to be 'findVirtualNetworkResourceGroup is mocked'")
+	}
+	if c.VirtualNetworkSubnetName != "--virtual-network-subnet-name--" {
+		t.Fatalf("Expected VirtualNetworkSubnetName to be --virtual-network-subnet-name--")
+	}
+}
+
 func newTestResourceResolver() resourceResolver {
 	return resourceResolver{
 		client: nil,
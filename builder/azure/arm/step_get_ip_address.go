@@ -84,7 +84,9 @@ func (s *StepGetIPAddress) Run(state multistep.StateBag) multistep.StepAction {
 	var nicName = state.Get(constants.ArmNicName).(string)
 
 	s.say(fmt.Sprintf(" -> ResourceGroupName   : '%s'", resourceGroupName))
-	s.say(fmt.Sprintf(" -> PublicIPAddressName : '%s'", ipAddressName))
+	if s.endpoint != PrivateEndpoint {
+		s.say(fmt.Sprintf(" -> PublicIPAddressName : '%s'", ipAddressName))
+	}
 	s.say(fmt.Sprintf(" -> NicName             : '%s'", nicName))
 	s.say(fmt.Sprintf(" -> Network Connection  : '%s'", EndpointCommunicationText[s.endpoint]))
 
@@ -0,0 +1,58 @@
+package chroot
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// Artifact is the managed image created by the builder.
+type Artifact struct {
+	client        *azureClient
+	id            string
+	location      string
+	name          string
+	resourceGroup string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Destroy() error {
+	_, errCh := a.client.ImagesClient.Delete(a.resourceGroup, a.name, nil)
+	return <-errCh
+}
+
+func (*Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return a.id
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("A managed image was created: %s (resource group %s, location %s)",
+		a.name, a.resourceGroup, a.location)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	switch name {
+	case "ManagedImageName":
+		return a.name
+	case "ManagedImageResourceGroupName":
+		return a.resourceGroup
+	case "ManagedImageLocation":
+		return a.location
+	}
+	return nil
+}
+
+// Metadata returns the id and the location of the image.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	return packer.ArtifactMetadata{
+		packer.MetadataImageId: {a.id},
+		packer.MetadataRegion:  {a.location},
+	}
+}
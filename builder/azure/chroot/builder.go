@@ -0,0 +1,230 @@
+// The chroot package is able to create an Azure managed image without
+// launching a new virtual machine for every build. It does this by
+// creating a managed disk from the source, attaching it to the virtual
+// machine Packer runs on and chrooting into it. It then creates a managed
+// image from that disk.
+package chroot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/multistep"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.azure-chroot"
+
+// Config is the configuration that is chained through the steps and
+// settable from the template.
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+	chroot.Config       `mapstructure:",squash"`
+
+	ClientID             string `mapstructure:"client_id"`
+	ClientSecret         string `mapstructure:"client_secret"`
+	CloudEnvironmentName string `mapstructure:"cloud_environment_name"`
+	SubscriptionID       string `mapstructure:"subscription_id"`
+	TenantID             string `mapstructure:"tenant_id"`
+
+	ImageName              string `mapstructure:"image_name"`
+	ImageResourceGroupName string `mapstructure:"image_resource_group_name"`
+	OSDiskName             string `mapstructure:"os_disk_name"`
+	OSDiskSizeGB           int32  `mapstructure:"os_disk_size_gb"`
+	OSDiskStorageAccount   string `mapstructure:"os_disk_storage_account_type"`
+	Source                 string `mapstructure:"source"`
+
+	cloud azure.Environment
+	ctx   interpolate.Context
+}
+
+type Builder struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	err := config.Decode(&b.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &b.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: chroot.FilterExclude,
+		},
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accumulate any errors
+	var errs *packer.MultiError
+	errs = packer.MultiErrorAppend(errs, b.config.Config.Prepare(
+		&b.config.ctx, "/mnt/packer-azure-chroot-disks/{{.Device}}")...)
+
+	if b.config.CloudEnvironmentName == "" {
+		b.config.CloudEnvironmentName = "AzurePublicCloud"
+	}
+	b.config.cloud, err = azure.EnvironmentFromName(b.config.CloudEnvironmentName)
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"There is no cloud environment matching the name '%s'!", b.config.CloudEnvironmentName))
+	}
+
+	if b.config.OSDiskName == "" {
+		name, err := interpolate.Render("packer-osdisk-{{timestamp}}", nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Unable to parse os_disk_name: %s ", err))
+		}
+		b.config.OSDiskName = name
+	}
+
+	if b.config.OSDiskStorageAccount == "" {
+		b.config.OSDiskStorageAccount = "Standard_LRS"
+	}
+
+	for _, required := range []struct {
+		name, value string
+	}{
+		{"client_id", b.config.ClientID},
+		{"client_secret", b.config.ClientSecret},
+		{"subscription_id", b.config.SubscriptionID},
+		{"tenant_id", b.config.TenantID},
+		{"image_name", b.config.ImageName},
+		{"image_resource_group_name", b.config.ImageResourceGroupName},
+		{"source", b.config.Source},
+	} {
+		if required.value == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("A %s must be specified", required.name))
+		}
+	}
+
+	if b.config.Source != "" && !isDiskID(b.config.Source) {
+		if _, err := parseImageURN(b.config.Source); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if b.config.OSDiskSizeGB < 0 {
+		errs = packer.MultiErrorAppend(errs, errors.New("os_disk_size_gb can't be negative"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, errs
+	}
+
+	log.Println(common.ScrubConfig(b.config, b.config.ClientSecret))
+	return nil, nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("The azure-chroot builder only works on Linux environments.")
+	}
+
+	client, err := newAzureClient(&b.config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("azureclient", client)
+	state.Put("config", &b.config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+	state.Put("wrappedCommand", b.config.WrappedCommand(b.config.ctx))
+
+	// Build the steps
+	steps := []multistep.Step{
+		&stepInstanceInfo{},
+		&stepCreateDisk{},
+		&stepAttachDisk{},
+		&chroot.StepPreMountCommands{
+			Commands: b.config.PreMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountDevice{
+			MountPath:      b.config.MountPath,
+			MountOptions:   b.config.MountOptions,
+			MountPartition: fmt.Sprintf("-part%d", b.config.MountPartition),
+			Ctx:            b.config.ctx,
+		},
+		&chroot.StepPostMountCommands{
+			Commands: b.config.PostMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountExtra{
+			ChrootMounts: b.config.ChrootMounts,
+		},
+		&chroot.StepCopyFiles{
+			Files: b.config.CopyFiles,
+		},
+		&chroot.StepChrootProvision{},
+		&chroot.StepEarlyCleanup{},
+		&stepCreateImage{},
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If there is no image, then just return
+	if _, ok := state.GetOk("image_id"); !ok {
+		return nil, nil
+	}
+
+	artifact := &Artifact{
+		client:        client,
+		id:            state.Get("image_id").(string),
+		location:      state.Get("instance").(*instanceInfo).Location,
+		name:          b.config.ImageName,
+		resourceGroup: b.config.ImageResourceGroupName,
+	}
+	return artifact, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
+
+// isDiskID returns true if source is the resource id of a managed disk.
+func isDiskID(source string) bool {
+	return strings.HasPrefix(strings.ToLower(source), "/subscriptions/") &&
+		strings.Contains(strings.ToLower(source), "/providers/microsoft.compute/disks/")
+}
+
+// imageURN is a platform image, written Publisher:Offer:Sku:Version.
+type imageURN struct {
+	Publisher, Offer, Sku, Version string
+}
+
+func parseImageURN(source string) (*imageURN, error) {
+	parts := strings.Split(source, ":")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf(
+			"source must be the id of a managed disk or an image URN like Publisher:Offer:Sku:Version, not %q", source)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("source %q has an empty part", source)
+		}
+	}
+	return &imageURN{parts[0], parts[1], parts[2], parts[3]}, nil
+}
@@ -0,0 +1,85 @@
+package chroot
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"client_id":                 "client",
+		"client_secret":             "secret",
+		"subscription_id":           "subscription",
+		"tenant_id":                 "tenant",
+		"image_name":                "image",
+		"image_resource_group_name": "images",
+		"source":                    "Canonical:UbuntuServer:16.04-LTS:latest",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	if _, err := b.Prepare(testConfig()); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.CloudEnvironmentName != "AzurePublicCloud" {
+		t.Errorf("bad cloud_environment_name: %s", b.config.CloudEnvironmentName)
+	}
+	if b.config.OSDiskStorageAccount != "Standard_LRS" {
+		t.Errorf("bad os_disk_storage_account_type: %s", b.config.OSDiskStorageAccount)
+	}
+	if b.config.OSDiskName == "" {
+		t.Error("os_disk_name should have a default")
+	}
+	if b.config.MountPath != "/mnt/packer-azure-chroot-disks/{{.Device}}" {
+		t.Errorf("bad mount_path: %s", b.config.MountPath)
+	}
+}
+
+func TestBuilderPrepare(t *testing.T) {
+	cases := []struct {
+		Name  string
+		Key   string
+		Value interface{}
+		Err   bool
+	}{
+		{"no client id", "client_id", nil, true},
+		{"no client secret", "client_secret", nil, true},
+		{"no subscription", "subscription_id", nil, true},
+		{"no tenant", "tenant_id", nil, true},
+		{"no image name", "image_name", nil, true},
+		{"no image resource group", "image_resource_group_name", nil, true},
+		{"no source", "source", nil, true},
+		{"disk source", "source", "/subscriptions/1234/resourceGroups/rg/providers/Microsoft.Compute/disks/disk", false},
+		{"short urn", "source", "Canonical:UbuntuServer:16.04-LTS", true},
+		{"empty urn part", "source", "Canonical::16.04-LTS:latest", true},
+		{"bad cloud", "cloud_environment_name", "Nowhere", true},
+		{"china cloud", "cloud_environment_name", "AzureChinaCloud", false},
+		{"negative disk size", "os_disk_size_gb", -1, true},
+	}
+
+	for _, tc := range cases {
+		config := testConfig()
+		if tc.Value == nil {
+			delete(config, tc.Key)
+		} else {
+			config[tc.Key] = tc.Value
+		}
+
+		var b Builder
+		_, err := b.Prepare(config)
+		if (err != nil) != tc.Err {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+		}
+	}
+}
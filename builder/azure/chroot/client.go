@@ -0,0 +1,51 @@
+package chroot
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/hashicorp/packer/version"
+)
+
+var packerUserAgent = fmt.Sprintf(";packer/%s", version.FormattedVersion())
+
+// azureClient holds the clients of the APIs the steps use.
+type azureClient struct {
+	compute.ImagesClient
+	compute.VirtualMachineImagesClient
+	compute.VirtualMachinesClient
+	disks disksClient
+}
+
+func newAzureClient(c *Config) (*azureClient, error) {
+	oauthConfig, err := adal.NewOAuthConfig(c.cloud.ActiveDirectoryEndpoint, c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	spt, err := adal.NewServicePrincipalToken(
+		*oauthConfig, c.ClientID, c.ClientSecret, c.cloud.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURI := c.cloud.ResourceManagerEndpoint
+	client := &azureClient{
+		ImagesClient:               compute.NewImagesClientWithBaseURI(baseURI, c.SubscriptionID),
+		VirtualMachineImagesClient: compute.NewVirtualMachineImagesClientWithBaseURI(baseURI, c.SubscriptionID),
+		VirtualMachinesClient:      compute.NewVirtualMachinesClientWithBaseURI(baseURI, c.SubscriptionID),
+		disks:                      newDisksClient(baseURI, c.SubscriptionID),
+	}
+	for _, ac := range []*autorest.Client{
+		&client.ImagesClient.Client,
+		&client.VirtualMachineImagesClient.Client,
+		&client.VirtualMachinesClient.Client,
+		&client.disks.Client,
+	} {
+		ac.Authorizer = autorest.NewBearerAuthorizer(spt)
+		ac.UserAgent += packerUserAgent
+	}
+
+	return client, nil
+}
@@ -0,0 +1,117 @@
+package chroot
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// The vendored compute SDK has no client for managed disks, so disksClient
+// implements the few operations of the disks API the builder uses.
+const disksAPIVersion = "2017-03-30"
+
+// disk is a managed disk.
+type disk struct {
+	ID         *string         `json:"id,omitempty"`
+	Name       *string         `json:"name,omitempty"`
+	Location   *string         `json:"location,omitempty"`
+	Sku        *diskSku        `json:"sku,omitempty"`
+	Properties *diskProperties `json:"properties,omitempty"`
+}
+
+type diskSku struct {
+	Name string `json:"name,omitempty"`
+}
+
+type diskProperties struct {
+	OsType       compute.OperatingSystemTypes `json:"osType,omitempty"`
+	CreationData *diskCreationData            `json:"creationData,omitempty"`
+	DiskSizeGB   *int32                       `json:"diskSizeGB,omitempty"`
+}
+
+type diskCreationData struct {
+	CreateOption     string              `json:"createOption"`
+	ImageReference   *diskImageReference `json:"imageReference,omitempty"`
+	SourceResourceID *string             `json:"sourceResourceId,omitempty"`
+}
+
+type diskImageReference struct {
+	ID *string `json:"id"`
+}
+
+type disksClient struct {
+	compute.ManagementClient
+}
+
+func newDisksClient(baseURI, subscriptionID string) disksClient {
+	return disksClient{compute.NewWithBaseURI(baseURI, subscriptionID)}
+}
+
+// CreateOrUpdate creates a disk and waits for it to be provisioned.
+func (client disksClient) CreateOrUpdate(resourceGroupName, diskName string, parameters disk) (result disk, err error) {
+	req, err := client.prepare(resourceGroupName, diskName,
+		autorest.AsJSON(),
+		autorest.AsPut(),
+		autorest.WithJSON(parameters))
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "chroot.disksClient", "CreateOrUpdate", nil, "Failure preparing request")
+	}
+
+	resp, err := autorest.SendWithSender(client, req, azure.DoPollForAsynchronous(client.PollingDelay))
+	if err != nil {
+		return result, autorest.NewErrorWithError(err, "chroot.disksClient", "CreateOrUpdate", resp, "Failure sending request")
+	}
+
+	err = autorest.Respond(
+		resp,
+		client.ByInspecting(),
+		azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusCreated),
+		autorest.ByUnmarshallingJSON(&result),
+		autorest.ByClosing())
+	if err != nil {
+		err = autorest.NewErrorWithError(err, "chroot.disksClient", "CreateOrUpdate", resp, "Failure responding to request")
+	}
+	return result, err
+}
+
+// Delete deletes a disk and waits for it to be gone.
+func (client disksClient) Delete(resourceGroupName, diskName string) error {
+	req, err := client.prepare(resourceGroupName, diskName, autorest.AsDelete())
+	if err != nil {
+		return autorest.NewErrorWithError(err, "chroot.disksClient", "Delete", nil, "Failure preparing request")
+	}
+
+	resp, err := autorest.SendWithSender(client, req, azure.DoPollForAsynchronous(client.PollingDelay))
+	if err != nil {
+		return autorest.NewErrorWithError(err, "chroot.disksClient", "Delete", resp, "Failure sending request")
+	}
+
+	err = autorest.Respond(
+		resp,
+		client.ByInspecting(),
+		azure.WithErrorUnlessStatusCode(http.StatusOK, http.StatusAccepted, http.StatusNoContent),
+		autorest.ByClosing())
+	if err != nil {
+		return autorest.NewErrorWithError(err, "chroot.disksClient", "Delete", resp, "Failure responding to request")
+	}
+	return nil
+}
+
+func (client disksClient) prepare(resourceGroupName, diskName string, decorators ...autorest.PrepareDecorator) (*http.Request, error) {
+	pathParameters := map[string]interface{}{
+		"diskName":          autorest.Encode("path", diskName),
+		"resourceGroupName": autorest.Encode("path", resourceGroupName),
+		"subscriptionId":    autorest.Encode("path", client.SubscriptionID),
+	}
+	queryParameters := map[string]interface{}{
+		"api-version": disksAPIVersion,
+	}
+
+	decorators = append(decorators,
+		autorest.WithBaseURL(client.BaseURI),
+		autorest.WithPathParameters("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/disks/{diskName}", pathParameters),
+		autorest.WithQueryParameters(queryParameters))
+	return autorest.CreatePreparer(decorators...).Prepare(&http.Request{})
+}
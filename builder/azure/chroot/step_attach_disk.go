@@ -0,0 +1,133 @@
+package chroot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// deviceTimeout is how long the attached disk has to appear.
+const deviceTimeout = 5 * time.Minute
+
+// stepAttachDisk attaches the disk as a data disk of the virtual machine
+// Packer runs on, at the first free LUN.
+//
+// Produces:
+//
+//	device string - The path of the attached disk.
+//	attach_cleanup CleanupFunc - To perform early cleanup
+type stepAttachDisk struct {
+	diskID string
+}
+
+func (s *stepAttachDisk) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("azureclient").(*azureClient)
+	config := state.Get("config").(*Config)
+	diskID := state.Get("disk_id").(string)
+	instance := state.Get("instance").(*instanceInfo)
+	ui := state.Get("ui").(packer.Ui)
+
+	vm, err := client.VirtualMachinesClient.Get(instance.ResourceGroupName, instance.Name, "")
+	if err != nil {
+		return halt(state, fmt.Errorf("Error getting the virtual machine: %s", err))
+	}
+
+	var disks []compute.DataDisk
+	if vm.StorageProfile.DataDisks != nil {
+		disks = *vm.StorageProfile.DataDisks
+	}
+	lun := freeLUN(disks)
+	disks = append(disks, compute.DataDisk{
+		Lun:          &lun,
+		Name:         &config.OSDiskName,
+		CreateOption: compute.Attach,
+		ManagedDisk:  &compute.ManagedDiskParameters{ID: &diskID},
+	})
+
+	ui.Say(fmt.Sprintf("Attaching disk %s at LUN %d...", config.OSDiskName, lun))
+	if err := updateDataDisks(client, instance, vm, disks); err != nil {
+		return halt(state, fmt.Errorf("Error attaching disk: %s", err))
+	}
+	s.diskID = diskID
+
+	device := fmt.Sprintf("/dev/disk/azure/scsi1/lun%d", lun)
+	if err := chroot.WaitForDevice(device, deviceTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error waiting for the attached disk: %s", err))
+	}
+
+	state.Put("device", device)
+	state.Put("attach_cleanup", s)
+	return multistep.ActionContinue
+}
+
+func (s *stepAttachDisk) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packer.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *stepAttachDisk) CleanupFunc(state multistep.StateBag) error {
+	if s.diskID == "" {
+		return nil
+	}
+
+	client := state.Get("azureclient").(*azureClient)
+	instance := state.Get("instance").(*instanceInfo)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Detaching disk...")
+	vm, err := client.VirtualMachinesClient.Get(instance.ResourceGroupName, instance.Name, "")
+	if err != nil {
+		return fmt.Errorf("Error getting the virtual machine: %s", err)
+	}
+
+	var disks []compute.DataDisk
+	if vm.StorageProfile.DataDisks != nil {
+		for _, d := range *vm.StorageProfile.DataDisks {
+			if d.ManagedDisk != nil && d.ManagedDisk.ID != nil &&
+				strings.EqualFold(*d.ManagedDisk.ID, s.diskID) {
+				continue
+			}
+			disks = append(disks, d)
+		}
+	}
+	if err := updateDataDisks(client, instance, vm, disks); err != nil {
+		return fmt.Errorf("Error detaching disk: %s", err)
+	}
+
+	s.diskID = ""
+	return nil
+}
+
+// freeLUN returns the lowest LUN no data disk uses.
+func freeLUN(disks []compute.DataDisk) int32 {
+	used := make(map[int32]bool)
+	for _, d := range disks {
+		if d.Lun != nil {
+			used[*d.Lun] = true
+		}
+	}
+
+	var lun int32
+	for used[lun] {
+		lun++
+	}
+	return lun
+}
+
+// updateDataDisks replaces the data disks of the virtual machine.
+func updateDataDisks(client *azureClient, instance *instanceInfo, vm compute.VirtualMachine, disks []compute.DataDisk) error {
+	vm.StorageProfile.DataDisks = &disks
+	// The extensions are read-only in an update.
+	vm.Resources = nil
+
+	_, errCh := client.VirtualMachinesClient.CreateOrUpdate(
+		instance.ResourceGroupName, instance.Name, vm, nil)
+	return <-errCh
+}
@@ -0,0 +1,29 @@
+package chroot
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+)
+
+func TestFreeLUN(t *testing.T) {
+	lun := func(n int32) compute.DataDisk {
+		return compute.DataDisk{Lun: &n}
+	}
+
+	cases := []struct {
+		Disks    []compute.DataDisk
+		Expected int32
+	}{
+		{nil, 0},
+		{[]compute.DataDisk{lun(0)}, 1},
+		{[]compute.DataDisk{lun(1), lun(0), lun(3)}, 2},
+		{[]compute.DataDisk{lun(2)}, 0},
+	}
+
+	for _, tc := range cases {
+		if actual := freeLUN(tc.Disks); actual != tc.Expected {
+			t.Errorf("freeLUN of %d disks: expected %d, got %d", len(tc.Disks), tc.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package chroot
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepCreateDisk creates the managed disk that is provisioned, either as a
+// copy of the source disk or from the source platform image. The disk is
+// created in the resource group of the virtual machine and deleted when
+// the build ends.
+//
+// Produces:
+//
+//	disk_id string - The resource id of the disk.
+type stepCreateDisk struct {
+	resourceGroup string
+}
+
+func (s *stepCreateDisk) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("azureclient").(*azureClient)
+	config := state.Get("config").(*Config)
+	instance := state.Get("instance").(*instanceInfo)
+	ui := state.Get("ui").(packer.Ui)
+
+	creationData := &diskCreationData{}
+	if isDiskID(config.Source) {
+		creationData.CreateOption = "Copy"
+		creationData.SourceResourceID = &config.Source
+	} else {
+		imageID, err := platformImageID(client, instance.Location, config.Source)
+		if err != nil {
+			return halt(state, fmt.Errorf("Error getting the source image: %s", err))
+		}
+		creationData.CreateOption = "FromImage"
+		creationData.ImageReference = &diskImageReference{ID: &imageID}
+	}
+
+	params := disk{
+		Location: &instance.Location,
+		Sku:      &diskSku{Name: config.OSDiskStorageAccount},
+		Properties: &diskProperties{
+			OsType:       compute.Linux,
+			CreationData: creationData,
+		},
+	}
+	if config.OSDiskSizeGB > 0 {
+		params.Properties.DiskSizeGB = &config.OSDiskSizeGB
+	}
+
+	ui.Say(fmt.Sprintf("Creating disk %s...", config.OSDiskName))
+	result, err := client.disks.CreateOrUpdate(instance.ResourceGroupName, config.OSDiskName, params)
+	if err != nil {
+		return halt(state, fmt.Errorf("Error creating disk: %s", err))
+	}
+	s.resourceGroup = instance.ResourceGroupName
+
+	state.Put("disk_id", *result.ID)
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateDisk) Cleanup(state multistep.StateBag) {
+	if s.resourceGroup == "" {
+		return
+	}
+
+	client := state.Get("azureclient").(*azureClient)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Deleting disk...")
+	if err := client.disks.Delete(s.resourceGroup, config.OSDiskName); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error deleting disk %s. Please delete it manually: %s", config.OSDiskName, err))
+	}
+}
+
+// platformImageID returns the resource id of the platform image the URN
+// source designates, resolving the "latest" version.
+func platformImageID(client *azureClient, location, source string) (string, error) {
+	urn, err := parseImageURN(source)
+	if err != nil {
+		return "", err
+	}
+
+	version := urn.Version
+	if version == "latest" {
+		top := int32(1)
+		images, err := client.VirtualMachineImagesClient.List(
+			location, urn.Publisher, urn.Offer, urn.Sku, "", &top, "name desc")
+		if err != nil {
+			return "", err
+		}
+		if images.Value == nil || len(*images.Value) == 0 {
+			return "", fmt.Errorf("no version of %s found in %s", source, location)
+		}
+		version = *(*images.Value)[0].Name
+	}
+
+	image, err := client.VirtualMachineImagesClient.Get(
+		location, urn.Publisher, urn.Offer, urn.Sku, version)
+	if err != nil {
+		return "", err
+	}
+	return *image.ID, nil
+}
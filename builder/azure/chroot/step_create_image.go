@@ -0,0 +1,70 @@
+package chroot
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepCreateImage creates the managed image from the detached disk,
+// replacing an existing image of the same name if the build is forced.
+//
+// Produces:
+//
+//	image_id string - The resource id of the image.
+type stepCreateImage struct{}
+
+func (s *stepCreateImage) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("azureclient").(*azureClient)
+	config := state.Get("config").(*Config)
+	diskID := state.Get("disk_id").(string)
+	instance := state.Get("instance").(*instanceInfo)
+	ui := state.Get("ui").(packer.Ui)
+
+	existing, err := client.ImagesClient.Get(config.ImageResourceGroupName, config.ImageName, "")
+	if err == nil {
+		if !config.PackerForce {
+			return halt(state, fmt.Errorf("Image %s already exists in resource group %s.\n"+
+				"Use the force flag to delete it prior to building.",
+				config.ImageName, config.ImageResourceGroupName))
+		}
+
+		ui.Say("Deleting previous image...")
+		_, errCh := client.ImagesClient.Delete(config.ImageResourceGroupName, config.ImageName, nil)
+		if err := <-errCh; err != nil {
+			return halt(state, fmt.Errorf("Error deleting image: %s", err))
+		}
+	} else if existing.Response.Response == nil || existing.StatusCode != http.StatusNotFound {
+		return halt(state, fmt.Errorf("Error looking for an existing image: %s", err))
+	}
+
+	image := compute.Image{
+		Location: &instance.Location,
+		ImageProperties: &compute.ImageProperties{
+			StorageProfile: &compute.ImageStorageProfile{
+				OsDisk: &compute.ImageOSDisk{
+					OsType:      compute.Linux,
+					OsState:     compute.Generalized,
+					ManagedDisk: &compute.SubResource{ID: &diskID},
+				},
+			},
+		},
+	}
+
+	ui.Say(fmt.Sprintf("Creating image %s in resource group %s...",
+		config.ImageName, config.ImageResourceGroupName))
+	resultCh, errCh := client.ImagesClient.CreateOrUpdate(
+		config.ImageResourceGroupName, config.ImageName, image, nil)
+	result := <-resultCh
+	if err := <-errCh; err != nil {
+		return halt(state, fmt.Errorf("Error creating image: %s", err))
+	}
+
+	state.Put("image_id", *result.ID)
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateImage) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,85 @@
+package chroot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// metadataURL is the URL of the instance metadata service of the virtual
+// machine.
+var metadataURL = "http://169.254.169.254/metadata/instance?api-version=2017-08-01"
+
+type instanceInfo struct {
+	Location          string `json:"location"`
+	Name              string `json:"name"`
+	ResourceGroupName string `json:"resourceGroupName"`
+	SubscriptionID    string `json:"subscriptionId"`
+}
+
+// stepInstanceInfo gets the virtual machine Packer runs on from the
+// instance metadata service.
+//
+// Produces:
+//
+//	instance *instanceInfo - The virtual machine.
+type stepInstanceInfo struct{}
+
+func (s *stepInstanceInfo) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Gathering information about this virtual machine...")
+	info, err := instanceMetadata()
+	if err != nil {
+		return halt(state, fmt.Errorf(
+			"Error getting the virtual machine. Is Packer running on an Azure virtual machine? %s", err))
+	}
+	if info.SubscriptionID != config.SubscriptionID {
+		return halt(state, fmt.Errorf(
+			"The virtual machine is in subscription %s, not in subscription_id %s",
+			info.SubscriptionID, config.SubscriptionID))
+	}
+
+	ui.Message(fmt.Sprintf("Virtual machine %s in resource group %s (%s)",
+		info.Name, info.ResourceGroupName, info.Location))
+	state.Put("instance", info)
+	return multistep.ActionContinue
+}
+
+func (s *stepInstanceInfo) Cleanup(multistep.StateBag) {}
+
+func instanceMetadata() (*instanceInfo, error) {
+	req, err := http.NewRequest("GET", metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from the metadata service", resp.Status)
+	}
+
+	var metadata struct {
+		Compute instanceInfo `json:"compute"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	return &metadata.Compute, nil
+}
+
+func halt(state multistep.StateBag, err error) multistep.StepAction {
+	state.Put("error", err)
+	state.Get("ui").(packer.Ui).Error(err.Error())
+	return multistep.ActionHalt
+}
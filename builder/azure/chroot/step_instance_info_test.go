@@ -0,0 +1,63 @@
+package chroot
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepInstanceInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"compute": {"location": "westeurope", "name": "builder",
+			"resourceGroupName": "builders", "subscriptionId": "subscription"}}`))
+	}))
+	defer ts.Close()
+
+	old := metadataURL
+	metadataURL = ts.URL
+	defer func() { metadataURL = old }()
+
+	cases := []struct {
+		Subscription string
+		Action       multistep.StepAction
+	}{
+		{"subscription", multistep.ActionContinue},
+		{"other", multistep.ActionHalt},
+	}
+
+	for _, tc := range cases {
+		state := new(multistep.BasicStateBag)
+		state.Put("config", &Config{SubscriptionID: tc.Subscription})
+		state.Put("ui", &packer.BasicUi{
+			Reader: new(bytes.Buffer),
+			Writer: new(bytes.Buffer),
+		})
+
+		step := new(stepInstanceInfo)
+		if action := step.Run(state); action != tc.Action {
+			t.Fatalf("%s: bad action: %#v", tc.Subscription, action)
+		}
+		if tc.Action != multistep.ActionContinue {
+			continue
+		}
+
+		info := state.Get("instance").(*instanceInfo)
+		expected := instanceInfo{
+			Location:          "westeurope",
+			Name:              "builder",
+			ResourceGroupName: "builders",
+			SubscriptionID:    "subscription",
+		}
+		if *info != expected {
+			t.Errorf("bad instance: %#v", info)
+		}
+	}
+}
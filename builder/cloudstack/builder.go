@@ -59,9 +59,16 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	steps := []multistep.Step{
 		&stepPrepareConfig{},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 		&stepKeypair{
 			Debug:                b.config.PackerDebug,
@@ -2,6 +2,8 @@ package docker
 
 import (
 	"fmt"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 // ImportArtifact is an Artifact implementation for when a container is
@@ -32,6 +34,11 @@ func (*ImportArtifact) State(name string) interface{} {
 	return nil
 }
 
+// Metadata returns the ID of the image.
+func (a *ImportArtifact) Metadata() packer.ArtifactMetadata {
+	return packer.ArtifactMetadata{packer.MetadataImageId: {a.Id()}}
+}
+
 func (a *ImportArtifact) Destroy() error {
 	return a.Driver.DeleteImage(a.Id())
 }
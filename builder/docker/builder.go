@@ -41,9 +41,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 	log.Printf("[DEBUG] Docker version: %s", version.String())
 
+	// With checkpoints, the provisioners run in groups and the container
+	// is committed between them
+	var provision multistep.Step = &common.StepProvision{}
+	if len(b.config.Checkpoints) > 0 {
+		provision = &StepProvisionCheckpoints{}
+	}
+
 	steps := []multistep.Step{
 		&StepTempDir{},
 		&StepPull{},
+		&StepCheckpointCache{},
 		&StepRun{},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
@@ -53,7 +61,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 				"docker": &StepConnectDocker{},
 			},
 		},
-		&common.StepProvision{},
+		provision,
 	}
 
 	if b.config.Discard {
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 
@@ -47,9 +49,35 @@ type Config struct {
 	EcrLogin        bool   `mapstructure:"ecr_login"`
 	AwsAccessConfig `mapstructure:",squash"`
 
+	// CheckpointRepository is the repository of the images saved at the
+	// checkpoints of the provisioners. Checkpoints are set by Packer.
+	CheckpointRepository string       `mapstructure:"checkpoint_repository"`
+	Checkpoints          []Checkpoint `mapstructure:"packer_checkpoints"`
+
 	ctx interpolate.Context
 }
 
+// Checkpoint is a checkpoint of the provisioners, after which the
+// container is committed so that later builds can start from there.
+type Checkpoint struct {
+	// Name is the name of the checkpoint in the template.
+	Name string
+
+	// Provisioners is the number of provisioners run before it.
+	Provisioners int
+
+	// Hash is the hash of the configuration of those provisioners.
+	Hash string
+}
+
+// checkpointTag returns the tag of the image of a checkpoint when the
+// build starts from the base image with the given ID. The tag changes
+// with the base image and with the provisioners before the checkpoint.
+func (c *Config) checkpointTag(base string, cp Checkpoint) string {
+	sum := sha256.Sum256([]byte(base + "\n" + cp.Hash))
+	return fmt.Sprintf("%s:%s-%s", c.CheckpointRepository, cp.Name, hex.EncodeToString(sum[:])[:16])
+}
+
 func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	c := new(Config)
 
@@ -113,6 +141,10 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		}
 	}
 
+	if c.CheckpointRepository == "" {
+		c.CheckpointRepository = "packer-checkpoints"
+	}
+
 	if c.ContainerDir == "" {
 		c.ContainerDir = "/packer-files"
 	}
@@ -3,6 +3,7 @@ package docker
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -141,3 +142,21 @@ func TestConfigPrepare_pull(t *testing.T) {
 		t.Fatal("should not pull")
 	}
 }
+
+func TestConfigPrepare_checkpoints(t *testing.T) {
+	raw := testConfig()
+	raw["packer_checkpoints"] = []interface{}{
+		map[string]interface{}{"name": "deps", "provisioners": 2, "hash": "abc"},
+	}
+
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+
+	expected := []Checkpoint{{Name: "deps", Provisioners: 2, Hash: "abc"}}
+	if !reflect.DeepEqual(c.Checkpoints, expected) {
+		t.Fatalf("bad: %#v", c.Checkpoints)
+	}
+	if c.CheckpointRepository != "packer-checkpoints" {
+		t.Fatalf("bad: %s", c.CheckpointRepository)
+	}
+}
@@ -19,6 +19,10 @@ type Driver interface {
 	// Export exports the container with the given ID to the given writer.
 	Export(id string, dst io.Writer) error
 
+	// ImageId returns the ID of the image with the given name, or an
+	// error if there is no such image.
+	ImageId(name string) (string, error)
+
 	// Import imports a container from a tar file
 	Import(path, repo string) (string, error)
 
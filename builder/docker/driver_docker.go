@@ -97,6 +97,24 @@ func (d *DockerDriver) Export(id string, dst io.Writer) error {
 	return nil
 }
 
+func (d *DockerDriver) ImageId(name string) (string, error) {
+	var stderr, stdout bytes.Buffer
+	cmd := exec.Command(
+		"docker",
+		"inspect",
+		"--type", "image",
+		"--format",
+		"{{ .Id }}",
+		name)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Error inspecting image: %s\n\nStderr: %s", err, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 func (d *DockerDriver) Import(path string, repo string) (string, error) {
 	var stdout, stderr bytes.Buffer
 	cmd := exec.Command("docker", "import", "-", repo)
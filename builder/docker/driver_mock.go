@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/hashicorp/go-version"
@@ -17,6 +18,10 @@ type MockDriver struct {
 	DeleteImageId     string
 	DeleteImageErr    error
 
+	ImageIdCalled bool
+	ImageIdNames  []string
+	ImageIdImages map[string]string
+
 	ImportCalled bool
 	ImportPath   string
 	ImportRepo   string
@@ -102,6 +107,15 @@ func (d *MockDriver) Export(id string, dst io.Writer) error {
 	return d.ExportError
 }
 
+func (d *MockDriver) ImageId(name string) (string, error) {
+	d.ImageIdCalled = true
+	d.ImageIdNames = append(d.ImageIdNames, name)
+	if id, ok := d.ImageIdImages[name]; ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("No such image: %s", name)
+}
+
 func (d *MockDriver) Import(path, repo string) (string, error) {
 	d.ImportCalled = true
 	d.ImportPath = path
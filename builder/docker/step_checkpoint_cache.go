@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// StepCheckpointCache looks for the images of the checkpoints of the
+// provisioners that earlier builds saved, so that the container starts
+// from the last one that is still up to date. It saves the ID of the base
+// image as checkpoint_base and, if a checkpoint is cached, its image as
+// checkpoint_image and the number of cached checkpoints as
+// checkpoint_start.
+type StepCheckpointCache struct{}
+
+func (s *StepCheckpointCache) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	if len(config.Checkpoints) == 0 {
+		return multistep.ActionContinue
+	}
+
+	base, err := driver.ImageId(config.Image)
+	if err != nil {
+		err := fmt.Errorf("Error inspecting the image %s: %s", config.Image, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("checkpoint_base", base)
+
+	if config.PackerForce {
+		ui.Say("Force build, not using the cached checkpoints...")
+		return multistep.ActionContinue
+	}
+
+	for i := len(config.Checkpoints) - 1; i >= 0; i-- {
+		cp := config.Checkpoints[i]
+		tag := config.checkpointTag(base, cp)
+		if _, err := driver.ImageId(tag); err != nil {
+			log.Printf("Checkpoint %s isn't cached: %s", cp.Name, err)
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Using the cached checkpoint %s: %s", cp.Name, tag))
+		state.Put("checkpoint_image", tag)
+		state.Put("checkpoint_start", i+1)
+		return multistep.ActionContinue
+	}
+
+	ui.Say("No cached checkpoints, running all the provisioners...")
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckpointCache) Cleanup(state multistep.StateBag) {}
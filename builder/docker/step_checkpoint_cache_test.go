@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/mitchellh/multistep"
+)
+
+func testStepCheckpointState(t *testing.T) multistep.StateBag {
+	state := testState(t)
+	config := state.Get("config").(*Config)
+	config.Checkpoints = []Checkpoint{
+		{Name: "deps", Provisioners: 1, Hash: "a"},
+		{Name: "app", Provisioners: 3, Hash: "b"},
+	}
+	driver := state.Get("driver").(*MockDriver)
+	driver.ImageIdImages = map[string]string{config.Image: "sha256:base"}
+	return state
+}
+
+func TestStepCheckpointCache_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCheckpointCache)
+}
+
+func TestStepCheckpointCache(t *testing.T) {
+	state := testStepCheckpointState(t)
+	step := new(StepCheckpointCache)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*MockDriver)
+	deps := config.checkpointTag("sha256:base", config.Checkpoints[0])
+	driver.ImageIdImages[deps] = "sha256:deps"
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if state.Get("checkpoint_base").(string) != "sha256:base" {
+		t.Fatalf("bad: %#v", state.Get("checkpoint_base"))
+	}
+	if state.Get("checkpoint_image").(string) != deps {
+		t.Fatalf("bad: %#v", state.Get("checkpoint_image"))
+	}
+	if state.Get("checkpoint_start").(int) != 1 {
+		t.Fatalf("bad: %#v", state.Get("checkpoint_start"))
+	}
+}
+
+func TestStepCheckpointCache_changedBase(t *testing.T) {
+	state := testStepCheckpointState(t)
+	step := new(StepCheckpointCache)
+	defer step.Cleanup(state)
+
+	// The checkpoints of another base image don't count
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*MockDriver)
+	driver.ImageIdImages[config.checkpointTag("sha256:old", config.Checkpoints[1])] = "sha256:app"
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("checkpoint_image"); ok {
+		t.Fatal("shouldn't use a checkpoint")
+	}
+}
+
+func TestStepCheckpointCache_force(t *testing.T) {
+	state := testStepCheckpointState(t)
+	step := new(StepCheckpointCache)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.PackerForce = true
+	driver := state.Get("driver").(*MockDriver)
+	driver.ImageIdImages[config.checkpointTag("sha256:base", config.Checkpoints[1])] = "sha256:app"
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("checkpoint_image"); ok {
+		t.Fatal("shouldn't use a checkpoint")
+	}
+	if _, ok := state.GetOk("checkpoint_base"); !ok {
+		t.Fatal("should save the base image")
+	}
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// StepProvisionCheckpoints runs the provisioners that come after the
+// cached checkpoints, and commits the container at each checkpoint so
+// that later builds can start from there.
+type StepProvisionCheckpoints struct{}
+
+func (s *StepProvisionCheckpoints) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	containerId := state.Get("container_id").(string)
+	base := state.Get("checkpoint_base").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	start := 0
+	if raw, ok := state.GetOk("checkpoint_start"); ok {
+		start = raw.(int)
+	}
+
+	from := 0
+	if start > 0 {
+		from = config.Checkpoints[start-1].Provisioners
+	}
+
+	for _, cp := range config.Checkpoints[start:] {
+		step := &common.StepProvision{Data: packer.ProvisionRangeData(from, cp.Provisioners)}
+		if action := step.Run(state); action != multistep.ActionContinue {
+			return action
+		}
+
+		tag := config.checkpointTag(base, cp)
+		ui.Say(fmt.Sprintf("Saving the checkpoint %s: %s", cp.Name, tag))
+		imageId, err := driver.Commit(containerId, config.Author, nil,
+			fmt.Sprintf("Packer checkpoint %s", cp.Name))
+		if err == nil {
+			err = driver.TagImage(imageId, tag, true)
+		}
+		if err != nil {
+			err := fmt.Errorf("Error saving the checkpoint %s: %s", cp.Name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		from = cp.Provisioners
+	}
+
+	step := &common.StepProvision{Data: packer.ProvisionRangeData(from, -1)}
+	return step.Run(state)
+}
+
+func (s *StepProvisionCheckpoints) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepProvisionCheckpoints_impl(t *testing.T) {
+	var _ multistep.Step = new(StepProvisionCheckpoints)
+}
+
+func TestStepProvisionCheckpoints(t *testing.T) {
+	state := testStepCheckpointState(t)
+	state.Put("container_id", "foo")
+	state.Put("checkpoint_base", "sha256:base")
+	state.Put("checkpoint_start", 1)
+	step := new(StepProvisionCheckpoints)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(*MockDriver)
+	driver.CommitImageId = "sha256:app"
+	hook := state.Get("hook").(*packer.MockHook)
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Only the app checkpoint is saved, deps was cached
+	if driver.CommitContainerId != "foo" {
+		t.Fatalf("bad: %#v", driver.CommitContainerId)
+	}
+	if driver.TagImageImageId != "sha256:app" {
+		t.Fatalf("bad: %#v", driver.TagImageImageId)
+	}
+	if tag := config.checkpointTag("sha256:base", config.Checkpoints[1]); driver.TagImageRepo != tag {
+		t.Fatalf("bad: %#v", driver.TagImageRepo)
+	}
+
+	// The last group runs the provisioners after the last checkpoint
+	if !reflect.DeepEqual(hook.RunData, packer.ProvisionRangeData(3, -1)) {
+		t.Fatalf("bad: %#v", hook.RunData)
+	}
+}
@@ -16,8 +16,14 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 	tempDir := state.Get("temp_dir").(string)
 	ui := state.Get("ui").(packer.Ui)
 
+	// The container starts from the image of the last cached checkpoint
+	image := config.Image
+	if raw, ok := state.GetOk("checkpoint_image"); ok {
+		image = raw.(string)
+	}
+
 	runConfig := ContainerConfig{
-		Image:      config.Image,
+		Image:      image,
 		RunCommand: config.RunCommand,
 		Volumes:    make(map[string]string),
 		Privileged: config.Privileged,
@@ -3,6 +3,8 @@ package googlecompute
 import (
 	"fmt"
 	"log"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 // Artifact represents a GCE image as the result of a Packer build.
@@ -54,3 +56,11 @@ func (a *Artifact) State(name string) interface{} {
 	}
 	return nil
 }
+
+// Metadata returns the name of the image and the zone it was built in.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	return packer.ArtifactMetadata{
+		packer.MetadataImageId: {a.image.Name},
+		packer.MetadataRegion:  {a.config.Zone},
+	}
+}
@@ -0,0 +1,59 @@
+package chroot
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/packer"
+)
+
+// Artifact is the GCE image created by the builder.
+type Artifact struct {
+	image  *googlecompute.Image
+	driver googlecompute.Driver
+	zone   string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Destroy() error {
+	log.Printf("Destroying image: %s", a.image.Name)
+	return <-a.driver.DeleteImage(a.image.Name)
+}
+
+func (*Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return a.image.Name
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("A disk image was created: %v", a.image.Name)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	switch name {
+	case "ImageName":
+		return a.image.Name
+	case "ImageSizeGb":
+		return a.image.SizeGb
+	case "ProjectId":
+		return a.image.ProjectId
+	case "BuildZone":
+		return a.zone
+	}
+	return nil
+}
+
+// Metadata returns the name of the image and the zone it was built in.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	return packer.ArtifactMetadata{
+		packer.MetadataImageId: {a.image.Name},
+		packer.MetadataRegion:  {a.zone},
+	}
+}
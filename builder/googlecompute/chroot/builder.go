@@ -0,0 +1,205 @@
+// The chroot package is able to create a Google Compute Engine image
+// without launching a new instance for every build. It does this by
+// creating a disk from the source image, attaching it to the instance
+// Packer runs on and chrooting into it. It then creates an image from
+// that disk.
+package chroot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/multistep"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.googlecompute-chroot"
+
+// Config is the configuration that is chained through the steps and
+// settable from the template.
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+	chroot.Config       `mapstructure:",squash"`
+
+	AccountFile string `mapstructure:"account_file"`
+	ProjectId   string `mapstructure:"project_id"`
+
+	DiskSizeGb           int64  `mapstructure:"disk_size"`
+	DiskType             string `mapstructure:"disk_type"`
+	ImageDescription     string `mapstructure:"image_description"`
+	ImageFamily          string `mapstructure:"image_family"`
+	ImageName            string `mapstructure:"image_name"`
+	RawStateTimeout      string `mapstructure:"state_timeout"`
+	SourceImage          string `mapstructure:"source_image"`
+	SourceImageFamily    string `mapstructure:"source_image_family"`
+	SourceImageProjectId string `mapstructure:"source_image_project_id"`
+
+	account      googlecompute.AccountFile
+	stateTimeout time.Duration
+	ctx          interpolate.Context
+}
+
+type Builder struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	err := config.Decode(&b.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &b.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: chroot.FilterExclude,
+		},
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accumulate any errors
+	var errs *packer.MultiError
+	errs = packer.MultiErrorAppend(errs, b.config.Config.Prepare(
+		&b.config.ctx, "/mnt/packer-googlecompute-chroot-disks/{{.Device}}")...)
+
+	if b.config.DiskType == "" {
+		b.config.DiskType = "pd-standard"
+	}
+
+	if b.config.ImageDescription == "" {
+		b.config.ImageDescription = "Created by Packer"
+	}
+
+	if b.config.ImageName == "" {
+		img, err := interpolate.Render("packer-{{timestamp}}", nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Unable to parse image name: %s ", err))
+		} else {
+			b.config.ImageName = img
+		}
+	}
+
+	if b.config.RawStateTimeout == "" {
+		b.config.RawStateTimeout = "5m"
+	}
+	b.config.stateTimeout, err = time.ParseDuration(b.config.RawStateTimeout)
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("Failed parsing state_timeout: %s", err))
+	}
+
+	if b.config.ProjectId == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("a project_id must be specified"))
+	}
+
+	if b.config.SourceImage == "" && b.config.SourceImageFamily == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("a source_image or source_image_family must be specified"))
+	}
+
+	if b.config.DiskSizeGb < 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("disk_size can't be negative"))
+	}
+
+	if b.config.AccountFile != "" {
+		if err := googlecompute.ProcessAccountFile(&b.config.account, b.config.AccountFile); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, errs
+	}
+
+	log.Println(common.ScrubConfig(b.config, b.config.account.PrivateKey))
+	return nil, nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("The googlecompute-chroot builder only works on Linux environments.")
+	}
+
+	driver, err := googlecompute.NewDriverGCE(ui, b.config.ProjectId, &b.config.account)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("config", &b.config)
+	state.Put("driver", driver)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+	state.Put("wrappedCommand", b.config.WrappedCommand(b.config.ctx))
+
+	// Build the steps
+	steps := []multistep.Step{
+		&stepInstanceInfo{},
+		&stepSourceImage{},
+		&stepCreateDisk{},
+		&stepAttachDisk{},
+		&chroot.StepPreMountCommands{
+			Commands: b.config.PreMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountDevice{
+			MountPath:      b.config.MountPath,
+			MountOptions:   b.config.MountOptions,
+			MountPartition: fmt.Sprintf("-part%d", b.config.MountPartition),
+			Ctx:            b.config.ctx,
+		},
+		&chroot.StepPostMountCommands{
+			Commands: b.config.PostMountCommands,
+			Ctx:      b.config.ctx,
+		},
+		&chroot.StepMountExtra{
+			ChrootMounts: b.config.ChrootMounts,
+		},
+		&chroot.StepCopyFiles{
+			Files: b.config.CopyFiles,
+		},
+		&chroot.StepChrootProvision{},
+		&chroot.StepEarlyCleanup{},
+		&stepCreateImage{},
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If there is no image, then just return
+	if _, ok := state.GetOk("image"); !ok {
+		return nil, nil
+	}
+
+	artifact := &Artifact{
+		image:  state.Get("image").(*googlecompute.Image),
+		driver: driver,
+		zone:   state.Get("zone").(string),
+	}
+	return artifact, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
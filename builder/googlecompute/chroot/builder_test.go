@@ -0,0 +1,73 @@
+package chroot
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"project_id":   "project",
+		"source_image": "image",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	if _, err := b.Prepare(testConfig()); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.DiskType != "pd-standard" {
+		t.Errorf("bad disk_type: %s", b.config.DiskType)
+	}
+	if b.config.MountPath != "/mnt/packer-googlecompute-chroot-disks/{{.Device}}" {
+		t.Errorf("bad mount_path: %s", b.config.MountPath)
+	}
+	if b.config.MountPartition != 1 {
+		t.Errorf("bad mount_partition: %d", b.config.MountPartition)
+	}
+	if b.config.ImageName == "" {
+		t.Error("image_name should have a default")
+	}
+}
+
+func TestBuilderPrepare(t *testing.T) {
+	cases := []struct {
+		Name  string
+		Key   string
+		Value interface{}
+		Err   bool
+	}{
+		{"no project", "project_id", nil, true},
+		{"no source image", "source_image", nil, true},
+		{"source image family", "source_image_family", "family", false},
+		{"bad state timeout", "state_timeout", "never", true},
+		{"negative disk size", "disk_size", -1, true},
+		{"bad chroot mounts", "chroot_mounts", [][]string{{"proc", "/proc"}}, true},
+	}
+
+	for _, tc := range cases {
+		config := testConfig()
+		if tc.Value == nil {
+			delete(config, tc.Key)
+		} else {
+			config[tc.Key] = tc.Value
+		}
+
+		var b Builder
+		_, err := b.Prepare(config)
+		if (err != nil) != tc.Err {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+		}
+	}
+}
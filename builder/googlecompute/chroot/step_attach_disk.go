@@ -0,0 +1,80 @@
+package chroot
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepAttachDisk attaches the disk to the instance Packer runs on, using
+// the name of the disk as device name.
+//
+// Produces:
+//
+//	device string - The path of the attached disk.
+//	attach_cleanup CleanupFunc - To perform early cleanup
+type stepAttachDisk struct {
+	deviceName string
+}
+
+func (s *stepAttachDisk) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	diskName := state.Get("disk_name").(string)
+	driver := state.Get("driver").(googlecompute.Driver)
+	instance := state.Get("instance_name").(string)
+	ui := state.Get("ui").(packer.Ui)
+	zone := state.Get("zone").(string)
+
+	ui.Say(fmt.Sprintf("Attaching disk %s to %s...", diskName, instance))
+	errCh, err := driver.AttachDisk(zone, instance, diskName, diskName)
+	if err == nil {
+		err = waitForOperation(errCh, config.stateTimeout)
+	}
+	if err != nil {
+		return halt(state, fmt.Errorf("Error attaching disk: %s", err))
+	}
+	s.deviceName = diskName
+
+	device := "/dev/disk/by-id/google-" + diskName
+	if err := chroot.WaitForDevice(device, config.stateTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error waiting for the attached disk: %s", err))
+	}
+
+	state.Put("device", device)
+	state.Put("attach_cleanup", s)
+	return multistep.ActionContinue
+}
+
+func (s *stepAttachDisk) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packer.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *stepAttachDisk) CleanupFunc(state multistep.StateBag) error {
+	if s.deviceName == "" {
+		return nil
+	}
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(googlecompute.Driver)
+	instance := state.Get("instance_name").(string)
+	ui := state.Get("ui").(packer.Ui)
+	zone := state.Get("zone").(string)
+
+	ui.Say("Detaching disk...")
+	errCh, err := driver.DetachDisk(zone, instance, s.deviceName)
+	if err == nil {
+		err = waitForOperation(errCh, config.stateTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Error detaching disk: %s", err)
+	}
+
+	s.deviceName = ""
+	return nil
+}
@@ -0,0 +1,81 @@
+package chroot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepCreateDisk creates the disk that is provisioned from the source
+// image. The disk is deleted when the build ends.
+//
+// Produces:
+//
+//	disk_name string - The name of the disk.
+type stepCreateDisk struct {
+	diskName string
+}
+
+func (s *stepCreateDisk) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(googlecompute.Driver)
+	image := state.Get("source_image").(*googlecompute.Image)
+	ui := state.Get("ui").(packer.Ui)
+	zone := state.Get("zone").(string)
+
+	name := fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
+	ui.Say(fmt.Sprintf("Creating disk %s...", name))
+	errCh, err := driver.CreateDisk(&googlecompute.DiskConfig{
+		Image:  image,
+		Name:   name,
+		SizeGb: config.DiskSizeGb,
+		Type:   config.DiskType,
+		Zone:   zone,
+	})
+	if err == nil {
+		err = waitForOperation(errCh, config.stateTimeout)
+	}
+	if err != nil {
+		return halt(state, fmt.Errorf("Error creating disk: %s", err))
+	}
+
+	s.diskName = name
+	state.Put("disk_name", name)
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateDisk) Cleanup(state multistep.StateBag) {
+	if s.diskName == "" {
+		return
+	}
+
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(googlecompute.Driver)
+	ui := state.Get("ui").(packer.Ui)
+	zone := state.Get("zone").(string)
+
+	ui.Say("Deleting disk...")
+	errCh, err := driver.DeleteDisk(zone, s.diskName)
+	if err == nil {
+		err = waitForOperation(errCh, config.stateTimeout)
+	}
+	if err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error deleting disk %s. Please delete it manually: %s", s.diskName, err))
+	}
+}
+
+// waitForOperation waits for an operation of the driver to finish.
+func waitForOperation(errCh <-chan error, timeout time.Duration) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		return errors.New("time out while waiting for the operation to finish")
+	}
+}
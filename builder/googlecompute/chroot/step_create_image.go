@@ -0,0 +1,49 @@
+package chroot
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepCreateImage creates the image from the detached disk, replacing an
+// existing image of the same name if the build is forced.
+//
+// Produces:
+//
+//	image *googlecompute.Image - The created image.
+type stepCreateImage struct{}
+
+func (s *stepCreateImage) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	diskName := state.Get("disk_name").(string)
+	driver := state.Get("driver").(googlecompute.Driver)
+	ui := state.Get("ui").(packer.Ui)
+	zone := state.Get("zone").(string)
+
+	if driver.ImageExists(config.ImageName) {
+		if !config.PackerForce {
+			return halt(state, fmt.Errorf("Image %s already exists.\n"+
+				"Use the force flag to delete it prior to building.", config.ImageName))
+		}
+
+		ui.Say("Deleting previous image...")
+		if err := <-driver.DeleteImage(config.ImageName); err != nil {
+			return halt(state, fmt.Errorf("Error deleting image: %s", err))
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Creating image %s...", config.ImageName))
+	imageCh, errCh := driver.CreateImage(
+		config.ImageName, config.ImageDescription, config.ImageFamily, zone, diskName)
+	if err := waitForOperation(errCh, config.stateTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error waiting for image: %s", err))
+	}
+
+	state.Put("image", <-imageCh)
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateImage) Cleanup(multistep.StateBag) {}
@@ -0,0 +1,60 @@
+package chroot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepCreateImage(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Exists  bool
+		Force   bool
+		Action  multistep.StepAction
+		Deleted bool
+	}{
+		{"new image", false, false, multistep.ActionContinue, false},
+		{"existing image", true, false, multistep.ActionHalt, false},
+		{"forced", true, true, multistep.ActionContinue, true},
+	}
+
+	for _, tc := range cases {
+		config := &Config{ImageName: "image", stateTimeout: time.Minute}
+		config.PackerForce = tc.Force
+		image := &googlecompute.Image{Name: "image"}
+		imageCh := make(chan *googlecompute.Image, 1)
+		imageCh <- image
+		driver := &googlecompute.DriverMock{
+			ImageExistsResult:   tc.Exists,
+			CreateImageResultCh: imageCh,
+		}
+
+		state := testState()
+		state.Put("config", config)
+		state.Put("disk_name", "disk")
+		state.Put("driver", driver)
+		state.Put("zone", "zone")
+
+		step := new(stepCreateImage)
+		if action := step.Run(state); action != tc.Action {
+			t.Errorf("%s: bad action: %#v", tc.Name, action)
+			continue
+		}
+		if deleted := driver.DeleteImageName == "image"; deleted != tc.Deleted {
+			t.Errorf("%s: image deleted: %t", tc.Name, deleted)
+		}
+		if tc.Action != multistep.ActionContinue {
+			continue
+		}
+
+		if driver.CreateImageDisk != "disk" || driver.CreateImageZone != "zone" {
+			t.Errorf("%s: bad image source: %s in %s", tc.Name, driver.CreateImageDisk, driver.CreateImageZone)
+		}
+		if state.Get("image") != image {
+			t.Errorf("%s: bad image: %#v", tc.Name, state.Get("image"))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package chroot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// metadataURL is the base URL of the metadata server of the instance.
+var metadataURL = "http://metadata.google.internal/computeMetadata/v1"
+
+// stepInstanceInfo gets the name and the zone of the instance Packer runs
+// on from the metadata server.
+//
+// Produces:
+//
+//	instance_name string - The name of the instance.
+//	zone string - The zone of the instance.
+type stepInstanceInfo struct{}
+
+func (s *stepInstanceInfo) Run(state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Gathering information about this instance...")
+	name, err := instanceMetadata("instance/name")
+	if err != nil {
+		return halt(state, fmt.Errorf(
+			"Error getting the name of the instance. Is Packer running on a GCE instance? %s", err))
+	}
+
+	// The zone is returned as projects/<number>/zones/<zone>
+	zone, err := instanceMetadata("instance/zone")
+	if err != nil {
+		return halt(state, fmt.Errorf("Error getting the zone of the instance: %s", err))
+	}
+	zone = path.Base(zone)
+
+	ui.Message(fmt.Sprintf("Instance %s in zone %s", name, zone))
+	state.Put("instance_name", name)
+	state.Put("zone", zone)
+	return multistep.ActionContinue
+}
+
+func (s *stepInstanceInfo) Cleanup(multistep.StateBag) {}
+
+func instanceMetadata(key string) (string, error) {
+	req, err := http.NewRequest("GET", metadataURL+"/"+key, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s from the metadata server", resp.Status)
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func halt(state multistep.StateBag, err error) multistep.StepAction {
+	state.Put("error", err)
+	state.Get("ui").(packer.Ui).Error(err.Error())
+	return multistep.ActionHalt
+}
@@ -0,0 +1,73 @@
+package chroot
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+func testState() multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepInstanceInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/instance/name":
+			w.Write([]byte("builder"))
+		case "/instance/zone":
+			w.Write([]byte("projects/1234/zones/us-central1-a"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	old := metadataURL
+	metadataURL = ts.URL
+	defer func() { metadataURL = old }()
+
+	state := testState()
+	step := new(stepInstanceInfo)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %v", action, state.Get("error"))
+	}
+
+	if name := state.Get("instance_name"); name != "builder" {
+		t.Errorf("bad instance_name: %#v", name)
+	}
+	if zone := state.Get("zone"); zone != "us-central1-a" {
+		t.Errorf("bad zone: %#v", zone)
+	}
+}
+
+func TestStepInstanceInfo_notGCE(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	old := metadataURL
+	metadataURL = ts.URL
+	defer func() { metadataURL = old }()
+
+	state := testState()
+	step := new(stepInstanceInfo)
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,44 @@
+package chroot
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepSourceImage looks up the image the disk is created from.
+//
+// Produces:
+//
+//	source_image *googlecompute.Image - The source image.
+type stepSourceImage struct{}
+
+func (s *stepSourceImage) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(googlecompute.Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	name, fromFamily := config.SourceImage, false
+	if name == "" {
+		name, fromFamily = config.SourceImageFamily, true
+	}
+
+	var image *googlecompute.Image
+	var err error
+	if config.SourceImageProjectId != "" {
+		image, err = driver.GetImageFromProject(config.SourceImageProjectId, name, fromFamily)
+	} else {
+		image, err = driver.GetImage(name, fromFamily)
+	}
+	if err != nil {
+		return halt(state, fmt.Errorf("Error getting source image for disk creation: %s", err))
+	}
+
+	ui.Say(fmt.Sprintf("Using image %s", image.Name))
+	state.Put("source_image", image)
+	return multistep.ActionContinue
+}
+
+func (s *stepSourceImage) Cleanup(multistep.StateBag) {}
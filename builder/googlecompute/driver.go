@@ -9,6 +9,13 @@ import (
 // with GCE. The Driver interface exists mostly to allow a mock implementation
 // to be used to test the steps.
 type Driver interface {
+	// AttachDisk attaches the disk with the given name to an instance as
+	// deviceName.
+	AttachDisk(zone, instance, disk, deviceName string) (<-chan error, error)
+
+	// CreateDisk creates a disk from the given image.
+	CreateDisk(config *DiskConfig) (<-chan error, error)
+
 	// CreateImage creates an image from the given disk in Google Compute
 	// Engine.
 	CreateImage(name, description, family, zone, disk string) (<-chan *Image, <-chan error)
@@ -19,6 +26,9 @@ type Driver interface {
 	// DeleteInstance deletes the given instance, keeping the boot disk.
 	DeleteInstance(zone, name string) (<-chan error, error)
 
+	// DetachDisk detaches the disk attached to an instance as deviceName.
+	DetachDisk(zone, instance, deviceName string) (<-chan error, error)
+
 	// DeleteDisk deletes the disk with the given name.
 	DeleteDisk(zone, name string) (<-chan error, error)
 
@@ -47,6 +57,9 @@ type Driver interface {
 	// occurs calling the API, this method returns false.
 	ImageExists(name string) bool
 
+	// ListImages lists the images of the project.
+	ListImages() ([]*Image, error)
+
 	// RunInstance takes the given config and launches an instance.
 	RunInstance(*InstanceConfig) (<-chan error, error)
 
@@ -81,6 +94,15 @@ type InstanceConfig struct {
 	Zone              string
 }
 
+// DiskConfig is the configuration of a disk created with CreateDisk.
+type DiskConfig struct {
+	Image  *Image
+	Name   string
+	SizeGb int64
+	Type   string
+	Zone   string
+}
+
 // WindowsPasswordConfig is the data structue that GCE needs to encrypt the created
 // windows password.
 type WindowsPasswordConfig struct {
@@ -1,6 +1,7 @@
 package googlecompute
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -98,6 +99,40 @@ func NewDriverGCE(ui packer.Ui, p string, a *AccountFile) (Driver, error) {
 	}, nil
 }
 
+func (d *driverGCE) AttachDisk(zone, instance, disk, deviceName string) (<-chan error, error) {
+	attached := &compute.AttachedDisk{
+		DeviceName: deviceName,
+		Mode:       "READ_WRITE",
+		Source:     fmt.Sprintf("projects/%s/zones/%s/disks/%s", d.projectId, zone, disk),
+		Type:       "PERSISTENT",
+	}
+	op, err := d.service.Instances.AttachDisk(d.projectId, zone, instance, attached).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	errCh := make(chan error, 1)
+	go waitForState(errCh, "DONE", d.refreshZoneOp(zone, op))
+	return errCh, nil
+}
+
+func (d *driverGCE) CreateDisk(c *DiskConfig) (<-chan error, error) {
+	disk := &compute.Disk{
+		Name:        c.Name,
+		SizeGb:      c.SizeGb,
+		SourceImage: c.Image.SelfLink,
+		Type:        fmt.Sprintf("zones/%s/diskTypes/%s", c.Zone, c.Type),
+	}
+	op, err := d.service.Disks.Insert(d.projectId, c.Zone, disk).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	errCh := make(chan error, 1)
+	go waitForState(errCh, "DONE", d.refreshZoneOp(c.Zone, op))
+	return errCh, nil
+}
+
 func (d *driverGCE) CreateImage(name, description, family, zone, disk string) (<-chan *Image, <-chan error) {
 	gce_image := &compute.Image{
 		Description: description,
@@ -158,6 +193,17 @@ func (d *driverGCE) DeleteInstance(zone, name string) (<-chan error, error) {
 	return errCh, nil
 }
 
+func (d *driverGCE) DetachDisk(zone, instance, deviceName string) (<-chan error, error) {
+	op, err := d.service.Instances.DetachDisk(d.projectId, zone, instance, deviceName).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	errCh := make(chan error, 1)
+	go waitForState(errCh, "DONE", d.refreshZoneOp(zone, op))
+	return errCh, nil
+}
+
 func (d *driverGCE) DeleteDisk(zone, name string) (<-chan error, error) {
 	op, err := d.service.Disks.Delete(d.projectId, zone, name).Do()
 	if err != nil {
@@ -204,13 +250,20 @@ func (d *driverGCE) GetImageFromProject(project, name string, fromFamily bool) (
 	} else if image == nil || image.SelfLink == "" {
 		return nil, fmt.Errorf("Image, %s, could not be found in project: %s", name, project)
 	} else {
-		return &Image{
-			Licenses:  image.Licenses,
-			Name:      image.Name,
-			ProjectId: project,
-			SelfLink:  image.SelfLink,
-			SizeGb:    image.DiskSizeGb,
-		}, nil
+		return newImage(project, image), nil
+	}
+}
+
+func newImage(project string, image *compute.Image) *Image {
+	// The creation time is in RFC 3339
+	created, _ := time.Parse(time.RFC3339, image.CreationTimestamp)
+	return &Image{
+		Created:   created,
+		Licenses:  image.Licenses,
+		Name:      image.Name,
+		ProjectId: project,
+		SelfLink:  image.SelfLink,
+		SizeGb:    image.DiskSizeGb,
 	}
 }
 
@@ -281,6 +334,21 @@ func (d *driverGCE) ImageExists(name string) bool {
 	return err == nil
 }
 
+func (d *driverGCE) ListImages() ([]*Image, error) {
+	var images []*Image
+	err := d.service.Images.List(d.projectId).Pages(context.TODO(), func(l *compute.ImageList) error {
+		for _, image := range l.Items {
+			images = append(images, newImage(d.projectId, image))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
 func (d *driverGCE) RunInstance(c *InstanceConfig) (<-chan error, error) {
 	// Get the zone
 	d.ui.Message(fmt.Sprintf("Loading zone: %s", c.Zone))
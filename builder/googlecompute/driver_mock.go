@@ -25,6 +25,23 @@ type DriverMock struct {
 	DeleteInstanceErrCh <-chan error
 	DeleteInstanceErr   error
 
+	AttachDiskZone       string
+	AttachDiskInstance   string
+	AttachDiskName       string
+	AttachDiskDeviceName string
+	AttachDiskErrCh      <-chan error
+	AttachDiskErr        error
+
+	CreateDiskConfig DiskConfig
+	CreateDiskErrCh  <-chan error
+	CreateDiskErr    error
+
+	DetachDiskZone       string
+	DetachDiskInstance   string
+	DetachDiskDeviceName string
+	DetachDiskErrCh      <-chan error
+	DetachDiskErr        error
+
 	DeleteDiskZone  string
 	DeleteDiskName  string
 	DeleteDiskErrCh <-chan error
@@ -65,6 +82,10 @@ type DriverMock struct {
 	ImageExistsName   string
 	ImageExistsResult bool
 
+	ListImagesCalled bool
+	ListImagesResult []*Image
+	ListImagesErr    error
+
 	RunInstanceConfig *InstanceConfig
 	RunInstanceErrCh  <-chan error
 	RunInstanceErr    error
@@ -150,6 +171,50 @@ func (d *DriverMock) DeleteInstance(zone, name string) (<-chan error, error) {
 	return resultCh, d.DeleteInstanceErr
 }
 
+func (d *DriverMock) AttachDisk(zone, instance, disk, deviceName string) (<-chan error, error) {
+	d.AttachDiskZone = zone
+	d.AttachDiskInstance = instance
+	d.AttachDiskName = disk
+	d.AttachDiskDeviceName = deviceName
+
+	resultCh := d.AttachDiskErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh, d.AttachDiskErr
+}
+
+func (d *DriverMock) CreateDisk(c *DiskConfig) (<-chan error, error) {
+	d.CreateDiskConfig = *c
+
+	resultCh := d.CreateDiskErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh, d.CreateDiskErr
+}
+
+func (d *DriverMock) DetachDisk(zone, instance, deviceName string) (<-chan error, error) {
+	d.DetachDiskZone = zone
+	d.DetachDiskInstance = instance
+	d.DetachDiskDeviceName = deviceName
+
+	resultCh := d.DetachDiskErrCh
+	if resultCh == nil {
+		ch := make(chan error)
+		close(ch)
+		resultCh = ch
+	}
+
+	return resultCh, d.DetachDiskErr
+}
+
 func (d *DriverMock) DeleteDisk(zone, name string) (<-chan error, error) {
 	d.DeleteDiskZone = zone
 	d.DeleteDiskName = name
@@ -207,6 +272,11 @@ func (d *DriverMock) ImageExists(name string) bool {
 	return d.ImageExistsResult
 }
 
+func (d *DriverMock) ListImages() ([]*Image, error) {
+	d.ListImagesCalled = true
+	return d.ListImagesResult, d.ListImagesErr
+}
+
 func (d *DriverMock) RunInstance(c *InstanceConfig) (<-chan error, error) {
 	d.RunInstanceConfig = c
 
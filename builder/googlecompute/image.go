@@ -2,9 +2,11 @@ package googlecompute
 
 import (
 	"strings"
+	"time"
 )
 
 type Image struct {
+	Created   time.Time
 	Licenses  []string
 	Name      string
 	ProjectId string
@@ -43,6 +43,9 @@ type Driver interface {
 	// Type scan codes to virtual keyboard of vm
 	TypeScanCodes(string, string) error
 
+	// Save a PNG screenshot of the console of vm to path
+	Screenshot(string, string) error
+
 	//Get the ip address for network adaptor
 	GetVirtualMachineNetworkAdapterAddress(string) (string, error)
 
@@ -66,6 +69,9 @@ type Driver interface {
 
 	CreateVirtualMachine(string, string, int64, int64, string, uint) error
 
+	// Create a VM on a differencing disk of the given parent disk
+	CreateDifferencingVirtualMachine(string, string, string, int64, string, uint) error
+
 	DeleteVirtualMachine(string) error
 
 	SetVirtualMachineCpuCount(string, uint) error
@@ -74,12 +80,18 @@ type Driver interface {
 
 	SetVirtualMachineDynamicMemory(string, bool) error
 
+	// Set the startup memory, in bytes, of the VM
+	SetVirtualMachineMemory(string, int64) error
+
 	SetVirtualMachineSecureBoot(string, bool) error
 
 	SetVirtualMachineVirtualizationExtensions(string, bool) error
 
 	EnableVirtualMachineIntegrationService(string, string) error
 
+	// Replace the differencing disks of the VM with standalone disks
+	MergeDifferencingDisk(string) error
+
 	ExportVirtualMachine(string, string) error
 
 	CompactDisks(string, string) error
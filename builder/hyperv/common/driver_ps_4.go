@@ -127,6 +127,11 @@ func (d *HypervPS4Driver) TypeScanCodes(vmName string, scanCodes string) error {
 	return hyperv.TypeScanCodes(vmName, scanCodes)
 }
 
+// Save a PNG screenshot of the console of vm to path
+func (d *HypervPS4Driver) Screenshot(vmName string, path string) error {
+	return hyperv.Screenshot(vmName, path)
+}
+
 // Get network adapter address
 func (d *HypervPS4Driver) GetVirtualMachineNetworkAdapterAddress(vmName string) (string, error) {
 	return hyperv.GetVirtualMachineNetworkAdapterAddress(vmName)
@@ -170,6 +175,10 @@ func (d *HypervPS4Driver) CreateVirtualMachine(vmName string, path string, ram i
 	return hyperv.CreateVirtualMachine(vmName, path, ram, diskSize, switchName, generation)
 }
 
+func (d *HypervPS4Driver) CreateDifferencingVirtualMachine(vmName string, path string, parentPath string, ram int64, switchName string, generation uint) error {
+	return hyperv.CreateDifferencingVirtualMachine(vmName, path, parentPath, ram, switchName, generation)
+}
+
 func (d *HypervPS4Driver) DeleteVirtualMachine(vmName string) error {
 	return hyperv.DeleteVirtualMachine(vmName)
 }
@@ -186,6 +195,10 @@ func (d *HypervPS4Driver) SetVirtualMachineDynamicMemory(vmName string, enable b
 	return hyperv.SetVirtualMachineDynamicMemory(vmName, enable)
 }
 
+func (d *HypervPS4Driver) SetVirtualMachineMemory(vmName string, ram int64) error {
+	return hyperv.SetVirtualMachineMemory(vmName, ram)
+}
+
 func (d *HypervPS4Driver) SetVirtualMachineSecureBoot(vmName string, enable bool) error {
 	return hyperv.SetVirtualMachineSecureBoot(vmName, enable)
 }
@@ -198,6 +211,10 @@ func (d *HypervPS4Driver) EnableVirtualMachineIntegrationService(vmName string,
 	return hyperv.EnableVirtualMachineIntegrationService(vmName, integrationServiceName)
 }
 
+func (d *HypervPS4Driver) MergeDifferencingDisk(vmName string) error {
+	return hyperv.MergeDifferencingDisk(vmName)
+}
+
 func (d *HypervPS4Driver) ExportVirtualMachine(vmName string, path string) error {
 	return hyperv.ExportVirtualMachine(vmName, path)
 }
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// StepConfigureFinalHardware sets the processors and memory the VM is
+// exported with, once it is off, if they differ from the ones it was
+// built with.
+type StepConfigureFinalHardware struct {
+	Cpu     uint
+	RamSize uint
+}
+
+func (s *StepConfigureFinalHardware) Run(state multistep.StateBag) multistep.StepAction {
+	if s.Cpu == 0 && s.RamSize == 0 {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	vmName := state.Get("vmName").(string)
+
+	ui.Say("Configuring the final hardware of the virtual machine...")
+
+	if s.Cpu > 0 {
+		if err := driver.SetVirtualMachineCpuCount(vmName, s.Cpu); err != nil {
+			err := fmt.Errorf("Error setting the final virtual machine cpu: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if s.RamSize > 0 {
+		if err := driver.SetVirtualMachineMemory(vmName, int64(s.RamSize)*1024*1024); err != nil {
+			err := fmt.Errorf("Error setting the final virtual machine memory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepConfigureFinalHardware) Cleanup(state multistep.StateBag) {
+	// do nothing
+}
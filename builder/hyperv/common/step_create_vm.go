@@ -22,6 +22,10 @@ type StepCreateVM struct {
 	EnableDynamicMemory            bool
 	EnableSecureBoot               bool
 	EnableVirtualizationExtensions bool
+
+	// DifferencingDiskParent is the disk the disk of the VM is a
+	// differencing disk of. A new disk of DiskSize is created if empty.
+	DifferencingDiskParent string
 }
 
 func (s *StepCreateVM) Run(state multistep.StateBag) multistep.StepAction {
@@ -35,7 +39,13 @@ func (s *StepCreateVM) Run(state multistep.StateBag) multistep.StepAction {
 	ramSize := int64(s.RamSize * 1024 * 1024)
 	diskSize := int64(s.DiskSize * 1024 * 1024)
 
-	err := driver.CreateVirtualMachine(s.VMName, path, ramSize, diskSize, s.SwitchName, s.Generation)
+	var err error
+	if s.DifferencingDiskParent != "" {
+		ui.Say(fmt.Sprintf("Using a differencing disk of %s...", s.DifferencingDiskParent))
+		err = driver.CreateDifferencingVirtualMachine(s.VMName, path, s.DifferencingDiskParent, ramSize, s.SwitchName, s.Generation)
+	} else {
+		err = driver.CreateVirtualMachine(s.VMName, path, ramSize, diskSize, s.SwitchName, s.Generation)
+	}
 	if err != nil {
 		err := fmt.Errorf("Error creating virtual machine: %s", err)
 		state.Put("error", err)
@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"github.com/hashicorp/packer/packer"
@@ -14,9 +15,19 @@ const (
 	vmDir  string = "Virtual Machines"
 )
 
+// StepExportVm exports the VM to the output directory, compacting its
+// disks unless SkipCompaction is set. The VM is exported to a directory in
+// the output directory first so that moving the files in place doesn't
+// copy them from another volume.
 type StepExportVm struct {
 	OutputDir      string
 	SkipCompaction bool
+
+	// MergeDifferencingDisk replaces the differencing disks of the VM
+	// with standalone disks before it is exported.
+	MergeDifferencingDisk bool
+
+	exportPath string
 }
 
 func (s *StepExportVm) Run(state multistep.StateBag) multistep.StepAction {
@@ -27,18 +38,30 @@ func (s *StepExportVm) Run(state multistep.StateBag) multistep.StepAction {
 	var errorMsg string
 
 	vmName := state.Get("vmName").(string)
-	tmpPath := state.Get("packerTempDir").(string)
 	outputPath := s.OutputDir
 
+	if s.MergeDifferencingDisk {
+		ui.Say("Merging differencing disk...")
+		err = driver.MergeDifferencingDisk(vmName)
+		if err != nil {
+			errorMsg = "Error merging differencing disk: %s"
+			err := fmt.Errorf(errorMsg, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	// create temp path to export vm
 	errorMsg = "Error creating temp export path: %s"
-	vmExportPath, err := ioutil.TempDir(tmpPath, "export")
+	vmExportPath, err := ioutil.TempDir(outputPath, "export")
 	if err != nil {
 		err := fmt.Errorf(errorMsg, err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	s.exportPath = vmExportPath
 
 	ui.Say("Exporting vm...")
 
@@ -82,5 +105,7 @@ func (s *StepExportVm) Run(state multistep.StateBag) multistep.StepAction {
 }
 
 func (s *StepExportVm) Cleanup(state multistep.StateBag) {
-	// do nothing
+	if s.exportPath != "" {
+		os.RemoveAll(s.exportPath)
+	}
 }
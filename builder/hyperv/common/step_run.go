@@ -2,14 +2,20 @@ package common
 
 import (
 	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer/common/consoles"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
-	"time"
 )
 
 type StepRun struct {
 	BootWait time.Duration
 
+	// BuildName is the name of the build the console of the VM is
+	// recorded for.
+	BuildName string
+
 	vmName string
 }
 
@@ -30,6 +36,13 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 
 	s.vmName = vmName
 
+	// Hyper-V VMs always run without a window
+	consoles.Record(consoles.Console{
+		Build:   s.BuildName,
+		Builder: "hyperv",
+		Command: fmt.Sprintf(`vmconnect.exe localhost "%s"`, vmName),
+	})
+
 	if int64(s.BootWait) > 0 {
 		ui.Say(fmt.Sprintf("Waiting %s for boot...", s.BootWait))
 		wait := time.After(s.BootWait)
@@ -53,6 +66,7 @@ func (s *StepRun) Cleanup(state multistep.StateBag) {
 	if s.vmName == "" {
 		return
 	}
+	consoles.Forget(s.BuildName)
 
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
@@ -2,26 +2,31 @@ package common
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
 )
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort uint
-	Name     string
+	HTTPIP     string
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+	Name       string
 }
 
 // This step "types" the boot command into the VM via the Hyper-V virtual keyboard
 type StepTypeBootCommand struct {
 	BootCommand []string
+	BootConfig  bootcommand.BootConfig
 	SwitchName  string
 	Ctx         interpolate.Context
 }
@@ -44,15 +49,23 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 	ui.Say(fmt.Sprintf("Host IP for the HyperV machine: %s", hostIp))
 
 	common.SetHTTPIP(hostIp)
+	httpScheme, httpToken := common.HTTPServerFromState(state)
 	s.Ctx.Data = &bootCommandTemplateData{
 		hostIp,
 		httpPort,
+		httpScheme,
+		httpToken,
 		vmName,
 	}
 
-	ui.Say("Typing the boot command...")
-	scanCodesToSend := []string{}
+	// The keys are typed by the virtual keyboard at its own rate, so
+	// boot_key_interval isn't used.
+	typeKeys := func(keys string) error {
+		return driver.TypeScanCodes(vmName, strings.Join(scancodes(keys), " "))
+	}
+	screenshot := screenshotter(driver, vmName)
 
+	ui.Say("Typing the boot command...")
 	for _, command := range s.BootCommand {
 		command, err := interpolate.Render(command, &s.Ctx)
 
@@ -63,16 +76,15 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 			return multistep.ActionHalt
 		}
 
-		scanCodesToSend = append(scanCodesToSend, scancodes(command)...)
-	}
-
-	scanCodesToSendString := strings.Join(scanCodesToSend, " ")
-
-	if err := driver.TypeScanCodes(vmName, scanCodesToSendString); err != nil {
-		err := fmt.Errorf("Error sending boot command: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+		if err := s.BootConfig.Run(state, command, typeKeys, screenshot); err != nil {
+			if err == bootcommand.ErrCancelled {
+				return multistep.ActionHalt
+			}
+			err := fmt.Errorf("Error sending boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	return multistep.ActionContinue
@@ -80,6 +92,19 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 
 func (*StepTypeBootCommand) Cleanup(multistep.StateBag) {}
 
+// Screenshot captures the screen of the VM of the build.
+func Screenshot(state multistep.StateBag) (image.Image, error) {
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+	return screenshotter(driver, vmName)()
+}
+
+func screenshotter(driver Driver, vmName string) bootcommand.Screenshotter {
+	return bootcommand.PNGScreenshotter(func(path string) error {
+		return driver.Screenshot(vmName, path)
+	})
+}
+
 func scancodes(message string) []string {
 	// Scancodes reference: http://www.win.tue.nl/~aeb/linux/kbd/scancodes-1.html
 	//
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	hypervcommon "github.com/hashicorp/packer/builder/hyperv/common"
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	powershell "github.com/hashicorp/packer/common/powershell"
 	"github.com/hashicorp/packer/common/powershell/hyperv"
 	"github.com/hashicorp/packer/helper/communicator"
@@ -46,6 +48,7 @@ type Config struct {
 	common.HTTPConfig           `mapstructure:",squash"`
 	common.ISOConfig            `mapstructure:",squash"`
 	common.FloppyConfig         `mapstructure:",squash"`
+	bootcommand.BootConfig      `mapstructure:",squash"`
 	hypervcommon.OutputConfig   `mapstructure:",squash"`
 	hypervcommon.SSHConfig      `mapstructure:",squash"`
 	hypervcommon.RunConfig      `mapstructure:",squash"`
@@ -57,6 +60,10 @@ type Config struct {
 	// The size, in megabytes, of the computer memory in the VM.
 	// By default, this is 1024 (about 1 GB).
 	RamSize uint `mapstructure:"ram_size"`
+	// The processors and the memory, in megabytes, of the exported VM, if
+	// they should differ from the ones the VM is built with.
+	FinalCpu     uint `mapstructure:"final_cpu"`
+	FinalRamSize uint `mapstructure:"final_ram_size"`
 	//
 	SecondaryDvdImages []string `mapstructure:"secondary_iso_images"`
 
@@ -86,6 +93,13 @@ type Config struct {
 
 	SkipCompaction bool `mapstructure:"skip_compaction"`
 
+	// The VHD or VHDX the disk of the VM is a differencing disk of,
+	// instead of a new empty disk. The ISO is optional then.
+	DifferencingDiskParent string `mapstructure:"differencing_disk_parent"`
+	// Replace the differencing disk with a standalone disk before the VM
+	// is exported, so that the parent isn't needed to use it.
+	MergeDifferencingDisk bool `mapstructure:"merge_differencing_disk"`
+
 	ctx interpolate.Context
 }
 
@@ -108,11 +122,28 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	var errs *packer.MultiError
 	warnings := make([]string, 0)
 
-	isoWarnings, isoErrs := b.config.ISOConfig.Prepare(&b.config.ctx)
-	warnings = append(warnings, isoWarnings...)
-	errs = packer.MultiErrorAppend(errs, isoErrs...)
+	if b.config.DifferencingDiskParent == "" || b.config.hasISO() {
+		isoWarnings, isoErrs := b.config.ISOConfig.Prepare(&b.config.ctx)
+		warnings = append(warnings, isoWarnings...)
+		errs = packer.MultiErrorAppend(errs, isoErrs...)
+	}
+
+	if b.config.DifferencingDiskParent != "" {
+		ext := strings.ToLower(filepath.Ext(b.config.DifferencingDiskParent))
+		if _, err := os.Stat(b.config.DifferencingDiskParent); err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("differencing_disk_parent is invalid: %s", err))
+		} else if ext != ".vhd" && ext != ".vhdx" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("differencing_disk_parent must be a .vhd or .vhdx file"))
+		}
+	} else if b.config.MergeDifferencingDisk {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("merge_differencing_disk requires differencing_disk_parent"))
+	}
 
 	errs = packer.MultiErrorAppend(errs, b.config.FloppyConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.HTTPConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.RunConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.OutputConfig.Prepare(&b.config.ctx, &b.config.PackerConfig)...)
@@ -129,6 +160,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		errs = packer.MultiErrorAppend(errs, err)
 	}
 
+	if b.config.FinalRamSize != 0 && (b.config.FinalRamSize < MinRamSize || b.config.FinalRamSize > MaxRamSize) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"final_ram_size: Virtual machine requires memory size between %v and %v MB, but defined: %v",
+			MinRamSize, MaxRamSize, b.config.FinalRamSize))
+	}
+
 	if b.config.VMName == "" {
 		b.config.VMName = fmt.Sprintf("packer-%s", b.config.PackerBuildName)
 	}
@@ -302,23 +339,42 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Force: b.config.PackerForce,
 			Path:  b.config.OutputDir,
 		},
-		&common.StepDownload{
-			Checksum:     b.config.ISOChecksum,
-			ChecksumType: b.config.ISOChecksumType,
-			Description:  "ISO",
-			ResultKey:    "iso_path",
-			Url:          b.config.ISOUrls,
-			Extension:    b.config.TargetExtension,
-			TargetPath:   b.config.TargetPath,
-		},
+	}
+
+	// VMs on a differencing disk may boot from it without an ISO
+	if b.config.hasISO() {
+		steps = append(steps,
+			&common.StepDownload{
+				Checksum:     b.config.ISOChecksum,
+				ChecksumType: b.config.ISOChecksumType,
+				Description:  "ISO",
+				ResultKey:    "iso_path",
+				Segments:     b.config.ISODownloadSegments,
+				Url:          b.config.ISOUrls,
+				Extension:    b.config.TargetExtension,
+				Proxy:        b.config.ISODownloadProxy,
+				TargetPath:   b.config.TargetPath,
+			},
+		)
+	}
+
+	steps = append(steps,
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
+			TempDir:     b.config.PackerTempDir,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 		&hypervcommon.StepCreateSwitch{
 			SwitchName: b.config.SwitchName,
@@ -334,12 +390,20 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			EnableDynamicMemory:            b.config.EnableDynamicMemory,
 			EnableSecureBoot:               b.config.EnableSecureBoot,
 			EnableVirtualizationExtensions: b.config.EnableVirtualizationExtensions,
+			DifferencingDiskParent:         b.config.DifferencingDiskParent,
 		},
 		&hypervcommon.StepEnableIntegrationService{},
+	)
 
-		&hypervcommon.StepMountDvdDrive{
-			Generation: b.config.Generation,
-		},
+	if b.config.hasISO() {
+		steps = append(steps,
+			&hypervcommon.StepMountDvdDrive{
+				Generation: b.config.Generation,
+			},
+		)
+	}
+
+	steps = append(steps,
 		&hypervcommon.StepMountFloppydrive{
 			Generation: b.config.Generation,
 		},
@@ -361,11 +425,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 
 		&hypervcommon.StepRun{
-			BootWait: b.config.BootWait,
+			BootWait:  b.config.BootWait,
+			BuildName: b.config.PackerBuildName,
 		},
 
+		&bootcommand.StepFailureScreenshot{
+			Path:       fmt.Sprintf("packer-%s-failure.png", b.config.PackerBuildName),
+			Screenshot: hypervcommon.Screenshot,
+		},
 		&hypervcommon.StepTypeBootCommand{
 			BootCommand: b.config.BootCommand,
+			BootConfig:  b.config.BootConfig,
 			SwitchName:  b.config.SwitchName,
 			Ctx:         b.config.ctx,
 		},
@@ -396,13 +466,18 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&hypervcommon.StepUnmountFloppyDrive{
 			Generation: b.config.Generation,
 		},
+		&hypervcommon.StepConfigureFinalHardware{
+			Cpu:     b.config.FinalCpu,
+			RamSize: b.config.FinalRamSize,
+		},
 		&hypervcommon.StepExportVm{
-			OutputDir:      b.config.OutputDir,
-			SkipCompaction: b.config.SkipCompaction,
+			OutputDir:             b.config.OutputDir,
+			SkipCompaction:        b.config.SkipCompaction,
+			MergeDifferencingDisk: b.config.MergeDifferencingDisk,
 		},
 
 		// the clean up actions for each step will be executed reverse order
-	}
+	)
 
 	// Run the steps.
 	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
@@ -433,6 +508,11 @@ func (b *Builder) Cancel() {
 	}
 }
 
+// hasISO returns true if the VM boots from an ISO.
+func (c *Config) hasISO() bool {
+	return c.RawSingleISOUrl != "" || len(c.ISOUrls) > 0
+}
+
 func appendWarnings(slice []string, data ...string) []string {
 	m := len(slice)
 	n := m + len(data)
@@ -2,6 +2,9 @@ package iso
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -79,6 +82,31 @@ func TestBuilderPrepare_DiskSize(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_FinalHardware(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	config["final_cpu"] = 1
+	config["final_ram_size"] = 1024
+	warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.FinalCpu != 1 || b.config.FinalRamSize != 1024 {
+		t.Fatalf("bad: %d %d", b.config.FinalCpu, b.config.FinalRamSize)
+	}
+
+	config["final_ram_size"] = 1
+	b = Builder{}
+	_, err = b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_FloppyFiles(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -298,3 +326,76 @@ func TestBuilderPrepare_ISOUrl(t *testing.T) {
 		t.Fatalf("bad: %#v", b.config.ISOUrls)
 	}
 }
+
+func TestBuilderPrepare_DifferencingDiskParent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	parent := filepath.Join(dir, "base.vhdx")
+	if err := ioutil.WriteFile(parent, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	other := filepath.Join(dir, "base.iso")
+	if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Config map[string]interface{}
+		NoISO  bool
+		Err    bool
+	}{
+		{
+			Config: map[string]interface{}{"differencing_disk_parent": parent},
+		},
+		{
+			Config: map[string]interface{}{
+				"differencing_disk_parent": parent,
+				"merge_differencing_disk":  true,
+			},
+			NoISO: true,
+		},
+		{
+			Config: map[string]interface{}{"differencing_disk_parent": filepath.Join(dir, "missing.vhdx")},
+			Err:    true,
+		},
+		{
+			Config: map[string]interface{}{"differencing_disk_parent": other},
+			Err:    true,
+		},
+		{
+			Config: map[string]interface{}{"merge_differencing_disk": true},
+			Err:    true,
+		},
+	}
+
+	for i, tc := range cases {
+		config := testConfig()
+		if tc.NoISO {
+			delete(config, "iso_url")
+			delete(config, "iso_checksum")
+			delete(config, "iso_checksum_type")
+		}
+		for k, v := range tc.Config {
+			config[k] = v
+		}
+
+		var b Builder
+		_, err := b.Prepare(config)
+		if tc.Err {
+			if err == nil {
+				t.Fatalf("%d: should have error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: should not have error: %s", i, err)
+		}
+		if b.config.hasISO() == tc.NoISO {
+			t.Fatalf("%d: bad: %#v", i, b.config.ISOUrls)
+		}
+	}
+}
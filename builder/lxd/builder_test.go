@@ -2,6 +2,7 @@ package lxd
 
 import (
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -75,3 +76,38 @@ func TestBuilder_ImplementsBuilder(t *testing.T) {
 		t.Fatalf("Builder should be a builder")
 	}
 }
+
+func TestBuilderPrepare_LaunchAndPublish(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["profile"] = "build"
+	config["launch_config"] = map[string]string{
+		"security.nesting": "true",
+		"limits.cpu":       "2",
+	}
+	config["publish_properties"] = map[string]string{
+		"os":          "Ubuntu",
+		"description": "Ubuntu with nginx",
+	}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	launch := []string{
+		"launch", "--ephemeral=false", "bar", b.config.ContainerName,
+		"--profile", "build",
+		"--config", "limits.cpu=2",
+		"--config", "security.nesting=true",
+	}
+	if args := launchArgs(b.config); !reflect.DeepEqual(args, launch) {
+		t.Fatalf("bad launch args: %#v", args)
+	}
+
+	publish := []string{
+		"publish", b.config.ContainerName, "--alias", "foo",
+		"description=Ubuntu with nginx", "os=Ubuntu",
+	}
+	if args := publishArgs(b.config); !reflect.DeepEqual(args, publish) {
+		t.Fatalf("bad publish args: %#v", args)
+	}
+}
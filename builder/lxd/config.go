@@ -16,8 +16,17 @@ type Config struct {
 	ContainerName       string `mapstructure:"container_name"`
 	CommandWrapper      string `mapstructure:"command_wrapper"`
 	Image               string `mapstructure:"image"`
+	Profile             string `mapstructure:"profile"`
 	InitTimeout         time.Duration
 
+	// LaunchConfig are configuration keys of the container, like
+	// "limits.cpu", set when it is launched.
+	LaunchConfig map[string]string `mapstructure:"launch_config"`
+
+	// PublishProperties are the properties of the published image, like
+	// "description" or "os".
+	PublishProperties map[string]string `mapstructure:"publish_properties"`
+
 	ctx interpolate.Context
 }
 
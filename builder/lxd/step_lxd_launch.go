@@ -13,15 +13,8 @@ func (s *stepLxdLaunch) Run(state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
 
-	name := config.ContainerName
-	image := config.Image
-
-	args := []string{
-		"launch", "--ephemeral=false", image, name,
-	}
-
 	ui.Say("Creating container...")
-	_, err := LXDCommand(args...)
+	_, err := LXDCommand(launchArgs(config)...)
 	if err != nil {
 		err := fmt.Errorf("Error creating container: %s", err)
 		state.Put("error", err)
@@ -48,3 +41,17 @@ func (s *stepLxdLaunch) Cleanup(state multistep.StateBag) {
 		ui.Error(fmt.Sprintf("Error deleting container: %s", err))
 	}
 }
+
+// launchArgs returns the arguments of lxc to launch the container.
+func launchArgs(config *Config) []string {
+	args := []string{
+		"launch", "--ephemeral=false", config.Image, config.ContainerName,
+	}
+	if config.Profile != "" {
+		args = append(args, "--profile", config.Profile)
+	}
+	for _, k := range sortedKeys(config.LaunchConfig) {
+		args = append(args, "--config", fmt.Sprintf("%s=%s", k, config.LaunchConfig[k]))
+	}
+	return args
+}
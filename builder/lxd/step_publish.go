@@ -5,6 +5,7 @@ import (
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
 	"regexp"
+	"sort"
 )
 
 type stepPublish struct{}
@@ -28,12 +29,8 @@ func (s *stepPublish) Run(state multistep.StateBag) multistep.StepAction {
 		return multistep.ActionHalt
 	}
 
-	publish_args := []string{
-		"publish", name, "--alias", config.OutputImage,
-	}
-
 	ui.Say("Publishing container...")
-	stdoutString, err := LXDCommand(publish_args...)
+	stdoutString, err := LXDCommand(publishArgs(config)...)
 	if err != nil {
 		err := fmt.Errorf("Error publishing container: %s", err)
 		state.Put("error", err)
@@ -52,3 +49,24 @@ func (s *stepPublish) Run(state multistep.StateBag) multistep.StepAction {
 }
 
 func (s *stepPublish) Cleanup(state multistep.StateBag) {}
+
+// publishArgs returns the arguments of lxc to publish the container as an
+// image with its alias and properties.
+func publishArgs(config *Config) []string {
+	args := []string{
+		"publish", config.ContainerName, "--alias", config.OutputImage,
+	}
+	for _, k := range sortedKeys(config.PublishProperties) {
+		args = append(args, fmt.Sprintf("%s=%s", k, config.PublishProperties[k]))
+	}
+	return args
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
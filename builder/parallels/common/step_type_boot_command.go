@@ -15,9 +15,11 @@ import (
 )
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort uint
-	Name     string
+	HTTPIP     string
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+	Name       string
 }
 
 // StepTypeBootCommand is a step that "types" the boot command into the VM via
@@ -69,9 +71,12 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 	ui.Say(fmt.Sprintf("Host IP for the Parallels machine: %s", hostIP))
 
 	packer_common.SetHTTPIP(hostIP)
+	httpScheme, httpToken := packer_common.HTTPServerFromState(state)
 	s.Ctx.Data = &bootCommandTemplateData{
 		hostIP,
 		httpPort,
+		httpScheme,
+		httpToken,
 		s.VMName,
 	}
 
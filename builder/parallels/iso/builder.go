@@ -156,7 +156,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			ChecksumType: b.config.ISOChecksumType,
 			Description:  "ISO",
 			Extension:    b.config.TargetExtension,
+			Proxy:        b.config.ISODownloadProxy,
 			ResultKey:    "iso_path",
+			Segments:     b.config.ISODownloadSegments,
 			TargetPath:   b.config.TargetPath,
 			Url:          b.config.ISOUrls,
 		},
@@ -167,11 +169,19 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
+			TempDir:     b.config.PackerTempDir,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 		new(stepCreateVM),
 		new(stepCreateDisk),
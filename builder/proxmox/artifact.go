@@ -0,0 +1,60 @@
+package proxmox
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+type Artifact struct {
+	// The id and name of the template
+	templateID   int
+	templateName string
+
+	// The node the template is on
+	node string
+
+	// The client for making API calls
+	client      *client
+	taskTimeout time.Duration
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*Artifact) Files() []string {
+	// No files with Proxmox
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return strconv.Itoa(a.templateID)
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("A template was created: '%s' (ID: %d) on node '%s'",
+		a.templateName, a.templateID, a.node)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return nil
+}
+
+// Metadata returns the node and id of the template.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	m := make(packer.ArtifactMetadata)
+	m.Set("node", a.node)
+	m.Set("template_id", strconv.Itoa(a.templateID))
+	return m
+}
+
+func (a *Artifact) Destroy() error {
+	log.Printf("Destroying template: %d (%s)", a.templateID, a.templateName)
+	vm := vmRef{Node: a.node, ID: a.templateID}
+	return a.client.task(a.node, "DELETE", vm.path(""), url.Values{"purge": {"1"}}, a.taskTimeout)
+}
@@ -0,0 +1,110 @@
+// The proxmox package contains a packer.Builder implementation that
+// builds Proxmox VE templates, by installing a VM from an ISO or by
+// cloning an existing VM.
+package proxmox
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// The unique id for the builder
+const BuilderId = "proxmox.template"
+
+type Builder struct {
+	config *Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	c, warnings, errs := NewConfig(raws...)
+	if errs != nil {
+		return warnings, errs
+	}
+	b.config = c
+
+	return warnings, nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	client, err := newClient(b.config)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.login(); err != nil {
+		return nil, err
+	}
+
+	// Set up the state
+	state := new(multistep.BasicStateBag)
+	state.Put("config", b.config)
+	state.Put("client", client)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	// Build the steps
+	steps := []multistep.Step{
+		&common.StepHTTPServer{
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
+		},
+		new(stepStartVM),
+		new(stepTypeBootCommand),
+		&communicator.StepConnect{
+			Config:    &b.config.Comm,
+			Host:      commHost(b.config),
+			SSHConfig: sshConfig(b.config),
+		},
+		new(common.StepProvision),
+		new(stepShutdown),
+		new(stepConvertToTemplate),
+	}
+
+	// Run the steps
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, fmt.Errorf("Build was cancelled.")
+	}
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, fmt.Errorf("Build was halted.")
+	}
+
+	vm := state.Get("vm").(vmRef)
+	artifact := &Artifact{
+		templateID:   vm.ID,
+		templateName: b.config.TemplateName,
+		node:         vm.Node,
+		client:       client,
+		taskTimeout:  b.config.TaskTimeout,
+	}
+
+	return artifact, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
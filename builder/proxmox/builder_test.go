@@ -0,0 +1,152 @@
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":  "https://pve.example.com:8006/api2/json",
+		"username":     "root@pam",
+		"password":     "secret",
+		"node":         "pve",
+		"iso_file":     "local:iso/debian.iso",
+		"ssh_username": "root",
+		"disks": []map[string]interface{}{
+			{"storage_pool": "local-lvm"},
+		},
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_InvalidKey(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Add a random key
+	config["i_should_not_be_valid"] = true
+	_, err := b.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	if _, err := b.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := b.config
+	if c.Memory != 512 || c.Cores != 1 || c.Sockets != 1 || c.OS != "other" {
+		t.Fatalf("bad hardware: %#v", c)
+	}
+	if c.Disks[0].Type != "scsi" || c.Disks[0].Size != "20G" {
+		t.Fatalf("bad disks: %#v", c.Disks)
+	}
+	if len(c.NICs) != 1 || c.NICs[0].Model != "virtio" || c.NICs[0].Bridge != "vmbr0" {
+		t.Fatalf("bad network adapters: %#v", c.NICs)
+	}
+	if !*c.QemuAgent || !*c.FullClone {
+		t.Fatal("qemu_agent and full_clone should default to true")
+	}
+	if c.TemplateName != c.VMName {
+		t.Fatalf("bad template name: %s", c.TemplateName)
+	}
+	if c.CloudInitStoragePool != "local-lvm" {
+		t.Fatalf("bad cloud-init storage pool: %s", c.CloudInitStoragePool)
+	}
+}
+
+func TestBuilderPrepare_Clone(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	delete(config, "iso_file")
+	delete(config, "disks")
+	config["clone_vm"] = "debian-base"
+	config["full_clone"] = false
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Clones keep their hardware
+	if b.config.Memory != 0 || len(b.config.NICs) != 0 {
+		t.Fatalf("bad: %#v", b.config)
+	}
+	if *b.config.FullClone {
+		t.Fatal("full_clone should be false")
+	}
+}
+
+func TestBuilderPrepare_Errors(t *testing.T) {
+	cases := map[string]func(map[string]interface{}){
+		"no url":      func(c map[string]interface{}) { delete(c, "proxmox_url") },
+		"no node":     func(c map[string]interface{}) { delete(c, "node") },
+		"no password": func(c map[string]interface{}) { delete(c, "password") },
+		"token without id": func(c map[string]interface{}) {
+			delete(c, "password")
+			c["token"] = "abc"
+		},
+		"no source": func(c map[string]interface{}) { delete(c, "iso_file") },
+		"both sources": func(c map[string]interface{}) {
+			c["clone_vm"] = "base"
+		},
+		"no disks": func(c map[string]interface{}) { delete(c, "disks") },
+		"bad disk type": func(c map[string]interface{}) {
+			c["disks"] = []map[string]interface{}{{"storage_pool": "local", "type": "floppy"}}
+		},
+		"bad disk size": func(c map[string]interface{}) {
+			c["disks"] = []map[string]interface{}{{"storage_pool": "local", "disk_size": "20T"}}
+		},
+		"no storage pool": func(c map[string]interface{}) {
+			c["disks"] = []map[string]interface{}{{"disk_size": "10G"}}
+		},
+		"too many ide disks": func(c map[string]interface{}) {
+			disk := map[string]interface{}{"storage_pool": "local", "type": "ide"}
+			c["disks"] = []map[string]interface{}{disk, disk, disk}
+		},
+		"no agent or host": func(c map[string]interface{}) { c["qemu_agent"] = false },
+		"unmount clone": func(c map[string]interface{}) {
+			delete(c, "iso_file")
+			delete(c, "disks")
+			c["clone_vm"] = "base"
+			c["unmount_iso"] = true
+		},
+		"cloud-init without pool": func(c map[string]interface{}) {
+			delete(c, "iso_file")
+			delete(c, "disks")
+			c["clone_vm"] = "base"
+			c["cloud_init"] = true
+		},
+	}
+
+	for name, f := range cases {
+		config := testConfig()
+		f(config)
+
+		var b Builder
+		if _, err := b.Prepare(config); err == nil {
+			t.Fatalf("%s: should have error", name)
+		}
+	}
+}
+
+func TestBuilderPrepare_Token(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	delete(config, "password")
+	config["username"] = "packer@pve!build"
+	config["token"] = "7c2b5a5e-0f8e-4c1b-9a3e-2f1d2c3b4a5d"
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
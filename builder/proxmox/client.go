@@ -0,0 +1,310 @@
+package proxmox
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// taskPollInterval is how often the status of Proxmox tasks and VMs is
+// checked while waiting for them.
+var taskPollInterval = 2 * time.Second
+
+// vmRef identifies a VM of the cluster.
+type vmRef struct {
+	Node string
+	ID   int
+}
+
+func (r vmRef) path(format string, args ...interface{}) string {
+	return fmt.Sprintf("/nodes/%s/qemu/%d", url.PathEscape(r.Node), r.ID) +
+		fmt.Sprintf(format, args...)
+}
+
+// client is a client of the Proxmox VE API. It authenticates either with
+// an API token or with a ticket obtained with the password of the user.
+type client struct {
+	url      string
+	username string
+	password string
+	token    string
+
+	http *http.Client
+
+	ticket string
+	csrf   string
+}
+
+func newClient(c *Config) (*client, error) {
+	u, err := url.Parse(c.ProxmoxURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid proxmox_url: %s", err)
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: c.SkipCertValidation,
+		},
+	}
+
+	return &client{
+		url:      strings.TrimSuffix(u.String(), "/"),
+		username: c.Username,
+		password: c.Password,
+		token:    c.Token,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   5 * time.Minute,
+		},
+	}, nil
+}
+
+// login obtains a ticket with the password of the user. It does nothing
+// when an API token is used.
+func (c *client) login() error {
+	if c.token != "" {
+		return nil
+	}
+
+	var ticket struct {
+		Ticket string `json:"ticket"`
+		CSRF   string `json:"CSRFPreventionToken"`
+	}
+	err := c.do("POST", "/access/ticket", url.Values{
+		"username": {c.username},
+		"password": {c.password},
+	}, &ticket)
+	if err != nil {
+		return fmt.Errorf("Error logging in to Proxmox: %s", err)
+	}
+
+	c.ticket = ticket.Ticket
+	c.csrf = ticket.CSRF
+	return nil
+}
+
+// do sends a request to the API and decodes the data of the response into
+// out, if it isn't nil. The parameters are sent in the query of GET and
+// DELETE requests and as a form otherwise.
+func (c *client) do(method, path string, params url.Values, out interface{}) error {
+	u := c.url + path
+	var body *bytes.Buffer
+	if method == "GET" || method == "DELETE" {
+		if len(params) > 0 {
+			u += "?" + params.Encode()
+		}
+		body = new(bytes.Buffer)
+	} else {
+		body = bytes.NewBufferString(params.Encode())
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if method != "GET" && method != "DELETE" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", c.username, c.token))
+	} else if c.ticket != "" {
+		req.AddCookie(&http.Cookie{Name: "PVEAuthCookie", Value: c.ticket})
+		if method != "GET" {
+			req.Header.Set("CSRFPreventionToken", c.csrf)
+		}
+	}
+
+	log.Printf("[DEBUG] (proxmox) %s %s", method, path)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Data   json.RawMessage   `json:"data"`
+		Errors map[string]string `json:"errors"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &result); err != nil && resp.StatusCode < 300 {
+			return fmt.Errorf("Error decoding the response of %s %s: %s", method, path, err)
+		}
+	}
+
+	if resp.StatusCode >= 300 {
+		msg := resp.Status
+		if len(result.Errors) > 0 {
+			keys := make([]string, 0, len(result.Errors))
+			for k := range result.Errors {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				msg += fmt.Sprintf("; %s: %s", k, strings.TrimSpace(result.Errors[k]))
+			}
+		}
+		return fmt.Errorf("%s %s: %s", method, path, msg)
+	}
+
+	if out == nil || len(result.Data) == 0 || string(result.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// task runs a request that starts a task, like cloning a VM, and waits
+// for the task to end.
+func (c *client) task(node, method, path string, params url.Values, timeout time.Duration) error {
+	var upid string
+	if err := c.do(method, path, params, &upid); err != nil {
+		return err
+	}
+	if upid == "" {
+		return nil
+	}
+	return c.waitForTask(node, upid, timeout)
+}
+
+// waitForTask waits for the task to end and returns an error if it
+// failed.
+func (c *client) waitForTask(node, upid string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/status", url.PathEscape(node), url.PathEscape(upid))
+	for {
+		var status struct {
+			Status     string `json:"status"`
+			ExitStatus string `json:"exitstatus"`
+		}
+		if err := c.do("GET", path, nil, &status); err != nil {
+			return err
+		}
+		if status.Status == "stopped" {
+			if status.ExitStatus != "OK" {
+				return fmt.Errorf("task %s failed: %s", upid, status.ExitStatus)
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for task %s", upid)
+		}
+		time.Sleep(taskPollInterval)
+	}
+}
+
+// nextID returns a free VM id.
+func (c *client) nextID() (int, error) {
+	var id json.Number
+	if err := c.do("GET", "/cluster/nextid", nil, &id); err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(string(id))
+	if err != nil {
+		return 0, fmt.Errorf("invalid VM id %q: %s", id, err)
+	}
+	return n, nil
+}
+
+// findVM returns the VM with the name.
+func (c *client) findVM(name string) (vmRef, error) {
+	var vms []struct {
+		ID   int    `json:"vmid"`
+		Name string `json:"name"`
+		Node string `json:"node"`
+	}
+	params := url.Values{"type": {"vm"}}
+	if err := c.do("GET", "/cluster/resources", params, &vms); err != nil {
+		return vmRef{}, err
+	}
+
+	for _, vm := range vms {
+		if vm.Name == name {
+			return vmRef{Node: vm.Node, ID: vm.ID}, nil
+		}
+	}
+	return vmRef{}, fmt.Errorf("VM %q not found", name)
+}
+
+// vmStatus returns the status of the VM, like "running" or "stopped".
+func (c *client) vmStatus(vm vmRef) (string, error) {
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := c.do("GET", vm.path("/status/current"), nil, &status); err != nil {
+		return "", err
+	}
+	return status.Status, nil
+}
+
+// waitForStatus waits for the VM to reach the status.
+func (c *client) waitForStatus(vm vmRef, want string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := c.vmStatus(vm)
+		if err != nil {
+			return err
+		}
+		if status == want {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for the VM to be %s, it is %s", want, status)
+		}
+		time.Sleep(taskPollInterval)
+	}
+}
+
+// vmConfig returns the configuration of the VM.
+func (c *client) vmConfig(vm vmRef) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	err := c.do("GET", vm.path("/config"), nil, &config)
+	return config, err
+}
+
+// vmIPAddress returns the first IPv4 address of the VM that isn't a
+// loopback address, as reported by the QEMU guest agent.
+func (c *client) vmIPAddress(vm vmRef) (string, error) {
+	var result struct {
+		Result []struct {
+			Name      string `json:"name"`
+			Addresses []struct {
+				Type    string `json:"ip-address-type"`
+				Address string `json:"ip-address"`
+			} `json:"ip-addresses"`
+		} `json:"result"`
+	}
+	if err := c.do("GET", vm.path("/agent/network-get-interfaces"), nil, &result); err != nil {
+		return "", err
+	}
+
+	for _, iface := range result.Result {
+		for _, addr := range iface.Addresses {
+			if addr.Type == "ipv4" && !strings.HasPrefix(addr.Address, "127.") {
+				return addr.Address, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the guest agent reports no IPv4 address yet")
+}
+
+// sendKey presses a key, in the syntax of QEMU, like "ret" or
+// "ctrl-alt-delete", on the keyboard of the VM.
+func (c *client) sendKey(vm vmRef, key string) error {
+	return c.do("PUT", vm.path("/sendkey"), url.Values{"key": {key}}, nil)
+}
@@ -0,0 +1,141 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testClient(t *testing.T, handler http.HandlerFunc, token string) (*client, func()) {
+	server := httptest.NewServer(handler)
+	c, err := newClient(&Config{
+		ProxmoxURL: server.URL + "/api2/json",
+		Username:   "root@pam",
+		Password:   "secret",
+		Token:      token,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return c, server.Close
+}
+
+func TestClient_login(t *testing.T) {
+	c, done := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/json/access/ticket":
+			if r.FormValue("username") != "root@pam" || r.FormValue("password") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"ticket":"PVE:ticket","CSRFPreventionToken":"csrf"}}`)
+		case "/api2/json/nodes/pve/qemu/100/config":
+			cookie, err := r.Cookie("PVEAuthCookie")
+			if err != nil || cookie.Value != "PVE:ticket" || r.Header.Get("CSRFPreventionToken") != "csrf" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"data":null}`)
+		}
+	}, "")
+	defer done()
+
+	if err := c.login(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := c.do("POST", vmRef{"pve", 100}.path("/config"), nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestClient_token(t *testing.T) {
+	c, done := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "PVEAPIToken=root@pam=abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":"101"}`)
+	}, "abc")
+	defer done()
+
+	if err := c.login(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	id, err := c.nextID()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != 101 {
+		t.Fatalf("bad id: %d", id)
+	}
+}
+
+func TestClient_error(t *testing.T) {
+	c, done := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"data":null,"errors":{"memory":"value must be at least 16\n"}}`)
+	}, "abc")
+	defer done()
+
+	err := c.do("POST", "/nodes/pve/qemu", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "memory: value must be at least 16") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestClient_task(t *testing.T) {
+	defer func(d time.Duration) { taskPollInterval = d }(taskPollInterval)
+	taskPollInterval = time.Millisecond
+
+	polls := 0
+	exitStatus := "OK"
+	c, done := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			fmt.Fprint(w, `{"data":"UPID:pve:1:2:3:qmclone:100:root@pam:"}`)
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			polls++
+			if polls < 3 {
+				fmt.Fprint(w, `{"data":{"status":"running"}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"data":{"status":"stopped","exitstatus":%q}}`, exitStatus)
+		}
+	}, "abc")
+	defer done()
+
+	if err := c.task("pve", "POST", "/nodes/pve/qemu/100/clone", nil, time.Minute); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if polls != 3 {
+		t.Fatalf("the task should be polled until it stopped, polled %d times", polls)
+	}
+
+	polls = 0
+	exitStatus = "storage full"
+	if err := c.task("pve", "POST", "/nodes/pve/qemu/100/clone", nil, time.Minute); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestClient_vmIPAddress(t *testing.T) {
+	c, done := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"result":[
+			{"name":"lo","ip-addresses":[{"ip-address-type":"ipv4","ip-address":"127.0.0.1"}]},
+			{"name":"eth0","ip-addresses":[
+				{"ip-address-type":"ipv6","ip-address":"fe80::1"},
+				{"ip-address-type":"ipv4","ip-address":"10.0.0.5"}]}]}}`)
+	}, "abc")
+	defer done()
+
+	ip, err := c.vmIPAddress(vmRef{"pve", 100})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ip != "10.0.0.5" {
+		t.Fatalf("bad ip: %s", ip)
+	}
+}
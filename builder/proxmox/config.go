@@ -0,0 +1,287 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/mapstructure"
+)
+
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	common.HTTPConfig      `mapstructure:",squash"`
+	bootcommand.BootConfig `mapstructure:",squash"`
+	Comm                   communicator.Config `mapstructure:",squash"`
+
+	ProxmoxURL         string `mapstructure:"proxmox_url"`
+	SkipCertValidation bool   `mapstructure:"insecure_skip_tls_verify"`
+	Username           string `mapstructure:"username"`
+	Password           string `mapstructure:"password"`
+	Token              string `mapstructure:"token"`
+	Node               string `mapstructure:"node"`
+	Pool               string `mapstructure:"pool"`
+
+	VMID   int    `mapstructure:"vm_id"`
+	VMName string `mapstructure:"vm_name"`
+
+	CloneVM   string `mapstructure:"clone_vm"`
+	FullClone *bool  `mapstructure:"full_clone"`
+	ISOFile   string `mapstructure:"iso_file"`
+
+	Memory    int          `mapstructure:"memory"`
+	Cores     int          `mapstructure:"cores"`
+	Sockets   int          `mapstructure:"sockets"`
+	CPUType   string       `mapstructure:"cpu_type"`
+	OS        string       `mapstructure:"os"`
+	Disks     []diskConfig `mapstructure:"disks"`
+	NICs      []nicConfig  `mapstructure:"network_adapters"`
+	QemuAgent *bool        `mapstructure:"qemu_agent"`
+
+	BootCommand []string      `mapstructure:"boot_command"`
+	BootWait    time.Duration `mapstructure:"boot_wait"`
+
+	TemplateName         string `mapstructure:"template_name"`
+	TemplateDescription  string `mapstructure:"template_description"`
+	UnmountISO           bool   `mapstructure:"unmount_iso"`
+	CloudInit            bool   `mapstructure:"cloud_init"`
+	CloudInitStoragePool string `mapstructure:"cloud_init_storage_pool"`
+
+	TaskTimeout     time.Duration `mapstructure:"task_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	ctx interpolate.Context
+}
+
+type diskConfig struct {
+	Type        string `mapstructure:"type"`
+	StoragePool string `mapstructure:"storage_pool"`
+	Size        string `mapstructure:"disk_size"`
+	Format      string `mapstructure:"format"`
+}
+
+// sizeGB returns the size of the disk in gigabytes, the unit Proxmox
+// allocates disks in.
+func (d diskConfig) sizeGB() (int, error) {
+	size, err := strconv.Atoi(strings.TrimSuffix(d.Size, "G"))
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid disk_size %q, must be a number of gigabytes like 20G", d.Size)
+	}
+	return size, nil
+}
+
+type nicConfig struct {
+	Model   string `mapstructure:"model"`
+	Bridge  string `mapstructure:"bridge"`
+	VLANTag string `mapstructure:"vlan_tag"`
+}
+
+// diskTypes are the buses disks can be attached to, with the number of
+// slots of each. Of the 4 ide slots, ide2 holds the ISO and ide0 the
+// cloud-init drive.
+var diskTypes = map[string]int{
+	"ide":    2,
+	"sata":   6,
+	"scsi":   31,
+	"virtio": 16,
+}
+
+func NewConfig(raws ...interface{}) (*Config, []string, error) {
+	c := new(Config)
+
+	var md mapstructure.Metadata
+	err := config.Decode(c, &config.DecodeOpts{
+		Metadata:           &md,
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"boot_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Defaults
+	if c.ProxmoxURL == "" {
+		c.ProxmoxURL = os.Getenv("PROXMOX_URL")
+	}
+	if c.Username == "" {
+		c.Username = os.Getenv("PROXMOX_USERNAME")
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv("PROXMOX_PASSWORD")
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("PROXMOX_TOKEN")
+	}
+	if c.VMName == "" {
+		c.VMName = fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
+	}
+	if c.TemplateName == "" {
+		c.TemplateName = c.VMName
+	}
+	if c.FullClone == nil {
+		t := true
+		c.FullClone = &t
+	}
+	if c.QemuAgent == nil {
+		t := true
+		c.QemuAgent = &t
+	}
+	if c.CloneVM == "" {
+		// Clones keep the hardware of the VM they are cloned from
+		// unless it is configured.
+		if c.Memory == 0 {
+			c.Memory = 512
+		}
+		if c.Cores == 0 {
+			c.Cores = 1
+		}
+		if c.Sockets == 0 {
+			c.Sockets = 1
+		}
+		if c.CPUType == "" {
+			c.CPUType = "kvm64"
+		}
+		if c.OS == "" {
+			c.OS = "other"
+		}
+	}
+	if c.BootWait == 0 {
+		c.BootWait = 10 * time.Second
+	}
+	if c.TaskTimeout == 0 {
+		c.TaskTimeout = 10 * time.Minute
+	}
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = 5 * time.Minute
+	}
+	for i := range c.Disks {
+		if c.Disks[i].Type == "" {
+			c.Disks[i].Type = "scsi"
+		}
+		if c.Disks[i].Size == "" {
+			c.Disks[i].Size = "20G"
+		}
+	}
+	if len(c.NICs) == 0 && c.CloneVM == "" {
+		c.NICs = []nicConfig{{}}
+	}
+	for i := range c.NICs {
+		if c.NICs[i].Model == "" {
+			c.NICs[i].Model = "virtio"
+		}
+		if c.NICs[i].Bridge == "" {
+			c.NICs[i].Bridge = "vmbr0"
+		}
+	}
+	if c.CloudInitStoragePool == "" && len(c.Disks) > 0 {
+		c.CloudInitStoragePool = c.Disks[0].StoragePool
+	}
+
+	var errs *packer.MultiError
+	errs = packer.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
+	errs = packer.MultiErrorAppend(errs, c.BootConfig.Prepare(&c.ctx)...)
+	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if c.ProxmoxURL == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("proxmox_url must be specified"))
+	}
+	if c.Username == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("username must be specified"))
+	}
+	if c.Password == "" && c.Token == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("one of password or token must be specified"))
+	}
+	if c.Token != "" && !strings.Contains(c.Username, "!") {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("username must be of the form user@realm!tokenid when using a token"))
+	}
+	if c.Node == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("node must be specified"))
+	}
+	if c.VMID < 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("vm_id can't be negative"))
+	}
+
+	if c.CloneVM == "" && c.ISOFile == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("one of clone_vm or iso_file must be specified"))
+	} else if c.CloneVM != "" && c.ISOFile != "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("only one of clone_vm or iso_file can be specified"))
+	}
+	if c.ISOFile != "" && len(c.Disks) == 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("disks must be specified when installing from iso_file"))
+	}
+	if c.CloneVM != "" && len(c.Disks) > 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("disks can't be specified with clone_vm, the disks of the clone are used"))
+	}
+	if c.UnmountISO && c.ISOFile == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("unmount_iso can only be used with iso_file"))
+	}
+
+	slots := make(map[string]int)
+	for i, disk := range c.Disks {
+		if disk.StoragePool == "" {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("disks[%d]: storage_pool must be specified", i))
+		}
+		if _, err := disk.sizeGB(); err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("disks[%d]: %s", i, err))
+		}
+
+		max, ok := diskTypes[disk.Type]
+		if !ok {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("disks[%d]: unknown type %q, must be one of ide, sata, scsi or virtio", i, disk.Type))
+			continue
+		}
+		slots[disk.Type]++
+		if slots[disk.Type] > max {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("disks[%d]: too many %s disks, at most %d are supported", i, disk.Type, max))
+		}
+	}
+	if c.CloudInit && c.CloudInitStoragePool == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("cloud_init_storage_pool must be specified with cloud_init"))
+	}
+
+	if !*c.QemuAgent && c.Comm.Type != "none" && c.Comm.Host() == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("the IP address of the VM is found with the guest agent: "+
+				"qemu_agent must be true, or ssh_host or winrm_host specified"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, nil, errs
+	}
+
+	common.ScrubConfig(c, c.Password, c.Token)
+	return c, nil, nil
+}
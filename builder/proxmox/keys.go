@@ -0,0 +1,163 @@
+package proxmox
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// specialKeys are the QEMU names of the special keys of boot commands.
+var specialKeys = map[string]string{
+	"<bs>":         "backspace",
+	"<del>":        "delete",
+	"<enter>":      "ret",
+	"<return>":     "ret",
+	"<esc>":        "esc",
+	"<tab>":        "tab",
+	"<spacebar>":   "spc",
+	"<up>":         "up",
+	"<down>":       "down",
+	"<left>":       "left",
+	"<right>":      "right",
+	"<insert>":     "insert",
+	"<home>":       "home",
+	"<end>":        "end",
+	"<pageUp>":     "pgup",
+	"<pageDown>":   "pgdn",
+	"<leftAlt>":    "alt",
+	"<leftCtrl>":   "ctrl",
+	"<leftShift>":  "shift",
+	"<rightAlt>":   "alt_r",
+	"<rightCtrl>":  "ctrl_r",
+	"<rightShift>": "shift_r",
+	"<f1>":         "f1",
+	"<f2>":         "f2",
+	"<f3>":         "f3",
+	"<f4>":         "f4",
+	"<f5>":         "f5",
+	"<f6>":         "f6",
+	"<f7>":         "f7",
+	"<f8>":         "f8",
+	"<f9>":         "f9",
+	"<f10>":        "f10",
+	"<f11>":        "f11",
+	"<f12>":        "f12",
+}
+
+// charKeys are the QEMU names of the characters that aren't named after
+// themselves, and whether shift is pressed to type them.
+var charKeys = map[rune]struct {
+	key   string
+	shift bool
+}{
+	' ':  {"spc", false},
+	'\n': {"ret", false},
+	'\t': {"tab", false},
+	'-':  {"minus", false},
+	'_':  {"minus", true},
+	'=':  {"equal", false},
+	'+':  {"equal", true},
+	'[':  {"bracket_left", false},
+	'{':  {"bracket_left", true},
+	']':  {"bracket_right", false},
+	'}':  {"bracket_right", true},
+	';':  {"semicolon", false},
+	':':  {"semicolon", true},
+	'\'': {"apostrophe", false},
+	'"':  {"apostrophe", true},
+	'`':  {"grave_accent", false},
+	'~':  {"grave_accent", true},
+	'\\': {"backslash", false},
+	'|':  {"backslash", true},
+	',':  {"comma", false},
+	'<':  {"comma", true},
+	'.':  {"dot", false},
+	'>':  {"dot", true},
+	'/':  {"slash", false},
+	'?':  {"slash", true},
+	'!':  {"1", true},
+	'@':  {"2", true},
+	'#':  {"3", true},
+	'$':  {"4", true},
+	'%':  {"5", true},
+	'^':  {"6", true},
+	'&':  {"7", true},
+	'*':  {"8", true},
+	'(':  {"9", true},
+	')':  {"0", true},
+}
+
+var modifierRe = regexp.MustCompile(`^<(leftAlt|leftCtrl|leftShift|rightAlt|rightCtrl|rightShift)(On|Off)>`)
+
+// qemuKeys translates keys written in the boot_command syntax to the QEMU
+// key names the Proxmox API sends, like "shift-a" or "ctrl-alt-delete".
+// Proxmox only sends whole key presses, so modifiers turned on with
+// <leftCtrlOn> are pressed with each key until they are turned off.
+func qemuKeys(keys string) []string {
+	var result []string
+	var held []string
+
+	press := func(key string, shift bool) {
+		mods := append([]string(nil), held...)
+		if shift && !containsString(mods, "shift") && !containsString(mods, "shift_r") {
+			mods = append(mods, "shift")
+		}
+		result = append(result, strings.Join(append(mods, key), "-"))
+	}
+
+	for len(keys) > 0 {
+		if m := modifierRe.FindStringSubmatch(keys); m != nil {
+			mod := specialKeys["<"+m[1]+">"]
+			if m[2] == "On" {
+				if !containsString(held, mod) {
+					held = append(held, mod)
+				}
+			} else {
+				for i, h := range held {
+					if h == mod {
+						held = append(held[:i], held[i+1:]...)
+						break
+					}
+				}
+			}
+			keys = keys[len(m[0]):]
+			continue
+		}
+
+		if strings.HasPrefix(keys, "<") {
+			if i := strings.Index(keys, ">"); i > 0 {
+				if key, ok := specialKeys[keys[:i+1]]; ok {
+					press(key, false)
+					keys = keys[i+1:]
+					continue
+				}
+			}
+		}
+
+		r := []rune(keys)[0]
+		keys = keys[len(string(r)):]
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			press(string(r), false)
+		case r >= 'A' && r <= 'Z':
+			press(strings.ToLower(string(r)), true)
+		default:
+			if k, ok := charKeys[r]; ok {
+				press(k.key, k.shift)
+			} else {
+				log.Printf("[WARN] (proxmox) Can't type %q, skipping it", r)
+			}
+		}
+	}
+
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
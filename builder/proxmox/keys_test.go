@@ -0,0 +1,28 @@
+package proxmox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQemuKeys(t *testing.T) {
+	cases := []struct {
+		Keys string
+		Want []string
+	}{
+		{"ls -l", []string{"l", "s", "spc", "minus", "l"}},
+		{"Ab:", []string{"shift-a", "b", "shift-semicolon"}},
+		{"<esc><enter>", []string{"esc", "ret"}},
+		{"<leftCtrlOn><leftAltOn><del><leftAltOff><leftCtrlOff>x",
+			[]string{"ctrl-alt-delete", "x"}},
+		{"<leftShiftOn>A<leftShiftOff>", []string{"shift-a"}},
+		{"1 < 2", []string{"1", "spc", "shift-comma", "spc", "2"}},
+		{"<f12>", []string{"f12"}},
+	}
+
+	for _, tc := range cases {
+		if got := qemuKeys(tc.Keys); !reflect.DeepEqual(got, tc.Want) {
+			t.Fatalf("%q: bad keys: %#v", tc.Keys, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package proxmox
+
+import (
+	commonssh "github.com/hashicorp/packer/common/ssh"
+	"github.com/hashicorp/packer/communicator/ssh"
+	"github.com/mitchellh/multistep"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// commHost returns the host to connect to: ssh_host or winrm_host if set,
+// otherwise the address the guest agent reports.
+func commHost(c *Config) func(multistep.StateBag) (string, error) {
+	return func(state multistep.StateBag) (string, error) {
+		if host := c.Comm.Host(); host != "" {
+			return host, nil
+		}
+
+		client := state.Get("client").(*client)
+		vm := state.Get("vm").(vmRef)
+		return client.vmIPAddress(vm)
+	}
+}
+
+func sshConfig(c *Config) func(multistep.StateBag) (*gossh.ClientConfig, error) {
+	return func(state multistep.StateBag) (*gossh.ClientConfig, error) {
+		auth := []gossh.AuthMethod{
+			gossh.Password(c.Comm.SSHPassword),
+			gossh.KeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(c.Comm.SSHPassword)),
+		}
+
+		if c.Comm.SSHPrivateKey != "" {
+			signer, err := commonssh.FileSigner(c.Comm.SSHPrivateKey)
+			if err != nil {
+				return nil, err
+			}
+
+			auth = append(auth, gossh.PublicKeys(signer))
+		}
+
+		return &gossh.ClientConfig{
+			User:            c.Comm.SSHUsername,
+			Auth:            auth,
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		}, nil
+	}
+}
@@ -0,0 +1,58 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepConvertToTemplate names the stopped VM and converts it to a
+// template, with a cloud-init drive if configured.
+//
+// Produces:
+//
+//	template_id int - The id of the template.
+type stepConvertToTemplate struct{}
+
+func (s *stepConvertToTemplate) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*client)
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	vm := state.Get("vm").(vmRef)
+
+	params := url.Values{"name": {c.TemplateName}}
+	if c.TemplateDescription != "" {
+		params.Set("description", c.TemplateDescription)
+	}
+	if c.UnmountISO {
+		params.Set("ide2", "none,media=cdrom")
+	}
+	if c.CloudInit {
+		// The credentials of the build aren't left in the template.
+		params.Set("delete", "ciuser,cipassword,sshkeys")
+
+		config, err := client.vmConfig(vm)
+		if err != nil {
+			return halt(state, fmt.Errorf("Error getting the configuration of the VM: %s", err))
+		}
+		if !hasCloudInitDrive(config) {
+			ui.Say("Adding cloud-init drive...")
+			params.Set(cloudInitDrive, c.CloudInitStoragePool+":cloudinit")
+		}
+	}
+	if err := client.do("POST", vm.path("/config"), params, nil); err != nil {
+		return halt(state, fmt.Errorf("Error configuring the VM: %s", err))
+	}
+
+	ui.Say(fmt.Sprintf("Converting VM %d to template %s...", vm.ID, c.TemplateName))
+	if err := client.task(vm.Node, "POST", vm.path("/template"), nil, c.TaskTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error converting the VM to a template: %s", err))
+	}
+
+	state.Put("template_id", vm.ID)
+	return multistep.ActionContinue
+}
+
+func (s *stepConvertToTemplate) Cleanup(state multistep.StateBag) {}
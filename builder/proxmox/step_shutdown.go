@@ -0,0 +1,34 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepShutdown shuts the VM down, through ACPI or the guest agent, and
+// waits for it to stop.
+type stepShutdown struct{}
+
+func (s *stepShutdown) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*client)
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	vm := state.Get("vm").(vmRef)
+
+	ui.Say("Shutting down VM...")
+	params := url.Values{"timeout": {strconv.Itoa(int(c.ShutdownTimeout.Seconds()))}}
+	if err := client.task(vm.Node, "POST", vm.path("/status/shutdown"), params, c.ShutdownTimeout+c.TaskTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error shutting down the VM: %s", err))
+	}
+	if err := client.waitForStatus(vm, "stopped", c.ShutdownTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error shutting down the VM: %s", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepShutdown) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,243 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer/helper/communicator"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// stepStartVM creates the VM, either by cloning clone_vm or as a new VM
+// booting iso_file, and starts it. The VM is deleted if the build fails.
+//
+// Produces:
+//
+//	vm vmRef - The VM.
+type stepStartVM struct {
+	vm vmRef
+}
+
+func (s *stepStartVM) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*client)
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	id := c.VMID
+	if id == 0 {
+		var err error
+		if id, err = client.nextID(); err != nil {
+			return halt(state, fmt.Errorf("Error getting a free VM id: %s", err))
+		}
+	}
+	vm := vmRef{Node: c.Node, ID: id}
+
+	if c.CloneVM != "" {
+		ui.Say(fmt.Sprintf("Cloning %s to VM %d...", c.CloneVM, vm.ID))
+		src, err := client.findVM(c.CloneVM)
+		if err != nil {
+			return halt(state, fmt.Errorf("Error finding the VM to clone: %s", err))
+		}
+
+		params := url.Values{
+			"newid":  {strconv.Itoa(vm.ID)},
+			"name":   {c.VMName},
+			"target": {vm.Node},
+			"full":   {boolParam(*c.FullClone)},
+		}
+		if c.Pool != "" {
+			params.Set("pool", c.Pool)
+		}
+		if err := client.task(src.Node, "POST", src.path("/clone"), params, c.TaskTimeout); err != nil {
+			return halt(state, fmt.Errorf("Error cloning the VM: %s", err))
+		}
+		s.vm = vm
+
+		params = vmParams(c)
+		if c.CloudInit {
+			cloudInit, err := s.cloudInitParams(client, c)
+			if err != nil {
+				return halt(state, fmt.Errorf("Error configuring cloud-init: %s", err))
+			}
+			for k, v := range cloudInit {
+				params[k] = v
+			}
+		}
+		if err := client.do("POST", vm.path("/config"), params, nil); err != nil {
+			return halt(state, fmt.Errorf("Error configuring the VM: %s", err))
+		}
+	} else {
+		ui.Say(fmt.Sprintf("Creating VM %d...", vm.ID))
+		params := vmParams(c)
+		params.Set("vmid", strconv.Itoa(vm.ID))
+		params.Set("name", c.VMName)
+		params.Set("ide2", c.ISOFile+",media=cdrom")
+		if c.Pool != "" {
+			params.Set("pool", c.Pool)
+		}
+		if err := client.task(vm.Node, "POST", fmt.Sprintf("/nodes/%s/qemu", url.PathEscape(vm.Node)), params, c.TaskTimeout); err != nil {
+			return halt(state, fmt.Errorf("Error creating the VM: %s", err))
+		}
+		s.vm = vm
+	}
+	state.Put("vm", vm)
+
+	ui.Say("Starting VM...")
+	if err := client.task(vm.Node, "POST", vm.path("/status/start"), nil, c.TaskTimeout); err != nil {
+		return halt(state, fmt.Errorf("Error starting the VM: %s", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+// cloudInitParams returns the configuration of the VM that lets Packer
+// connect to it with the credentials of the communicator. A cloud-init
+// drive is added if the VM has none.
+func (s *stepStartVM) cloudInitParams(client *client, c *Config) (url.Values, error) {
+	params := url.Values{"ipconfig0": {"ip=dhcp"}}
+
+	config, err := client.vmConfig(s.vm)
+	if err != nil {
+		return nil, err
+	}
+	if !hasCloudInitDrive(config) {
+		params.Set(cloudInitDrive, c.CloudInitStoragePool+":cloudinit")
+	}
+
+	if user := c.Comm.User(); user != "" {
+		params.Set("ciuser", user)
+	}
+	if password := c.Comm.Password(); password != "" {
+		params.Set("cipassword", password)
+	}
+	if c.Comm.Type == "ssh" && c.Comm.SSHPrivateKey != "" {
+		signer, err := communicator.SSHFileSigner(c.Comm.SSHPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(signer.PublicKey())))
+		// Proxmox expects the keys to be URL encoded, on top of the
+		// encoding of the form.
+		params.Set("sshkeys", strings.Replace(url.QueryEscape(key), "+", "%20", -1))
+	}
+
+	return params, nil
+}
+
+func (s *stepStartVM) Cleanup(state multistep.StateBag) {
+	if s.vm.ID == 0 {
+		return
+	}
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		// The VM became the template
+		return
+	}
+
+	client := state.Get("client").(*client)
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say(fmt.Sprintf("Deleting VM %d...", s.vm.ID))
+	if status, err := client.vmStatus(s.vm); err == nil && status == "running" {
+		if err := client.task(s.vm.Node, "POST", s.vm.path("/status/stop"), nil, c.TaskTimeout); err != nil {
+			ui.Error(fmt.Sprintf("Error stopping the VM: %s", err))
+		}
+	}
+	err := client.task(s.vm.Node, "DELETE", s.vm.path(""), url.Values{"purge": {"1"}}, c.TaskTimeout)
+	if err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error deleting VM %d. Please delete it manually: %s", s.vm.ID, err))
+	}
+}
+
+// cloudInitDrive is the slot of the cloud-init drive added by Packer.
+const cloudInitDrive = "ide0"
+
+// hasCloudInitDrive returns true if the configuration of a VM has a
+// cloud-init drive.
+func hasCloudInitDrive(config map[string]interface{}) bool {
+	for k, v := range config {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		for bus := range diskTypes {
+			if strings.HasPrefix(k, bus) && strings.Contains(s, "cloudinit") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vmParams returns the hardware of the VM that is configured.
+func vmParams(c *Config) url.Values {
+	params := make(url.Values)
+	if c.Memory != 0 {
+		params.Set("memory", strconv.Itoa(c.Memory))
+	}
+	if c.Cores != 0 {
+		params.Set("cores", strconv.Itoa(c.Cores))
+	}
+	if c.Sockets != 0 {
+		params.Set("sockets", strconv.Itoa(c.Sockets))
+	}
+	if c.CPUType != "" {
+		params.Set("cpu", c.CPUType)
+	}
+	if c.OS != "" {
+		params.Set("ostype", c.OS)
+	}
+	params.Set("agent", boolParam(*c.QemuAgent))
+
+	for i, nic := range c.NICs {
+		v := fmt.Sprintf("%s,bridge=%s", nic.Model, nic.Bridge)
+		if nic.VLANTag != "" {
+			v += ",tag=" + nic.VLANTag
+		}
+		params.Set(fmt.Sprintf("net%d", i), v)
+	}
+
+	slots := make(map[string]int)
+	if c.CloudInit {
+		// Keep the slot of the cloud-init drive free.
+		slots["ide"] = 1
+	}
+	for _, disk := range c.Disks {
+		slot := slots[disk.Type]
+		if disk.Type == "ide" && slot == 2 {
+			// ide2 holds the ISO
+			slot++
+		}
+		slots[disk.Type] = slot + 1
+
+		size, _ := disk.sizeGB()
+		v := fmt.Sprintf("%s:%d", disk.StoragePool, size)
+		if disk.Format != "" {
+			v += ",format=" + disk.Format
+		}
+		params.Set(fmt.Sprintf("%s%d", disk.Type, slot), v)
+	}
+
+	return params
+}
+
+func boolParam(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func halt(state multistep.StateBag, err error) multistep.StepAction {
+	state.Put("error", err)
+	state.Get("ui").(packer.Ui).Error(err.Error())
+	return multistep.ActionHalt
+}
@@ -0,0 +1,51 @@
+package proxmox
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestVMParams(t *testing.T) {
+	agent := true
+	c := &Config{
+		Memory:    1024,
+		Cores:     2,
+		Sockets:   1,
+		CPUType:   "host",
+		OS:        "l26",
+		QemuAgent: &agent,
+		CloudInit: true,
+		NICs:      []nicConfig{{Model: "virtio", Bridge: "vmbr1", VLANTag: "10"}},
+		Disks: []diskConfig{
+			{Type: "scsi", StoragePool: "local-lvm", Size: "32G"},
+			{Type: "ide", StoragePool: "local", Size: "8", Format: "qcow2"},
+			{Type: "ide", StoragePool: "local", Size: "8G"},
+		},
+	}
+
+	want := url.Values{
+		"memory":  {"1024"},
+		"cores":   {"2"},
+		"sockets": {"1"},
+		"cpu":     {"host"},
+		"ostype":  {"l26"},
+		"agent":   {"1"},
+		"net0":    {"virtio,bridge=vmbr1,tag=10"},
+		"scsi0":   {"local-lvm:32"},
+		"ide1":    {"local:8,format=qcow2"},
+		"ide3":    {"local:8"},
+	}
+	if got := vmParams(c); !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad params: %#v", got)
+	}
+}
+
+func TestHasCloudInitDrive(t *testing.T) {
+	if hasCloudInitDrive(map[string]interface{}{"ide2": "local:iso/debian.iso,media=cdrom", "memory": 512}) {
+		t.Fatal("should have no cloud-init drive")
+	}
+	if !hasCloudInitDrive(map[string]interface{}{"ide0": "local-lvm:vm-100-cloudinit,media=cdrom"}) {
+		t.Fatal("should have a cloud-init drive")
+	}
+}
@@ -0,0 +1,136 @@
+package proxmox
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/multistep"
+)
+
+type bootCommandTemplateData struct {
+	HTTPIP     string
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+	Name       string
+}
+
+// stepTypeBootCommand waits for boot_wait and types the boot command with
+// the sendkey API of Proxmox.
+//
+// Uses:
+//
+//	http_port uint
+//	vm vmRef
+type stepTypeBootCommand struct{}
+
+func (s *stepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*client)
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	vm := state.Get("vm").(vmRef)
+
+	if len(c.BootCommand) == 0 {
+		return multistep.ActionContinue
+	}
+
+	if c.BootWait > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", c.BootWait))
+		for deadline := time.Now().Add(c.BootWait); time.Now().Before(deadline); {
+			if _, ok := state.GetOk(multistep.StateCancelled); ok {
+				return multistep.ActionHalt
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	httpIP, err := hostIP(c)
+	if err != nil {
+		return halt(state, fmt.Errorf("Error finding the IP address of the HTTP server: %s", err))
+	}
+	common.SetHTTPIP(httpIP)
+	httpScheme, httpToken := common.HTTPServerFromState(state)
+	ctx := c.ctx
+	ctx.Data = &bootCommandTemplateData{
+		HTTPIP:     httpIP,
+		HTTPPort:   state.Get("http_port").(uint),
+		HTTPScheme: httpScheme,
+		HTTPToken:  httpToken,
+		Name:       c.VMName,
+	}
+
+	keyInterval := c.BootConfig.KeyInterval
+	if keyInterval <= 0 {
+		keyInterval = common.PackerKeyDefault
+		if delay, err := time.ParseDuration(os.Getenv(common.PackerKeyEnv)); err == nil {
+			keyInterval = delay
+		}
+	}
+	typeKeys := func(keys string) error {
+		for _, key := range qemuKeys(keys) {
+			if err := client.sendKey(vm, key); err != nil {
+				return err
+			}
+			time.Sleep(keyInterval)
+		}
+		return nil
+	}
+
+	ui.Say("Typing the boot command...")
+	for _, command := range c.BootCommand {
+		command, err := interpolate.Render(command, &ctx)
+		if err != nil {
+			return halt(state, fmt.Errorf("Error preparing boot command: %s", err))
+		}
+
+		// Proxmox has no API to capture the screen of the VM, so
+		// <waitFor> can't be used.
+		if err := c.BootConfig.Run(state, command, typeKeys, nil); err != nil {
+			if err == bootcommand.ErrCancelled {
+				return multistep.ActionHalt
+			}
+			return halt(state, fmt.Errorf("Error sending boot command: %s", err))
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (*stepTypeBootCommand) Cleanup(multistep.StateBag) {}
+
+// hostIP returns the IP address the HTTP server is reachable at from the
+// VM: the bind address of the server if it is set, otherwise the address
+// Packer reaches the Proxmox host from.
+func hostIP(c *Config) (string, error) {
+	if c.HTTPAddress != "" && c.HTTPAddress != "0.0.0.0" {
+		return c.HTTPAddress, nil
+	}
+
+	u, err := url.Parse(c.ProxmoxURL)
+	if err != nil {
+		return "", err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "8006")
+	}
+
+	// Nothing is sent over UDP, this only picks the local address.
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.String()
+	log.Printf("[DEBUG] (proxmox) Using %s as the IP address of the HTTP server", ip)
+	return ip, nil
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
@@ -78,11 +79,12 @@ type Builder struct {
 }
 
 type Config struct {
-	common.PackerConfig `mapstructure:",squash"`
-	common.HTTPConfig   `mapstructure:",squash"`
-	common.ISOConfig    `mapstructure:",squash"`
-	Comm                communicator.Config `mapstructure:",squash"`
-	common.FloppyConfig `mapstructure:",squash"`
+	common.PackerConfig    `mapstructure:",squash"`
+	common.HTTPConfig      `mapstructure:",squash"`
+	common.ISOConfig       `mapstructure:",squash"`
+	Comm                   communicator.Config `mapstructure:",squash"`
+	common.FloppyConfig    `mapstructure:",squash"`
+	bootcommand.BootConfig `mapstructure:",squash"`
 
 	ISOSkipCache      bool       `mapstructure:"iso_skip_cache"`
 	Accelerator       string     `mapstructure:"accelerator"`
@@ -110,6 +112,16 @@ type Config struct {
 	VNCPortMax        uint       `mapstructure:"vnc_port_max"`
 	VMName            string     `mapstructure:"vm_name"`
 
+	// SharedFolder is a directory of the host exposed to the VM with 9p
+	// or virtio-fs, and mounted on SharedFolderMountPath for the
+	// provisioners.
+	SharedFolder             string `mapstructure:"shared_folder"`
+	SharedFolderDriver       string `mapstructure:"shared_folder_driver"`
+	SharedFolderTag          string `mapstructure:"shared_folder_tag"`
+	SharedFolderMountPath    string `mapstructure:"shared_folder_mount_path"`
+	SharedFolderMountCommand string `mapstructure:"shared_folder_mount_command"`
+	VirtiofsdBinary          string `mapstructure:"virtiofsd_binary"`
+
 	// These are deprecated, but we keep them around for BC
 	// TODO(@mitchellh): remove
 	SSHWaitTimeout time.Duration `mapstructure:"ssh_wait_timeout"`
@@ -133,6 +145,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 			Exclude: []string{
 				"boot_command",
 				"qemuargs",
+				"shared_folder_mount_command",
 			},
 		},
 	}, raws...)
@@ -220,6 +233,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	errs = packer.MultiErrorAppend(errs, b.config.FloppyConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
 
 	if b.config.NetDevice == "" {
 		b.config.NetDevice = "virtio-net"
@@ -247,6 +261,8 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		errs = packer.MultiErrorAppend(errs, es...)
 	}
 
+	errs = packer.MultiErrorAppend(errs, b.config.prepareSharedFolder()...)
+
 	if !(b.config.Format == "qcow2" || b.config.Format == "raw") {
 		errs = packer.MultiErrorAppend(
 			errs, errors.New("invalid format, only 'qcow2' or 'raw' are allowed"))
@@ -350,7 +366,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			ChecksumType: b.config.ISOChecksumType,
 			Description:  "ISO",
 			Extension:    b.config.TargetExtension,
+			Proxy:        b.config.ISODownloadProxy,
 			ResultKey:    "iso_path",
+			Segments:     b.config.ISODownloadSegments,
 			TargetPath:   b.config.TargetPath,
 			Url:          b.config.ISOUrls,
 		},
@@ -367,14 +385,22 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
+			TempDir:     b.config.PackerTempDir,
 		},
 		new(stepCreateDisk),
 		new(stepCopyDisk),
 		new(stepResizeDisk),
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 	)
 
@@ -384,10 +410,20 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		)
 	}
 
+	if b.config.SharedFolder != "" && b.config.SharedFolderDriver == "virtio-fs" {
+		steps = append(steps,
+			new(stepStartVirtiofsd),
+		)
+	}
+
 	steps = append(steps,
 		new(stepConfigureVNC),
 		steprun,
 		&stepBootWait{},
+		&bootcommand.StepFailureScreenshot{
+			Path:       fmt.Sprintf("packer-%s-failure.png", b.config.PackerBuildName),
+			Screenshot: vncScreenshot,
+		},
 		&stepTypeBootCommand{},
 	)
 
@@ -403,6 +439,12 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		)
 	}
 
+	if b.config.SharedFolder != "" {
+		steps = append(steps,
+			new(stepMountSharedFolder),
+		)
+	}
+
 	steps = append(steps,
 		new(common.StepProvision),
 	)
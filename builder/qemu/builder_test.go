@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
 )
 
 var testPem = `
@@ -565,3 +567,152 @@ func TestBuilderPrepare_QemuArgs(t *testing.T) {
 		t.Fatalf("bad: %#v", b.config.QemuArgs)
 	}
 }
+
+func TestBuilderPrepare_SharedFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		Config  map[string]interface{}
+		Err     bool
+		Driver  string
+		Path    string
+		Command string
+	}{
+		{
+			Config:  map[string]interface{}{"shared_folder": dir},
+			Driver:  "9p",
+			Path:    "/mnt/packer",
+			Command: sharedFolderMountCommands["9p"],
+		},
+		{
+			Config: map[string]interface{}{
+				"shared_folder":               dir,
+				"shared_folder_driver":        "virtio-fs",
+				"shared_folder_mount_path":    "/payload",
+				"shared_folder_mount_command": "sudo mount -t virtiofs {{.Tag}} {{.Path}}",
+			},
+			Driver:  "virtio-fs",
+			Path:    "/payload",
+			Command: "sudo mount -t virtiofs {{.Tag}} {{.Path}}",
+		},
+		{
+			Config: map[string]interface{}{
+				"shared_folder":            dir,
+				"shared_folder_driver":     "virtio-fs",
+				"communicator":             "winrm",
+				"winrm_username":           "packer",
+				"shared_folder_mount_path": `Z:\`,
+			},
+			Driver: "virtio-fs",
+			Path:   `Z:\`,
+		},
+		{
+			Config: map[string]interface{}{
+				"shared_folder":  dir,
+				"communicator":   "winrm",
+				"winrm_username": "packer",
+			},
+			Err: true,
+		},
+		{
+			Config: map[string]interface{}{"shared_folder": dir, "shared_folder_driver": "nfs"},
+			Err:    true,
+		},
+		{
+			Config: map[string]interface{}{"shared_folder": filepath.Join(dir, "missing")},
+			Err:    true,
+		},
+		{
+			Config: map[string]interface{}{"shared_folder": dir, "communicator": "none"},
+			Err:    true,
+		},
+	}
+
+	for i, tc := range cases {
+		config := testConfig()
+		for k, v := range tc.Config {
+			config[k] = v
+		}
+
+		var b Builder
+		_, err := b.Prepare(config)
+		if tc.Err {
+			if err == nil {
+				t.Fatalf("%d: should have error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: should not have error: %s", i, err)
+		}
+
+		if b.config.SharedFolderDriver != tc.Driver {
+			t.Fatalf("%d: bad driver: %s", i, b.config.SharedFolderDriver)
+		}
+		if b.config.SharedFolderMountPath != tc.Path {
+			t.Fatalf("%d: bad mount path: %s", i, b.config.SharedFolderMountPath)
+		}
+		if b.config.SharedFolderMountCommand != tc.Command {
+			t.Fatalf("%d: bad mount command: %s", i, b.config.SharedFolderMountCommand)
+		}
+		if b.config.SharedFolderTag != "packer" {
+			t.Fatalf("%d: bad tag: %s", i, b.config.SharedFolderTag)
+		}
+	}
+}
+
+func TestSharedFolderArgs(t *testing.T) {
+	config := &Config{
+		SharedFolder:       "/srv/pay,load",
+		SharedFolderDriver: "9p",
+		SharedFolderTag:    "packer",
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("virtiofsd_socket", "/tmp/virtiofsd.sock")
+
+	args := map[string][]string{"-device": {"virtio-net,netdev=user.0"}}
+	sharedFolderArgs(args, config, state)
+	expected := map[string][]string{
+		"-device": {
+			"virtio-net,netdev=user.0",
+			"virtio-9p-pci,fsdev=packer-shared-folder,mount_tag=packer",
+		},
+		"-fsdev": {"local,id=packer-shared-folder,path=/srv/pay,,load,security_model=none"},
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	config.SharedFolderDriver = "virtio-fs"
+	args = map[string][]string{"-m": {"2048"}}
+	sharedFolderArgs(args, config, state)
+	expected = map[string][]string{
+		"-m":       {"2048"},
+		"-chardev": {"socket,id=packer-shared-folder,path=/tmp/virtiofsd.sock"},
+		"-device":  {"vhost-user-fs-pci,chardev=packer-shared-folder,tag=packer"},
+		"-object":  {"memory-backend-memfd,id=packer-shared-folder-memory,size=2048M,share=on"},
+		"-numa":    {"node,memdev=packer-shared-folder-memory"},
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestMemorySize(t *testing.T) {
+	cases := map[string]string{
+		"512":              "512M",
+		"2G":               "2G",
+		"size=4G,slots=2":  "4G",
+		"1024M,maxmem=8G":  "1024M",
+		"slots=2,size=768": "768M",
+	}
+	for m, expected := range cases {
+		if size := memorySize(m); size != expected {
+			t.Fatalf("%s: bad: %s", m, size)
+		}
+	}
+}
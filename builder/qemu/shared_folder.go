@@ -0,0 +1,131 @@
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/multistep"
+)
+
+// sharedFolderMountCommands are the default commands mounting the shared
+// folder in Linux guests, by driver.
+var sharedFolderMountCommands = map[string]string{
+	"9p":        "mkdir -p {{.Path}} && mount -t 9p -o trans=virtio,version=9p2000.L,msize=262144 {{.Tag}} {{.Path}}",
+	"virtio-fs": "mkdir -p {{.Path}} && mount -t virtiofs {{.Tag}} {{.Path}}",
+}
+
+// sharedFolderID is the id of the QEMU objects of the shared folder.
+const sharedFolderID = "packer-shared-folder"
+
+func (c *Config) prepareSharedFolder() []error {
+	if c.SharedFolder == "" {
+		return nil
+	}
+
+	var errs []error
+
+	if c.SharedFolderDriver == "" {
+		c.SharedFolderDriver = "9p"
+	}
+	if c.SharedFolderTag == "" {
+		c.SharedFolderTag = "packer"
+	}
+	if c.VirtiofsdBinary == "" {
+		c.VirtiofsdBinary = "virtiofsd"
+	}
+
+	mountCommand, ok := sharedFolderMountCommands[c.SharedFolderDriver]
+	if !ok {
+		errs = append(errs, errors.New("shared_folder_driver must be '9p' or 'virtio-fs'"))
+	}
+
+	if path, err := filepath.Abs(c.SharedFolder); err != nil {
+		errs = append(errs, fmt.Errorf("Error finding shared_folder: %s", err))
+	} else if info, err := os.Stat(path); err != nil {
+		errs = append(errs, fmt.Errorf("shared_folder is invalid: %s", err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("shared_folder '%s' isn't a directory", c.SharedFolder))
+	} else {
+		c.SharedFolder = path
+	}
+
+	switch c.Comm.Type {
+	case "none":
+		errs = append(errs, errors.New("shared_folder can't be used without a communicator"))
+	case "winrm":
+		// Windows mounts virtio-fs folders by itself, on a drive letter
+		if c.SharedFolderMountPath == "" {
+			errs = append(errs, errors.New(
+				"shared_folder_mount_path must be set with the winrm communicator"))
+		}
+	default:
+		if c.SharedFolderMountPath == "" {
+			c.SharedFolderMountPath = "/mnt/packer"
+		}
+		if c.SharedFolderMountCommand == "" {
+			c.SharedFolderMountCommand = mountCommand
+		}
+	}
+
+	return errs
+}
+
+// sharedFolderArgs adds the arguments exposing the shared folder to the
+// VM to the arguments of QEMU. They are added after the qemuargs are
+// applied so that overriding -device doesn't remove the shared folder.
+func sharedFolderArgs(args map[string][]string, config *Config, state multistep.StateBag) {
+	switch config.SharedFolderDriver {
+	case "9p":
+		args["-fsdev"] = append(args["-fsdev"], fmt.Sprintf(
+			"local,id=%s,path=%s,security_model=none",
+			sharedFolderID, qemuEscape(config.SharedFolder)))
+		args["-device"] = append(args["-device"], fmt.Sprintf(
+			"virtio-9p-pci,fsdev=%s,mount_tag=%s", sharedFolderID, config.SharedFolderTag))
+	case "virtio-fs":
+		// vhost-user devices need the memory of the VM to be shared
+		// with virtiofsd.
+		memory := "512M"
+		if m := args["-m"]; len(m) > 0 {
+			memory = memorySize(m[0])
+		}
+		args["-chardev"] = append(args["-chardev"], fmt.Sprintf(
+			"socket,id=%s,path=%s",
+			sharedFolderID, qemuEscape(state.Get("virtiofsd_socket").(string))))
+		args["-device"] = append(args["-device"], fmt.Sprintf(
+			"vhost-user-fs-pci,chardev=%s,tag=%s", sharedFolderID, config.SharedFolderTag))
+		args["-object"] = append(args["-object"], fmt.Sprintf(
+			"memory-backend-memfd,id=%s-memory,size=%s,share=on", sharedFolderID, memory))
+		args["-numa"] = append(args["-numa"], fmt.Sprintf(
+			"node,memdev=%s-memory", sharedFolderID))
+	}
+}
+
+// memorySize returns the size of the memory of the VM given the value of
+// the -m option, like "2G" for "size=2G,slots=2". Sizes without a suffix
+// are in megabytes for -m but in bytes for memory backends.
+func memorySize(m string) string {
+	size := m
+	for _, opt := range strings.Split(m, ",") {
+		if strings.HasPrefix(opt, "size=") {
+			size = strings.TrimPrefix(opt, "size=")
+			break
+		}
+		if !strings.Contains(opt, "=") {
+			size = opt
+			break
+		}
+	}
+
+	if strings.TrimLeft(size, "0123456789") == "" {
+		size += "M"
+	}
+	return size
+}
+
+// qemuEscape escapes the commas of a value of a QEMU option.
+func qemuEscape(v string) string {
+	return strings.Replace(v, ",", ",,", -1)
+}
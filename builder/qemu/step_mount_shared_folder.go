@@ -0,0 +1,76 @@
+package qemu
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/multistep"
+)
+
+type sharedFolderMountTemplateData struct {
+	Path string
+	Tag  string
+}
+
+// stepMountSharedFolder runs the command mounting the shared folder in the
+// VM, and makes its path available to the provisioners.
+//
+// Uses:
+//
+//	communicator packer.Communicator
+type stepMountSharedFolder struct{}
+
+func (s *stepMountSharedFolder) Run(state multistep.StateBag) multistep.StepAction {
+	comm := state.Get("communicator").(packer.Communicator)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	if config.SharedFolderMountCommand != "" {
+		ctx := config.ctx
+		ctx.Data = &sharedFolderMountTemplateData{
+			Path: config.SharedFolderMountPath,
+			Tag:  config.SharedFolderTag,
+		}
+		command, err := interpolate.Render(config.SharedFolderMountCommand, &ctx)
+		if err != nil {
+			err := fmt.Errorf("Error preparing shared folder mount command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		ui.Say(fmt.Sprintf("Mounting the shared folder on %s...", config.SharedFolderMountPath))
+		log.Printf("Executing shared folder mount command: %s", command)
+		cmd := &packer.RemoteCmd{Command: command}
+		if err := cmd.StartWithUi(comm, ui); err != nil {
+			err := fmt.Errorf("Error mounting the shared folder: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if cmd.ExitStatus != 0 {
+			err := fmt.Errorf(
+				"Shared folder mount command exited with non-zero exit status: %d",
+				cmd.ExitStatus)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	if err := common.SetSharedFolderPath(config.SharedFolderMountPath); err != nil {
+		err := fmt.Errorf("Error saving the path of the shared folder: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepMountSharedFolder) Cleanup(state multistep.StateBag) {
+	common.RemoveSharedFolderPath()
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/packer/common/consoles"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
@@ -16,6 +17,9 @@ import (
 type stepRun struct {
 	BootDrive string
 	Message   string
+
+	// recorded is the build the console was recorded for, if any.
+	recorded string
 }
 
 type qemuArgsTemplateData struct {
@@ -46,6 +50,17 @@ func (s *stepRun) Run(state multistep.StateBag) multistep.StepAction {
 		return multistep.ActionHalt
 	}
 
+	// The console of headless VMs can be looked up by the build name
+	if config := state.Get("config").(*Config); config.Headless {
+		consoles.Record(consoles.Console{
+			Build:   config.PackerBuildName,
+			Builder: "qemu",
+			URL: fmt.Sprintf("vnc://%s:%d",
+				state.Get("vnc_ip").(string), state.Get("vnc_port").(uint)),
+		})
+		s.recorded = config.PackerBuildName
+	}
+
 	return multistep.ActionContinue
 }
 
@@ -53,6 +68,10 @@ func (s *stepRun) Cleanup(state multistep.StateBag) {
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
 
+	if s.recorded != "" {
+		consoles.Forget(s.recorded)
+	}
+
 	if err := driver.Stop(); err != nil {
 		ui.Error(fmt.Sprintf("Error shutting down VM: %s", err))
 	}
@@ -216,6 +235,10 @@ func getCommandArgs(bootDrive string, state multistep.StateBag) ([]string, error
 		}
 	}
 
+	if config.SharedFolder != "" {
+		sharedFolderArgs(inArgs, config, state)
+	}
+
 	// Flatten to array of strings
 	outArgs := make([]string, 0)
 	for key, values := range inArgs {
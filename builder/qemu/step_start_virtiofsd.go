@@ -0,0 +1,105 @@
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// stepStartVirtiofsd starts the virtiofsd daemon serving the shared folder
+// to the VM with virtio-fs. It is stopped once the build is over.
+//
+// Produces:
+//
+//	virtiofsd_socket string - The socket QEMU connects to virtiofsd with.
+type stepStartVirtiofsd struct {
+	cmd *exec.Cmd
+	dir string
+}
+
+func (s *stepStartVirtiofsd) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say("Starting virtiofsd for the shared folder...")
+	socket, err := s.start(config)
+	if err != nil {
+		err := fmt.Errorf("Error starting virtiofsd: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("virtiofsd_socket", socket)
+	return multistep.ActionContinue
+}
+
+// start starts virtiofsd and waits for its socket, which QEMU fails to
+// start without.
+func (s *stepStartVirtiofsd) start(config *Config) (string, error) {
+	dir, err := ioutil.TempDir("", "packer-virtiofsd")
+	if err != nil {
+		return "", err
+	}
+	s.dir = dir
+	socket := filepath.Join(dir, "virtiofsd.sock")
+
+	args := []string{
+		"--socket-path=" + socket,
+		"--shared-dir=" + config.SharedFolder,
+	}
+	log.Printf("Executing %s: %#v", config.VirtiofsdBinary, args)
+	cmd := exec.Command(config.VirtiofsdBinary, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	s.cmd = cmd
+	go logReader("virtiofsd stdout", stdout)
+	go logReader("virtiofsd stderr", stderr)
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	timeout := time.After(30 * time.Second)
+	for {
+		if _, err := os.Stat(socket); err == nil {
+			return socket, nil
+		}
+
+		select {
+		case err := <-exited:
+			s.cmd = nil
+			return "", fmt.Errorf("virtiofsd exited before serving the shared folder: %v", err)
+		case <-timeout:
+			return "", errors.New("timeout waiting for the socket of virtiofsd")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (s *stepStartVirtiofsd) Cleanup(state multistep.StateBag) {
+	if s.cmd != nil {
+		// virtiofsd usually exits with QEMU, this is in case it didn't
+		if err := s.cmd.Process.Kill(); err != nil {
+			log.Printf("Error killing virtiofsd: %s", err)
+		}
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
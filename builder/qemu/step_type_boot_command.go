@@ -2,6 +2,7 @@ package qemu
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"net"
 	"regexp"
@@ -11,6 +12,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/go-vnc"
@@ -21,21 +23,25 @@ import (
 const KeyLeftShift uint32 = 0xFFE1
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort uint
-	Name     string
+	HTTPIP     string
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+	Name       string
 }
 
 // This step "types" the boot command into the VM over VNC.
 //
 // Uses:
-//   config *config
-//   http_port int
-//   ui     packer.Ui
-//   vnc_port uint
+//
+//	config *config
+//	http_port int
+//	ui     packer.Ui
+//	vnc_port uint
 //
 // Produces:
-//   <nothing>
+//
+//	<nothing>
 type stepTypeBootCommand struct{}
 
 func (s *stepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction {
@@ -61,7 +67,10 @@ func (s *stepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 	}
 	defer nc.Close()
 
-	c, err := vnc.Client(nc, &vnc.ClientConfig{Exclusive: false})
+	// Screen updates are read from messages while waiting for text on the
+	// screen.
+	messages := make(chan vnc.ServerMessage, 16)
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Exclusive: false, ServerMessageCh: messages})
 	if err != nil {
 		err := fmt.Errorf("Error handshaking with VNC: %s", err)
 		state.Put("error", err)
@@ -75,12 +84,21 @@ func (s *stepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 	hostIP := "10.0.2.2"
 	common.SetHTTPIP(hostIP)
 	ctx := config.ctx
+	httpScheme, httpToken := common.HTTPServerFromState(state)
 	ctx.Data = &bootCommandTemplateData{
 		hostIP,
 		httpPort,
+		httpScheme,
+		httpToken,
 		config.VMName,
 	}
 
+	typeKeys := func(keys string) error {
+		vncSendString(c, keys, config.BootConfig.KeyInterval)
+		return nil
+	}
+	screenshot := bootcommand.VNCScreenshotter(c, messages)
+
 	ui.Say("Typing the boot command over VNC...")
 	for i, command := range config.BootCommand {
 		command, err := interpolate.Render(command, &ctx)
@@ -101,7 +119,15 @@ func (s *stepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 			pauseFn(multistep.DebugLocationAfterRun, fmt.Sprintf("boot_command[%d]: %s", i, command), state)
 		}
 
-		vncSendString(c, command)
+		if err := config.BootConfig.Run(state, command, typeKeys, screenshot); err != nil {
+			if err == bootcommand.ErrCancelled {
+				return multistep.ActionHalt
+			}
+			err := fmt.Errorf("Error sending boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	return multistep.ActionContinue
@@ -109,7 +135,13 @@ func (s *stepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 
 func (*stepTypeBootCommand) Cleanup(multistep.StateBag) {}
 
-func vncSendString(c *vnc.ClientConn, original string) {
+// vncScreenshot captures the screen of the VM over VNC.
+func vncScreenshot(state multistep.StateBag) (image.Image, error) {
+	vncPort := state.Get("vnc_port").(uint)
+	return bootcommand.VNCScreenshot(fmt.Sprintf("127.0.0.1:%d", vncPort), nil)
+}
+
+func vncSendString(c *vnc.ClientConn, original string, keyInterval time.Duration) {
 	// Scancodes reference: https://github.com/qemu/qemu/blob/master/ui/vnc_keysym.h
 	special := make(map[string]uint32)
 	special["<bs>"] = 0xFF08
@@ -151,10 +183,13 @@ func vncSendString(c *vnc.ClientConn, original string) {
 	waitRe := regexp.MustCompile(`^<wait([0-9hms]+)>`)
 
 	// We delay (default 100ms) between each key event to allow for CPU or
-	// network latency. See PackerKeyEnv for tuning.
-	keyInterval := common.PackerKeyDefault
-	if delay, err := time.ParseDuration(os.Getenv(common.PackerKeyEnv)); err == nil {
-		keyInterval = delay
+	// network latency. See PackerKeyEnv for tuning, boot_key_interval takes
+	// precedence.
+	if keyInterval <= 0 {
+		keyInterval = common.PackerKeyDefault
+		if delay, err := time.ParseDuration(os.Getenv(common.PackerKeyEnv)); err == nil {
+			keyInterval = delay
+		}
 	}
 
 	// TODO(mitchellh): Ripe for optimizations of some point, perhaps.
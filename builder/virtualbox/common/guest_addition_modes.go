@@ -1,5 +1,11 @@
 package common
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
 // These are the different valid mode values for "guest_additions_mode" which
 // determine how guest additions are delivered to the guest.
 const (
@@ -7,3 +13,32 @@ const (
 	GuestAdditionsModeAttach         = "attach"
 	GuestAdditionsModeUpload         = "upload"
 )
+
+var guestAdditionsVersionRe = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// ValidateGuestAdditions checks the guest additions options that aren't
+// specific to a builder.
+func ValidateGuestAdditions(mode, version, installCommand string) []error {
+	var errs []error
+
+	if version != "" && !guestAdditionsVersionRe.MatchString(version) {
+		errs = append(errs, fmt.Errorf(
+			"guest_additions_version must be a version like 5.2.8, got: %s", version))
+	}
+
+	if installCommand != "" && mode == GuestAdditionsModeDisable {
+		errs = append(errs, errors.New(
+			"guest_additions_install_command can't be used when guest additions are disabled"))
+	}
+
+	return errs
+}
+
+// guestAdditionsVersion returns the version of the guest additions to use:
+// the pinned version if any, otherwise the version of VirtualBox.
+func guestAdditionsVersion(driver Driver, pinned string) (string, error) {
+	if pinned != "" {
+		return pinned, nil
+	}
+	return driver.Version()
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/common/resources"
+)
+
+// ResourceVM is the kind of the temporary virtual machines the VirtualBox
+// builders track, see the resources package.
+const ResourceVM = "vm"
+
+// TempResource returns the record of a temporary virtual machine.
+func TempResource(vmName string) resources.Resource {
+	return resources.Resource{
+		Builder: "virtualbox",
+		Kind:    ResourceVM,
+		ID:      vmName,
+	}
+}
+
+// CleanupResource stops and deletes a virtual machine left behind by a
+// build.
+func CleanupResource(r resources.Resource) error {
+	if r.Kind != ResourceVM {
+		return fmt.Errorf("Unknown resource kind: %s", r.Kind)
+	}
+
+	driver, err := NewDriver()
+	if err != nil {
+		return err
+	}
+
+	running, err := driver.IsRunning(r.ID)
+	if err != nil {
+		return err
+	}
+	if running {
+		if err := driver.Stop(r.ID); err != nil {
+			return err
+		}
+	}
+	return driver.Delete(r.ID)
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// This step attaches the CD created from cd_files and cd_content to the
+// virtual machine, as the secondary device of the secondary IDE channel.
+//
+// Uses:
+//   cd_path string
+//   driver Driver
+//   ui packer.Ui
+//   vmName string
+//
+// Produces:
+//   cd_attached bool
+type StepAttachCD struct {
+	cdPath string
+}
+
+func (s *StepAttachCD) Run(state multistep.StateBag) multistep.StepAction {
+	// Determine if we even have a CD to attach
+	cdPathRaw, ok := state.GetOk("cd_path")
+	if !ok {
+		log.Println("No CD disk, not attaching.")
+		return multistep.ActionContinue
+	}
+	cdPath := cdPathRaw.(string)
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	vmName := state.Get("vmName").(string)
+
+	ui.Say("Attaching CD disk...")
+	command := []string{
+		"storageattach", vmName,
+		"--storagectl", "IDE Controller",
+		"--port", "1",
+		"--device", "1",
+		"--type", "dvddrive",
+		"--medium", cdPath,
+	}
+	if err := driver.VBoxManage(command...); err != nil {
+		err := fmt.Errorf("Error attaching CD: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Track the path so that we can unregister it from VirtualBox later
+	s.cdPath = cdPath
+	state.Put("cd_attached", true)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepAttachCD) Cleanup(state multistep.StateBag) {
+	if s.cdPath == "" {
+		return
+	}
+
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+
+	command := []string{
+		"storageattach", vmName,
+		"--storagectl", "IDE Controller",
+		"--port", "1",
+		"--device", "1",
+		"--medium", "none",
+	}
+
+	// This will probably fail since StepRemoveDevices does this as well.
+	if err := driver.VBoxManage(command...); err != nil {
+		log.Printf("Error unregistering CD: %s", err)
+	}
+}
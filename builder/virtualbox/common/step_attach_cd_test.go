@@ -0,0 +1,60 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepAttachCD_impl(t *testing.T) {
+	var _ multistep.Step = new(StepAttachCD)
+}
+
+func TestStepAttachCD(t *testing.T) {
+	state := testState(t)
+	step := new(StepAttachCD)
+
+	state.Put("cd_path", "/tmp/packer.iso")
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+	if _, ok := state.GetOk("cd_attached"); !ok {
+		t.Fatal("should be attached")
+	}
+
+	if len(driver.VBoxManageCalls) != 1 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+	if call := driver.VBoxManageCalls[0]; call[0] != "storageattach" || call[11] != "/tmp/packer.iso" {
+		t.Fatalf("bad: %#v", call)
+	}
+
+	// Test the cleanup
+	step.Cleanup(state)
+	if driver.VBoxManageCalls[1][9] != "none" {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls[1])
+	}
+}
+
+func TestStepAttachCD_noCD(t *testing.T) {
+	state := testState(t)
+	step := new(StepAttachCD)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if len(driver.VBoxManageCalls) > 0 {
+		t.Fatal("should not call vboxmanage")
+	}
+}
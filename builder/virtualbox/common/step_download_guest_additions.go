@@ -24,8 +24,8 @@ type guestAdditionsUrlTemplate struct {
 	Version string
 }
 
-// This step uploads a file containing the VirtualBox version, which
-// can be useful for various provisioning reasons.
+// This step downloads the guest additions ISO, or finds the one
+// installed with VirtualBox.
 //
 // Produces:
 //   guest_additions_path string - Path to the guest additions.
@@ -34,6 +34,10 @@ type StepDownloadGuestAdditions struct {
 	GuestAdditionsURL    string
 	GuestAdditionsSHA256 string
 	Ctx                  interpolate.Context
+
+	// GuestAdditionsVersion pins the version of the guest additions
+	// instead of using the version of VirtualBox.
+	GuestAdditionsVersion string
 }
 
 func (s *StepDownloadGuestAdditions) Run(state multistep.StateBag) multistep.StepAction {
@@ -54,7 +58,15 @@ func (s *StepDownloadGuestAdditions) Run(state multistep.StateBag) multistep.Ste
 		return multistep.ActionHalt
 	}
 
-	if newVersion, ok := additionsVersionMap[version]; ok {
+	// The ISO installed with VirtualBox can only be used if it is the
+	// pinned version
+	useInstalled := true
+	if s.GuestAdditionsVersion != "" && s.GuestAdditionsVersion != version {
+		log.Printf("Using pinned guest additions version %s with VirtualBox %s",
+			s.GuestAdditionsVersion, version)
+		version = s.GuestAdditionsVersion
+		useInstalled = false
+	} else if newVersion, ok := additionsVersionMap[version]; ok {
 		log.Printf("Rewriting guest additions version: %s to %s", version, newVersion)
 		version = newVersion
 	}
@@ -81,7 +93,11 @@ func (s *StepDownloadGuestAdditions) Run(state multistep.StateBag) multistep.Ste
 			return multistep.ActionHalt
 		}
 	} else {
-		url, err = driver.Iso()
+		if useInstalled {
+			url, err = driver.Iso()
+		} else {
+			err = fmt.Errorf("VirtualBox doesn't come with guest additions %s", version)
+		}
 
 		if err == nil {
 			checksumType = "none"
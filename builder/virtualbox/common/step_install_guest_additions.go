@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/multistep"
+)
+
+type guestAdditionsInstallTemplate struct {
+	Path    string
+	Version string
+}
+
+// This step runs the command installing the guest additions from the ISO
+// attached to or uploaded to the VM, so that they are installed without
+// a provisioner.
+//
+// Uses:
+//
+//	communicator packer.Communicator
+//	driver Driver
+//	ui packer.Ui
+type StepInstallGuestAdditions struct {
+	Command               string
+	GuestAdditionsMode    string
+	GuestAdditionsPath    string
+	GuestAdditionsVersion string
+	Ctx                   interpolate.Context
+}
+
+func (s *StepInstallGuestAdditions) Run(state multistep.StateBag) multistep.StepAction {
+	comm := state.Get("communicator").(packer.Communicator)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	if s.Command == "" || s.GuestAdditionsMode == GuestAdditionsModeDisable {
+		log.Println("No guest additions install command, not installing them.")
+		return multistep.ActionContinue
+	}
+
+	version, err := guestAdditionsVersion(driver, s.GuestAdditionsVersion)
+	if err != nil {
+		state.Put("error", fmt.Errorf("Error reading version for guest additions install: %s", err))
+		return multistep.ActionHalt
+	}
+
+	// The path is only meaningful if the ISO was uploaded
+	s.Ctx.Data = &guestAdditionsPathTemplate{
+		Version: version,
+	}
+	path := ""
+	if s.GuestAdditionsMode == GuestAdditionsModeUpload {
+		path, err = interpolate.Render(s.GuestAdditionsPath, &s.Ctx)
+		if err != nil {
+			err := fmt.Errorf("Error preparing guest additions path: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	s.Ctx.Data = &guestAdditionsInstallTemplate{
+		Path:    path,
+		Version: version,
+	}
+	command, err := interpolate.Render(s.Command, &s.Ctx)
+	if err != nil {
+		err := fmt.Errorf("Error preparing guest additions install command: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Installing VirtualBox guest additions %s...", version))
+	log.Printf("Executing guest additions install command: %s", command)
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.StartWithUi(comm, ui); err != nil {
+		err := fmt.Errorf("Error installing guest additions: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if cmd.ExitStatus != 0 {
+		err := fmt.Errorf(
+			"Guest additions install command exited with non-zero exit status: %d",
+			cmd.ExitStatus)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepInstallGuestAdditions) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepInstallGuestAdditions_impl(t *testing.T) {
+	var _ multistep.Step = new(StepInstallGuestAdditions)
+}
+
+func TestStepInstallGuestAdditions(t *testing.T) {
+	cases := []struct {
+		Mode     string
+		Version  string
+		Expected string
+	}{
+		{GuestAdditionsModeUpload, "", "install VBoxGuestAdditions_5.2.8.iso 5.2.8"},
+		{GuestAdditionsModeUpload, "5.1.30", "install VBoxGuestAdditions_5.1.30.iso 5.1.30"},
+		{GuestAdditionsModeAttach, "", "install  5.2.8"},
+	}
+
+	for _, tc := range cases {
+		state := testState(t)
+		comm := new(packer.MockCommunicator)
+		state.Put("communicator", comm)
+		state.Get("driver").(*DriverMock).VersionResult = "5.2.8"
+
+		step := &StepInstallGuestAdditions{
+			Command:               "install {{.Path}} {{.Version}}",
+			GuestAdditionsMode:    tc.Mode,
+			GuestAdditionsPath:    "VBoxGuestAdditions_{{.Version}}.iso",
+			GuestAdditionsVersion: tc.Version,
+		}
+		if action := step.Run(state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v", action)
+		}
+		if _, ok := state.GetOk("error"); ok {
+			t.Fatal("should NOT have error")
+		}
+		if comm.StartCmd.Command != tc.Expected {
+			t.Fatalf("bad: %s", comm.StartCmd.Command)
+		}
+	}
+}
+
+func TestStepInstallGuestAdditions_failure(t *testing.T) {
+	state := testState(t)
+	comm := &packer.MockCommunicator{StartExitStatus: 1}
+	state.Put("communicator", comm)
+
+	step := &StepInstallGuestAdditions{
+		Command:            "install",
+		GuestAdditionsMode: GuestAdditionsModeAttach,
+	}
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepInstallGuestAdditions_noCommand(t *testing.T) {
+	state := testState(t)
+	comm := new(packer.MockCommunicator)
+	state.Put("communicator", comm)
+
+	step := &StepInstallGuestAdditions{GuestAdditionsMode: GuestAdditionsModeUpload}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if comm.StartCalled {
+		t.Fatal("should not run a command")
+	}
+}
@@ -112,3 +112,28 @@ func TestStepRemoveDevices_floppyPath(t *testing.T) {
 		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
 	}
 }
+
+func TestStepRemoveDevices_cdAttached(t *testing.T) {
+	state := testState(t)
+	step := new(StepRemoveDevices)
+
+	state.Put("cd_attached", true)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	if len(driver.VBoxManageCalls) != 1 {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+	if driver.VBoxManageCalls[0][3] != "IDE Controller" || driver.VBoxManageCalls[0][9] != "none" {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+}
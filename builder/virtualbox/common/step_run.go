@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/hashicorp/packer/common/consoles"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
 )
@@ -20,7 +21,12 @@ type StepRun struct {
 	BootWait time.Duration
 	Headless bool
 
-	vmName string
+	// BuildName is the name of the build the console of headless VMs is
+	// recorded for.
+	BuildName string
+
+	vmName   string
+	recorded bool
 }
 
 func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
@@ -42,6 +48,12 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 				"The VM will be run headless, without a GUI. If you want to\n"+
 					"view the screen of the VM, connect via VRDP without a password to\n"+
 					"rdp://%s:%d", vrdpIp, vrdpPort))
+			consoles.Record(consoles.Console{
+				Build:   s.BuildName,
+				Builder: "virtualbox",
+				URL:     fmt.Sprintf("rdp://%s:%d", vrdpIp, vrdpPort),
+			})
+			s.recorded = true
 		} else {
 			ui.Message("The VM will be run headless, without a GUI, as configured.\n" +
 				"If the run isn't succeeding as you expect, please enable the GUI\n" +
@@ -79,6 +91,10 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 }
 
 func (s *StepRun) Cleanup(state multistep.StateBag) {
+	if s.recorded {
+		consoles.Forget(s.BuildName)
+	}
+
 	if s.vmName == "" {
 		return
 	}
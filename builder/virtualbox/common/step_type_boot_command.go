@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"strings"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
@@ -17,9 +19,11 @@ import (
 const KeyLeftShift uint32 = 0xFFE1
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort uint
-	Name     string
+	HTTPIP     string
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+	Name       string
 }
 
 // This step "types" the boot command into the VM over VNC.
@@ -34,6 +38,7 @@ type bootCommandTemplateData struct {
 //   <nothing>
 type StepTypeBootCommand struct {
 	BootCommand []string
+	BootConfig  bootcommand.BootConfig
 	VMName      string
 	Ctx         interpolate.Context
 }
@@ -52,23 +57,17 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 
 	hostIP := "10.0.2.2"
 	common.SetHTTPIP(hostIP)
+	httpScheme, httpToken := common.HTTPServerFromState(state)
 	s.Ctx.Data = &bootCommandTemplateData{
 		hostIP,
 		httpPort,
+		httpScheme,
+		httpToken,
 		s.VMName,
 	}
 
-	ui.Say("Typing the boot command...")
-	for i, command := range s.BootCommand {
-		command, err := interpolate.Render(command, &s.Ctx)
-		if err != nil {
-			err := fmt.Errorf("Error preparing boot command: %s", err)
-			state.Put("error", err)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
-		}
-
-		for _, code := range scancodes(command) {
+	typeKeys := func(keys string) error {
+		for _, code := range scancodes(keys) {
 			if code == "wait" {
 				time.Sleep(1 * time.Second)
 				continue
@@ -87,15 +86,37 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 			// Since typing is sometimes so slow, we check for an interrupt
 			// in between each character.
 			if _, ok := state.GetOk(multistep.StateCancelled); ok {
-				return multistep.ActionHalt
+				return bootcommand.ErrCancelled
 			}
 
 			if err := driver.VBoxManage("controlvm", vmName, "keyboardputscancode", code); err != nil {
-				err := fmt.Errorf("Error sending boot command: %s", err)
-				state.Put("error", err)
-				ui.Error(err.Error())
+				return err
+			}
+			time.Sleep(s.BootConfig.KeyInterval)
+		}
+		return nil
+	}
+
+	screenshot := screenshotter(driver, vmName)
+
+	ui.Say("Typing the boot command...")
+	for i, command := range s.BootCommand {
+		command, err := interpolate.Render(command, &s.Ctx)
+		if err != nil {
+			err := fmt.Errorf("Error preparing boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if err := s.BootConfig.Run(state, command, typeKeys, screenshot); err != nil {
+			if err == bootcommand.ErrCancelled {
 				return multistep.ActionHalt
 			}
+			err := fmt.Errorf("Error sending boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
 		}
 
 		if pauseFn != nil {
@@ -109,6 +130,19 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 
 func (*StepTypeBootCommand) Cleanup(multistep.StateBag) {}
 
+// Screenshot captures the screen of the VM of the build.
+func Screenshot(state multistep.StateBag) (image.Image, error) {
+	driver := state.Get("driver").(Driver)
+	vmName := state.Get("vmName").(string)
+	return screenshotter(driver, vmName)()
+}
+
+func screenshotter(driver Driver, vmName string) bootcommand.Screenshotter {
+	return bootcommand.PNGScreenshotter(func(path string) error {
+		return driver.VBoxManage("controlvm", vmName, "screenshotpng", path)
+	})
+}
+
 func scancodes(message string) []string {
 	// Scancodes reference: http://www.win.tue.nl/~aeb/linux/kbd/scancodes-1.html
 	//
@@ -16,9 +16,10 @@ type guestAdditionsPathTemplate struct {
 
 // This step uploads the guest additions ISO to the VM.
 type StepUploadGuestAdditions struct {
-	GuestAdditionsMode string
-	GuestAdditionsPath string
-	Ctx                interpolate.Context
+	GuestAdditionsMode    string
+	GuestAdditionsPath    string
+	GuestAdditionsVersion string
+	Ctx                   interpolate.Context
 }
 
 func (s *StepUploadGuestAdditions) Run(state multistep.StateBag) multistep.StepAction {
@@ -35,7 +36,7 @@ func (s *StepUploadGuestAdditions) Run(state multistep.StateBag) multistep.StepA
 	// Get the guest additions path since we're doing it
 	guestAdditionsPath := state.Get("guest_additions_path").(string)
 
-	version, err := driver.Version()
+	version, err := guestAdditionsVersion(driver, s.GuestAdditionsVersion)
 	if err != nil {
 		state.Put("error", fmt.Errorf("Error reading version for guest additions upload: %s", err))
 		return multistep.ActionHalt
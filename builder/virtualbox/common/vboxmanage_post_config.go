@@ -1,11 +1,19 @@
 package common
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/hashicorp/packer/template/interpolate"
 )
 
 type VBoxManagePostConfig struct {
 	VBoxManagePost [][]string `mapstructure:"vboxmanage_post"`
+
+	// The hardware of the exported VM, if it should differ from the
+	// hardware the VM was built with.
+	FinalCPUs   int `mapstructure:"final_cpus"`
+	FinalMemory int `mapstructure:"final_memory"`
 }
 
 func (c *VBoxManagePostConfig) Prepare(ctx *interpolate.Context) []error {
@@ -13,5 +21,26 @@ func (c *VBoxManagePostConfig) Prepare(ctx *interpolate.Context) []error {
 		c.VBoxManagePost = make([][]string, 0)
 	}
 
-	return nil
+	var errs []error
+	if c.FinalCPUs < 0 {
+		errs = append(errs, fmt.Errorf("final_cpus must not be negative"))
+	}
+	if c.FinalMemory < 0 {
+		errs = append(errs, fmt.Errorf("final_memory must not be negative"))
+	}
+
+	// The final hardware is set before the vboxmanage_post commands, which
+	// can still change it
+	modify := []string{"modifyvm", "{{.Name}}"}
+	if c.FinalCPUs > 0 {
+		modify = append(modify, "--cpus", strconv.Itoa(c.FinalCPUs))
+	}
+	if c.FinalMemory > 0 {
+		modify = append(modify, "--memory", strconv.Itoa(c.FinalMemory))
+	}
+	if len(errs) == 0 && len(modify) > 2 {
+		c.VBoxManagePost = append([][]string{modify}, c.VBoxManagePost...)
+	}
+
+	return errs
 }
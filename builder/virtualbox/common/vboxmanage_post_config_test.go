@@ -35,3 +35,30 @@ func TestVBoxManagePostConfigPrepare_VBoxManage(t *testing.T) {
 		t.Fatalf("bad: %#v", c.VBoxManagePost)
 	}
 }
+
+func TestVBoxManagePostConfigPrepare_FinalHardware(t *testing.T) {
+	c := new(VBoxManagePostConfig)
+	c.FinalCPUs = 1
+	c.FinalMemory = 1024
+	c.VBoxManagePost = [][]string{
+		{"modifyvm", "{{.Name}}", "--memory", "2048"},
+	}
+	errs := c.Prepare(testConfigTemplate(t))
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	expected := [][]string{
+		{"modifyvm", "{{.Name}}", "--cpus", "1", "--memory", "1024"},
+		{"modifyvm", "{{.Name}}", "--memory", "2048"},
+	}
+	if !reflect.DeepEqual(c.VBoxManagePost, expected) {
+		t.Fatalf("bad: %#v", c.VBoxManagePost)
+	}
+
+	c = new(VBoxManagePostConfig)
+	c.FinalMemory = -1
+	if errs := c.Prepare(testConfigTemplate(t)); len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
@@ -27,6 +28,8 @@ type Config struct {
 	common.HTTPConfig               `mapstructure:",squash"`
 	common.ISOConfig                `mapstructure:",squash"`
 	common.FloppyConfig             `mapstructure:",squash"`
+	common.CDConfig                 `mapstructure:",squash"`
+	bootcommand.BootConfig          `mapstructure:",squash"`
 	vboxcommon.ExportConfig         `mapstructure:",squash"`
 	vboxcommon.ExportOpts           `mapstructure:",squash"`
 	vboxcommon.OutputConfig         `mapstructure:",squash"`
@@ -43,6 +46,7 @@ type Config struct {
 	GuestAdditionsPath     string   `mapstructure:"guest_additions_path"`
 	GuestAdditionsSHA256   string   `mapstructure:"guest_additions_sha256"`
 	GuestAdditionsURL      string   `mapstructure:"guest_additions_url"`
+	GuestAdditionsVersion  string   `mapstructure:"guest_additions_version"`
 	GuestOSType            string   `mapstructure:"guest_os_type"`
 	HardDriveDiscard       bool     `mapstructure:"hard_drive_discard"`
 	HardDriveInterface     string   `mapstructure:"hard_drive_interface"`
@@ -53,6 +57,10 @@ type Config struct {
 	SkipExport             bool     `mapstructure:"skip_export"`
 	VMName                 string   `mapstructure:"vm_name"`
 
+	// GuestAdditionsInstallCommand installs the guest additions from the
+	// attached or uploaded ISO once Packer is connected.
+	GuestAdditionsInstallCommand string `mapstructure:"guest_additions_install_command"`
+
 	ctx interpolate.Context
 }
 
@@ -63,6 +71,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"guest_additions_install_command",
 				"guest_additions_path",
 				"guest_additions_url",
 				"vboxmanage",
@@ -85,6 +94,8 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.ExportConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.ExportOpts.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.FloppyConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.CDConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(
 		errs, b.config.OutputConfig.Prepare(&b.config.ctx, &b.config.PackerConfig)...)
 	errs = packer.MultiErrorAppend(errs, b.config.HTTPConfig.Prepare(&b.config.ctx)...)
@@ -166,6 +177,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		b.config.GuestAdditionsSHA256 = strings.ToLower(b.config.GuestAdditionsSHA256)
 	}
 
+	errs = packer.MultiErrorAppend(errs, vboxcommon.ValidateGuestAdditions(
+		b.config.GuestAdditionsMode, b.config.GuestAdditionsVersion,
+		b.config.GuestAdditionsInstallCommand)...)
+
 	// Warnings
 	if b.config.ShutdownCommand == "" {
 		warnings = append(warnings,
@@ -189,17 +204,20 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	steps := []multistep.Step{
 		&vboxcommon.StepDownloadGuestAdditions{
-			GuestAdditionsMode:   b.config.GuestAdditionsMode,
-			GuestAdditionsURL:    b.config.GuestAdditionsURL,
-			GuestAdditionsSHA256: b.config.GuestAdditionsSHA256,
-			Ctx:                  b.config.ctx,
+			GuestAdditionsMode:    b.config.GuestAdditionsMode,
+			GuestAdditionsURL:     b.config.GuestAdditionsURL,
+			GuestAdditionsSHA256:  b.config.GuestAdditionsSHA256,
+			GuestAdditionsVersion: b.config.GuestAdditionsVersion,
+			Ctx:                   b.config.ctx,
 		},
 		&common.StepDownload{
 			Checksum:     b.config.ISOChecksum,
 			ChecksumType: b.config.ISOChecksumType,
 			Description:  "ISO",
 			Extension:    b.config.TargetExtension,
+			Proxy:        b.config.ISODownloadProxy,
 			ResultKey:    "iso_path",
+			Segments:     b.config.ISODownloadSegments,
 			TargetPath:   b.config.TargetPath,
 			Url:          b.config.ISOUrls,
 		},
@@ -210,11 +228,25 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
+			TempDir:     b.config.PackerTempDir,
+		},
+		&common.StepCreateCD{
+			Files:   b.config.CDConfig.CDFiles,
+			Content: b.config.CDConfig.CDContent,
+			Label:   b.config.CDConfig.CDLabel,
+			TempDir: b.config.PackerTempDir,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 		new(vboxcommon.StepSuppressMessages),
 		new(stepCreateVM),
@@ -229,6 +261,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			VRDPPortMax:     b.config.VRDPPortMax,
 		},
 		new(vboxcommon.StepAttachFloppy),
+		new(vboxcommon.StepAttachCD),
 		&vboxcommon.StepForwardSSH{
 			CommConfig:     &b.config.SSHConfig.Comm,
 			HostPortMin:    b.config.SSHHostPortMin,
@@ -240,11 +273,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Ctx:      b.config.ctx,
 		},
 		&vboxcommon.StepRun{
-			BootWait: b.config.BootWait,
-			Headless: b.config.Headless,
+			BootWait:  b.config.BootWait,
+			Headless:  b.config.Headless,
+			BuildName: b.config.PackerBuildName,
+		},
+		&bootcommand.StepFailureScreenshot{
+			Path:       fmt.Sprintf("packer-%s-failure.png", b.config.PackerBuildName),
+			Screenshot: vboxcommon.Screenshot,
 		},
 		&vboxcommon.StepTypeBootCommand{
 			BootCommand: b.config.BootCommand,
+			BootConfig:  b.config.BootConfig,
 			VMName:      b.config.VMName,
 			Ctx:         b.config.ctx,
 		},
@@ -259,9 +298,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Path: *b.config.VBoxVersionFile,
 		},
 		&vboxcommon.StepUploadGuestAdditions{
-			GuestAdditionsMode: b.config.GuestAdditionsMode,
-			GuestAdditionsPath: b.config.GuestAdditionsPath,
-			Ctx:                b.config.ctx,
+			GuestAdditionsMode:    b.config.GuestAdditionsMode,
+			GuestAdditionsPath:    b.config.GuestAdditionsPath,
+			GuestAdditionsVersion: b.config.GuestAdditionsVersion,
+			Ctx:                   b.config.ctx,
+		},
+		&vboxcommon.StepInstallGuestAdditions{
+			Command:               b.config.GuestAdditionsInstallCommand,
+			GuestAdditionsMode:    b.config.GuestAdditionsMode,
+			GuestAdditionsPath:    b.config.GuestAdditionsPath,
+			GuestAdditionsVersion: b.config.GuestAdditionsVersion,
+			Ctx:                   b.config.ctx,
 		},
 		new(common.StepProvision),
 		&vboxcommon.StepShutdown{
@@ -288,6 +335,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state.Put("cache", cache)
 	state.Put("config", &b.config)
 	state.Put("debug", b.config.PackerDebug)
+	state.Put("guest_facts", packer.GuestFactsForOSType(b.config.GuestOSType))
 	state.Put("driver", driver)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
@@ -179,6 +179,51 @@ func TestBuilderPrepare_GuestAdditionsMode(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_GuestAdditionsVersion(t *testing.T) {
+	cases := map[string]bool{
+		"":        false,
+		"5.2.8":   false,
+		"5.2":     true,
+		"latest":  true,
+		"5.2.8 ":  true,
+		"10.0.12": false,
+	}
+
+	for version, fails := range cases {
+		config := testConfig()
+		config["guest_additions_version"] = version
+
+		var b Builder
+		_, err := b.Prepare(config)
+		if fails && err == nil {
+			t.Fatalf("%q: should error", version)
+		}
+		if !fails && err != nil {
+			t.Fatalf("%q: should not have error: %s", version, err)
+		}
+	}
+}
+
+func TestBuilderPrepare_GuestAdditionsInstallCommand(t *testing.T) {
+	config := testConfig()
+	config["guest_additions_mode"] = "attach"
+	config["guest_additions_install_command"] = "sh /media/cdrom/VBoxLinuxAdditions.run"
+
+	var b Builder
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.GuestAdditionsInstallCommand != "sh /media/cdrom/VBoxLinuxAdditions.run" {
+		t.Fatalf("bad: %s", b.config.GuestAdditionsInstallCommand)
+	}
+
+	config["guest_additions_mode"] = "disable"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestBuilderPrepare_GuestAdditionsPath(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -2,10 +2,12 @@ package iso
 
 import (
 	"fmt"
+	"time"
+
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/common/resources"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
-	"time"
 )
 
 // This step creates the actual virtual machine.
@@ -48,6 +50,7 @@ func (s *stepCreateVM) Run(state multistep.StateBag) multistep.StepAction {
 		// Set the VM name property on the first command
 		if s.vmName == "" {
 			s.vmName = name
+			resources.Track(vboxcommon.TempResource(name))
 		}
 	}
 
@@ -70,6 +73,7 @@ func (s *stepCreateVM) Cleanup(state multistep.StateBag) {
 	_, halted := state.GetOk(multistep.StateHalted)
 	if (config.KeepRegistered) && (!cancelled && !halted) {
 		ui.Say("Keeping virtual machine registered with VirtualBox host (keep_registered = true)")
+		resources.Release(vboxcommon.TempResource(s.vmName))
 		return
 	}
 
@@ -86,5 +90,7 @@ func (s *stepCreateVM) Cleanup(state multistep.StateBag) {
 
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error deleting virtual machine: %s", err))
+		return
 	}
+	resources.Release(vboxcommon.TempResource(s.vmName))
 }
@@ -7,6 +7,7 @@ import (
 
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
@@ -58,17 +59,32 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
+			TempDir:     b.config.PackerTempDir,
+		},
+		&common.StepCreateCD{
+			Files:   b.config.CDConfig.CDFiles,
+			Content: b.config.CDConfig.CDContent,
+			Label:   b.config.CDConfig.CDLabel,
+			TempDir: b.config.PackerTempDir,
 		},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 		&vboxcommon.StepDownloadGuestAdditions{
-			GuestAdditionsMode:   b.config.GuestAdditionsMode,
-			GuestAdditionsURL:    b.config.GuestAdditionsURL,
-			GuestAdditionsSHA256: b.config.GuestAdditionsSHA256,
-			Ctx:                  b.config.ctx,
+			GuestAdditionsMode:    b.config.GuestAdditionsMode,
+			GuestAdditionsURL:     b.config.GuestAdditionsURL,
+			GuestAdditionsSHA256:  b.config.GuestAdditionsSHA256,
+			GuestAdditionsVersion: b.config.GuestAdditionsVersion,
+			Ctx:                   b.config.ctx,
 		},
 		&common.StepDownload{
 			Checksum:     b.config.Checksum,
@@ -92,6 +108,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			VRDPPortMax:     b.config.VRDPPortMax,
 		},
 		new(vboxcommon.StepAttachFloppy),
+		new(vboxcommon.StepAttachCD),
 		&vboxcommon.StepForwardSSH{
 			CommConfig:     &b.config.SSHConfig.Comm,
 			HostPortMin:    b.config.SSHHostPortMin,
@@ -103,11 +120,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Ctx:      b.config.ctx,
 		},
 		&vboxcommon.StepRun{
-			BootWait: b.config.BootWait,
-			Headless: b.config.Headless,
+			BootWait:  b.config.BootWait,
+			Headless:  b.config.Headless,
+			BuildName: b.config.PackerBuildName,
+		},
+		&bootcommand.StepFailureScreenshot{
+			Path:       fmt.Sprintf("packer-%s-failure.png", b.config.PackerBuildName),
+			Screenshot: vboxcommon.Screenshot,
 		},
 		&vboxcommon.StepTypeBootCommand{
 			BootCommand: b.config.BootCommand,
+			BootConfig:  b.config.BootConfig,
 			VMName:      b.config.VMName,
 			Ctx:         b.config.ctx,
 		},
@@ -122,9 +145,17 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Path: *b.config.VBoxVersionFile,
 		},
 		&vboxcommon.StepUploadGuestAdditions{
-			GuestAdditionsMode: b.config.GuestAdditionsMode,
-			GuestAdditionsPath: b.config.GuestAdditionsPath,
-			Ctx:                b.config.ctx,
+			GuestAdditionsMode:    b.config.GuestAdditionsMode,
+			GuestAdditionsPath:    b.config.GuestAdditionsPath,
+			GuestAdditionsVersion: b.config.GuestAdditionsVersion,
+			Ctx:                   b.config.ctx,
+		},
+		&vboxcommon.StepInstallGuestAdditions{
+			Command:               b.config.GuestAdditionsInstallCommand,
+			GuestAdditionsMode:    b.config.GuestAdditionsMode,
+			GuestAdditionsPath:    b.config.GuestAdditionsPath,
+			GuestAdditionsVersion: b.config.GuestAdditionsVersion,
+			Ctx:                   b.config.ctx,
 		},
 		new(common.StepProvision),
 		&vboxcommon.StepShutdown{
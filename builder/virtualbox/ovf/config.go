@@ -6,6 +6,7 @@ import (
 
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
@@ -16,6 +17,8 @@ type Config struct {
 	common.PackerConfig             `mapstructure:",squash"`
 	common.HTTPConfig               `mapstructure:",squash"`
 	common.FloppyConfig             `mapstructure:",squash"`
+	common.CDConfig                 `mapstructure:",squash"`
+	bootcommand.BootConfig          `mapstructure:",squash"`
 	vboxcommon.ExportConfig         `mapstructure:",squash"`
 	vboxcommon.ExportOpts           `mapstructure:",squash"`
 	vboxcommon.OutputConfig         `mapstructure:",squash"`
@@ -26,19 +29,24 @@ type Config struct {
 	vboxcommon.VBoxManagePostConfig `mapstructure:",squash"`
 	vboxcommon.VBoxVersionConfig    `mapstructure:",squash"`
 
-	BootCommand          []string `mapstructure:"boot_command"`
-	Checksum             string   `mapstructure:"checksum"`
-	ChecksumType         string   `mapstructure:"checksum_type"`
-	GuestAdditionsMode   string   `mapstructure:"guest_additions_mode"`
-	GuestAdditionsPath   string   `mapstructure:"guest_additions_path"`
-	GuestAdditionsSHA256 string   `mapstructure:"guest_additions_sha256"`
-	GuestAdditionsURL    string   `mapstructure:"guest_additions_url"`
-	ImportFlags          []string `mapstructure:"import_flags"`
-	ImportOpts           string   `mapstructure:"import_opts"`
-	SourcePath           string   `mapstructure:"source_path"`
-	TargetPath           string   `mapstructure:"target_path"`
-	VMName               string   `mapstructure:"vm_name"`
-	SkipExport           bool     `mapstructure:"skip_export"`
+	BootCommand           []string `mapstructure:"boot_command"`
+	Checksum              string   `mapstructure:"checksum"`
+	ChecksumType          string   `mapstructure:"checksum_type"`
+	GuestAdditionsMode    string   `mapstructure:"guest_additions_mode"`
+	GuestAdditionsPath    string   `mapstructure:"guest_additions_path"`
+	GuestAdditionsSHA256  string   `mapstructure:"guest_additions_sha256"`
+	GuestAdditionsURL     string   `mapstructure:"guest_additions_url"`
+	GuestAdditionsVersion string   `mapstructure:"guest_additions_version"`
+	ImportFlags           []string `mapstructure:"import_flags"`
+	ImportOpts            string   `mapstructure:"import_opts"`
+	SourcePath            string   `mapstructure:"source_path"`
+	TargetPath            string   `mapstructure:"target_path"`
+	VMName                string   `mapstructure:"vm_name"`
+	SkipExport            bool     `mapstructure:"skip_export"`
+
+	// GuestAdditionsInstallCommand installs the guest additions from the
+	// attached or uploaded ISO once Packer is connected.
+	GuestAdditionsInstallCommand string `mapstructure:"guest_additions_install_command"`
 
 	ctx interpolate.Context
 }
@@ -51,6 +59,7 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"guest_additions_install_command",
 				"guest_additions_path",
 				"guest_additions_url",
 				"vboxmanage",
@@ -81,6 +90,8 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	errs = packer.MultiErrorAppend(errs, c.ExportConfig.Prepare(&c.ctx)...)
 	errs = packer.MultiErrorAppend(errs, c.ExportOpts.Prepare(&c.ctx)...)
 	errs = packer.MultiErrorAppend(errs, c.FloppyConfig.Prepare(&c.ctx)...)
+	errs = packer.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
+	errs = packer.MultiErrorAppend(errs, c.BootConfig.Prepare(&c.ctx)...)
 	errs = packer.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
 	errs = packer.MultiErrorAppend(errs, c.OutputConfig.Prepare(&c.ctx, &c.PackerConfig)...)
 	errs = packer.MultiErrorAppend(errs, c.RunConfig.Prepare(&c.ctx)...)
@@ -125,6 +136,9 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		c.GuestAdditionsSHA256 = strings.ToLower(c.GuestAdditionsSHA256)
 	}
 
+	errs = packer.MultiErrorAppend(errs, vboxcommon.ValidateGuestAdditions(
+		c.GuestAdditionsMode, c.GuestAdditionsVersion, c.GuestAdditionsInstallCommand)...)
+
 	// Warnings
 	var warnings []string
 	if c.ShutdownCommand == "" {
@@ -2,7 +2,9 @@ package ovf
 
 import (
 	"fmt"
+
 	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/common/resources"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
 )
@@ -29,6 +31,7 @@ func (s *StepImport) Run(state multistep.StateBag) multistep.StepAction {
 	}
 
 	s.vmName = s.Name
+	resources.Track(vboxcommon.TempResource(s.vmName))
 	state.Put("vmName", s.Name)
 	return multistep.ActionContinue
 }
@@ -44,5 +47,7 @@ func (s *StepImport) Cleanup(state multistep.StateBag) {
 	ui.Say("Unregistering and deleting imported VM...")
 	if err := driver.Delete(s.vmName); err != nil {
 		ui.Error(fmt.Sprintf("Error deleting VM: %s", err))
+		return
 	}
+	resources.Release(vboxcommon.TempResource(s.vmName))
 }
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// OVFConfig sets the OVF environment properties of the build VM, and the
+// vApp options and properties of the exported OVF or OVA.
+type OVFConfig struct {
+	OVFProperties       map[string]string `mapstructure:"ovf_properties"`
+	OVFExportProperties []string          `mapstructure:"ovf_export_properties"`
+	VAppProduct         string            `mapstructure:"vapp_product"`
+	VAppVendor          string            `mapstructure:"vapp_vendor"`
+	VAppVersion         string            `mapstructure:"vapp_version"`
+}
+
+func (c *OVFConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	for _, key := range c.OVFExportProperties {
+		if _, ok := c.OVFProperties[key]; !ok {
+			errs = append(errs, fmt.Errorf(
+				"ovf_export_properties: '%s' isn't in ovf_properties", key))
+		}
+	}
+
+	return errs
+}
+
+// HasProductSection returns whether a product section should be added to
+// the exported OVF.
+func (c *OVFConfig) HasProductSection() bool {
+	return len(c.OVFExportProperties) > 0 ||
+		c.VAppProduct != "" || c.VAppVendor != "" || c.VAppVersion != ""
+}
+
+// SetVMXData sets the OVF environment of the build VM in the given VMX
+// data, where VMware Tools reads it from, unless it is already set.
+func (c *OVFConfig) SetVMXData(data map[string]string) map[string]string {
+	if len(c.OVFProperties) == 0 {
+		return data
+	}
+	if data == nil {
+		data = make(map[string]string)
+	}
+	for k := range data {
+		if strings.EqualFold(k, "guestinfo.ovfEnv") {
+			return data
+		}
+	}
+
+	data["guestinfo.ovfEnv"] = c.OVFEnvironment()
+	return data
+}
+
+// OVFEnvironment returns the OVF environment document of the build VM.
+// It is on a single line and only uses single quotes, so that it can be
+// a VMX value.
+func (c *OVFConfig) OVFEnvironment() string {
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version='1.0' encoding='UTF-8'?>")
+	buf.WriteString("<Environment xmlns='http://schemas.dmtf.org/ovf/environment/1'" +
+		" xmlns:oe='http://schemas.dmtf.org/ovf/environment/1'" +
+		" xmlns:xsi='http://www.w3.org/2001/XMLSchema-instance' oe:id=''>")
+	buf.WriteString("<PropertySection>")
+	for _, k := range sortedKeys(c.OVFProperties) {
+		buf.WriteString(fmt.Sprintf("<Property oe:key='%s' oe:value='%s'/>",
+			xmlEscape(k), xmlEscape(c.OVFProperties[k])))
+	}
+	buf.WriteString("</PropertySection>")
+	buf.WriteString("</Environment>")
+	return buf.String()
+}
+
+// ProductSection returns the product section of the exported OVF, with
+// the vApp options and the exported properties.
+func (c *OVFConfig) ProductSection() string {
+	var buf bytes.Buffer
+	buf.WriteString("<ProductSection ovf:required=\"false\">\n")
+	buf.WriteString("      <Info>Information about the installed software</Info>\n")
+	for _, e := range []struct{ name, value string }{
+		{"Product", c.VAppProduct},
+		{"Vendor", c.VAppVendor},
+		{"Version", c.VAppVersion},
+	} {
+		if e.value != "" {
+			buf.WriteString(fmt.Sprintf("      <%s>%s</%s>\n", e.name, xmlEscape(e.value), e.name))
+		}
+	}
+	for _, k := range c.OVFExportProperties {
+		buf.WriteString(fmt.Sprintf(
+			"      <Property ovf:key=\"%s\" ovf:type=\"string\" ovf:userConfigurable=\"true\" ovf:value=\"%s\"/>\n",
+			xmlEscape(k), xmlEscape(c.OVFProperties[k])))
+	}
+	buf.WriteString("    </ProductSection>")
+	return buf.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// xmlEscape escapes a value of an XML attribute or element. Pipes are
+// escaped as well since VMX files use them to escape characters.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return strings.Replace(buf.String(), "|", "&#124;", -1)
+}
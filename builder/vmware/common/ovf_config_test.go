@@ -0,0 +1,78 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOVFConfigPrepare(t *testing.T) {
+	c := new(OVFConfig)
+	c.OVFProperties = map[string]string{"hostname": "appliance"}
+	c.OVFExportProperties = []string{"hostname"}
+	if errs := c.Prepare(testConfigTemplate(t)); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	c.OVFExportProperties = []string{"hostname", "ip"}
+	if errs := c.Prepare(testConfigTemplate(t)); len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestOVFConfigSetVMXData(t *testing.T) {
+	c := new(OVFConfig)
+	if data := c.SetVMXData(nil); data != nil {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	c.OVFProperties = map[string]string{
+		"hostname": "app|1",
+		"motd":     `say "hi" & <bye>`,
+	}
+	data := c.SetVMXData(nil)
+	env := data["guestinfo.ovfEnv"]
+	if strings.ContainsAny(env, "\"|\n") {
+		t.Fatalf("environment isn't a valid VMX value: %s", env)
+	}
+	expected := "<PropertySection>" +
+		"<Property oe:key='hostname' oe:value='app&#124;1'/>" +
+		"<Property oe:key='motd' oe:value='say &#34;hi&#34; &amp; &lt;bye&gt;'/>" +
+		"</PropertySection>"
+	if !strings.Contains(env, expected) {
+		t.Fatalf("bad: %s", env)
+	}
+
+	data = c.SetVMXData(map[string]string{"guestinfo.ovfenv": "custom"})
+	if len(data) != 1 || data["guestinfo.ovfenv"] != "custom" {
+		t.Fatalf("bad: %#v", data)
+	}
+}
+
+func TestOVFConfigProductSection(t *testing.T) {
+	c := new(OVFConfig)
+	if c.HasProductSection() {
+		t.Fatal("should not have a product section")
+	}
+
+	c.OVFProperties = map[string]string{"hostname": "appliance", "password": "secret"}
+	c.OVFExportProperties = []string{"hostname"}
+	c.VAppProduct = "Appliance"
+	c.VAppVersion = "1.0"
+	if !c.HasProductSection() {
+		t.Fatal("should have a product section")
+	}
+
+	section := c.ProductSection()
+	for _, s := range []string{
+		"<Product>Appliance</Product>",
+		"<Version>1.0</Version>",
+		`<Property ovf:key="hostname" ovf:type="string" ovf:userConfigurable="true" ovf:value="appliance"/>`,
+	} {
+		if !strings.Contains(section, s) {
+			t.Fatalf("missing %q: %s", s, section)
+		}
+	}
+	if strings.Contains(section, "Vendor") || strings.Contains(section, "password") {
+		t.Fatalf("bad: %s", section)
+	}
+}
@@ -12,7 +12,8 @@ import (
 )
 
 // This step configures a VMX by setting some default settings as well
-// as taking in custom data to set, attaching a floppy if it exists, etc.
+// as taking in custom data to set, attaching a floppy and a CD if they
+// exist, etc.
 //
 // Uses:
 //   vmx_path string
@@ -66,6 +67,15 @@ func (s *StepConfigureVMX) Run(state multistep.StateBag) multistep.StepAction {
 			vmxData["floppy0.filetype"] = "file"
 			vmxData["floppy0.filename"] = floppyPathRaw.(string)
 		}
+
+		// The CD goes on the secondary device of the secondary IDE
+		// channel, the primary one is the installation ISO.
+		if cdPathRaw, ok := state.GetOk("cd_path"); ok {
+			log.Println("CD path present, setting in VMX")
+			vmxData["ide1:1.present"] = "TRUE"
+			vmxData["ide1:1.filename"] = cdPathRaw.(string)
+			vmxData["ide1:1.devicetype"] = "cdrom-image"
+		}
 	}
 
 	if err := WriteVMX(vmxPath, vmxData); err != nil {
@@ -124,6 +124,47 @@ func TestStepConfigureVMX_floppyPath(t *testing.T) {
 
 }
 
+func TestStepConfigureVMX_cdPath(t *testing.T) {
+	state := testState(t)
+	step := new(StepConfigureVMX)
+
+	vmxPath := testVMXFile(t)
+	defer os.Remove(vmxPath)
+
+	state.Put("cd_path", "foo.iso")
+	state.Put("vmx_path", vmxPath)
+
+	// Test the run
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Test the resulting data
+	vmxContents, err := ioutil.ReadFile(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	vmxData := ParseVMX(string(vmxContents))
+
+	cases := []struct {
+		Key   string
+		Value string
+	}{
+		{"ide1:1.present", "TRUE"},
+		{"ide1:1.filename", "foo.iso"},
+		{"ide1:1.devicetype", "cdrom-image"},
+	}
+
+	for _, tc := range cases {
+		if vmxData[tc.Key] != tc.Value {
+			t.Fatalf("bad: %s %#v", tc.Key, vmxData[tc.Key])
+		}
+	}
+}
+
 func TestStepConfigureVMX_generatedAddresses(t *testing.T) {
 	state := testState(t)
 	step := new(StepConfigureVMX)
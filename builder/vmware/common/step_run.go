@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"github.com/hashicorp/packer/common/consoles"
 	"github.com/hashicorp/packer/packer"
 	"github.com/mitchellh/multistep"
 	"time"
@@ -21,8 +22,13 @@ type StepRun struct {
 	DurationBeforeStop time.Duration
 	Headless           bool
 
+	// BuildName is the name of the build the console of headless VMs is
+	// recorded for.
+	BuildName string
+
 	bootTime time.Time
 	vmxPath  string
+	recorded bool
 }
 
 func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
@@ -49,6 +55,13 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 				"The VM will be run headless, without a GUI. If you want to\n"+
 					"view the screen of the VM, connect via VNC with the password \"%s\" to\n"+
 					"vnc://%s:%d", vncPassword, vncIp, vncPort))
+			consoles.Record(consoles.Console{
+				Build:    s.BuildName,
+				Builder:  "vmware",
+				URL:      fmt.Sprintf("vnc://%s:%d", vncIp, vncPort),
+				Password: vncPassword,
+			})
+			s.recorded = true
 		} else {
 			ui.Message("The VM will be run headless, without a GUI, as configured.\n" +
 				"If the run isn't succeeding as you expect, please enable the GUI\n" +
@@ -85,6 +98,10 @@ func (s *StepRun) Run(state multistep.StateBag) multistep.StepAction {
 }
 
 func (s *StepRun) Cleanup(state multistep.StateBag) {
+	if s.recorded {
+		consoles.Forget(s.BuildName)
+	}
+
 	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
 
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/hashicorp/packer/common/consoles"
 	"github.com/mitchellh/multistep"
 )
 
@@ -80,3 +83,39 @@ func TestStepRun_cleanupRunning(t *testing.T) {
 		t.Fatal("stop should be called")
 	}
 }
+
+func TestStepRun_headlessConsole(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	os.Setenv(consoles.EnvConsolesDir, td)
+	defer os.Unsetenv(consoles.EnvConsolesDir)
+
+	state := testState(t)
+	step := &StepRun{Headless: true, BuildName: "vmware-iso"}
+
+	state.Put("vmx_path", "foo")
+	state.Put("vnc_ip", "127.0.0.1")
+	state.Put("vnc_port", uint(5901))
+	state.Put("vnc_password", "secret")
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	cs, err := consoles.Consoles()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(cs) != 1 || cs[0].Build != "vmware-iso" || cs[0].URL != "vnc://127.0.0.1:5901" || cs[0].Password != "secret" {
+		t.Fatalf("bad: %#v", cs)
+	}
+
+	// The console is forgotten once the VM stops
+	step.Cleanup(state)
+	if cs, _ := consoles.Consoles(); len(cs) != 0 {
+		t.Fatalf("bad: %#v", cs)
+	}
+}
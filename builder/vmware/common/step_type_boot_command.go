@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"net"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/go-vnc"
@@ -21,9 +23,11 @@ import (
 const KeyLeftShift uint32 = 0xFFE1
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort uint
-	Name     string
+	HTTPIP     string
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+	Name       string
 }
 
 // This step "types" the boot command into the VM over VNC.
@@ -37,6 +41,7 @@ type bootCommandTemplateData struct {
 //   <nothing>
 type StepTypeBootCommand struct {
 	BootCommand []string
+	BootConfig  bootcommand.BootConfig
 	VMName      string
 	Ctx         interpolate.Context
 }
@@ -66,15 +71,12 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 	}
 	defer nc.Close()
 
-	var auth []vnc.ClientAuth
+	auth := vncAuth(vncPassword)
 
-	if vncPassword != nil && len(vncPassword.(string)) > 0 {
-		auth = []vnc.ClientAuth{&vnc.PasswordAuth{Password: vncPassword.(string)}}
-	} else {
-		auth = []vnc.ClientAuth{new(vnc.ClientAuthNone)}
-	}
-
-	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, Exclusive: true})
+	// Screen updates are read from messages while waiting for text on the
+	// screen.
+	messages := make(chan vnc.ServerMessage, 16)
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, Exclusive: true, ServerMessageCh: messages})
 	if err != nil {
 		err := fmt.Errorf("Error handshaking with VNC: %s", err)
 		state.Put("error", err)
@@ -106,12 +108,21 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 	log.Printf("Host IP for the VMware machine: %s", hostIP)
 	common.SetHTTPIP(hostIP)
 
+	httpScheme, httpToken := common.HTTPServerFromState(state)
 	s.Ctx.Data = &bootCommandTemplateData{
 		hostIP,
 		httpPort,
+		httpScheme,
+		httpToken,
 		s.VMName,
 	}
 
+	typeKeys := func(keys string) error {
+		vncSendString(c, keys, s.BootConfig.KeyInterval)
+		return nil
+	}
+	screenshot := bootcommand.VNCScreenshotter(c, messages)
+
 	ui.Say("Typing the boot command over VNC...")
 	for i, command := range s.BootCommand {
 		command, err := interpolate.Render(command, &s.Ctx)
@@ -132,7 +143,15 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 			pauseFn(multistep.DebugLocationAfterRun, fmt.Sprintf("boot_command[%d]: %s", i, command), state)
 		}
 
-		vncSendString(c, command)
+		if err := s.BootConfig.Run(state, command, typeKeys, screenshot); err != nil {
+			if err == bootcommand.ErrCancelled {
+				return multistep.ActionHalt
+			}
+			err := fmt.Errorf("Error sending boot command: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	return multistep.ActionContinue
@@ -140,7 +159,22 @@ func (s *StepTypeBootCommand) Run(state multistep.StateBag) multistep.StepAction
 
 func (*StepTypeBootCommand) Cleanup(multistep.StateBag) {}
 
-func vncSendString(c *vnc.ClientConn, original string) {
+// Screenshot captures the screen of the VM of the build over VNC.
+func Screenshot(state multistep.StateBag) (image.Image, error) {
+	vncIp := state.Get("vnc_ip").(string)
+	vncPort := state.Get("vnc_port").(uint)
+	vncPassword := state.Get("vnc_password")
+	return bootcommand.VNCScreenshot(fmt.Sprintf("%s:%d", vncIp, vncPort), vncAuth(vncPassword))
+}
+
+func vncAuth(vncPassword interface{}) []vnc.ClientAuth {
+	if vncPassword != nil && len(vncPassword.(string)) > 0 {
+		return []vnc.ClientAuth{&vnc.PasswordAuth{Password: vncPassword.(string)}}
+	}
+	return []vnc.ClientAuth{new(vnc.ClientAuthNone)}
+}
+
+func vncSendString(c *vnc.ClientConn, original string, keyInterval time.Duration) {
 	// Scancodes reference: https://github.com/qemu/qemu/blob/master/ui/vnc_keysym.h
 	special := make(map[string]uint32)
 	special["<bs>"] = 0xFF08
@@ -181,10 +215,13 @@ func vncSendString(c *vnc.ClientConn, original string) {
 	shiftedChars := "~!@#$%^&*()_+{}|:\"<>?"
 
 	// We delay (default 100ms) between each key event to allow for CPU or
-	// network latency. See PackerKeyEnv for tuning.
-	keyInterval := common.PackerKeyDefault
-	if delay, err := time.ParseDuration(os.Getenv(common.PackerKeyEnv)); err == nil {
-		keyInterval = delay
+	// network latency. See PackerKeyEnv for tuning, boot_key_interval takes
+	// precedence.
+	if keyInterval <= 0 {
+		keyInterval = common.PackerKeyDefault
+		if delay, err := time.ParseDuration(os.Getenv(common.PackerKeyEnv)); err == nil {
+			keyInterval = delay
+		}
 	}
 
 	// TODO(mitchellh): Ripe for optimizations of some point, perhaps.
@@ -1,6 +1,9 @@
 package common
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/hashicorp/packer/template/interpolate"
 )
 
@@ -8,8 +11,38 @@ type VMXConfig struct {
 	VMXData           map[string]string `mapstructure:"vmx_data"`
 	VMXDataPost       map[string]string `mapstructure:"vmx_data_post"`
 	VMXRemoveEthernet bool              `mapstructure:"vmx_remove_ethernet_interfaces"`
+
+	// The hardware of the exported VM, if it should differ from the
+	// hardware the VM was built with.
+	FinalCPUs   int `mapstructure:"final_cpus"`
+	FinalMemory int `mapstructure:"final_memory"`
 }
 
 func (c *VMXConfig) Prepare(ctx *interpolate.Context) []error {
-	return nil
+	var errs []error
+	if c.FinalCPUs < 0 {
+		errs = append(errs, fmt.Errorf("final_cpus must not be negative"))
+	}
+	if c.FinalMemory < 0 {
+		errs = append(errs, fmt.Errorf("final_memory must not be negative"))
+	}
+
+	// The final hardware is set with the other data of the VMX after the
+	// build, which takes precedence
+	c.setPost("numvcpus", c.FinalCPUs)
+	c.setPost("memsize", c.FinalMemory)
+
+	return errs
+}
+
+func (c *VMXConfig) setPost(key string, value int) {
+	if value <= 0 {
+		return
+	}
+	if c.VMXDataPost == nil {
+		c.VMXDataPost = make(map[string]string)
+	}
+	if _, ok := c.VMXDataPost[key]; !ok {
+		c.VMXDataPost[key] = strconv.Itoa(value)
+	}
 }
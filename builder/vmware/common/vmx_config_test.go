@@ -1,6 +1,7 @@
 package common
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -20,3 +21,32 @@ func TestVMXConfigPrepare(t *testing.T) {
 		t.Fatal("should have two items in VMXData")
 	}
 }
+
+func TestVMXConfigPrepare_FinalHardware(t *testing.T) {
+	c := new(VMXConfig)
+	c.FinalCPUs = 2
+	c.FinalMemory = 1024
+	c.VMXDataPost = map[string]string{"memsize": "2048"}
+	if errs := c.Prepare(testConfigTemplate(t)); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	expected := map[string]string{"numvcpus": "2", "memsize": "2048"}
+	if !reflect.DeepEqual(c.VMXDataPost, expected) {
+		t.Fatalf("bad: %#v", c.VMXDataPost)
+	}
+
+	c = new(VMXConfig)
+	if errs := c.Prepare(testConfigTemplate(t)); len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+	if c.VMXDataPost != nil {
+		t.Fatalf("bad: %#v", c.VMXDataPost)
+	}
+
+	c = new(VMXConfig)
+	c.FinalCPUs = -1
+	if errs := c.Prepare(testConfigTemplate(t)); len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	vmwcommon "github.com/hashicorp/packer/builder/vmware/common"
 	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/bootcommand"
 	"github.com/hashicorp/packer/helper/communicator"
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
@@ -29,8 +30,11 @@ type Config struct {
 	common.HTTPConfig        `mapstructure:",squash"`
 	common.ISOConfig         `mapstructure:",squash"`
 	common.FloppyConfig      `mapstructure:",squash"`
+	common.CDConfig          `mapstructure:",squash"`
+	bootcommand.BootConfig   `mapstructure:",squash"`
 	vmwcommon.DriverConfig   `mapstructure:",squash"`
 	vmwcommon.OutputConfig   `mapstructure:",squash"`
+	vmwcommon.OVFConfig      `mapstructure:",squash"`
 	vmwcommon.RunConfig      `mapstructure:",squash"`
 	vmwcommon.ShutdownConfig `mapstructure:",squash"`
 	vmwcommon.SSHConfig      `mapstructure:",squash"`
@@ -99,7 +103,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	errs = packer.MultiErrorAppend(errs, b.config.SSHConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.ToolsConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.VMXConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.OVFConfig.Prepare(&b.config.ctx)...)
 	errs = packer.MultiErrorAppend(errs, b.config.FloppyConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.CDConfig.Prepare(&b.config.ctx)...)
+	errs = packer.MultiErrorAppend(errs, b.config.BootConfig.Prepare(&b.config.ctx)...)
 
 	if b.config.DiskName == "" {
 		b.config.DiskName = "disk"
@@ -172,6 +179,18 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	// The OVF environment is given to the build VM with the VMX
+	b.config.VMXData = b.config.OVFConfig.SetVMXData(b.config.VMXData)
+	if b.config.OVFConfig.HasProductSection() {
+		if b.config.RemoteType != "esx5" || !(b.config.Format == "ova" || b.config.Format == "ovf") {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("ovf_export_properties and the vapp options need remote_type 'esx5' and format 'ova' or 'ovf'"))
+		} else if b.config.SkipExport {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("ovf_export_properties and the vapp options can't be used with skip_export"))
+		}
+	}
+
 	// Warnings
 	if b.config.ShutdownCommand == "" {
 		warnings = append(warnings,
@@ -213,6 +232,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state.Put("cache", cache)
 	state.Put("config", &b.config)
 	state.Put("debug", b.config.PackerDebug)
+	state.Put("guest_facts", packer.GuestFactsForOSType(b.config.GuestOSType))
 	state.Put("dir", dir)
 	state.Put("driver", driver)
 	state.Put("hook", hook)
@@ -228,7 +248,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			ChecksumType: b.config.ISOChecksumType,
 			Description:  "ISO",
 			Extension:    b.config.TargetExtension,
+			Proxy:        b.config.ISODownloadProxy,
 			ResultKey:    "iso_path",
+			Segments:     b.config.ISODownloadSegments,
 			TargetPath:   b.config.TargetPath,
 			Url:          b.config.ISOUrls,
 		},
@@ -238,11 +260,22 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&common.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
 			Directories: b.config.FloppyConfig.FloppyDirectories,
+			TempDir:     b.config.PackerTempDir,
+		},
+		&common.StepCreateCD{
+			Files:   b.config.CDConfig.CDFiles,
+			Content: b.config.CDConfig.CDContent,
+			Label:   b.config.CDConfig.CDLabel,
+			TempDir: b.config.PackerTempDir,
 		},
 		&stepRemoteUpload{
 			Key:     "floppy_path",
 			Message: "Uploading Floppy to remote machine...",
 		},
+		&stepRemoteUpload{
+			Key:     "cd_path",
+			Message: "Uploading CD to remote machine...",
+		},
 		&stepRemoteUpload{
 			Key:     "iso_path",
 			Message: "Uploading ISO to remote machine...",
@@ -254,9 +287,16 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		},
 		&vmwcommon.StepSuppressMessages{},
 		&common.StepHTTPServer{
-			HTTPDir:     b.config.HTTPDir,
-			HTTPPortMin: b.config.HTTPPortMin,
-			HTTPPortMax: b.config.HTTPPortMax,
+			HTTPDir:            b.config.HTTPDir,
+			HTTPPortMin:        b.config.HTTPPortMin,
+			HTTPPortMax:        b.config.HTTPPortMax,
+			HTTPAddress:        b.config.HTTPAddress,
+			HTTPTLS:            b.config.HTTPTLS,
+			HTTPRequireToken:   b.config.HTTPRequireToken,
+			HTTPDisableListing: b.config.HTTPDisableListing,
+			HTTPTemplates:      b.config.HTTPTemplates,
+			HTTPUploadDir:      b.config.HTTPUploadDir,
+			Ctx:                b.config.ctx,
 		},
 		&vmwcommon.StepConfigureVNC{
 			VNCBindAddress:     b.config.VNCBindAddress,
@@ -271,9 +311,15 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			BootWait:           b.config.BootWait,
 			DurationBeforeStop: 5 * time.Second,
 			Headless:           b.config.Headless,
+			BuildName:          b.config.PackerBuildName,
+		},
+		&bootcommand.StepFailureScreenshot{
+			Path:       fmt.Sprintf("packer-%s-failure.png", b.config.PackerBuildName),
+			Screenshot: vmwcommon.Screenshot,
 		},
 		&vmwcommon.StepTypeBootCommand{
 			BootCommand: b.config.BootCommand,
+			BootConfig:  b.config.BootConfig,
 			VMName:      b.config.VMName,
 			Ctx:         b.config.ctx,
 		},
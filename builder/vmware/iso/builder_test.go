@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -169,6 +170,43 @@ func TestBuilderPrepare_Format(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_OVFProperties(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["ovf_properties"] = map[string]string{"hostname": "appliance"}
+	warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !strings.Contains(b.config.VMXData["guestinfo.ovfEnv"], "oe:key='hostname'") {
+		t.Fatalf("bad: %#v", b.config.VMXData)
+	}
+
+	// The exported properties need an OVF
+	config["ovf_export_properties"] = []string{"hostname"}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	config["remote_type"] = "esx5"
+	config["remote_host"] = "esxi"
+	config["format"] = "ova"
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	config["ovf_export_properties"] = []string{"ip"}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_InvalidKey(t *testing.T) {
 	var b Builder
 	config := testConfig()
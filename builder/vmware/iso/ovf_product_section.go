@@ -0,0 +1,98 @@
+package iso
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ovfTransport is the transport vSphere gives the OVF environment to the
+// VMs deployed from the OVF with, where VMware Tools reads it from.
+const ovfTransport = "com.vmware.guestInfo"
+
+// findOVFs returns the OVF files ovftool exported to a directory.
+func findOVFs(dir string) ([]string, error) {
+	var ovfs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".ovf") {
+			ovfs = append(ovfs, path)
+		}
+		return nil
+	})
+	return ovfs, err
+}
+
+// addProductSection adds a product section to the virtual system of an
+// OVF, sets the transport of its OVF environment, and updates the
+// checksum of the OVF in its manifest.
+func addProductSection(path, section string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ovf := string(contents)
+
+	i := strings.LastIndex(ovf, "</VirtualSystem>")
+	if i < 0 {
+		return fmt.Errorf("no virtual system in %s", path)
+	}
+	ovf = ovf[:i] + "  " + section + "\n  " + ovf[i:]
+
+	if !strings.Contains(ovf, "ovf:transport=") {
+		ovf = strings.Replace(ovf, "<VirtualHardwareSection",
+			fmt.Sprintf("<VirtualHardwareSection ovf:transport=\"%s\"", ovfTransport), 1)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(ovf), 0644); err != nil {
+		return err
+	}
+
+	manifest := strings.TrimSuffix(path, ".ovf") + ".mf"
+	if _, err := os.Stat(manifest); os.IsNotExist(err) {
+		return nil
+	}
+	return updateManifest(manifest, filepath.Base(path), []byte(ovf))
+}
+
+var manifestLineRe = regexp.MustCompile(`^(SHA1|SHA256|SHA512)\((.+)\)\s*=\s*[0-9a-fA-F]+$`)
+
+// updateManifest updates the checksum of a file in an OVF manifest, in
+// the algorithm the manifest already uses.
+func updateManifest(path, name string, contents []byte) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		matches := manifestLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil || matches[2] != name {
+			continue
+		}
+
+		var h hash.Hash
+		switch matches[1] {
+		case "SHA1":
+			h = sha1.New()
+		case "SHA256":
+			h = sha256.New()
+		case "SHA512":
+			h = sha512.New()
+		}
+		h.Write(contents)
+		lines[i] = fmt.Sprintf("%s(%s)= %x", matches[1], name, h.Sum(nil))
+	}
+
+	return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
@@ -0,0 +1,90 @@
+package iso
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <VirtualSystem ovf:id="packer">
+    <Info>A virtual machine</Info>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements</Info>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`
+
+func TestAddProductSection(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "packer")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(dir, "packer.ovf")
+	if err := ioutil.WriteFile(path, []byte(testOVF), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	manifest := "SHA256(packer.ovf)= 00\nSHA256(packer-disk1.vmdk)= 01\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "packer.mf"), []byte(manifest), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ovfs, err := findOVFs(td)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(ovfs) != 1 || ovfs[0] != path {
+		t.Fatalf("bad: %#v", ovfs)
+	}
+
+	if err := addProductSection(path, "<ProductSection/>"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ovf := string(contents)
+	if !strings.Contains(ovf, "<ProductSection/>\n  </VirtualSystem>") {
+		t.Fatalf("bad: %s", ovf)
+	}
+	if !strings.Contains(ovf, `<VirtualHardwareSection ovf:transport="com.vmware.guestInfo">`) {
+		t.Fatalf("bad: %s", ovf)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "packer.mf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := fmt.Sprintf("SHA256(packer.ovf)= %x\nSHA256(packer-disk1.vmdk)= 01\n", sha256.Sum256(contents))
+	if string(data) != expected {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+func TestAddProductSection_noVirtualSystem(t *testing.T) {
+	f, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.WriteString("<Envelope/>")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := addProductSection(f.Name(), "<ProductSection/>"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -3,6 +3,7 @@ package iso
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
@@ -19,7 +20,7 @@ type StepExport struct {
 	OutputDir  string
 }
 
-func (s *StepExport) generateArgs(c *Config, hidePassword bool) []string {
+func (s *StepExport) generateArgs(c *Config, format, outputDir string, hidePassword bool) []string {
 	password := url.QueryEscape(c.RemotePassword)
 	if hidePassword {
 		password = "****"
@@ -27,9 +28,9 @@ func (s *StepExport) generateArgs(c *Config, hidePassword bool) []string {
 	args := []string{
 		"--noSSLVerify=true",
 		"--skipManifestCheck",
-		"-tt=" + s.Format,
+		"-tt=" + format,
 		"vi://" + c.RemoteUser + ":" + password + "@" + c.RemoteHost + "/" + c.VMName,
-		s.OutputDir,
+		outputDir,
 	}
 	return append(c.OVFToolOptions, args...)
 }
@@ -70,10 +71,25 @@ func (s *StepExport) Run(state multistep.StateBag) multistep.StepAction {
 		os.MkdirAll(s.OutputDir, 0755)
 	}
 
+	// The product section is added to the OVF, which is then packaged in
+	// the OVA
+	format, outputDir := s.Format, s.OutputDir
+	if c.OVFConfig.HasProductSection() && s.Format == "ova" {
+		dir, err := ioutil.TempDir(s.OutputDir, "ovf")
+		if err != nil {
+			err := fmt.Errorf("Error creating temporary export directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		defer os.RemoveAll(dir)
+		format, outputDir = "ovf", dir
+	}
+
 	ui.Say("Exporting virtual machine...")
-	ui.Message(fmt.Sprintf("Executing: %s %s", ovftool, strings.Join(s.generateArgs(c, true), " ")))
+	ui.Message(fmt.Sprintf("Executing: %s %s", ovftool, strings.Join(s.generateArgs(c, format, outputDir, true), " ")))
 	var out bytes.Buffer
-	cmd := exec.Command(ovftool, s.generateArgs(c, false)...)
+	cmd := exec.Command(ovftool, s.generateArgs(c, format, outputDir, false)...)
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
 		err := fmt.Errorf("Error exporting virtual machine: %s\n%s\n", err, out.String())
@@ -84,6 +100,42 @@ func (s *StepExport) Run(state multistep.StateBag) multistep.StepAction {
 
 	ui.Message(fmt.Sprintf("%s", out.String()))
 
+	if !c.OVFConfig.HasProductSection() {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Adding the vApp options and OVF properties to the OVF...")
+	ovfs, err := findOVFs(outputDir)
+	if err == nil && len(ovfs) == 0 {
+		err = fmt.Errorf("no OVF in %s", outputDir)
+	}
+	for _, path := range ovfs {
+		if err != nil {
+			break
+		}
+		err = addProductSection(path, c.OVFConfig.ProductSection())
+	}
+	if err != nil {
+		err := fmt.Errorf("Error adding the vApp options to the OVF: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if s.Format == "ova" {
+		args := []string{"--skipManifestCheck", "-tt=ova", ovfs[0], s.OutputDir}
+		ui.Message(fmt.Sprintf("Executing: %s %s", ovftool, strings.Join(args, " ")))
+		var out bytes.Buffer
+		cmd := exec.Command(ovftool, args...)
+		cmd.Stdout = &out
+		if err := cmd.Run(); err != nil {
+			err := fmt.Errorf("Error packaging the OVA: %s\n%s\n", err, out.String())
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	return multistep.ActionContinue
 }
 
@@ -6,10 +6,15 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/packer/common/history"
+	"github.com/hashicorp/packer/common/resources"
 	"github.com/hashicorp/packer/helper/enumflag"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template"
@@ -20,16 +25,22 @@ type BuildCommand struct {
 }
 
 func (c BuildCommand) Run(args []string) int {
-	var cfgColor, cfgDebug, cfgForce, cfgParallel bool
-	var cfgOnError string
-	flags := c.Meta.FlagSet("build", FlagSetBuildFilter|FlagSetVars)
+	var cfgCollapse, cfgColor, cfgDebug, cfgForce, cfgParallel bool
+	var cfgOnError, cfgResultsFile, cfgResultsFormat string
+	var cfgOutputLimit int
+	flags := c.Meta.FlagSet("build", FlagSetBuildFilter|FlagSetVars|FlagSetPolicy|FlagSetStrict)
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.BoolVar(&cfgCollapse, "collapse", false, "")
 	flags.BoolVar(&cfgColor, "color", true, "")
 	flags.BoolVar(&cfgDebug, "debug", false, "")
 	flags.BoolVar(&cfgForce, "force", false, "")
 	flagOnError := enumflag.New(&cfgOnError, "cleanup", "abort", "ask")
 	flags.Var(flagOnError, "on-error", "")
+	flags.IntVar(&cfgOutputLimit, "output-limit", 20, "")
 	flags.BoolVar(&cfgParallel, "parallel", true, "")
+	flags.StringVar(&cfgResultsFile, "results-file", "", "")
+	flagResultsFormat := enumflag.New(&cfgResultsFormat, resultsFormatJUnit, resultsFormatTAP)
+	flags.Var(flagResultsFormat, "results-format", "")
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
@@ -40,6 +51,18 @@ func (c BuildCommand) Run(args []string) int {
 		return 1
 	}
 
+	if ui, ok := c.Ui.(*packer.BasicUi); ok {
+		ui.Collapse = cfgCollapse
+	}
+	if cfgOutputLimit < 0 {
+		c.Ui.Error("-output-limit must not be negative")
+		return 1
+	}
+	if cfgResultsFile != "" && cfgResultsFormat == "" {
+		c.Ui.Error("-results-file requires -results-format")
+		return 1
+	}
+
 	// Parse the template
 	var tpl *template.Template
 	var err error
@@ -56,8 +79,12 @@ func (c BuildCommand) Run(args []string) int {
 		return 1
 	}
 
-	// Get the builds we care about
-	buildNames := c.Meta.BuildNames(core)
+	// Get the builds we care about, after the builds they depend on
+	buildNames, err := core.OrderBuilds(c.Meta.BuildNames(core))
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
 	builds := make([]packer.Build, 0, len(buildNames))
 	for _, n := range buildNames {
 		b, err := core.Build(n)
@@ -71,10 +98,19 @@ func (c BuildCommand) Run(args []string) int {
 		builds = append(builds, b)
 	}
 
+	// Check the policies before anything is built
+	if err := c.Meta.CheckPolicies(core, buildNames); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
 	if cfgDebug {
 		c.Ui.Say("Debug mode enabled. Builds will not be parallelized.")
 	}
 
+	// Record the builds and their output in the history
+	record := startHistory(os.Getenv("PACKER_RUN_UUID"), "build", args[0], buildNames, core)
+
 	// Compile all the UIs for the builds
 	colors := [5]packer.UiColor{
 		packer.UiColorGreen,
@@ -101,6 +137,13 @@ func (c BuildCommand) Run(args []string) int {
 			}
 		}
 
+		// Each build has its own filter, so that a chatty build doesn't
+		// drop the output of the others
+		if cfgOutputLimit > 0 {
+			ui = &packer.FilteredUi{Ui: ui, Limit: cfgOutputLimit}
+		}
+		ui = &packer.RecordedUi{Ui: ui, Record: record.Output}
+
 		buildUis[b] = ui
 	}
 
@@ -108,29 +151,42 @@ func (c BuildCommand) Run(args []string) int {
 	log.Printf("Force build: %v", cfgForce)
 	log.Printf("On error: %v", cfgOnError)
 
-	// Set the debug and force mode and prepare all the builds
+	prepare := func(b packer.Build) error {
+		return prepareBuild(b, buildUis[b.Name()])
+	}
+
+	// Set the debug and force mode and prepare all the builds. The builds
+	// that use the artifacts of other builds are prepared once these
+	// are done.
 	for _, b := range builds {
-		log.Printf("Preparing build: %s", b.Name())
 		b.SetDebug(cfgDebug)
 		b.SetForce(cfgForce)
 		b.SetOnError(cfgOnError)
 
-		warnings, err := b.Prepare()
-		if err != nil {
+		if len(core.BuildDependencies(b.Name())) > 0 {
+			continue
+		}
+		if err := prepare(b); err != nil {
 			c.Ui.Error(err.Error())
+			record.Output(err.Error())
+			record.Finish(history.StateFailed)
 			return 1
 		}
-		if len(warnings) > 0 {
-			ui := buildUis[b.Name()]
-			ui.Say(fmt.Sprintf("Warnings for build '%s':\n", b.Name()))
-			for _, warning := range warnings {
-				ui.Say(fmt.Sprintf("* %s", warning))
-			}
-			ui.Say("")
-		}
 	}
 
+	// Record the run, so the temporary resources of its builds can be
+	// cleaned up with `packer cleanup` if it never gets to do it itself.
+	if err := resources.StartRun(); err != nil {
+		log.Printf("[WARN] Error recording the run: %s", err)
+	}
+	defer func() {
+		if err := resources.EndRun(); err != nil {
+			log.Printf("[WARN] Error removing the record of the run: %s", err)
+		}
+	}()
+
 	// Run all the builds in parallel and wait for them to complete
+	start := time.Now()
 	var interruptWg, wg sync.WaitGroup
 	interrupted := false
 	var artifacts = struct {
@@ -138,34 +194,118 @@ func (c BuildCommand) Run(args []string) int {
 		m map[string][]packer.Artifact
 	}{m: make(map[string][]packer.Artifact)}
 	errors := make(map[string]error)
+
+	// Handle interrupts in two stages. The first interrupt cancels the
+	// builds, which lets them clean up. The second one aborts them.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	forceCh := make(chan struct{})
+	go func() {
+		select {
+		case <-sigCh:
+		case <-doneCh:
+			return
+		}
+
+		interruptWg.Add(len(builds))
+		interrupted = true
+		c.Ui.Error("Interrupt received. Cancelling the builds and cleaning up, " +
+			"press Ctrl-C again to abort without cleaning up...")
+		for _, b := range builds {
+			go func(b packer.Build) {
+				defer interruptWg.Done()
+
+				log.Printf("Stopping build: %s", b.Name())
+				b.Cancel()
+				log.Printf("Build cancelled: %s", b.Name())
+			}(b)
+		}
+
+		select {
+		case <-sigCh:
+			close(forceCh)
+		case <-doneCh:
+		}
+	}()
+
+	// waitBuilds waits for the running builds, unless they are aborted
+	waitBuilds := func() bool {
+		waitCh := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(waitCh)
+		}()
+
+		select {
+		case <-waitCh:
+			return true
+		case <-forceCh:
+			c.Ui.Error("Aborted the builds without cleaning up. " +
+				"Resources they created may have been left behind.")
+			return false
+		}
+	}
+
+	// The channels of the builds are closed when they are done
+	doneChs := make(map[string]chan struct{})
+	for _, b := range builds {
+		doneChs[b.Name()] = make(chan struct{})
+	}
+
 	for _, b := range builds {
 		// Increment the waitgroup so we wait for this item to finish properly
 		wg.Add(1)
 
-		// Handle interrupts for this build
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt)
-		defer signal.Stop(sigCh)
-		go func(b packer.Build) {
-			<-sigCh
-			interruptWg.Add(1)
-			defer interruptWg.Done()
-			interrupted = true
-
-			log.Printf("Stopping build: %s", b.Name())
-			b.Cancel()
-			log.Printf("Build cancelled: %s", b.Name())
-		}(b)
-
 		// Run the build in a goroutine
 		go func(b packer.Build) {
 			defer wg.Done()
 
 			name := b.Name()
-			log.Printf("Starting build run: %s", name)
+			defer close(doneChs[name])
 			ui := buildUis[name]
+
+			if deps := core.BuildDependencies(name); len(deps) > 0 {
+				log.Printf("Build %s waiting for the builds it depends on: %s",
+					name, strings.Join(deps, ", "))
+				for _, dep := range deps {
+					<-doneChs[dep]
+				}
+				if interrupted {
+					return
+				}
+
+				buildArtifacts, err := dependencyArtifacts(deps, func(dep string) []packer.Artifact {
+					artifacts.RLock()
+					defer artifacts.RUnlock()
+					return artifacts.m[dep]
+				})
+				if err == nil {
+					b.SetBuildArtifacts(buildArtifacts)
+					err = prepare(b)
+				}
+				if err != nil {
+					ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
+					errors[name] = err
+					record.FinishBuild(name, history.StateFailed, nil, err)
+					return
+				}
+			}
+
+			log.Printf("Starting build run: %s", name)
+			record.StartBuild(name)
 			runArtifacts, err := b.Run(ui, c.Cache)
 
+			state := history.StateSucceeded
+			if err != nil && interrupted {
+				state = history.StateCancelled
+			} else if err != nil {
+				state = history.StateFailed
+			}
+			record.FinishBuild(name, state, runArtifacts, err)
+
 			if err != nil {
 				ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
 				errors[name] = err
@@ -179,12 +319,16 @@ func (c BuildCommand) Run(args []string) int {
 
 		if cfgDebug {
 			log.Printf("Debug enabled, so waiting for build to finish: %s", b.Name())
-			wg.Wait()
+			if !waitBuilds() {
+				return 1
+			}
 		}
 
 		if !cfgParallel {
 			log.Printf("Parallelization disabled, waiting for build to finish: %s", b.Name())
-			wg.Wait()
+			if !waitBuilds() {
+				return 1
+			}
 		}
 
 		if interrupted {
@@ -193,16 +337,19 @@ func (c BuildCommand) Run(args []string) int {
 		}
 	}
 
-	// Wait for both the builds to complete and the interrupt handler,
-	// if it is interrupted.
+	// Wait for the builds to complete, and if interrupted, for them to
+	// be cancelled.
 	log.Printf("Waiting on builds to complete...")
-	wg.Wait()
-
-	log.Printf("Builds completed. Waiting on interrupt barrier...")
-	interruptWg.Wait()
+	if !waitBuilds() {
+		return 1
+	}
 
 	if interrupted {
+		log.Printf("Builds completed. Waiting on interrupt barrier...")
+		interruptWg.Wait()
+
 		c.Ui.Say("Cleanly cancelled builds after being interrupted.")
+		record.Finish(history.StateCancelled)
 		return 1
 	}
 
@@ -271,14 +418,134 @@ func (c BuildCommand) Run(args []string) int {
 		c.Ui.Say("\n==> Builds finished but no artifacts were created.")
 	}
 
+	c.printTimings(builds)
+	if cfgResultsFormat != "" {
+		if err := writeResults(cfgResultsFormat, cfgResultsFile, builds, errors); err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to write the build results: %s", err))
+		}
+	}
+	if err := c.exportTrace(start, builds, errors); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to export the build trace: %s", err))
+	}
+
 	if len(errors) > 0 {
 		// If any errors occurred, exit with a non-zero exit status
+		record.Finish(history.StateFailed)
 		return 1
 	}
+	record.Finish(history.StateSucceeded)
 
 	return 0
 }
 
+// startHistory records a job in the history. Failing to record it doesn't
+// fail the builds, and the job isn't recorded.
+func startHistory(id, command, template string, builds []string, core *packer.Core) *history.Record {
+	if id == "" {
+		var err error
+		if id, err = uuid.GenerateUUID(); err != nil {
+			log.Printf("[WARN] Error recording the job in the history: %s", err)
+			return nil
+		}
+	}
+
+	if template != "" {
+		if abs, err := filepath.Abs(template); err == nil {
+			template = abs
+		}
+	}
+	record, err := history.Start(id, command, template, builds, core.Redact)
+	if err != nil {
+		log.Printf("[WARN] Error recording the job in the history: %s", err)
+		return nil
+	}
+	return record
+}
+
+// prepareBuild prepares a build and prints its warnings.
+func prepareBuild(b packer.Build, ui packer.Ui) error {
+	log.Printf("Preparing build: %s", b.Name())
+	warnings, err := b.Prepare()
+	if err != nil {
+		return err
+	}
+	if len(warnings) > 0 {
+		ui.Say(fmt.Sprintf("Warnings for build '%s':\n", b.Name()))
+		for _, warning := range warnings {
+			ui.Say(fmt.Sprintf("* %s", warning))
+		}
+		ui.Say("")
+	}
+	return nil
+}
+
+// dependencyArtifacts returns the ids of the artifacts of the builds a
+// build depends on, by build name. A build without artifact, because it
+// failed or only made files, is an error.
+func dependencyArtifacts(deps []string, artifacts func(string) []packer.Artifact) (map[string]string, error) {
+	result := make(map[string]string, len(deps))
+	for _, dep := range deps {
+		as := artifacts(dep)
+		if len(as) == 0 || as[0] == nil || as[0].Id() == "" {
+			return nil, fmt.Errorf("build '%s' didn't produce an artifact to use", dep)
+		}
+
+		result[dep] = as[0].Id()
+	}
+
+	return result, nil
+}
+
+// printTimings prints how long the parts of every build took, so slow
+// steps and provisioners are easy to spot.
+func (c BuildCommand) printTimings(builds []packer.Build) {
+	header := false
+	for _, b := range builds {
+		timings := b.Timings()
+		if len(timings) == 0 {
+			continue
+		}
+
+		if !header {
+			c.Ui.Say("\n==> Build timings:")
+			header = true
+		}
+
+		// Create a UI for the machine readable stuff to be targeted
+		ui := &packer.TargetedUI{
+			Target: b.Name(),
+			Ui:     c.Ui,
+		}
+		ui.Machine("timing-count", strconv.FormatInt(int64(len(timings)), 10))
+
+		var message bytes.Buffer
+		fmt.Fprintf(&message, "--> %s:", b.Name())
+		for i, t := range timings {
+			ui.Machine("timing",
+				strconv.FormatInt(int64(i), 10),
+				t.Type,
+				t.Name,
+				strconv.FormatInt(int64(t.Depth), 10),
+				strconv.FormatFloat(t.Duration.Seconds(), 'f', 3, 64),
+				t.Error)
+
+			fmt.Fprintf(&message, "\n    %10s  %s%s: %s",
+				formatTiming(t.Duration), strings.Repeat("  ", t.Depth), t.Type, t.Name)
+			if t.Error != "" {
+				fmt.Fprint(&message, " (failed)")
+			}
+		}
+		c.Ui.Say(message.String())
+	}
+}
+
+func formatTiming(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
 func (BuildCommand) Help() string {
 	helpText := `
 Usage: packer build [options] TEMPLATE
@@ -288,6 +555,7 @@ Usage: packer build [options] TEMPLATE
 
 Options:
 
+  -collapse                  Collapse the output of build steps in terminals
   -color=false               Disable color output (on by default)
   -debug                     Debug mode enabled for builds
   -except=foo,bar,baz        Build all builds other than these
@@ -295,9 +563,16 @@ Options:
   -force                     Force a build to continue if artifacts exist, deletes existing artifacts
   -machine-readable          Machine-readable output
   -on-error=[cleanup|abort|ask] If the build fails do: clean up (default), abort, or ask
+  -output-limit=20           Lines of output per second and build over which output is dropped, 0 to disable
   -parallel=false            Disable parallelization (on by default)
+  -policy=path               Rego policies or OPA server URL to check the template against
+  -results-format=[junit|tap] Write the results of builds and provisioners for CI systems
+  -results-file=path         File of the results, packer-results.xml or .tap by default
+  -strict                    Reject undeclared variables, deprecated and reserved keys
   -var 'key=value'           Variable for templates, can be used multiple times.
   -var-file=path             JSON file containing user variables.
+  -var-dir=path              Directory of layered var files, see 'packer vars'.
+  -profile=name              Var file of the -var-dir to layer, e.g. prod.
 `
 
 	return strings.TrimSpace(helpText)
@@ -0,0 +1,249 @@
+package command
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// The formats of the results file of builds.
+const (
+	resultsFormatJUnit = "junit"
+	resultsFormatTAP   = "tap"
+)
+
+// resultsFiles are the default paths of the results file by format.
+var resultsFiles = map[string]string{
+	resultsFormatJUnit: "packer-results.xml",
+	resultsFormatTAP:   "packer-results.tap",
+}
+
+// buildResult is the outcome of a build and of its provisioners, as
+// reported in the results file.
+type buildResult struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Error    string
+
+	// Skipped is set for builds that never ran, e.g. because the run was
+	// aborted.
+	Skipped bool
+
+	Provisioners []packer.Timing
+}
+
+// newBuildResults returns the results of the builds from their timings.
+func newBuildResults(builds []packer.Build, errors map[string]error) []buildResult {
+	results := make([]buildResult, 0, len(builds))
+	for _, b := range builds {
+		r := buildResult{Name: b.Name()}
+		if err, ok := errors[b.Name()]; ok {
+			r.Error = err.Error()
+		}
+
+		timings := b.Timings()
+		if len(timings) == 0 {
+			r.Skipped = r.Error == ""
+			results = append(results, r)
+			continue
+		}
+
+		r.Start = timings[0].Start
+		end := r.Start
+		for _, t := range timings {
+			if e := t.Start.Add(t.Duration); e.After(end) {
+				end = e
+			}
+			if t.Type == "provisioner" {
+				r.Provisioners = append(r.Provisioners, t)
+			}
+		}
+		r.Duration = end.Sub(r.Start)
+		results = append(results, r)
+	}
+	return results
+}
+
+// writeResults writes the results of the builds to path in the format,
+// so that CI systems show which builds and provisioners failed.
+func writeResults(format, path string, builds []packer.Build, errors map[string]error) error {
+	if path == "" {
+		path = resultsFiles[format]
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	results := newBuildResults(builds, errors)
+	switch format {
+	case resultsFormatJUnit:
+		err = writeJUnit(f, results)
+	case resultsFormatTAP:
+		err = writeTAP(f, results)
+	default:
+		err = fmt.Errorf("unknown results format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// provisionerName names a provisioner by its position, since a template
+// can have several of the same type.
+func provisionerName(i int, t packer.Timing) string {
+	return fmt.Sprintf("provisioner %d: %s", i+1, t.Name)
+}
+
+// firstLine returns the first line of an error, as its summary.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i > -1 {
+		return s[:i]
+	}
+	return s
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func newJUnitTestCase(class, name string, d time.Duration, err string) junitTestCase {
+	c := junitTestCase{ClassName: class, Name: name, Time: junitTime(d)}
+	if err != "" {
+		c.Failure = &junitFailure{Message: firstLine(err), Type: "error", Text: err}
+	}
+	return c
+}
+
+// writeJUnit writes the results as JUnit XML: a test suite per build,
+// with a test case for the build and one for each of its provisioners.
+func writeJUnit(w io.Writer, results []buildResult) error {
+	suites := junitTestSuites{Name: "packer build"}
+	var total time.Duration
+	for _, r := range results {
+		suite := junitTestSuite{Name: r.Name, Time: junitTime(r.Duration)}
+		if !r.Start.IsZero() {
+			suite.Timestamp = r.Start.UTC().Format("2006-01-02T15:04:05")
+		}
+
+		build := newJUnitTestCase(r.Name, "build", r.Duration, r.Error)
+		if r.Skipped {
+			build.Skipped = &struct{}{}
+		}
+		suite.Cases = append(suite.Cases, build)
+		for i, t := range r.Provisioners {
+			suite.Cases = append(suite.Cases,
+				newJUnitTestCase(r.Name, provisionerName(i, t), t.Duration, t.Error))
+		}
+
+		for _, c := range suite.Cases {
+			suite.Tests++
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			if c.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		total += r.Duration
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeTAP writes the results in the Test Anything Protocol, a test point
+// for each build and one for each of its provisioners. Failures have
+// their error and duration in a YAML block.
+func writeTAP(w io.Writer, results []buildResult) error {
+	var points []string
+	add := func(name string, d time.Duration, err string, skipped bool) {
+		n := len(points) + 1
+		switch {
+		case skipped:
+			points = append(points, fmt.Sprintf("ok %d - %s # SKIP not run", n, name))
+		case err == "":
+			points = append(points, fmt.Sprintf("ok %d - %s", n, name))
+		default:
+			point := fmt.Sprintf("not ok %d - %s\n  ---\n  message: |\n", n, name)
+			for _, line := range strings.Split(err, "\n") {
+				point += "    " + line + "\n"
+			}
+			point += fmt.Sprintf("  duration_ms: %d\n  ...", int64(d/time.Millisecond))
+			points = append(points, point)
+		}
+	}
+
+	for _, r := range results {
+		add(r.Name, r.Duration, r.Error, r.Skipped)
+		for i, t := range r.Provisioners {
+			add(r.Name+": "+provisionerName(i, t), t.Duration, t.Error, false)
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "TAP version 13\n1..%d\n", len(points)); err != nil {
+		return err
+	}
+	for _, point := range points {
+		if _, err := io.WriteString(w, point+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,9 +2,16 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/builder/file"
 	"github.com/hashicorp/packer/packer"
@@ -37,6 +44,45 @@ func TestBuildOnlyFileCommaFlags(t *testing.T) {
 	}
 }
 
+func TestBuildDependencies(t *testing.T) {
+	for _, parallel := range []string{"true", "false"} {
+		c := &BuildCommand{
+			Meta: testMetaFile(t),
+		}
+
+		args := []string{
+			"-parallel=" + parallel,
+			filepath.Join(testFixture("build-dependencies"), "template.json"),
+		}
+
+		if code := c.Run(args); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+
+		// The id of the artifact of the file builder is "File"
+		content, err := ioutil.ReadFile("vanilla.txt")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(content) != "File vanilla" {
+			t.Fatalf("bad: %q", content)
+		}
+		cleanup()
+	}
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		"-only=vanilla",
+		filepath.Join(testFixture("build-dependencies"), "template.json"),
+	}
+	defer cleanup()
+	if code := c.Run(args); code == 0 {
+		t.Fatal("should fail without the build vanilla depends on")
+	}
+}
+
 func TestBuildStdin(t *testing.T) {
 	c := &BuildCommand{
 		Meta: testMetaFile(t),
@@ -160,3 +206,167 @@ func cleanup() {
 	os.RemoveAll("vanilla.txt")
 	os.RemoveAll("cherry.txt")
 }
+
+func TestBuildExportTrace(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	for k, v := range map[string]string{
+		EnvOTLPEndpoint: server.URL + "/",
+		EnvOTLPHeaders:  "Authorization=Bearer secret",
+		EnvTraceParent:  "00-" + traceID + "-00f067aa0ba902b7-01",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-only=chocolate",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	if body == nil {
+		t.Fatal("no trace was exported")
+	}
+	spans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	names := []string{}
+	for _, s := range spans {
+		span := s.(map[string]interface{})
+		if span["traceId"] != traceID {
+			t.Fatalf("bad trace id: %#v", span)
+		}
+		names = append(names, span["name"].(string))
+	}
+	expected := []string{"packer build", "builder file", "build chocolate"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad spans: %#v", names)
+	}
+	if spans[0].(map[string]interface{})["parentSpanId"] != "00f067aa0ba902b7" {
+		t.Fatalf("bad root span: %#v", spans[0])
+	}
+}
+
+func TestBuildResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.xml")
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-only=chocolate,vanilla",
+		"-results-format=junit",
+		"-results-file=" + path,
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if suites.Tests != 2 || suites.Failures != 0 || len(suites.Suites) != 2 {
+		t.Fatalf("bad: %s", data)
+	}
+	for _, s := range suites.Suites {
+		if len(s.Cases) != 1 || s.Cases[0].ClassName != s.Name || s.Cases[0].Name != "build" {
+			t.Fatalf("bad: %s", data)
+		}
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	start := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []buildResult{
+		{
+			Name:     "vbox",
+			Start:    start,
+			Duration: 90 * time.Second,
+			Error:    "Script exited with non-zero exit status: 1",
+			Provisioners: []packer.Timing{
+				{Type: "provisioner", Name: "file", Start: start, Duration: time.Second},
+				{Type: "provisioner", Name: "shell", Start: start, Duration: 2 * time.Second,
+					Error: "Script exited with non-zero exit status: 1"},
+			},
+		},
+		{Name: "vmware", Skipped: true},
+	}
+
+	var junit bytes.Buffer
+	if err := writeJUnit(&junit, results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(junit.Bytes(), &suites); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if suites.Tests != 4 || suites.Failures != 2 || suites.Skipped != 1 {
+		t.Fatalf("bad: %s", junit.String())
+	}
+	vbox := suites.Suites[0]
+	if vbox.Time != "90.000" || vbox.Timestamp != "2017-01-02T03:04:05" {
+		t.Fatalf("bad: %#v", vbox)
+	}
+	shell := vbox.Cases[2]
+	if shell.Name != "provisioner 2: shell" || shell.Time != "2.000" ||
+		shell.Failure == nil || shell.Failure.Message != "Script exited with non-zero exit status: 1" {
+		t.Fatalf("bad: %#v", shell)
+	}
+
+	var tap bytes.Buffer
+	if err := writeTAP(&tap, results); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `TAP version 13
+1..4
+not ok 1 - vbox
+  ---
+  message: |
+    Script exited with non-zero exit status: 1
+  duration_ms: 90000
+  ...
+ok 2 - vbox: provisioner 1: file
+not ok 3 - vbox: provisioner 2: shell
+  ---
+  message: |
+    Script exited with non-zero exit status: 1
+  duration_ms: 2000
+  ...
+ok 4 - vmware # SKIP not run
+`
+	if tap.String() != expected {
+		t.Fatalf("bad:\n%s", tap.String())
+	}
+}
@@ -0,0 +1,213 @@
+package command
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/version"
+)
+
+// These are the environment variables that configure the export of build
+// traces. They are the ones OpenTelemetry SDKs use, traces are sent with
+// the OpenTelemetry protocol (OTLP) over HTTP in its JSON encoding.
+const (
+	EnvOTLPEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvOTLPTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	EnvOTLPHeaders        = "OTEL_EXPORTER_OTLP_HEADERS"
+	EnvOTELServiceName    = "OTEL_SERVICE_NAME"
+
+	// EnvTraceParent is a W3C traceparent header value, such as the one CI
+	// systems set for their jobs. The build trace becomes part of its trace.
+	EnvTraceParent = "TRACEPARENT"
+)
+
+var traceParentRe = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// traceEndpoint returns the URL traces are sent to, or an empty string if
+// the export isn't configured.
+func traceEndpoint() string {
+	if endpoint := os.Getenv(EnvOTLPTracesEndpoint); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv(EnvOTLPEndpoint); endpoint != "" {
+		return strings.TrimRight(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// exportTrace sends the timings of the builds as a trace. The run is the
+// root span, each build a span below it and the timings of the build the
+// spans below the build, nested by their depth.
+func (c BuildCommand) exportTrace(start time.Time, builds []packer.Build, errors map[string]error) error {
+	endpoint := traceEndpoint()
+	if endpoint == "" {
+		return nil
+	}
+
+	traceID, parentID := randomID(16), ""
+	if m := traceParentRe.FindStringSubmatch(os.Getenv(EnvTraceParent)); m != nil {
+		traceID, parentID = m[1], m[2]
+	}
+
+	rootID := randomID(8)
+	spans := []otlpSpan{
+		newOTLPSpan(traceID, rootID, parentID, "packer build", start, time.Now(), nil),
+	}
+	if len(errors) > 0 {
+		spans[0].setError(fmt.Sprintf("%d build(s) errored", len(errors)))
+	}
+
+	for _, b := range builds {
+		timings := b.Timings()
+		if len(timings) == 0 {
+			continue
+		}
+
+		buildID := randomID(8)
+		buildStart, buildEnd := timings[0].Start, timings[0].Start
+
+		// Parents of the timings by their depth
+		parents := []string{buildID}
+		for _, t := range timings {
+			if t.Depth >= len(parents) {
+				continue
+			}
+
+			id := randomID(8)
+			end := t.Start.Add(t.Duration)
+			span := newOTLPSpan(traceID, id, parents[t.Depth], t.Type+" "+t.Name, t.Start, end, []otlpAttribute{
+				stringAttribute("packer.build.name", b.Name()),
+				stringAttribute("packer.timing.type", t.Type),
+				stringAttribute("packer.timing.name", t.Name),
+			})
+			if t.Error != "" {
+				span.setError(t.Error)
+			}
+			spans = append(spans, span)
+			parents = append(parents[:t.Depth+1], id)
+
+			if end.After(buildEnd) {
+				buildEnd = end
+			}
+		}
+
+		span := newOTLPSpan(traceID, buildID, rootID, "build "+b.Name(), buildStart, buildEnd, []otlpAttribute{
+			stringAttribute("packer.build.name", b.Name()),
+		})
+		if err, ok := errors[b.Name()]; ok {
+			span.setError(err.Error())
+		}
+		spans = append(spans, span)
+	}
+
+	serviceName := os.Getenv(EnvOTELServiceName)
+	if serviceName == "" {
+		serviceName = "packer"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpAttribute{
+						stringAttribute("service.name", serviceName),
+						stringAttribute("service.version", version.FormattedVersion()),
+					},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "packer"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range strings.Split(os.Getenv(EnvOTLPHeaders), ",") {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) == 2 {
+			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded with %s", endpoint, resp.Status)
+	}
+
+	return nil
+}
+
+// otlpSpan is a span in the JSON encoding of OTLP.
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func newOTLPSpan(traceID, id, parentID, name string, start, end time.Time, attrs []otlpAttribute) otlpSpan {
+	return otlpSpan{
+		TraceID:           traceID,
+		SpanID:            id,
+		ParentSpanID:      parentID,
+		Name:              name,
+		Kind:              1, // SPAN_KIND_INTERNAL
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        attrs,
+	}
+}
+
+func (s *otlpSpan) setError(msg string) {
+	s.Status = &otlpStatus{Code: 2, Message: msg} // STATUS_CODE_ERROR
+}
+
+func stringAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]string{"stringValue": value}}
+}
+
+// randomID returns a random hex encoded ID of n bytes.
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
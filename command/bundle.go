@@ -0,0 +1,328 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/helper/bundle"
+	"github.com/hashicorp/packer/helper/flag-slice"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/post-processor/manifest"
+)
+
+// BundleCommand packs the artifacts of builds, with their manifest, SBOMs
+// and signatures, into a bundle, and verifies and extracts bundles.
+type BundleCommand struct {
+	Meta
+}
+
+func (c *BundleCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.Ui.Say(c.Help())
+		return 1
+	}
+
+	switch args[0] {
+	case "create":
+		return c.create(args[1:])
+	case "import":
+		return c.importBundle(args[1:])
+	default:
+		c.Ui.Say(c.Help())
+		return 1
+	}
+}
+
+func (c *BundleCommand) create(args []string) int {
+	var cfgManifest, cfgSignKey string
+	var cfgBuilds, cfgSBOMs, cfgSignatures []string
+	var cfgSign bool
+	flags := c.Meta.FlagSet("bundle create", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&cfgManifest, "manifest", "packer-manifest.json", "")
+	flags.Var((*sliceflag.StringFlag)(&cfgBuilds), "build", "")
+	flags.Var((*sliceflag.StringFlag)(&cfgSBOMs), "sbom", "")
+	flags.Var((*sliceflag.StringFlag)(&cfgSignatures), "signature", "")
+	flags.BoolVar(&cfgSign, "sign", false, "")
+	flags.StringVar(&cfgSignKey, "sign-key", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 1 {
+		flags.Usage()
+		return 1
+	}
+	output := flags.Arg(0)
+
+	data, err := ioutil.ReadFile(cfgManifest)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the manifest: %s", err))
+		return 1
+	}
+	var manifestFile manifest.ManifestFile
+	if err := json.Unmarshal(data, &manifestFile); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error parsing the manifest %s: %s", cfgManifest, err))
+		return 1
+	}
+
+	builds, err := selectBundleBuilds(&manifestFile, cfgBuilds)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	entries, err := bundleEntries(filepath.Dir(cfgManifest), builds, manifestFile.LastRunUUID)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	for _, path := range cfgSBOMs {
+		entries = append(entries, bundle.Entry{
+			File:   bundle.File{Path: "sbom/" + filepath.Base(path), Kind: bundle.KindSBOM},
+			Source: path,
+		})
+	}
+	for _, path := range cfgSignatures {
+		entries = append(entries, bundle.Entry{
+			File:   bundle.File{Path: "signatures/" + filepath.Base(path), Kind: bundle.KindSignature},
+			Source: path,
+		})
+	}
+
+	index := &bundle.Index{
+		Created:       time.Now().UTC(),
+		PackerVersion: c.CoreConfig.Version,
+	}
+	for _, b := range builds {
+		index.Builds = append(index.Builds, b.BuildName)
+	}
+
+	var sign bundle.SignFunc
+	if cfgSign || cfgSignKey != "" {
+		sign = bundle.GPGSigner(cfgSignKey)
+	}
+
+	// The bundle is written next to the output and only moved there once
+	// complete
+	f, err := ioutil.TempFile(filepath.Dir(output), ".packer-bundle")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating the bundle: %s", err))
+		return 1
+	}
+	defer os.Remove(f.Name())
+
+	c.Ui.Say(fmt.Sprintf("Bundling %d file(s) of build(s) %s...", len(entries), strings.Join(index.Builds, ", ")))
+	err = bundle.Write(f, index, entries, sign)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), output)
+	}
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating the bundle: %s", err))
+		return 1
+	}
+
+	var size int64
+	for _, file := range index.Files {
+		c.Ui.Machine("bundle-file", file.Path, file.Kind, file.SHA256)
+		size += file.Size
+	}
+	signed := ""
+	if sign != nil {
+		signed = ", signed"
+	}
+	c.Ui.Say(fmt.Sprintf("Created bundle %s (%s%s)", output, packer.FormatSize(size), signed))
+	return 0
+}
+
+// selectBundleBuilds returns the builds of the manifest with the given
+// names, or the builds of the last run if there are none. The manifest
+// keeps the builds of every run, so the last build with a name is used.
+func selectBundleBuilds(m *manifest.ManifestFile, names []string) ([]manifest.Artifact, error) {
+	last := make(map[string]int)
+	var order []string
+	for i, b := range m.Builds {
+		if len(names) == 0 && m.LastRunUUID != "" && b.PackerRunUUID != m.LastRunUUID {
+			continue
+		}
+		if _, ok := last[b.BuildName]; !ok {
+			order = append(order, b.BuildName)
+		}
+		last[b.BuildName] = i
+	}
+
+	if len(names) == 0 {
+		names = order
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("The manifest has no builds")
+	}
+
+	builds := make([]manifest.Artifact, 0, len(names))
+	for _, name := range names {
+		i, ok := last[name]
+		if !ok {
+			return nil, fmt.Errorf("The manifest has no build '%s'", name)
+		}
+		builds = append(builds, m.Builds[i])
+	}
+	return builds, nil
+}
+
+// bundleEntries returns the entries of the artifacts of the builds, and of
+// a manifest of the builds with the paths of their files in the bundle.
+// Relative paths of the manifest are relative to its directory.
+func bundleEntries(dir string, builds []manifest.Artifact, runUUID string) ([]bundle.Entry, error) {
+	var entries []bundle.Entry
+	for i, b := range builds {
+		files := make([]manifest.ArtifactFile, len(b.ArtifactFiles))
+		for j, af := range b.ArtifactFiles {
+			source := af.Name
+			if !filepath.IsAbs(source) {
+				source = filepath.Join(dir, source)
+			}
+			if _, err := os.Stat(source); err != nil {
+				return nil, fmt.Errorf("Error reading the artifact of build '%s': %s", b.BuildName, err)
+			}
+
+			path := fmt.Sprintf("artifacts/%s/%s", bundleName(b.BuildName), filepath.Base(af.Name))
+			entries = append(entries, bundle.Entry{
+				File:   bundle.File{Path: path, Kind: bundle.KindArtifact, Build: b.BuildName},
+				Source: source,
+			})
+			files[j] = manifest.ArtifactFile{Name: path, Size: af.Size}
+		}
+		builds[i].ArtifactFiles = files
+	}
+
+	data, err := json.MarshalIndent(&manifest.ManifestFile{
+		Builds:      builds,
+		LastRunUUID: runUUID,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, bundle.Entry{
+		File: bundle.File{Path: "packer-manifest.json", Kind: bundle.KindManifest},
+		Data: data,
+	})
+
+	return entries, nil
+}
+
+// bundleName returns a build name that can be used in the paths of a
+// bundle.
+func bundleName(name string) string {
+	name = strings.NewReplacer("/", "-", `\`, "-").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		name = "build"
+	}
+	return name
+}
+
+func (c *BundleCommand) importBundle(args []string) int {
+	var cfgOutputDir, cfgKeyring string
+	var cfgForce bool
+	flags := c.Meta.FlagSet("bundle import", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&cfgOutputDir, "output-dir", ".", "")
+	flags.StringVar(&cfgKeyring, "keyring", "", "")
+	flags.BoolVar(&cfgForce, "force", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error opening the bundle: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	// Without a keyring, only the checksums are verified
+	signed := false
+	verify := func(index, signature []byte) error {
+		signed = signature != nil
+		return nil
+	}
+	if cfgKeyring != "" {
+		verify = bundle.GPGVerifier(cfgKeyring)
+	}
+
+	c.Ui.Say(fmt.Sprintf("Verifying and extracting %s...", flags.Arg(0)))
+	index, err := bundle.Extract(f, cfgOutputDir, verify, cfgForce)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error importing the bundle: %s", err))
+		return 1
+	}
+
+	for _, file := range index.Files {
+		c.Ui.Machine("bundle-file", file.Path, file.Kind, file.SHA256)
+		c.Ui.Message(fmt.Sprintf("%s (%s)", file.Path, packer.FormatSize(file.Size)))
+	}
+	if cfgKeyring != "" {
+		c.Ui.Say("The signature of the bundle is valid.")
+	} else if signed {
+		c.Ui.Say("The bundle is signed, but its signature wasn't verified: use -keyring to verify it.")
+	}
+	c.Ui.Say(fmt.Sprintf("Imported the bundle of build(s) %s, created %s, to %s",
+		strings.Join(index.Builds, ", "), index.Created.Format(time.RFC3339), cfgOutputDir))
+	return 0
+}
+
+func (*BundleCommand) Help() string {
+	helpText := `
+Usage: packer bundle create [options] OUTPUT
+       packer bundle import [options] BUNDLE
+
+  Packs the artifacts of builds into a single archive that can be moved to
+  machines without access to where the builds ran, and verifies and
+  extracts these archives.
+
+  create   Creates a bundle of the files of the artifacts of builds, listed
+           in the manifest written by the manifest post-processor, with the
+           manifest, SBOMs and signatures. The bundle lists the checksums
+           of its files, and can be signed with gpg.
+
+  import   Verifies the checksums of the files of a bundle, and its
+           signature if -keyring is set, then extracts it. Nothing is
+           extracted from a bundle that fails to verify.
+
+Create Options:
+
+  -manifest=path        The manifest of the builds, packer-manifest.json by
+                        default.
+  -build=name           A build to bundle, can be repeated. Defaults to the
+                        builds of the last run in the manifest.
+  -sbom=path            An SBOM to add to the bundle, can be repeated.
+  -signature=path       A signature of the artifacts to add to the bundle,
+                        can be repeated.
+  -sign                 Sign the bundle with the default key of gpg.
+  -sign-key=id          Sign the bundle with this key of gpg.
+
+Import Options:
+
+  -output-dir=path      The directory to extract the bundle to, the
+                        current directory by default.
+  -keyring=path         Verify the signature of the bundle with the public
+                        keys of this keyring.
+  -force                Replace the files that already exist.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*BundleCommand) Synopsis() string {
+	return "create or import a bundle of the artifacts of builds"
+}
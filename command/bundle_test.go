@@ -0,0 +1,146 @@
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/post-processor/manifest"
+)
+
+// testBundleManifest writes a manifest with a run of the builds, each with
+// an artifact file, and returns its path.
+func testBundleManifest(t *testing.T, dir string, builds ...string) string {
+	m := manifest.ManifestFile{LastRunUUID: "run"}
+	for _, name := range builds {
+		path := name + ".img"
+		if err := ioutil.WriteFile(filepath.Join(dir, path), []byte("image of "+name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		m.Builds = append(m.Builds, manifest.Artifact{
+			BuildName:     name,
+			BuilderType:   "null",
+			ArtifactFiles: []manifest.ArtifactFile{{Name: path, Size: int64(len("image of " + name))}},
+			PackerRunUUID: "run",
+		})
+	}
+
+	data, err := json.Marshal(&m)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(dir, "packer-manifest.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path
+}
+
+func TestBundleCommand_createImport(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	manifestPath := testBundleManifest(t, td, "qemu", "vmware")
+	sbom := filepath.Join(td, "sbom.spdx.json")
+	if err := ioutil.WriteFile(sbom, []byte("{}"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	output := filepath.Join(td, "images.tar.gz")
+
+	c := &BundleCommand{Meta: testMeta(t)}
+	args := []string{"create", "-manifest", manifestPath, "-build", "qemu", "-sbom", sbom, output}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	c = &BundleCommand{Meta: testMeta(t)}
+	out := filepath.Join(td, "out")
+	if code := c.Run([]string{"import", "-output-dir", out, output}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	stdout, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(stdout, "Imported the bundle of build(s) qemu") {
+		t.Fatalf("bad: %s", stdout)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(out, "artifacts", "qemu", "qemu.img"))
+	if err != nil || string(data) != "image of qemu" {
+		t.Fatalf("bad: %q %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "sbom", "sbom.spdx.json")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The manifest of the bundle only has the bundled build, with the
+	// paths of its files in the bundle
+	data, err = ioutil.ReadFile(filepath.Join(out, "packer-manifest.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var m manifest.ManifestFile
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(m.Builds) != 1 || m.Builds[0].ArtifactFiles[0].Name != "artifacts/qemu/qemu.img" {
+		t.Fatalf("bad: %#v", m.Builds)
+	}
+
+	// Importing again doesn't replace the files without -force
+	c = &BundleCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"import", "-output-dir", out, output}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	c = &BundleCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"import", "-output-dir", out, "-force", output}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+}
+
+func TestBundleCommand_createErrors(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	manifestPath := testBundleManifest(t, td, "qemu")
+	output := filepath.Join(td, "images.tar.gz")
+
+	cases := map[string]struct {
+		Args []string
+		Err  string
+	}{
+		"unknown build": {
+			[]string{"create", "-manifest", manifestPath, "-build", "vmware", output},
+			"The manifest has no build 'vmware'",
+		},
+		"missing manifest": {
+			[]string{"create", "-manifest", filepath.Join(td, "missing.json"), output},
+			"Error reading the manifest",
+		},
+		"missing sbom": {
+			[]string{"create", "-manifest", manifestPath, "-sbom", filepath.Join(td, "missing.json"), output},
+			"Error creating the bundle",
+		},
+	}
+
+	for name, tc := range cases {
+		c := &BundleCommand{Meta: testMeta(t)}
+		if code := c.Run(tc.Args); code != 1 {
+			t.Fatalf("%s: bad: %d", name, code)
+		}
+		_, stderr := outputCommand(t, c.Meta)
+		if !strings.Contains(stderr, tc.Err) {
+			t.Fatalf("%s: bad: %s", name, stderr)
+		}
+		if _, err := os.Stat(output); !os.IsNotExist(err) {
+			t.Fatalf("%s: the bundle was created: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// CacheCommand lists and prunes the files of the cache directory, which
+// Packer processes can share.
+type CacheCommand struct {
+	Meta
+}
+
+func (c *CacheCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.Ui.Say(c.Help())
+		return 1
+	}
+
+	cache, ok := c.Cache.(*packer.FileCache)
+	if !ok {
+		c.Ui.Error("The cache directory is not available.")
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		return c.list(cache, args[1:])
+	case "prune":
+		return c.prune(cache, args[1:])
+	default:
+		c.Ui.Say(c.Help())
+		return 1
+	}
+}
+
+func (c *CacheCommand) list(cache *packer.FileCache, args []string) int {
+	flags := c.Meta.FlagSet("cache list", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the cache directory: %s", err))
+		return 1
+	}
+	if len(entries) == 0 {
+		c.Ui.Say(fmt.Sprintf("The cache directory %s is empty.", cache.CacheDir))
+		return 0
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+
+		var notes []string
+		inUse := cache.InUse(e)
+		if inUse {
+			notes = append(notes, "in use")
+		}
+		if e.Partial {
+			notes = append(notes, "partial")
+		}
+		note := ""
+		if len(notes) > 0 {
+			note = fmt.Sprintf(" (%s)", strings.Join(notes, ", "))
+		}
+
+		c.Ui.Machine("cache-entry", e.Path,
+			strconv.FormatInt(e.Size, 10),
+			strconv.FormatInt(e.LastUsed.Unix(), 10),
+			strconv.FormatBool(inUse))
+		c.Ui.Say(fmt.Sprintf("%-72s %10s  used %s%s",
+			filepath.Base(e.Path), packer.FormatSize(e.Size), formatLastUsed(e.LastUsed), note))
+	}
+	c.Ui.Say(fmt.Sprintf("%d file(s), %s in %s", len(entries), packer.FormatSize(total), cache.CacheDir))
+
+	return 0
+}
+
+func (c *CacheCommand) prune(cache *packer.FileCache, args []string) int {
+	var cfgOlderThan, cfgMaxSize string
+	var cfgDryRun bool
+	flags := c.Meta.FlagSet("cache prune", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&cfgOlderThan, "older-than", "", "")
+	flags.StringVar(&cfgMaxSize, "max-size", "", "")
+	flags.BoolVar(&cfgDryRun, "dry-run", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 0 || (cfgOlderThan == "" && cfgMaxSize == "") {
+		flags.Usage()
+		return 1
+	}
+
+	var olderThan time.Duration
+	if cfgOlderThan != "" {
+		var err error
+		if olderThan, err = parseAge(cfgOlderThan); err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -older-than: %s", err))
+			return 1
+		}
+	}
+
+	maxSize := int64(-1)
+	if cfgMaxSize != "" {
+		var err error
+		if maxSize, err = packer.ParseSize(cfgMaxSize); err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -max-size: %s", err))
+			return 1
+		}
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the cache directory: %s", err))
+		return 1
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	// Entries are sorted from the least recently used, so removing them in
+	// order removes the old ones first, then the least recently used ones
+	// until the cache is small enough.
+	failed := 0
+	var freed int64
+	cutoff := time.Now().Add(-olderThan)
+	for _, e := range entries {
+		old := olderThan > 0 && e.LastUsed.Before(cutoff)
+		large := maxSize >= 0 && total-freed > maxSize
+		if !old && !large {
+			continue
+		}
+
+		name := filepath.Base(e.Path)
+		if cfgDryRun {
+			if cache.InUse(e) {
+				c.Ui.Say(fmt.Sprintf("Would skip %s, it is in use", name))
+				continue
+			}
+			c.Ui.Say(fmt.Sprintf("Would remove %s (%s)", name, packer.FormatSize(e.Size)))
+			freed += e.Size
+			continue
+		}
+
+		removed, err := cache.Remove(e)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error removing %s: %s", name, err))
+			failed++
+			continue
+		}
+		if !removed {
+			c.Ui.Say(fmt.Sprintf("Skipping %s, it is in use", name))
+			continue
+		}
+
+		c.Ui.Machine("cache-removed", e.Path, strconv.FormatInt(e.Size, 10))
+		c.Ui.Say(fmt.Sprintf("Removed %s (%s)", name, packer.FormatSize(e.Size)))
+		freed += e.Size
+	}
+
+	c.Ui.Say(fmt.Sprintf("Freed %s, %s left in %s",
+		packer.FormatSize(freed), packer.FormatSize(total-freed), cache.CacheDir))
+
+	if failed > 0 {
+		c.Ui.Error(fmt.Sprintf("%d file(s) couldn't be removed.", failed))
+		return 1
+	}
+	if maxSize >= 0 && total-freed > maxSize {
+		c.Ui.Error(fmt.Sprintf("The cache is still larger than %s, files in use were kept.", packer.FormatSize(maxSize)))
+	}
+
+	return 0
+}
+
+// parseAge parses a duration, which can also be a number of days, like
+// 30d.
+func parseAge(v string) (time.Duration, error) {
+	if strings.HasSuffix(v, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(v, "d"), 64)
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("%q isn't a number of days", v)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%q is negative", v)
+	}
+	return d, nil
+}
+
+// formatLastUsed formats the last use of an entry relative to now.
+func formatLastUsed(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minute(s) ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hour(s) ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%d day(s) ago", int(d/(24*time.Hour)))
+	}
+}
+
+func (*CacheCommand) Help() string {
+	helpText := `
+Usage: packer cache list
+       packer cache prune [options]
+
+  Lists or prunes the files downloaded to the cache directory, which is set
+  with PACKER_CACHE_DIR and defaults to ./packer_cache.
+
+  Packer processes can share the cache directory: downloads are locked so
+  that a file is only downloaded once, and files are only moved into place
+  once complete. Builds keep the files they use locked until they are done,
+  pruning skips these files.
+
+  list     Lists the files from the least to the most recently used, with
+           their size and whether a build is using them.
+
+  prune    Removes the files unused for longer than -older-than, then the
+           least recently used files until the cache is no larger than
+           -max-size. At least one of them is required.
+
+Prune Options:
+
+  -older-than=duration   Remove the files unused for this long, like 72h
+                         or 30d.
+  -max-size=size         Maximum size of the cache, like 500MB or 50GB.
+  -dry-run               Only show the files that would be removed.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*CacheCommand) Synopsis() string {
+	return "list and prune the download cache"
+}
@@ -0,0 +1,144 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// testCache returns a cache with a 1KB file for every key, last used the
+// given time ago, and the paths of the files by key.
+func testCache(t *testing.T, ages map[string]time.Duration) (*packer.FileCache, map[string]string) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cache := &packer.FileCache{CacheDir: dir}
+	paths := make(map[string]string)
+	now := time.Now()
+	for key, age := range ages {
+		path := cache.Lock(key)
+		if err := ioutil.WriteFile(path, []byte(strings.Repeat("x", 1024)), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		cache.Unlock(key)
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		paths[key] = filepath.Base(path)
+	}
+
+	return cache, paths
+}
+
+func TestCacheCommand_list(t *testing.T) {
+	cache, paths := testCache(t, map[string]time.Duration{
+		"old.iso": 48 * time.Hour,
+	})
+	defer os.RemoveAll(cache.CacheDir)
+
+	// An interrupted download
+	partial := filepath.Join(cache.CacheDir, "partial.iso.part")
+	if err := ioutil.WriteFile(partial, []byte(strings.Repeat("x", 1024)), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &CacheCommand{Meta: testMeta(t)}
+	c.Cache = cache
+	if code := c.Run([]string{"list"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	for _, s := range []string{
+		paths["old.iso"], "1.0 KB  used 2 day(s) ago",
+		"partial.iso", "used just now (partial)",
+		"2 file(s), 2.0 KB in " + cache.CacheDir,
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("output should contain %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestCacheCommand_prune(t *testing.T) {
+	cache, paths := testCache(t, map[string]time.Duration{
+		"old.iso":    10 * 24 * time.Hour,
+		"used.iso":   9 * 24 * time.Hour,
+		"recent.iso": 3 * time.Hour,
+		"new.iso":    time.Hour,
+	})
+	defer os.RemoveAll(cache.CacheDir)
+
+	// A build uses used.iso, which makes it the most recently used file,
+	// and keeps it from being removed
+	if _, ok := cache.RLock("used.iso"); !ok {
+		t.Fatal("used.iso should exist")
+	}
+	defer cache.RUnlock("used.iso")
+
+	c := &CacheCommand{Meta: testMeta(t)}
+	c.Cache = cache
+
+	// A dry run removes nothing
+	if code := c.Run([]string{"prune", "-older-than=7d", "-dry-run"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	if entries, _ := cache.Entries(); len(entries) != 4 {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	if code := c.Run([]string{"prune", "-older-than=7d", "-max-size=0"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	for _, s := range []string{
+		"Would remove " + paths["old.iso"] + " (1.0 KB)",
+		"Removed " + paths["old.iso"],
+		"Removed " + paths["recent.iso"],
+		"Removed " + paths["new.iso"],
+		"Skipping " + paths["used.iso"] + ", it is in use",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("output should contain %q:\n%s", s, out)
+		}
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 1 || filepath.Base(entries[0].Path) != paths["used.iso"] {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"72h":  72 * time.Hour,
+		"30d":  30 * 24 * time.Hour,
+		"1.5d": 36 * time.Hour,
+	}
+	for v, expected := range cases {
+		d, err := parseAge(v)
+		if err != nil {
+			t.Fatalf("%s: err: %s", v, err)
+		}
+		if d != expected {
+			t.Fatalf("%s: got %s, expected %s", v, d, expected)
+		}
+	}
+
+	for _, v := range []string{"", "d", "-2d", "soon"} {
+		if _, err := parseAge(v); err == nil {
+			t.Fatalf("%q: should error", v)
+		}
+	}
+}
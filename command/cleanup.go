@@ -0,0 +1,137 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	awscommon "github.com/hashicorp/packer/builder/amazon/common"
+	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/common/resources"
+)
+
+// ResourceCleaners delete the temporary resources of the builders, by the
+// builder family recorded with the resource.
+var ResourceCleaners = map[string]func(resources.Resource) error{
+	"amazon":     awscommon.CleanupResource,
+	"virtualbox": vboxcommon.CleanupResource,
+}
+
+// CleanupCommand deletes the temporary resources left behind by builds
+// that crashed or were aborted.
+type CleanupCommand struct {
+	Meta
+
+	// Cleaners overrides ResourceCleaners, for tests.
+	Cleaners map[string]func(resources.Resource) error
+}
+
+func (c *CleanupCommand) Run(args []string) int {
+	var cfgDryRun bool
+	flags := c.Meta.FlagSet("cleanup", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.BoolVar(&cfgDryRun, "dry-run", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	cleaners := c.Cleaners
+	if cleaners == nil {
+		cleaners = ResourceCleaners
+	}
+
+	runs, err := resources.Runs()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the recorded resources: %s", err))
+		return 1
+	}
+
+	failed := 0
+	found := false
+	for _, run := range runs {
+		if run.Active() {
+			c.Ui.Say(fmt.Sprintf(
+				"Skipping run %s, it is still running (pid %d)", run.ID, run.Pid))
+			continue
+		}
+
+		if len(run.Resources) == 0 {
+			if !cfgDryRun {
+				run.Remove()
+			}
+			continue
+		}
+
+		found = true
+		c.Ui.Say(fmt.Sprintf("Run %s started %s:", run.ID, run.Started.Format("2006-01-02 15:04:05")))
+
+		// Delete in the reverse order of creation, so that resources are
+		// deleted before the ones they depend on, like an instance before
+		// its security group.
+		for i := len(run.Resources) - 1; i >= 0; i-- {
+			r := run.Resources[i]
+			if cfgDryRun {
+				c.Ui.Say(fmt.Sprintf("  Would delete %s", r))
+				continue
+			}
+
+			cleaner, ok := cleaners[r.Builder]
+			if !ok {
+				c.Ui.Error(fmt.Sprintf("  Can't delete %s: unknown builder %s", r, r.Builder))
+				failed++
+				continue
+			}
+
+			c.Ui.Say(fmt.Sprintf("  Deleting %s...", r))
+			if err := cleaner(r); err != nil {
+				c.Ui.Error(fmt.Sprintf("  Error deleting %s: %s", r, err))
+				failed++
+				continue
+			}
+			if err := run.Release(r); err != nil {
+				c.Ui.Error(fmt.Sprintf("  Error forgetting %s: %s", r, err))
+				failed++
+			}
+		}
+
+		if !cfgDryRun && len(run.Resources) == 0 {
+			run.Remove()
+		}
+	}
+
+	if !found {
+		c.Ui.Say("No resources left behind by builds were found.")
+	}
+
+	if failed > 0 {
+		c.Ui.Error(fmt.Sprintf("%d resource(s) couldn't be deleted.", failed))
+		return 1
+	}
+
+	return 0
+}
+
+func (*CleanupCommand) Help() string {
+	helpText := `
+Usage: packer cleanup [options]
+
+  Deletes the temporary resources left behind by builds that crashed or
+  were aborted, such as instances, key pairs, security groups and virtual
+  machines. Builders record these resources while they run, the resources
+  of builds that are still running are left alone.
+
+Options:
+
+  -dry-run               Only show the resources that would be deleted.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*CleanupCommand) Synopsis() string {
+	return "delete resources left behind by builds"
+}
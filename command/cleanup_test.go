@@ -0,0 +1,78 @@
+package command
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/common/resources"
+)
+
+func testCleanupRun(t *testing.T, runID string, rs ...resources.Resource) {
+	os.Setenv("PACKER_RUN_UUID", runID)
+	defer os.Unsetenv("PACKER_RUN_UUID")
+
+	for _, r := range rs {
+		resources.Track(r)
+	}
+}
+
+func TestCleanupCommand(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	os.Setenv(resources.EnvResourcesDir, td)
+	defer os.Unsetenv(resources.EnvResourcesDir)
+
+	sg := resources.Resource{Builder: "amazon", Kind: "security-group", ID: "sg-1", Region: "us-east-1"}
+	instance := resources.Resource{Builder: "amazon", Kind: "instance", ID: "i-1", Region: "us-east-1"}
+	vm := resources.Resource{Builder: "virtualbox", Kind: "vm", ID: "packer-broken"}
+	testCleanupRun(t, "crashed", sg, instance)
+	testCleanupRun(t, "failing", vm)
+
+	var deleted []string
+	cleaners := map[string]func(resources.Resource) error{
+		"amazon": func(r resources.Resource) error {
+			deleted = append(deleted, r.ID)
+			return nil
+		},
+		"virtualbox": func(r resources.Resource) error {
+			return errors.New("VBoxManage failed")
+		},
+	}
+
+	// A dry run deletes nothing
+	c := &CleanupCommand{Meta: testMeta(t), Cleaners: cleaners}
+	if code := c.Run([]string{"-dry-run"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	out, _ := outputCommand(t, c.Meta)
+	for _, s := range []string{"Would delete amazon instance i-1 (us-east-1)", "Would delete virtualbox vm packer-broken"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("output should contain %q:\n%s", s, out)
+		}
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("bad: %#v", deleted)
+	}
+
+	c = &CleanupCommand{Meta: testMeta(t), Cleaners: cleaners}
+	if code := c.Run(nil); code != 1 {
+		fatalCommand(t, c.Meta)
+	}
+	if strings.Join(deleted, ",") != "i-1,sg-1" {
+		t.Fatalf("resources should be deleted in reverse order: %#v", deleted)
+	}
+
+	runs, err := resources.Runs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(runs) != 1 || runs[0].ID != "failing" || len(runs[0].Resources) != 1 {
+		t.Fatalf("only the resource that couldn't be deleted should be left: %#v", runs)
+	}
+}
@@ -2,14 +2,31 @@ package command
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/packer/common/history"
 	"github.com/hashicorp/packer/packer"
 )
 
 const fixturesDir = "./test-fixtures"
 
+func TestMain(m *testing.M) {
+	// Keep the history of the builds of the tests out of the home
+	// directory
+	dir, err := ioutil.TempDir("", "packer-history")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(history.EnvHistoryDir, dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func fatalCommand(t *testing.T, m Meta) {
 	ui := m.Ui.(*packer.BasicUi)
 	out := ui.Writer.(*bytes.Buffer)
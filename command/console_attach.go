@@ -0,0 +1,86 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/common/consoles"
+)
+
+// ConsoleAttachCommand shows how to open the console of the machine of a
+// running headless build.
+type ConsoleAttachCommand struct {
+	Meta
+}
+
+func (c *ConsoleAttachCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("console-attach", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) > 1 {
+		flags.Usage()
+		return 1
+	}
+
+	all, err := consoles.Consoles()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the recorded consoles: %s", err))
+		return 1
+	}
+
+	found := false
+	for _, console := range all {
+		if len(args) == 1 && console.Build != args[0] {
+			continue
+		}
+		found = true
+
+		c.Ui.Say(fmt.Sprintf("Console of %s (%s, pid %d):", console.Build, console.Builder, console.Pid))
+		if console.URL != "" {
+			c.Ui.Say(fmt.Sprintf("  URL: %s", console.URL))
+		}
+		if console.Password != "" {
+			c.Ui.Say(fmt.Sprintf("  Password: %s", console.Password))
+		}
+		if console.Command != "" {
+			c.Ui.Say(fmt.Sprintf("  Command: %s", console.Command))
+		}
+		c.Ui.Machine("console", console.Build, console.Builder, console.URL, console.Password, console.Command)
+	}
+
+	if !found {
+		if len(args) == 1 {
+			c.Ui.Error(fmt.Sprintf("No console of a running build named %s was found.", args[0]))
+		} else {
+			c.Ui.Error("No console of a running build was found.")
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func (*ConsoleAttachCommand) Help() string {
+	helpText := `
+Usage: packer console-attach [BUILD]
+
+  Shows how to connect to the console of the machine of a running build,
+  such as the VNC URL and password of a headless VM, to look at the screen
+  of a hung installer without restarting the build with a GUI. Without a
+  build name, the consoles of all running builds are shown.
+
+  The builders record the consoles of their headless machines while they
+  run: the VNC server of QEMU and VMware VMs, the VRDP server of
+  VirtualBox VMs and the vmconnect command of Hyper-V VMs.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*ConsoleAttachCommand) Synopsis() string {
+	return "show how to connect to the console of a running build"
+}
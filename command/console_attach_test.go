@@ -0,0 +1,54 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/common/consoles"
+)
+
+func TestConsoleAttachCommand(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	os.Setenv(consoles.EnvConsolesDir, td)
+	defer os.Unsetenv(consoles.EnvConsolesDir)
+
+	c := &ConsoleAttachCommand{Meta: testMeta(t)}
+	if code := c.Run(nil); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+
+	consoles.Record(consoles.Console{Build: "ubuntu", Builder: "qemu", URL: "vnc://127.0.0.1:5901"})
+	consoles.Record(consoles.Console{Build: "windows", Builder: "vmware", URL: "vnc://127.0.0.1:5902", Password: "secret"})
+
+	c = &ConsoleAttachCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"windows"}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	out, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(out, "URL: vnc://127.0.0.1:5902") || !strings.Contains(out, "Password: secret") {
+		t.Fatalf("bad: %s", out)
+	}
+	if strings.Contains(out, "ubuntu") {
+		t.Fatalf("should only show the console of windows: %s", out)
+	}
+
+	c = &ConsoleAttachCommand{Meta: testMeta(t)}
+	if code := c.Run(nil); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	out, _ = outputCommand(t, c.Meta)
+	if !strings.Contains(out, "vnc://127.0.0.1:5901") || !strings.Contains(out, "vnc://127.0.0.1:5902") {
+		t.Fatalf("bad: %s", out)
+	}
+
+	c = &ConsoleAttachCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"centos"}); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+}
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/hashicorp/packer/common/resources"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template"
+)
+
+// DevelopCommand runs a build in develop mode: the builder starts the
+// machine and the provisioners run against it again whenever their local
+// files change, until the command is interrupted.
+type DevelopCommand struct {
+	Meta
+}
+
+func (c *DevelopCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("develop", FlagSetBuildFilter|FlagSetVars)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	tpl, err := template.ParseFile(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to parse template: %s", err))
+		return 1
+	}
+
+	core, err := c.Meta.Core(tpl)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	// Only one machine is kept running, the others would compete for
+	// the same files
+	buildNames := c.Meta.BuildNames(core)
+	if len(buildNames) != 1 {
+		c.Ui.Error(fmt.Sprintf(
+			"packer develop runs exactly one build, select one of %s with -only.",
+			strings.Join(buildNames, ", ")))
+		return 1
+	}
+
+	if deps := core.BuildDependencies(buildNames[0]); len(deps) > 0 {
+		c.Ui.Error(fmt.Sprintf(
+			"packer develop can't run build '%s', it uses the artifacts of %s.",
+			buildNames[0], strings.Join(deps, ", ")))
+		return 1
+	}
+
+	b, err := core.Build(buildNames[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to initialize build '%s': %s", buildNames[0], err))
+		return 1
+	}
+
+	b.SetDevelop(true)
+	b.SetOnError("cleanup")
+	warnings, err := b.Prepare()
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	if len(warnings) > 0 {
+		c.Ui.Say(fmt.Sprintf("Warnings for build '%s':\n", b.Name()))
+		for _, warning := range warnings {
+			c.Ui.Say(fmt.Sprintf("* %s", warning))
+		}
+		c.Ui.Say("")
+	}
+
+	if err := resources.StartRun(); err != nil {
+		log.Printf("[WARN] Error recording the run: %s", err)
+	}
+	defer func() {
+		if err := resources.EndRun(); err != nil {
+			log.Printf("[WARN] Error removing the record of the run: %s", err)
+		}
+	}()
+
+	// An interrupt is the normal way to stop developing, it cancels the
+	// build, which tears the machine down
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	cancelledCh := make(chan struct{})
+	go func() {
+		select {
+		case <-sigCh:
+		case <-doneCh:
+			return
+		}
+
+		c.Ui.Say("Interrupt received. Stopping the build and cleaning up...")
+		close(cancelledCh)
+		b.Cancel()
+	}()
+
+	ui := &packer.TargetedUI{
+		Target: b.Name(),
+		Ui:     c.Ui,
+	}
+	_, err = b.Run(ui, c.Cache)
+
+	select {
+	case <-cancelledCh:
+		c.Ui.Say(fmt.Sprintf("Stopped developing build '%s'.", b.Name()))
+		return 0
+	default:
+	}
+
+	// The build only ends by itself if it failed before the provisioners
+	// ran, or if it has none
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Build '%s' errored: %s", b.Name(), err))
+		return 1
+	}
+
+	c.Ui.Say(fmt.Sprintf("Build '%s' finished.", b.Name()))
+	return 0
+}
+
+func (*DevelopCommand) Help() string {
+	helpText := `
+Usage: packer develop [options] TEMPLATE
+
+  Starts the build of the template and runs its provisioners, then keeps
+  the machine running and watches the local files the provisioners use,
+  such as their scripts. When a file changes, only the provisioners that
+  use it run again against the same machine.
+
+  Press Ctrl-C to stop: the machine is torn down, no artifact is created
+  and the post-processors don't run. Changes to the template itself need
+  a restart.
+
+Options:
+
+  -except=foo,bar,baz        Develop all builds other than these
+  -only=foo                  Develop only the specified build
+  -machine-readable          Machine-readable output
+  -var 'key=value'           Variable for templates, can be used multiple times.
+  -var-file=path             JSON file containing user variables.
+  -var-dir=path              Directory of layered var files, see 'packer vars'.
+  -profile=name              Var file of the -var-dir to layer, e.g. prod.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*DevelopCommand) Synopsis() string {
+	return "run the provisioners again when their files change"
+}
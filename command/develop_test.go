@@ -0,0 +1,48 @@
+package command
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDevelop_multipleBuilds(t *testing.T) {
+	c := &DevelopCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if fileExists("chocolate.txt") {
+		t.Error("Expected NOT to find chocolate.txt")
+	}
+}
+
+func TestDevelop_only(t *testing.T) {
+	c := &DevelopCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-only=chocolate",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	if !fileExists("chocolate.txt") {
+		t.Error("Expected to find chocolate.txt")
+	}
+	if fileExists("vanilla.txt") {
+		t.Error("Expected NOT to find vanilla.txt")
+	}
+}
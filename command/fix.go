@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"strings"
 
 	"github.com/hashicorp/packer/fix"
 	"github.com/hashicorp/packer/template"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 type FixCommand struct {
@@ -17,9 +19,11 @@ type FixCommand struct {
 }
 
 func (c *FixCommand) Run(args []string) int {
-	var flagValidate bool
+	var flagValidate, flagWrite, flagDiff bool
 	flags := c.Meta.FlagSet("fix", FlagSetNone)
 	flags.BoolVar(&flagValidate, "validate", true, "")
+	flags.BoolVar(&flagWrite, "w", false, "")
+	flags.BoolVar(&flagDiff, "diff", false, "")
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -31,25 +35,29 @@ func (c *FixCommand) Run(args []string) int {
 		return 1
 	}
 
+	if flagWrite && flagDiff {
+		c.Ui.Error("-w and -diff can't be used together")
+		return 1
+	}
+
 	// Read the file for decoding
-	tplF, err := os.Open(args[0])
+	path := args[0]
+	original, err := ioutil.ReadFile(path)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error opening template: %s", err))
 		return 1
 	}
-	defer tplF.Close()
 
-	// Decode the JSON into a generic map structure
+	// Decode the JSON into a generic map structure, keeping the numbers
+	// as they are written
 	var templateData map[string]interface{}
-	decoder := json.NewDecoder(tplF)
+	decoder := json.NewDecoder(bytes.NewReader(original))
+	decoder.UseNumber()
 	if err := decoder.Decode(&templateData); err != nil {
 		c.Ui.Error(fmt.Sprintf("Error parsing template: %s", err))
 		return 1
 	}
 
-	// Close the file since we're done with that
-	tplF.Close()
-
 	input := templateData
 	for _, name := range fix.FixerOrder {
 		var err error
@@ -66,23 +74,53 @@ func (c *FixCommand) Run(args []string) int {
 		}
 	}
 
-	var output bytes.Buffer
-	encoder := json.NewEncoder(&output)
-	if err := encoder.Encode(input); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error encoding: %s", err))
-		return 1
+	// The fixers that are plugins run after the built-in ones, in the
+	// order of their names
+	components := c.CoreConfig.Components
+	for _, name := range components.FixerNames {
+		fixer, err := components.Fixer(name)
+		if err == nil && fixer == nil {
+			err = fmt.Errorf("fixer not found: %s", name)
+		}
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error loading fixer %s: %s", name, err))
+			return 1
+		}
+
+		log.Printf("Running fixer plugin: %s", name)
+		input, err = fixer.Fix(input)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error fixing with %s: %s", name, err))
+			return 1
+		}
 	}
 
-	var indented bytes.Buffer
-	if err := json.Indent(&indented, output.Bytes(), "", "  "); err != nil {
+	fixed, err := fix.Rewrite(original, input)
+	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error encoding: %s", err))
 		return 1
 	}
-
-	result := indented.String()
-	result = strings.Replace(result, `\u003c`, "<", -1)
-	result = strings.Replace(result, `\u003e`, ">", -1)
-	c.Ui.Say(result)
+	result := string(fixed)
+
+	switch {
+	case flagDiff:
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(original)),
+			B:        difflib.SplitLines(result),
+			FromFile: path,
+			ToFile:   path + " (fixed)",
+			Context:  3,
+		})
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error comparing: %s", err))
+			return 1
+		}
+		if diff != "" {
+			c.Ui.Say(strings.TrimSuffix(diff, "\n"))
+		}
+	case !flagWrite:
+		c.Ui.Say(strings.TrimSuffix(result, "\n"))
+	}
 
 	if flagValidate {
 		// Attemot to parse and validate the template
@@ -105,6 +143,19 @@ func (c *FixCommand) Run(args []string) int {
 		}
 	}
 
+	// The template is only written once it is validated
+	if flagWrite && result != string(original) {
+		info, err := os.Stat(path)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error writing template: %s", err))
+			return 1
+		}
+		if err := ioutil.WriteFile(path, fixed, info.Mode()); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error writing template: %s", err))
+			return 1
+		}
+	}
+
 	return 0
 }
 
@@ -128,10 +179,21 @@ Fixes that are run:
                       post-processor to new-style as of Packer 0.5.0.
   virtualbox-rename   Updates "virtualbox" builders to "virtualbox-iso"
   vmware-rename       Updates "vmware" builders to "vmware-iso"
+  deprecations        Migrates the deprecated configuration keys of the
+                      builders, provisioners and post-processors
+
+  The fixers that are plugins, named packer-fixer-NAME, run after these.
+
+  The fixed template keeps the order of the keys, and so the "_comment"
+  keys, and the indentation of the template.
 
 Options:
 
+  -diff               Outputs the differences between the template and the
+                      fixed template instead of the fixed template.
   -validate=true      If true (default), validates the fixed template.
+  -w                  Writes the fixed template to the template file
+                      instead of standard out, once it is validated.
 `
 
 	return strings.TrimSpace(helpText)
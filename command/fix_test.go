@@ -1,8 +1,13 @@
 package command
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 func TestFix_noArgs(t *testing.T) {
@@ -56,3 +61,115 @@ func TestFix_invalidTemplateDisableValidation(t *testing.T) {
 		fatalCommand(t, c.Meta)
 	}
 }
+
+func TestFix_write(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "template.json")
+	original := `{
+    "_comment": "Builds the base image",
+    "builders": [{"type": "dummy", "iso_md5": "abc"}]
+}
+`
+	if err := ioutil.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &FixCommand{
+		Meta: testMeta(t),
+	}
+	if code := c.Run([]string{"-validate=false", "-w", path}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	fixed, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `{
+    "_comment": "Builds the base image",
+    "builders": [
+        {
+            "type": "dummy",
+            "iso_checksum": "abc",
+            "iso_checksum_type": "md5"
+        }
+    ]
+}
+`
+	if string(fixed) != expected {
+		t.Fatalf("bad:\n%s", fixed)
+	}
+	if out, _ := outputCommand(t, c.Meta); out != "" {
+		t.Fatalf("should not output the template: %s", out)
+	}
+}
+
+func TestFix_diff(t *testing.T) {
+	c := &FixCommand{
+		Meta: testMeta(t),
+	}
+
+	path := filepath.Join(testFixture("fix"), "template.json")
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if code := c.Run([]string{"-diff", path}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(out, "--- "+path) || !strings.Contains(out, `+    "builders": [`) {
+		t.Fatalf("bad: %s", out)
+	}
+
+	// The template isn't written
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(after) != string(original) {
+		t.Fatal("template should not be modified")
+	}
+}
+
+func TestFix_plugin(t *testing.T) {
+	fixer := &packer.MockFixer{
+		FixOutput: map[string]interface{}{
+			"builders": []interface{}{
+				map[string]interface{}{"type": "dummy"},
+			},
+		},
+	}
+	meta := testMeta(t)
+	meta.CoreConfig.Components.Fixer = func(name string) (packer.Fixer, error) {
+		if name != "custom" {
+			return nil, nil
+		}
+		return fixer, nil
+	}
+	meta.CoreConfig.Components.FixerNames = []string{"custom"}
+
+	c := &FixCommand{Meta: meta}
+	args := []string{filepath.Join(testFixture("fix"), "template.json")}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	if !fixer.FixCalled {
+		t.Fatal("the fixer plugin should be called")
+	}
+	if _, ok := fixer.FixInput["push"]; !ok {
+		t.Fatalf("bad: %#v", fixer.FixInput)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	if strings.Contains(out, "push") {
+		t.Fatalf("bad: %s", out)
+	}
+}
@@ -0,0 +1,284 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/common/history"
+)
+
+// HistoryCommand lists and shows the jobs of packer build and packer serve
+// recorded in the history, and prunes it.
+type HistoryCommand struct {
+	Meta
+}
+
+func (c *HistoryCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.Ui.Say(c.Help())
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		return c.list(args[1:])
+	case "show":
+		return c.show(args[1:])
+	case "logs":
+		return c.logs(args[1:])
+	case "prune":
+		return c.prune(args[1:])
+	default:
+		c.Ui.Say(c.Help())
+		return 1
+	}
+}
+
+func (c *HistoryCommand) list(args []string) int {
+	var cfgBuild, cfgState string
+	var cfgLimit int
+	flags := c.Meta.FlagSet("history list", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&cfgBuild, "build", "", "")
+	flags.IntVar(&cfgLimit, "limit", 20, "")
+	flags.StringVar(&cfgState, "state", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	jobs, err := history.Jobs()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the history: %s", err))
+		return 1
+	}
+
+	var matching []*history.Job
+	for _, job := range jobs {
+		if cfgState != "" && job.State != cfgState {
+			continue
+		}
+		if cfgBuild != "" && !jobHasBuild(job, cfgBuild) {
+			continue
+		}
+		matching = append(matching, job)
+	}
+	if cfgLimit > 0 && len(matching) > cfgLimit {
+		matching = matching[len(matching)-cfgLimit:]
+	}
+	if len(matching) == 0 {
+		c.Ui.Say("No jobs found in the history.")
+		return 0
+	}
+
+	// The most recent jobs first
+	for i := len(matching) - 1; i >= 0; i-- {
+		job := matching[i]
+		names := make([]string, len(job.Builds))
+		for i, b := range job.Builds {
+			names[i] = b.Name
+		}
+
+		c.Ui.Machine("history-job", job.ID, job.Command, job.State,
+			strconv.FormatInt(job.Started.Unix(), 10),
+			strconv.FormatFloat(job.Duration().Seconds(), 'f', 3, 64),
+			strings.Join(names, ";"))
+		c.Ui.Say(fmt.Sprintf("%-8s  %s  %8s  %-11s  %s",
+			shortJobID(job.ID), job.Started.Local().Format("2006-01-02 15:04:05"),
+			formatTiming(job.Duration()), job.State, strings.Join(names, ", ")))
+	}
+
+	return 0
+}
+
+func jobHasBuild(job *history.Job, name string) bool {
+	for _, b := range job.Builds {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// shortJobID returns the first characters of the ID of a job, which are
+// enough to tell it apart in most histories.
+func shortJobID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func (c *HistoryCommand) job(name string, args []string) (*history.Job, bool) {
+	flags := c.Meta.FlagSet("history "+name, FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return nil, false
+	}
+	if len(flags.Args()) != 1 {
+		flags.Usage()
+		return nil, false
+	}
+
+	job, err := history.Get(flags.Arg(0))
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return nil, false
+	}
+	return job, true
+}
+
+func (c *HistoryCommand) show(args []string) int {
+	job, ok := c.job("show", args)
+	if !ok {
+		return 1
+	}
+
+	c.Ui.Say(fmt.Sprintf("Job %s (packer %s)", job.ID, job.Command))
+	if job.Template != "" {
+		c.Ui.Say(fmt.Sprintf("  Template: %s", job.Template))
+	}
+	c.Ui.Say(fmt.Sprintf("  State:    %s", job.State))
+	c.Ui.Say(fmt.Sprintf("  Started:  %s", job.Started.Local().Format(time.RFC3339)))
+	c.Ui.Say(fmt.Sprintf("  Duration: %s", formatTiming(job.Duration())))
+
+	for _, b := range job.Builds {
+		c.Ui.Machine("history-build", b.Name, b.State,
+			strconv.FormatFloat(b.Duration().Seconds(), 'f', 3, 64), b.Error)
+
+		c.Ui.Say(fmt.Sprintf("\n==> %s: %s in %s", b.Name, b.State, formatTiming(b.Duration())))
+		if b.Error != "" {
+			c.Ui.Say(fmt.Sprintf("    Error: %s", b.Error))
+		}
+		for i, a := range b.Artifacts {
+			c.Ui.Machine("history-artifact", b.Name, strconv.Itoa(i), a.BuilderId, a.Id)
+			c.Ui.Say(fmt.Sprintf("    Artifact: %s", a.String))
+			for _, f := range a.Files {
+				c.Ui.Say(fmt.Sprintf("      %s", f))
+			}
+		}
+	}
+
+	return 0
+}
+
+func (c *HistoryCommand) logs(args []string) int {
+	job, ok := c.job("logs", args)
+	if !ok {
+		return 1
+	}
+
+	r, err := job.Output()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the output of job %s: %s", job.ID, err))
+		return 1
+	}
+	defer r.Close()
+
+	output, err := ioutil.ReadAll(r)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the output of job %s: %s", job.ID, err))
+		return 1
+	}
+	if len(output) > 0 {
+		c.Ui.Say(strings.TrimRight(string(output), "\n"))
+	}
+	return 0
+}
+
+func (c *HistoryCommand) prune(args []string) int {
+	var cfgOlderThan string
+	var cfgKeep int
+	flags := c.Meta.FlagSet("history prune", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.IntVar(&cfgKeep, "keep", -1, "")
+	flags.StringVar(&cfgOlderThan, "older-than", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 0 || (cfgOlderThan == "" && cfgKeep < 0) {
+		flags.Usage()
+		return 1
+	}
+
+	var olderThan time.Duration
+	if cfgOlderThan != "" {
+		var err error
+		if olderThan, err = parseAge(cfgOlderThan); err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -older-than: %s", err))
+			return 1
+		}
+	}
+
+	jobs, err := history.Jobs()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading the history: %s", err))
+		return 1
+	}
+
+	// Jobs that are still running are never removed
+	cutoff := time.Now().Add(-olderThan)
+	removed, failed := 0, 0
+	for i, job := range jobs {
+		old := cfgOlderThan != "" && job.Started.Before(cutoff)
+		extra := cfgKeep >= 0 && i < len(jobs)-cfgKeep
+		if (!old && !extra) || job.State == history.StateRunning || job.State == history.StatePending {
+			continue
+		}
+
+		if err := job.Remove(); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error removing job %s: %s", job.ID, err))
+			failed++
+			continue
+		}
+		c.Ui.Machine("history-removed", job.ID)
+		removed++
+	}
+
+	c.Ui.Say(fmt.Sprintf("Removed %d job(s) from the history.", removed))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (*HistoryCommand) Help() string {
+	helpText := `
+Usage: packer history <subcommand> [options] [JOB]
+
+  Lists and shows the jobs of packer build and packer serve, with their
+  builds, how long they took, their artifacts and their output. Jobs can
+  be referred to by the first characters of their ID.
+
+  list     Lists the most recent jobs, the most recent first.
+  show     Shows the builds of a job, with their errors and artifacts.
+  logs     Prints the output of the builds of a job.
+  prune    Removes the jobs older than -older-than, and all but the -keep
+           most recent jobs. Running jobs are kept.
+
+List Options:
+
+  -build=name            Only list the jobs that ran this build.
+  -limit=20              The number of jobs to list, 0 for all.
+  -state=state           Only list the jobs in this state: succeeded,
+                         failed, cancelled, interrupted or running.
+
+Prune Options:
+
+  -older-than=duration   Remove the jobs started this long ago, like 72h
+                         or 30d.
+  -keep=n                Keep only the n most recent jobs.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*HistoryCommand) Synopsis() string {
+	return "list and show past builds"
+}
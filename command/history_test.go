@@ -0,0 +1,66 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/common/history"
+)
+
+func TestHistoryCommand(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	old := os.Getenv(history.EnvHistoryDir)
+	os.Setenv(history.EnvHistoryDir, td)
+	defer os.Setenv(history.EnvHistoryDir, old)
+
+	build := &BuildCommand{Meta: testMetaFile(t)}
+	defer cleanup()
+	args := []string{"-only=chocolate", filepath.Join(testFixture("build-only"), "template.json")}
+	if code := build.Run(args); code != 0 {
+		fatalCommand(t, build.Meta)
+	}
+
+	jobs, err := history.Jobs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(jobs) != 1 || jobs[0].State != history.StateSucceeded {
+		t.Fatalf("bad: %#v", jobs)
+	}
+	id := jobs[0].ID
+
+	cases := []struct {
+		Args   []string
+		Output []string
+	}{
+		{[]string{"list"}, []string{shortJobID(id), "succeeded", "chocolate"}},
+		{[]string{"list", "-state=failed"}, []string{"No jobs found"}},
+		{[]string{"show", id[:6]}, []string{"Job " + id + " (packer build)", "==> chocolate: succeeded", "Artifact: Stored file: chocolate.txt"}},
+		{[]string{"logs", id}, []string{"Build 'chocolate' finished."}},
+		{[]string{"prune", "-keep=0"}, []string{"Removed 1 job(s)"}},
+	}
+	for _, tc := range cases {
+		c := &HistoryCommand{Meta: testMeta(t)}
+		if code := c.Run(tc.Args); code != 0 {
+			fatalCommand(t, c.Meta)
+		}
+		out, _ := outputCommand(t, c.Meta)
+		for _, s := range tc.Output {
+			if !strings.Contains(out, s) {
+				t.Fatalf("%v: output should contain %q:\n%s", tc.Args, s, out)
+			}
+		}
+	}
+
+	c := &HistoryCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"show", id}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template"
+)
+
+type InitCommand struct {
+	Meta
+}
+
+func (c *InitCommand) Run(args []string) int {
+	var cfgPluginDir string
+	flags := c.Meta.FlagSet("init", FlagSetVars)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&cfgPluginDir, "plugin-dir", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	// Parse the template
+	tpl, err := template.ParseFile(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to parse template: %s", err))
+		return 1
+	}
+
+	// Get the core, this validates and interpolates the required plugins
+	if _, err := c.Meta.Core(tpl); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	if len(tpl.RequiredPlugins) == 0 {
+		c.Ui.Say("The template doesn't require any plugins.")
+		return 0
+	}
+
+	if cfgPluginDir == "" {
+		dir, err := packer.ConfigDir()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error finding plugin directory: %s", err))
+			return 1
+		}
+		cfgPluginDir = filepath.Join(dir, "plugins")
+	}
+
+	if err := os.MkdirAll(cfgPluginDir, 0755); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating plugin directory: %s", err))
+		return 1
+	}
+
+	names := make([]string, 0, len(tpl.RequiredPlugins))
+	for name := range tpl.RequiredPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := tpl.RequiredPlugins[name]
+		c.Ui.Say(fmt.Sprintf("Installing plugin %s from %s", name, p.Source))
+		path, err := installPlugin(p, cfgPluginDir)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error installing plugin %s: %s", name, err))
+			return 1
+		}
+		c.Ui.Say(fmt.Sprintf("Installed plugin %s to %s", name, path))
+	}
+
+	return 0
+}
+
+// installPlugin downloads the plugin into dir, verifying its checksum.
+// Nothing is downloaded if the plugin is already installed with the
+// expected checksum.
+func installPlugin(p *template.RequiredPlugin, dir string) (string, error) {
+	checksum, err := hex.DecodeString(strings.ToLower(p.Checksum))
+	if err != nil {
+		return "", fmt.Errorf("invalid checksum: %s", err)
+	}
+
+	url, err := common.DownloadableURL(p.Source)
+	if err != nil {
+		return "", err
+	}
+
+	name := p.Name
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	target := filepath.Join(dir, name)
+
+	client := common.NewDownloadClient(&common.DownloadConfig{
+		Url:        url,
+		TargetPath: target,
+		Hash:       common.HashForType(p.ChecksumType),
+		Checksum:   checksum,
+		UserAgent:  "Packer",
+	})
+
+	path, err := client.Get()
+	if err != nil {
+		return "", err
+	}
+
+	// Local sources are verified in place, so copy them over.
+	if path != target {
+		if err := copyPlugin(path, target); err != nil {
+			return "", err
+		}
+	}
+
+	return target, os.Chmod(target, 0755)
+}
+
+func copyPlugin(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func (*InitCommand) Help() string {
+	helpText := `
+Usage: packer init [options] TEMPLATE
+
+  Installs the plugins listed in the "required_plugins" section of the
+  template into the plugins directory. Every plugin is verified against
+  its checksum. Plugins that are already installed with a matching
+  checksum are not downloaded again.
+
+Options:
+
+  -plugin-dir=path           Install plugins into this directory instead of
+                             the plugins directory in the Packer config
+                             directory.
+  -var 'key=value'           Variable for templates, can be used multiple times.
+  -var-file=path             JSON file containing user variables.
+  -var-dir=path              Directory of layered var files, see 'packer vars'.
+  -profile=name              Var file of the -var-dir to layer, e.g. prod.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*InitCommand) Synopsis() string {
+	return "install the plugins required by a template"
+}
@@ -0,0 +1,66 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestInitCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &InitCommand{
+		Meta: testMeta(t),
+	}
+	args := []string{
+		"-plugin-dir", dir,
+		filepath.Join(testFixture("init"), "template.json"),
+	}
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	name := "packer-provisioner-test"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+		t.Fatalf("plugin should be installed: %s", err)
+	}
+
+	// Running again should find the installed plugin
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+}
+
+func TestInitCommand_badChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &InitCommand{
+		Meta: testMeta(t),
+	}
+	args := []string{
+		"-plugin-dir", dir,
+		filepath.Join(testFixture("init"), "bad-checksum.json"),
+	}
+
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "packer-provisioner-test")); err == nil {
+		t.Fatal("plugin should not be installed")
+	}
+}
@@ -1,10 +1,12 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 
+	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template"
 )
 
@@ -13,8 +15,10 @@ type InspectCommand struct {
 }
 
 func (c *InspectCommand) Run(args []string) int {
-	flags := c.Meta.FlagSet("inspect", FlagSetNone)
+	var cfgJSON bool
+	flags := c.Meta.FlagSet("inspect", FlagSetBuildFilter|FlagSetVars)
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.BoolVar(&cfgJSON, "json", false, "output json")
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
@@ -32,6 +36,10 @@ func (c *InspectCommand) Run(args []string) int {
 		return 1
 	}
 
+	if cfgJSON {
+		return c.inspectJSON(tpl)
+	}
+
 	// Convenience...
 	ui := c.Ui
 
@@ -81,10 +89,15 @@ func (c *InspectCommand) Run(args []string) int {
 				continue
 			}
 
+			def := v.Default
+			if v.Sensitive {
+				def = packer.RedactedValue
+			}
+
 			padding := strings.Repeat(" ", max-len(k))
-			output := fmt.Sprintf("  %s%s = %s", k, padding, v.Default)
+			output := fmt.Sprintf("  %s%s = %s", k, padding, def)
 
-			ui.Machine("template-variable", k, v.Default, "0")
+			ui.Machine("template-variable", k, def, "0")
 			ui.Say(output)
 		}
 	}
@@ -141,17 +154,70 @@ func (c *InspectCommand) Run(args []string) int {
 	return 0
 }
 
+// inspectJSON outputs the decoded configuration of the builds as JSON.
+func (c *InspectCommand) inspectJSON(tpl *template.Template) int {
+	core, err := c.Meta.Core(tpl)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	buildNames := c.Meta.BuildNames(core)
+	sort.Strings(buildNames)
+
+	builds := make([]*packer.InspectedBuild, 0, len(buildNames))
+	for _, n := range buildNames {
+		b, err := core.Inspect(n)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to inspect build '%s': %s", n, err))
+			return 1
+		}
+
+		builds = append(builds, b)
+	}
+
+	output := struct {
+		Description string                   `json:"description"`
+		Variables   map[string]string        `json:"variables"`
+		Builds      []*packer.InspectedBuild `json:"builds"`
+	}{
+		Description: tpl.Description,
+		Variables:   core.InspectVariables(),
+		Builds:      builds,
+	}
+
+	out, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to encode configuration: %s", err))
+		return 1
+	}
+
+	c.Ui.Say(string(out))
+	return 0
+}
+
 func (*InspectCommand) Help() string {
 	helpText := `
-Usage: packer inspect TEMPLATE
+Usage: packer inspect [options] TEMPLATE
 
   Inspects a template, parsing and outputting the components a template
   defines. This does not validate the contents of a template (other than
   basic syntax by necessity).
 
+  With -json, every build is prepared and the configuration its builder,
+  provisioners and post-processors decoded is output as JSON, with their
+  defaults applied and the values of sensitive variables redacted.
+
 Options:
 
-  -machine-readable  Machine-readable output
+  -json                  Output the decoded configuration as JSON
+  -machine-readable      Machine-readable output
+  -except=foo,bar,baz    Only with -json, inspect all builds other than these
+  -only=foo,bar,baz      Only with -json, inspect only these builds
+  -var 'key=value'       Variable for templates, can be used multiple times.
+  -var-file=path         JSON file containing user variables.
+  -var-dir=path          Directory of layered var files, see 'packer vars'.
+  -profile=name          Var file of the -var-dir to layer, e.g. prod.
 `
 
 	return strings.TrimSpace(helpText)
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	shelllocal "github.com/hashicorp/packer/provisioner/shell-local"
+)
+
+func TestInspectCommand_json(t *testing.T) {
+	c := &InspectCommand{
+		Meta: testMetaFile(t),
+	}
+	c.Meta.CoreConfig.Components.Provisioner = func(n string) (packer.Provisioner, error) {
+		return &shelllocal.Provisioner{}, nil
+	}
+
+	args := []string{
+		"-json",
+		"-only=vanilla",
+		filepath.Join(testFixture("inspect"), "template.json"),
+	}
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	stdout, _ := outputCommand(t, c.Meta)
+	var output struct {
+		Variables map[string]string
+		Builds    []*packer.InspectedBuild
+	}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("err: %s\n\n%s", err, stdout)
+	}
+
+	if output.Variables["password"] != packer.RedactedValue {
+		t.Fatalf("bad: %#v", output.Variables)
+	}
+	if len(output.Builds) != 1 || output.Builds[0].Name != "vanilla" {
+		t.Fatalf("bad: %#v", output.Builds)
+	}
+
+	b := output.Builds[0]
+	if !b.Decoded || b.Config["target"] != "vanilla.txt" {
+		t.Fatalf("bad: %#v", b.Config)
+	}
+	if _, ok := b.Config["packer_build_name"]; ok {
+		t.Fatalf("bad: %#v", b.Config)
+	}
+
+	// The defaults of the provisioner are applied
+	p := b.Provisioners[0]
+	if !p.Decoded || p.Config["command"] != "echo "+packer.RedactedValue {
+		t.Fatalf("bad: %#v", p.Config)
+	}
+	if codes := p.Config["valid_exit_codes"].([]interface{}); len(codes) != 1 {
+		t.Fatalf("bad: %#v", p.Config)
+	}
+}
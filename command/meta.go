@@ -2,9 +2,13 @@ package command
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/hashicorp/packer/helper/flag-kv"
 	"github.com/hashicorp/packer/helper/flag-slice"
@@ -20,6 +24,8 @@ const (
 	FlagSetNone        FlagSetFlags = 0
 	FlagSetBuildFilter FlagSetFlags = 1 << iota
 	FlagSetVars
+	FlagSetPolicy
+	FlagSetStrict
 )
 
 // Meta contains the meta-options and functionality that nearly every
@@ -30,10 +36,22 @@ type Meta struct {
 	Ui         packer.Ui
 	Version    string
 
+	// Interactive is true if the user can be prompted for the values of
+	// the required variables that aren't set.
+	Interactive bool
+
 	// These are set by command-line flags
 	flagBuildExcept []string
 	flagBuildOnly   []string
 	flagVars        map[string]string
+	flagVarDir      string
+	flagProfile     string
+	flagPolicies    []string
+	flagStrict      bool
+
+	// varSources are the var files the variables of the var dir were set
+	// by, once it is loaded.
+	varSources map[string]string
 }
 
 // Core returns the core for the given template given the configured
@@ -42,7 +60,18 @@ func (m *Meta) Core(tpl *template.Template) (*packer.Core, error) {
 	// Copy the config so we don't modify it
 	config := *m.CoreConfig
 	config.Template = tpl
+	if err := m.loadVarDir(); err != nil {
+		return nil, err
+	}
 	config.Variables = m.flagVars
+	config.Strict = config.Strict || m.flagStrict
+	if m.Interactive {
+		vars, err := m.promptVariables(tpl, m.flagVars)
+		if err != nil {
+			return nil, err
+		}
+		config.Variables = vars
+	}
 
 	// Init the core
 	core, err := packer.NewCore(&config)
@@ -53,6 +82,114 @@ func (m *Meta) Core(tpl *template.Template) (*packer.Core, error) {
 	return core, nil
 }
 
+// VarDirLayers returns the var files of a var dir, in the order they are
+// merged: defaults.json, the file of the profile, and local.json, which
+// is meant for the overrides of the user and is usually not committed.
+func VarDirLayers(dir, profile string) []kvflag.Layer {
+	layers := []kvflag.Layer{{Path: filepath.Join(dir, "defaults.json"), Optional: true}}
+	if profile != "" {
+		layers = append(layers, kvflag.Layer{Path: filepath.Join(dir, profile+".json")})
+	}
+	return append(layers, kvflag.Layer{Path: filepath.Join(dir, "local.json"), Optional: true})
+}
+
+// loadVarDir merges the var files of the var dir into the variables that
+// aren't set by -var or -var-file, which override them.
+func (m *Meta) loadVarDir() error {
+	if m.varSources != nil {
+		return nil
+	}
+	if m.flagVarDir == "" {
+		if m.flagProfile != "" {
+			return errors.New("-profile can only be used with -var-dir")
+		}
+		return nil
+	}
+
+	if info, err := os.Stat(m.flagVarDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("-var-dir %s isn't a directory", m.flagVarDir)
+	}
+
+	// The profile defaults to the environment, so that it is set once
+	// for a shell
+	profile := m.flagProfile
+	if profile == "" {
+		profile = os.Getenv("PACKER_PROFILE")
+	}
+
+	vars, sources, err := kvflag.MergeLayers(VarDirLayers(m.flagVarDir, profile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("profile '%s' not found in %s", profile, m.flagVarDir)
+		}
+		return fmt.Errorf("Error loading -var-dir: %s", err)
+	}
+
+	if m.flagVars == nil {
+		m.flagVars = make(map[string]string)
+	}
+	m.varSources = make(map[string]string)
+	for k, v := range vars {
+		if _, ok := m.flagVars[k]; !ok {
+			m.flagVars[k] = v
+			m.varSources[k] = sources[k]
+		}
+	}
+
+	return nil
+}
+
+// promptVariables asks the user for the values of the required variables
+// that aren't set, until they are valid. Variables left empty stay unset,
+// so that the core reports them.
+func (m *Meta) promptVariables(tpl *template.Template, vars map[string]string) (map[string]string, error) {
+	var names []string
+	for n, v := range tpl.Variables {
+		if _, ok := vars[n]; v.Required && !ok {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return vars, nil
+	}
+	sort.Strings(names)
+
+	result := make(map[string]string, len(vars)+len(names))
+	for k, v := range vars {
+		result[k] = v
+	}
+
+	for _, n := range names {
+		v := tpl.Variables[n]
+		query := fmt.Sprintf("Value for required variable '%s'", n)
+		if v.Description != "" {
+			query += fmt.Sprintf(" (%s)", v.Description)
+		}
+		if v.Type != "" && v.Type != template.VariableTypeString {
+			query += fmt.Sprintf(" [%s]", v.Type)
+		}
+
+		for {
+			value, err := m.Ui.Ask(query + ":")
+			if err != nil {
+				return nil, fmt.Errorf("Error asking for variable '%s': %s", n, err)
+			}
+			if value == "" {
+				break
+			}
+			if _, err := v.Check(value); err != nil {
+				m.Ui.Error(fmt.Sprintf("Invalid value for variable '%s': %s", n, err))
+				continue
+			}
+
+			result[n] = value
+			break
+		}
+	}
+
+	return result, nil
+}
+
 // BuildNames returns the list of builds that are in the given core
 // that we care about taking into account the only and except flags.
 func (m *Meta) BuildNames(c *packer.Core) []string {
@@ -119,6 +256,19 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 	if fs&FlagSetVars != 0 {
 		f.Var((*kvflag.Flag)(&m.flagVars), "var", "")
 		f.Var((*kvflag.FlagJSON)(&m.flagVars), "var-file", "")
+		f.StringVar(&m.flagVarDir, "var-dir", "", "")
+		f.StringVar(&m.flagProfile, "profile", "", "")
+	}
+
+	// FlagSetPolicy tells us to check templates against policies
+	if fs&FlagSetPolicy != 0 {
+		f.Var((*sliceflag.StringFlag)(&m.flagPolicies), "policy", "")
+	}
+
+	// FlagSetStrict tells us to reject what templates otherwise get away
+	// with
+	if fs&FlagSetStrict != 0 {
+		f.BoolVar(&m.flagStrict, "strict", false, "")
 	}
 
 	// Create an io.Writer that writes to our Ui properly for errors.
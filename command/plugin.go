@@ -20,11 +20,13 @@ import (
 	amazonebsvolumebuilder "github.com/hashicorp/packer/builder/amazon/ebsvolume"
 	amazoninstancebuilder "github.com/hashicorp/packer/builder/amazon/instance"
 	azurearmbuilder "github.com/hashicorp/packer/builder/azure/arm"
+	azurechrootbuilder "github.com/hashicorp/packer/builder/azure/chroot"
 	cloudstackbuilder "github.com/hashicorp/packer/builder/cloudstack"
 	digitaloceanbuilder "github.com/hashicorp/packer/builder/digitalocean"
 	dockerbuilder "github.com/hashicorp/packer/builder/docker"
 	filebuilder "github.com/hashicorp/packer/builder/file"
 	googlecomputebuilder "github.com/hashicorp/packer/builder/googlecompute"
+	googlecomputechrootbuilder "github.com/hashicorp/packer/builder/googlecompute/chroot"
 	hypervisobuilder "github.com/hashicorp/packer/builder/hyperv/iso"
 	lxcbuilder "github.com/hashicorp/packer/builder/lxc"
 	lxdbuilder "github.com/hashicorp/packer/builder/lxd"
@@ -35,6 +37,7 @@ import (
 	parallelsisobuilder "github.com/hashicorp/packer/builder/parallels/iso"
 	parallelspvmbuilder "github.com/hashicorp/packer/builder/parallels/pvm"
 	profitbricksbuilder "github.com/hashicorp/packer/builder/profitbricks"
+	proxmoxbuilder "github.com/hashicorp/packer/builder/proxmox"
 	qemubuilder "github.com/hashicorp/packer/builder/qemu"
 	tritonbuilder "github.com/hashicorp/packer/builder/triton"
 	virtualboxisobuilder "github.com/hashicorp/packer/builder/virtualbox/iso"
@@ -45,31 +48,42 @@ import (
 	amazonimportpostprocessor "github.com/hashicorp/packer/post-processor/amazon-import"
 	artificepostprocessor "github.com/hashicorp/packer/post-processor/artifice"
 	atlaspostprocessor "github.com/hashicorp/packer/post-processor/atlas"
+	azurevhdpostprocessor "github.com/hashicorp/packer/post-processor/azure-vhd"
 	checksumpostprocessor "github.com/hashicorp/packer/post-processor/checksum"
 	compresspostprocessor "github.com/hashicorp/packer/post-processor/compress"
+	diskconvertpostprocessor "github.com/hashicorp/packer/post-processor/disk-convert"
 	dockerimportpostprocessor "github.com/hashicorp/packer/post-processor/docker-import"
 	dockerpushpostprocessor "github.com/hashicorp/packer/post-processor/docker-push"
 	dockersavepostprocessor "github.com/hashicorp/packer/post-processor/docker-save"
 	dockertagpostprocessor "github.com/hashicorp/packer/post-processor/docker-tag"
 	googlecomputeexportpostprocessor "github.com/hashicorp/packer/post-processor/googlecompute-export"
+	imageretentionpostprocessor "github.com/hashicorp/packer/post-processor/image-retention"
 	manifestpostprocessor "github.com/hashicorp/packer/post-processor/manifest"
 	shelllocalpostprocessor "github.com/hashicorp/packer/post-processor/shell-local"
 	vagrantpostprocessor "github.com/hashicorp/packer/post-processor/vagrant"
 	vagrantcloudpostprocessor "github.com/hashicorp/packer/post-processor/vagrant-cloud"
 	vspherepostprocessor "github.com/hashicorp/packer/post-processor/vsphere"
 	vspheretemplatepostprocessor "github.com/hashicorp/packer/post-processor/vsphere-template"
+	vulnerabilityscanpostprocessor "github.com/hashicorp/packer/post-processor/vulnerability-scan"
 	ansibleprovisioner "github.com/hashicorp/packer/provisioner/ansible"
 	ansiblelocalprovisioner "github.com/hashicorp/packer/provisioner/ansible-local"
+	certificateprovisioner "github.com/hashicorp/packer/provisioner/certificate"
 	chefclientprovisioner "github.com/hashicorp/packer/provisioner/chef-client"
 	chefsoloprovisioner "github.com/hashicorp/packer/provisioner/chef-solo"
+	containerimagesprovisioner "github.com/hashicorp/packer/provisioner/container-images"
 	convergeprovisioner "github.com/hashicorp/packer/provisioner/converge"
 	fileprovisioner "github.com/hashicorp/packer/provisioner/file"
+	hardeningprovisioner "github.com/hashicorp/packer/provisioner/hardening"
+	localusersprovisioner "github.com/hashicorp/packer/provisioner/local-users"
 	powershellprovisioner "github.com/hashicorp/packer/provisioner/powershell"
 	puppetmasterlessprovisioner "github.com/hashicorp/packer/provisioner/puppet-masterless"
 	puppetserverprovisioner "github.com/hashicorp/packer/provisioner/puppet-server"
 	saltmasterlessprovisioner "github.com/hashicorp/packer/provisioner/salt-masterless"
+	servicesprovisioner "github.com/hashicorp/packer/provisioner/services"
 	shellprovisioner "github.com/hashicorp/packer/provisioner/shell"
 	shelllocalprovisioner "github.com/hashicorp/packer/provisioner/shell-local"
+	sysprepprovisioner "github.com/hashicorp/packer/provisioner/sysprep"
+	windowsregistryprovisioner "github.com/hashicorp/packer/provisioner/windows-registry"
 	windowsrestartprovisioner "github.com/hashicorp/packer/provisioner/windows-restart"
 	windowsshellprovisioner "github.com/hashicorp/packer/provisioner/windows-shell"
 )
@@ -79,49 +93,59 @@ type PluginCommand struct {
 }
 
 var Builders = map[string]packer.Builder{
-	"alicloud-ecs":        new(alicloudecsbuilder.Builder),
-	"amazon-chroot":       new(amazonchrootbuilder.Builder),
-	"amazon-ebs":          new(amazonebsbuilder.Builder),
-	"amazon-ebssurrogate": new(amazonebssurrogatebuilder.Builder),
-	"amazon-ebsvolume":    new(amazonebsvolumebuilder.Builder),
-	"amazon-instance":     new(amazoninstancebuilder.Builder),
-	"azure-arm":           new(azurearmbuilder.Builder),
-	"cloudstack":          new(cloudstackbuilder.Builder),
-	"digitalocean":        new(digitaloceanbuilder.Builder),
-	"docker":              new(dockerbuilder.Builder),
-	"file":                new(filebuilder.Builder),
-	"googlecompute":       new(googlecomputebuilder.Builder),
-	"hyperv-iso":          new(hypervisobuilder.Builder),
-	"lxc":                 new(lxcbuilder.Builder),
-	"lxd":                 new(lxdbuilder.Builder),
-	"null":                new(nullbuilder.Builder),
-	"oneandone":           new(oneandonebuilder.Builder),
-	"openstack":           new(openstackbuilder.Builder),
-	"oracle-oci":          new(oracleocibuilder.Builder),
-	"parallels-iso":       new(parallelsisobuilder.Builder),
-	"parallels-pvm":       new(parallelspvmbuilder.Builder),
-	"profitbricks":        new(profitbricksbuilder.Builder),
-	"qemu":                new(qemubuilder.Builder),
-	"triton":              new(tritonbuilder.Builder),
-	"virtualbox-iso":      new(virtualboxisobuilder.Builder),
-	"virtualbox-ovf":      new(virtualboxovfbuilder.Builder),
-	"vmware-iso":          new(vmwareisobuilder.Builder),
-	"vmware-vmx":          new(vmwarevmxbuilder.Builder),
+	"alicloud-ecs":         new(alicloudecsbuilder.Builder),
+	"amazon-chroot":        new(amazonchrootbuilder.Builder),
+	"amazon-ebs":           new(amazonebsbuilder.Builder),
+	"amazon-ebssurrogate":  new(amazonebssurrogatebuilder.Builder),
+	"amazon-ebsvolume":     new(amazonebsvolumebuilder.Builder),
+	"amazon-instance":      new(amazoninstancebuilder.Builder),
+	"azure-arm":            new(azurearmbuilder.Builder),
+	"azure-chroot":         new(azurechrootbuilder.Builder),
+	"cloudstack":           new(cloudstackbuilder.Builder),
+	"digitalocean":         new(digitaloceanbuilder.Builder),
+	"docker":               new(dockerbuilder.Builder),
+	"file":                 new(filebuilder.Builder),
+	"googlecompute":        new(googlecomputebuilder.Builder),
+	"googlecompute-chroot": new(googlecomputechrootbuilder.Builder),
+	"hyperv-iso":           new(hypervisobuilder.Builder),
+	"lxc":                  new(lxcbuilder.Builder),
+	"lxd":                  new(lxdbuilder.Builder),
+	"null":                 new(nullbuilder.Builder),
+	"oneandone":            new(oneandonebuilder.Builder),
+	"openstack":            new(openstackbuilder.Builder),
+	"oracle-oci":           new(oracleocibuilder.Builder),
+	"parallels-iso":        new(parallelsisobuilder.Builder),
+	"parallels-pvm":        new(parallelspvmbuilder.Builder),
+	"profitbricks":         new(profitbricksbuilder.Builder),
+	"proxmox":              new(proxmoxbuilder.Builder),
+	"qemu":                 new(qemubuilder.Builder),
+	"triton":               new(tritonbuilder.Builder),
+	"virtualbox-iso":       new(virtualboxisobuilder.Builder),
+	"virtualbox-ovf":       new(virtualboxovfbuilder.Builder),
+	"vmware-iso":           new(vmwareisobuilder.Builder),
+	"vmware-vmx":           new(vmwarevmxbuilder.Builder),
 }
 
 var Provisioners = map[string]packer.Provisioner{
 	"ansible":           new(ansibleprovisioner.Provisioner),
 	"ansible-local":     new(ansiblelocalprovisioner.Provisioner),
+	"certificate":       new(certificateprovisioner.Provisioner),
 	"chef-client":       new(chefclientprovisioner.Provisioner),
 	"chef-solo":         new(chefsoloprovisioner.Provisioner),
+	"container-images":  new(containerimagesprovisioner.Provisioner),
 	"converge":          new(convergeprovisioner.Provisioner),
 	"file":              new(fileprovisioner.Provisioner),
+	"hardening":         new(hardeningprovisioner.Provisioner),
+	"local-users":       new(localusersprovisioner.Provisioner),
 	"powershell":        new(powershellprovisioner.Provisioner),
 	"puppet-masterless": new(puppetmasterlessprovisioner.Provisioner),
 	"puppet-server":     new(puppetserverprovisioner.Provisioner),
 	"salt-masterless":   new(saltmasterlessprovisioner.Provisioner),
+	"services":          new(servicesprovisioner.Provisioner),
 	"shell":             new(shellprovisioner.Provisioner),
 	"shell-local":       new(shelllocalprovisioner.Provisioner),
+	"sysprep":           new(sysprepprovisioner.Provisioner),
+	"windows-registry":  new(windowsregistryprovisioner.Provisioner),
 	"windows-restart":   new(windowsrestartprovisioner.Provisioner),
 	"windows-shell":     new(windowsshellprovisioner.Provisioner),
 }
@@ -131,19 +155,23 @@ var PostProcessors = map[string]packer.PostProcessor{
 	"amazon-import":        new(amazonimportpostprocessor.PostProcessor),
 	"artifice":             new(artificepostprocessor.PostProcessor),
 	"atlas":                new(atlaspostprocessor.PostProcessor),
+	"azure-vhd":            new(azurevhdpostprocessor.PostProcessor),
 	"checksum":             new(checksumpostprocessor.PostProcessor),
 	"compress":             new(compresspostprocessor.PostProcessor),
+	"disk-convert":         new(diskconvertpostprocessor.PostProcessor),
 	"docker-import":        new(dockerimportpostprocessor.PostProcessor),
 	"docker-push":          new(dockerpushpostprocessor.PostProcessor),
 	"docker-save":          new(dockersavepostprocessor.PostProcessor),
 	"docker-tag":           new(dockertagpostprocessor.PostProcessor),
 	"googlecompute-export": new(googlecomputeexportpostprocessor.PostProcessor),
+	"image-retention":      new(imageretentionpostprocessor.PostProcessor),
 	"manifest":             new(manifestpostprocessor.PostProcessor),
 	"shell-local":          new(shelllocalpostprocessor.PostProcessor),
 	"vagrant":              new(vagrantpostprocessor.PostProcessor),
 	"vagrant-cloud":        new(vagrantcloudpostprocessor.PostProcessor),
 	"vsphere":              new(vspherepostprocessor.PostProcessor),
 	"vsphere-template":     new(vspheretemplatepostprocessor.PostProcessor),
+	"vulnerability-scan":   new(vulnerabilityscanpostprocessor.PostProcessor),
 }
 
 var pluginRegexp = regexp.MustCompile("packer-(builder|post-processor|provisioner)-(.+)")
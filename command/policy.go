@@ -0,0 +1,181 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// EnvPolicy is the policies every template is checked against, comma
+// separated. They are checked before the ones given with -policy, which
+// can't disable them, so that they can be enforced by the environment of
+// CI systems.
+const EnvPolicy = "PACKER_POLICY"
+
+// policyQuery is the document of the Rego policies that is evaluated.
+// Its deny rules block the build, its warn rules are printed.
+const policyQuery = "data.packer"
+
+// opaCommand is the OPA executable that evaluates local policies.
+var opaCommand = "opa"
+
+// policyInput is the input document of the policies: the template as
+// written, its user variables, and the resolved configuration of the
+// builds.
+type policyInput struct {
+	Template  interface{}              `json:"template"`
+	Variables map[string]string        `json:"variables"`
+	Builds    []*packer.InspectedBuild `json:"builds"`
+}
+
+// policyResult is the value of the policy document.
+type policyResult struct {
+	Deny []string `json:"deny"`
+	Warn []string `json:"warn"`
+}
+
+// policies returns the policies templates are checked against.
+func (m *Meta) policies() []string {
+	var result []string
+	for _, p := range strings.Split(os.Getenv(EnvPolicy), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return append(result, m.flagPolicies...)
+}
+
+// CheckPolicies checks the builds with the given names against the
+// policies. It prints their warnings and returns an error with the rules
+// the builds violate. Policies that can't be evaluated fail the check.
+func (m *Meta) CheckPolicies(core *packer.Core, names []string) error {
+	policies := m.policies()
+	if len(policies) == 0 {
+		return nil
+	}
+
+	input := &policyInput{
+		Variables: core.InspectVariables(),
+		Builds:    make([]*packer.InspectedBuild, 0, len(names)),
+	}
+	if len(core.Template.RawContents) > 0 {
+		if err := json.Unmarshal(core.Template.RawContents, &input.Template); err != nil {
+			return fmt.Errorf("Error reading the template for policies: %s", err)
+		}
+	}
+	for _, n := range names {
+		b, err := core.Inspect(n)
+		if err != nil {
+			return err
+		}
+		input.Builds = append(input.Builds, b)
+	}
+
+	var errs *packer.MultiError
+	for _, p := range policies {
+		result, err := evalPolicy(p, input)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("Error evaluating policy %s: %s", p, err))
+			continue
+		}
+
+		for _, w := range result.Warn {
+			m.Ui.Say(fmt.Sprintf("Policy warning (%s): %s", p, w))
+		}
+		for _, d := range result.Deny {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("%s: %s", p, d))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return fmt.Errorf("Template violates policies. %s", errs)
+	}
+	return nil
+}
+
+// evalPolicy evaluates a policy: the URL of the document of an OPA
+// server, or Rego files evaluated with the opa executable.
+func evalPolicy(policy string, input *policyInput) (*policyResult, error) {
+	if strings.HasPrefix(policy, "http://") || strings.HasPrefix(policy, "https://") {
+		return evalPolicyServer(policy, input)
+	}
+	return evalPolicyFiles(policy, input)
+}
+
+// evalPolicyServer evaluates the policy document at the URL of the data
+// API of an OPA server, e.g. http://localhost:8181/v1/data/packer.
+func evalPolicyServer(url string, input *policyInput) (*policyResult, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+
+	var response struct {
+		Result *policyResult `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("bad response: %s", err)
+	}
+	if response.Result == nil {
+		return nil, fmt.Errorf("the document at %s is undefined", url)
+	}
+	return response.Result, nil
+}
+
+// evalPolicyFiles evaluates the Rego files of a file or directory.
+func evalPolicyFiles(path string, input *policyInput) (*policyResult, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(opaCommand); err != nil {
+		return nil, fmt.Errorf("the opa executable, required for local policies, wasn't found: %s", err)
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(opaCommand, "eval",
+		"--format", "json", "--stdin-input", "--data", path, policyQuery)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+
+	var output struct {
+		Result []struct {
+			Expressions []struct {
+				Value *policyResult `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("bad output of opa: %s", err)
+	}
+	if len(output.Result) == 0 || len(output.Result[0].Expressions) == 0 ||
+		output.Result[0].Expressions[0].Value == nil {
+		return nil, fmt.Errorf("%s is undefined, the policies must be in package packer", policyQuery)
+	}
+	return output.Result[0].Expressions[0].Value, nil
+}
@@ -0,0 +1,171 @@
+package command
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testPolicyServer returns an OPA server that denies the builds of the
+// file builder writing vanilla.txt.
+func testPolicyServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/data/packer" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			Input policyInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("bad input: %s", err)
+		}
+		if _, ok := body.Input.Template.(map[string]interface{})["builders"]; !ok {
+			t.Errorf("bad template: %#v", body.Input.Template)
+		}
+
+		result := &policyResult{Warn: []string{"builds should be signed"}}
+		for _, b := range body.Input.Builds {
+			if b.Config["target"] == "vanilla.txt" {
+				result.Deny = append(result.Deny, "vanilla isn't allowed in "+b.Name)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}))
+}
+
+func TestBuildPolicy(t *testing.T) {
+	server := testPolicyServer(t)
+	defer server.Close()
+
+	defer cleanup()
+
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		"-policy=" + server.URL + "/v1/data/packer",
+		"-only=chocolate",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+	out, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(out, "Policy warning") || !strings.Contains(out, "builds should be signed") {
+		t.Fatalf("bad: %s", out)
+	}
+
+	c = &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+	args = []string{
+		"-policy=" + server.URL + "/v1/data/packer",
+		"-only=chocolate,vanilla",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+	os.Remove("chocolate.txt")
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "vanilla isn't allowed in vanilla") {
+		t.Fatalf("bad: %s", stderr)
+	}
+	if fileExists("chocolate.txt") || fileExists("vanilla.txt") {
+		t.Fatal("builds ran despite violating the policies")
+	}
+}
+
+func TestValidatePolicy_env(t *testing.T) {
+	server := testPolicyServer(t)
+	defer server.Close()
+
+	os.Setenv(EnvPolicy, server.URL+"/v1/data/packer")
+	defer os.Unsetenv(EnvPolicy)
+
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "vanilla isn't allowed") {
+		t.Fatalf("bad: %s", stderr)
+	}
+}
+
+func TestValidatePolicy_undefined(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		"-policy=" + server.URL + "/v1/data/packer",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "is undefined") {
+		t.Fatalf("bad: %s", stderr)
+	}
+}
+
+func TestEvalPolicyFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake opa is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The fake opa checks its arguments and reports a denial
+	opa := filepath.Join(dir, "opa")
+	script := `#!/bin/sh
+[ "$*" = "eval --format json --stdin-input --data policies data.packer" ] || exit 1
+grep -q '"builds"' || exit 1
+echo '{"result":[{"expressions":[{"value":{"deny":["no public images"]},"text":"data.packer"}]}]}'
+`
+	if err := ioutil.WriteFile(opa, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func(old string) { opaCommand = old }(opaCommand)
+	opaCommand = opa
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	os.Mkdir("policies", 0755)
+
+	result, err := evalPolicyFiles("policies", &policyInput{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(result.Deny) != 1 || result.Deny[0] != "no public images" {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	if _, err := evalPolicyFiles("missing", &policyInput{}); err == nil {
+		t.Fatal("should error")
+	}
+}
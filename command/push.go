@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/atlas-go/archive"
@@ -211,6 +212,24 @@ func (c *PushCommand) Run(args []string) int {
 		uploadOpts.SensitiveVars = svars
 	}
 
+	// Variables declared sensitive in the template are sensitive too
+	var declared []string
+	for k, v := range tpl.Variables {
+		if v.Sensitive {
+			declared = append(declared, k)
+		}
+	}
+	sort.Strings(declared)
+	for _, k := range declared {
+		found := false
+		for _, sv := range uploadOpts.SensitiveVars {
+			found = found || sv == k
+		}
+		if !found {
+			uploadOpts.SensitiveVars = append(uploadOpts.SensitiveVars, k)
+		}
+	}
+
 	uploadOpts.Vars = make(map[string]string)
 	if vs := flags.Lookup("var"); vs != nil {
 		f := vs.Value.(*kvflag.Flag)
@@ -315,6 +334,8 @@ Options:
   -var 'key=value'         Variable for templates, can be used multiple times.
 
   -var-file=path           JSON file containing user variables.
+  -var-dir=path            Directory of layered var files, see 'packer vars'.
+  -profile=name            Var file of the -var-dir to layer, e.g. prod.
 `
 
 	return strings.TrimSpace(helpText)
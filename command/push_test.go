@@ -220,19 +220,20 @@ func TestPush_vars(t *testing.T) {
 	}
 
 	expected := map[string]string{
-		"bar":        "baz",
-		"name":       "foo/bar",
-		"null":       "",
-		"one":        "two",
-		"overridden": "yes",
-		"super":      "this should be secret",
-		"secret":     "this one too",
+		"bar":         "baz",
+		"db_password": "",
+		"name":        "foo/bar",
+		"null":        "",
+		"one":         "two",
+		"overridden":  "yes",
+		"super":       "this should be secret",
+		"secret":      "this one too",
 	}
 	if !reflect.DeepEqual(actualOpts.Vars, expected) {
 		t.Fatalf("bad vars: got %#v\n expected %#v\n", actualOpts.Vars, expected)
 	}
 
-	expected_sensitive := []string{"super", "secret"}
+	expected_sensitive := []string{"super", "secret", "db_password"}
 	if !reflect.DeepEqual(actualOpts.SensitiveVars, expected_sensitive) {
 		t.Fatalf("bad vars: got %#v\n expected %#v\n", actualOpts.SensitiveVars, expected_sensitive)
 	}
@@ -0,0 +1,527 @@
+package command
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer/common/resources"
+	sliceflag "github.com/hashicorp/packer/helper/flag-slice"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template"
+)
+
+// EnvServeToken is the token clients of packer serve must send, if
+// -token isn't set. A random one is generated if neither is.
+const EnvServeToken = "PACKER_SERVE_TOKEN"
+
+// ServeCommand serves an HTTP API to run builds, so that services can
+// embed Packer without running it and parsing its output.
+type ServeCommand struct {
+	Meta
+}
+
+func (c *ServeCommand) Run(args []string) int {
+	var cfgAddress, cfgToken string
+	var cfgAllowHosts []string
+	var cfgMaxJobs int
+	flags := c.Meta.FlagSet("serve", FlagSetPolicy|FlagSetStrict)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&cfgAddress, "address", "127.0.0.1:8091", "")
+	flags.Var((*sliceflag.StringFlag)(&cfgAllowHosts), "allow-host", "")
+	flags.IntVar(&cfgMaxJobs, "max-jobs", 0, "")
+	flags.StringVar(&cfgToken, "token", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+	if cfgMaxJobs < 0 {
+		c.Ui.Error("-max-jobs must not be negative")
+		return 1
+	}
+	if cfgToken == "" {
+		cfgToken = os.Getenv(EnvServeToken)
+	}
+	generated := cfgToken == ""
+	if generated {
+		var err error
+		if cfgToken, err = newServeToken(); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error generating the token: %s", err))
+			return 1
+		}
+	}
+
+	l, err := net.Listen("tcp", cfgAddress)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error listening on %s: %s", cfgAddress, err))
+		return 1
+	}
+	hosts := append(serveHosts(l.Addr()), cfgAllowHosts...)
+	server := newBuildServer(c.Meta, cfgToken, cfgMaxJobs, hosts)
+
+	// The builds of every job are builds of this run
+	if err := resources.StartRun(); err != nil {
+		log.Printf("[WARN] Error recording the run: %s", err)
+	}
+	defer func() {
+		if err := resources.EndRun(); err != nil {
+			log.Printf("[WARN] Error removing the record of the run: %s", err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	go func() {
+		if err := http.Serve(l, server); err != nil {
+			log.Printf("[DEBUG] Stopped serving the build API: %s", err)
+		}
+	}()
+	c.Ui.Say(fmt.Sprintf("Serving the build API on http://%s", l.Addr()))
+	c.Ui.Machine("serve-address", l.Addr().String())
+	if generated {
+		c.Ui.Say(fmt.Sprintf("Token of the API, set -token or %s to choose it: %s",
+			EnvServeToken, cfgToken))
+		c.Ui.Machine("serve-token", cfgToken)
+	}
+
+	// Handle interrupts in two stages, like packer build. The first
+	// interrupt cancels the running jobs, which lets them clean up. The
+	// second one aborts them.
+	<-sigCh
+	l.Close()
+	c.Ui.Error("Interrupt received. Cancelling the running jobs and cleaning up, " +
+		"press Ctrl-C again to abort without cleaning up...")
+
+	doneCh := make(chan struct{})
+	go func() {
+		server.CancelAll()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+		c.Ui.Say("Cleanly cancelled the running jobs.")
+		return 0
+	case <-sigCh:
+		c.Ui.Error("Aborted the builds without cleaning up. " +
+			"Resources they created may have been left behind.")
+		return 1
+	}
+}
+
+// newServeToken generates a random token for the API.
+func newServeToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// serveHosts returns the values of the Host header of the requests for
+// the address the server listens on: the address itself, and localhost
+// for a loopback address.
+func serveHosts(addr net.Addr) []string {
+	hosts := []string{addr.String()}
+	if tcp, ok := addr.(*net.TCPAddr); ok && tcp.IP.IsLoopback() {
+		hosts = append(hosts, net.JoinHostPort("localhost", strconv.Itoa(tcp.Port)))
+	}
+	return hosts
+}
+
+// serveRequest is a job submitted to packer serve: the template to build,
+// inline or as a path on the machine of the server, and the options of
+// packer build.
+type serveRequest struct {
+	Template     json.RawMessage   `json:"template"`
+	TemplatePath string            `json:"template_path"`
+	Vars         map[string]string `json:"vars"`
+	Only         []string          `json:"only"`
+	Except       []string          `json:"except"`
+	Force        bool              `json:"force"`
+	OnError      string            `json:"on_error"`
+	Strict       bool              `json:"strict"`
+}
+
+// buildServer serves the API of packer serve.
+type buildServer struct {
+	meta  Meta
+	token string
+
+	// hosts are the values of the Host header, and the hosts of the
+	// Origin header, that the server accepts.
+	hosts map[string]bool
+
+	// slots limits the number of jobs running at once, if not nil. The
+	// other jobs wait in the queue, in the order they were submitted.
+	slots chan struct{}
+
+	l    sync.Mutex
+	jobs map[string]*serveJob
+	ids  []string
+}
+
+func newBuildServer(meta Meta, token string, maxJobs int, hosts []string) *buildServer {
+	s := &buildServer{
+		meta:  meta,
+		token: token,
+		hosts: make(map[string]bool),
+		jobs:  make(map[string]*serveJob),
+	}
+	for _, h := range hosts {
+		s.hosts[strings.ToLower(h)] = true
+	}
+	if maxJobs > 0 {
+		s.slots = make(chan struct{}, maxJobs)
+	}
+	return s
+}
+
+// Submit prepares the builds of a job and starts it. Errors of the
+// template and of the configuration of the builds are returned.
+func (s *buildServer) Submit(req *serveRequest) (*serveJob, error) {
+	switch req.OnError {
+	case "", "cleanup", "abort":
+	default:
+		return nil, fmt.Errorf("on_error must be cleanup or abort")
+	}
+
+	var tpl *template.Template
+	var err error
+	switch {
+	case len(req.Template) > 0 && req.TemplatePath != "":
+		return nil, errors.New("only one of template and template_path can be set")
+	case len(req.Template) > 0:
+		tpl, err = template.Parse(bytes.NewReader(req.Template))
+	case req.TemplatePath != "":
+		tpl, err = template.ParseFile(req.TemplatePath)
+	default:
+		return nil, errors.New("template or template_path must be set")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template: %s", err)
+	}
+
+	job, err := newServeJob()
+	if err != nil {
+		return nil, err
+	}
+	if req.TemplatePath != "" {
+		if job.template, err = filepath.Abs(req.TemplatePath); err != nil {
+			return nil, err
+		}
+	}
+
+	// The options of the request replace the flags of packer build
+	m := s.meta
+	m.Ui = &serveUi{job: job}
+	m.Interactive = false
+	m.flagVars = req.Vars
+	m.flagBuildOnly = req.Only
+	m.flagBuildExcept = req.Except
+	m.flagStrict = m.flagStrict || req.Strict
+	core, err := m.Core(tpl)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := core.OrderBuilds(m.BuildNames(core))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, errors.New("The template has no builds to run")
+	}
+	builds := make([]packer.Build, 0, len(names))
+	for _, n := range names {
+		b, err := core.Build(n)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to initialize build '%s': %s", n, err)
+		}
+		builds = append(builds, b)
+	}
+
+	if err := m.CheckPolicies(core, names); err != nil {
+		return nil, err
+	}
+
+	for _, b := range builds {
+		b.SetForce(req.Force)
+		b.SetOnError(req.OnError)
+		if len(core.BuildDependencies(b.Name())) > 0 {
+			continue
+		}
+		if err := prepareBuild(b, &serveUi{job: job, build: b.Name()}); err != nil {
+			return nil, err
+		}
+	}
+
+	s.l.Lock()
+	s.jobs[job.status.ID] = job
+	s.ids = append(s.ids, job.status.ID)
+	s.l.Unlock()
+
+	log.Printf("Starting job %s: %s", job.status.ID, strings.Join(names, ", "))
+	job.Start(core, builds, s.meta.Cache, s.slots)
+	return job, nil
+}
+
+// Job returns the job with the given ID, or nil.
+func (s *buildServer) Job(id string) *serveJob {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.jobs[id]
+}
+
+// Jobs returns the jobs in the order they were submitted.
+func (s *buildServer) Jobs() []*serveJob {
+	s.l.Lock()
+	defer s.l.Unlock()
+	jobs := make([]*serveJob, len(s.ids))
+	for i, id := range s.ids {
+		jobs[i] = s.jobs[id]
+	}
+	return jobs
+}
+
+// CancelAll cancels the jobs and waits for them to be done.
+func (s *buildServer) CancelAll() {
+	for _, job := range s.Jobs() {
+		job.Cancel()
+	}
+	for _, job := range s.Jobs() {
+		<-job.done
+	}
+}
+
+// ServeHTTP routes the requests of the API:
+//
+//	GET  /v1/jobs                                 lists the jobs
+//	POST /v1/jobs                                 submits a job
+//	GET  /v1/jobs/ID                              returns the state of a job
+//	POST /v1/jobs/ID/cancel                       cancels a job
+//	GET  /v1/jobs/ID/events                       streams the events of a job
+//	GET  /v1/jobs/ID/artifacts/BUILD/N/files/N    downloads a file of an artifact
+func (s *buildServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Web pages the user visits can send requests to the server, with
+	// the name of the page as Host if it is rebound to the address of
+	// the server in DNS. Only requests for the address of the server,
+	// and not from pages of other origins, are served.
+	if !s.hosts[strings.ToLower(r.Host)] {
+		serveError(w, http.StatusForbidden, fmt.Errorf("Host not allowed: %s", r.Host))
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !s.hosts[strings.ToLower(u.Host)] {
+			serveError(w, http.StatusForbidden, fmt.Errorf("Origin not allowed: %s", origin))
+			return
+		}
+	}
+
+	auth := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(auth, []byte("Bearer "+s.token)) != 1 {
+		serveError(w, http.StatusUnauthorized, errors.New("Invalid or missing token"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v1" || parts[1] != "jobs" {
+		serveError(w, http.StatusNotFound, fmt.Errorf("Not found: %s", r.URL.Path))
+		return
+	}
+	parts = parts[2:]
+
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			jobs := s.Jobs()
+			statuses := make([]*serveStatus, len(jobs))
+			for i, job := range jobs {
+				statuses[i] = job.Status()
+			}
+			serveJSON(w, http.StatusOK, map[string]interface{}{"jobs": statuses})
+		case http.MethodPost:
+			if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+				serveError(w, http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/json"))
+				return
+			}
+
+			var req serveRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				serveError(w, http.StatusBadRequest, fmt.Errorf("Error parsing the request: %s", err))
+				return
+			}
+			job, err := s.Submit(&req)
+			if err != nil {
+				serveError(w, http.StatusBadRequest, err)
+				return
+			}
+			serveJSON(w, http.StatusCreated, job.Status())
+		default:
+			serveError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed: %s", r.Method))
+		}
+		return
+	}
+
+	job := s.Job(parts[0])
+	if job == nil {
+		serveError(w, http.StatusNotFound, fmt.Errorf("No job %s", parts[0]))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		serveJSON(w, http.StatusOK, job.Status())
+	case len(parts) == 2 && parts[1] == "cancel" && r.Method == http.MethodPost:
+		if !job.Cancel() {
+			serveError(w, http.StatusConflict, errors.New("The job is already done"))
+			return
+		}
+		serveJSON(w, http.StatusAccepted, job.Status())
+	case len(parts) == 2 && parts[1] == "events" && r.Method == http.MethodGet:
+		serveEvents(w, r, job)
+	case len(parts) == 6 && parts[1] == "artifacts" && parts[4] == "files" && r.Method == http.MethodGet:
+		serveArtifactFile(w, r, job, parts[2], parts[3], parts[5])
+	default:
+		serveError(w, http.StatusNotFound, fmt.Errorf("Not found: %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// serveEvents writes the events of a job as JSON, one per line, from the
+// sequence number of the "from" parameter. Unless "follow" is false, it
+// waits for more events until the job is done.
+func serveEvents(w http.ResponseWriter, r *http.Request, job *serveJob) {
+	from := 0
+	if v := r.URL.Query().Get("from"); v != "" {
+		var err error
+		if from, err = strconv.Atoi(v); err != nil || from < 0 {
+			serveError(w, http.StatusBadRequest, fmt.Errorf("Invalid from: %s", v))
+			return
+		}
+	}
+	follow := r.URL.Query().Get("follow") != "false"
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for {
+		events, changed, done := job.Events(from)
+		for _, e := range events {
+			if err := enc.Encode(&e); err != nil {
+				return
+			}
+		}
+		from += len(events)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done || !follow {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveArtifactFile serves a file of an artifact of a build of a job, by
+// the indexes of the artifact and of the file in the state of the job.
+func serveArtifactFile(w http.ResponseWriter, r *http.Request, job *serveJob, build, artifact, file string) {
+	var files []string
+	for _, b := range job.Status().Builds {
+		if b.Name != build {
+			continue
+		}
+		if i, err := strconv.Atoi(artifact); err == nil && i >= 0 && i < len(b.Artifacts) {
+			files = b.Artifacts[i].Files
+		}
+	}
+	i, err := strconv.Atoi(file)
+	if err != nil || i < 0 || i >= len(files) {
+		serveError(w, http.StatusNotFound, fmt.Errorf("No file %s of artifact %s of build %s", file, artifact, build))
+		return
+	}
+
+	f, err := os.Open(files[i])
+	if err != nil {
+		serveError(w, http.StatusNotFound, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		serveError(w, http.StatusNotFound, fmt.Errorf("%s isn't a file", files[i]))
+		return
+	}
+
+	name := filepath.Base(files[i])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+func serveJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[WARN] Error writing the response: %s", err)
+	}
+}
+
+func serveError(w http.ResponseWriter, status int, err error) {
+	serveJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (*ServeCommand) Help() string {
+	helpText := `
+Usage: packer serve [options]
+
+  Serves an HTTP API to submit templates to build, stream the output of
+  their builds, cancel them and download their artifacts, so that services
+  can run builds without running packer and parsing its output.
+
+  The API can run any command on this machine through the templates it
+  builds: it listens on the loopback interface by default, and requires
+  the token of -token or PACKER_SERVE_TOKEN, or a random one printed on
+  start. The API is HTTP only, there's no gRPC API.
+
+Options:
+
+  -address=addr          The address to listen on, 127.0.0.1:8091 by default.
+  -allow-host=host:port  Also accept requests for this Host, such as the
+                         name of the machine. Can be used multiple times.
+  -max-jobs=n            Run at most n jobs at once, the others are queued.
+  -token=token           Require this bearer token in the Authorization header.
+  -policy=path           Rego policies or OPA server URL to check templates against
+  -strict                Build every template in strict mode, see packer build.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*ServeCommand) Synopsis() string {
+	return "serve an HTTP API to run builds"
+}
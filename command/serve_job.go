@@ -0,0 +1,384 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/packer/common/history"
+	"github.com/hashicorp/packer/packer"
+)
+
+// The types of the events of jobs, besides the output of the Ui.
+const (
+	eventBuildDone = "build-done"
+	eventJobDone   = "job-done"
+)
+
+// serveEvent is the output of a build of a job, or a change of its state.
+type serveEvent struct {
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Build   string    `json:"build,omitempty"`
+	Type    string    `json:"type"`
+	Message string    `json:"message,omitempty"`
+	Args    []string  `json:"args,omitempty"`
+}
+
+// serveArtifact is an artifact of a build of a job.
+type serveArtifact struct {
+	Id        string   `json:"id"`
+	BuilderId string   `json:"builder_id"`
+	String    string   `json:"string"`
+	Files     []string `json:"files"`
+}
+
+// serveBuild is the state of a build of a job.
+type serveBuild struct {
+	Name      string          `json:"name"`
+	State     string          `json:"state"`
+	Error     string          `json:"error,omitempty"`
+	Started   *time.Time      `json:"started,omitempty"`
+	Finished  *time.Time      `json:"finished,omitempty"`
+	Artifacts []serveArtifact `json:"artifacts"`
+}
+
+// serveStatus is the state of a job, as returned by the API.
+type serveStatus struct {
+	ID       string        `json:"id"`
+	State    string        `json:"state"`
+	Created  time.Time     `json:"created"`
+	Finished *time.Time    `json:"finished,omitempty"`
+	Builds   []*serveBuild `json:"builds"`
+}
+
+// serveJob runs the builds of a template submitted to packer serve and
+// records their output as events, and in the history.
+type serveJob struct {
+	l         sync.Mutex
+	status    serveStatus
+	events    []serveEvent
+	changed   chan struct{}
+	cancelled bool
+	cancelCh  chan struct{}
+	builds    []packer.Build
+	record    *history.Record
+	done      chan struct{}
+
+	// template is the path of the template, if it was read from a file.
+	template string
+}
+
+func newServeJob() (*serveJob, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	return &serveJob{
+		status: serveStatus{
+			ID:      id,
+			State:   history.StatePending,
+			Created: time.Now().UTC(),
+		},
+		changed:  make(chan struct{}),
+		cancelCh: make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Status returns a copy of the state of the job.
+func (j *serveJob) Status() *serveStatus {
+	j.l.Lock()
+	defer j.l.Unlock()
+
+	status := j.status
+	status.Builds = make([]*serveBuild, len(j.status.Builds))
+	for i, b := range j.status.Builds {
+		copied := *b
+		status.Builds[i] = &copied
+	}
+	return &status
+}
+
+// Events returns the events of the job from the given sequence number, a
+// channel closed once there are more, and whether the job is done.
+func (j *serveJob) Events(from int) ([]serveEvent, <-chan struct{}, bool) {
+	j.l.Lock()
+	defer j.l.Unlock()
+
+	var events []serveEvent
+	if from < len(j.events) {
+		events = append(events, j.events[from:]...)
+	}
+	done := j.status.Finished != nil
+	return events, j.changed, done
+}
+
+// event records an event and wakes up the readers waiting for it. It is
+// called with the lock held.
+func (j *serveJob) event(build, t, message string, args ...string) {
+	j.events = append(j.events, serveEvent{
+		Seq:     len(j.events),
+		Time:    time.Now().UTC(),
+		Build:   build,
+		Type:    t,
+		Message: message,
+		Args:    args,
+	})
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// build returns the state of the build with the given name. It is called
+// with the lock held.
+func (j *serveJob) build(name string) *serveBuild {
+	for _, b := range j.status.Builds {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// Cancel cancels the builds of the job, which lets them clean up. It
+// returns false if the job is already done.
+func (j *serveJob) Cancel() bool {
+	j.l.Lock()
+	if j.status.Finished != nil || j.cancelled {
+		done := j.status.Finished != nil
+		j.l.Unlock()
+		return !done
+	}
+	j.cancelled = true
+	close(j.cancelCh)
+	j.event("", "say", "Cancelling the builds and cleaning up...")
+
+	// The builds that didn't start never will
+	var builds []packer.Build
+	for _, b := range j.builds {
+		if j.build(b.Name()).State == history.StateRunning {
+			builds = append(builds, b)
+		}
+	}
+	j.l.Unlock()
+
+	for _, b := range builds {
+		go func(b packer.Build) {
+			log.Printf("Stopping build: %s", b.Name())
+			b.Cancel()
+			log.Printf("Build cancelled: %s", b.Name())
+		}(b)
+	}
+	return true
+}
+
+// Start runs the builds of the job in the background. The builds without
+// dependencies must be prepared. If slots isn't nil, the job waits in the
+// queue for a slot before running.
+func (j *serveJob) Start(core *packer.Core, builds []packer.Build, cache packer.Cache, slots chan struct{}) {
+	j.l.Lock()
+	j.builds = builds
+	for _, b := range builds {
+		j.status.Builds = append(j.status.Builds, &serveBuild{Name: b.Name(), State: history.StatePending})
+	}
+	j.l.Unlock()
+
+	go j.run(core, builds, cache, slots)
+}
+
+// run runs the builds of the job in parallel, the builds that use the
+// artifacts of other builds once these are done, and records their
+// results.
+func (j *serveJob) run(core *packer.Core, builds []packer.Build, cache packer.Cache, slots chan struct{}) {
+	defer close(j.done)
+
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-j.cancelCh:
+		}
+	}
+
+	names := make([]string, len(builds))
+	for i, b := range builds {
+		names[i] = b.Name()
+	}
+	j.l.Lock()
+	if !j.cancelled {
+		j.status.State = history.StateRunning
+		j.record = startHistory(j.status.ID, "serve", j.template, names, core)
+	}
+	j.l.Unlock()
+
+	var wg sync.WaitGroup
+	doneChs := make(map[string]chan struct{})
+	for _, b := range builds {
+		doneChs[b.Name()] = make(chan struct{})
+	}
+	artifacts := make(map[string][]packer.Artifact)
+
+	for _, b := range builds {
+		wg.Add(1)
+		go func(b packer.Build) {
+			defer wg.Done()
+
+			name := b.Name()
+			defer close(doneChs[name])
+			ui := &serveUi{job: j, build: name}
+
+			var err error
+			if deps := core.BuildDependencies(name); len(deps) > 0 {
+				for _, dep := range deps {
+					<-doneChs[dep]
+				}
+
+				var buildArtifacts map[string]string
+				buildArtifacts, err = dependencyArtifacts(deps, func(dep string) []packer.Artifact {
+					j.l.Lock()
+					defer j.l.Unlock()
+					return artifacts[dep]
+				})
+				if err == nil && !j.isCancelled() {
+					b.SetBuildArtifacts(buildArtifacts)
+					err = prepareBuild(b, ui)
+				}
+			}
+
+			if err == nil && !j.start(name) {
+				j.finish(name, nil, nil)
+				return
+			}
+
+			var runArtifacts []packer.Artifact
+			if err == nil {
+				log.Printf("Starting build run: %s", name)
+				runArtifacts, err = b.Run(ui, cache)
+			}
+			if err != nil {
+				ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
+			} else {
+				ui.Say(fmt.Sprintf("Build '%s' finished.", name))
+			}
+
+			j.l.Lock()
+			artifacts[name] = runArtifacts
+			j.l.Unlock()
+			j.finish(name, runArtifacts, err)
+		}(b)
+	}
+	wg.Wait()
+
+	j.l.Lock()
+	defer j.l.Unlock()
+	now := time.Now().UTC()
+	j.status.Finished = &now
+	j.status.State = history.StateSucceeded
+	for _, b := range j.status.Builds {
+		if b.State == history.StateFailed {
+			j.status.State = history.StateFailed
+		}
+	}
+	if j.cancelled {
+		j.status.State = history.StateCancelled
+	}
+	j.record.Finish(j.status.State)
+	j.event("", eventJobDone, "", j.status.State)
+}
+
+func (j *serveJob) isCancelled() bool {
+	j.l.Lock()
+	defer j.l.Unlock()
+	return j.cancelled
+}
+
+// start marks a build as running, unless the job is cancelled.
+func (j *serveJob) start(name string) bool {
+	j.l.Lock()
+	defer j.l.Unlock()
+	if j.cancelled {
+		return false
+	}
+
+	now := time.Now().UTC()
+	b := j.build(name)
+	b.State = history.StateRunning
+	b.Started = &now
+	j.record.StartBuild(name)
+	return true
+}
+
+// finish records the result of a build.
+func (j *serveJob) finish(name string, artifacts []packer.Artifact, err error) {
+	j.l.Lock()
+	defer j.l.Unlock()
+
+	now := time.Now().UTC()
+	b := j.build(name)
+	b.Finished = &now
+	switch {
+	case j.cancelled && (err != nil || b.Started == nil):
+		b.State = history.StateCancelled
+	case err != nil:
+		b.State = history.StateFailed
+		b.Error = err.Error()
+	default:
+		b.State = history.StateSucceeded
+	}
+
+	b.Artifacts = make([]serveArtifact, 0, len(artifacts))
+	for _, a := range artifacts {
+		if a == nil {
+			continue
+		}
+		b.Artifacts = append(b.Artifacts, serveArtifact{
+			Id:        a.Id(),
+			BuilderId: a.BuilderId(),
+			String:    a.String(),
+			Files:     a.Files(),
+		})
+	}
+
+	j.record.FinishBuild(name, b.State, artifacts, err)
+	j.event(name, eventBuildDone, b.Error, b.State)
+}
+
+// serveUi records the output of a build as events of its job. Builds of
+// packer serve can't ask for input.
+type serveUi struct {
+	job   *serveJob
+	build string
+}
+
+func (u *serveUi) Ask(query string) (string, error) {
+	return "", errors.New("builds of packer serve can't ask for input")
+}
+
+func (u *serveUi) Say(message string) {
+	u.output("say", message)
+}
+
+func (u *serveUi) Message(message string) {
+	u.output("message", message)
+}
+
+func (u *serveUi) Error(message string) {
+	u.output("error", message)
+}
+
+func (u *serveUi) Machine(t string, args ...string) {
+	u.job.l.Lock()
+	defer u.job.l.Unlock()
+	u.job.event(u.build, "machine", t, args...)
+}
+
+func (u *serveUi) output(t, message string) {
+	u.job.l.Lock()
+	defer u.job.l.Unlock()
+	u.job.record.Output(message)
+	u.job.event(u.build, t, strings.TrimRight(message, "\n"))
+}
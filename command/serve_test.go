@@ -0,0 +1,230 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/common/history"
+)
+
+func testServeRequest(t *testing.T, method, url string, body interface{}) *http.Response {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return resp
+}
+
+func testServeServer(t *testing.T) *httptest.Server {
+	server := httptest.NewUnstartedServer(nil)
+	server.Config.Handler = newBuildServer(testMetaFile(t), "secret", 0, serveHosts(server.Listener.Addr()))
+	server.Start()
+	return server
+}
+
+func TestServe_build(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	server := testServeServer(t)
+	defer server.Close()
+
+	target := filepath.Join(td, "vanilla.txt")
+	tpl := fmt.Sprintf(`{
+		"variables": {"flavor": null},
+		"builders": [
+			{"name": "vanilla", "type": "file", "content": "{{user `+"`flavor`"+`}}", "target": %q},
+			{"name": "cherry", "type": "file", "content": "cherry", "target": %q}
+		]
+	}`, target, filepath.Join(td, "cherry.txt"))
+	resp := testServeRequest(t, "POST", server.URL+"/v1/jobs", map[string]interface{}{
+		"template": json.RawMessage(tpl),
+		"vars":     map[string]string{"flavor": "vanilla"},
+		"only":     []string{"vanilla"},
+	})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		data, _ := ioutil.ReadAll(resp.Body)
+		t.Fatalf("bad: %d %s", resp.StatusCode, data)
+	}
+	var status serveStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(status.Builds) != 1 || status.Builds[0].Name != "vanilla" {
+		t.Fatalf("bad: %#v", status.Builds)
+	}
+
+	// The events are streamed until the job is done
+	events := testServeRequest(t, "GET", server.URL+"/v1/jobs/"+status.ID+"/events", nil)
+	defer events.Body.Close()
+	var last serveEvent
+	var types []string
+	scanner := bufio.NewScanner(events.Body)
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		types = append(types, last.Type)
+	}
+	if last.Type != eventJobDone || last.Args[0] != history.StateSucceeded {
+		t.Fatalf("bad: %#v %v", last, types)
+	}
+
+	resp = testServeRequest(t, "GET", server.URL+"/v1/jobs/"+status.ID, nil)
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b := status.Builds[0]
+	if status.State != history.StateSucceeded || b.State != history.StateSucceeded || len(b.Artifacts) != 1 || b.Finished == nil {
+		t.Fatalf("bad: %#v", b)
+	}
+
+	resp = testServeRequest(t, "GET", server.URL+"/v1/jobs/"+status.ID+"/artifacts/vanilla/0/files/0", nil)
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "vanilla" {
+		t.Fatalf("bad: %d %q", resp.StatusCode, data)
+	}
+
+	// A done job can't be cancelled
+	resp = testServeRequest(t, "POST", server.URL+"/v1/jobs/"+status.ID+"/cancel", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("bad: %d", resp.StatusCode)
+	}
+}
+
+func TestServe_errors(t *testing.T) {
+	server := testServeServer(t)
+	defer server.Close()
+
+	cases := []struct {
+		Method string
+		Path   string
+		Body   interface{}
+		Status int
+		Err    string
+	}{
+		{"POST", "/v1/jobs", map[string]interface{}{}, http.StatusBadRequest, "template or template_path must be set"},
+		{"POST", "/v1/jobs", map[string]interface{}{"template_path": "foo.json", "on_error": "ask"}, http.StatusBadRequest, "on_error"},
+		{"POST", "/v1/jobs", map[string]interface{}{"template": json.RawMessage(`{"builders": [{"type": "file"}]}`)}, http.StatusBadRequest, "target required"},
+		{"GET", "/v1/jobs/missing", nil, http.StatusNotFound, "No job missing"},
+		{"DELETE", "/v1/jobs", nil, http.StatusMethodNotAllowed, "Method not allowed"},
+	}
+
+	for _, tc := range cases {
+		resp := testServeRequest(t, tc.Method, server.URL+tc.Path, tc.Body)
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.Status || !strings.Contains(string(data), tc.Err) {
+			t.Fatalf("%s %s: bad: %d %s", tc.Method, tc.Path, resp.StatusCode, data)
+		}
+	}
+
+	// Requests without the token are rejected
+	resp, err := http.Get(server.URL + "/v1/jobs")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad: %d", resp.StatusCode)
+	}
+
+	// Jobs are only submitted as JSON, from the origin of the server
+	headers := []struct {
+		Header string
+		Value  string
+		Status int
+	}{
+		{"Content-Type", "text/plain", http.StatusUnsupportedMediaType},
+		{"Content-Type", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"Host", "rebound.example.com", http.StatusForbidden},
+		{"Origin", "http://evil.example.com", http.StatusForbidden},
+		{"Origin", "null", http.StatusForbidden},
+		{"Origin", "http://" + server.Listener.Addr().String(), http.StatusBadRequest},
+	}
+	for _, tc := range headers {
+		req, err := http.NewRequest("POST", server.URL+"/v1/jobs", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "application/json")
+		if tc.Header == "Host" {
+			req.Host = tc.Value
+		} else {
+			req.Header.Set(tc.Header, tc.Value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.Status {
+			t.Fatalf("%s %s: bad: %d", tc.Header, tc.Value, resp.StatusCode)
+		}
+	}
+}
+
+func TestServe_queue(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	s := newBuildServer(testMetaFile(t), "secret", 1, nil)
+
+	// Another job is running
+	s.slots <- struct{}{}
+
+	tpl := fmt.Sprintf(`{"builders": [{"type": "file", "content": "vanilla", "target": %q}]}`,
+		filepath.Join(td, "vanilla.txt"))
+	job, err := s.Submit(&serveRequest{Template: json.RawMessage(tpl)})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if status := job.Status(); status.State != history.StatePending {
+		t.Fatalf("bad: %#v", status)
+	}
+
+	// A queued job is cancelled without running
+	if !job.Cancel() {
+		t.Fatal("should cancel")
+	}
+	<-job.done
+	status := job.Status()
+	if status.State != history.StateCancelled || status.Builds[0].State != history.StateCancelled {
+		t.Fatalf("bad: %#v", status.Builds[0])
+	}
+	if _, err := os.Stat(filepath.Join(td, "vanilla.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the build ran: %v", err)
+	}
+}
@@ -14,10 +14,11 @@ type ValidateCommand struct {
 }
 
 func (c *ValidateCommand) Run(args []string) int {
-	var cfgSyntaxOnly bool
-	flags := c.Meta.FlagSet("validate", FlagSetBuildFilter|FlagSetVars)
+	var cfgSyntaxOnly, cfgCheckRemote bool
+	flags := c.Meta.FlagSet("validate", FlagSetBuildFilter|FlagSetVars|FlagSetPolicy|FlagSetStrict)
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	flags.BoolVar(&cfgSyntaxOnly, "syntax-only", false, "check syntax only")
+	flags.BoolVar(&cfgCheckRemote, "check-remote", false, "run remote checks")
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
@@ -66,13 +67,34 @@ func (c *ValidateCommand) Run(args []string) int {
 		builds = append(builds, b)
 	}
 
-	// Check the configuration of all builds
+	if err := c.Meta.CheckPolicies(core, buildNames); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Check the configuration of all builds. The builds that use the
+	// artifacts of other builds get placeholders, as nothing is built.
 	for _, b := range builds {
+		if deps := core.BuildDependencies(b.Name()); len(deps) > 0 {
+			placeholders := make(map[string]string, len(deps))
+			for _, dep := range deps {
+				placeholders[dep] = fmt.Sprintf("packer-artifact-of-%s", dep)
+			}
+			b.SetBuildArtifacts(placeholders)
+		}
+
 		log.Printf("Preparing build: %s", b.Name())
 		warns, err := b.Prepare()
 		if len(warns) > 0 {
 			warnings[b.Name()] = warns
 		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Errors validating build '%s'. %s", b.Name(), err))
+			continue
+		}
+
+		// Run the deeper checks of the components that support them
+		log.Printf("Validating build: %s", b.Name())
+		err = b.Validate(&packer.ValidateContext{CheckRemote: cfgCheckRemote})
 		if err != nil {
 			errs = append(errs, fmt.Errorf("Errors validating build '%s'. %s", b.Name(), err))
 		}
@@ -116,6 +138,9 @@ Usage: packer validate [options] TEMPLATE
 
   Checks the template is valid by parsing the template and also
   checking the configuration with the various builders, provisioners, etc.
+  Components can also run additional checks, such as that scripts exist
+  and parse. With -check-remote, they may also call remote services, for
+  example to check that credentials and source images are valid.
 
   If it is not valid, the errors will be shown and the command will exit
   with a non-zero exit status. If it is valid, it will exit with a zero
@@ -123,11 +148,16 @@ Usage: packer validate [options] TEMPLATE
 
 Options:
 
+  -check-remote          Also run checks that call remote services.
   -syntax-only           Only check syntax. Do not verify config of the template.
   -except=foo,bar,baz    Validate all builds other than these
   -only=foo,bar,baz      Validate only these builds
+  -policy=path           Rego policies or OPA server URL to check the template against
+  -strict                Reject undeclared variables, deprecated and reserved keys
   -var 'key=value'       Variable for templates, can be used multiple times.
   -var-file=path         JSON file containing user variables.
+  -var-dir=path          Directory of layered var files, see 'packer vars'.
+  -profile=name          Var file of the -var-dir to layer, e.g. prod.
 `
 
 	return strings.TrimSpace(helpText)
@@ -2,7 +2,10 @@ package command
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 func TestValidateCommandOKVersion(t *testing.T) {
@@ -42,3 +45,76 @@ func TestValidateCommandBadVersion(t *testing.T) {
 	}
 	t.Log(stdout)
 }
+
+func TestValidateCommandPromptVariables(t *testing.T) {
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	c.Interactive = true
+	c.Ui.(*packer.BasicUi).Reader = strings.NewReader("cherry\nvanilla\n")
+	args := []string{
+		filepath.Join(testFixture("validate-variables"), "template.json"),
+	}
+
+	// The invalid value is asked again
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	stdout, stderr := outputCommand(t, c.Meta)
+	query := "Value for required variable 'flavor' (The flavor of the file):"
+	if strings.Count(stdout, query) != 2 {
+		t.Fatalf("should ask twice:\n%s", stdout)
+	}
+	expected := "Invalid value for variable 'flavor': 'cherry' must be one of: chocolate, vanilla\n"
+	if stderr != expected {
+		t.Fatalf("Expected:\n%s\nFound:\n%s\n", expected, stderr)
+	}
+}
+
+func TestValidateCommandRequiredVariable(t *testing.T) {
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	args := []string{
+		filepath.Join(testFixture("validate-variables"), "template.json"),
+	}
+
+	// Without a terminal, the user isn't asked
+	if code := c.Run(args); code != 1 {
+		t.Errorf("Expected exit code 1")
+	}
+
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "required variable not set: flavor (The flavor of the file)") {
+		t.Fatalf("bad error:\n%s", stderr)
+	}
+}
+
+func TestValidateCommandStrict(t *testing.T) {
+	args := []string{
+		"-var", "flavr=chocolate",
+		filepath.Join(testFixture("validate"), "template.json"),
+	}
+
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	c.CoreConfig.Version = "102.0.0"
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	c = &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	c.CoreConfig.Version = "102.0.0"
+	if code := c.Run(append([]string{"-strict"}, args...)); code != 1 {
+		t.Errorf("Expected exit code 1")
+	}
+
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "variable 'flavr' is set but not declared in the template") {
+		t.Fatalf("bad error:\n%s", stderr)
+	}
+}
@@ -0,0 +1,147 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/template"
+)
+
+// The sources of variables that aren't set by a var file of the var dir.
+const (
+	varSourceFlags   = "-var/-var-file"
+	varSourceDefault = "default"
+)
+
+type VarsCommand struct {
+	Meta
+}
+
+// effectiveVar is the value of a variable and where it was set.
+type effectiveVar struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+func (c *VarsCommand) Run(args []string) int {
+	var cfgJSON bool
+	flags := c.Meta.FlagSet("vars", FlagSetVars)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.BoolVar(&cfgJSON, "json", false, "output json")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	// Parse the template
+	tpl, err := template.ParseFile(args[0])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to parse template: %s", err))
+		return 1
+	}
+
+	// The variables set by flags override the var dir, and the core adds
+	// the defaults of the others
+	fromFlags := make(map[string]bool)
+	for k := range c.flagVars {
+		fromFlags[k] = true
+	}
+	if err := c.loadVarDir(); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	core, err := c.Meta.Core(tpl)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	vars := make(map[string]*effectiveVar)
+	for k, v := range core.InspectVariables() {
+		source := varSourceDefault
+		if fromFlags[k] {
+			source = varSourceFlags
+		} else if s, ok := c.varSources[k]; ok {
+			source = s
+		}
+		vars[k] = &effectiveVar{Value: v, Source: source}
+	}
+
+	if cfgJSON {
+		out, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to encode variables: %s", err))
+			return 1
+		}
+		c.Ui.Say(string(out))
+		return 0
+	}
+
+	if len(vars) == 0 {
+		c.Ui.Say("<No variables>")
+		return 0
+	}
+
+	keys := make([]string, 0, len(vars))
+	max := 0
+	for k := range vars {
+		keys = append(keys, k)
+		if len(k) > max {
+			max = len(k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := vars[k]
+		padding := strings.Repeat(" ", max-len(k))
+		c.Ui.Machine("variable", k, v.Value, v.Source)
+		c.Ui.Say(fmt.Sprintf("%s%s = %s (%s)", k, padding, v.Value, v.Source))
+	}
+
+	return 0
+}
+
+func (*VarsCommand) Help() string {
+	helpText := `
+Usage: packer vars [options] TEMPLATE
+
+  Prints the effective values of the user variables of a template, and
+  where each was set. Sensitive values are redacted.
+
+  Variables are set, from lowest to highest precedence, by their default
+  in the template, then by the var files of the -var-dir directory in this
+  order:
+
+    defaults.json     the defaults shared by every profile
+    PROFILE.json      the file of the profile, given with -profile or
+                      the PACKER_PROFILE environment variable
+    local.json        local overrides, usually not committed
+
+  and finally by -var and -var-file. Objects are merged deeply between the
+  var files of the directory, other values are replaced.
+
+Options:
+
+  -json                  Output the variables as JSON
+  -machine-readable      Machine-readable output
+  -var 'key=value'       Variable for templates, can be used multiple times.
+  -var-file=path         JSON file containing user variables.
+  -var-dir=path          Directory of layered var files.
+  -profile=name          Var file of the -var-dir to layer, e.g. prod.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *VarsCommand) Synopsis() string {
+	return "print the effective values of user variables"
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVarsCommand(t *testing.T) {
+	c := &VarsCommand{
+		Meta: testMeta(t),
+	}
+	dir := testFixture("vars")
+	args := []string{
+		"-var-dir", filepath.Join(dir, "vars"),
+		"-profile", "prod",
+		"-var", "ami_name=web",
+		filepath.Join(dir, "template.json"),
+	}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	stdout, _ := outputCommand(t, c.Meta)
+	expected := []string{
+		"ami_name      = web (-var/-var-file)",
+		"api_token     = <sensitive> (" + filepath.Join(dir, "vars", "defaults.json") + ")",
+		"instance_type = m5.large (" + filepath.Join(dir, "vars", "prod.json") + ")",
+		"region        = eu-west-1 (" + filepath.Join(dir, "vars", "local.json") + ")",
+		`tags          = {"env":"prod","team":"platform"} (` + filepath.Join(dir, "vars", "prod.json") + ")",
+	}
+	if actual := strings.TrimSpace(stdout); actual != strings.Join(expected, "\n") {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
+
+func TestVarsCommand_defaults(t *testing.T) {
+	c := &VarsCommand{
+		Meta: testMeta(t),
+	}
+	args := []string{
+		filepath.Join(testFixture("vars"), "template.json"),
+	}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	stdout, _ := outputCommand(t, c.Meta)
+	if !strings.Contains(stdout, "region        = us-east-1 (default)\n") {
+		t.Fatalf("bad:\n%s", stdout)
+	}
+}
+
+func TestVarsCommand_missingProfile(t *testing.T) {
+	c := &VarsCommand{
+		Meta: testMeta(t),
+	}
+	dir := testFixture("vars")
+	args := []string{
+		"-var-dir", filepath.Join(dir, "vars"),
+		"-profile", "staging",
+		filepath.Join(dir, "template.json"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatal("should fail")
+	}
+
+	_, stderr := outputCommand(t, c.Meta)
+	if !strings.Contains(stderr, "profile 'staging' not found") {
+		t.Fatalf("bad:\n%s", stderr)
+	}
+}
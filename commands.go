@@ -24,12 +24,54 @@ func init() {
 			}, nil
 		},
 
+		"bundle": func() (cli.Command, error) {
+			return &command.BundleCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"cache": func() (cli.Command, error) {
+			return &command.CacheCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"cleanup": func() (cli.Command, error) {
+			return &command.CleanupCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"console-attach": func() (cli.Command, error) {
+			return &command.ConsoleAttachCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"develop": func() (cli.Command, error) {
+			return &command.DevelopCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"fix": func() (cli.Command, error) {
 			return &command.FixCommand{
 				Meta: *CommandMeta,
 			}, nil
 		},
 
+		"history": func() (cli.Command, error) {
+			return &command.HistoryCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"init": func() (cli.Command, error) {
+			return &command.InitCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"inspect": func() (cli.Command, error) {
 			return &command.InspectCommand{
 				Meta: *CommandMeta,
@@ -42,12 +84,24 @@ func init() {
 			}, nil
 		},
 
+		"serve": func() (cli.Command, error) {
+			return &command.ServeCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"validate": func() (cli.Command, error) {
 			return &command.ValidateCommand{
 				Meta: *CommandMeta,
 			}, nil
 		},
 
+		"vars": func() (cli.Command, error) {
+			return &command.VarsCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"version": func() (cli.Command, error) {
 			return &command.VersionCommand{
 				Meta:              *CommandMeta,
@@ -0,0 +1,270 @@
+package bootcommand
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/multistep"
+)
+
+// ErrCancelled is returned by Run when the build was cancelled while the
+// boot command was running.
+var ErrCancelled = errors.New("boot command cancelled")
+
+// Typer types keys written in the boot_command syntax of a builder.
+type Typer func(keys string) error
+
+// Screenshotter captures the screen of the VM.
+type Screenshotter func() (image.Image, error)
+
+// Part is a part of a boot command. It is either keys to type, a wait or a
+// wait for text on the screen.
+type Part struct {
+	// Keys are typed by the builder.
+	Keys string
+
+	// Wait is how long to sleep.
+	Wait time.Duration
+
+	// WaitForText is the text to wait for on the screen. WaitForTimeout
+	// is how long to wait, zero means the default timeout.
+	WaitForText    string
+	WaitForTimeout time.Duration
+}
+
+var (
+	waitRe    = regexp.MustCompile(`^<wait([0-9.hmsuµn]*)>`)
+	waitForRe = regexp.MustCompile(`^<waitFor '([^']*)'(?:\s+([^\s>]+))?\s*>`)
+	chordRe   = regexp.MustCompile(`^<((?:[A-Za-z]+\+)+)([A-Za-z0-9]+|[^A-Za-z0-9>])>`)
+)
+
+// chordModifiers are the names of the modifiers that can be used in key
+// chords, by their lower case name, and the name of their key.
+var chordModifiers = map[string]string{
+	"ctrl":       "leftCtrl",
+	"leftctrl":   "leftCtrl",
+	"rightctrl":  "rightCtrl",
+	"alt":        "leftAlt",
+	"leftalt":    "leftAlt",
+	"rightalt":   "rightAlt",
+	"shift":      "leftShift",
+	"leftshift":  "leftShift",
+	"rightshift": "rightShift",
+}
+
+// Split splits a boot command in the parts that are run by Run. Waits and
+// waits for text become parts of their own, and key chords like
+// <ctrl+alt+del> are expanded to the modifiers being pressed, the key and
+// the modifiers being released, in the syntax the builders understand.
+// Everything else is left to the builder.
+func Split(command string) ([]Part, error) {
+	var parts []Part
+	var keys string
+
+	flush := func() {
+		if keys != "" {
+			parts = append(parts, Part{Keys: keys})
+			keys = ""
+		}
+	}
+
+	for len(command) > 0 {
+		if !strings.HasPrefix(command, "<") {
+			i := strings.Index(command, "<")
+			if i < 0 {
+				i = len(command)
+			}
+			keys += command[:i]
+			command = command[i:]
+			continue
+		}
+
+		if m := waitForRe.FindStringSubmatch(command); m != nil {
+			part := Part{WaitForText: m[1]}
+			if m[2] != "" {
+				d, err := time.ParseDuration(m[2])
+				if err != nil {
+					return nil, fmt.Errorf("Bad timeout in %s: %s", m[0], err)
+				}
+				part.WaitForTimeout = d
+			}
+			flush()
+			parts = append(parts, part)
+			command = command[len(m[0]):]
+			continue
+		}
+
+		if m := waitRe.FindStringSubmatch(command); m != nil {
+			d, err := parseWait(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("Bad duration in %s: %s", m[0], err)
+			}
+			flush()
+			parts = append(parts, Part{Wait: d})
+			command = command[len(m[0]):]
+			continue
+		}
+
+		if m := chordRe.FindStringSubmatch(command); m != nil {
+			if chord, ok := expandChord(m[1], m[2]); ok {
+				keys += chord
+				command = command[len(m[0]):]
+				continue
+			}
+		}
+
+		keys += "<"
+		command = command[1:]
+	}
+	flush()
+
+	return parts, nil
+}
+
+// parseWait parses the duration of <waitX>: one second if it is empty, a
+// number of seconds like <wait10>, or a duration like <wait1m30s>.
+func parseWait(s string) (time.Duration, error) {
+	if s == "" {
+		return time.Second, nil
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// expandChord expands the modifiers, separated and followed by a "+", and
+// the key of a chord. It returns false if one of the modifiers is unknown.
+func expandChord(modifiers string, key string) (string, bool) {
+	var names []string
+	for _, modifier := range strings.Split(strings.TrimSuffix(modifiers, "+"), "+") {
+		name, ok := chordModifiers[strings.ToLower(modifier)]
+		if !ok {
+			return "", false
+		}
+		names = append(names, name)
+	}
+
+	chord := ""
+	for _, name := range names {
+		chord += "<" + name + "On>"
+	}
+	if len(key) == 1 {
+		chord += key
+	} else {
+		chord += "<" + key + ">"
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		chord += "<" + names[i] + "Off>"
+	}
+	return chord, true
+}
+
+// Run runs a boot command. Waits and waits for text are run here, keys
+// are typed with typeKeys. screenshot may be nil if the builder can't
+// capture the screen of the VM, waiting for text is an error then.
+func (c *BootConfig) Run(state multistep.StateBag, command string, typeKeys Typer, screenshot Screenshotter) error {
+	parts, err := Split(command)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		if _, ok := state.GetOk(multistep.StateCancelled); ok {
+			return ErrCancelled
+		}
+
+		switch {
+		case part.Keys != "":
+			if err := typeKeys(part.Keys); err != nil {
+				return err
+			}
+		case part.WaitForText != "":
+			timeout := part.WaitForTimeout
+			if timeout == 0 {
+				timeout = c.WaitForTextTimeout
+			}
+			if err := c.waitForText(state, screenshot, part.WaitForText, timeout); err != nil {
+				return err
+			}
+		default:
+			log.Printf("Waiting %s", part.Wait)
+			if !sleep(state, part.Wait) {
+				return ErrCancelled
+			}
+		}
+	}
+
+	return nil
+}
+
+// waitForText reads the screen until the text appears on it.
+func (c *BootConfig) waitForText(state multistep.StateBag, screenshot Screenshotter, text string, timeout time.Duration) error {
+	if screenshot == nil {
+		return fmt.Errorf("Waiting for text on the screen isn't supported by this builder")
+	}
+
+	log.Printf("Waiting up to %s for %q on the screen", timeout, text)
+	interval := c.WaitForTextInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+	last := ""
+	for {
+		img, err := screenshot()
+		if err != nil {
+			return fmt.Errorf("Error capturing the screen: %s", err)
+		}
+		last, err = ocr(img)
+		if err != nil {
+			return fmt.Errorf("Error reading the screen: %s", err)
+		}
+		if containsText(last, text) {
+			log.Printf("Found %q on the screen", text)
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("Timeout waiting for %q on the screen, it shows:\n%s", text, last)
+		}
+		if !sleep(state, interval) {
+			return ErrCancelled
+		}
+	}
+}
+
+// containsText returns true if the text is in the screen text, ignoring
+// case and white space differences, which OCR often gets wrong.
+func containsText(screen, text string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return strings.Contains(normalize(screen), normalize(text))
+}
+
+// sleep sleeps for d, or until the build is cancelled. It returns false
+// if the build was cancelled.
+func sleep(state multistep.StateBag, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for {
+		if _, ok := state.GetOk(multistep.StateCancelled); ok {
+			return false
+		}
+		left := deadline.Sub(time.Now())
+		if left <= 0 {
+			return true
+		}
+		if left > 100*time.Millisecond {
+			left = 100 * time.Millisecond
+		}
+		time.Sleep(left)
+	}
+}
@@ -0,0 +1,140 @@
+package bootcommand
+
+import (
+	"image"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-vnc"
+	"github.com/mitchellh/multistep"
+)
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		Command string
+		Parts   []Part
+	}{
+		{"", nil},
+		{"linux ks=http://x/ks.cfg<enter>", []Part{{Keys: "linux ks=http://x/ks.cfg<enter>"}}},
+		{"<esc><wait>", []Part{{Keys: "<esc>"}, {Wait: time.Second}}},
+		{"<wait5>a<wait10>", []Part{{Wait: 5 * time.Second}, {Keys: "a"}, {Wait: 10 * time.Second}}},
+		{"<wait1m30s>", []Part{{Wait: 90 * time.Second}}},
+		{"<waitFor 'Install now'><enter>", []Part{{WaitForText: "Install now"}, {Keys: "<enter>"}}},
+		{"<waitFor 'boot: ' 2m>", []Part{{WaitForText: "boot: ", WaitForTimeout: 2 * time.Minute}}},
+		{"<ctrl+alt+del>", []Part{{Keys: "<leftCtrlOn><leftAltOn><del><leftAltOff><leftCtrlOff>"}}},
+		{"<ctrl+c>x", []Part{{Keys: "<leftCtrlOn>c<leftCtrlOff>x"}}},
+		{"<rightAlt+f2>", []Part{{Keys: "<rightAltOn><f2><rightAltOff>"}}},
+		{"<shift+tab>", []Part{{Keys: "<leftShiftOn><tab><leftShiftOff>"}}},
+		{"a<b+c>", []Part{{Keys: "a<b+c>"}}},
+		{"<leftAltOn>x<leftAltOff>", []Part{{Keys: "<leftAltOn>x<leftAltOff>"}}},
+		{"1 < 2", []Part{{Keys: "1 < 2"}}},
+	}
+
+	for _, tc := range cases {
+		parts, err := Split(tc.Command)
+		if err != nil {
+			t.Fatalf("%q: err: %s", tc.Command, err)
+		}
+		if !reflect.DeepEqual(parts, tc.Parts) {
+			t.Fatalf("%q: bad parts: %#v", tc.Command, parts)
+		}
+	}
+}
+
+func TestSplit_badDuration(t *testing.T) {
+	for _, command := range []string{"<waitFor 'x' soon>", "<wait1.2.3s>"} {
+		if _, err := Split(command); err == nil {
+			t.Fatalf("%q: should have error", command)
+		}
+	}
+}
+
+func testConfig(t *testing.T) *BootConfig {
+	c := &BootConfig{RawWaitForTextInterval: "1ms"}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	return c
+}
+
+func TestBootConfigRun(t *testing.T) {
+	defer func() { ocr = tesseract }()
+	screens := []string{"Loading...", "Press any  KEY to boot"}
+	ocr = func(image.Image) (string, error) {
+		screen := screens[0]
+		if len(screens) > 1 {
+			screens = screens[1:]
+		}
+		return screen, nil
+	}
+
+	var typed []string
+	shots := 0
+	err := testConfig(t).Run(new(multistep.BasicStateBag), "<esc><waitFor 'press any key'><enter>",
+		func(keys string) error {
+			typed = append(typed, keys)
+			return nil
+		},
+		func() (image.Image, error) {
+			shots++
+			return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil
+		})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(typed, []string{"<esc>", "<enter>"}) {
+		t.Fatalf("bad keys: %#v", typed)
+	}
+	if shots != 2 {
+		t.Fatalf("the screen should be read until the text appears, read %d times", shots)
+	}
+}
+
+func TestBootConfigRun_waitForTimeout(t *testing.T) {
+	defer func() { ocr = tesseract }()
+	ocr = func(image.Image) (string, error) { return "Loading...", nil }
+
+	err := testConfig(t).Run(new(multistep.BasicStateBag), "<waitFor 'login:' 10ms>",
+		func(string) error { return nil },
+		func() (image.Image, error) { return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil })
+	if err == nil {
+		t.Fatal("should time out")
+	}
+}
+
+func TestBootConfigRun_noScreenshot(t *testing.T) {
+	err := testConfig(t).Run(new(multistep.BasicStateBag), "<waitFor 'login:'>",
+		func(string) error { return nil }, nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBootConfigRun_cancelled(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put(multistep.StateCancelled, true)
+
+	err := testConfig(t).Run(state, "<wait10>a", func(string) error {
+		t.Fatal("nothing should be typed")
+		return nil
+	}, nil)
+	if err != ErrCancelled {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestDrawRaw(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	format := vnc.PixelFormat{TrueColor: true, RedMax: 31, GreenMax: 63, BlueMax: 31}
+	rect := vnc.Rectangle{X: 1, Y: 1, Width: 1, Height: 1}
+	drawRaw(img, format, rect, &vnc.RawEncoding{Colors: []vnc.Color{{R: 31, G: 0, B: 31}}})
+
+	if c := img.RGBAAt(1, 1); c.R != 255 || c.G != 0 || c.B != 255 || c.A != 255 {
+		t.Fatalf("bad color: %#v", c)
+	}
+	if c := img.RGBAAt(0, 0); c.A != 0 {
+		t.Fatalf("only the rectangle should be drawn: %#v", c)
+	}
+}
@@ -0,0 +1,65 @@
+// Package bootcommand implements the parts of the boot_command that are
+// shared by the builders: waits, waiting for text to appear on the screen
+// of the VM, key chords and the typing rate. Typing the keys themselves is
+// left to each builder.
+package bootcommand
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// BootConfig is the configuration of the boot_command shared by the
+// builders.
+type BootConfig struct {
+	RawKeyInterval         string `mapstructure:"boot_key_interval"`
+	RawWaitForTextTimeout  string `mapstructure:"boot_wait_for_text_timeout"`
+	RawWaitForTextInterval string `mapstructure:"boot_wait_for_text_interval"`
+
+	// KeyInterval is the delay between key events. Zero means the default
+	// of the builder.
+	KeyInterval time.Duration ``
+
+	// WaitForTextTimeout is how long <waitFor> waits for its text, unless
+	// it has its own timeout.
+	WaitForTextTimeout time.Duration ``
+
+	// WaitForTextInterval is the delay between two reads of the screen
+	// while waiting for text.
+	WaitForTextInterval time.Duration ``
+}
+
+func (c *BootConfig) Prepare(ctx *interpolate.Context) []error {
+	if c.RawWaitForTextTimeout == "" {
+		c.RawWaitForTextTimeout = "5m"
+	}
+
+	if c.RawWaitForTextInterval == "" {
+		c.RawWaitForTextInterval = "2s"
+	}
+
+	var errs []error
+	var err error
+	if c.RawKeyInterval != "" {
+		c.KeyInterval, err = time.ParseDuration(c.RawKeyInterval)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing boot_key_interval: %s", err))
+		}
+	}
+
+	c.WaitForTextTimeout, err = time.ParseDuration(c.RawWaitForTextTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing boot_wait_for_text_timeout: %s", err))
+	}
+
+	c.WaitForTextInterval, err = time.ParseDuration(c.RawWaitForTextInterval)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed parsing boot_wait_for_text_interval: %s", err))
+	} else if c.WaitForTextInterval <= 0 {
+		errs = append(errs, fmt.Errorf("boot_wait_for_text_interval must be positive"))
+	}
+
+	return errs
+}
@@ -0,0 +1,40 @@
+package bootcommand
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBootConfigPrepare(t *testing.T) {
+	var c BootConfig
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if c.KeyInterval != 0 {
+		t.Fatalf("the builder default should be used: %s", c.KeyInterval)
+	}
+	if c.WaitForTextTimeout != 5*time.Minute {
+		t.Fatalf("bad timeout: %s", c.WaitForTextTimeout)
+	}
+	if c.WaitForTextInterval != 2*time.Second {
+		t.Fatalf("bad interval: %s", c.WaitForTextInterval)
+	}
+
+	c = BootConfig{RawKeyInterval: "10ms", RawWaitForTextTimeout: "20m"}
+	if errs := c.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if c.KeyInterval != 10*time.Millisecond || c.WaitForTextTimeout != 20*time.Minute {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	for _, bad := range []BootConfig{
+		{RawKeyInterval: "fast"},
+		{RawWaitForTextTimeout: "forever"},
+		{RawWaitForTextInterval: "0s"},
+	} {
+		if errs := bad.Prepare(nil); len(errs) == 0 {
+			t.Fatalf("%#v: should have error", bad)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package bootcommand
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// ocr reads the text on an image. It is replaced in tests.
+var ocr = tesseract
+
+// tesseract reads the text on an image with the tesseract command line
+// tool, which must be installed to wait for text.
+func tesseract(img image.Image) (string, error) {
+	path, err := exec.LookPath("tesseract")
+	if err != nil {
+		return "", fmt.Errorf("Waiting for text on the screen requires tesseract to be installed: %s", err)
+	}
+
+	f, err := ioutil.TempFile("", "packer-screen")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, f.Name(), "stdout")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// PNGScreenshotter returns a Screenshotter for builders that capture the
+// screen to a PNG file with capture.
+func PNGScreenshotter(capture func(path string) error) Screenshotter {
+	return func() (image.Image, error) {
+		f, err := ioutil.TempFile("", "packer-screen")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+
+		if err := capture(f.Name()); err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(data))
+	}
+}
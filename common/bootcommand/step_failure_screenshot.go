@@ -0,0 +1,71 @@
+package bootcommand
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// StepFailureScreenshot saves a screenshot of the console of the VM when
+// the build fails before the communicator is connected, e.g. because the
+// boot command or an unattended install hangs. It should be placed right
+// after the VM is started, so that the VM is still running when its
+// Cleanup is called.
+//
+// Uses:
+//   communicator packer.Communicator
+//   ui           packer.Ui
+type StepFailureScreenshot struct {
+	// Path is where the PNG screenshot is saved.
+	Path string
+
+	// Screenshot captures the console of the VM.
+	Screenshot func(state multistep.StateBag) (image.Image, error)
+}
+
+func (s *StepFailureScreenshot) Run(multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (s *StepFailureScreenshot) Cleanup(state multistep.StateBag) {
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	// Once the communicator is connected the console doesn't tell much
+	// about the failure.
+	if _, ok := state.GetOk("communicator"); ok {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	if err := s.save(state); err != nil {
+		log.Printf("Error saving a screenshot of the console: %s", err)
+		return
+	}
+	ui.Message(fmt.Sprintf("Saved a screenshot of the console to %s", s.Path))
+}
+
+func (s *StepFailureScreenshot) save(state multistep.StateBag) error {
+	img, err := s.Screenshot(state)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(s.Path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
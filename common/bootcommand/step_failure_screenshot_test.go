@@ -0,0 +1,66 @@
+package bootcommand
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepFailureScreenshot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepFailureScreenshot)
+}
+
+func TestStepFailureScreenshot(t *testing.T) {
+	cases := []struct {
+		Name  string
+		State map[string]interface{}
+		Saved bool
+	}{
+		{"success", nil, false},
+		{"halted", map[string]interface{}{multistep.StateHalted: true}, true},
+		{"cancelled", map[string]interface{}{multistep.StateCancelled: true}, true},
+		{"connected", map[string]interface{}{
+			multistep.StateHalted: true,
+			"communicator":        new(packer.MockCommunicator),
+		}, false},
+	}
+
+	for _, tc := range cases {
+		dir, err := ioutil.TempDir("", "packer")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer os.RemoveAll(dir)
+
+		state := new(multistep.BasicStateBag)
+		state.Put("ui", &packer.BasicUi{
+			Reader: new(bytes.Buffer),
+			Writer: new(bytes.Buffer),
+		})
+		for k, v := range tc.State {
+			state.Put(k, v)
+		}
+
+		step := &StepFailureScreenshot{
+			Path: filepath.Join(dir, "failure.png"),
+			Screenshot: func(multistep.StateBag) (image.Image, error) {
+				return image.NewRGBA(image.Rect(0, 0, 8, 8)), nil
+			},
+		}
+		if action := step.Run(state); action != multistep.ActionContinue {
+			t.Fatalf("%s: bad action: %#v", tc.Name, action)
+		}
+		step.Cleanup(state)
+
+		_, err = os.Stat(step.Path)
+		if saved := err == nil; saved != tc.Saved {
+			t.Fatalf("%s: saved %t, expected %t", tc.Name, saved, tc.Saved)
+		}
+	}
+}
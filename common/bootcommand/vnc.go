@@ -0,0 +1,101 @@
+package bootcommand
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"net"
+	"time"
+
+	"github.com/mitchellh/go-vnc"
+)
+
+// VNCScreenshotter returns a Screenshotter for builders typing the boot
+// command over VNC. The connection must have been made with messages as
+// its ServerMessageCh.
+func VNCScreenshotter(c *vnc.ClientConn, messages <-chan vnc.ServerMessage) Screenshotter {
+	return func() (image.Image, error) {
+		// Drop the updates that arrived since the last screenshot.
+		for len(messages) > 0 {
+			<-messages
+		}
+
+		width, height := c.FrameBufferWidth, c.FrameBufferHeight
+		if err := c.FramebufferUpdateRequest(false, 0, 0, width, height); err != nil {
+			return nil, err
+		}
+
+		timeout := time.After(30 * time.Second)
+		for {
+			select {
+			case msg := <-messages:
+				update, ok := msg.(*vnc.FramebufferUpdateMessage)
+				if !ok {
+					continue
+				}
+				img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+				for _, rect := range update.Rectangles {
+					raw, ok := rect.Enc.(*vnc.RawEncoding)
+					if !ok {
+						continue
+					}
+					drawRaw(img, c.PixelFormat, rect, raw)
+				}
+				return img, nil
+			case <-timeout:
+				return nil, fmt.Errorf("Timeout waiting for the screen over VNC")
+			}
+		}
+	}
+}
+
+// VNCScreenshot connects to the VNC server at addr and captures its
+// screen, for builders that aren't connected to it anymore.
+func VNCScreenshot(addr string, auth []vnc.ClientAuth) (image.Image, error) {
+	nc, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+
+	messages := make(chan vnc.ServerMessage, 16)
+	c, err := vnc.Client(nc, &vnc.ClientConfig{Auth: auth, ServerMessageCh: messages})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	return VNCScreenshotter(c, messages)()
+}
+
+// drawRaw draws the pixels of a rectangle on the image. The colors of a
+// true color format are scaled to 8 bits, the ones of a color map are 16
+// bits.
+func drawRaw(img *image.RGBA, format vnc.PixelFormat, rect vnc.Rectangle, raw *vnc.RawEncoding) {
+	redMax, greenMax, blueMax := uint32(65535), uint32(65535), uint32(65535)
+	if format.TrueColor {
+		redMax, greenMax, blueMax = uint32(format.RedMax), uint32(format.GreenMax), uint32(format.BlueMax)
+	}
+	scale := func(v uint16, max uint32) uint8 {
+		if max == 0 {
+			return 0
+		}
+		return uint8(uint32(v) * 255 / max)
+	}
+
+	for y := 0; y < int(rect.Height); y++ {
+		for x := 0; x < int(rect.Width); x++ {
+			i := y*int(rect.Width) + x
+			if i >= len(raw.Colors) {
+				return
+			}
+			c := raw.Colors[i]
+			img.Set(int(rect.X)+x, int(rect.Y)+y, color.RGBA{
+				R: scale(c.R, redMax),
+				G: scale(c.G, greenMax),
+				B: scale(c.B, blueMax),
+				A: 255,
+			})
+		}
+	}
+}
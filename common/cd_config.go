@@ -0,0 +1,59 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// CDConfig is the configuration of a CD built from local files and content
+// and attached to the VM in addition to the installation ISO, e.g. for
+// unattended installs that can't read their answer file from a floppy.
+type CDConfig struct {
+	CDFiles   []string          `mapstructure:"cd_files"`
+	CDContent map[string]string `mapstructure:"cd_content"`
+	CDLabel   string            `mapstructure:"cd_label"`
+}
+
+func (c *CDConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	var err error
+
+	if c.CDFiles == nil {
+		c.CDFiles = make([]string, 0)
+	}
+
+	for _, path := range c.CDFiles {
+		if strings.ContainsAny(path, "*?[") {
+			_, err = filepath.Glob(path)
+		} else {
+			_, err = os.Stat(path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Bad CD disk file '%s': %s", path, err))
+		}
+	}
+
+	// The content is written below the root of the CD, so its paths must
+	// be clean relative paths that stay there.
+	for name := range c.CDContent {
+		slashed := filepath.ToSlash(name)
+		if slashed == "" || slashed == "." || path.IsAbs(slashed) || filepath.IsAbs(name) ||
+			path.Clean(slashed) != slashed || slashed == ".." || strings.HasPrefix(slashed, "../") {
+			errs = append(errs, fmt.Errorf("Bad CD disk content path '%s': must be a relative path to a file", name))
+		}
+	}
+
+	if c.CDLabel == "" {
+		c.CDLabel = "packer"
+	}
+	if len(c.CDLabel) > 32 {
+		errs = append(errs, fmt.Errorf("cd_label must be at most 32 characters long"))
+	}
+
+	return errs
+}
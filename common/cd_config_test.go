@@ -0,0 +1,44 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCDConfigPrepare(t *testing.T) {
+	cases := []struct {
+		Config CDConfig
+		Err    bool
+	}{
+		{CDConfig{}, false},
+		{CDConfig{CDFiles: []string{"cd_config.go"}}, false},
+		{CDConfig{CDFiles: []string{"cd_config.foo"}}, true},
+		{CDConfig{CDFiles: []string{"*.go"}}, false},
+		{CDConfig{CDContent: map[string]string{"autounattend.xml": "<unattend/>"}}, false},
+		{CDConfig{CDContent: map[string]string{"scripts/setup.ps1": ""}}, false},
+		{CDConfig{CDContent: map[string]string{"": ""}}, true},
+		{CDConfig{CDContent: map[string]string{"/etc/passwd": ""}}, true},
+		{CDConfig{CDContent: map[string]string{"../outside": ""}}, true},
+		{CDConfig{CDContent: map[string]string{"a/../../outside": ""}}, true},
+		{CDConfig{CDContent: map[string]string{"dir/": ""}}, true},
+		{CDConfig{CDLabel: "cidata"}, false},
+		{CDConfig{CDLabel: strings.Repeat("a", 33)}, true},
+	}
+
+	for _, tc := range cases {
+		errs := tc.Config.Prepare(nil)
+		if (len(errs) > 0) != tc.Err {
+			t.Fatalf("%#v: bad errors: %v", tc.Config, errs)
+		}
+	}
+}
+
+func TestCDConfigPrepare_defaultLabel(t *testing.T) {
+	c := CDConfig{}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	if c.CDLabel != "packer" {
+		t.Fatalf("bad label: %s", c.CDLabel)
+	}
+}
@@ -1,3 +1,6 @@
+// Package chroot implements the steps shared by the chroot builders, which
+// build images by mounting a device attached to the machine Packer runs on
+// and provisioning it in a chroot, without launching a new machine.
 package chroot
 
 import (
@@ -0,0 +1,85 @@
+package chroot
+
+import (
+	"errors"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// Config is the configuration of the chroot shared by the chroot
+// builders.
+type Config struct {
+	ChrootMounts      [][]string `mapstructure:"chroot_mounts"`
+	CommandWrapper    string     `mapstructure:"command_wrapper"`
+	CopyFiles         []string   `mapstructure:"copy_files"`
+	MountOptions      []string   `mapstructure:"mount_options"`
+	MountPartition    int        `mapstructure:"mount_partition"`
+	MountPath         string     `mapstructure:"mount_path"`
+	PostMountCommands []string   `mapstructure:"post_mount_commands"`
+	PreMountCommands  []string   `mapstructure:"pre_mount_commands"`
+}
+
+// FilterExclude are the options that are interpolated when they are used
+// rather than when the configuration is decoded.
+var FilterExclude = []string{
+	"command_wrapper",
+	"post_mount_commands",
+	"pre_mount_commands",
+	"mount_path",
+}
+
+type wrappedCommandTemplate struct {
+	Command string
+}
+
+// Prepare sets the defaults of the options, with the mount path
+// defaulting to defaultMountPath, and validates them.
+func (c *Config) Prepare(ctx *interpolate.Context, defaultMountPath string) []error {
+	if len(c.ChrootMounts) == 0 {
+		c.ChrootMounts = [][]string{
+			{"proc", "proc", "/proc"},
+			{"sysfs", "sysfs", "/sys"},
+			{"bind", "/dev", "/dev"},
+			{"devpts", "devpts", "/dev/pts"},
+			{"binfmt_misc", "binfmt_misc", "/proc/sys/fs/binfmt_misc"},
+		}
+	}
+
+	if c.CopyFiles == nil {
+		c.CopyFiles = []string{"/etc/resolv.conf"}
+	}
+
+	if c.CommandWrapper == "" {
+		c.CommandWrapper = "{{.Command}}"
+	}
+
+	if c.MountPath == "" {
+		c.MountPath = defaultMountPath
+	}
+
+	if c.MountPartition == 0 {
+		c.MountPartition = 1
+	}
+
+	var errs []error
+	for _, mounts := range c.ChrootMounts {
+		if len(mounts) != 3 {
+			errs = append(errs, errors.New("Each chroot_mounts entry should be three elements."))
+			break
+		}
+	}
+	if c.MountPartition < 0 {
+		errs = append(errs, errors.New("mount_partition can't be negative"))
+	}
+
+	return errs
+}
+
+// WrappedCommand returns the CommandWrapper rendering command_wrapper.
+func (c *Config) WrappedCommand(ctx interpolate.Context) CommandWrapper {
+	return func(command string) (string, error) {
+		ctx := ctx
+		ctx.Data = &wrappedCommandTemplate{Command: command}
+		return interpolate.Render(c.CommandWrapper, &ctx)
+	}
+}
@@ -0,0 +1,36 @@
+package chroot
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+func TestConfigPrepare(t *testing.T) {
+	var c Config
+	if errs := c.Prepare(&interpolate.Context{}, "/mnt/packer/{{.Device}}"); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if len(c.ChrootMounts) != 5 || len(c.CopyFiles) != 1 || c.MountPartition != 1 {
+		t.Fatalf("bad defaults: %#v", c)
+	}
+	if c.MountPath != "/mnt/packer/{{.Device}}" {
+		t.Fatalf("bad mount path: %s", c.MountPath)
+	}
+
+	c = Config{ChrootMounts: [][]string{{"bind", "/dev"}}}
+	if errs := c.Prepare(&interpolate.Context{}, "/mnt"); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestConfigWrappedCommand(t *testing.T) {
+	c := Config{CommandWrapper: "sudo {{.Command}}"}
+	command, err := c.WrappedCommand(interpolate.Context{})("mount /dev/sdc1 /mnt")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if command != "sudo mount /dev/sdc1 /mnt" {
+		t.Fatalf("bad command: %s", command)
+	}
+}
@@ -0,0 +1,30 @@
+package chroot
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// devicePollInterval is how often WaitForDevice checks for the device.
+var devicePollInterval = time.Second
+
+// WaitForDevice waits for the device at path, like a link of /dev/disk, to
+// appear after it was attached.
+func WaitForDevice(path string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for the device %s to appear", path)
+		}
+		time.Sleep(devicePollInterval)
+	}
+}
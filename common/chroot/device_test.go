@@ -0,0 +1,33 @@
+package chroot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitForDevice(t *testing.T) {
+	defer func(d time.Duration) { devicePollInterval = d }(devicePollInterval)
+	devicePollInterval = time.Millisecond
+
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "lun0")
+	if err := WaitForDevice(path, 10*time.Millisecond); err == nil {
+		t.Fatal("should time out")
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		ioutil.WriteFile(path, nil, 0644)
+	}()
+	if err := WaitForDevice(path, time.Second); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -2,6 +2,7 @@ package chroot
 
 import (
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
 )
 
@@ -14,10 +15,12 @@ type postMountCommandsData struct {
 // device, but prior to the bind mount and copy steps.
 type StepPostMountCommands struct {
 	Commands []string
+
+	// Ctx is used to render the commands.
+	Ctx interpolate.Context
 }
 
 func (s *StepPostMountCommands) Run(state multistep.StateBag) multistep.StepAction {
-	config := state.Get("config").(*Config)
 	device := state.Get("device").(string)
 	mountPath := state.Get("mount_path").(string)
 	ui := state.Get("ui").(packer.Ui)
@@ -27,7 +30,7 @@ func (s *StepPostMountCommands) Run(state multistep.StateBag) multistep.StepActi
 		return multistep.ActionContinue
 	}
 
-	ctx := config.ctx
+	ctx := s.Ctx
 	ctx.Data = &postMountCommandsData{
 		Device:    device,
 		MountPath: mountPath,
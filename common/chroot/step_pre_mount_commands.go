@@ -2,6 +2,7 @@ package chroot
 
 import (
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
 )
 
@@ -12,10 +13,12 @@ type preMountCommandsData struct {
 // StepPreMountCommands sets up the a new block device when building from scratch
 type StepPreMountCommands struct {
 	Commands []string
+
+	// Ctx is used to render the commands.
+	Ctx interpolate.Context
 }
 
 func (s *StepPreMountCommands) Run(state multistep.StateBag) multistep.StepAction {
-	config := state.Get("config").(*Config)
 	device := state.Get("device").(string)
 	ui := state.Get("ui").(packer.Ui)
 	wrappedCommand := state.Get("wrappedCommand").(CommandWrapper)
@@ -24,7 +27,7 @@ func (s *StepPreMountCommands) Run(state multistep.StateBag) multistep.StepActio
 		return multistep.ActionContinue
 	}
 
-	ctx := config.ctx
+	ctx := s.Ctx
 	ctx.Data = &preMountCommandsData{Device: device}
 
 	ui.Say("Running device setup commands...")
@@ -0,0 +1,168 @@
+// Package consoles keeps track of the consoles of the machines of running
+// headless builds, such as the VNC server of a VM, so that `packer
+// console-attach` can tell how to look at the screen of a build without
+// restarting it with a GUI.
+package consoles
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/common/resources"
+	"github.com/hashicorp/packer/packer"
+)
+
+// EnvConsolesDir is the environment variable that overrides the directory
+// the consoles are recorded in.
+const EnvConsolesDir = "PACKER_CONSOLES_DIR"
+
+// Console is the console of the machine of a build.
+type Console struct {
+	// Build is the name of the build.
+	Build string
+
+	// Builder is the type of the builder, e.g. qemu.
+	Builder string
+
+	// URL is where a client connects to the console, e.g.
+	// vnc://127.0.0.1:5901.
+	URL string `json:",omitempty"`
+
+	// Password is the password of the console, if any.
+	Password string `json:",omitempty"`
+
+	// Command opens the console, for consoles without a URL like the one
+	// of Hyper-V.
+	Command string `json:",omitempty"`
+
+	// Pid is the process of the builder, the console is gone once it
+	// exited.
+	Pid     int
+	Started time.Time
+}
+
+func (c *Console) fileName() string {
+	h := sha1.Sum([]byte(c.Build))
+	return fmt.Sprintf("%s-%d.json", hex.EncodeToString(h[:8]), c.Pid)
+}
+
+// Dir returns the directory the consoles are recorded in.
+func Dir() (string, error) {
+	if dir := os.Getenv(EnvConsolesDir); dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	configDir, err := packer.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "consoles"), nil
+}
+
+// Record records the console of a build of the current process. Failing
+// to record it doesn't fail the build, so errors are only logged.
+func Record(c Console) {
+	c.Pid = os.Getpid()
+	if c.Started.IsZero() {
+		c.Started = time.Now()
+	}
+
+	dir, err := Dir()
+	if err == nil {
+		err = writeJSON(filepath.Join(dir, c.fileName()), &c)
+	}
+	if err != nil {
+		log.Printf("[WARN] Not recording the console of %s: %s", c.Build, err)
+		return
+	}
+	log.Printf("[DEBUG] Recorded the console of %s: %s", c.Build, c.URL+c.Command)
+}
+
+// Forget forgets the console of a build of the current process once its
+// machine stopped.
+func Forget(build string) {
+	dir, err := Dir()
+	if err != nil {
+		return
+	}
+	c := Console{Build: build, Pid: os.Getpid()}
+	if err := os.Remove(filepath.Join(dir, c.fileName())); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Error forgetting the console of %s: %s", build, err)
+	}
+}
+
+// Consoles returns the consoles of the running builds, oldest first. The
+// records of builds whose process exited are removed.
+func Consoles() ([]*Console, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Console
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+
+		c := new(Console)
+		if err := readJSON(path, c); err != nil {
+			return nil, err
+		}
+		if !resources.ProcessAlive(c.Pid) {
+			os.Remove(path)
+			continue
+		}
+		result = append(result, c)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result, nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The record can hold the password of the console
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	return nil
+}
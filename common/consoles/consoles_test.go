@@ -0,0 +1,59 @@
+package consoles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testDir(t *testing.T) (string, func()) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	os.Setenv(EnvConsolesDir, td)
+	return td, func() {
+		os.Unsetenv(EnvConsolesDir)
+		os.RemoveAll(td)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	now := time.Now()
+	Record(Console{Build: "ubuntu", Builder: "qemu", URL: "vnc://127.0.0.1:5901", Started: now.Add(time.Second)})
+	Record(Console{Build: "windows", Builder: "hyperv-iso", Command: `vmconnect.exe localhost "packer-windows"`, Started: now})
+
+	// A console of a process that exited
+	stale := &Console{Build: "old", Pid: 999999999}
+	if err := writeJSON(filepath.Join(dir, stale.fileName()), stale); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	consoles, err := Consoles()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(consoles) != 2 || consoles[0].Build != "windows" || consoles[1].Build != "ubuntu" {
+		t.Fatalf("bad: %#v", consoles)
+	}
+	if consoles[1].Pid != os.Getpid() || consoles[1].URL != "vnc://127.0.0.1:5901" {
+		t.Fatalf("bad: %#v", consoles[1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, stale.fileName())); !os.IsNotExist(err) {
+		t.Fatal("the stale console should be removed")
+	}
+
+	Forget("ubuntu")
+	consoles, err = Consoles()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(consoles) != 1 || consoles[0].Build != "windows" {
+		t.Fatalf("bad: %#v", consoles)
+	}
+}
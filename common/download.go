@@ -7,15 +7,20 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // DownloadConfig is the configuration given to instantiate a new
@@ -47,6 +52,16 @@ type DownloadConfig struct {
 	// What to use for the user agent for HTTP requests. If set to "", use the
 	// default user agent provided by Go.
 	UserAgent string
+
+	// The URL of the proxy to use for HTTP requests. If set to "", the
+	// proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	Proxy string
+
+	// The number of segments to download in parallel from HTTP servers
+	// that support range requests. Zero or one downloads the file in a
+	// single request.
+	Segments int
 }
 
 // A DownloadClient helps download, verify checksums, etc.
@@ -77,8 +92,8 @@ func HashForType(t string) hash.Hash {
 func NewDownloadClient(c *DownloadConfig) *DownloadClient {
 	if c.DownloaderMap == nil {
 		c.DownloaderMap = map[string]Downloader{
-			"http":  &HTTPDownloader{userAgent: c.UserAgent},
-			"https": &HTTPDownloader{userAgent: c.UserAgent},
+			"http":  &HTTPDownloader{userAgent: c.UserAgent, proxy: c.Proxy, segments: c.Segments},
+			"https": &HTTPDownloader{userAgent: c.UserAgent, proxy: c.Proxy, segments: c.Segments},
 		}
 	}
 
@@ -148,8 +163,20 @@ func (d *DownloadClient) Get() (string, error) {
 			return "", fmt.Errorf("No downloader for scheme: %s", u.Scheme)
 		}
 
-		// Otherwise, download using the downloader.
-		f, err = os.OpenFile(finalPath, os.O_RDWR|os.O_CREATE, os.FileMode(0666))
+		// Otherwise, download using the downloader. The file is downloaded
+		// next to the target and renamed once complete and verified, so
+		// that the target is never a partial or corrupt file, even for
+		// other Packer processes sharing the cache.
+		partPath := finalPath + ".part"
+		if _, err := os.Stat(partPath); os.IsNotExist(err) {
+			// Resume from the target, such as a file downloaded without a
+			// checksum that is downloaded again
+			if err := os.Rename(finalPath, partPath); err != nil && !os.IsNotExist(err) {
+				log.Printf("[DEBUG] Error resuming from %s: %s", finalPath, err)
+			}
+		}
+
+		f, err = os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, os.FileMode(0666))
 		if err != nil {
 			return "", err
 		}
@@ -160,6 +187,25 @@ func (d *DownloadClient) Get() (string, error) {
 		if err != nil {
 			return "", err
 		}
+
+		if d.config.Hash != nil {
+			verify, err := d.VerifyChecksum(partPath)
+			if err != nil {
+				return "", err
+			}
+			if !verify {
+				os.Remove(partPath)
+				return "", fmt.Errorf(
+					"checksums didn't match expected: %s",
+					hex.EncodeToString(d.config.Checksum))
+			}
+		}
+
+		if err := os.Rename(partPath, finalPath); err != nil {
+			return "", err
+		}
+
+		return finalPath, nil
 	}
 
 	if d.config.Hash != nil {
@@ -180,6 +226,16 @@ func (d *DownloadClient) Get() (string, error) {
 	return finalPath, err
 }
 
+// Progress returns the bytes downloaded so far, and the size of the
+// download, zero if it isn't known yet.
+func (d *DownloadClient) Progress() (int64, int64) {
+	if d.downloader == nil {
+		return 0, 0
+	}
+
+	return int64(d.downloader.Progress()), int64(d.downloader.Total())
+}
+
 // PercentProgress returns the download progress as a percentage.
 func (d *DownloadClient) PercentProgress() int {
 	if d.downloader == nil {
@@ -208,12 +264,40 @@ func (d *DownloadClient) VerifyChecksum(path string) (bool, error) {
 	return bytes.Equal(d.config.Hash.Sum(nil), d.config.Checksum), nil
 }
 
+// minSegmentSize is the smallest size of the segments of a download, so
+// that small files aren't split into many requests. It is replaced in tests.
+var minSegmentSize int64 = 8 * 1024 * 1024
+
+// HTTPClient returns an HTTP client using the given proxy URL, or the
+// proxy from the environment if it is empty.
+func HTTPClient(proxy string) (*http.Client, error) {
+	proxyFunc := http.ProxyFromEnvironment
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing proxy URL: %s", err)
+		}
+		proxyFunc = http.ProxyURL(u)
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: proxyFunc,
+		},
+	}, nil
+}
+
 // HTTPDownloader is an implementation of Downloader that downloads
-// files over HTTP.
+// files over HTTP. Files from servers that support range requests are
+// downloaded in segments in parallel, and interrupted downloads are
+// resumed.
 type HTTPDownloader struct {
-	progress  uint
+	// progress is first so that it is aligned for atomic operations.
+	progress  uint64
 	total     uint
 	userAgent string
+	proxy     string
+	segments  int
 }
 
 func (*HTTPDownloader) Cancel() {
@@ -229,49 +313,82 @@ func (d *HTTPDownloader) Download(dst *os.File, src *url.URL) error {
 	}
 
 	// Reset our progress
-	d.progress = 0
+	atomic.StoreUint64(&d.progress, 0)
+
+	httpClient, err := HTTPClient(d.proxy)
+	if err != nil {
+		return err
+	}
 
 	// Make the request. We first make a HEAD request so we can check
 	// if the server supports range queries. If the server/URL doesn't
 	// support HEAD requests, we just fall back to GET.
-	req, err := http.NewRequest("HEAD", src.String(), nil)
+	req, err := d.newRequest("HEAD", src)
 	if err != nil {
 		return err
 	}
 
-	if d.userAgent != "" {
-		req.Header.Set("User-Agent", d.userAgent)
-	}
-
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-		},
+	ranges := false
+	size := int64(-1)
+	resp, err := httpClient.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			ranges = resp.Header.Get("Accept-Ranges") == "bytes"
+			size = resp.ContentLength
+		}
 	}
 
-	resp, err := httpClient.Do(req)
-	if err == nil && (resp.StatusCode >= 200 && resp.StatusCode < 300) {
-		// If the HEAD request succeeded, then attempt to set the range
-		// query if we can.
-		if resp.Header.Get("Accept-Ranges") == "bytes" {
-			if fi, err := dst.Stat(); err == nil {
-				if _, err = dst.Seek(0, os.SEEK_END); err == nil {
-					req.Header.Set("Range", fmt.Sprintf("bytes=%d-", fi.Size()))
-					d.progress = uint(fi.Size())
-				}
-			}
+	if ranges && size > 0 {
+		segments := d.segments
+		if max := int(size / minSegmentSize); segments > max {
+			segments = max
+		}
+		if segments > 1 {
+			return d.downloadSegments(httpClient, dst, src, size, segments)
 		}
 	}
 
 	// Set the request to GET now, and redo the query to download
 	req.Method = "GET"
 
+	// If the server supports range queries, resume the download from the
+	// end of the file.
+	resumed := false
+	if ranges {
+		if fi, err := dst.Stat(); err == nil && fi.Size() > 0 {
+			if _, err = dst.Seek(0, os.SEEK_END); err == nil {
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-", fi.Size()))
+				atomic.StoreUint64(&d.progress, uint64(fi.Size()))
+				resumed = true
+			}
+		}
+	}
+
 	resp, err = httpClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	d.total = d.progress + uint(resp.ContentLength)
+	switch {
+	case resumed && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The file was already completely downloaded.
+		return nil
+	case resumed && resp.StatusCode == http.StatusOK:
+		// The server ignored the range, start over.
+		if err := dst.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := dst.Seek(0, 0); err != nil {
+			return err
+		}
+		atomic.StoreUint64(&d.progress, 0)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("Error downloading %s: %s", src.String(), resp.Status)
+	}
+
+	d.total = uint(atomic.LoadUint64(&d.progress)) + uint(resp.ContentLength)
 	var buffer [4096]byte
 	for {
 		n, err := resp.Body.Read(buffer[:])
@@ -279,7 +396,7 @@ func (d *HTTPDownloader) Download(dst *os.File, src *url.URL) error {
 			return err
 		}
 
-		d.progress += uint(n)
+		atomic.AddUint64(&d.progress, uint64(n))
 
 		if _, werr := dst.Write(buffer[:n]); werr != nil {
 			return werr
@@ -293,8 +410,189 @@ func (d *HTTPDownloader) Download(dst *os.File, src *url.URL) error {
 	return nil
 }
 
+// downloadSegment is a byte range of a download. End is exclusive and
+// Done is the number of bytes of the range that were downloaded.
+type downloadSegment struct {
+	Start int64
+	End   int64
+	Done  int64
+}
+
+// downloadSegments is the state of a segmented download, saved next to
+// the file being downloaded so that an interrupted download is resumed.
+type downloadSegments struct {
+	Size     int64
+	Segments []*downloadSegment
+}
+
+// downloadSegments downloads the segments of the file in parallel, resuming
+// from the saved state if there is one for a file of the same size.
+func (d *HTTPDownloader) downloadSegments(client *http.Client, dst *os.File, src *url.URL, size int64, count int) error {
+	statePath := dst.Name() + ".segments"
+	state := readDownloadSegments(statePath, size)
+	if state == nil {
+		if err := dst.Truncate(0); err != nil {
+			return err
+		}
+		state = &downloadSegments{Size: size}
+		for i := 0; i < count; i++ {
+			state.Segments = append(state.Segments, &downloadSegment{
+				Start: size * int64(i) / int64(count),
+				End:   size * int64(i+1) / int64(count),
+			})
+		}
+	} else {
+		log.Printf("Resuming download of %s", src.String())
+	}
+
+	if err := dst.Truncate(size); err != nil {
+		return err
+	}
+
+	var l sync.Mutex
+	save := func() {
+		l.Lock()
+		defer l.Unlock()
+		data, err := json.Marshal(state)
+		if err == nil {
+			err = ioutil.WriteFile(statePath, data, 0644)
+		}
+		if err != nil {
+			log.Printf("[WARN] Error saving download state: %s", err)
+		}
+	}
+
+	d.total = uint(size)
+	errCh := make(chan error, len(state.Segments))
+	var wg sync.WaitGroup
+	for _, segment := range state.Segments {
+		atomic.AddUint64(&d.progress, uint64(segment.Done))
+		if segment.Start+segment.Done >= segment.End {
+			continue
+		}
+
+		wg.Add(1)
+		go func(segment *downloadSegment) {
+			defer wg.Done()
+			errCh <- d.downloadSegment(client, dst, src, segment, &l)
+		}(segment)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Save the state regularly, so that the download is resumed even if
+	// Packer is killed.
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-ticker.C:
+			save()
+		case <-done:
+			running = false
+		}
+	}
+
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			save()
+			return err
+		}
+	}
+
+	os.Remove(statePath)
+	return nil
+}
+
+// downloadSegment downloads the rest of a segment with a range request.
+// l guards the progress of the segment.
+func (d *HTTPDownloader) downloadSegment(client *http.Client, dst *os.File, src *url.URL, segment *downloadSegment, l sync.Locker) error {
+	l.Lock()
+	offset := segment.Start + segment.Done
+	l.Unlock()
+
+	req, err := d.newRequest("GET", src)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, segment.End-1))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("Error downloading %s: %s", src.String(), resp.Status)
+	}
+
+	var buffer [32 * 1024]byte
+	for offset < segment.End {
+		n, err := resp.Body.Read(buffer[:])
+		if int64(n) > segment.End-offset {
+			n = int(segment.End - offset)
+		}
+		if n > 0 {
+			if _, werr := dst.WriteAt(buffer[:n], offset); werr != nil {
+				return werr
+			}
+			offset += int64(n)
+			atomic.AddUint64(&d.progress, uint64(n))
+
+			l.Lock()
+			segment.Done = offset - segment.Start
+			l.Unlock()
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if offset < segment.End {
+		return fmt.Errorf("Error downloading %s: %s", src.String(), io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// readDownloadSegments reads the saved state of a segmented download. It
+// returns nil if there is none for a file of the given size.
+func readDownloadSegments(path string, size int64) *downloadSegments {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var state downloadSegments
+	if err := json.Unmarshal(data, &state); err != nil || state.Size != size {
+		return nil
+	}
+	return &state
+}
+
+func (d *HTTPDownloader) newRequest(method string, src *url.URL) (*http.Request, error) {
+	req, err := http.NewRequest(method, src.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.userAgent != "" {
+		req.Header.Set("User-Agent", d.userAgent)
+	}
+	return req, nil
+}
+
 func (d *HTTPDownloader) Progress() uint {
-	return d.progress
+	return uint(atomic.LoadUint64(&d.progress))
 }
 
 func (d *HTTPDownloader) Total() uint {
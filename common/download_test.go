@@ -1,6 +1,7 @@
 package common
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
@@ -9,7 +10,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDownloadClientVerifyChecksum(t *testing.T) {
@@ -384,3 +387,152 @@ func TestDownloadFileUrl(t *testing.T) {
 	}
 
 }
+
+func testSegmentsServer(t *testing.T, content []byte, served *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			if r.Header.Get("Range") == "" {
+				t.Errorf("download should use range requests")
+			}
+			rw = &countingResponseWriter{rw, served}
+		}
+		http.ServeContent(rw, r, "file.iso", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written *int64
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	atomic.AddInt64(w.written, int64(n))
+	return n, err
+}
+
+func TestDownloadClient_segments(t *testing.T) {
+	defer func(size int64) { minSegmentSize = size }(minSegmentSize)
+	minSegmentSize = 16
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	var served int64
+	ts := testSegmentsServer(t, content, &served)
+	defer ts.Close()
+
+	tf, _ := ioutil.TempFile("", "packer")
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	sum := md5.Sum(content)
+	client := NewDownloadClient(&DownloadConfig{
+		Url:        ts.URL,
+		TargetPath: tf.Name(),
+		Hash:       HashForType("md5"),
+		Checksum:   sum[:],
+		Segments:   4,
+	})
+	path, err := client.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(raw, content) {
+		t.Fatalf("bad: %s", string(raw))
+	}
+	if served != int64(len(content)) {
+		t.Fatalf("bad served: %d", served)
+	}
+	if _, err := os.Stat(path + ".part.segments"); !os.IsNotExist(err) {
+		t.Fatalf("segments state should be removed: %s", err)
+	}
+}
+
+func TestDownloadClient_segmentsResume(t *testing.T) {
+	defer func(size int64) { minSegmentSize = size }(minSegmentSize)
+	minSegmentSize = 16
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	var served int64
+	ts := testSegmentsServer(t, content, &served)
+	defer ts.Close()
+
+	// The first half of both segments was downloaded before.
+	partial := make([]byte, len(content))
+	copy(partial[:250], content[:250])
+	copy(partial[500:750], content[500:750])
+	tf, _ := ioutil.TempFile("", "packer")
+	tf.Close()
+	os.Remove(tf.Name())
+	defer os.Remove(tf.Name())
+	if err := ioutil.WriteFile(tf.Name()+".part", partial, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state := `{"Size":1000,"Segments":[{"Start":0,"End":500,"Done":250},{"Start":500,"End":1000,"Done":250}]}`
+	if err := ioutil.WriteFile(tf.Name()+".part.segments", []byte(state), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name() + ".part.segments")
+
+	client := NewDownloadClient(&DownloadConfig{
+		Url:        ts.URL,
+		TargetPath: tf.Name(),
+		Segments:   2,
+	})
+	path, err := client.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(raw, content) {
+		t.Fatalf("bad: %s", string(raw))
+	}
+	if served != 500 {
+		t.Fatalf("should only download the rest, served: %d", served)
+	}
+}
+
+func TestDownloadClient_proxy(t *testing.T) {
+	asserted := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Proxied requests have the absolute URL of the target.
+		if r.URL.Host == "example.invalid" {
+			asserted = true
+		}
+		rw.Write([]byte("proxied\n"))
+	}))
+	defer proxy.Close()
+
+	tf, _ := ioutil.TempFile("", "packer")
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	client := NewDownloadClient(&DownloadConfig{
+		Url:        "http://example.invalid/file.iso",
+		TargetPath: tf.Name(),
+		Proxy:      proxy.URL,
+	})
+	path, err := client.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(raw) != "proxied\n" {
+		t.Fatalf("bad: %s", string(raw))
+	}
+	if !asserted {
+		t.Fatal("request should go through the proxy")
+	}
+}
@@ -0,0 +1,381 @@
+// Package history records the jobs of `packer build` and `packer serve`:
+// their builds, how long they took, their artifacts and their output, so
+// that past builds can be looked up with `packer history` instead of
+// being tracked by hand.
+//
+// Every job is a directory of the history directory, named by the ID of
+// the job, with the record of the job, job.json, and its output,
+// output.log.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/common/resources"
+	"github.com/hashicorp/packer/packer"
+)
+
+// EnvHistoryDir is the environment variable that overrides the directory
+// the history is recorded in.
+const EnvHistoryDir = "PACKER_HISTORY_DIR"
+
+const (
+	jobFile = "job.json"
+	logFile = "output.log"
+)
+
+// The states of jobs and of their builds.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+
+	// StateInterrupted is the state of the jobs whose process exited
+	// while they were running, so they never recorded their result.
+	StateInterrupted = "interrupted"
+)
+
+// Artifact is an artifact of a build.
+type Artifact struct {
+	Id        string
+	BuilderId string
+	String    string
+	Files     []string `json:",omitempty"`
+}
+
+// Build is a build of a job.
+type Build struct {
+	Name      string
+	State     string
+	Error     string     `json:",omitempty"`
+	Started   time.Time  `json:",omitempty"`
+	Finished  time.Time  `json:",omitempty"`
+	Artifacts []Artifact `json:",omitempty"`
+}
+
+// Duration returns how long the build ran, or has been running.
+func (b *Build) Duration() time.Duration {
+	return duration(b.Started, b.Finished)
+}
+
+// Job is a run of the builds of a template.
+type Job struct {
+	ID string
+
+	// Command is the command that ran the job, build or serve.
+	Command string
+
+	// Template is the path of the template, if it was read from a file.
+	Template string `json:",omitempty"`
+
+	State    string
+	Pid      int
+	Started  time.Time
+	Finished time.Time `json:",omitempty"`
+	Builds   []Build
+
+	dir string
+}
+
+// Duration returns how long the job ran, or has been running.
+func (j *Job) Duration() time.Duration {
+	return duration(j.Started, j.Finished)
+}
+
+// Output opens the output of the job.
+func (j *Job) Output() (io.ReadCloser, error) {
+	return os.Open(filepath.Join(j.dir, logFile))
+}
+
+// Remove removes the job from the history.
+func (j *Job) Remove() error {
+	return os.RemoveAll(j.dir)
+}
+
+func (j *Job) build(name string) *Build {
+	for i := range j.Builds {
+		if j.Builds[i].Name == name {
+			return &j.Builds[i]
+		}
+	}
+	return nil
+}
+
+func duration(start, end time.Time) time.Duration {
+	if start.IsZero() {
+		return 0
+	}
+	if end.IsZero() {
+		return time.Since(start)
+	}
+	return end.Sub(start)
+}
+
+// Dir returns the directory the history is recorded in.
+func Dir() (string, error) {
+	if dir := os.Getenv(EnvHistoryDir); dir != "" {
+		return filepath.Abs(dir)
+	}
+	configDir, err := packer.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "history"), nil
+}
+
+// Record records a running job. Failing to record it doesn't fail the
+// builds, so errors are only logged, and the methods of a nil Record do
+// nothing.
+type Record struct {
+	l      sync.Mutex
+	job    Job
+	out    *os.File
+	redact func(string) string
+}
+
+// Start records a job with the given builds, which starts running. The
+// output and the errors of the job are recorded as redact returns them,
+// if not nil, and only the user can read the record.
+func Start(id, command, template string, builds []string, redact func(string) string) (*Record, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	out, err := os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{
+		job: Job{
+			ID:       id,
+			Command:  command,
+			Template: template,
+			State:    StateRunning,
+			Pid:      os.Getpid(),
+			Started:  time.Now().UTC(),
+			dir:      dir,
+		},
+		out:    out,
+		redact: redact,
+	}
+	for _, name := range builds {
+		r.job.Builds = append(r.job.Builds, Build{Name: name, State: StatePending})
+	}
+	if err := r.save(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output records output of the job, line by line.
+func (r *Record) Output(message string) {
+	if r == nil {
+		return
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	message = r.redacted(message)
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		if _, err := fmt.Fprintf(r.out, "%s %s\n", now, line); err != nil {
+			log.Printf("[WARN] Error recording the output of job %s: %s", r.job.ID, err)
+			return
+		}
+	}
+}
+
+// StartBuild records that a build started.
+func (r *Record) StartBuild(name string) {
+	if r == nil {
+		return
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if b := r.job.build(name); b != nil {
+		b.State = StateRunning
+		b.Started = time.Now().UTC()
+	}
+	r.saveOrLog()
+}
+
+// FinishBuild records the result of a build.
+func (r *Record) FinishBuild(name, state string, artifacts []packer.Artifact, err error) {
+	if r == nil {
+		return
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	b := r.job.build(name)
+	if b == nil {
+		return
+	}
+	b.State = state
+	b.Finished = time.Now().UTC()
+	if err != nil {
+		b.Error = r.redacted(err.Error())
+	}
+	for _, a := range artifacts {
+		if a == nil {
+			continue
+		}
+		b.Artifacts = append(b.Artifacts, Artifact{
+			Id:        a.Id(),
+			BuilderId: a.BuilderId(),
+			String:    r.redacted(a.String()),
+			Files:     a.Files(),
+		})
+	}
+	r.saveOrLog()
+}
+
+// Finish records the result of the job. Its builds that didn't finish
+// are recorded as cancelled.
+func (r *Record) Finish(state string) {
+	if r == nil {
+		return
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	r.job.State = state
+	r.job.Finished = time.Now().UTC()
+	for i := range r.job.Builds {
+		if b := &r.job.Builds[i]; b.Finished.IsZero() {
+			b.State = StateCancelled
+		}
+	}
+	r.saveOrLog()
+	if err := r.out.Close(); err != nil {
+		log.Printf("[WARN] Error recording the output of job %s: %s", r.job.ID, err)
+	}
+}
+
+// redacted returns s redacted, if the record redacts.
+func (r *Record) redacted(s string) string {
+	if r.redact == nil {
+		return s
+	}
+	return r.redact(s)
+}
+
+func (r *Record) saveOrLog() {
+	if err := r.save(); err != nil {
+		log.Printf("[WARN] Error recording job %s: %s", r.job.ID, err)
+	}
+}
+
+// save writes the record of the job to a temporary file first, so a
+// crash never leaves a partial record behind.
+func (r *Record) save() error {
+	data, err := json.MarshalIndent(&r.job, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.job.dir, jobFile)
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Jobs returns the recorded jobs, oldest first.
+func Jobs() ([]*Job, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		job, err := readJob(filepath.Join(dir, info.Name()))
+		if os.IsNotExist(err) {
+			// Being created
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Started.Before(jobs[j].Started)
+	})
+	return jobs, nil
+}
+
+// Get returns the job with the given ID, or the only job whose ID starts
+// with it.
+func Get(id string) (*Job, error) {
+	jobs, err := Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Job
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, nil
+		}
+		if id == "" || !strings.HasPrefix(job.ID, id) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("Several jobs start with %s", id)
+		}
+		found = job
+	}
+	if found == nil {
+		return nil, fmt.Errorf("No job %s in the history", id)
+	}
+	return found, nil
+}
+
+func readJob(dir string) (*Job, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, jobFile))
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{dir: dir}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", filepath.Join(dir, jobFile), err)
+	}
+
+	// A job whose process is gone never gets to record its result
+	if job.State == StateRunning && job.Pid != os.Getpid() && !resources.ProcessAlive(job.Pid) {
+		job.State = StateInterrupted
+	}
+	return job, nil
+}
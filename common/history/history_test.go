@@ -0,0 +1,138 @@
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testDir(t *testing.T) func() {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	os.Setenv(EnvHistoryDir, td)
+	return func() {
+		os.Unsetenv(EnvHistoryDir)
+		os.RemoveAll(td)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	defer testDir(t)()
+
+	redact := func(s string) string {
+		return strings.Replace(s, "hunter2", "<sensitive>", -1)
+	}
+	r, err := Start("0f5c5b33-a", "build", "/templates/ubuntu.json", []string{"qemu", "vmware"}, redact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r.Output("==> qemu: Creating VM\n==> qemu: Starting VM with hunter2\n")
+	r.StartBuild("qemu")
+	r.FinishBuild("qemu", StateSucceeded, []packer.Artifact{&packer.MockArtifact{FilesValue: []string{"disk.qcow2"}}}, nil)
+	r.StartBuild("vmware")
+	r.FinishBuild("vmware", StateFailed, nil, errors.New("timeout logging in with hunter2"))
+
+	// The job can be read while it's running
+	job, err := Get("0f5c")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if job.State != StateRunning || job.Builds[0].State != StateSucceeded {
+		t.Fatalf("bad: %#v", job)
+	}
+
+	r.Finish(StateFailed)
+	job, err = Get("0f5c5b33-a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if job.State != StateFailed || job.Finished.IsZero() || job.Template != "/templates/ubuntu.json" {
+		t.Fatalf("bad: %#v", job)
+	}
+	qemu, vmware := job.Builds[0], job.Builds[1]
+	if len(qemu.Artifacts) != 1 || qemu.Artifacts[0].Files[0] != "disk.qcow2" || qemu.Duration() <= 0 {
+		t.Fatalf("bad: %#v", qemu)
+	}
+	if vmware.State != StateFailed || vmware.Error != "timeout logging in with <sensitive>" {
+		t.Fatalf("bad: %#v", vmware)
+	}
+
+	out, err := job.Output()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer out.Close()
+	data, _ := ioutil.ReadAll(out)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], " ==> qemu: Starting VM with <sensitive>") {
+		t.Fatalf("bad: %q", data)
+	}
+
+	// Only the user can read the record
+	for _, path := range []string{job.dir, filepath.Join(job.dir, jobFile), filepath.Join(job.dir, logFile)} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if fi.Mode().Perm()&0077 != 0 {
+			t.Fatalf("%s: bad: %s", path, fi.Mode())
+		}
+	}
+}
+
+func TestRecord_nil(t *testing.T) {
+	var r *Record
+	r.Output("foo")
+	r.StartBuild("qemu")
+	r.FinishBuild("qemu", StateSucceeded, nil, nil)
+	r.Finish(StateSucceeded)
+}
+
+func TestJobs(t *testing.T) {
+	defer testDir(t)()
+
+	for _, id := range []string{"ab1", "ab2", "c"} {
+		r, err := Start(id, "serve", "", []string{"qemu"}, nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if id == "c" {
+			// Its process exited without recording the result
+			r.job.Pid = 1 << 22
+			if err := r.save(); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			continue
+		}
+		r.Finish(StateSucceeded)
+	}
+
+	jobs, err := Jobs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(jobs) != 3 || jobs[0].ID != "ab1" || jobs[2].State != StateInterrupted {
+		t.Fatalf("bad: %#v", jobs)
+	}
+
+	if _, err := Get("ab"); err == nil || !strings.Contains(err.Error(), "Several jobs") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := Get("d"); err == nil {
+		t.Fatal("should error")
+	}
+
+	if err := jobs[0].Remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if job, err := Get("ab"); err != nil || job.ID != "ab2" {
+		t.Fatalf("bad: %v %v", job, err)
+	}
+}
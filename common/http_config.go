@@ -2,15 +2,30 @@ package common
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
 
 	"github.com/hashicorp/packer/template/interpolate"
 )
 
 // HTTPConfig contains configuration for the local HTTP Server
 type HTTPConfig struct {
-	HTTPDir     string `mapstructure:"http_directory"`
-	HTTPPortMin uint   `mapstructure:"http_port_min"`
-	HTTPPortMax uint   `mapstructure:"http_port_max"`
+	HTTPDir            string `mapstructure:"http_directory"`
+	HTTPPortMin        uint   `mapstructure:"http_port_min"`
+	HTTPPortMax        uint   `mapstructure:"http_port_max"`
+	HTTPAddress        string `mapstructure:"http_bind_address"`
+	HTTPTLS            bool   `mapstructure:"http_tls"`
+	HTTPRequireToken   bool   `mapstructure:"http_require_token"`
+	HTTPDisableListing bool   `mapstructure:"http_disable_directory_listing"`
+
+	// HTTPTemplates are glob patterns, relative to HTTPDir, of the files
+	// that are rendered with the template engine whenever they are served.
+	HTTPTemplates []string `mapstructure:"http_templates"`
+
+	// HTTPUploadDir is where files uploaded by the guest are saved. Uploads
+	// are disabled if it is empty.
+	HTTPUploadDir string `mapstructure:"http_upload_directory"`
 }
 
 func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
@@ -25,10 +40,26 @@ func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
 		c.HTTPPortMax = 9000
 	}
 
+	if c.HTTPAddress == "" {
+		c.HTTPAddress = "0.0.0.0"
+	}
+
 	if c.HTTPPortMin > c.HTTPPortMax {
 		errs = append(errs,
 			errors.New("http_port_min must be less than http_port_max"))
 	}
 
+	for _, pattern := range c.HTTPTemplates {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs,
+				fmt.Errorf("http_templates pattern is invalid: %s: %s", pattern, err))
+		}
+	}
+
+	if net.ParseIP(c.HTTPAddress) == nil {
+		errs = append(errs,
+			fmt.Errorf("http_bind_address is not a valid IP address: %s", c.HTTPAddress))
+	}
+
 	return errs
 }
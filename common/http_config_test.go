@@ -43,3 +43,38 @@ func TestHTTPConfigPrepare_Bounds(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestHTTPConfigPrepare_BindAddress(t *testing.T) {
+	// Test default
+	h := HTTPConfig{}
+	if errs := h.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+	if h.HTTPAddress != "0.0.0.0" {
+		t.Fatalf("bad: %s", h.HTTPAddress)
+	}
+
+	// Test good
+	h = HTTPConfig{HTTPAddress: "192.168.56.1"}
+	if errs := h.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+
+	// Test bad
+	h = HTTPConfig{HTTPAddress: "eth0"}
+	if errs := h.Prepare(nil); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestHTTPConfigPrepare_Templates(t *testing.T) {
+	h := HTTPConfig{HTTPTemplates: []string{"*.cfg", "preseed/*"}}
+	if errs := h.Prepare(nil); len(errs) > 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+
+	h = HTTPConfig{HTTPTemplates: []string{"[.cfg"}}
+	if errs := h.Prepare(nil); len(errs) == 0 {
+		t.Fatal("should have error")
+	}
+}
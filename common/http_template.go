@@ -0,0 +1,82 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// HTTPTemplateData is the data available to files rendered by the HTTP
+// server, e.g. `{{ .HTTPPort }}`.
+type HTTPTemplateData struct {
+	HTTPPort   uint
+	HTTPScheme string
+	HTTPToken  string
+}
+
+// templateHandler renders the files matching one of the patterns with the
+// template engine before serving them. Everything else is passed on to
+// Handler.
+type templateHandler struct {
+	Dir      string
+	Patterns []string
+	Ctx      *interpolate.Context
+	Handler  http.Handler
+}
+
+func (h *templateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !h.matches(name) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	filename := filepath.Join(h.Dir, filepath.FromSlash(name))
+	info, err := os.Stat(filename)
+	if err != nil || info.IsDir() {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	rendered, err := interpolate.Render(string(contents), h.Ctx)
+	if err != nil {
+		log.Printf("[ERR] Error rendering HTTP template %s: %s", name, err)
+		http.Error(w, fmt.Sprintf("Error rendering %s", name), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Serving rendered HTTP template: %s", name)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), bytes.NewReader([]byte(rendered)))
+}
+
+// matches checks the path, relative to Dir, against the patterns. A
+// pattern without a directory matches files of that name anywhere.
+func (h *templateHandler) matches(name string) bool {
+	for _, pattern := range h.Patterns {
+		pattern = filepath.ToSlash(pattern)
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+
+	return false
+}
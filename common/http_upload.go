@@ -0,0 +1,80 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// HTTPUploadPath is the URL path under which the HTTP server accepts
+// uploads from the guest.
+const HTTPUploadPath = "/upload/"
+
+// uploadHandler saves the body of POST and PUT requests under HTTPUploadPath
+// to Dir, keeping the rest of the path as the file name. Uploads must carry
+// Token. Every other request is passed on to Handler.
+type uploadHandler struct {
+	Dir     string
+	Token   string
+	Handler http.Handler
+}
+
+func (h *uploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, HTTPUploadPath) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method != "POST" && r.Method != "PUT" {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !validHTTPToken(r, h.Token) {
+		log.Printf("[WARN] Rejected HTTP upload without a valid token: %s", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Cleaning the rooted path makes sure that the upload can't escape Dir
+	name := strings.TrimPrefix(
+		path.Clean("/"+strings.TrimPrefix(r.URL.Path, HTTPUploadPath)), "/")
+	if name == "" {
+		http.Error(w, "Missing file name", http.StatusBadRequest)
+		return
+	}
+
+	filename := filepath.Join(h.Dir, filepath.FromSlash(name))
+	if err := h.save(filename, r.Body); err != nil {
+		log.Printf("[ERR] Error saving HTTP upload %s: %s", name, err)
+		http.Error(w, fmt.Sprintf("Error saving %s", name), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Saved HTTP upload: %s", filename)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *uploadHandler) save(filename string, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
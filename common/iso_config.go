@@ -2,11 +2,13 @@ package common
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
-	"net/http"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -16,13 +18,17 @@ import (
 
 // ISOConfig contains configuration for downloading ISO images.
 type ISOConfig struct {
-	ISOChecksum     string   `mapstructure:"iso_checksum"`
-	ISOChecksumURL  string   `mapstructure:"iso_checksum_url"`
-	ISOChecksumType string   `mapstructure:"iso_checksum_type"`
-	ISOUrls         []string `mapstructure:"iso_urls"`
-	TargetPath      string   `mapstructure:"iso_target_path"`
-	TargetExtension string   `mapstructure:"iso_target_extension"`
-	RawSingleISOUrl string   `mapstructure:"iso_url"`
+	ISOChecksum             string   `mapstructure:"iso_checksum"`
+	ISOChecksumURL          string   `mapstructure:"iso_checksum_url"`
+	ISOChecksumType         string   `mapstructure:"iso_checksum_type"`
+	ISOChecksumSignatureURL string   `mapstructure:"iso_checksum_signature_url"`
+	ISOChecksumKeyring      string   `mapstructure:"iso_checksum_keyring"`
+	ISOUrls                 []string `mapstructure:"iso_urls"`
+	ISODownloadProxy        string   `mapstructure:"iso_download_proxy"`
+	ISODownloadSegments     int      `mapstructure:"iso_download_segments"`
+	TargetPath              string   `mapstructure:"iso_target_path"`
+	TargetExtension         string   `mapstructure:"iso_target_extension"`
+	RawSingleISOUrl         string   `mapstructure:"iso_url"`
 }
 
 func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs []error) {
@@ -38,6 +44,38 @@ func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs [
 		c.ISOUrls = []string{c.RawSingleISOUrl}
 	}
 
+	if c.ISODownloadProxy != "" {
+		if _, err := url.Parse(c.ISODownloadProxy); err != nil {
+			errs = append(
+				errs, fmt.Errorf("Failed to parse iso_download_proxy: %s", err))
+			return
+		}
+	}
+
+	if c.ISODownloadSegments == 0 {
+		c.ISODownloadSegments = 4
+	} else if c.ISODownloadSegments < 0 {
+		errs = append(
+			errs, errors.New("iso_download_segments must be positive."))
+	}
+
+	if c.ISOChecksumKeyring != "" {
+		if c.ISOChecksumURL == "" || c.ISOChecksum != "" {
+			errs = append(
+				errs, errors.New("iso_checksum_keyring can only be used with iso_checksum_url."))
+			return
+		}
+		if _, err := os.Stat(c.ISOChecksumKeyring); err != nil {
+			errs = append(
+				errs, fmt.Errorf("Bad iso_checksum_keyring: %s", err))
+			return
+		}
+	} else if c.ISOChecksumSignatureURL != "" {
+		errs = append(
+			errs, errors.New("iso_checksum_signature_url requires an iso_checksum_keyring."))
+		return
+	}
+
 	if c.ISOChecksumType == "" {
 		errs = append(
 			errs, errors.New("The iso_checksum_type must be specified."))
@@ -57,51 +95,25 @@ func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs [
 
 				// If iso_checksum has no value use iso_checksum_url instead.
 				if c.ISOChecksum == "" {
-					u, err := url.Parse(c.ISOChecksumURL)
+					data, err := c.readURL(c.ISOChecksumURL)
 					if err != nil {
-						errs = append(errs,
-							fmt.Errorf("Error parsing checksum: %s", err))
+						errs = append(errs, err)
 						return warnings, errs
 					}
-					switch u.Scheme {
-					case "http", "https":
-						res, err := http.Get(c.ISOChecksumURL)
-						c.ISOChecksum = ""
-						if err != nil {
-							errs = append(errs,
-								fmt.Errorf("Error getting checksum from url: %s", c.ISOChecksumURL))
-							return warnings, errs
+					if data != nil {
+						if c.ISOChecksumKeyring != "" {
+							data, err = c.verifyChecksumFile(data)
+							if err != nil {
+								errs = append(errs, err)
+								return warnings, errs
+							}
 						}
-						defer res.Body.Close()
-						err = c.parseCheckSumFile(bufio.NewReader(res.Body))
-						if err != nil {
-							errs = append(errs, err)
-							return warnings, errs
-						}
-					case "file":
-						path := u.Path
 
-						if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
-							path = strings.TrimLeft(path, "/")
-						}
-
-						file, err := os.Open(path)
-						if err != nil {
-							errs = append(errs, err)
-							return warnings, errs
-						}
-						err = c.parseCheckSumFile(bufio.NewReader(file))
+						err = c.parseCheckSumFile(bufio.NewReader(bytes.NewReader(data)))
 						if err != nil {
 							errs = append(errs, err)
 							return warnings, errs
 						}
-
-					case "":
-						break
-					default:
-						errs = append(errs,
-							fmt.Errorf("Error parsing checksum url: %s, scheme not supported: %s", c.ISOChecksumURL, u.Scheme))
-						return warnings, errs
 					}
 				}
 			}
@@ -135,6 +147,102 @@ func (c *ISOConfig) Prepare(ctx *interpolate.Context) (warnings []string, errs [
 	return warnings, errs
 }
 
+// readURL reads a checksum or signature file from an http, https or file
+// URL. It returns nil for URLs without a scheme.
+func (c *ISOConfig) readURL(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing checksum: %s", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		client, err := HTTPClient(c.ISODownloadProxy)
+		if err != nil {
+			return nil, err
+		}
+		res, err := client.Get(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting checksum from url: %s", rawURL)
+		}
+		defer res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return nil, fmt.Errorf("Error getting checksum from url: %s: %s", rawURL, res.Status)
+		}
+		return ioutil.ReadAll(res.Body)
+	case "file":
+		path := u.Path
+
+		if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			path = strings.TrimLeft(path, "/")
+		}
+
+		return ioutil.ReadFile(path)
+	case "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Error parsing checksum url: %s, scheme not supported: %s", rawURL, u.Scheme)
+	}
+}
+
+// verifyChecksumFile verifies the OpenPGP signature of the checksum file
+// with gpgv and the keyring, and returns the signed content. The signature
+// is either detached, at iso_checksum_signature_url, or inline in a clear
+// signed checksum file.
+func (c *ISOConfig) verifyChecksumFile(data []byte) ([]byte, error) {
+	gpgv, err := exec.LookPath("gpgv")
+	if err != nil {
+		return nil, fmt.Errorf("Verifying the checksum file requires gpgv to be installed: %s", err)
+	}
+
+	// gpgv looks for keyrings without a path in the GnuPG home directory.
+	keyring, err := filepath.Abs(c.ISOChecksumKeyring)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	dataPath := filepath.Join(dir, "checksums")
+	if err := ioutil.WriteFile(dataPath, data, 0644); err != nil {
+		return nil, err
+	}
+
+	args := []string{"--keyring", keyring}
+	var outputPath string
+	if c.ISOChecksumSignatureURL != "" {
+		sig, err := c.readURL(c.ISOChecksumSignatureURL)
+		if err != nil {
+			return nil, err
+		}
+		sigPath := filepath.Join(dir, "checksums.sig")
+		if err := ioutil.WriteFile(sigPath, sig, 0644); err != nil {
+			return nil, err
+		}
+		args = append(args, sigPath, dataPath)
+	} else {
+		// Only the signed part of a clear signed file is used.
+		outputPath = filepath.Join(dir, "checksums.out")
+		args = append(args, "--output", outputPath, dataPath)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(gpgv, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Bad signature of checksum file %s: %s\n%s", c.ISOChecksumURL, err, stderr.String())
+	}
+
+	if outputPath == "" {
+		return data, nil
+	}
+	return ioutil.ReadFile(outputPath)
+}
+
 func (c *ISOConfig) parseCheckSumFile(rd *bufio.Reader) error {
 	u, err := url.Parse(c.ISOUrls[0])
 	if err != nil {
@@ -5,6 +5,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"testing"
@@ -335,3 +338,107 @@ func TestISOConfigPrepare_TargetExtension(t *testing.T) {
 		t.Fatalf("should've lowercased: %s", i.TargetExtension)
 	}
 }
+
+func TestISOConfigPrepare_ISOChecksumKeyring(t *testing.T) {
+	keyring, _ := ioutil.TempFile("", "packer-test-")
+	keyring.Close()
+	defer os.Remove(keyring.Name())
+
+	// Test bad - keyring with a checksum
+	i := testISOConfig()
+	i.ISOChecksumKeyring = keyring.Name()
+	_, err := i.Prepare(nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test bad - missing keyring
+	i = testISOConfig()
+	i.ISOChecksum = ""
+	i.ISOChecksumURL = "file:///not_read"
+	i.ISOChecksumKeyring = "/i/dont/exist"
+	_, err = i.Prepare(nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test bad - signature without keyring
+	i = testISOConfig()
+	i.ISOChecksum = ""
+	i.ISOChecksumURL = "file:///not_read"
+	i.ISOChecksumSignatureURL = "file:///not_read.sig"
+	_, err = i.Prepare(nil)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestISOConfigPrepare_ISOChecksumSignature(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not installed")
+	}
+	if _, err := exec.LookPath("gpgv"); err != nil {
+		t.Skip("gpgv not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "packer-test-")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	defer exec.Command("gpgconf", "--homedir", dir, "--kill", "all").Run()
+
+	gpg := func(args ...string) {
+		args = append([]string{"--homedir", dir, "--batch", "--passphrase", "", "--pinentry-mode", "loopback"}, args...)
+		if out, err := exec.Command("gpg", args...).CombinedOutput(); err != nil {
+			t.Fatalf("gpg %v: %s\n%s", args, err, out)
+		}
+	}
+
+	checksums := filepath.Join(dir, "SHA256SUMS")
+	ioutil.WriteFile(checksums, []byte(cs_gnu_style), 0644)
+	keyring := filepath.Join(dir, "keyring.gpg")
+	gpg("--quick-generate-key", "packer@example.com", "ed25519", "sign", "never")
+	gpg("--output", keyring, "--export", "packer@example.com")
+	gpg("--output", checksums+".sig", "--detach-sign", checksums)
+	gpg("--output", checksums+".asc", "--clearsign", checksums)
+
+	// Test good - detached signature
+	i := testISOConfig()
+	i.ISOChecksum = ""
+	i.ISOChecksumURL = "file://" + filepath.ToSlash(checksums)
+	i.ISOChecksumSignatureURL = "file://" + filepath.ToSlash(checksums+".sig")
+	i.ISOChecksumKeyring = keyring
+	_, errs := i.Prepare(nil)
+	if errs != nil {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if i.ISOChecksum != "bar0" {
+		t.Fatalf("should've found \"bar0\" got: %s", i.ISOChecksum)
+	}
+
+	// Test good - clear signed checksum file
+	i = testISOConfig()
+	i.ISOChecksum = ""
+	i.ISOChecksumURL = "file://" + filepath.ToSlash(checksums+".asc")
+	i.ISOChecksumKeyring = keyring
+	_, errs = i.Prepare(nil)
+	if errs != nil {
+		t.Fatalf("should not have error: %s", errs)
+	}
+	if i.ISOChecksum != "bar0" {
+		t.Fatalf("should've found \"bar0\" got: %s", i.ISOChecksum)
+	}
+
+	// Test bad - the checksum file was changed
+	ioutil.WriteFile(checksums, []byte(cs_bsd_style), 0644)
+	i = testISOConfig()
+	i.ISOChecksum = ""
+	i.ISOChecksumURL = "file://" + filepath.ToSlash(checksums)
+	i.ISOChecksumSignatureURL = "file://" + filepath.ToSlash(checksums+".sig")
+	i.ISOChecksumKeyring = keyring
+	_, errs = i.Prepare(nil)
+	if errs == nil {
+		t.Fatal("should have error")
+	}
+}
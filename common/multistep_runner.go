@@ -13,6 +13,10 @@ import (
 )
 
 func newRunner(steps []multistep.Step, config PackerConfig, ui packer.Ui) (multistep.Runner, multistep.DebugPauseFn) {
+	for i, step := range steps {
+		steps[i] = timedStep{step, ui}
+	}
+
 	switch config.PackerOnError {
 	case "", "cleanup":
 	case "abort":
@@ -53,9 +57,45 @@ func NewRunnerWithPauseFn(steps []multistep.Step, config PackerConfig, ui packer
 }
 
 func typeName(i interface{}) string {
+	if wrapped, ok := i.(multistep.StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
 	return reflect.Indirect(reflect.ValueOf(i)).Type().Name()
 }
 
+// timedStep reports how long the step runs to the timing report of the
+// build.
+type timedStep struct {
+	step multistep.Step
+	ui   packer.Ui
+}
+
+func (s timedStep) InnerStepName() string {
+	return typeName(s.step)
+}
+
+func (s timedStep) Run(state multistep.StateBag) multistep.StepAction {
+	end := packer.StartTiming(s.ui, "step", typeName(s.step))
+	action := s.step.Run(state)
+
+	var err error
+	if action == multistep.ActionHalt {
+		if rawErr, ok := state.GetOk("error"); ok {
+			err, _ = rawErr.(error)
+		}
+		if err == nil {
+			err = fmt.Errorf("step %s halted", typeName(s.step))
+		}
+	}
+	end(err)
+
+	return action
+}
+
+func (s timedStep) Cleanup(state multistep.StateBag) {
+	s.step.Cleanup(state)
+}
+
 type abortStep struct {
 	step multistep.Step
 	ui   packer.Ui
@@ -10,4 +10,5 @@ type PackerConfig struct {
 	PackerForce       bool              `mapstructure:"packer_force"`
 	PackerOnError     string            `mapstructure:"packer_on_error"`
 	PackerUserVars    map[string]string `mapstructure:"packer_user_variables"`
+	PackerTempDir     string            `mapstructure:"packer_temp_dir"`
 }
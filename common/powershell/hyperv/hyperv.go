@@ -217,6 +217,57 @@ New-VM -Name $vmName -Path $path -MemoryStartupBytes $memoryStartupBytes -NewVHD
 	}
 }
 
+// CreateDifferencingVirtualMachine creates a VM whose disk is a new
+// differencing disk of the parent disk, which isn't modified.
+func CreateDifferencingVirtualMachine(vmName string, path string, parentPath string, ram int64, switchName string, generation uint) error {
+
+	var script = `
+param([string]$vmName, [string]$path, [string]$parentPath, [long]$memoryStartupBytes, [string]$switchName, [int]$generation)
+$vhdx = $vmName + [IO.Path]::GetExtension($parentPath)
+$vhdPath = Join-Path -Path $path -ChildPath $vhdx
+New-VHD -Path $vhdPath -ParentPath $parentPath -Differencing | Out-Null
+New-VM -Name $vmName -Path $path -MemoryStartupBytes $memoryStartupBytes -VHDPath $vhdPath -SwitchName $switchName -Generation $generation
+if ($generation -eq 2) {
+  Set-VMFirmware -VMName $vmName -FirstBootDevice (Get-VMHardDiskDrive -VMName $vmName | Select -First 1)
+}
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, path, parentPath, strconv.FormatInt(ram, 10), switchName, strconv.FormatInt(int64(generation), 10))
+	if err != nil {
+		return err
+	}
+
+	if generation == 2 {
+		return nil
+	}
+	return DeleteAllDvdDrives(vmName)
+}
+
+// MergeDifferencingDisk replaces the differencing disks of a VM with
+// standalone disks holding the data of their parents too, so that the VM
+// doesn't need the parents anymore.
+func MergeDifferencingDisk(vmName string) error {
+
+	var script = `
+param([string]$vmName)
+Get-VMHardDiskDrive -VMName $vmName | ForEach {
+  $vhd = Get-VHD -Path $_.Path
+  if ($vhd.VhdType -eq 'Differencing') {
+    $dir = Split-Path -Parent $vhd.Path
+    $name = [IO.Path]::GetFileNameWithoutExtension($vhd.Path) + '-merged' + [IO.Path]::GetExtension($vhd.Path)
+    $merged = Join-Path -Path $dir -ChildPath $name
+    Convert-VHD -Path $vhd.Path -DestinationPath $merged -VHDType Dynamic
+    Set-VMHardDiskDrive -VMHardDiskDrive $_ -Path $merged
+    Remove-Item -Path $vhd.Path -Force
+  }
+}
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName)
+	return err
+}
+
 func SetVirtualMachineCpuCount(vmName string, cpu uint) error {
 
 	var script = `
@@ -260,6 +311,17 @@ Set-VMMemory -VMName $vmName -DynamicMemoryEnabled $enableDynamicMemory
 	return err
 }
 
+func SetVirtualMachineMemory(vmName string, ram int64) error {
+
+	var script = `
+param([string]$vmName, [long]$memoryStartupBytes)
+Set-VMMemory -VMName $vmName -StartupBytes $memoryStartupBytes
+`
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, strconv.FormatInt(ram, 10))
+	return err
+}
+
 func SetVirtualMachineMacSpoofing(vmName string, enableMacSpoofing bool) error {
 	var script = `
 param([string]$vmName, $enableMacSpoofing)
@@ -981,3 +1043,36 @@ param([string]$vmName, [string]$scanCodes)
 	err := ps.Run(script, vmName, scanCodes)
 	return err
 }
+
+func Screenshot(vmName string, path string) error {
+
+	var script = `
+param([string]$vmName, [string]$path)
+$vm = Get-CimInstance -Namespace "root\virtualization\v2" -ClassName Msvm_ComputerSystem | where ElementName -eq $vmName | select -first 1
+if ($vm -eq $null) {
+  throw "VirtualMachine($vmName) is not found!"
+}
+$video = $vm | Get-CimAssociatedInstance -ResultClassName "Msvm_VideoHead" | select -first 1
+$width = $video.CurrentHorizontalResolution
+$height = $video.CurrentVerticalResolution
+$service = Get-CimInstance -Namespace "root\virtualization\v2" -ClassName Msvm_VirtualSystemManagementService
+$settings = $vm | Get-CimAssociatedInstance -ResultClassName "Msvm_VirtualSystemSettingData" | where VirtualSystemType -eq "Microsoft:Hyper-V:System:Realized" | select -first 1
+$result = $service | Invoke-CimMethod -MethodName "GetVirtualSystemThumbnailImage" -Arguments @{ TargetSystem = $settings; WidthPixels = [uint16]$width; HeightPixels = [uint16]$height }
+if ($result.ReturnValue -ne 0) {
+  throw "GetVirtualSystemThumbnailImage failed with $($result.ReturnValue)"
+}
+
+Add-Type -AssemblyName System.Drawing
+$bitmap = New-Object System.Drawing.Bitmap -ArgumentList $width, $height, ([System.Drawing.Imaging.PixelFormat]::Format16bppRgb565)
+$rect = New-Object System.Drawing.Rectangle -ArgumentList 0, 0, $width, $height
+$data = $bitmap.LockBits($rect, [System.Drawing.Imaging.ImageLockMode]::WriteOnly, $bitmap.PixelFormat)
+[System.Runtime.InteropServices.Marshal]::Copy([byte[]]$result.ImageData, 0, $data.Scan0, $width * $height * 2)
+$bitmap.UnlockBits($data)
+$bitmap.Save($path, [System.Drawing.Imaging.ImageFormat]::Png)
+$bitmap.Dispose()
+`
+
+	var ps powershell.PowerShellCmd
+	err := ps.Run(script, vmName, path)
+	return err
+}
@@ -0,0 +1,18 @@
+// +build !windows
+
+package resources
+
+import (
+	"os"
+	"syscall"
+)
+
+// ProcessAlive returns true if the process with the given pid is running.
+func ProcessAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
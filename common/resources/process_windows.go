@@ -0,0 +1,26 @@
+// +build windows
+
+package resources
+
+import (
+	"syscall"
+)
+
+const processQueryLimitedInformation = 0x1000
+
+const stillActive = 259
+
+// ProcessAlive returns true if the process with the given pid is running.
+func ProcessAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
@@ -0,0 +1,281 @@
+// Package resources keeps track of the temporary resources builders create
+// during a run, such as instances, key pairs and virtual machines. Builders
+// record a resource when they create it and release it once it is deleted,
+// so the resources left behind by a crashed build can be found and deleted
+// later by `packer cleanup`.
+package resources
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// EnvResourcesDir is the environment variable that overrides the
+// directory the resources are recorded in.
+const EnvResourcesDir = "PACKER_RESOURCES_DIR"
+
+const runFile = "run.json"
+
+// Resource is a temporary resource created by a builder.
+type Resource struct {
+	// Builder is the builder family that knows how to delete the resource,
+	// e.g. amazon or virtualbox.
+	Builder string
+
+	// Kind is the type of the resource, e.g. instance or keypair.
+	Kind string
+
+	// ID identifies the resource for its builder, e.g. the instance ID.
+	ID string
+
+	// Region is where the resource lives, if the builder has regions.
+	Region string `json:",omitempty"`
+
+	Created time.Time
+}
+
+func (r Resource) String() string {
+	s := fmt.Sprintf("%s %s %s", r.Builder, r.Kind, r.ID)
+	if r.Region != "" {
+		s += fmt.Sprintf(" (%s)", r.Region)
+	}
+	return s
+}
+
+func (r Resource) fileName() string {
+	h := sha1.Sum([]byte(r.Builder + "/" + r.Kind + "/" + r.Region + "/" + r.ID))
+	return fmt.Sprintf("%s-%s-%s.json", r.Builder, r.Kind, hex.EncodeToString(h[:8]))
+}
+
+// Dir returns the directory the resources of all runs are recorded in.
+func Dir() (string, error) {
+	if dir := os.Getenv(EnvResourcesDir); dir != "" {
+		return filepath.Abs(dir)
+	}
+	configDir, err := packer.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "resources"), nil
+}
+
+// runDir returns the directory of the current run, or an empty string if
+// there is no current run.
+func runDir() (string, error) {
+	id := os.Getenv("PACKER_RUN_UUID")
+	if id == "" {
+		return "", nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id), nil
+}
+
+// Track records a resource of the current run. Failing to record it
+// doesn't fail the build, so errors are only logged.
+func Track(r Resource) {
+	dir, err := runDir()
+	if err != nil || dir == "" {
+		if err != nil {
+			log.Printf("[WARN] Not tracking %s: %s", r, err)
+		}
+		return
+	}
+
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+	if err := writeJSON(filepath.Join(dir, r.fileName()), r); err != nil {
+		log.Printf("[WARN] Not tracking %s: %s", r, err)
+		return
+	}
+	log.Printf("[DEBUG] Tracking %s", r)
+}
+
+// Release forgets a resource of the current run after it was deleted.
+func Release(r Resource) {
+	dir, err := runDir()
+	if err != nil || dir == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(dir, r.fileName())); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Error releasing %s: %s", r, err)
+	}
+}
+
+// StartRun records the current process as the one running the builds of
+// the current run. As long as it is alive the run is active and its
+// resources are left alone.
+func StartRun() error {
+	dir, err := runDir()
+	if err != nil || dir == "" {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, runFile), &Run{
+		Pid:     os.Getpid(),
+		Started: time.Now(),
+	})
+}
+
+// EndRun removes the record of the current run, unless some of its
+// resources couldn't be deleted.
+func EndRun() error {
+	dir, err := runDir()
+	if err != nil || dir == "" {
+		return err
+	}
+	run, err := readRun(dir)
+	if err != nil {
+		return err
+	}
+	if len(run.Resources) > 0 {
+		return nil
+	}
+	return os.RemoveAll(dir)
+}
+
+// Run is a run of packer that recorded resources.
+type Run struct {
+	ID      string `json:"-"`
+	Pid     int
+	Started time.Time
+
+	// Resources are the resources that weren't released, in the order
+	// they were created.
+	Resources []Resource `json:"-"`
+
+	dir string
+}
+
+// Active returns true if the process of the run is still alive.
+func (r *Run) Active() bool {
+	return r.Pid > 0 && ProcessAlive(r.Pid)
+}
+
+// Release forgets a resource of the run after it was deleted.
+func (r *Run) Release(res Resource) error {
+	if err := os.Remove(filepath.Join(r.dir, res.fileName())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i, other := range r.Resources {
+		if other.fileName() == res.fileName() {
+			r.Resources = append(r.Resources[:i], r.Resources[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Remove removes the record of the run.
+func (r *Run) Remove() error {
+	return os.RemoveAll(r.dir)
+}
+
+// Runs returns the recorded runs, oldest first.
+func Runs() ([]*Run, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*Run
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		run, err := readRun(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Started.Before(runs[j].Started)
+	})
+	return runs, nil
+}
+
+func readRun(dir string) (*Run, error) {
+	run := &Run{ID: filepath.Base(dir), dir: dir}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		switch {
+		case info.Name() == runFile:
+			if err := readJSON(path, run); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(info.Name(), ".json"):
+			var r Resource
+			if err := readJSON(path, &r); err != nil {
+				return nil, err
+			}
+			run.Resources = append(run.Resources, r)
+		}
+	}
+
+	// A run whose core never recorded itself started with its oldest
+	// resource. Its process is unknown, so it is never active.
+	sort.SliceStable(run.Resources, func(i, j int) bool {
+		return run.Resources[i].Created.Before(run.Resources[j].Created)
+	})
+	if run.Started.IsZero() && len(run.Resources) > 0 {
+		run.Started = run.Resources[0].Created
+	}
+	return run, nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a crash never leaves a partial
+	// record behind.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package resources
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func testDir(t *testing.T, runID string) func() {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	os.Setenv(EnvResourcesDir, td)
+	os.Setenv("PACKER_RUN_UUID", runID)
+	return func() {
+		os.Unsetenv(EnvResourcesDir)
+		os.Unsetenv("PACKER_RUN_UUID")
+		os.RemoveAll(td)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	defer testDir(t, "run")()
+
+	if err := StartRun(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	now := time.Now()
+	instance := Resource{Builder: "amazon", Kind: "instance", ID: "i-1", Region: "us-east-1", Created: now.Add(time.Second)}
+	keyPair := Resource{Builder: "amazon", Kind: "keypair", ID: "packer 1", Region: "us-east-1", Created: now}
+	Track(instance)
+	Track(keyPair)
+	Track(Resource{Builder: "virtualbox", Kind: "vm", ID: "packer-1"})
+	Release(Resource{Builder: "virtualbox", Kind: "vm", ID: "packer-1"})
+
+	runs, err := Runs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("bad: %#v", runs)
+	}
+	run := runs[0]
+	if run.ID != "run" || run.Pid != os.Getpid() || !run.Active() {
+		t.Fatalf("bad run: %#v", run)
+	}
+	if len(run.Resources) != 2 || run.Resources[0].ID != "packer 1" || run.Resources[1].ID != "i-1" {
+		t.Fatalf("resources should be in the order of creation: %#v", run.Resources)
+	}
+
+	// The run is kept as long as it has resources
+	if err := EndRun(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if runs, _ := Runs(); len(runs) != 1 {
+		t.Fatalf("bad: %#v", runs)
+	}
+
+	if err := run.Release(keyPair); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	Release(instance)
+	if err := EndRun(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if runs, _ := Runs(); len(runs) != 0 {
+		t.Fatalf("bad: %#v", runs)
+	}
+}
+
+func TestTrack_NoRun(t *testing.T) {
+	defer testDir(t, "")()
+
+	Track(Resource{Builder: "virtualbox", Kind: "vm", ID: "packer-1"})
+	if err := StartRun(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	runs, err := Runs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(runs) != 0 {
+		t.Fatalf("nothing should be recorded without a run: %#v", runs)
+	}
+}
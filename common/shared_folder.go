@@ -0,0 +1,28 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// SetSharedFolderPath saves the path a builder mounted a folder of the host
+// on in the machine, so that provisioners can use the files of the folder
+// in place instead of uploading them.
+func SetSharedFolderPath(path string) error {
+	return ioutil.WriteFile(httpAddrFilename("shared_folder"), []byte(path), 0644)
+}
+
+// GetSharedFolderPath returns the path of the folder shared by the builder
+// in the machine, or an empty string if no folder is shared.
+func GetSharedFolderPath() string {
+	path, err := ioutil.ReadFile(httpAddrFilename("shared_folder"))
+	if err != nil {
+		return ""
+	}
+	return string(path)
+}
+
+// RemoveSharedFolderPath forgets the path saved by SetSharedFolderPath.
+func RemoveSharedFolderPath() {
+	os.Remove(httpAddrFilename("shared_folder"))
+}
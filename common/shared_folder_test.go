@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestSharedFolderPath(t *testing.T) {
+	defer RemoveSharedFolderPath()
+
+	if path := GetSharedFolderPath(); path != "" {
+		t.Fatalf("bad: %q", path)
+	}
+
+	if err := SetSharedFolderPath("/mnt/packer"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if path := GetSharedFolderPath(); path != "/mnt/packer" {
+		t.Fatalf("bad: %q", path)
+	}
+
+	RemoveSharedFolderPath()
+	if path := GetSharedFolderPath(); path != "" {
+		t.Fatalf("bad: %q", path)
+	}
+}
@@ -0,0 +1,223 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/multistep"
+)
+
+// isoTool is a tool an ISO can be created with. Args returns its arguments
+// creating the ISO at out from the files in dir.
+type isoTool struct {
+	Name string
+	Args func(label, out, dir string) []string
+}
+
+// isoTools are the tools an ISO can be created with, in the order they are
+// looked for.
+var isoTools = []isoTool{
+	{"xorriso", func(label, out, dir string) []string {
+		return []string{"-as", "genisoimage", "-rock", "-joliet", "-volid", label, "-output", out, dir}
+	}},
+	{"mkisofs", func(label, out, dir string) []string {
+		return []string{"-rock", "-joliet", "-volid", label, "-o", out, dir}
+	}},
+	{"genisoimage", func(label, out, dir string) []string {
+		return []string{"-rock", "-joliet", "-volid", label, "-o", out, dir}
+	}},
+	{"hdiutil", func(label, out, dir string) []string {
+		return []string{"makehybrid", "-iso", "-joliet", "-default-volume-name", label, "-o", out, dir}
+	}},
+	{"oscdimg", func(label, out, dir string) []string {
+		return []string{"-m", "-o", "-u2", "-udfver102", "-l" + label, dir, out}
+	}},
+}
+
+// lookPath is exec.LookPath, replaced in tests.
+var lookPath = exec.LookPath
+
+// StepCreateCD will create an ISO image with the given files and content,
+// using the first of xorriso, mkisofs, genisoimage, hdiutil or oscdimg that
+// is installed.
+//
+// Produces:
+//   cd_path string - The path to the ISO.
+type StepCreateCD struct {
+	Files   []string
+	Content map[string]string
+	Label   string
+
+	// TempDir is the directory the CD is created in, the default
+	// directory for temporary files if empty.
+	TempDir string
+
+	cdDir string
+
+	FilesAdded map[string]bool
+}
+
+func (s *StepCreateCD) Run(state multistep.StateBag) multistep.StepAction {
+	if len(s.Files) == 0 && len(s.Content) == 0 {
+		log.Println("No CD files specified. CD disk will not be made.")
+		return multistep.ActionContinue
+	}
+
+	s.FilesAdded = make(map[string]bool)
+
+	ui := state.Get("ui").(packer.Ui)
+	ui.Say("Creating CD disk...")
+
+	tool, args, err := s.isoCommand()
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// The ISO and the files it is made from are in a temporary directory
+	// that is removed on cleanup. The ISO is named after the directory so
+	// that its name is unique, e.g. on the datastore of a remote ESXi.
+	s.cdDir, err = ioutil.TempDir(s.TempDir, "packer")
+	if err != nil {
+		state.Put("error", fmt.Errorf("Error creating temporary directory for CD: %s", err))
+		return multistep.ActionHalt
+	}
+	rootDir := filepath.Join(s.cdDir, "root")
+	cdPath := filepath.Join(s.cdDir, filepath.Base(s.cdDir)+".iso")
+
+	ui.Message("Copying files from cd_files")
+	if err := s.addFiles(ui, rootDir); err != nil {
+		state.Put("error", fmt.Errorf("Error adding files to CD: %s", err))
+		return multistep.ActionHalt
+	}
+	if err := s.addContent(rootDir); err != nil {
+		state.Put("error", fmt.Errorf("Error adding content to CD: %s", err))
+		return multistep.ActionHalt
+	}
+
+	args = append(args, tool.Args(s.Label, cdPath, rootDir)...)
+	log.Printf("Creating CD with: %s", strings.Join(args, " "))
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		state.Put("error", fmt.Errorf("Error creating CD with %s: %s\n%s", tool.Name, err, stderr.String()))
+		return multistep.ActionHalt
+	}
+
+	log.Printf("CD path: %s", cdPath)
+	state.Put("cd_path", cdPath)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateCD) Cleanup(multistep.StateBag) {
+	if s.cdDir != "" {
+		log.Printf("Deleting CD disk: %s", s.cdDir)
+		os.RemoveAll(s.cdDir)
+	}
+}
+
+// isoCommand returns the first installed ISO tool, and the path to it as
+// the first argument of its command.
+func (s *StepCreateCD) isoCommand() (*isoTool, []string, error) {
+	names := make([]string, 0, len(isoTools))
+	for i, tool := range isoTools {
+		names = append(names, tool.Name)
+		if path, err := lookPath(tool.Name); err == nil {
+			return &isoTools[i], []string{path}, nil
+		}
+	}
+	return nil, nil, fmt.Errorf(
+		"Creating a CD requires one of %s to be installed", strings.Join(names, ", "))
+}
+
+// addFiles copies the files to the root of the CD. Directories are copied
+// with their content, keeping their name.
+func (s *StepCreateCD) addFiles(ui packer.Ui, rootDir string) error {
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+
+	for _, pattern := range s.Files {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, src := range matches {
+			ui.Message(fmt.Sprintf("Copying: %s", src))
+			base := filepath.Dir(filepath.Clean(src))
+			err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(base, path)
+				if err != nil {
+					return err
+				}
+				dst := filepath.Join(rootDir, rel)
+				if info.IsDir() {
+					return os.MkdirAll(dst, 0755)
+				}
+				if err := copyFile(path, dst); err != nil {
+					return err
+				}
+				s.FilesAdded[path] = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addContent writes the content to its path below the root of the CD.
+func (s *StepCreateCD) addContent(rootDir string) error {
+	for name, content := range s.Content {
+		dst := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(dst, []byte(content), 0644); err != nil {
+			return err
+		}
+		s.FilesAdded[name] = true
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
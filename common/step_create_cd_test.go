@@ -0,0 +1,131 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepCreateCD_Impl(t *testing.T) {
+	var raw interface{}
+	raw = new(StepCreateCD)
+	if _, ok := raw.(multistep.Step); !ok {
+		t.Fatalf("StepCreateCD should be a step")
+	}
+}
+
+func TestStepCreateCD_empty(t *testing.T) {
+	state := testStepCreateFloppyState(t)
+	step := new(StepCreateCD)
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("cd_path"); ok {
+		t.Fatal("no CD should be made")
+	}
+}
+
+func TestStepCreateCD_noTool(t *testing.T) {
+	defer func() { lookPath = exec.LookPath }()
+	lookPath = func(string) (string, error) { return "", os.ErrNotExist }
+
+	state := testStepCreateFloppyState(t)
+	step := &StepCreateCD{Content: map[string]string{"file": "content"}}
+	defer step.Cleanup(state)
+
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepCreateCD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ISO tool is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A fake xorriso that creates an empty ISO and keeps the files it was
+	// given, so they can be checked.
+	tool := filepath.Join(dir, "xorriso")
+	script := `#!/bin/sh
+while [ $# -gt 1 ]; do
+  if [ "$1" = "-output" ]; then out="$2"; fi
+  shift
+done
+cp -R "$1" "$out.root" && touch "$out"
+`
+	if err := ioutil.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { lookPath = exec.LookPath }()
+	lookPath = func(name string) (string, error) {
+		if name == "xorriso" {
+			return tool, nil
+		}
+		return "", os.ErrNotExist
+	}
+
+	state := testStepCreateFloppyState(t)
+	step := &StepCreateCD{
+		Files: []string{
+			filepath.Join(TestFixtures, "floppies", "bar.bat"),
+			filepath.Join(TestFixtures, "floppy-hier", "test-0"),
+		},
+		Content: map[string]string{
+			"autounattend.xml":  "<unattend/>",
+			"scripts/setup.ps1": "Write-Host setup",
+		},
+		Label: "packer",
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+
+	cdPath, ok := state.GetOk("cd_path")
+	if !ok {
+		t.Fatal("should have cd_path")
+	}
+	if _, err := os.Stat(cdPath.(string)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	root := cdPath.(string) + ".root"
+	expected := map[string]string{
+		"bar.bat":           "",
+		"autounattend.xml":  "<unattend/>",
+		"scripts/setup.ps1": "Write-Host setup",
+	}
+	for name, content := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if content != "" && string(data) != content {
+			t.Fatalf("%s: bad content: %s", name, data)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(root, "test-0")); err != nil || !info.IsDir() {
+		t.Fatalf("directories should be copied with their name: %s", err)
+	}
+
+	step.Cleanup(state)
+	if _, err := os.Stat(cdPath.(string)); !os.IsNotExist(err) {
+		t.Fatal("CD should be removed")
+	}
+}
@@ -21,6 +21,10 @@ type StepCreateFloppy struct {
 	Files       []string
 	Directories []string
 
+	// TempDir is the directory the floppy is created in, the default
+	// directory for temporary files if empty.
+	TempDir string
+
 	floppyPath string
 
 	FilesAdded map[string]bool
@@ -38,7 +42,7 @@ func (s *StepCreateFloppy) Run(state multistep.StateBag) multistep.StepAction {
 	ui.Say("Creating floppy disk...")
 
 	// Create a temporary file to be our floppy drive
-	floppyF, err := ioutil.TempFile("", "packer")
+	floppyF, err := ioutil.TempFile(s.TempDir, "packer")
 	if err != nil {
 		state.Put("error",
 			fmt.Errorf("Error creating temporary file for floppy: %s", err))
@@ -5,6 +5,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer/packer"
@@ -43,6 +46,18 @@ type StepDownload struct {
 	// extension on the URL is used. Otherwise, this will be forced
 	// on the downloaded file for every URL.
 	Extension string
+
+	// The URL of the proxy to use for HTTP downloads. If this isn't set,
+	// the proxy is taken from the environment.
+	Proxy string
+
+	// The number of segments to download in parallel from HTTP servers
+	// that support it.
+	Segments int
+
+	// usedKey is the cache key of the downloaded file, which is read locked
+	// until the cleanup so that it isn't pruned while the build uses it.
+	usedKey string
 }
 
 func (s *StepDownload) Run(state multistep.StateBag) multistep.StepAction {
@@ -66,20 +81,11 @@ func (s *StepDownload) Run(state multistep.StateBag) multistep.StepAction {
 		ui.Message(fmt.Sprintf("Downloading or copying: %s", url))
 
 		targetPath := s.TargetPath
+		cacheKey := ""
 		if targetPath == "" {
-			// Determine a cache key. This is normally just the URL but
-			// if we force a certain extension we hash the URL and add
-			// the extension to force it.
-			cacheKey := url
-			if s.Extension != "" {
-				hash := sha1.Sum([]byte(url))
-				cacheKey = fmt.Sprintf(
-					"%s.%s", hex.EncodeToString(hash[:]), s.Extension)
-			}
-
+			cacheKey = s.cacheKey(url)
 			log.Printf("Acquiring lock to download: %s", url)
 			targetPath = cache.Lock(cacheKey)
-			defer cache.Unlock(cacheKey)
 		}
 
 		config := &DownloadConfig{
@@ -89,9 +95,14 @@ func (s *StepDownload) Run(state multistep.StateBag) multistep.StepAction {
 			Hash:       HashForType(s.ChecksumType),
 			Checksum:   checksum,
 			UserAgent:  "Packer",
+			Proxy:      s.Proxy,
+			Segments:   s.Segments,
 		}
 
 		path, err, retry := s.download(config, state)
+		if cacheKey != "" {
+			cache.Unlock(cacheKey)
+		}
 		if err != nil {
 			ui.Message(fmt.Sprintf("Error downloading: %s", err))
 		}
@@ -102,6 +113,11 @@ func (s *StepDownload) Run(state multistep.StateBag) multistep.StepAction {
 
 		if err == nil {
 			finalPath = path
+			if cacheKey != "" && path == targetPath {
+				if _, ok := cache.RLock(cacheKey); ok {
+					s.usedKey = cacheKey
+				}
+			}
 			break
 		}
 	}
@@ -117,7 +133,40 @@ func (s *StepDownload) Run(state multistep.StateBag) multistep.StepAction {
 	return multistep.ActionContinue
 }
 
-func (s *StepDownload) Cleanup(multistep.StateBag) {}
+func (s *StepDownload) Cleanup(state multistep.StateBag) {
+	if s.usedKey != "" {
+		cache := state.Get("cache").(packer.Cache)
+		cache.RUnlock(s.usedKey)
+		s.usedKey = ""
+	}
+}
+
+// cacheKey returns the key of the download in the cache. Downloads with a
+// checksum are cached by their checksum, so that the same file downloaded
+// from any of its URLs, by any build, is only downloaded once. Otherwise
+// the key is the URL, hashed if a certain extension is forced.
+func (s *StepDownload) cacheKey(rawURL string) string {
+	extension := s.Extension
+	if extension == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			extension = strings.TrimPrefix(path.Ext(u.Path), ".")
+		}
+	}
+
+	if s.Checksum != "" && s.ChecksumType != "" && s.ChecksumType != "none" {
+		key := fmt.Sprintf("%s-%s", s.ChecksumType, strings.ToLower(s.Checksum))
+		if extension != "" {
+			key += "." + extension
+		}
+		return key
+	}
+
+	if s.Extension == "" {
+		return rawURL
+	}
+	hash := sha1.Sum([]byte(rawURL))
+	return fmt.Sprintf("%s.%s", hex.EncodeToString(hash[:]), s.Extension)
+}
 
 func (s *StepDownload) download(config *DownloadConfig, state multistep.StateBag) (string, error, bool) {
 	var path string
@@ -131,7 +180,11 @@ func (s *StepDownload) download(config *DownloadConfig, state multistep.StateBag
 		downloadCompleteCh <- err
 	}()
 
-	progressTicker := time.NewTicker(5 * time.Second)
+	// The ticker also checks for interrupts, so its interval is at most a
+	// second
+	progress := packer.NewProgressBar(ui, "Download progress")
+	defer progress.Finish()
+	progressTicker := time.NewTicker(packer.StatusInterval)
 	defer progressTicker.Stop()
 
 	for {
@@ -143,11 +196,10 @@ func (s *StepDownload) download(config *DownloadConfig, state multistep.StateBag
 
 			return path, nil, true
 		case <-progressTicker.C:
-			progress := download.PercentProgress()
-			if progress >= 0 {
-				ui.Message(fmt.Sprintf("Download progress: %d%%", progress))
+			if done, total := download.Progress(); done > 0 {
+				progress.Update(done, total)
 			}
-		case <-time.After(1 * time.Second):
+
 			if _, ok := state.GetOk(multistep.StateCancelled); ok {
 				ui.Say("Interrupt received. Cancelling download...")
 				return "", nil, false
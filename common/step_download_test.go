@@ -12,3 +12,44 @@ func TestStepDownload_Impl(t *testing.T) {
 		t.Fatalf("download should be a step")
 	}
 }
+
+func TestStepDownload_cacheKey(t *testing.T) {
+	cases := []struct {
+		Step StepDownload
+		Url  string
+		Key  string
+	}{
+		{
+			StepDownload{},
+			"http://example.com/os.iso",
+			"http://example.com/os.iso",
+		},
+		{
+			StepDownload{Checksum: "ABC", ChecksumType: "sha512"},
+			"http://example.com/os.iso?mirror=1",
+			"sha512-abc.iso",
+		},
+		{
+			StepDownload{Checksum: "abc", ChecksumType: "sha256", Extension: "ova"},
+			"http://example.com/os",
+			"sha256-abc.ova",
+		},
+		{
+			StepDownload{Checksum: "abc", ChecksumType: "none", Extension: "iso"},
+			"http://example.com/os",
+			"4727bd207935e4f735f68a1b1318461455429d19.iso",
+		},
+	}
+
+	for _, tc := range cases {
+		if key := tc.Step.cacheKey(tc.Url); key != tc.Key {
+			t.Fatalf("%s: bad key: %s", tc.Url, key)
+		}
+	}
+
+	// The same file from different mirrors shares its key.
+	step := StepDownload{Checksum: "abc", ChecksumType: "sha256"}
+	if step.cacheKey("http://a.example.com/os.iso") != step.cacheKey("https://b.example.com/pub/os.iso") {
+		t.Fatal("mirrors should share the cache key")
+	}
+}
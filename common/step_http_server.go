@@ -1,16 +1,22 @@
 package common
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/rand"
+	mathrand "math/rand"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/mitchellh/multistep"
 )
 
@@ -23,10 +29,21 @@ import (
 //
 // Produces:
 //   http_port int - The port the HTTP server started on.
+//   http_scheme string - "http", or "https" if TLS is enabled.
+//   http_token string - The token required to fetch or upload files, if any.
 type StepHTTPServer struct {
-	HTTPDir     string
-	HTTPPortMin uint
-	HTTPPortMax uint
+	HTTPDir            string
+	HTTPPortMin        uint
+	HTTPPortMax        uint
+	HTTPAddress        string
+	HTTPTLS            bool
+	HTTPRequireToken   bool
+	HTTPDisableListing bool
+	HTTPTemplates      []string
+	HTTPUploadDir      string
+
+	// Ctx is used to render the files matching HTTPTemplates.
+	Ctx interpolate.Context
 
 	l net.Listener
 }
@@ -35,11 +52,18 @@ func (s *StepHTTPServer) Run(state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packer.Ui)
 
 	var httpPort uint = 0
-	if s.HTTPDir == "" {
+	if s.HTTPDir == "" && s.HTTPUploadDir == "" {
 		state.Put("http_port", httpPort)
+		state.Put("http_scheme", "http")
+		state.Put("http_token", "")
 		return multistep.ActionContinue
 	}
 
+	bindAddress := s.HTTPAddress
+	if bindAddress == "" {
+		bindAddress = "0.0.0.0"
+	}
+
 	// Find an available TCP port for our HTTP server
 	var httpAddr string
 	portRange := int(s.HTTPPortMax - s.HTTPPortMin)
@@ -50,11 +74,11 @@ func (s *StepHTTPServer) Run(state multistep.StateBag) multistep.StepAction {
 		if portRange > 0 {
 			// Intn will panic if portRange == 0, so we do a check.
 			// Intn is from [0, n), so add 1 to make from [0, n]
-			offset = uint(rand.Intn(portRange + 1))
+			offset = uint(mathrand.Intn(portRange + 1))
 		}
 
 		httpPort = offset + s.HTTPPortMin
-		httpAddr = fmt.Sprintf("0.0.0.0:%d", httpPort)
+		httpAddr = net.JoinHostPort(bindAddress, fmt.Sprintf("%d", httpPort))
 		log.Printf("Trying port: %d", httpPort)
 		s.l, err = net.Listen("tcp", httpAddr)
 		if err == nil {
@@ -62,20 +86,183 @@ func (s *StepHTTPServer) Run(state multistep.StateBag) multistep.StepAction {
 		}
 	}
 
-	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", httpPort))
+	scheme := "http"
+	if s.HTTPTLS {
+		scheme = "https"
+		cert, err := generateHTTPCertificate(bindAddress)
+		if err != nil {
+			err := fmt.Errorf("Error generating HTTP server certificate: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			s.l.Close()
+			s.l = nil
+			return multistep.ActionHalt
+		}
+
+		s.l = tls.NewListener(s.l, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		})
+	}
+
+	// Uploads always require the token, even if downloads don't.
+	var token string
+	if s.HTTPRequireToken || s.HTTPUploadDir != "" {
+		var err error
+		token, err = generateHTTPToken()
+		if err != nil {
+			err := fmt.Errorf("Error generating HTTP server token: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			s.l.Close()
+			s.l = nil
+			return multistep.ActionHalt
+		}
+	}
+
+	if s.HTTPUploadDir != "" {
+		if err := os.MkdirAll(s.HTTPUploadDir, 0755); err != nil {
+			err := fmt.Errorf("Error creating HTTP upload directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			s.l.Close()
+			s.l = nil
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Starting %s server on port %d", strings.ToUpper(scheme), httpPort))
 
 	// Start the HTTP server and run it in the background
-	fileServer := http.FileServer(http.Dir(s.HTTPDir))
-	server := &http.Server{Addr: httpAddr, Handler: fileServer}
+	var handler http.Handler = http.NotFoundHandler()
+	if s.HTTPDir != "" {
+		var fs http.FileSystem = http.Dir(s.HTTPDir)
+		if s.HTTPDisableListing {
+			fs = noListingFileSystem{fs}
+		}
+		handler = http.FileServer(fs)
+	}
+	if len(s.HTTPTemplates) > 0 && s.HTTPDir != "" {
+		ctx := s.Ctx
+		ctx.Data = &HTTPTemplateData{
+			HTTPPort:   httpPort,
+			HTTPScheme: scheme,
+			HTTPToken:  token,
+		}
+		handler = &templateHandler{
+			Dir:      s.HTTPDir,
+			Patterns: s.HTTPTemplates,
+			Ctx:      &ctx,
+			Handler:  handler,
+		}
+	}
+	if s.HTTPUploadDir != "" {
+		ui.Say(fmt.Sprintf("Accepting uploads into %s", s.HTTPUploadDir))
+		handler = &uploadHandler{
+			Dir:     s.HTTPUploadDir,
+			Token:   token,
+			Handler: handler,
+		}
+	}
+	if s.HTTPRequireToken {
+		handler = &tokenHandler{Token: token, Handler: handler}
+	}
+	server := &http.Server{Addr: httpAddr, Handler: handler}
 	go server.Serve(s.l)
 
 	// Save the address into the state so it can be accessed in the future
 	state.Put("http_port", httpPort)
+	state.Put("http_scheme", scheme)
+	state.Put("http_token", token)
 	SetHTTPPort(fmt.Sprintf("%d", httpPort))
+	SetHTTPScheme(scheme)
+	SetHTTPToken(token)
+	if s.HTTPUploadDir != "" {
+		SetHTTPUploadPath(HTTPUploadPath)
+	}
 
 	return multistep.ActionContinue
 }
 
+// HTTPServerFromState returns the scheme and token of the HTTP server
+// started by StepHTTPServer, so that they can be used in boot commands.
+// The defaults are returned if the step didn't run.
+func HTTPServerFromState(state multistep.StateBag) (scheme string, token string) {
+	scheme = "http"
+	if v, ok := state.GetOk("http_scheme"); ok {
+		scheme = v.(string)
+	}
+	if v, ok := state.GetOk("http_token"); ok {
+		token = v.(string)
+	}
+	return
+}
+
+// tokenHandler only serves requests that carry the token, either as a
+// bearer token or as a "token" query parameter for clients, such as boot
+// loaders, that can't set headers.
+type tokenHandler struct {
+	Token   string
+	Handler http.Handler
+}
+
+func (h *tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validHTTPToken(r, h.Token) {
+		log.Printf("[WARN] Rejected HTTP request without a valid token: %s", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.Handler.ServeHTTP(w, r)
+}
+
+// validHTTPToken checks the token of a request, see tokenHandler.
+func validHTTPToken(r *http.Request, expected string) bool {
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// noListingFileSystem hides directories that don't have an index.html so
+// that http.FileServer doesn't list their contents.
+type noListingFileSystem struct {
+	fs http.FileSystem
+}
+
+func (fs noListingFileSystem) Open(name string) (http.File, error) {
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		index, err := fs.fs.Open(strings.TrimSuffix(name, "/") + "/index.html")
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+
+	return f, nil
+}
+
+func generateHTTPToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 func httpAddrFilename(suffix string) string {
 	uuid := os.Getenv("PACKER_RUN_UUID")
 	return filepath.Join(os.TempDir(), fmt.Sprintf("packer-%s-%s", uuid, suffix))
@@ -89,6 +276,22 @@ func SetHTTPIP(ip string) error {
 	return ioutil.WriteFile(httpAddrFilename("ip"), []byte(ip), 0644)
 }
 
+func SetHTTPScheme(scheme string) error {
+	return ioutil.WriteFile(httpAddrFilename("scheme"), []byte(scheme), 0644)
+}
+
+// SetHTTPToken saves the token of the HTTP server. The file is only
+// readable by the current user since the token protects the files served.
+func SetHTTPToken(token string) error {
+	return ioutil.WriteFile(httpAddrFilename("token"), []byte(token), 0600)
+}
+
+// SetHTTPUploadPath saves the path of the upload endpoint. It is only set
+// if uploads are enabled.
+func SetHTTPUploadPath(path string) error {
+	return ioutil.WriteFile(httpAddrFilename("upload"), []byte(path), 0644)
+}
+
 func GetHTTPAddr() string {
 	ip, err := ioutil.ReadFile(httpAddrFilename("ip"))
 	if err != nil {
@@ -101,6 +304,40 @@ func GetHTTPAddr() string {
 	return fmt.Sprintf("%s:%s", ip, port)
 }
 
+// GetHTTPEnvVars returns the environment variables that describe the
+// HTTP server to provisioners. It is empty if no HTTP server is running.
+func GetHTTPEnvVars() map[string]string {
+	envVars := make(map[string]string)
+
+	httpAddr := GetHTTPAddr()
+	if httpAddr == "" {
+		return envVars
+	}
+
+	ip, port, err := net.SplitHostPort(httpAddr)
+	if err != nil {
+		return envVars
+	}
+
+	scheme := "http"
+	if v, err := ioutil.ReadFile(httpAddrFilename("scheme")); err == nil && len(v) > 0 {
+		scheme = string(v)
+	}
+
+	envVars["PACKER_HTTP_ADDR"] = httpAddr
+	envVars["PACKER_HTTP_IP"] = ip
+	envVars["PACKER_HTTP_PORT"] = port
+	envVars["PACKER_HTTP_SCHEME"] = scheme
+	if token, err := ioutil.ReadFile(httpAddrFilename("token")); err == nil && len(token) > 0 {
+		envVars["PACKER_HTTP_TOKEN"] = string(token)
+	}
+	if path, err := ioutil.ReadFile(httpAddrFilename("upload")); err == nil && len(path) > 0 {
+		envVars["PACKER_HTTP_UPLOAD"] = fmt.Sprintf("%s://%s%s", scheme, httpAddr, path)
+	}
+
+	return envVars
+}
+
 func (s *StepHTTPServer) Cleanup(multistep.StateBag) {
 	if s.l != nil {
 		// Close the listener so that the HTTP server stops
@@ -108,4 +345,7 @@ func (s *StepHTTPServer) Cleanup(multistep.StateBag) {
 	}
 	os.Remove(httpAddrFilename("port"))
 	os.Remove(httpAddrFilename("ip"))
+	os.Remove(httpAddrFilename("scheme"))
+	os.Remove(httpAddrFilename("token"))
+	os.Remove(httpAddrFilename("upload"))
 }
@@ -0,0 +1,297 @@
+package common
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/multistep"
+)
+
+func TestStepHTTPServer_Impl(t *testing.T) {
+	var raw interface{}
+	raw = new(StepHTTPServer)
+	if _, ok := raw.(multistep.Step); !ok {
+		t.Fatalf("StepHTTPServer should be a step")
+	}
+}
+
+func testStepHTTPServerState(t *testing.T) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func testStepHTTPServerDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "ks.cfg"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dir
+}
+
+func testStepHTTPServerGet(t *testing.T, url string, header http.Header) int {
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestStepHTTPServer_noDir(t *testing.T) {
+	state := testStepHTTPServerState(t)
+	step := new(StepHTTPServer)
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	scheme, token := HTTPServerFromState(state)
+	if scheme != "http" || token != "" {
+		t.Fatalf("bad: %s %s", scheme, token)
+	}
+}
+
+func TestStepHTTPServer_tlsToken(t *testing.T) {
+	dir := testStepHTTPServerDir(t)
+	defer os.RemoveAll(dir)
+
+	state := testStepHTTPServerState(t)
+	step := &StepHTTPServer{
+		HTTPDir:          dir,
+		HTTPPortMin:      8000,
+		HTTPPortMax:      9000,
+		HTTPAddress:      "127.0.0.1",
+		HTTPTLS:          true,
+		HTTPRequireToken: true,
+	}
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	scheme, token := HTTPServerFromState(state)
+	if scheme != "https" {
+		t.Fatalf("bad: %s", scheme)
+	}
+	if token == "" {
+		t.Fatal("should have a token")
+	}
+
+	url := fmt.Sprintf("https://127.0.0.1:%d/ks.cfg", state.Get("http_port").(uint))
+	if code := testStepHTTPServerGet(t, url, nil); code != http.StatusUnauthorized {
+		t.Fatalf("bad: %d", code)
+	}
+	if code := testStepHTTPServerGet(t, url+"?token="+token, nil); code != http.StatusOK {
+		t.Fatalf("bad: %d", code)
+	}
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	if code := testStepHTTPServerGet(t, url, header); code != http.StatusOK {
+		t.Fatalf("bad: %d", code)
+	}
+}
+
+func TestStepHTTPServer_disableListing(t *testing.T) {
+	dir := testStepHTTPServerDir(t)
+	defer os.RemoveAll(dir)
+
+	state := testStepHTTPServerState(t)
+	step := &StepHTTPServer{
+		HTTPDir:            dir,
+		HTTPPortMin:        8000,
+		HTTPPortMax:        9000,
+		HTTPAddress:        "127.0.0.1",
+		HTTPDisableListing: true,
+	}
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", state.Get("http_port").(uint))
+	if code := testStepHTTPServerGet(t, base+"/ks.cfg", nil); code != http.StatusOK {
+		t.Fatalf("bad: %d", code)
+	}
+	if code := testStepHTTPServerGet(t, base+"/", nil); code != http.StatusNotFound {
+		t.Fatalf("bad: %d", code)
+	}
+	if code := testStepHTTPServerGet(t, base+"/sub/", nil); code != http.StatusNotFound {
+		t.Fatalf("bad: %d", code)
+	}
+}
+
+func TestStepHTTPServer_templates(t *testing.T) {
+	dir := testStepHTTPServerDir(t)
+	defer os.RemoveAll(dir)
+
+	contents := []byte(`{{ build_name }} {{ user "password" }} {{ .HTTPPort }}`)
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "unattend.xml"), contents, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testStepHTTPServerState(t)
+	step := &StepHTTPServer{
+		HTTPDir:       dir,
+		HTTPPortMin:   8000,
+		HTTPPortMax:   9000,
+		HTTPAddress:   "127.0.0.1",
+		HTTPTemplates: []string{"*.xml"},
+		Ctx: interpolate.Context{
+			BuildName:     "foo",
+			UserVariables: map[string]string{"password": "secret"},
+		},
+	}
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	port := state.Get("http_port").(uint)
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	resp, err := http.Get(base + "/sub/unattend.xml")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := fmt.Sprintf("foo secret %d", port); string(body) != expected {
+		t.Fatalf("bad: %q", body)
+	}
+
+	// Files that don't match are served as-is
+	resp, err = http.Get(base + "/ks.cfg")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("bad: %q", body)
+	}
+}
+
+func TestStepHTTPServer_upload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	state := testStepHTTPServerState(t)
+	step := &StepHTTPServer{
+		HTTPPortMin:   8000,
+		HTTPPortMax:   9000,
+		HTTPAddress:   "127.0.0.1",
+		HTTPUploadDir: filepath.Join(dir, "uploads"),
+	}
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	defer step.Cleanup(state)
+
+	_, token := HTTPServerFromState(state)
+	if token == "" {
+		t.Fatal("should have a token")
+	}
+
+	upload := func(path string, header http.Header) int {
+		url := fmt.Sprintf("http://127.0.0.1:%d%s", state.Get("http_port").(uint), path)
+		req, err := http.NewRequest("PUT", url, bytes.NewBufferString("log"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		req.Header = header
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	auth := http.Header{"Authorization": []string{"Bearer " + token}}
+	cases := []struct {
+		Path   string
+		Header http.Header
+		Code   int
+		File   string
+	}{
+		{"/upload/a.log", nil, http.StatusUnauthorized, ""},
+		{"/upload/a.log", auth, http.StatusCreated, "a.log"},
+		{"/upload/logs/b.log", auth, http.StatusCreated, "logs/b.log"},
+		{"/upload/../../c.log", auth, http.StatusCreated, "c.log"},
+		{"/upload/", auth, http.StatusBadRequest, ""},
+		{"/a.log", auth, http.StatusNotFound, ""},
+	}
+
+	for _, tc := range cases {
+		if code := upload(tc.Path, tc.Header); code != tc.Code {
+			t.Fatalf("bad: %s: %d", tc.Path, code)
+		}
+		if tc.File == "" {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "uploads", tc.File))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(contents) != "log" {
+			t.Fatalf("bad: %s: %q", tc.Path, contents)
+		}
+	}
+
+	// The IP is normally set by the builder
+	SetHTTPIP("127.0.0.1")
+	vars := GetHTTPEnvVars()
+	expected := fmt.Sprintf("http://127.0.0.1:%d/upload/", state.Get("http_port").(uint))
+	if vars["PACKER_HTTP_UPLOAD"] != expected {
+		t.Fatalf("bad: %#v", vars)
+	}
+}
@@ -11,6 +11,7 @@ import (
 //
 // Uses:
 //   communicator packer.Communicator
+//   guest_facts  *packer.GuestFacts
 //   hook         packer.Hook
 //   ui           packer.Ui
 //
@@ -18,6 +19,10 @@ import (
 //   <nothing>
 type StepProvision struct {
 	Comm packer.Communicator
+
+	// Data is the data of the provision hook, which can select the
+	// provisioners to run with packer.ProvisionRangeData.
+	Data interface{}
 }
 
 func (s *StepProvision) Run(state multistep.StateBag) multistep.StepAction {
@@ -31,12 +36,17 @@ func (s *StepProvision) Run(state multistep.StateBag) multistep.StepAction {
 	hook := state.Get("hook").(packer.Hook)
 	ui := state.Get("ui").(packer.Ui)
 
+	// Publish the guest facts to the provisioners
+	if facts, ok := state.GetOk("guest_facts"); ok {
+		comm = packer.WithGuestFacts(comm, facts.(*packer.GuestFacts))
+	}
+
 	// Run the provisioner in a goroutine so we can continually check
 	// for cancellations...
 	log.Println("Running the provision hook")
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- hook.Run(packer.HookProvision, ui, comm, nil)
+		errCh <- hook.Run(packer.HookProvision, ui, comm, s.Data)
 	}()
 
 	for {
@@ -52,6 +62,9 @@ func (s *StepProvision) Run(state multistep.StateBag) multistep.StepAction {
 			if _, ok := state.GetOk(multistep.StateCancelled); ok {
 				log.Println("Cancelling provisioning due to interrupt...")
 				hook.Cancel()
+
+				// Let the provisioner stop before the builder cleans up
+				<-errCh
 				return multistep.ActionHalt
 			}
 		}
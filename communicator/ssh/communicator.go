@@ -3,6 +3,7 @@ package ssh
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -55,6 +56,23 @@ type Config struct {
 
 	// UseSftp, if true, sftp will be used instead of scp for file transfers
 	UseSftp bool
+
+	// SftpConcurrency is how many parts of large files are uploaded at
+	// once with sftp. Zero or one uploads them sequentially.
+	SftpConcurrency int
+
+	// Compression, if true, compresses file uploads with scp. The Go SSH
+	// library doesn't negotiate compression, so files are gzipped and
+	// uncompressed by gzip on the remote end instead.
+	Compression bool
+
+	// BandwidthLimit is the maximum bandwidth of file transfers in bytes
+	// per second. Zero means unlimited.
+	BandwidthLimit int64
+
+	// Ui, if set, is where the progress of long file transfers and the
+	// reconnects are reported.
+	Ui packer.Ui
 }
 
 // Creates a new packer.Communicator implementation over SSH. This takes
@@ -98,7 +116,7 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 		}
 	}
 
-	log.Printf("starting remote command: %s", cmd.Command)
+	log.Printf("(communicator.ssh) starting remote command: %s", cmd.Command)
 	err = session.Start(cmd.Command + "\n")
 	if err != nil {
 		return
@@ -115,12 +133,12 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 			switch err.(type) {
 			case *ssh.ExitError:
 				exitStatus = err.(*ssh.ExitError).ExitStatus()
-				log.Printf("Remote command exited with '%d': %s", exitStatus, cmd.Command)
+				log.Printf("(communicator.ssh) Remote command exited with '%d': %s", exitStatus, cmd.Command)
 			case *ssh.ExitMissingError:
-				log.Printf("Remote command exited without exit status or exit signal.")
+				log.Printf("(communicator.ssh) Remote command exited without exit status or exit signal.")
 				exitStatus = packer.CmdDisconnect
 			default:
-				log.Printf("Error occurred waiting for ssh session: %s", err.Error())
+				log.Printf("(communicator.ssh) Error occurred waiting for ssh session: %s", err.Error())
 			}
 		}
 		cmd.SetExited(exitStatus)
@@ -129,24 +147,56 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 }
 
 func (c *comm) Upload(path string, input io.Reader, fi *os.FileInfo) error {
+	var size int64
+	if fi != nil && (*fi).Mode().IsRegular() {
+		size = (*fi).Size()
+	}
+	t, finish := c.newTransfer("Uploading "+path, packer.MetricUploadBytes, size)
+	defer finish()
+
 	if c.config.UseSftp {
-		return c.sftpUploadSession(path, input, fi)
+		return c.sftpUploadSession(path, input, fi, t)
+	} else if c.config.Compression {
+		return c.gzipUploadSession(path, input, fi, t)
 	} else {
-		return c.scpUploadSession(path, input, fi)
+		return c.scpUploadSession(path, input, fi, t)
 	}
 }
 
 func (c *comm) UploadDir(dst string, src string, excl []string) error {
-	log.Printf("Upload dir '%s' to '%s'", src, dst)
+	log.Printf("(communicator.ssh) Upload dir '%s' to '%s'", src, dst)
+	t, finish := c.newTransfer("Uploading "+src, packer.MetricUploadBytes, packer.DirSize(src))
+	defer finish()
+
 	if c.config.UseSftp {
-		return c.sftpUploadDirSession(dst, src, excl)
+		return c.sftpUploadDirSession(dst, src, excl, t)
 	} else {
-		return c.scpUploadDirSession(dst, src, excl)
+		return c.scpUploadDirSession(dst, src, excl, t)
+	}
+}
+
+// newTransfer returns a transfer limited to the bandwidth limit of the
+// config, reporting its progress to the Ui of the config, and the
+// function to call once it is done, which adds the bytes transferred to
+// the metric.
+func (c *comm) newTransfer(description, metric string, total int64) (*packer.Transfer, func()) {
+	t := &packer.Transfer{Limit: c.config.BandwidthLimit, Total: total}
+	if c.config.Ui == nil {
+		return t, func() {}
+	}
+	bar := packer.NewProgressBar(c.config.Ui, description)
+	t.Progress = bar.Update
+	return t, func() {
+		bar.Finish()
+		packer.AddMetric(c.config.Ui, metric, t.Done())
 	}
 }
 
 func (c *comm) DownloadDir(src string, dst string, excl []string) error {
-	log.Printf("Download dir '%s' to '%s'", src, dst)
+	log.Printf("(communicator.ssh) Download dir '%s' to '%s'", src, dst)
+	t, finish := c.newTransfer("Downloading "+src, packer.MetricDownloadBytes, 0)
+	defer finish()
+
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		dirStack := []string{dst}
 		for {
@@ -181,7 +231,7 @@ func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 			var mode int64
 			var size int64
 			var name string
-			log.Printf("Download dir str:%s", fi)
+			log.Printf("(communicator.ssh) Download dir str:%s", fi)
 			n, err := fmt.Sscanf(fi[1:], "%o %d %s", &mode, &size, &name)
 			if err != nil || n != 3 {
 				return fmt.Errorf("can't parse server response (%s)", fi)
@@ -190,7 +240,7 @@ func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 				return fmt.Errorf("negative file size")
 			}
 
-			log.Printf("Download dir mode:%0o size:%d name:%s", mode, size, name)
+			log.Printf("(communicator.ssh) Download dir mode:%0o size:%d name:%s", mode, size, name)
 
 			dst = filepath.Join(dirStack...)
 			switch fi[0] {
@@ -203,7 +253,7 @@ func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 				continue
 			case 'C':
 				fmt.Fprint(w, "\x00")
-				err = scpDownloadFile(filepath.Join(dst, name), stdoutR, size, os.FileMode(mode))
+				err = scpDownloadFile(filepath.Join(dst, name), t.Reader(stdoutR), size, os.FileMode(mode))
 				if err != nil {
 					return err
 				}
@@ -218,14 +268,17 @@ func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 }
 
 func (c *comm) Download(path string, output io.Writer) error {
+	t, finish := c.newTransfer("Downloading "+path, packer.MetricDownloadBytes, 0)
+	defer finish()
+
 	if c.config.UseSftp {
-		return c.sftpDownloadSession(path, output)
+		return c.sftpDownloadSession(path, output, t)
 	}
-	return c.scpDownloadSession(path, output)
+	return c.scpDownloadSession(path, output, t)
 }
 
 func (c *comm) newSession() (session *ssh.Session, err error) {
-	log.Println("opening new ssh session")
+	log.Println("(communicator.ssh) opening new ssh session")
 	if c.client == nil {
 		err = errors.New("client not available")
 	} else {
@@ -233,10 +286,12 @@ func (c *comm) newSession() (session *ssh.Session, err error) {
 	}
 
 	if err != nil {
-		log.Printf("ssh session open error: '%s', attempting reconnect", err)
+		log.Printf("(communicator.ssh) ssh session open error: '%s', attempting reconnect", err)
 		if err := c.reconnect(); err != nil {
 			return nil, err
 		}
+		packer.CommunicatorEvent(c.config.Ui, packer.CommunicatorReconnect, "ssh",
+			fmt.Sprintf("session open error: %s", err))
 
 		if c.client == nil {
 			return nil, errors.New("client not available")
@@ -258,7 +313,7 @@ func (c *comm) reconnect() (err error) {
 	c.conn = nil
 	c.client = nil
 
-	log.Printf("reconnecting to TCP connection for SSH")
+	log.Printf("(communicator.ssh) reconnecting to TCP connection for SSH")
 	c.conn, err = c.config.Connection()
 	if err != nil {
 		// Explicitly set this to the REAL nil. Connection() can return
@@ -269,11 +324,11 @@ func (c *comm) reconnect() (err error) {
 		// http://golang.org/doc/faq#nil_error
 		c.conn = nil
 
-		log.Printf("reconnection error: %s", err)
+		log.Printf("(communicator.ssh) reconnection error: %s", err)
 		return
 	}
 
-	log.Printf("handshaking with SSH")
+	log.Printf("(communicator.ssh) handshaking with SSH")
 
 	// Default timeout to 1 minute if it wasn't specified (zero value). For
 	// when you need to handshake from low orbit.
@@ -310,10 +365,10 @@ func (c *comm) reconnect() (err error) {
 	}
 
 	if err != nil {
-		log.Printf("handshake error: %s", err)
+		log.Printf("(communicator.ssh) handshake error: %s", err)
 		return
 	}
-	log.Printf("handshake complete!")
+	log.Printf("(communicator.ssh) handshake complete!")
 	if sshConn != nil {
 		c.client = ssh.NewClient(sshConn, sshChan, req)
 	}
@@ -328,26 +383,26 @@ func (c *comm) connectToAgent() {
 	}
 
 	if c.config.DisableAgentForwarding {
-		log.Printf("[INFO] SSH agent forwarding is disabled.")
+		log.Printf("[INFO] (communicator.ssh) SSH agent forwarding is disabled.")
 		return
 	}
 
 	// open connection to the local agent
 	socketLocation := os.Getenv("SSH_AUTH_SOCK")
 	if socketLocation == "" {
-		log.Printf("[INFO] no local agent socket, will not connect agent")
+		log.Printf("[INFO] (communicator.ssh) no local agent socket, will not connect agent")
 		return
 	}
 	agentConn, err := net.Dial("unix", socketLocation)
 	if err != nil {
-		log.Printf("[ERROR] could not connect to local agent socket: %s", socketLocation)
+		log.Printf("[ERROR] (communicator.ssh) could not connect to local agent socket: %s", socketLocation)
 		return
 	}
 
 	// create agent and add in auth
 	forwardingAgent := agent.NewClient(agentConn)
 	if forwardingAgent == nil {
-		log.Printf("[ERROR] Could not create agent client")
+		log.Printf("[ERROR] (communicator.ssh) Could not create agent client")
 		agentConn.Close()
 		return
 	}
@@ -367,24 +422,28 @@ func (c *comm) connectToAgent() {
 
 	err = agent.RequestAgentForwarding(session)
 	if err != nil {
-		log.Printf("[ERROR] RequestAgentForwarding: %#v", err)
+		log.Printf("[ERROR] (communicator.ssh) RequestAgentForwarding: %#v", err)
 		return
 	}
 
-	log.Printf("[INFO] agent forwarding enabled")
+	log.Printf("[INFO] (communicator.ssh) agent forwarding enabled")
 	return
 }
 
-func (c *comm) sftpUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
+// sftpChunkSize is the minimum size of the parts of a file uploaded at
+// once with sftp, so that small files are uploaded sequentially.
+var sftpChunkSize int64 = 32 * 1024 * 1024
+
+func (c *comm) sftpUploadSession(path string, input io.Reader, fi *os.FileInfo, t *packer.Transfer) error {
 	sftpFunc := func(client *sftp.Client) error {
-		return sftpUploadFile(path, input, client, fi)
+		return sftpUploadFile(path, input, client, fi, c.config.SftpConcurrency, t)
 	}
 
 	return c.sftpSession(sftpFunc)
 }
 
-func sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo) error {
-	log.Printf("[DEBUG] sftp: uploading %s", path)
+func sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.FileInfo, concurrency int, t *packer.Transfer) error {
+	log.Printf("[DEBUG] (communicator.ssh) sftp: uploading %s", path)
 
 	f, err := client.Create(path)
 	if err != nil {
@@ -392,7 +451,18 @@ func sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.Fi
 	}
 	defer f.Close()
 
-	if _, err = io.Copy(f, input); err != nil {
+	// Large files that can be read at any offset are uploaded in parts
+	// at once, which is much faster over links with a high latency
+	var size int64
+	if fi != nil && (*fi).Mode().IsRegular() {
+		size = (*fi).Size()
+	}
+	if ra, ok := input.(io.ReaderAt); ok && concurrency > 1 && size >= 2*sftpChunkSize {
+		err = sftpUploadChunks(path, ra, size, client, concurrency, t)
+	} else {
+		_, err = io.Copy(f, t.Reader(input))
+	}
+	if err != nil {
 		return err
 	}
 
@@ -407,11 +477,56 @@ func sftpUploadFile(path string, input io.Reader, client *sftp.Client, fi *os.Fi
 	return nil
 }
 
-func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error {
+// sftpUploadChunks uploads the file in as many parts as the concurrency,
+// each written with its own handle on the remote file.
+func sftpUploadChunks(path string, input io.ReaderAt, size int64, client *sftp.Client, concurrency int, t *packer.Transfer) error {
+	parts := int64(concurrency)
+	if n := size / sftpChunkSize; n < parts {
+		parts = n
+	}
+	partSize := (size + parts - 1) / parts
+	log.Printf("[DEBUG] (communicator.ssh) sftp: uploading %s in %d parts", path, parts)
+
+	errs := make(chan error, parts)
+	for offset := int64(0); offset < size; offset += partSize {
+		n := partSize
+		if offset+n > size {
+			n = size - offset
+		}
+
+		go func(offset, n int64) {
+			errs <- sftpUploadChunk(path, io.NewSectionReader(input, offset, n), offset, client, t)
+		}(offset, n)
+	}
+
+	var err error
+	for i := int64(0); i < parts; i++ {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func sftpUploadChunk(path string, input io.Reader, offset int64, client *sftp.Client, t *packer.Transfer) error {
+	f, err := client.OpenFile(path, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(f, t.Reader(input))
+	return err
+}
+
+func (c *comm) sftpUploadDirSession(dst string, src string, excl []string, t *packer.Transfer) error {
 	sftpFunc := func(client *sftp.Client) error {
 		rootDst := dst
 		if src[len(src)-1] != '/' {
-			log.Printf("No trailing slash, creating the source directory name")
+			log.Printf("(communicator.ssh) No trailing slash, creating the source directory name")
 			rootDst = filepath.Join(dst, filepath.Base(src))
 		}
 		walkFunc := func(path string, info os.FileInfo, err error) error {
@@ -436,7 +551,7 @@ func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error
 				return nil
 			}
 
-			return sftpVisitFile(finalDst, path, info, client)
+			return sftpVisitFile(finalDst, path, info, client, c.config.SftpConcurrency, t)
 		}
 
 		return filepath.Walk(src, walkFunc)
@@ -446,7 +561,7 @@ func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error
 }
 
 func sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
-	log.Printf("[DEBUG] sftp: creating dir %s", path)
+	log.Printf("[DEBUG] (communicator.ssh) sftp: creating dir %s", path)
 
 	if err := client.Mkdir(path); err != nil {
 		// Do not consider it an error if the directory existed
@@ -463,21 +578,21 @@ func sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
 	return nil
 }
 
-func sftpVisitFile(dst string, src string, fi os.FileInfo, client *sftp.Client) error {
+func sftpVisitFile(dst string, src string, fi os.FileInfo, client *sftp.Client, concurrency int, t *packer.Transfer) error {
 	if !fi.IsDir() {
 		f, err := os.Open(src)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
-		return sftpUploadFile(dst, f, client, &fi)
+		return sftpUploadFile(dst, f, client, &fi, concurrency, t)
 	} else {
 		err := sftpMkdir(dst, client, fi)
 		return err
 	}
 }
 
-func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
+func (c *comm) sftpDownloadSession(path string, output io.Writer, t *packer.Transfer) error {
 	sftpFunc := func(client *sftp.Client) error {
 		f, err := client.Open(path)
 		if err != nil {
@@ -485,7 +600,10 @@ func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
 		}
 		defer f.Close()
 
-		if _, err = io.Copy(output, f); err != nil {
+		if fi, err := f.Stat(); err == nil {
+			t.Total = fi.Size()
+		}
+		if _, err = io.Copy(t.Writer(output), f); err != nil {
 			return err
 		}
 
@@ -527,7 +645,7 @@ func (c *comm) newSftpClient() (*sftp.Client, error) {
 	return sftp.NewClientPipe(pr, pw)
 }
 
-func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
+func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo, t *packer.Transfer) error {
 
 	// The target directory and file for talking the SCP protocol
 	target_dir := filepath.Dir(path)
@@ -539,14 +657,61 @@ func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) e
 	target_dir = filepath.ToSlash(target_dir)
 
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
-		return scpUploadFile(target_file, input, w, stdoutR, fi)
+		return scpUploadFile(target_file, input, t.Writer(w), stdoutR, fi)
 	}
 
 	return c.scpSession("scp -vt "+target_dir, scpFunc)
 }
 
-func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error {
+// gzipUploadSession uploads the file compressed with gzip, which is
+// uncompressed by gzip on the remote end.
+func (c *comm) gzipUploadSession(path string, input io.Reader, fi *os.FileInfo, t *packer.Transfer) error {
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	cmd := "gzip -dc > " + shellQuote(path)
+	if fi != nil && (*fi).Mode().IsRegular() {
+		cmd += fmt.Sprintf(" && chmod %04o %s", (*fi).Mode().Perm(), shellQuote(path))
+	}
+	log.Printf("[DEBUG] (communicator.ssh) uploading %s compressed: %s", path, cmd)
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+
+	zw, err := gzip.NewWriterLevel(stdin, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zw, t.Reader(input))
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	stdin.Close()
+	if werr := session.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("error uncompressing %s: %s: %s",
+			path, werr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+func (c *comm) scpUploadDirSession(dst string, src string, excl []string, t *packer.Transfer) error {
 	scpFunc := func(w io.Writer, r *bufio.Reader) error {
+		w = t.Writer(w)
 		uploadEntries := func() error {
 			f, err := os.Open(src)
 			if err != nil {
@@ -563,7 +728,7 @@ func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error
 		}
 
 		if src[len(src)-1] != '/' {
-			log.Printf("No trailing slash, creating the source directory name")
+			log.Printf("(communicator.ssh) No trailing slash, creating the source directory name")
 			fi, err := os.Stat(src)
 			if err != nil {
 				return err
@@ -578,7 +743,7 @@ func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error
 	return c.scpSession("scp -rvt "+dst, scpFunc)
 }
 
-func (c *comm) scpDownloadSession(path string, output io.Writer) error {
+func (c *comm) scpDownloadSession(path string, output io.Writer, t *packer.Transfer) error {
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		fmt.Fprint(w, "\x00")
 
@@ -615,7 +780,8 @@ func (c *comm) scpDownloadSession(path string, output io.Writer) error {
 
 		fmt.Fprint(w, "\x00")
 
-		if _, err := io.CopyN(output, stdoutR, size); err != nil {
+		t.Total = size
+		if _, err := io.CopyN(output, t.Reader(stdoutR), size); err != nil {
 			return err
 		}
 
@@ -664,7 +830,7 @@ func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 
 	// Start the sink mode on the other side
 	// TODO(mitchellh): There are probably issues with shell escaping the path
-	log.Println("Starting remote scp process: ", scpCommand)
+	log.Println("(communicator.ssh) Starting remote scp process: ", scpCommand)
 	if err := session.Start(scpCommand); err != nil {
 		return err
 	}
@@ -672,7 +838,7 @@ func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	// Call our callback that executes in the context of SCP. We ignore
 	// EOF errors if they occur because it usually means that SCP prematurely
 	// ended on the other side.
-	log.Println("Started SCP session, beginning transfers...")
+	log.Println("(communicator.ssh) Started SCP session, beginning transfers...")
 	if err := f(stdinW, stdoutR); err != nil && err != io.EOF {
 		return err
 	}
@@ -680,24 +846,24 @@ func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 	// Close the stdin, which sends an EOF, and then set w to nil so that
 	// our defer func doesn't close it again since that is unsafe with
 	// the Go SSH package.
-	log.Println("SCP session complete, closing stdin pipe.")
+	log.Println("(communicator.ssh) SCP session complete, closing stdin pipe.")
 	stdinW.Close()
 	stdinW = nil
 
 	// Wait for the SCP connection to close, meaning it has consumed all
 	// our data and has completed. Or has errored.
-	log.Println("Waiting for SSH session to complete.")
+	log.Println("(communicator.ssh) Waiting for SSH session to complete.")
 	err = session.Wait()
 	if err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
 			// Otherwise, we have an ExitErorr, meaning we can just read
 			// the exit status
-			log.Printf("non-zero exit status: %d", exitErr.ExitStatus())
+			log.Printf("(communicator.ssh) non-zero exit status: %d", exitErr.ExitStatus())
 			stdoutB, err := ioutil.ReadAll(stdoutR)
 			if err != nil {
 				return err
 			}
-			log.Printf("scp output: %s", stdoutB)
+			log.Printf("(communicator.ssh) scp output: %s", stdoutB)
 
 			// If we exited with status 127, it means SCP isn't available.
 			// Return a more descriptive error for that.
@@ -711,7 +877,7 @@ func (c *comm) scpSession(scpCommand string, f func(io.Writer, *bufio.Reader) er
 		return err
 	}
 
-	log.Printf("scp stderr (length %d): %s", stderr.Len(), stderr.String())
+	log.Printf("(communicator.ssh) scp stderr (length %d): %s", stderr.Len(), stderr.String())
 	return nil
 }
 
@@ -768,7 +934,7 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 
 		mode = 0644
 
-		log.Println("Copying input data into temporary file so we can read the length")
+		log.Println("(communicator.ssh) Copying input data into temporary file so we can read the length")
 		if _, err := io.Copy(tf, src); err != nil {
 			return err
 		}
@@ -795,7 +961,7 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 
 	// Start the protocol
 	perms := fmt.Sprintf("C%04o", mode)
-	log.Printf("[DEBUG] scp: Uploading %s: perms=%s size=%d", dst, perms, size)
+	log.Printf("[DEBUG] (communicator.ssh) scp: Uploading %s: perms=%s size=%d", dst, perms, size)
 
 	fmt.Fprintln(w, perms, size, dst)
 	if err := checkSCPStatus(r); err != nil {
@@ -811,7 +977,7 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 }
 
 func scpUploadDirProtocol(name string, w io.Writer, r *bufio.Reader, f func() error, fi os.FileInfo) error {
-	log.Printf("SCP: starting directory upload: %s", name)
+	log.Printf("(communicator.ssh) SCP: starting directory upload: %s", name)
 
 	mode := fi.Mode().Perm()
 
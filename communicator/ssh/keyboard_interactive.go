@@ -0,0 +1,52 @@
+package ssh
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// KeyboardInteractiveAnswer is the answer to the questions of a
+// keyboard-interactive challenge that match Prompt.
+type KeyboardInteractiveAnswer struct {
+	Prompt *regexp.Regexp
+	Answer string
+}
+
+// KeyboardInteractive returns an ssh.KeyboardInteractiveChallenge that
+// answers every question with the first answer whose prompt matches it.
+// Other questions are answered by fallback, or fail the challenge if it
+// is nil. The questions are logged, the answers aren't.
+func KeyboardInteractive(answers []KeyboardInteractiveAnswer, fallback func(question string, echo bool) (string, error)) ssh.KeyboardInteractiveChallenge {
+	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		log.Printf("(communicator.ssh) Keyboard interactive challenge: ")
+		log.Printf("(communicator.ssh) -- User: %s", user)
+		log.Printf("(communicator.ssh) -- Instructions: %s", instruction)
+
+		result := make([]string, len(questions))
+	Questions:
+		for i, question := range questions {
+			log.Printf("(communicator.ssh) -- Question %d: %s", i+1, question)
+			for _, a := range answers {
+				if a.Prompt.MatchString(question) {
+					result[i] = a.Answer
+					continue Questions
+				}
+			}
+
+			if fallback == nil {
+				return nil, fmt.Errorf("no answer to keyboard-interactive prompt %q", question)
+			}
+			echo := i < len(echos) && echos[i]
+			answer, err := fallback(question, echo)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = answer
+		}
+
+		return result, nil
+	}
+}
@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestKeyboardInteractive(t *testing.T) {
+	answers := []KeyboardInteractiveAnswer{
+		{regexp.MustCompile(`(?i)verification code`), "123456"},
+		{regexp.MustCompile(`(?i)password`), "secret"},
+	}
+
+	var asked []string
+	fallback := func(question string, echo bool) (string, error) {
+		asked = append(asked, question)
+		return "yes", nil
+	}
+
+	challenge := KeyboardInteractive(answers, fallback)
+	result, err := challenge("root", "", []string{"Password: ", "Verification code: ", "Accept? "}, []bool{false, false, true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(result, []string{"secret", "123456", "yes"}) {
+		t.Fatalf("bad: %#v", result)
+	}
+	if !reflect.DeepEqual(asked, []string{"Accept? "}) {
+		t.Fatalf("bad: %#v", asked)
+	}
+
+	// Without a fallback, unknown prompts fail the challenge
+	challenge = KeyboardInteractive(answers, nil)
+	if _, err := challenge("root", "", []string{"Accept? "}, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -10,11 +10,11 @@ import (
 // back the password for all questions. The questions are logged.
 func PasswordKeyboardInteractive(password string) ssh.KeyboardInteractiveChallenge {
 	return func(user, instruction string, questions []string, echos []bool) ([]string, error) {
-		log.Printf("Keyboard interactive challenge: ")
-		log.Printf("-- User: %s", user)
-		log.Printf("-- Instructions: %s", instruction)
+		log.Printf("(communicator.ssh) Keyboard interactive challenge: ")
+		log.Printf("(communicator.ssh) -- User: %s", user)
+		log.Printf("(communicator.ssh) -- Instructions: %s", instruction)
 		for i, question := range questions {
-			log.Printf("-- Question %d: %s", i+1, question)
+			log.Printf("(communicator.ssh) -- Question %d: %s", i+1, question)
 		}
 
 		// Just send the password back for all questions
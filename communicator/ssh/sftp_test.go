@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/pkg/sftp"
+)
+
+// testSftpClient returns a client of an sftp server serving the local
+// file system in memory.
+func testSftpClient(t *testing.T) *sftp.Client {
+	c2sR, c2sW := io.Pipe()
+	s2cR, s2cW := io.Pipe()
+
+	server, err := sftp.NewServer(c2sR, s2cW)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(s2cR, c2sW)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return client
+}
+
+func TestSftpUploadFile_chunks(t *testing.T) {
+	defer func(size int64) { sftpChunkSize = size }(sftpChunkSize)
+	sftpChunkSize = 1024
+
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	data := make([]byte, 10*1024+7)
+	rand.Read(data)
+	src := filepath.Join(td, "src")
+	if err := ioutil.WriteFile(src, data, 0640); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := testSftpClient(t)
+	defer client.Close()
+
+	for _, concurrency := range []int{0, 4} {
+		f, err := os.Open(src)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		dst := filepath.Join(td, "dst")
+		tr := new(packer.Transfer)
+		err = sftpUploadFile(dst, f, client, &fi, concurrency, tr)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%d: err: %s", concurrency, err)
+		}
+
+		uploaded, err := ioutil.ReadFile(dst)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !bytes.Equal(uploaded, data) {
+			t.Fatalf("%d: uploaded file differs", concurrency)
+		}
+		if tr.Done() != int64(len(data)) {
+			t.Fatalf("%d: bad transfer: %d", concurrency, tr.Done())
+		}
+		os.Remove(dst)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if v := shellQuote("/tmp/it's here"); v != `'/tmp/it'"'"'s here'` {
+		t.Fatalf("bad: %s", v)
+	}
+}
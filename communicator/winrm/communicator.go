@@ -30,6 +30,7 @@ func New(config *Config) (*Communicator, error) {
 		Port:     config.Port,
 		HTTPS:    config.Https,
 		Insecure: config.Insecure,
+		Timeout:  config.ReceiveTimeout,
 
 		/*
 			TODO
@@ -46,7 +47,7 @@ func New(config *Config) (*Communicator, error) {
 		params.TransportDecorator = config.TransportDecorator
 	}
 
-	params.Timeout = formatDuration(config.Timeout)
+	params.Timeout = formatDuration(config.operationTimeout())
 	client, err := winrm.NewClientWithParameters(
 		endpoint, config.Username, config.Password, &params)
 	if err != nil {
@@ -54,15 +55,15 @@ func New(config *Config) (*Communicator, error) {
 	}
 
 	// Create the shell to verify the connection
-	log.Printf("[DEBUG] connecting to remote shell using WinRM")
+	log.Printf("[DEBUG] (communicator.winrm) connecting to remote shell using WinRM")
 	shell, err := client.CreateShell()
 	if err != nil {
-		log.Printf("[ERROR] connection error: %s", err)
+		log.Printf("[ERROR] (communicator.winrm) connection error: %s", err)
 		return nil, err
 	}
 
 	if err := shell.Close(); err != nil {
-		log.Printf("[ERROR] error closing connection: %s", err)
+		log.Printf("[ERROR] (communicator.winrm) error closing connection: %s", err)
 		return nil, err
 	}
 
@@ -75,12 +76,17 @@ func New(config *Config) (*Communicator, error) {
 
 // Start implementation of communicator.Communicator interface
 func (c *Communicator) Start(rc *packer.RemoteCmd) error {
-	shell, err := c.client.CreateShell()
+	client, err := c.newCommandClient(rc)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("[INFO] starting remote command: %s", rc.Command)
+	shell, err := client.CreateShell()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] (communicator.winrm) starting remote command: %s", rc.Command)
 	cmd, err := shell.Execute(rc.Command)
 	if err != nil {
 		return err
@@ -90,6 +96,68 @@ func (c *Communicator) Start(rc *packer.RemoteCmd) error {
 	return nil
 }
 
+// newCommandClient creates a client for running a command. Its requests
+// wait for output no longer than KeepAlive, unless the command raises the
+// operation timeout, and it reconnects when the connection is lost, which
+// is counted in rc.
+func (c *Communicator) newCommandClient(rc *packer.RemoteCmd) (*winrm.Client, error) {
+	params := *winrm.DefaultParameters
+
+	timeout := c.config.operationTimeout()
+	if c.config.KeepAlive > 0 && c.config.KeepAlive < timeout {
+		timeout = c.config.KeepAlive
+	}
+	if rc.OperationTimeout > timeout {
+		timeout = rc.OperationTimeout
+	}
+	params.Timeout = formatDuration(timeout)
+
+	// Zero means no timeout, which can't be raised
+	endpoint := *c.endpoint
+	if endpoint.Timeout > 0 && rc.ReceiveTimeout > endpoint.Timeout {
+		endpoint.Timeout = rc.ReceiveTimeout
+	}
+	if endpoint.Timeout > 0 && endpoint.Timeout <= timeout {
+		log.Printf("[WARN] (communicator.winrm) receive timeout %s isn't longer than operation timeout %s", endpoint.Timeout, timeout)
+	}
+
+	params.TransportDecorator = func() winrm.Transporter {
+		var transport winrm.Transporter
+		if c.config.TransportDecorator != nil {
+			transport = c.config.TransportDecorator()
+		} else {
+			transport = &basicTransport{
+				url:      fmt.Sprintf("%s://%s:%d/wsman", c.scheme(), c.endpoint.Host, c.endpoint.Port),
+				username: c.config.Username,
+				password: c.config.Password,
+			}
+		}
+
+		if c.config.ReconnectTimeout <= 0 {
+			return transport
+		}
+		return &reconnectTransport{
+			Transporter: transport,
+			timeout:     c.config.ReconnectTimeout,
+			onReconnect: func(cause error) {
+				rc.Reconnected()
+				packer.CommunicatorEvent(c.config.Ui, packer.CommunicatorReconnect, "winrm",
+					fmt.Sprintf("connection lost while receiving output: %s", cause))
+			},
+		}
+	}
+
+	return winrm.NewClientWithParameters(
+		&endpoint, c.config.Username, c.config.Password, &params)
+}
+
+func (c *Communicator) scheme() string {
+	if c.endpoint.HTTPS {
+		return "https"
+	}
+	return "http"
+}
+
 func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packer.RemoteCmd) {
 	defer shell.Close()
 	var wg sync.WaitGroup
@@ -103,32 +171,40 @@ func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packer.RemoteCmd) {
 		wg.Add(1)
 		go copyFunc(rc.Stdout, cmd.Stdout)
 	} else {
-		log.Printf("[WARN] Failed to read stdout for command '%s'", rc.Command)
+		log.Printf("[WARN] (communicator.winrm) Failed to read stdout for command '%s'", rc.Command)
 	}
 
 	if rc.Stderr != nil && cmd.Stderr != nil {
 		wg.Add(1)
 		go copyFunc(rc.Stderr, cmd.Stderr)
 	} else {
-		log.Printf("[WARN] Failed to read stderr for command '%s'", rc.Command)
+		log.Printf("[WARN] (communicator.winrm) Failed to read stderr for command '%s'", rc.Command)
 	}
 
 	cmd.Wait()
 	wg.Wait()
 
 	code := cmd.ExitCode()
-	log.Printf("[INFO] command '%s' exited with code: %d", rc.Command, code)
+	log.Printf("[INFO] (communicator.winrm) command '%s' exited with code: %d", rc.Command, code)
 	rc.SetExited(code)
 }
 
 // Upload implementation of communicator.Communicator interface
-func (c *Communicator) Upload(path string, input io.Reader, _ *os.FileInfo) error {
+func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) error {
 	wcp, err := c.newCopyClient()
 	if err != nil {
 		return err
 	}
-	log.Printf("Uploading file to '%s'", path)
-	return wcp.Write(path, input)
+
+	var size int64
+	if fi != nil && (*fi).Mode().IsRegular() {
+		size = (*fi).Size()
+	}
+	t, finish := c.newTransfer("Uploading "+path, packer.MetricUploadBytes, size)
+	defer finish()
+
+	log.Printf("(communicator.winrm) Uploading file to '%s'", path)
+	return wcp.Write(path, t.Reader(input))
 }
 
 // UploadDir implementation of communicator.Communicator interface
@@ -136,12 +212,56 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 	if !strings.HasSuffix(src, "/") {
 		dst = fmt.Sprintf("%s\\%s", dst, filepath.Base(src))
 	}
-	log.Printf("Uploading dir '%s' to '%s'", src, dst)
+	log.Printf("(communicator.winrm) Uploading dir '%s' to '%s'", src, dst)
 	wcp, err := c.newCopyClient()
 	if err != nil {
 		return err
 	}
-	return wcp.Copy(src, dst)
+
+	t, finish := c.newTransfer("Uploading "+src, packer.MetricUploadBytes, packer.DirSize(src))
+	defer finish()
+
+	// The files are walked here rather than by winrmcp, so that they are
+	// read through the transfer
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() == ".DS_Store" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			rel = ""
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Couldn't read file %s: %v", path, err)
+		}
+		defer f.Close()
+		return wcp.Write(filepath.Join(dst, rel), t.Reader(f))
+	})
+}
+
+// newTransfer returns a transfer limited to the bandwidth limit of the
+// config, reporting its progress to the Ui of the config, and the
+// function to call once it is done, which adds the bytes transferred to
+// the metric.
+func (c *Communicator) newTransfer(description, metric string, total int64) (*packer.Transfer, func()) {
+	t := &packer.Transfer{Limit: c.config.BandwidthLimit, Total: total}
+	if c.config.Ui == nil {
+		return t, func() {}
+	}
+	bar := packer.NewProgressBar(c.config.Ui, description)
+	t.Progress = bar.Update
+	return t, func() {
+		bar.Finish()
+		packer.AddMetric(c.config.Ui, metric, t.Done())
+	}
 }
 
 func (c *Communicator) Download(src string, dst io.Writer) error {
@@ -151,9 +271,12 @@ func (c *Communicator) Download(src string, dst io.Writer) error {
 		return err
 	}
 
+	t, finish := c.newTransfer("Downloading "+src, packer.MetricDownloadBytes, 0)
+	defer finish()
+
 	encodeScript := `$file=[System.IO.File]::ReadAllBytes("%s"); Write-Output $([System.Convert]::ToBase64String($file))`
 
-	base64DecodePipe := &Base64Pipe{w: dst}
+	base64DecodePipe := &Base64Pipe{w: t.Writer(dst)}
 
 	cmd := winrm.Powershell(fmt.Sprintf(encodeScript, src))
 	_, err = client.Run(cmd, base64DecodePipe, ioutil.Discard)
@@ -174,7 +297,7 @@ func (c *Communicator) newCopyClient() (*winrmcp.Winrmcp, error) {
 		},
 		Https:                 c.config.Https,
 		Insecure:              c.config.Insecure,
-		OperationTimeout:      c.config.Timeout,
+		OperationTimeout:      c.config.operationTimeout(),
 		MaxOperationsPerShell: 15, // lowest common denominator
 		TransportDecorator:    c.config.TransportDecorator,
 	})
@@ -9,6 +9,7 @@ import (
 
 	"github.com/dylanmei/winrmtest"
 	"github.com/hashicorp/packer/packer"
+	"github.com/masterzen/winrm"
 )
 
 const PAYLOAD = "stuff"
@@ -115,3 +116,36 @@ func TestUpload(t *testing.T) {
 	}
 
 }
+
+func TestNewCommandClient_timeouts(t *testing.T) {
+	c := &Communicator{
+		config: &Config{
+			Username:  "user",
+			Password:  "pass",
+			Timeout:   30 * time.Minute,
+			KeepAlive: time.Minute,
+		},
+		endpoint: &winrm.Endpoint{Host: "localhost", Port: 5985},
+	}
+
+	cases := []struct {
+		Config    time.Duration
+		Command   time.Duration
+		Operation string
+	}{
+		{0, 0, "PT1M"},
+		{0, 10 * time.Minute, "PT10M"},
+		{30 * time.Second, 0, "PT30S"},
+	}
+
+	for _, tc := range cases {
+		c.config.OperationTimeout = tc.Config
+		client, err := c.newCommandClient(&packer.RemoteCmd{OperationTimeout: tc.Command})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if client.Parameters.Timeout != tc.Operation {
+			t.Fatalf("%s/%s: bad operation timeout: %s", tc.Config, tc.Command, client.Parameters.Timeout)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package winrm
 import (
 	"time"
 
+	"github.com/hashicorp/packer/packer"
 	"github.com/masterzen/winrm"
 )
 
@@ -16,4 +17,38 @@ type Config struct {
 	Https              bool
 	Insecure           bool
 	TransportDecorator func() winrm.Transporter
+
+	// KeepAlive is the longest time a request waiting for the output of
+	// a command stays idle, so that connections aren't dropped by load
+	// balancers during long commands. Zero means Timeout.
+	KeepAlive time.Duration
+
+	// ReconnectTimeout is how long to try to reconnect when the connection
+	// is lost while a command runs. Zero means not reconnecting.
+	ReconnectTimeout time.Duration
+
+	// OperationTimeout is the WS-Management OperationTimeout of requests.
+	// Zero means Timeout.
+	OperationTimeout time.Duration
+
+	// ReceiveTimeout is how long to wait for the response to a request.
+	// Zero means waiting indefinitely.
+	ReceiveTimeout time.Duration
+
+	// BandwidthLimit is the maximum bandwidth of file transfers in bytes
+	// per second, counting the content of files before it is encoded.
+	// Zero means unlimited.
+	BandwidthLimit int64
+
+	// Ui, if set, is where the progress of long file transfers and the
+	// reconnects are reported.
+	Ui packer.Ui
+}
+
+// operationTimeout returns the OperationTimeout of requests.
+func (c *Config) operationTimeout() time.Duration {
+	if c.OperationTimeout > 0 {
+		return c.OperationTimeout
+	}
+	return c.Timeout
 }
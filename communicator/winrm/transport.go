@@ -0,0 +1,139 @@
+package winrm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// reconnectSleep is how long to wait between two attempts to reconnect.
+var reconnectSleep = 5 * time.Second
+
+// basicTransport posts requests with basic authentication, like the
+// default transport of the winrm package, which can't be wrapped because
+// it isn't exported.
+type basicTransport struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func (t *basicTransport) Transport(endpoint *winrm.Endpoint) error {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: endpoint.Insecure,
+			ServerName:         endpoint.TLSServerName,
+		},
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		ResponseHeaderTimeout: endpoint.Timeout,
+	}
+
+	if len(endpoint.CACert) > 0 {
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(endpoint.CACert) {
+			return fmt.Errorf("Unable to read certificates")
+		}
+		transport.TLSClientConfig.RootCAs = certPool
+	}
+
+	t.client = &http.Client{Transport: transport}
+	return nil
+}
+
+func (t *basicTransport) Post(_ *winrm.Client, request *soap.SoapMessage) (string, error) {
+	req, err := http.NewRequest("POST", t.url, strings.NewReader(request.String()))
+	if err != nil {
+		return "", fmt.Errorf("impossible to create http request %s", err)
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(t.username, t.password)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unknown error %s", err)
+	}
+	defer resp.Body.Close()
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/soap+xml") {
+		return "", fmt.Errorf("http response error: %d - invalid content type", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("http response error: %d - error while reading request body %s", resp.StatusCode, err)
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, body)
+	}
+	return string(body), nil
+}
+
+// reconnectTransport resends the requests receiving the output of a
+// command when the connection is lost, e.g. because a load balancer
+// dropped it, until timeout. The command keeps running on the machine in
+// the meantime, but output sent in a lost response is lost too. Other
+// requests aren't resent, since they may have been processed already.
+type reconnectTransport struct {
+	winrm.Transporter
+
+	timeout time.Duration
+
+	// onReconnect, if set, is called with the error that lost the
+	// connection once it is established again.
+	onReconnect func(cause error)
+}
+
+func (t *reconnectTransport) Post(client *winrm.Client, request *soap.SoapMessage) (string, error) {
+	body, err := t.Transporter.Post(client, request)
+	if err == nil || !isReceive(request) || !isConnectionError(err) {
+		return body, err
+	}
+
+	cause := err
+	deadline := time.Now().Add(t.timeout)
+	for time.Now().Before(deadline) {
+		log.Printf("[WARN] (communicator.winrm) connection lost while receiving output, reconnecting: %s", err)
+		time.Sleep(reconnectSleep)
+
+		body, err = t.Transporter.Post(client, request)
+		if err == nil {
+			log.Printf("[INFO] (communicator.winrm) reconnected")
+			if t.onReconnect != nil {
+				t.onReconnect(cause)
+			}
+			return body, nil
+		}
+		if !isConnectionError(err) {
+			return body, err
+		}
+	}
+
+	return body, err
+}
+
+// isReceive returns true if the request receives the output of a command.
+func isReceive(request *soap.SoapMessage) bool {
+	return strings.Contains(request.String(), "/windows/shell/Receive<")
+}
+
+// isConnectionError returns true if the error of a request is caused by
+// the connection rather than by the WinRM service. The transports of the
+// winrm package only tell them apart by their messages.
+func isConnectionError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "unknown error") ||
+		strings.Contains(msg, "error while reading request body")
+}
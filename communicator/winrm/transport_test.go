@@ -0,0 +1,98 @@
+package winrm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// failingTransport fails the first requests with the given errors.
+type failingTransport struct {
+	errs  []error
+	posts int
+}
+
+func (t *failingTransport) Transport(*winrm.Endpoint) error {
+	return nil
+}
+
+func (t *failingTransport) Post(*winrm.Client, *soap.SoapMessage) (string, error) {
+	t.posts++
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		return "", err
+	}
+	return "ok", nil
+}
+
+func TestReconnectTransport(t *testing.T) {
+	old := reconnectSleep
+	reconnectSleep = time.Millisecond
+	defer func() { reconnectSleep = old }()
+
+	params := winrm.DefaultParameters
+	receive := winrm.NewGetOutputRequest("http://localhost/wsman", "shell", "command", "stdout stderr", params)
+	openShell := winrm.NewOpenShellRequest("http://localhost/wsman", params)
+	dropped := errors.New("unknown error Post http://localhost/wsman: EOF")
+	fault := errors.New("http error 500: <s:Fault/>")
+
+	cases := []struct {
+		Name       string
+		Request    *soap.SoapMessage
+		Errs       []error
+		Err        bool
+		Posts      int
+		Reconnects int
+	}{
+		{"success", receive, nil, false, 1, 0},
+		{"dropped receive", receive, []error{dropped, dropped}, false, 3, 1},
+		{"dropped open shell", openShell, []error{dropped}, true, 1, 0},
+		{"fault", receive, []error{fault}, true, 1, 0},
+		{"fault after reconnect", receive, []error{dropped, fault}, true, 2, 0},
+	}
+
+	for _, tc := range cases {
+		inner := &failingTransport{errs: tc.Errs}
+		reconnects := 0
+		transport := &reconnectTransport{
+			Transporter: inner,
+			timeout:     time.Minute,
+			onReconnect: func(error) { reconnects++ },
+		}
+
+		_, err := transport.Post(nil, tc.Request)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Name, err)
+		}
+		if inner.posts != tc.Posts {
+			t.Fatalf("%s: bad posts: %d", tc.Name, inner.posts)
+		}
+		if reconnects != tc.Reconnects {
+			t.Fatalf("%s: bad reconnects: %d", tc.Name, reconnects)
+		}
+	}
+}
+
+func TestReconnectTransport_timeout(t *testing.T) {
+	old := reconnectSleep
+	reconnectSleep = 10 * time.Millisecond
+	defer func() { reconnectSleep = old }()
+
+	inner := &failingTransport{errs: make([]error, 100)}
+	for i := range inner.errs {
+		inner.errs[i] = errors.New("unknown error connection reset by peer")
+	}
+	transport := &reconnectTransport{Transporter: inner, timeout: 50 * time.Millisecond}
+
+	request := winrm.NewGetOutputRequest("http://localhost/wsman", "shell", "command", "stdout stderr", winrm.DefaultParameters)
+	if _, err := transport.Post(nil, request); err == nil {
+		t.Fatal("should have error")
+	}
+	if inner.posts > 10 {
+		t.Fatalf("should give up after the timeout: %d posts", inner.posts)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/packer/command"
@@ -22,12 +23,15 @@ import (
 const PACKERSPACE = "-PACKERSPACE-"
 
 type config struct {
-	DisableCheckpoint          bool `json:"disable_checkpoint"`
-	DisableCheckpointSignature bool `json:"disable_checkpoint_signature"`
+	DisableCheckpoint          bool   `json:"disable_checkpoint"`
+	DisableCheckpointSignature bool   `json:"disable_checkpoint_signature"`
+	MetricsAddress             string `json:"metrics_address"`
+	TempDir                    string `json:"temp_dir"`
 	PluginMinPort              uint
 	PluginMaxPort              uint
 
 	Builders       map[string]string
+	Fixers         map[string]string
 	PostProcessors map[string]string `json:"post-processors"`
 	Provisioners   map[string]string
 }
@@ -41,8 +45,8 @@ func decodeConfig(r io.Reader, c *config) error {
 
 // Discover discovers plugins.
 //
-// Search the directory of the executable, then the plugins directory, and
-// finally the CWD, in that order. Any conflicts will overwrite previously
+// Search the directory of the executable, then the plugins directory, then
+// the directories in PACKER_PLUGIN_PATH, and finally the CWD, in that order. Any conflicts will overwrite previously
 // found plugins, in that order.
 // Hence, the priority order is the reverse of the search order - i.e., the
 // CWD has the highest priority.
@@ -73,6 +77,16 @@ func (c *config) Discover() error {
 		}
 	}
 
+	// Next, look in the directories in PACKER_PLUGIN_PATH.
+	for _, dir := range filepath.SplitList(os.Getenv("PACKER_PLUGIN_PATH")) {
+		if dir == "" {
+			continue
+		}
+		if err := c.discover(dir); err != nil {
+			return err
+		}
+	}
+
 	// Next, look in the CWD.
 	if err := c.discover("."); err != nil {
 		return err
@@ -100,6 +114,29 @@ func (c *config) LoadBuilder(name string) (packer.Builder, error) {
 	return c.pluginClient(bin).Builder()
 }
 
+// This is a proper packer.FixerFunc that can be used to load packer.Fixer
+// implementations from the defined plugins.
+func (c *config) LoadFixer(name string) (packer.Fixer, error) {
+	log.Printf("Loading fixer: %s", name)
+	bin, ok := c.Fixers[name]
+	if !ok {
+		log.Printf("Fixer not found: %s", name)
+		return nil, nil
+	}
+
+	return c.pluginClient(bin).Fixer()
+}
+
+// FixerNames returns the names of the fixers that are plugins, sorted.
+func (c *config) FixerNames() []string {
+	names := make([]string, 0, len(c.Fixers))
+	for name := range c.Fixers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // This is a proper implementation of packer.HookFunc that can be used
 // to load packer.Hook implementations from the defined plugins.
 func (c *config) LoadHook(name string) (packer.Hook, error) {
@@ -149,6 +186,12 @@ func (c *config) discover(path string) error {
 		return err
 	}
 
+	err = c.discoverSingle(
+		filepath.Join(path, "packer-fixer-*"), &c.Fixers)
+	if err != nil {
+		return err
+	}
+
 	err = c.discoverSingle(
 		filepath.Join(path, "packer-post-processor-*"), &c.PostProcessors)
 	if err != nil {
@@ -0,0 +1,86 @@
+package fix
+
+import (
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/mitchellh/mapstructure"
+)
+
+// FixerDeprecations migrates the deprecated configuration keys the
+// builders, provisioners and post-processors registered with
+// config.RegisterDeprecations, the same way they are migrated when the
+// template is decoded.
+type FixerDeprecations struct{}
+
+func (FixerDeprecations) Fix(input map[string]interface{}) (map[string]interface{}, error) {
+	// Our template type we'll use for this fixer only
+	type template struct {
+		Builders       []map[string]interface{}
+		Provisioners   []map[string]interface{}
+		PostProcessors []interface{} `mapstructure:"post-processors"`
+	}
+
+	// Decode the input into our structure, if we can
+	var tpl template
+	if err := mapstructure.Decode(input, &tpl); err != nil {
+		return nil, err
+	}
+
+	for _, builder := range tpl.Builders {
+		migrateDeprecations(config.KindBuilder, builder)
+	}
+
+	for _, provisioner := range tpl.Provisioners {
+		migrateDeprecations(config.KindProvisioner, provisioner)
+
+		// The overrides are merged in the configuration of the
+		// provisioner, they can have the deprecated keys as well
+		override, ok := provisioner["override"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, raw := range override {
+			if m, ok := raw.(map[string]interface{}); ok {
+				m["type"] = provisioner["type"]
+				migrateDeprecations(config.KindProvisioner, m)
+				delete(m, "type")
+			}
+		}
+	}
+
+	for _, rawPP := range tpl.PostProcessors {
+		switch pp := rawPP.(type) {
+		case map[string]interface{}:
+			migrateDeprecations(config.KindPostProcessor, pp)
+		case []interface{}:
+			for _, innerRawPP := range pp {
+				if innerPP, ok := innerRawPP.(map[string]interface{}); ok {
+					migrateDeprecations(config.KindPostProcessor, innerPP)
+				}
+			}
+		}
+	}
+
+	if len(tpl.Builders) > 0 {
+		input["builders"] = tpl.Builders
+	}
+	if len(tpl.Provisioners) > 0 {
+		input["provisioners"] = tpl.Provisioners
+	}
+	if len(tpl.PostProcessors) > 0 {
+		input["post-processors"] = tpl.PostProcessors
+	}
+	return input, nil
+}
+
+func (FixerDeprecations) Synopsis() string {
+	return `Migrates the deprecated configuration keys of the builders, provisioners and post-processors`
+}
+
+func migrateDeprecations(kind string, raw map[string]interface{}) {
+	typ, ok := raw["type"].(string)
+	if !ok {
+		return
+	}
+
+	config.GetDeprecations(kind, typ).Migrate(raw)
+}
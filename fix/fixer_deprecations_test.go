@@ -0,0 +1,94 @@
+package fix
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/config"
+)
+
+func TestFixerDeprecations_Impl(t *testing.T) {
+	var _ Fixer = new(FixerDeprecations)
+}
+
+func TestFixerDeprecations_Fix(t *testing.T) {
+	config.RegisterDeprecations(config.KindBuilder, "fixer-builder",
+		config.Deprecations{{Key: "old_builder_key", NewKey: "builder_key"}})
+	config.RegisterDeprecations(config.KindProvisioner, "fixer-provisioner",
+		config.Deprecations{{Key: "removed_key"}})
+	config.RegisterDeprecations(config.KindPostProcessor, "fixer-pp",
+		config.Deprecations{{Key: "old_pp_key", NewKey: "pp_key"}})
+
+	input := map[string]interface{}{
+		"builders": []interface{}{
+			map[string]interface{}{
+				"type":            "fixer-builder",
+				"old_builder_key": "foo",
+			},
+			map[string]interface{}{
+				"type":            "other",
+				"old_builder_key": "foo",
+			},
+		},
+		"provisioners": []interface{}{
+			map[string]interface{}{
+				"type":        "fixer-provisioner",
+				"removed_key": true,
+				"override": map[string]interface{}{
+					"fixer-builder": map[string]interface{}{
+						"removed_key": false,
+					},
+				},
+			},
+		},
+		"post-processors": []interface{}{
+			"compress",
+			[]interface{}{
+				map[string]interface{}{
+					"type":       "fixer-pp",
+					"old_pp_key": "bar",
+				},
+			},
+		},
+	}
+
+	expected := map[string]interface{}{
+		"builders": []map[string]interface{}{
+			{
+				"type":        "fixer-builder",
+				"builder_key": "foo",
+			},
+			{
+				"type":            "other",
+				"old_builder_key": "foo",
+			},
+		},
+		"provisioners": []map[string]interface{}{
+			{
+				"type": "fixer-provisioner",
+				"override": map[string]interface{}{
+					"fixer-builder": map[string]interface{}{},
+				},
+			},
+		},
+		"post-processors": []interface{}{
+			"compress",
+			[]interface{}{
+				map[string]interface{}{
+					"type":   "fixer-pp",
+					"pp_key": "bar",
+				},
+			},
+		},
+	}
+
+	var f FixerDeprecations
+	output, err := f.Fix(input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("unexpected: %#v\nexpected: %#v\n", output, expected)
+	}
+}
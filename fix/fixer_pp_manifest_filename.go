@@ -60,7 +60,9 @@ func (FixerManifestFilename) Fix(input map[string]interface{}) (map[string]inter
 
 	}
 
-	input["post-processors"] = tpl.PostProcessors
+	if len(tpl.PostProcessors) > 0 {
+		input["post-processors"] = tpl.PostProcessors
+	}
 	return input, nil
 }
 
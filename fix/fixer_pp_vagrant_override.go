@@ -66,7 +66,9 @@ func (FixerVagrantPPOverride) Fix(input map[string]interface{}) (map[string]inte
 		}
 	}
 
-	input["post-processors"] = tpl.PostProcessors
+	if len(tpl.PostProcessors) > 0 {
+		input["post-processors"] = tpl.PostProcessors
+	}
 	return input, nil
 }
 
@@ -0,0 +1,175 @@
+package fix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rewrite encodes a fixed template like the original template it was
+// fixed from: the keys of its objects keep their order, so that the
+// "_comment" keys stay where they were, and it is indented the same way.
+// The keys the fixers added come after the original ones, sorted.
+func Rewrite(original []byte, fixed map[string]interface{}) ([]byte, error) {
+	orders := make(map[string][]string)
+	dec := json.NewDecoder(bytes.NewReader(original))
+	dec.UseNumber()
+	if err := readKeyOrders(dec, "", orders); err != nil {
+		return nil, err
+	}
+
+	var compact bytes.Buffer
+	if err := writeOrdered(&compact, fixed, "", orders); err != nil {
+		return nil, err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", detectIndent(original)); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// readKeyOrders reads the next value of a template, and records the order
+// of the keys of its objects by their path.
+func readKeyOrders(dec *json.Decoder, path string, orders map[string][]string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		orders[path] = []string{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			orders[path] = append(orders[path], key)
+			if err := readKeyOrders(dec, childPath(path, key), orders); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := readKeyOrders(dec, childPath(path, fmt.Sprint(i)), orders); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// The closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// writeOrdered writes the compact JSON of a value, with the keys of its
+// objects in the order they had in the original template.
+func writeOrdered(buf *bytes.Buffer, v interface{}, path string, orders map[string][]string) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		buf.WriteByte('{')
+		for i, key := range orderedKeys(v, orders[path]) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeValue(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeOrdered(buf, v[key], childPath(path, key), orders); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []map[string]interface{}:
+		// The fixers decode the builders and provisioners into these
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeOrdered(buf, elem, childPath(path, fmt.Sprint(i)), orders); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeOrdered(buf, elem, childPath(path, fmt.Sprint(i)), orders); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return writeValue(buf, v)
+	}
+
+	return nil
+}
+
+// writeValue writes the compact JSON of a value without escaping HTML
+// characters, which are common in boot commands.
+func writeValue(buf *bytes.Buffer, v interface{}) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	return nil
+}
+
+// orderedKeys returns the keys of an object in their original order,
+// followed by the new keys, sorted.
+func orderedKeys(m map[string]interface{}, order []string) []string {
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	for _, key := range order {
+		if _, ok := m[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	var added []string
+	for key := range m {
+		if !seen[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	return append(keys, added...)
+}
+
+// childPath returns the path of a key or an index of a value, escaped
+// like a JSON pointer.
+func childPath(path, key string) string {
+	key = strings.Replace(key, "~", "~0", -1)
+	key = strings.Replace(key, "/", "~1", -1)
+	return path + "/" + key
+}
+
+// detectIndent returns the indentation of the first indented line of a
+// template, two spaces if there is none.
+func detectIndent(template []byte) string {
+	for _, line := range strings.Split(string(template), "\n")[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if indent := line[:len(line)-len(trimmed)]; indent != "" && trimmed != "" {
+			return indent
+		}
+	}
+	return "  "
+}
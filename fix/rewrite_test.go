@@ -0,0 +1,82 @@
+package fix
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	original := `{
+    "_comment": "Builds the base image",
+    "variables": {"version": "1.0"},
+    "builders": [
+        {
+            "type": "vmware",
+            "_comment": "The installer needs <enter>",
+            "boot_command": ["<enter>"],
+            "disk_size": 1000000,
+            "iso_md5": "abc"
+        }
+    ]
+}
+`
+
+	var input map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(original)))
+	dec.UseNumber()
+	if err := dec.Decode(&input); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	output := input
+	for _, name := range []string{"iso-md5", "vmware-rename"} {
+		var err error
+		output, err = Fixers[name].Fix(output)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	result, err := Rewrite([]byte(original), output)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `{
+    "_comment": "Builds the base image",
+    "variables": {
+        "version": "1.0"
+    },
+    "builders": [
+        {
+            "type": "vmware-iso",
+            "_comment": "The installer needs <enter>",
+            "boot_command": [
+                "<enter>"
+            ],
+            "disk_size": 1000000,
+            "iso_checksum": "abc",
+            "iso_checksum_type": "md5"
+        }
+    ]
+}
+`
+	if string(result) != expected {
+		t.Fatalf("bad:\n%s", result)
+	}
+}
+
+func TestDetectIndent(t *testing.T) {
+	cases := map[string]string{
+		"{}":                   "  ",
+		"{\n\t\"a\": 1\n}":     "\t",
+		"{\n\n    \"a\": 1\n}": "    ",
+	}
+
+	for input, expected := range cases {
+		if indent := detectIndent([]byte(input)); indent != expected {
+			t.Fatalf("bad indent for %q: %q", input, indent)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package bundle reads and writes bundles: single archives of the
+// artifacts of builds, with their manifest, SBOMs and signatures, that are
+// verified when they are extracted, so that images can be moved between
+// machines that aren't connected to each other.
+//
+// A bundle is a gzipped tar archive. Its first file is the index,
+// bundle.json, which lists the other files with their size and SHA-256
+// checksum. It can be followed by bundle.json.sig, a detached signature of
+// the index, then the files in the order of the index.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// FormatVersion is the version of the format of the bundles written
+	// by this package.
+	FormatVersion = 1
+
+	// IndexName is the path of the index in bundles.
+	IndexName = "bundle.json"
+
+	// SignatureName is the path of the signature of the index in bundles.
+	SignatureName = "bundle.json.sig"
+)
+
+// The kinds of the files of bundles.
+const (
+	KindArtifact  = "artifact"
+	KindManifest  = "manifest"
+	KindSBOM      = "sbom"
+	KindSignature = "signature"
+)
+
+// Index lists the files of a bundle.
+type Index struct {
+	FormatVersion int       `json:"format_version"`
+	Created       time.Time `json:"created"`
+	PackerVersion string    `json:"packer_version,omitempty"`
+	Builds        []string  `json:"builds"`
+	Files         []File    `json:"files"`
+}
+
+// File is a file of a bundle.
+type File struct {
+	// Path is the slash separated path of the file in the bundle.
+	Path string `json:"path"`
+
+	// Kind is the kind of the file, like KindArtifact.
+	Kind string `json:"kind"`
+
+	// Build is the name of the build the file belongs to, if any.
+	Build string `json:"build,omitempty"`
+
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Entry is a file to write to a bundle, from the local file Source or,
+// if Source is empty, from Data.
+type Entry struct {
+	File
+	Source string
+	Data   []byte
+}
+
+// open opens the contents of the entry.
+func (e *Entry) open() (io.ReadCloser, error) {
+	if e.Source == "" {
+		return ioutil.NopCloser(strings.NewReader(string(e.Data))), nil
+	}
+	return os.Open(e.Source)
+}
+
+// A SignFunc returns the detached signature of an index.
+type SignFunc func(index []byte) ([]byte, error)
+
+// A VerifyFunc verifies an index and its signature, which is nil if the
+// bundle isn't signed.
+type VerifyFunc func(index, signature []byte) error
+
+// Write writes a bundle of the entries to w. The size and checksum of the
+// entries are set, and the files of the index are set to them. The index
+// is signed with sign, if not nil.
+func Write(w io.Writer, index *Index, entries []Entry, sign SignFunc) error {
+	seen := make(map[string]bool)
+	index.Files = make([]File, 0, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		if err := checkPath(e.Path); err != nil {
+			return err
+		}
+		if seen[e.Path] || e.Path == IndexName || e.Path == SignatureName {
+			return fmt.Errorf("%s is in the bundle twice", e.Path)
+		}
+		seen[e.Path] = true
+
+		size, sum, err := e.checksum()
+		if err != nil {
+			return err
+		}
+		e.Size = size
+		e.SHA256 = sum
+		index.Files = append(index.Files, e.File)
+	}
+
+	if index.FormatVersion == 0 {
+		index.FormatVersion = FormatVersion
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var signature []byte
+	if sign != nil {
+		if signature, err = sign(data); err != nil {
+			return fmt.Errorf("Error signing the bundle: %s", err)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeData(tw, IndexName, data, index.Created); err != nil {
+		return err
+	}
+	if signature != nil {
+		if err := writeData(tw, SignatureName, signature, index.Created); err != nil {
+			return err
+		}
+	}
+	for i := range entries {
+		if err := writeEntry(tw, &entries[i], index.Created); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// checksum returns the size and SHA-256 checksum of the entry.
+func (e *Entry) checksum() (int64, string, error) {
+	r, err := e.open()
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return 0, "", fmt.Errorf("Error reading %s: %s", e.Path, err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeData(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// writeEntry writes an entry, checking that it didn't change since its
+// checksum was computed.
+func writeEntry(tw *tar.Writer, e *Entry, modTime time.Time) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     e.Path,
+		Mode:     0644,
+		Size:     e.Size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, h), io.LimitReader(r, e.Size)); err != nil {
+		return fmt.Errorf("Error writing %s: %s", e.Path, err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+		return fmt.Errorf("%s changed while it was written to the bundle", e.Path)
+	}
+	return nil
+}
+
+// Extract verifies a bundle and extracts its files to dir. The index and
+// its signature are given to verify, if not nil, before any file is read.
+// The files are extracted to a temporary directory in dir and only moved
+// into place once all of them are verified, so that nothing is extracted
+// from a bundle that fails to verify. Existing files are only replaced if
+// overwrite is true.
+func Extract(r io.Reader, dir string, verify VerifyFunc, overwrite bool) (*Index, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the bundle: %s", err)
+	}
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != IndexName {
+		return nil, fmt.Errorf("The bundle doesn't start with %s", IndexName)
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", IndexName, err)
+	}
+
+	// The signature is optional
+	var signature []byte
+	hdr, readErr := tr.Next()
+	if readErr == nil && hdr.Name == SignatureName {
+		if signature, err = ioutil.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", SignatureName, err)
+		}
+		hdr, readErr = tr.Next()
+	}
+	if readErr != nil && readErr != io.EOF {
+		return nil, fmt.Errorf("Error reading the bundle: %s", readErr)
+	}
+
+	if verify != nil {
+		if err := verify(data, signature); err != nil {
+			return nil, err
+		}
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", IndexName, err)
+	}
+	if index.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("Unsupported bundle format version %d", index.FormatVersion)
+	}
+
+	files := make(map[string]File)
+	for _, f := range index.Files {
+		if err := checkPath(f.Path); err != nil {
+			return nil, err
+		}
+		files[f.Path] = f
+		if !overwrite {
+			if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(f.Path))); err == nil {
+				return nil, fmt.Errorf("%s already exists", filepath.Join(dir, filepath.FromSlash(f.Path)))
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	staging, err := ioutil.TempDir(dir, ".packer-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	extracted := make(map[string]bool)
+	for ; readErr == nil; hdr, readErr = tr.Next() {
+		f, ok := files[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not in the index of the bundle", hdr.Name)
+		}
+		if extracted[hdr.Name] {
+			return nil, fmt.Errorf("%s is in the bundle twice", hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%s is not a regular file", hdr.Name)
+		}
+		if hdr.Size != f.Size {
+			return nil, fmt.Errorf("%s is %d bytes, the index says %d", hdr.Name, hdr.Size, f.Size)
+		}
+		if err := extractFile(tr, staging, f); err != nil {
+			return nil, err
+		}
+		extracted[hdr.Name] = true
+	}
+	if readErr != io.EOF {
+		return nil, fmt.Errorf("Error reading the bundle: %s", readErr)
+	}
+	for _, f := range index.Files {
+		if !extracted[f.Path] {
+			return nil, fmt.Errorf("%s is missing from the bundle", f.Path)
+		}
+	}
+
+	// Everything is verified, move the files into place
+	for _, f := range index.Files {
+		src := filepath.Join(staging, filepath.FromSlash(f.Path))
+		dst := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return nil, err
+		}
+	}
+
+	return &index, nil
+}
+
+// extractFile extracts a file to dir, checking its checksum.
+func extractFile(r io.Reader, dir string, f File) error {
+	dst := filepath.Join(dir, filepath.FromSlash(f.Path))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), r); err != nil {
+		return fmt.Errorf("Error extracting %s: %s", f.Path, err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != f.SHA256 {
+		return fmt.Errorf("Checksum of %s doesn't match: got %s, expected %s", f.Path, sum, f.SHA256)
+	}
+	return out.Close()
+}
+
+// checkPath checks that a path of a bundle is relative and stays in the
+// directory the bundle is extracted to.
+func checkPath(p string) error {
+	if p == "" || strings.Contains(p, `\`) || path.IsAbs(p) || path.Clean(p) != p ||
+		p == ".." || strings.HasPrefix(p, "../") || filepath.VolumeName(p) != "" {
+		return fmt.Errorf("Invalid path in the bundle: %q", p)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntries(t *testing.T, dir string) []Entry {
+	path := filepath.Join(dir, "image.qcow2")
+	if err := ioutil.WriteFile(path, []byte("disk"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return []Entry{
+		{File: File{Path: "artifacts/qemu/image.qcow2", Kind: KindArtifact, Build: "qemu"}, Source: path},
+		{File: File{Path: "packer-manifest.json", Kind: KindManifest}, Data: []byte(`{"builds": []}`)},
+	}
+}
+
+// testBundle writes a tar.gz of the files, in order.
+func testBundle(t *testing.T, files ...[2]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		if err := writeData(tw, f[0], []byte(f[1]), time.Now()); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func testIndex(t *testing.T, files ...File) string {
+	data, err := json.Marshal(&Index{FormatVersion: FormatVersion, Files: files})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return string(data)
+}
+
+func TestWriteExtract(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var buf bytes.Buffer
+	index := &Index{Created: time.Now().UTC(), Builds: []string{"qemu"}}
+	sign := func(index []byte) ([]byte, error) {
+		return []byte("signature"), nil
+	}
+	if err := Write(&buf, index, testEntries(t, td), sign); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(index.Files) != 2 || index.Files[0].Size != 4 ||
+		index.Files[0].SHA256 != "1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9" {
+		t.Fatalf("bad: %#v", index.Files)
+	}
+
+	out := filepath.Join(td, "out")
+	var verified []byte
+	verify := func(index, signature []byte) error {
+		verified = signature
+		return nil
+	}
+	extracted, err := Extract(bytes.NewReader(buf.Bytes()), out, verify, false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(verified) != "signature" {
+		t.Fatalf("bad: %q", verified)
+	}
+	if len(extracted.Files) != 2 || extracted.Builds[0] != "qemu" {
+		t.Fatalf("bad: %#v", extracted)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(out, "artifacts", "qemu", "image.qcow2"))
+	if err != nil || string(data) != "disk" {
+		t.Fatalf("bad: %q %v", data, err)
+	}
+
+	// The staging directory is removed
+	infos, err := ioutil.ReadDir(out)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("bad: %#v", infos)
+	}
+
+	// Files aren't replaced unless asked to
+	if _, err := Extract(bytes.NewReader(buf.Bytes()), out, nil, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := Extract(bytes.NewReader(buf.Bytes()), out, nil, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A failed verification extracts nothing
+	out = filepath.Join(td, "failed")
+	verify = func(index, signature []byte) error {
+		return errors.New("bad signature")
+	}
+	if _, err := Extract(bytes.NewReader(buf.Bytes()), out, verify, false); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestWrite_duplicate(t *testing.T) {
+	entries := []Entry{
+		{File: File{Path: "sbom/sbom.json", Kind: KindSBOM}, Data: []byte("a")},
+		{File: File{Path: "sbom/sbom.json", Kind: KindSBOM}, Data: []byte("b")},
+	}
+	if err := Write(ioutil.Discard, &Index{}, entries, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestExtract_invalid(t *testing.T) {
+	file := File{Path: "sbom.json", Kind: KindSBOM, Size: 2, SHA256: "4e3b1be4d8e2d12cf4a6c8b7e2a4f4cd8e7a4f16e7d1a8f8a58b7e6bca7f3c2e"}
+	good := File{Path: "sbom.json", Kind: KindSBOM, Size: 2, SHA256: "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"}
+
+	cases := map[string]struct {
+		Bundle *bytes.Buffer
+		Err    string
+	}{
+		"no index": {
+			testBundle(t, [2]string{"sbom.json", "{}"}),
+			"doesn't start with bundle.json",
+		},
+		"bad checksum": {
+			testBundle(t, [2]string{IndexName, testIndex(t, file)}, [2]string{"sbom.json", "{}"}),
+			"Checksum of sbom.json doesn't match",
+		},
+		"missing file": {
+			testBundle(t, [2]string{IndexName, testIndex(t, good)}),
+			"sbom.json is missing",
+		},
+		"extra file": {
+			testBundle(t, [2]string{IndexName, testIndex(t, good)}, [2]string{"sbom.json", "{}"}, [2]string{"other", "{}"}),
+			"other is not in the index",
+		},
+		"bad size": {
+			testBundle(t, [2]string{IndexName, testIndex(t, good)}, [2]string{"sbom.json", "{ }"}),
+			"is 3 bytes",
+		},
+		"outside": {
+			testBundle(t, [2]string{IndexName, testIndex(t, File{Path: "../sbom.json", Size: 2})}),
+			"Invalid path",
+		},
+	}
+
+	for name, tc := range cases {
+		td, err := ioutil.TempDir("", "packer")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		_, err = Extract(tc.Bundle, td, nil, false)
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", name, err)
+		}
+		infos, _ := ioutil.ReadDir(td)
+		os.RemoveAll(td)
+		if len(infos) != 0 {
+			t.Fatalf("%s: files were extracted: %#v", name, infos)
+		}
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	for p, valid := range map[string]bool{
+		"artifacts/qemu/image.qcow2": true,
+		"packer-manifest.json":       true,
+		"":                           false,
+		"/etc/passwd":                false,
+		"..":                         false,
+		"../image":                   false,
+		"artifacts/../../image":      false,
+		"artifacts//image":           false,
+		`artifacts\image`:            false,
+	} {
+		if err := checkPath(p); (err == nil) != valid {
+			t.Fatalf("%q: bad: %v", p, err)
+		}
+	}
+}
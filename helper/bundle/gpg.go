@@ -0,0 +1,81 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// GPGSigner returns a SignFunc signing indexes with gpg, with the secret
+// key of the given user ID, or the default key if empty.
+func GPGSigner(key string) SignFunc {
+	return func(index []byte) ([]byte, error) {
+		gpg, err := exec.LookPath("gpg")
+		if err != nil {
+			return nil, fmt.Errorf("Signing bundles requires gpg to be installed: %s", err)
+		}
+
+		args := []string{"--batch", "--detach-sign", "--armor"}
+		if key != "" {
+			args = append(args, "--local-user", key)
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(gpg, args...)
+		cmd.Stdin = bytes.NewReader(index)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s\n%s", err, stderr.String())
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// GPGVerifier returns a VerifyFunc checking the signature of indexes with
+// gpgv and the public keys of a keyring. Unsigned bundles fail to verify.
+func GPGVerifier(keyring string) VerifyFunc {
+	return func(index, signature []byte) error {
+		if signature == nil {
+			return errors.New("The bundle isn't signed")
+		}
+
+		gpgv, err := exec.LookPath("gpgv")
+		if err != nil {
+			return fmt.Errorf("Verifying bundles requires gpgv to be installed: %s", err)
+		}
+
+		// gpgv looks for keyrings without a path in the GnuPG home directory.
+		keyring, err := filepath.Abs(keyring)
+		if err != nil {
+			return err
+		}
+
+		dir, err := ioutil.TempDir("", "packer-bundle")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		indexPath := filepath.Join(dir, IndexName)
+		sigPath := filepath.Join(dir, SignatureName)
+		if err := ioutil.WriteFile(indexPath, index, 0644); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(sigPath, signature, 0644); err != nil {
+			return err
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command(gpgv, "--keyring", keyring, sigPath, indexPath)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("Bad signature of the bundle: %s\n%s", err, stderr.String())
+		}
+		return nil
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"time"
 
+	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/masterzen/winrm"
 )
@@ -33,6 +35,11 @@ type Config struct {
 	SSHBastionPassword        string        `mapstructure:"ssh_bastion_password"`
 	SSHBastionPrivateKey      string        `mapstructure:"ssh_bastion_private_key_file"`
 	SSHFileTransferMethod     string        `mapstructure:"ssh_file_transfer_method"`
+	SSHSftpConcurrency        int           `mapstructure:"ssh_sftp_concurrency"`
+	SSHCompression            bool          `mapstructure:"ssh_compression"`
+	SSHBandwidthLimit         string        `mapstructure:"ssh_bandwidth_limit"`
+
+	SSHKeyboardInteractiveAnswers []SSHKeyboardInteractiveAnswer `mapstructure:"ssh_keyboard_interactive_answers"`
 
 	// WinRM
 	WinRMUser               string        `mapstructure:"winrm_username"`
@@ -43,9 +50,21 @@ type Config struct {
 	WinRMUseSSL             bool          `mapstructure:"winrm_use_ssl"`
 	WinRMInsecure           bool          `mapstructure:"winrm_insecure"`
 	WinRMUseNTLM            bool          `mapstructure:"winrm_use_ntlm"`
+	WinRMKeepAlive          time.Duration `mapstructure:"winrm_keep_alive"`
+	WinRMReconnectTimeout   time.Duration `mapstructure:"winrm_reconnect_timeout"`
+	WinRMOperationTimeout   time.Duration `mapstructure:"winrm_operation_timeout"`
+	WinRMReceiveTimeout     time.Duration `mapstructure:"winrm_receive_timeout"`
+	WinRMBandwidthLimit     string        `mapstructure:"winrm_bandwidth_limit"`
 	WinRMTransportDecorator func() winrm.Transporter
 }
 
+// SSHKeyboardInteractiveAnswer is the answer to the keyboard-interactive
+// authentication prompts of SSH that match the Prompt regular expression.
+type SSHKeyboardInteractiveAnswer struct {
+	Prompt string
+	Answer string
+}
+
 // Port returns the port that will be used for access based on config.
 func (c *Config) Port() int {
 	switch c.Type {
@@ -94,6 +113,23 @@ func (c *Config) Password() string {
 	}
 }
 
+// BandwidthLimit returns the maximum bandwidth of file transfers in bytes
+// per second, zero if unlimited.
+func (c *Config) BandwidthLimit() int64 {
+	var limit string
+	switch c.Type {
+	case "ssh":
+		limit = c.SSHBandwidthLimit
+	case "winrm":
+		limit = c.WinRMBandwidthLimit
+	}
+	if limit == "" {
+		return 0
+	}
+	size, _ := packer.ParseSize(limit)
+	return size
+}
+
 func (c *Config) Prepare(ctx *interpolate.Context) []error {
 	if c.Type == "" {
 		c.Type = "ssh"
@@ -145,6 +181,10 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		c.SSHFileTransferMethod = "scp"
 	}
 
+	if c.SSHSftpConcurrency == 0 {
+		c.SSHSftpConcurrency = 4
+	}
+
 	// Validation
 	var errs []error
 	if c.SSHUsername == "" {
@@ -174,6 +214,31 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 			c.SSHFileTransferMethod))
 	}
 
+	for i, a := range c.SSHKeyboardInteractiveAnswers {
+		if a.Prompt == "" {
+			errs = append(errs, fmt.Errorf(
+				"ssh_keyboard_interactive_answers %d: prompt must be specified", i+1))
+		} else if _, err := regexp.Compile(a.Prompt); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"ssh_keyboard_interactive_answers %d: invalid prompt: %s", i+1, err))
+		}
+	}
+
+	if c.SSHSftpConcurrency < 0 {
+		errs = append(errs, errors.New("ssh_sftp_concurrency can't be negative"))
+	}
+
+	if c.SSHCompression && c.SSHFileTransferMethod != "scp" {
+		errs = append(errs, errors.New(
+			"ssh_compression is only supported with the scp ssh_file_transfer_method"))
+	}
+
+	if c.SSHBandwidthLimit != "" {
+		if _, err := packer.ParseSize(c.SSHBandwidthLimit); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_bandwidth_limit is invalid: %s", err))
+		}
+	}
+
 	return errs
 }
 
@@ -188,6 +253,14 @@ func (c *Config) prepareWinRM(ctx *interpolate.Context) []error {
 		c.WinRMTimeout = 30 * time.Minute
 	}
 
+	if c.WinRMKeepAlive == 0 {
+		c.WinRMKeepAlive = time.Minute
+	}
+
+	if c.WinRMReconnectTimeout == 0 {
+		c.WinRMReconnectTimeout = 5 * time.Minute
+	}
+
 	if c.WinRMUseNTLM == true {
 		c.WinRMTransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
 	}
@@ -197,5 +270,31 @@ func (c *Config) prepareWinRM(ctx *interpolate.Context) []error {
 		errs = append(errs, errors.New("winrm_username must be specified."))
 	}
 
+	if c.WinRMKeepAlive < time.Second {
+		errs = append(errs, errors.New("winrm_keep_alive must be at least 1s."))
+	}
+
+	if c.WinRMReconnectTimeout < 0 {
+		errs = append(errs, errors.New("winrm_reconnect_timeout must not be negative."))
+	}
+
+	operationTimeout := c.WinRMOperationTimeout
+	if operationTimeout == 0 {
+		operationTimeout = c.WinRMTimeout
+	}
+	if operationTimeout < 0 {
+		errs = append(errs, errors.New("winrm_operation_timeout must not be negative."))
+	}
+	if c.WinRMReceiveTimeout != 0 && c.WinRMReceiveTimeout <= operationTimeout {
+		errs = append(errs, fmt.Errorf(
+			"winrm_receive_timeout must be longer than the operation timeout, %s.", operationTimeout))
+	}
+
+	if c.WinRMBandwidthLimit != "" {
+		if _, err := packer.ParseSize(c.WinRMBandwidthLimit); err != nil {
+			errs = append(errs, fmt.Errorf("winrm_bandwidth_limit is invalid: %s", err))
+		}
+	}
+
 	return errs
 }
@@ -3,6 +3,7 @@ package communicator
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/template/interpolate"
 	"github.com/masterzen/winrm"
@@ -136,6 +137,143 @@ func TestConfig_winrm(t *testing.T) {
 	}
 }
 
+func TestConfig_winrm_keep_alive(t *testing.T) {
+	c := &Config{
+		Type:      "winrm",
+		WinRMUser: "admin",
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.WinRMKeepAlive != time.Minute {
+		t.Fatalf("bad keep alive: %s", c.WinRMKeepAlive)
+	}
+	if c.WinRMReconnectTimeout != 5*time.Minute {
+		t.Fatalf("bad reconnect timeout: %s", c.WinRMReconnectTimeout)
+	}
+
+	c = &Config{
+		Type:           "winrm",
+		WinRMUser:      "admin",
+		WinRMKeepAlive: time.Millisecond,
+	}
+	if err := c.Prepare(testContext(t)); len(err) == 0 {
+		t.Fatal("should have error")
+	}
+}
+
+func TestConfig_winrm_receive_timeout(t *testing.T) {
+	cases := []struct {
+		Operation time.Duration
+		Receive   time.Duration
+		Err       bool
+	}{
+		{0, 0, false},
+		{0, time.Hour, false},
+		{0, 30 * time.Minute, true},
+		{5 * time.Minute, 6 * time.Minute, false},
+		{5 * time.Minute, time.Minute, true},
+	}
+
+	for _, tc := range cases {
+		c := &Config{
+			Type:                  "winrm",
+			WinRMUser:             "admin",
+			WinRMOperationTimeout: tc.Operation,
+			WinRMReceiveTimeout:   tc.Receive,
+		}
+		if err := c.Prepare(testContext(t)); (len(err) > 0) != tc.Err {
+			t.Fatalf("%s/%s: bad: %#v", tc.Operation, tc.Receive, err)
+		}
+	}
+}
+
+func TestConfig_bandwidthLimit(t *testing.T) {
+	cases := []struct {
+		Type  string
+		Limit string
+		Bytes int64
+		Err   bool
+	}{
+		{"ssh", "", 0, false},
+		{"ssh", "10MB", 10 * 1024 * 1024, false},
+		{"ssh", "fast", 0, true},
+		{"winrm", "512k", 512 * 1024, false},
+		{"winrm", "-1MB", 0, true},
+	}
+
+	for _, tc := range cases {
+		c := &Config{
+			Type:                tc.Type,
+			SSHUsername:         "root",
+			SSHBandwidthLimit:   tc.Limit,
+			WinRMUser:           "admin",
+			WinRMBandwidthLimit: tc.Limit,
+		}
+		if err := c.Prepare(testContext(t)); (len(err) > 0) != tc.Err {
+			t.Fatalf("%s %q: bad: %#v", tc.Type, tc.Limit, err)
+		}
+		if !tc.Err && c.BandwidthLimit() != tc.Bytes {
+			t.Fatalf("%s %q: bad limit: %d", tc.Type, tc.Limit, c.BandwidthLimit())
+		}
+	}
+}
+
+func TestConfig_sshFileTransfer(t *testing.T) {
+	cases := []struct {
+		Method      string
+		Concurrency int
+		Compression bool
+		Err         bool
+	}{
+		{"", 0, false, false},
+		{"scp", 0, true, false},
+		{"sftp", 8, false, false},
+		{"sftp", 0, true, true},
+		{"sftp", -1, false, true},
+		{"rsync", 0, false, true},
+	}
+
+	for _, tc := range cases {
+		c := &Config{
+			SSHUsername:           "root",
+			SSHFileTransferMethod: tc.Method,
+			SSHSftpConcurrency:    tc.Concurrency,
+			SSHCompression:        tc.Compression,
+		}
+		if err := c.Prepare(testContext(t)); (len(err) > 0) != tc.Err {
+			t.Fatalf("%#v: bad: %#v", tc, err)
+		}
+	}
+
+	c := testConfig()
+	c.Prepare(testContext(t))
+	if c.SSHFileTransferMethod != "scp" || c.SSHSftpConcurrency != 4 {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestConfig_sshKeyboardInteractiveAnswers(t *testing.T) {
+	cases := []struct {
+		Prompt string
+		Err    bool
+	}{
+		{"(?i)verification code", false},
+		{"", true},
+		{"(", true},
+	}
+
+	for _, tc := range cases {
+		c := testConfig()
+		c.SSHKeyboardInteractiveAnswers = []SSHKeyboardInteractiveAnswer{
+			{Prompt: tc.Prompt, Answer: "123456"},
+		}
+		if err := c.Prepare(testContext(t)); (len(err) > 0) != tc.Err {
+			t.Fatalf("%q: bad: %#v", tc.Prompt, err)
+		}
+	}
+}
+
 func testContext(t *testing.T) *interpolate.Context {
 	return nil
 }
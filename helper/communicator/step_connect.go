@@ -84,7 +84,34 @@ func (s *StepConnect) Run(state multistep.StateBag) multistep.StepAction {
 	}
 
 	s.substep = step
-	return s.substep.Run(state)
+	action := s.substep.Run(state)
+	if action == multistep.ActionContinue {
+		s.putGuestFacts(state)
+	}
+	return action
+}
+
+// putGuestFacts completes the guest facts published by the builder with the
+// ones implied by the communicator, for the provisioners.
+func (s *StepConnect) putGuestFacts(state multistep.StateBag) {
+	facts := new(packer.GuestFacts)
+	if raw, ok := state.GetOk("guest_facts"); ok {
+		facts.Merge(raw.(*packer.GuestFacts))
+	}
+
+	if s.Config.Type == "winrm" {
+		facts.Merge(&packer.GuestFacts{OSFamily: "windows", Shell: "powershell"})
+	}
+
+	switch facts.OSFamily {
+	case "windows":
+		facts.Merge(&packer.GuestFacts{TempDir: `c:/Windows/Temp`})
+	case "linux", "darwin", "freebsd":
+		facts.Merge(&packer.GuestFacts{TempDir: "/tmp", Shell: "sh"})
+	}
+
+	log.Printf("[INFO] Guest facts: %#v", facts)
+	state.Put("guest_facts", facts)
 }
 
 func (s *StepConnect) Cleanup(state multistep.StateBag) {
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -42,6 +43,9 @@ func (s *StepConnectSSH) Run(state multistep.StateBag) multistep.StepAction {
 		waitDone <- true
 	}()
 
+	spinner := packer.StartSpinner(ui, "Waiting for SSH to become available...")
+	defer spinner.Stop()
+
 	log.Printf("[INFO] Waiting for SSH, up to timeout: %s", s.Config.SSHTimeout)
 	timeout := time.After(s.Config.SSHTimeout)
 WaitLoop:
@@ -50,6 +54,7 @@ WaitLoop:
 		// or an interrupt to come through.
 		select {
 		case <-waitDone:
+			spinner.Stop()
 			if err != nil {
 				ui.Error(fmt.Sprintf("Error waiting for SSH: %s", err))
 				state.Put("error", err)
@@ -61,6 +66,8 @@ WaitLoop:
 			break WaitLoop
 		case <-timeout:
 			err := fmt.Errorf("Timeout waiting for SSH.")
+			packer.CommunicatorEvent(ui, packer.CommunicatorTimeout, "ssh",
+				fmt.Sprintf("not connected after %s", s.Config.SSHTimeout))
 			state.Put("error", err)
 			ui.Error(err.Error())
 			close(cancel)
@@ -121,6 +128,8 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 		host, err := s.Host(state)
 		if err != nil {
 			log.Printf("[DEBUG] Error getting SSH address: %s", err)
+			packer.CommunicatorEvent(state.Get("ui").(packer.Ui), packer.CommunicatorRetry, "ssh",
+				fmt.Sprintf("no address yet: %s", err))
 			continue
 		}
 		port := s.Config.SSHPort
@@ -138,6 +147,7 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 			log.Printf("[DEBUG] Error getting SSH config: %s", err)
 			continue
 		}
+		sshConfig = s.keyboardInteractive(state, sshConfig)
 
 		// Attempt to connect to SSH port
 		var connFunc func() (net.Conn, error)
@@ -154,6 +164,8 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 		nc, err := connFunc()
 		if err != nil {
 			log.Printf("[DEBUG] TCP connection to SSH ip/port failed: %s", err)
+			packer.CommunicatorEvent(state.Get("ui").(packer.Ui), packer.CommunicatorRetry, "ssh",
+				fmt.Sprintf("TCP connection to %s failed: %s", address, err))
 			continue
 		}
 		nc.Close()
@@ -165,6 +177,10 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 			Pty:        s.Config.SSHPty,
 			DisableAgentForwarding: s.Config.SSHDisableAgentForwarding,
 			UseSftp:                s.Config.SSHFileTransferMethod == "sftp",
+			SftpConcurrency:        s.Config.SSHSftpConcurrency,
+			Compression:            s.Config.SSHCompression,
+			BandwidthLimit:         s.Config.BandwidthLimit(),
+			Ui:                     state.Get("ui").(packer.Ui),
 		}
 
 		log.Println("[INFO] Attempting SSH connection...")
@@ -185,6 +201,9 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 				// Try to connect via SSH a handful of times. We sleep here
 				// so we don't get a ton of authentication errors back to back.
 				time.Sleep(2 * time.Second)
+				packer.CommunicatorEvent(state.Get("ui").(packer.Ui), packer.CommunicatorRetry, "ssh",
+					fmt.Sprintf("handshake with %s failed (%d of %d authentication attempts): %s",
+						address, handshakeAttempts, s.Config.SSHHandshakeAttempts, err))
 				continue
 			}
 
@@ -197,6 +216,63 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, cancel <-chan stru
 	return comm, nil
 }
 
+// passwordPromptRe matches the keyboard-interactive prompts that ask for
+// the password.
+var passwordPromptRe = regexp.MustCompile(`(?i)password`)
+
+// keyboardInteractive replaces the keyboard-interactive authentication
+// of the builder, which answers every prompt with the password, with one
+// that gives the configured answers to the prompts they match. Other
+// prompts are asked in debug mode, except for the password, and get the
+// password otherwise.
+func (s *StepConnectSSH) keyboardInteractive(state multistep.StateBag, config *gossh.ClientConfig) *gossh.ClientConfig {
+	debug := debugMode(state)
+	if len(s.Config.SSHKeyboardInteractiveAnswers) == 0 && !debug {
+		return config
+	}
+
+	answers := make([]ssh.KeyboardInteractiveAnswer, 0, len(s.Config.SSHKeyboardInteractiveAnswers))
+	for _, a := range s.Config.SSHKeyboardInteractiveAnswers {
+		prompt, err := regexp.Compile(a.Prompt)
+		if err != nil {
+			continue
+		}
+		answers = append(answers, ssh.KeyboardInteractiveAnswer{Prompt: prompt, Answer: a.Answer})
+	}
+
+	// Without a password or debug mode, prompts without an answer fail
+	var fallback func(string, bool) (string, error)
+	if s.Config.SSHPassword != "" || debug {
+		ui := state.Get("ui").(packer.Ui)
+		fallback = func(question string, echo bool) (string, error) {
+			if s.Config.SSHPassword != "" && (!debug || passwordPromptRe.MatchString(question)) {
+				return s.Config.SSHPassword, nil
+			}
+			return ui.Ask(fmt.Sprintf("SSH keyboard-interactive authentication: %s", question))
+		}
+	}
+
+	result := *config
+	result.Auth = make([]gossh.AuthMethod, 0, len(config.Auth)+1)
+	for _, auth := range config.Auth {
+		if _, ok := auth.(gossh.KeyboardInteractiveChallenge); !ok {
+			result.Auth = append(result.Auth, auth)
+		}
+	}
+	result.Auth = append(result.Auth, gossh.KeyboardInteractive(ssh.KeyboardInteractive(answers, fallback)))
+	return &result
+}
+
+// debugMode reports whether the build runs with -debug, which builders
+// record in the state.
+func debugMode(state multistep.StateBag) bool {
+	if debug, ok := state.Get("debug").(bool); ok && debug {
+		return true
+	}
+	_, ok := state.GetOk("pauseFn")
+	return ok
+}
+
 func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 	auth := make([]gossh.AuthMethod, 0, 2)
 	if config.SSHBastionPassword != "" {
@@ -0,0 +1,45 @@
+package communicator
+
+import (
+	"reflect"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestStepConnectSSH_keyboardInteractive(t *testing.T) {
+	builderConfig := &gossh.ClientConfig{
+		Auth: []gossh.AuthMethod{
+			gossh.Password("secret"),
+			gossh.KeyboardInteractive(func(string, string, []string, []bool) ([]string, error) {
+				return nil, nil
+			}),
+		},
+	}
+
+	// Nothing changes without answers, outside of debug mode
+	step := &StepConnectSSH{Config: &Config{SSHPassword: "secret"}}
+	if config := step.keyboardInteractive(testState(t), builderConfig); config != builderConfig {
+		t.Fatal("should keep the builder configuration")
+	}
+
+	step.Config.SSHKeyboardInteractiveAnswers = []SSHKeyboardInteractiveAnswer{
+		{Prompt: "(?i)verification code", Answer: "123456"},
+	}
+	config := step.keyboardInteractive(testState(t), builderConfig)
+	if len(config.Auth) != 2 || len(builderConfig.Auth) != 2 {
+		t.Fatalf("should replace the keyboard-interactive method: %#v", config.Auth)
+	}
+	challenge, ok := config.Auth[1].(gossh.KeyboardInteractiveChallenge)
+	if !ok {
+		t.Fatalf("bad: %#v", config.Auth[1])
+	}
+
+	answers, err := challenge("root", "", []string{"Password: ", "Verification code: "}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(answers, []string{"secret", "123456"}) {
+		t.Fatalf("bad: %#v", answers)
+	}
+}
@@ -2,6 +2,7 @@ package communicator
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -28,6 +29,46 @@ func TestStepConnect_none(t *testing.T) {
 	}
 }
 
+func TestStepConnect_putGuestFacts(t *testing.T) {
+	cases := []struct {
+		Type     string
+		Builder  *packer.GuestFacts
+		Expected *packer.GuestFacts
+	}{
+		{"ssh", nil, &packer.GuestFacts{}},
+		{
+			"ssh",
+			&packer.GuestFacts{OSFamily: "linux", Arch: "amd64"},
+			&packer.GuestFacts{OSFamily: "linux", Arch: "amd64", TempDir: "/tmp", Shell: "sh"},
+		},
+		{
+			"winrm",
+			nil,
+			&packer.GuestFacts{OSFamily: "windows", TempDir: "c:/Windows/Temp", Shell: "powershell"},
+		},
+		{
+			"winrm",
+			&packer.GuestFacts{TempDir: `d:\temp`},
+			&packer.GuestFacts{OSFamily: "windows", TempDir: `d:\temp`, Shell: "powershell"},
+		},
+	}
+
+	for _, tc := range cases {
+		state := testState(t)
+		if tc.Builder != nil {
+			state.Put("guest_facts", tc.Builder)
+		}
+
+		step := &StepConnect{Config: &Config{Type: tc.Type}}
+		step.putGuestFacts(state)
+
+		facts := state.Get("guest_facts").(*packer.GuestFacts)
+		if !reflect.DeepEqual(facts, tc.Expected) {
+			t.Fatalf("%s: bad: %#v", tc.Type, facts)
+		}
+	}
+}
+
 func testState(t *testing.T) multistep.StateBag {
 	state := new(multistep.BasicStateBag)
 	state.Put("hook", &packer.MockHook{})
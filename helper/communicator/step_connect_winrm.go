@@ -46,6 +46,9 @@ func (s *StepConnectWinRM) Run(state multistep.StateBag) multistep.StepAction {
 		waitDone <- true
 	}()
 
+	spinner := packer.StartSpinner(ui, "Waiting for WinRM to become available...")
+	defer spinner.Stop()
+
 	log.Printf("Waiting for WinRM, up to timeout: %s", s.Config.WinRMTimeout)
 	timeout := time.After(s.Config.WinRMTimeout)
 WaitLoop:
@@ -54,6 +57,7 @@ WaitLoop:
 		// or an interrupt to come through.
 		select {
 		case <-waitDone:
+			spinner.Stop()
 			if err != nil {
 				ui.Error(fmt.Sprintf("Error waiting for WinRM: %s", err))
 				return multistep.ActionHalt
@@ -64,6 +68,8 @@ WaitLoop:
 			break WaitLoop
 		case <-timeout:
 			err := fmt.Errorf("Timeout waiting for WinRM.")
+			packer.CommunicatorEvent(ui, packer.CommunicatorTimeout, "winrm",
+				fmt.Sprintf("not connected after %s", s.Config.WinRMTimeout))
 			state.Put("error", err)
 			ui.Error(err.Error())
 			close(cancel)
@@ -98,6 +104,8 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, cancel <-chan
 		host, err := s.Host(state)
 		if err != nil {
 			log.Printf("[DEBUG] Error getting WinRM host: %s", err)
+			packer.CommunicatorEvent(state.Get("ui").(packer.Ui), packer.CommunicatorRetry, "winrm",
+				fmt.Sprintf("no address yet: %s", err))
 			continue
 		}
 
@@ -137,9 +145,17 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, cancel <-chan
 			Https:              s.Config.WinRMUseSSL,
 			Insecure:           s.Config.WinRMInsecure,
 			TransportDecorator: s.Config.WinRMTransportDecorator,
+			KeepAlive:          s.Config.WinRMKeepAlive,
+			ReconnectTimeout:   s.Config.WinRMReconnectTimeout,
+			OperationTimeout:   s.Config.WinRMOperationTimeout,
+			ReceiveTimeout:     s.Config.WinRMReceiveTimeout,
+			BandwidthLimit:     s.Config.BandwidthLimit(),
+			Ui:                 state.Get("ui").(packer.Ui),
 		})
 		if err != nil {
 			log.Printf("[ERROR] WinRM connection err: %s", err)
+			packer.CommunicatorEvent(state.Get("ui").(packer.Ui), packer.CommunicatorRetry, "winrm",
+				fmt.Sprintf("connection to %s:%d failed: %s", host, port, err))
 			continue
 		}
 
@@ -167,6 +183,8 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, cancel <-chan
 
 		if err != nil {
 			log.Printf("Communication connection err: %s", err)
+			packer.CommunicatorEvent(ui, packer.CommunicatorRetry, "winrm",
+				fmt.Sprintf("connection check failed: %s", err))
 			continue
 		}
 
@@ -174,6 +192,8 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, cancel <-chan
 		stdoutToRead := buf2.String()
 		if !strings.Contains(stdoutToRead, "WinRM connected.") {
 			log.Printf("echo didn't succeed; retrying...")
+			packer.CommunicatorEvent(ui, packer.CommunicatorRetry, "winrm",
+				"connection check didn't echo")
 			continue
 		}
 		break
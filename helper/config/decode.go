@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/packer/template/interpolate"
@@ -23,6 +25,10 @@ type DecodeOpts struct {
 	Interpolate        bool
 	InterpolateContext *interpolate.Context
 	InterpolateFilter  *interpolate.RenderFilter
+
+	// Deprecations are the deprecated keys of the configuration. They are
+	// migrated before the configuration is interpolated and decoded.
+	Deprecations Deprecations
 }
 
 // Decode decodes the configuration into the target and optionally
@@ -32,7 +38,26 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		config = &DecodeOpts{Interpolate: true}
 	}
 
-	// Interpolate first
+	// Migrate the deprecated keys first, the core warns about them
+	if len(config.Deprecations) > 0 {
+		for i, raw := range raws {
+			m, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			migrated := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				migrated[k] = v
+			}
+			for _, warning := range config.Deprecations.Migrate(migrated) {
+				log.Printf("[WARN] %s", warning)
+			}
+			raws[i] = migrated
+		}
+	}
+
+	// Interpolate
 	if config.Interpolate {
 		// Detect user variables from the raws and merge them into our context
 		ctx, err := DetectContext(raws...)
@@ -44,8 +69,15 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		} else {
 			config.InterpolateContext.BuildName = ctx.BuildName
 			config.InterpolateContext.BuildType = ctx.BuildType
+			config.InterpolateContext.ArtifactName = ctx.ArtifactName
 			config.InterpolateContext.TemplatePath = ctx.TemplatePath
 			config.InterpolateContext.UserVariables = ctx.UserVariables
+			config.InterpolateContext.LatestArtifacts = ctx.LatestArtifacts
+			config.InterpolateContext.BuildArtifacts = ctx.BuildArtifacts
+			config.InterpolateContext.BuildTime = ctx.BuildTime
+			if config.InterpolateContext.Secrets == nil {
+				config.InterpolateContext.Secrets = ctx.Secrets
+			}
 		}
 		ctx = config.InterpolateContext
 
@@ -108,10 +140,14 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 // detecting things like user variables from the raw configuration params.
 func DetectContext(raws ...interface{}) (*interpolate.Context, error) {
 	var s struct {
-		BuildName    string            `mapstructure:"packer_build_name"`
-		BuildType    string            `mapstructure:"packer_builder_type"`
-		TemplatePath string            `mapstructure:"packer_template_path"`
-		Vars         map[string]string `mapstructure:"packer_user_variables"`
+		BuildName    string                       `mapstructure:"packer_build_name"`
+		BuildType    string                       `mapstructure:"packer_builder_type"`
+		ArtifactName string                       `mapstructure:"packer_artifact_name"`
+		TemplatePath string                       `mapstructure:"packer_template_path"`
+		Vars         map[string]string            `mapstructure:"packer_user_variables"`
+		Latest       map[string]map[string]string `mapstructure:"packer_latest_artifacts"`
+		Artifacts    map[string]string            `mapstructure:"packer_build_artifacts"`
+		BuildTime    string                       `mapstructure:"packer_build_time"`
 	}
 
 	for _, r := range raws {
@@ -120,12 +156,30 @@ func DetectContext(raws ...interface{}) (*interpolate.Context, error) {
 		}
 	}
 
-	return &interpolate.Context{
-		BuildName:     s.BuildName,
-		BuildType:     s.BuildType,
-		TemplatePath:  s.TemplatePath,
-		UserVariables: s.Vars,
-	}, nil
+	ctx := &interpolate.Context{
+		BuildName:      s.BuildName,
+		BuildType:      s.BuildType,
+		ArtifactName:   s.ArtifactName,
+		TemplatePath:   s.TemplatePath,
+		UserVariables:  s.Vars,
+		BuildArtifacts: s.Artifacts,
+		Secrets:        interpolate.NewSecretCache(),
+	}
+
+	if s.BuildTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, s.BuildTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid packer_build_time: %s", err)
+		}
+
+		ctx.BuildTime = t
+	}
+
+	if s.Latest != nil {
+		ctx.LatestArtifacts = interpolate.ResolvedArtifacts(s.Latest)
+	}
+
+	return ctx, nil
 }
 
 func uint8ToStringHook(f reflect.Kind, t reflect.Kind, v interface{}) (interface{}, error) {
@@ -104,6 +104,39 @@ func TestDecode(t *testing.T) {
 			},
 			nil,
 		},
+
+		"build time": {
+			[]interface{}{
+				map[string]interface{}{
+					"name": "{{timestamp}}-{{format_time `%Y%m%d`}}",
+				},
+				map[string]interface{}{
+					"packer_build_time": "2018-06-12T09:41:07.5Z",
+				},
+			},
+			&Target{
+				Name: "1528796467-20180612",
+			},
+			nil,
+		},
+
+		"deprecations": {
+			[]interface{}{
+				map[string]interface{}{
+					"hostname": "bar",
+					"port":     "22",
+				},
+			},
+			&Target{
+				Name: "bar",
+			},
+			&DecodeOpts{
+				Deprecations: Deprecations{
+					{Key: "hostname", NewKey: "name"},
+					{Key: "port"},
+				},
+			},
+		},
 	}
 
 	for k, tc := range cases {
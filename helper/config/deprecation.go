@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The kinds of components deprecations are registered for.
+const (
+	KindBuilder       = "builder"
+	KindProvisioner   = "provisioner"
+	KindPostProcessor = "post-processor"
+)
+
+// A Deprecation describes a configuration key of a component that was
+// renamed or removed.
+type Deprecation struct {
+	// Key is the deprecated key.
+	Key string
+
+	// NewKey is the key replacing Key, if it was renamed. If empty, the
+	// key was removed and is ignored.
+	NewKey string
+
+	// Reason is an optional explanation added to the warning, such as
+	// what to use instead of a removed key.
+	Reason string
+}
+
+// Warning returns the warning shown to users of the deprecated key.
+func (d *Deprecation) Warning() string {
+	var warning string
+	if d.NewKey != "" {
+		warning = fmt.Sprintf("%q is deprecated, use %q instead", d.Key, d.NewKey)
+	} else {
+		warning = fmt.Sprintf("%q is deprecated and ignored", d.Key)
+	}
+	if d.Reason != "" {
+		warning += ": " + d.Reason
+	}
+	return warning
+}
+
+// Deprecations are the deprecated configuration keys of a component.
+type Deprecations []Deprecation
+
+// Migrate migrates the deprecated keys of a raw configuration in place,
+// and returns the warnings for the keys it migrated. A renamed key is
+// dropped if the new key is set as well, the new key wins.
+func (ds Deprecations) Migrate(raw map[string]interface{}) []string {
+	var warnings []string
+	for _, d := range ds {
+		v, ok := raw[d.Key]
+		if !ok {
+			continue
+		}
+
+		warnings = append(warnings, d.Warning())
+		delete(raw, d.Key)
+		if d.NewKey == "" {
+			continue
+		}
+		if _, ok := raw[d.NewKey]; !ok {
+			raw[d.NewKey] = v
+		}
+	}
+
+	return warnings
+}
+
+// Warnings returns the warnings for the deprecated keys of a raw
+// configuration, without migrating them.
+func (ds Deprecations) Warnings(raw map[string]interface{}) []string {
+	var warnings []string
+	for _, d := range ds {
+		if _, ok := raw[d.Key]; ok {
+			warnings = append(warnings, d.Warning())
+		}
+	}
+	return warnings
+}
+
+var (
+	deprecations     = make(map[string]map[string]Deprecations)
+	deprecationsLock sync.RWMutex
+)
+
+// RegisterDeprecations registers the deprecated keys of a component by
+// kind and type, such as KindProvisioner and "powershell", so that Packer
+// warns about them and "packer fix" migrates them. Components register
+// them in init and pass the same ones to Decode.
+func RegisterDeprecations(kind, typ string, ds Deprecations) {
+	deprecationsLock.Lock()
+	defer deprecationsLock.Unlock()
+
+	if deprecations[kind] == nil {
+		deprecations[kind] = make(map[string]Deprecations)
+	}
+	deprecations[kind][typ] = append(deprecations[kind][typ], ds...)
+}
+
+// GetDeprecations returns the registered deprecated keys of a component.
+func GetDeprecations(kind, typ string) Deprecations {
+	deprecationsLock.RLock()
+	defer deprecationsLock.RUnlock()
+
+	return deprecations[kind][typ]
+}
+
+// DeprecatedTypes returns the types of the components of a kind that have
+// registered deprecated keys, sorted.
+func DeprecatedTypes(kind string) []string {
+	deprecationsLock.RLock()
+	defer deprecationsLock.RUnlock()
+
+	types := make([]string, 0, len(deprecations[kind]))
+	for typ := range deprecations[kind] {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeprecationsMigrate(t *testing.T) {
+	ds := Deprecations{
+		{Key: "ssh_key_path", NewKey: "ssh_private_key_file"},
+		{Key: "tools_host_path", Reason: "the tools are always uploaded"},
+	}
+
+	cases := []struct {
+		Input    map[string]interface{}
+		Expected map[string]interface{}
+		Warnings []string
+	}{
+		{
+			map[string]interface{}{"ssh_username": "root"},
+			map[string]interface{}{"ssh_username": "root"},
+			nil,
+		},
+		{
+			map[string]interface{}{"ssh_key_path": "id_rsa"},
+			map[string]interface{}{"ssh_private_key_file": "id_rsa"},
+			[]string{`"ssh_key_path" is deprecated, use "ssh_private_key_file" instead`},
+		},
+		{
+			map[string]interface{}{
+				"ssh_key_path":         "old",
+				"ssh_private_key_file": "new",
+			},
+			map[string]interface{}{"ssh_private_key_file": "new"},
+			[]string{`"ssh_key_path" is deprecated, use "ssh_private_key_file" instead`},
+		},
+		{
+			map[string]interface{}{"tools_host_path": "/tools"},
+			map[string]interface{}{},
+			[]string{`"tools_host_path" is deprecated and ignored: the tools are always uploaded`},
+		},
+	}
+
+	for _, tc := range cases {
+		warnings := ds.Warnings(tc.Input)
+		if !reflect.DeepEqual(warnings, tc.Warnings) {
+			t.Fatalf("bad warnings: %#v", warnings)
+		}
+
+		warnings = ds.Migrate(tc.Input)
+		if !reflect.DeepEqual(warnings, tc.Warnings) {
+			t.Fatalf("bad warnings: %#v", warnings)
+		}
+		if !reflect.DeepEqual(tc.Input, tc.Expected) {
+			t.Fatalf("bad: %#v", tc.Input)
+		}
+	}
+}
+
+func TestRegisterDeprecations(t *testing.T) {
+	defer func() { delete(deprecations, "test") }()
+
+	RegisterDeprecations("test", "foo", Deprecations{{Key: "a", NewKey: "b"}})
+	RegisterDeprecations("test", "bar", Deprecations{{Key: "c"}})
+
+	if ds := GetDeprecations("test", "foo"); len(ds) != 1 || ds[0].Key != "a" {
+		t.Fatalf("bad: %#v", ds)
+	}
+	if ds := GetDeprecations("test", "baz"); ds != nil {
+		t.Fatalf("bad: %#v", ds)
+	}
+	if types := DeprecatedTypes("test"); !reflect.DeepEqual(types, []string{"bar", "foo"}) {
+		t.Fatalf("bad: %#v", types)
+	}
+}
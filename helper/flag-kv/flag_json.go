@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 )
 
 // FlagJSON is a flag.Value implementation for parsing user variables
@@ -15,20 +16,54 @@ func (v *FlagJSON) String() string {
 }
 
 func (v *FlagJSON) Set(raw string) error {
-	f, err := os.Open(raw)
+	vars, err := readVarFile(raw)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
 	if *v == nil {
 		*v = make(map[string]string)
 	}
 
-	if err := json.NewDecoder(f).Decode(v); err != nil {
-		return fmt.Errorf(
-			"Error reading variables in '%s': %s", raw, err)
+	for k, value := range vars {
+		(*v)[k] = stringValue(value)
 	}
 
 	return nil
 }
+
+// readVarFile reads the variables of a JSON file.
+func readVarFile(path string) (map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vars map[string]interface{}
+	if err := json.NewDecoder(f).Decode(&vars); err != nil {
+		return nil, fmt.Errorf(
+			"Error reading variables in '%s': %s", path, err)
+	}
+
+	return vars, nil
+}
+
+// stringValue returns a JSON value as a user variable. Values can have any
+// type, but are set as strings like -var ones: lists and maps as JSON.
+func stringValue(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}, map[string]interface{}:
+		// Values decoded from JSON can always be encoded back
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return ""
+	}
+}
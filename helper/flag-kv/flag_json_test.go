@@ -38,6 +38,25 @@ func TestFlagJSON(t *testing.T) {
 			map[string]string{"key": "value"},
 			false,
 		},
+
+		{
+			"typed.json",
+			nil,
+			map[string]string{
+				"count":   "3",
+				"enabled": "true",
+				"zones":   `["a","b"]`,
+				"tags":    `{"env":"prod"}`,
+			},
+			false,
+		},
+
+		{
+			"null.json",
+			nil,
+			map[string]string{"key": ""},
+			false,
+		},
 	}
 
 	for _, tc := range cases {
@@ -0,0 +1,64 @@
+package kvflag
+
+import "os"
+
+// Layer is a var file merged with others by MergeLayers.
+type Layer struct {
+	Path string
+
+	// If true, the layer is skipped if the file doesn't exist.
+	Optional bool
+}
+
+// MergeLayers reads var files and merges them in order: the values of a
+// layer override those of the previous layers, except objects, which are
+// merged deeply. It returns the variables and the layer each was last set
+// by.
+func MergeLayers(layers []Layer) (map[string]string, map[string]string, error) {
+	merged := make(map[string]interface{})
+	sources := make(map[string]string)
+	for _, l := range layers {
+		vars, err := readVarFile(l.Path)
+		if err != nil {
+			if l.Optional && os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+
+		for k, v := range vars {
+			merged[k] = deepMerge(merged[k], v)
+			sources[k] = l.Path
+		}
+	}
+
+	result := make(map[string]string, len(merged))
+	for k, v := range merged {
+		result[k] = stringValue(v)
+	}
+
+	return result, sources, nil
+}
+
+// deepMerge merges the value of a variable in a layer over its value in
+// the previous layers.
+func deepMerge(base, over interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return over
+	}
+	overMap, ok := over.(map[string]interface{})
+	if !ok {
+		return over
+	}
+
+	result := make(map[string]interface{}, len(baseMap)+len(overMap))
+	for k, v := range baseMap {
+		result[k] = v
+	}
+	for k, v := range overMap {
+		result[k] = deepMerge(result[k], v)
+	}
+
+	return result
+}
@@ -0,0 +1,48 @@
+package kvflag
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeLayers(t *testing.T) {
+	dir := filepath.Join("./test-fixtures", "layers")
+	layers := []Layer{
+		{Path: filepath.Join(dir, "defaults.json")},
+		{Path: filepath.Join(dir, "prod.json")},
+		{Path: filepath.Join(dir, "missing.json"), Optional: true},
+		{Path: filepath.Join(dir, "local.json"), Optional: true},
+	}
+
+	vars, sources, err := MergeLayers(layers)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"region":        "eu-west-1",
+		"instance_type": "m5.large",
+		"zones":         `["c"]`,
+		"tags":          `{"cost":{"center":"prod","owner":"ops"},"env":"prod","team":"platform"}`,
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("bad: %#v", vars)
+	}
+
+	expectedSources := map[string]string{
+		"region":        filepath.Join(dir, "local.json"),
+		"instance_type": filepath.Join(dir, "prod.json"),
+		"zones":         filepath.Join(dir, "prod.json"),
+		"tags":          filepath.Join(dir, "prod.json"),
+	}
+	if !reflect.DeepEqual(sources, expectedSources) {
+		t.Fatalf("bad: %#v", sources)
+	}
+
+	// Layers that aren't optional must exist
+	layers[2].Optional = false
+	if _, _, err := MergeLayers(layers); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,88 @@
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// fileLocker keeps every lock as a file named after the lock within
+// Directory, holding the name of its owner. Creating the file is
+// atomic, also on most network file systems, so the directory can be
+// shared between machines.
+type fileLocker struct {
+	Directory string
+}
+
+func (l *fileLocker) prepare() error {
+	if l.Directory == "" {
+		return fmt.Errorf("'directory' must be specified")
+	}
+
+	return nil
+}
+
+func (l *fileLocker) path(name string) string {
+	return filepath.Join(l.Directory, escapeName(name)+".lock")
+}
+
+func (l *fileLocker) TryLock(name, owner string) (string, error) {
+	if err := os.MkdirAll(l.Directory, 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(l.path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if !os.IsExist(err) {
+			return "", err
+		}
+
+		holder, err := l.holder(name)
+		if err != nil {
+			return "", err
+		}
+		if holder == "" {
+			// The lock was released in the meantime, or its owner is
+			// still writing its name
+			holder = "another build"
+		}
+		return holder, nil
+	}
+
+	if _, err := f.WriteString(owner); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return "", nil
+}
+
+func (l *fileLocker) Unlock(name, owner string) error {
+	holder, err := l.holder(name)
+	if err != nil {
+		return err
+	}
+	if holder != owner {
+		return fmt.Errorf("build lock '%s' is not held by %s", name, owner)
+	}
+
+	return os.Remove(l.path(name))
+}
+
+func (l *fileLocker) holder(name string) (string, error) {
+	data, err := ioutil.ReadFile(l.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return string(data), nil
+}
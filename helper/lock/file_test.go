@@ -0,0 +1,48 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLocker(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	l, err := New(map[string]interface{}{
+		"type":      "file",
+		"directory": filepath.Join(td, "locks"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	holder, err := l.TryLock("vsphere/ubuntu", "build a")
+	if err != nil || holder != "" {
+		t.Fatalf("should take the lock: %q %s", holder, err)
+	}
+	if _, err := os.Stat(filepath.Join(td, "locks", "vsphere%2Fubuntu.lock")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	holder, err = l.TryLock("vsphere/ubuntu", "build b")
+	if err != nil || holder != "build a" {
+		t.Fatalf("should be held: %q %s", holder, err)
+	}
+	if err := l.Unlock("vsphere/ubuntu", "build b"); err == nil {
+		t.Fatal("should only be released by its owner")
+	}
+
+	if err := l.Unlock("vsphere/ubuntu", "build a"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	holder, err = l.TryLock("vsphere/ubuntu", "build b")
+	if err != nil || holder != "" {
+		t.Fatalf("should take the lock: %q %s", holder, err)
+	}
+}
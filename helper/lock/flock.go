@@ -0,0 +1,7 @@
+package lock
+
+import "errors"
+
+// ErrFileLocked is returned by Flock when the file is locked by another
+// process and it isn't asked to wait.
+var ErrFileLocked = errors.New("locked by another process")
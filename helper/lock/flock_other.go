@@ -0,0 +1,11 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package lock
+
+import "os"
+
+// Flock doesn't lock anything on platforms without flock, where the files
+// it guards are only safe for a single Packer process.
+func Flock(f *os.File, exclusive, wait bool) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package lock
+
+import (
+	"os"
+	"syscall"
+)
+
+// Flock locks the file with flock(2), which is released when the file is
+// closed or the process exits.
+func Flock(f *os.File, exclusive, wait bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		switch err {
+		case syscall.EINTR:
+			continue
+		case syscall.EWOULDBLOCK:
+			return ErrFileLocked
+		}
+		return err
+	}
+}
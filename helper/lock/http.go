@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPTimeout is how long we wait for the HTTP lock service to respond.
+var HTTPTimeout = 30 * time.Second
+
+// httpLocker talks to a service that takes the lock on a PUT to
+// <url>/<name> and releases it on a DELETE of the same URL. Both send
+// the owner as JSON. The service answers a PUT of a lock held by another
+// owner with 409 Conflict or 423 Locked, and the holder as JSON.
+type httpLocker struct {
+	URL     string `mapstructure:"url"`
+	Headers map[string]string
+
+	client *http.Client
+}
+
+// httpLock is the body of the requests and of the conflict responses.
+type httpLock struct {
+	Owner string `json:"owner"`
+}
+
+func (l *httpLocker) prepare() error {
+	if l.URL == "" {
+		return fmt.Errorf("'url' must be specified")
+	}
+
+	l.client = &http.Client{Timeout: HTTPTimeout}
+	return nil
+}
+
+func (l *httpLocker) endpoint(name string) string {
+	return strings.TrimRight(l.URL, "/") + "/" + escapeName(name)
+}
+
+func (l *httpLocker) TryLock(name, owner string) (string, error) {
+	resp, err := l.do("PUT", name, owner)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return "", nil
+	case resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusLocked:
+		var held httpLock
+		if err := json.NewDecoder(resp.Body).Decode(&held); err != nil || held.Owner == "" {
+			return "another build", nil
+		}
+		return held.Owner, nil
+	}
+
+	return "", fmt.Errorf("unexpected response status from %s: %s",
+		resp.Request.URL, resp.Status)
+}
+
+func (l *httpLocker) Unlock(name, owner string) error {
+	resp, err := l.do("DELETE", name, owner)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status from %s: %s",
+			resp.Request.URL, resp.Status)
+	}
+
+	return nil
+}
+
+func (l *httpLocker) do(method, name, owner string) (*http.Response, error) {
+	body, err := json.Marshal(&httpLock{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, l.endpoint(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range l.Headers {
+		req.Header.Set(k, v)
+	}
+
+	return l.client.Do(req)
+}
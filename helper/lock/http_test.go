@@ -0,0 +1,78 @@
+package lock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHTTPLocker(t *testing.T) {
+	var l sync.Mutex
+	locks := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var req httpLock
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		l.Lock()
+		defer l.Unlock()
+		owner, held := locks[r.URL.Path]
+		switch r.Method {
+		case "PUT":
+			if held && owner != req.Owner {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(&httpLock{Owner: owner})
+				return
+			}
+			locks[r.URL.Path] = req.Owner
+		case "DELETE":
+			if owner != req.Owner {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			delete(locks, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	locker, err := New(map[string]interface{}{
+		"type":    "http",
+		"url":     ts.URL + "/locks/",
+		"headers": map[string]string{"X-Token": "secret"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	holder, err := locker.TryLock("hyperv switch", "build a")
+	if err != nil || holder != "" {
+		t.Fatalf("should take the lock: %q %s", holder, err)
+	}
+	if _, ok := locks["/locks/hyperv switch"]; !ok {
+		t.Fatalf("bad: %#v", locks)
+	}
+
+	holder, err = locker.TryLock("hyperv switch", "build b")
+	if err != nil || holder != "build a" {
+		t.Fatalf("should be held: %q %s", holder, err)
+	}
+	if err := locker.Unlock("hyperv switch", "build b"); err == nil {
+		t.Fatal("should only be released by its owner")
+	}
+
+	if err := locker.Unlock("hyperv switch", "build a"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(locks) != 0 {
+		t.Fatalf("bad: %#v", locks)
+	}
+}
@@ -0,0 +1,153 @@
+// Package lock serializes builds that share resources, like the name of a
+// template or a virtual switch, across Packer processes and machines.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// TypeFile keeps locks as files in a directory, which can be shared
+	// between machines.
+	TypeFile = "file"
+
+	// TypeHTTP takes locks by talking to an HTTP service.
+	TypeHTTP = "http"
+)
+
+// Types are all of the lock types that New knows about.
+var Types = []string{TypeFile, TypeHTTP}
+
+// PollInterval is how often a held lock is tried again.
+var PollInterval = 10 * time.Second
+
+// ErrCancelled is returned by Acquire when waiting for the lock is
+// cancelled.
+var ErrCancelled = errors.New("waiting for the lock was cancelled")
+
+// A Locker takes and releases named locks on behalf of owners.
+type Locker interface {
+	// TryLock takes the lock with the name for the owner without waiting.
+	// It returns the owner holding the lock if it couldn't be taken, or
+	// an empty string if it was.
+	TryLock(name, owner string) (string, error)
+
+	// Unlock releases the lock with the name if the owner holds it.
+	Unlock(name, owner string) error
+}
+
+// New creates the locker configured by raw. The "type" key selects the
+// backend, all other keys are backend specific.
+func New(raw map[string]interface{}) (Locker, error) {
+	var c struct {
+		Type string
+	}
+	if err := mapstructure.WeakDecode(raw, &c); err != nil {
+		return nil, err
+	}
+
+	var l Locker
+	switch c.Type {
+	case TypeFile:
+		l = new(fileLocker)
+	case TypeHTTP:
+		l = new(httpLocker)
+	case "":
+		return nil, fmt.Errorf("build lock 'type' must be specified")
+	default:
+		return nil, fmt.Errorf("unknown build lock type: %s", c.Type)
+	}
+
+	if err := decode(raw, l); err != nil {
+		return nil, fmt.Errorf("build lock %s: %s", c.Type, err)
+	}
+	if p, ok := l.(interface {
+		prepare() error
+	}); ok {
+		if err := p.prepare(); err != nil {
+			return nil, fmt.Errorf("build lock %s: %s", c.Type, err)
+		}
+	}
+
+	return l, nil
+}
+
+// Acquire takes the lock with the name for the owner, trying again every
+// PollInterval while another owner holds it. waiting, if set, is called
+// with the holder the first time the lock is found held. It gives up
+// after timeout, unless it is zero, or once cancel is closed.
+func Acquire(l Locker, name, owner string, timeout time.Duration, cancel <-chan struct{}, waiting func(holder string)) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	last := ""
+	for {
+		holder, err := l.TryLock(name, owner)
+		if err != nil {
+			return err
+		}
+		if holder == "" {
+			log.Printf("[INFO] Took build lock '%s' as %s", name, owner)
+			return nil
+		}
+
+		if holder != last {
+			log.Printf("[INFO] Build lock '%s' is held by %s", name, holder)
+			if last == "" && waiting != nil {
+				waiting(holder)
+			}
+			last = holder
+		}
+
+		select {
+		case <-cancel:
+			return ErrCancelled
+		case <-deadline:
+			return fmt.Errorf("timeout waiting for build lock '%s', held by %s", name, holder)
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// decode decodes the backend configuration, rejecting unknown keys.
+func decode(raw map[string]interface{}, target interface{}) error {
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           target,
+		Metadata:         &md,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return err
+	}
+
+	var errs error
+	sort.Strings(md.Unused)
+	for _, unused := range md.Unused {
+		if unused != "type" && unused != "timeout" {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"unknown configuration key: %q", unused))
+		}
+	}
+
+	return errs
+}
+
+// escapeName makes a lock name safe to use as a single path segment.
+func escapeName(name string) string {
+	return strings.Replace(url.QueryEscape(name), "+", "%20", -1)
+}
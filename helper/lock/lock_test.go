@@ -0,0 +1,81 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		Raw map[string]interface{}
+		Err bool
+	}{
+		{map[string]interface{}{"type": "file", "directory": "locks"}, false},
+		{map[string]interface{}{"type": "file", "directory": "locks", "timeout": "1h"}, false},
+		{map[string]interface{}{"type": "file"}, true},
+		{map[string]interface{}{"type": "file", "directory": "locks", "bad": "key"}, true},
+		{map[string]interface{}{"type": "http", "url": "http://localhost/locks"}, false},
+		{map[string]interface{}{"type": "http"}, true},
+		{map[string]interface{}{"type": "nope"}, true},
+		{map[string]interface{}{}, true},
+	}
+
+	for _, tc := range cases {
+		_, err := New(tc.Raw)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: bad: %s", tc.Raw, err)
+		}
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	defer func(d time.Duration) { PollInterval = d }(PollInterval)
+	PollInterval = 10 * time.Millisecond
+
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	l, err := New(map[string]interface{}{"type": "file", "directory": td})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := Acquire(l, "switch", "a", 0, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Another owner times out
+	var holder string
+	err = Acquire(l, "switch", "b", 50*time.Millisecond, nil, func(h string) { holder = h })
+	if err == nil {
+		t.Fatal("should time out")
+	}
+	if holder != "a" {
+		t.Fatalf("bad holder: %s", holder)
+	}
+
+	// or is cancelled
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := Acquire(l, "switch", "b", 0, cancel, nil); err != ErrCancelled {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// and gets the lock once it is released
+	done := make(chan error)
+	go func() {
+		done <- Acquire(l, "switch", "b", time.Minute, nil, nil)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	if err := l.Unlock("switch", "a"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
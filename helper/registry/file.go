@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/packer/helper/lock"
+)
+
+// fileRegistry keeps the history of every build name in a JSON file
+// named after the build within Directory. Records of the same name are
+// serialized with a file lock next to the history, so that Packer
+// processes sharing the directory don't lose each other's entries.
+type fileRegistry struct {
+	Directory string
+
+	l sync.Mutex
+}
+
+func (r *fileRegistry) prepare() error {
+	if r.Directory == "" {
+		return fmt.Errorf("'directory' must be specified")
+	}
+
+	return nil
+}
+
+func (r *fileRegistry) path(name string) string {
+	return filepath.Join(r.Directory, escapeName(name)+".json")
+}
+
+func (r *fileRegistry) Record(e *Entry) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if err := os.MkdirAll(r.Directory, 0755); err != nil {
+		return err
+	}
+
+	path := r.path(e.Name)
+	lf, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer lf.Close()
+	if err := lock.Flock(lf, true, true); err != nil {
+		return fmt.Errorf("error locking %s: %s", path, err)
+	}
+
+	entries, err := r.read(e.Name)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, e)
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that readers never see a
+	// partially written history.
+	tmp, err := ioutil.TempFile(r.Directory, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+func (r *fileRegistry) Latest(name string) (*Entry, error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	entries, err := r.read(name)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	return entries[len(entries)-1], nil
+}
+
+func (r *fileRegistry) read(name string) ([]*Entry, error) {
+	data, err := ioutil.ReadFile(r.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", r.path(name), err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileRegistry(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	r, err := New(map[string]interface{}{
+		"type":      "file",
+		"directory": filepath.Join(td, "registry"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	e, err := r.Latest("base/windows")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e != nil {
+		t.Fatalf("should have no entry: %#v", e)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		err := r.Record(&Entry{
+			Name:      "base/windows",
+			Artifacts: []Artifact{{BuilderId: "foo", Id: id}},
+			Time:      time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	e, err = r.Latest("base/windows")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e == nil || e.Artifacts[0].Id != "2" {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	entries, err := r.(*fileRegistry).read("base/windows")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("bad: %#v", entries)
+	}
+}
+
+func TestFileRegistry_concurrent(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Every registry stands for a Packer process sharing the directory
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		r, err := New(map[string]interface{}{
+			"type":      "file",
+			"directory": td,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			errs <- r.Record(&Entry{
+				Name:      "base/windows",
+				Artifacts: []Artifact{{BuilderId: "foo", Id: id}},
+				Time:      time.Now(),
+			})
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	entries, err := (&fileRegistry{Directory: td}).read("base/windows")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 10 {
+		t.Fatalf("should keep every entry: %d", len(entries))
+	}
+
+	files, err := ioutil.ReadDir(td)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" && filepath.Ext(f.Name()) != ".lock" {
+			t.Fatalf("should leave no temporary file: %s", f.Name())
+		}
+	}
+}
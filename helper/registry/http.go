@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPTimeout is how long we wait for the HTTP registry to respond.
+var HTTPTimeout = 30 * time.Second
+
+// httpRegistry talks to a service that accepts entries POSTed to
+// <url>/<name> and returns the latest one on a GET of the same URL.
+type httpRegistry struct {
+	URL     string `mapstructure:"url"`
+	Headers map[string]string
+
+	client *http.Client
+}
+
+func (r *httpRegistry) prepare() error {
+	if r.URL == "" {
+		return fmt.Errorf("'url' must be specified")
+	}
+
+	r.client = &http.Client{Timeout: HTTPTimeout}
+	return nil
+}
+
+func (r *httpRegistry) endpoint(name string) string {
+	return strings.TrimRight(r.URL, "/") + "/" + escapeName(name)
+}
+
+func (r *httpRegistry) Record(e *Entry) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", r.endpoint(e.Name), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("unexpected response status from %s: %s",
+			req.URL, resp.Status)
+	}
+
+	return nil
+}
+
+func (r *httpRegistry) Latest(name string) (*Entry, error) {
+	req, err := http.NewRequest("GET", r.endpoint(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	var e Entry
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return nil, fmt.Errorf("error decoding entry for '%s': %s", name, err)
+	}
+
+	return &e, nil
+}
+
+// do sends the request, treating any status but 2xx and 404 as an error.
+func (r *httpRegistry) do(req *http.Request) (*http.Response, error) {
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusNotFound &&
+		(resp.StatusCode < 200 || resp.StatusCode > 299) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status from %s: %s",
+			req.URL, resp.Status)
+	}
+
+	return resp, nil
+}
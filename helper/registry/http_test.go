@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHTTPRegistry(t *testing.T) {
+	var l sync.Mutex
+	entries := make(map[string][]byte)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		l.Lock()
+		defer l.Unlock()
+		switch r.Method {
+		case "POST":
+			var e Entry
+			if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			entries[r.URL.Path], _ = json.Marshal(e)
+			w.WriteHeader(http.StatusCreated)
+		case "GET":
+			data, ok := entries[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer ts.Close()
+
+	r, err := New(map[string]interface{}{
+		"type":    "http",
+		"url":     ts.URL + "/builds/",
+		"headers": map[string]string{"X-Token": "secret"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	e, err := r.Latest("base windows")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e != nil {
+		t.Fatalf("should have no entry: %#v", e)
+	}
+
+	err = r.Record(&Entry{
+		Name:      "base windows",
+		Artifacts: []Artifact{{BuilderId: "foo", Id: "bar"}},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := entries["/builds/base windows"]; !ok {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	e, err = r.Latest("base windows")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e == nil || e.Artifacts[0].Id != "bar" {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	// Errors from the service are returned
+	r.(*httpRegistry).Headers = nil
+	if _, err := r.Latest("base windows"); err == nil {
+		t.Fatal("should error")
+	}
+}
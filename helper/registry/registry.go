@@ -0,0 +1,142 @@
+// Package registry records the artifacts of successful builds so that
+// later builds, possibly from other templates, can reference them.
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// TypeFile stores entries as JSON files in a local directory.
+	TypeFile = "file"
+
+	// TypeS3 stores entries as JSON objects in an S3 bucket.
+	TypeS3 = "s3"
+
+	// TypeHTTP stores entries by talking to an HTTP service.
+	TypeHTTP = "http"
+)
+
+// Types are all of the registry types that New knows about.
+var Types = []string{TypeFile, TypeHTTP, TypeS3}
+
+// Artifact is a single artifact recorded for a build.
+type Artifact struct {
+	BuilderId string `json:"builder_id"`
+	Id        string `json:"id"`
+	String    string `json:"string,omitempty"`
+}
+
+// Entry is the record of a single successful build.
+type Entry struct {
+	Name         string            `json:"name"`
+	BuilderType  string            `json:"builder_type"`
+	Artifacts    []Artifact        `json:"artifacts"`
+	TemplateHash string            `json:"template_hash,omitempty"`
+	Time         time.Time         `json:"time"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// ArtifactId returns the ID of the artifact created by the given builder
+// ID, or of the first artifact if builderId is empty.
+func (e *Entry) ArtifactId(builderId string) (string, error) {
+	for _, a := range e.Artifacts {
+		if builderId == "" || a.BuilderId == builderId {
+			return a.Id, nil
+		}
+	}
+
+	if builderId == "" {
+		return "", fmt.Errorf("build '%s' recorded no artifacts", e.Name)
+	}
+
+	return "", fmt.Errorf(
+		"build '%s' recorded no artifact from builder '%s'", e.Name, builderId)
+}
+
+// A Registry stores the entries of successful builds.
+type Registry interface {
+	// Record stores the entry as the latest one for its name.
+	Record(*Entry) error
+
+	// Latest returns the most recently recorded entry for the name, or
+	// nil if there is none.
+	Latest(name string) (*Entry, error)
+}
+
+// New creates the registry configured by raw. The "type" key selects
+// the backend, all other keys are backend specific.
+func New(raw map[string]interface{}) (Registry, error) {
+	var c struct {
+		Type string
+	}
+	if err := mapstructure.WeakDecode(raw, &c); err != nil {
+		return nil, err
+	}
+
+	var r Registry
+	switch c.Type {
+	case TypeFile:
+		r = new(fileRegistry)
+	case TypeHTTP:
+		r = new(httpRegistry)
+	case TypeS3:
+		r = new(s3Registry)
+	case "":
+		return nil, fmt.Errorf("artifact registry 'type' must be specified")
+	default:
+		return nil, fmt.Errorf("unknown artifact registry type: %s", c.Type)
+	}
+
+	if err := decode(raw, r); err != nil {
+		return nil, fmt.Errorf("artifact registry %s: %s", c.Type, err)
+	}
+	if p, ok := r.(interface {
+		prepare() error
+	}); ok {
+		if err := p.prepare(); err != nil {
+			return nil, fmt.Errorf("artifact registry %s: %s", c.Type, err)
+		}
+	}
+
+	return r, nil
+}
+
+// decode decodes the backend configuration, rejecting unknown keys.
+func decode(raw map[string]interface{}, target interface{}) error {
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           target,
+		Metadata:         &md,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(raw); err != nil {
+		return err
+	}
+
+	var errs error
+	sort.Strings(md.Unused)
+	for _, unused := range md.Unused {
+		if unused != "type" && unused != "metadata" {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"unknown configuration key: %q", unused))
+		}
+	}
+
+	return errs
+}
+
+// escapeName makes a build name safe to use as a single path segment.
+func escapeName(name string) string {
+	return strings.Replace(url.QueryEscape(name), "+", "%20", -1)
+}
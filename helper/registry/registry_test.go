@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		Raw map[string]interface{}
+		Err bool
+	}{
+		{
+			map[string]interface{}{},
+			true,
+		},
+		{
+			map[string]interface{}{"type": "bad"},
+			true,
+		},
+		{
+			map[string]interface{}{"type": "file"},
+			true,
+		},
+		{
+			map[string]interface{}{"type": "file", "directory": "foo"},
+			false,
+		},
+		{
+			map[string]interface{}{
+				"type":      "file",
+				"directory": "foo",
+				"metadata":  map[string]interface{}{"foo": "bar"},
+			},
+			false,
+		},
+		{
+			map[string]interface{}{"type": "file", "directory": "foo", "bar": "baz"},
+			true,
+		},
+		{
+			map[string]interface{}{"type": "http"},
+			true,
+		},
+		{
+			map[string]interface{}{"type": "http", "url": "http://localhost"},
+			false,
+		},
+		{
+			map[string]interface{}{"type": "s3"},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := New(tc.Raw)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %s", tc.Raw, err)
+		}
+	}
+}
+
+func TestEntryArtifactId(t *testing.T) {
+	e := &Entry{
+		Name: "base",
+		Artifacts: []Artifact{
+			{BuilderId: "foo", Id: "1"},
+			{BuilderId: "bar", Id: "2"},
+		},
+	}
+
+	cases := []struct {
+		BuilderId string
+		Expected  string
+		Err       bool
+	}{
+		{"", "1", false},
+		{"foo", "1", false},
+		{"bar", "2", false},
+		{"baz", "", true},
+	}
+
+	for _, tc := range cases {
+		actual, err := e.ArtifactId(tc.BuilderId)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.BuilderId, err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("%s: bad: %s", tc.BuilderId, actual)
+		}
+	}
+
+	if _, err := new(Entry).ArtifactId(""); err == nil {
+		t.Fatal("should error without artifacts")
+	}
+}
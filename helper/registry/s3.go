@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Registry stores every entry under <prefix><name>/<unix time>.json
+// and keeps a copy of the most recent one in <prefix><name>/latest.json.
+type s3Registry struct {
+	Bucket    string
+	Prefix    string
+	Region    string
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Token     string
+
+	client *s3.S3
+}
+
+func (r *s3Registry) prepare() error {
+	if r.Bucket == "" {
+		return fmt.Errorf("'bucket' must be specified")
+	}
+
+	config := aws.NewConfig()
+	if r.Region != "" {
+		config = config.WithRegion(r.Region)
+	}
+	if r.AccessKey != "" && r.SecretKey != "" {
+		config = config.WithCredentials(credentials.NewStaticCredentials(
+			r.AccessKey, r.SecretKey, r.Token))
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.client = s3.New(sess)
+	return nil
+}
+
+func (r *s3Registry) key(name, object string) string {
+	return r.Prefix + escapeName(name) + "/" + object + ".json"
+}
+
+func (r *s3Registry) Record(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{
+		r.key(e.Name, strconv.FormatInt(e.Time.Unix(), 10)),
+		r.key(e.Name, "latest"),
+	}
+	for _, key := range keys {
+		_, err := r.client.PutObject(&s3.PutObjectInput{
+			Bucket:      aws.String(r.Bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			return fmt.Errorf("error uploading s3://%s/%s: %s", r.Bucket, key, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *s3Registry) Latest(name string) (*Entry, error) {
+	key := r.key(name, "latest")
+	resp, err := r.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(r.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("error reading s3://%s/%s: %s", r.Bucket, key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("error decoding s3://%s/%s: %s", r.Bucket, key, err)
+	}
+
+	return &e, nil
+}
@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The environment variables that configure the Consul agent to read keys
+// from, the same as the consul command uses.
+const (
+	EnvConsulAddr  = "CONSUL_HTTP_ADDR"
+	EnvConsulToken = "CONSUL_HTTP_TOKEN"
+	EnvConsulSSL   = "CONSUL_HTTP_SSL"
+)
+
+// DefaultConsulAddr is the address of the local Consul agent, used if
+// CONSUL_HTTP_ADDR isn't set.
+const DefaultConsulAddr = "127.0.0.1:8500"
+
+// ConsulKey reads the value of a key of the Consul KV store.
+func ConsulKey(key string) (string, error) {
+	addr := os.Getenv(EnvConsulAddr)
+	if addr == "" {
+		addr = DefaultConsulAddr
+	}
+	if !strings.Contains(addr, "://") {
+		scheme := "http"
+		if ssl, _ := strconv.ParseBool(os.Getenv(EnvConsulSSL)); ssl {
+			scheme = "https"
+		}
+		addr = scheme + "://" + addr
+	}
+
+	key = strings.TrimLeft(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("key must not be empty")
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/kv/"+key+"?raw", nil)
+	if err != nil {
+		return "", err
+	}
+	if token := os.Getenv(EnvConsulToken); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	client := &http.Client{Timeout: Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error reading Consul key '%s': %s", key, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("Consul key '%s' not found", key)
+	default:
+		return "", responseError(fmt.Sprintf("Consul key '%s'", key), resp)
+	}
+
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading Consul key '%s': %s", key, err)
+	}
+
+	return string(value), nil
+}
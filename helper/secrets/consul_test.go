@@ -0,0 +1,47 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConsulKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("ACL not found"))
+			return
+		}
+		if r.URL.Path != "/v1/kv/packer/password" || r.URL.RawQuery != "raw" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hunter2"))
+	}))
+	defer ts.Close()
+
+	os.Setenv(EnvConsulAddr, strings.TrimPrefix(ts.URL, "http://"))
+	defer os.Unsetenv(EnvConsulAddr)
+
+	if _, err := ConsulKey("packer/password"); err == nil || !strings.Contains(err.Error(), "ACL not found") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	os.Setenv(EnvConsulToken, "token")
+	defer os.Unsetenv(EnvConsulToken)
+
+	v, err := ConsulKey("/packer/password")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "hunter2" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	if _, err := ConsulKey("packer/other"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("bad: %v", err)
+	}
+}
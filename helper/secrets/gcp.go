@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// gcpEndpoint is the endpoint of the Secret Manager API.
+var gcpEndpoint = "https://secretmanager.googleapis.com"
+
+// gcpClient returns the client that authenticates the requests to the
+// Secret Manager. It uses the Application Default Credentials, like the
+// googlecompute builder does without an account file.
+var gcpClient = func() (*http.Client, error) {
+	return google.DefaultClient(oauth2.NoContext, "https://www.googleapis.com/auth/cloud-platform")
+}
+
+// GCPSecret reads a version of a secret of the GCP Secret Manager. The
+// version defaults to the latest one.
+func GCPSecret(project, secret, version string) (string, error) {
+	if project == "" || secret == "" {
+		return "", fmt.Errorf("project and secret must not be empty")
+	}
+	if version == "" {
+		version = "latest"
+	}
+	name := fmt.Sprintf("projects/%s/secrets/%s/versions/%s",
+		url.PathEscape(project), url.PathEscape(secret), url.PathEscape(version))
+
+	client, err := gcpClient()
+	if err != nil {
+		return "", fmt.Errorf("error authenticating to GCP: %s", err)
+	}
+	client.Timeout = Timeout
+
+	resp, err := client.Get(gcpEndpoint + "/v1/" + name + ":access")
+	if err != nil {
+		return "", fmt.Errorf("error reading GCP secret '%s': %s", name, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("GCP secret '%s' not found", name)
+	default:
+		return "", responseError(fmt.Sprintf("GCP secret '%s'", name), resp)
+	}
+
+	var result struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error decoding GCP secret '%s': %s", name, err)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(result.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("error decoding GCP secret '%s': %s", name, err)
+	}
+
+	return string(value), nil
+}
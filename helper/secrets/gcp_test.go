@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGCPSecret(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/projects/images/secrets/password/versions/latest:access":
+			w.Write([]byte(`{"name":"projects/1/secrets/password/versions/2","payload":{"data":"aHVudGVyMg=="}}`))
+		case "/v1/projects/images/secrets/password/versions/1:access":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"status":"PERMISSION_DENIED"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	oldEndpoint, oldClient := gcpEndpoint, gcpClient
+	defer func() { gcpEndpoint, gcpClient = oldEndpoint, oldClient }()
+	gcpEndpoint = ts.URL
+	gcpClient = func() (*http.Client, error) { return &http.Client{}, nil }
+
+	v, err := GCPSecret("images", "password", "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "hunter2" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	if _, err := GCPSecret("images", "password", "1"); err == nil || !strings.Contains(err.Error(), "PERMISSION_DENIED") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := GCPSecret("images", "other", ""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("bad: %v", err)
+	}
+}
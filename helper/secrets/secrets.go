@@ -0,0 +1,28 @@
+// Package secrets reads values from the secret stores that templates can
+// refer to: the Consul KV store, the AWS SSM Parameter Store and the GCP
+// Secret Manager.
+package secrets
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout is how long we wait for a secret store to respond.
+var Timeout = 30 * time.Second
+
+// responseError returns an error describing an unexpected response of a
+// secret store, with the start of its body that usually tells what's wrong.
+func responseError(what string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		return fmt.Errorf("error reading %s: %s", what, resp.Status)
+	}
+
+	return fmt.Errorf("error reading %s: %s: %s", what, resp.Status, msg)
+}
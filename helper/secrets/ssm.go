@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+)
+
+// ssmEndpoint overrides the endpoint of the SSM API, for tests.
+var ssmEndpoint string
+
+type ssmGetParameterInput struct {
+	_ struct{} `type:"structure"`
+
+	Name           *string `type:"string"`
+	WithDecryption *bool   `type:"boolean"`
+}
+
+type ssmGetParameterOutput struct {
+	_ struct{} `type:"structure"`
+
+	Parameter *ssmParameter `type:"structure"`
+}
+
+type ssmParameter struct {
+	_ struct{} `type:"structure"`
+
+	Value *string `type:"string"`
+}
+
+// SSMParameter reads the value of a parameter of the AWS SSM Parameter
+// Store, decrypted if it is a SecureString. The region defaults to the
+// one of the environment or the shared configuration, like the
+// credentials.
+func SSMParameter(name, region string) (string, error) {
+	config := aws.NewConfig().WithHTTPClient(&http.Client{Timeout: Timeout})
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	if ssmEndpoint != "" {
+		config = config.WithEndpoint(ssmEndpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return "", err
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		return "", fmt.Errorf("no AWS region set to read SSM parameter '%s' from", name)
+	}
+
+	// The SSM client of the SDK isn't vendored, and this is the only call
+	// we need, so the client is set up the way the SDK does it.
+	c := sess.ClientConfig("ssm")
+	svc := client.New(*c.Config, metadata.ClientInfo{
+		ServiceName:   "ssm",
+		SigningName:   c.SigningName,
+		SigningRegion: c.SigningRegion,
+		Endpoint:      c.Endpoint,
+		APIVersion:    "2014-11-06",
+		JSONVersion:   "1.1",
+		TargetPrefix:  "AmazonSSM",
+	}, c.Handlers)
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+
+	op := &request.Operation{
+		Name:       "GetParameter",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+	input := &ssmGetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}
+	output := &ssmGetParameterOutput{}
+	if err := svc.NewRequest(op, input, output).Send(); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ParameterNotFound" {
+			return "", fmt.Errorf("SSM parameter '%s' not found", name)
+		}
+
+		return "", fmt.Errorf("error reading SSM parameter '%s': %s", name, err)
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", fmt.Errorf("SSM parameter '%s' has no value", name)
+	}
+
+	return *output.Parameter.Value, nil
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSSMParameter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AmazonSSM.GetParameter" ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/ssm/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var input struct {
+			Name           string
+			WithDecryption bool
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if input.Name != "/packer/password" || !input.WithDecryption {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ParameterNotFound","message":""}`))
+			return
+		}
+		w.Write([]byte(`{"Parameter":{"Name":"/packer/password","Type":"SecureString","Value":"hunter2"}}`))
+	}))
+	defer ts.Close()
+
+	ssmEndpoint = ts.URL
+	defer func() { ssmEndpoint = "" }()
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID":           "AKID",
+		"AWS_SECRET_ACCESS_KEY":       "SECRET",
+		"AWS_CONFIG_FILE":             "/nonexistent",
+		"AWS_SHARED_CREDENTIALS_FILE": "/nonexistent",
+	} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	v, err := SSMParameter("/packer/password", "eu-west-1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "hunter2" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	if _, err := SSMParameter("/packer/other", "eu-west-1"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("bad: %v", err)
+	}
+}
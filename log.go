@@ -1,29 +1,315 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // These are the environmental variables that determine if we log, and if
 // we log whether or not the log should go to a file.
-const EnvLog = "PACKER_LOG"          //Set to True
-const EnvLogFile = "PACKER_LOG_PATH" //Set to a file
+const EnvLog = "PACKER_LOG"                   //Set to True or a log level
+const EnvLogFile = "PACKER_LOG_PATH"          //Set to a file
+const EnvLogFilter = "PACKER_LOG_FILTER"      //Set to component=level,...
+const EnvLogMaxSize = "PACKER_LOG_MAX_SIZE"   //Set to a size in megabytes
+const EnvLogMaxFiles = "PACKER_LOG_MAX_FILES" //Set to a number of files
+
+// logLevel is the severity of a log line. Lines are written when their
+// level is at least the level configured for their component.
+type logLevel int
+
+const (
+	logLevelTrace logLevel = iota
+	logLevelDebug
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+	logLevelOff
+)
+
+var logLevels = map[string]logLevel{
+	"trace": logLevelTrace,
+	"debug": logLevelDebug,
+	"info":  logLevelInfo,
+	"warn":  logLevelWarn,
+	"error": logLevelError,
+	"off":   logLevelOff,
+}
+
+// logTagLevels maps the tags log lines start with, like [DEBUG], to their
+// level. Lines without a known tag are info.
+var logTagLevels = map[string]logLevel{
+	"TRACE":   logLevelTrace,
+	"DEBUG":   logLevelDebug,
+	"INFO":    logLevelInfo,
+	"WARN":    logLevelWarn,
+	"WARNING": logLevelWarn,
+	"ERR":     logLevelError,
+	"ERROR":   logLevelError,
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	level, ok := logLevels[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q, must be one of trace, debug, info, warn, error or off", s)
+	}
+	return level, nil
+}
 
 // logOutput determines where we should send logs (if anywhere).
 func logOutput() (logOutput io.Writer, err error) {
-	logOutput = nil
-	if os.Getenv(EnvLog) != "" && os.Getenv(EnvLog) != "0" {
-		logOutput = os.Stderr
-
-		if logPath := os.Getenv(EnvLogFile); logPath != "" {
-			var err error
-			logOutput, err = os.Create(logPath)
-			if err != nil {
-				return nil, err
+	level := logLevelOff
+	switch v := os.Getenv(EnvLog); strings.ToLower(v) {
+	case "", "0", "false":
+	case "1", "true":
+		level = logLevelTrace
+	default:
+		level, err = parseLogLevel(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", EnvLog, err)
+		}
+	}
+
+	filter, err := newLogFilter(level, os.Getenv(EnvLogFilter))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", EnvLogFilter, err)
+	}
+	if filter.disabled() {
+		return nil, nil
+	}
+
+	filter.out = os.Stderr
+	if logPath := os.Getenv(EnvLogFile); logPath != "" {
+		filter.out, err = newLogFile(logPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+// logFilter is a writer that only passes on the log lines whose level is
+// enabled for their component. A line's component is the plugin that
+// logged it, e.g. provisioner.powershell, or the one given by a tag after
+// the level, like "[DEBUG] (communicator.winrm) ...". Everything else is
+// the core component.
+type logFilter struct {
+	out io.Writer
+
+	level      logLevel
+	components map[string]logLevel
+
+	l    sync.Mutex
+	buf  []byte
+	last map[string]bool
+}
+
+// newLogFilter parses filters in the form component=level separated by
+// commas. A filter also applies to the sub-components of its component,
+// so provisioner=debug applies to provisioner.shell.
+func newLogFilter(level logLevel, filters string) (*logFilter, error) {
+	f := &logFilter{
+		level:      level,
+		components: make(map[string]logLevel),
+		last:       make(map[string]bool),
+	}
+
+	for _, filter := range strings.Split(filters, ",") {
+		filter = strings.TrimSpace(filter)
+		if filter == "" {
+			continue
+		}
+
+		parts := strings.SplitN(filter, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("filter %q must be in the form component=level", filter)
+		}
+		componentLevel, err := parseLogLevel(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		f.components[strings.ToLower(strings.TrimSpace(parts[0]))] = componentLevel
+	}
+
+	return f, nil
+}
+
+// disabled returns true if no line can pass the filter.
+func (f *logFilter) disabled() bool {
+	if f.level != logLevelOff {
+		return false
+	}
+	for _, level := range f.components {
+		if level != logLevelOff {
+			return false
+		}
+	}
+	return true
+}
+
+// componentLevel returns the level of the most specific filter matching
+// the component.
+func (f *logFilter) componentLevel(component string) logLevel {
+	for {
+		if level, ok := f.components[component]; ok {
+			return level
+		}
+		i := strings.LastIndex(component, ".")
+		if i < 0 {
+			return f.level
+		}
+		component = component[:i]
+	}
+}
+
+func (f *logFilter) Write(p []byte) (int, error) {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	f.buf = append(f.buf, p...)
+	for {
+		i := bytes.IndexByte(f.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := f.buf[:i+1]
+		if f.allowed(string(line)) {
+			if _, err := f.out.Write(line); err != nil {
+				return 0, err
 			}
 		}
+		f.buf = f.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+var (
+	logTimestampRe = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+	logPluginRe    = regexp.MustCompile(`^packer-(builder|provisioner|post-processor)-([^:\s]+): `)
+	logLevelRe     = regexp.MustCompile(`^\[([A-Z]+)\] `)
+	logComponentRe = regexp.MustCompile(`^\(([\w.-]+)\) `)
+)
+
+// allowed decides if a line is written. Lines that don't start with a
+// timestamp continue the previous line of their source, like multi-line
+// messages and panics, and follow its decision.
+func (f *logFilter) allowed(line string) bool {
+	source := ""
+	if !logTimestampRe.MatchString(line) {
+		if allowed, ok := f.last[source]; ok {
+			return allowed
+		}
+		return logLevelInfo >= f.componentLevel("core")
+	}
+	line = logTimestampRe.ReplaceAllString(line, "")
+
+	component := "core"
+	if m := logPluginRe.FindStringSubmatch(line); m != nil {
+		source = m[0]
+		component = m[1] + "." + m[2]
+		line = line[len(m[0]):]
+
+		if !logTimestampRe.MatchString(line) {
+			return f.last[source]
+		}
+		line = logTimestampRe.ReplaceAllString(line, "")
+	}
+
+	level := logLevelInfo
+	if m := logLevelRe.FindStringSubmatch(line); m != nil {
+		if tagLevel, ok := logTagLevels[m[1]]; ok {
+			level = tagLevel
+		}
+		line = line[len(m[0]):]
+	}
+	if m := logComponentRe.FindStringSubmatch(line); m != nil {
+		component = m[1]
+	}
+
+	allowed := level >= f.componentLevel(strings.ToLower(component))
+	f.last[source] = allowed
+	return allowed
+}
+
+// logFile is a log file that is rotated when it grows larger than
+// PACKER_LOG_MAX_SIZE megabytes. PACKER_LOG_MAX_FILES old files are kept,
+// named like the log file with a .1, .2, ... suffix.
+type logFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	f    *os.File
+	size int64
+}
+
+func newLogFile(path string) (*logFile, error) {
+	lf := &logFile{path: path, maxFiles: 5}
+
+	if v := os.Getenv(EnvLogMaxSize); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("%s must be a positive number of megabytes, got %q", EnvLogMaxSize, v)
+		}
+		lf.maxSize = int64(size) * 1024 * 1024
+	}
+	if v := os.Getenv(EnvLogMaxFiles); v != "" {
+		files, err := strconv.Atoi(v)
+		if err != nil || files < 0 {
+			return nil, fmt.Errorf("%s must be a number of files, got %q", EnvLogMaxFiles, v)
+		}
+		lf.maxFiles = files
+	}
+
+	var err error
+	lf.f, err = os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+func (lf *logFile) Write(p []byte) (int, error) {
+	if lf.maxSize > 0 && lf.size > 0 && lf.size+int64(len(p)) > lf.maxSize {
+		if err := lf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := lf.f.Write(p)
+	lf.size += int64(n)
+	return n, err
+}
+
+func (lf *logFile) rotate() error {
+	if err := lf.f.Close(); err != nil {
+		return err
 	}
 
-	return
+	if lf.maxFiles == 0 {
+		os.Remove(lf.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", lf.path, lf.maxFiles))
+		for i := lf.maxFiles - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", lf.path, i), fmt.Sprintf("%s.%d", lf.path, i+1))
+		}
+		if err := os.Rename(lf.path, lf.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(lf.path)
+	if err != nil {
+		return err
+	}
+	lf.f = f
+	lf.size = 0
+	return nil
 }
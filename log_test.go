@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFilter(t *testing.T) {
+	f, err := newLogFilter(logLevelInfo, "provisioner.powershell=trace, communicator.winrm=debug,builder=error")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	out := new(bytes.Buffer)
+	f.out = out
+
+	lines := []struct {
+		Line    string
+		Allowed bool
+	}{
+		{"2017/10/01 12:00:00 [INFO] Packer version: 1.1.0\n", true},
+		{"2017/10/01 12:00:00 [DEBUG] core detail\n", false},
+		{"continued\n", false},
+		{"2017/10/01 12:00:00 untagged\n", true},
+		{"2017/10/01 12:00:00 packer-provisioner-powershell: 2017/10/01 12:00:00 [TRACE] script\n", true},
+		{"2017/10/01 12:00:00 packer-provisioner-shell: 2017/10/01 12:00:00 [DEBUG] script\n", false},
+		{"2017/10/01 12:00:00 packer-builder-amazon-ebs: 2017/10/01 12:00:00 [INFO] (communicator.winrm) connected\n", true},
+		{"2017/10/01 12:00:00 packer-builder-amazon-ebs: 2017/10/01 12:00:00 [DEBUG] (communicator.winrm) connecting\n", true},
+		{"2017/10/01 12:00:00 packer-builder-amazon-ebs: 2017/10/01 12:00:00 [WARN] waiting\n", false},
+		{"2017/10/01 12:00:00 packer-builder-amazon-ebs: second line of the warning\n", false},
+		{"2017/10/01 12:00:00 packer-builder-amazon-ebs: 2017/10/01 12:00:00 [ERROR] failed\n", true},
+	}
+
+	expected := ""
+	for _, l := range lines {
+		if l.Allowed {
+			expected += l.Line
+		}
+		// Write in two parts to check partial lines are buffered
+		half := len(l.Line) / 2
+		f.Write([]byte(l.Line[:half]))
+		f.Write([]byte(l.Line[half:]))
+	}
+
+	if out.String() != expected {
+		t.Fatalf("bad output:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestLogFilter_Errors(t *testing.T) {
+	cases := []string{
+		"provisioner",
+		"=debug",
+		"provisioner=verbose",
+	}
+
+	for _, filters := range cases {
+		if _, err := newLogFilter(logLevelInfo, filters); err == nil {
+			t.Fatalf("%s: should have error", filters)
+		}
+	}
+}
+
+func TestLogFilter_Disabled(t *testing.T) {
+	f, err := newLogFilter(logLevelOff, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !f.disabled() {
+		t.Fatal("filter should be disabled")
+	}
+
+	f, err = newLogFilter(logLevelOff, "core=off,builder.docker=debug")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if f.disabled() {
+		t.Fatal("filter shouldn't be disabled")
+	}
+}
+
+func TestLogFile_Rotate(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "packer.log")
+	lf, err := newLogFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer lf.f.Close()
+	lf.maxSize = 10
+	lf.maxFiles = 2
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := lf.Write([]byte(line)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for p, content := range expected {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s: bad content %q", p, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("only two old files should be kept")
+	}
+	if files, _ := ioutil.ReadDir(td); len(files) != 3 || !strings.HasPrefix(files[0].Name(), "packer.log") {
+		t.Fatalf("bad files: %v", files)
+	}
+}
@@ -20,11 +20,20 @@ import (
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/packer/plugin"
 	"github.com/hashicorp/packer/version"
+	"github.com/mattn/go-isatty"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/panicwrap"
 	"github.com/mitchellh/prefixedio"
 )
 
+// EnvNoTTY, if set, prints plain lines instead of drawing the status of
+// running tasks in place, for terminals that don't support it.
+const EnvNoTTY = "PACKER_NO_TTY"
+
+// envStdoutTerminal tells the wrapped process that its output goes to an
+// interactive terminal, since it only sees the pipe of panicwrap.
+const envStdoutTerminal = "PACKER_STDOUT_TERMINAL"
+
 func main() {
 	// Call realMain instead of doing the work here so we can use
 	// `defer` statements within the function and have them work properly.
@@ -43,6 +52,10 @@ func realMain() int {
 		UUID, _ := uuid.GenerateUUID()
 		os.Setenv("PACKER_RUN_UUID", UUID)
 
+		if isatty.IsTerminal(os.Stdout.Fd()) {
+			os.Setenv(envStdoutTerminal, "1")
+		}
+
 		// Determine where logs should go in general (requested by the user)
 		logWriter, err := logOutput()
 		if err != nil {
@@ -128,6 +141,10 @@ func wrappedMain() int {
 
 	inPlugin := os.Getenv(plugin.MagicCookieKey) == plugin.MagicCookieValue
 
+	// The user can only be prompted if stdin is a terminal, which can't be
+	// known anymore once it is switched to a pipe
+	stdinTerminal := isatty.IsTerminal(os.Stdin.Fd())
+
 	// Prepare stdin for plugin usage by switching it to a pipe
 	// But do not switch to pipe in plugin
 	if !inPlugin {
@@ -147,6 +164,27 @@ func wrappedMain() int {
 		packer.CheckpointReporter.Enable(config.DisableCheckpointSignature)
 	}
 
+	// Serve the metrics of the builds if asked to. Plugins report theirs
+	// to the core, so they never serve them.
+	metricsAddr := config.MetricsAddress
+	if v := os.Getenv("PACKER_METRICS_ADDR"); v != "" {
+		metricsAddr = v
+	}
+	if metricsAddr != "" && !inPlugin {
+		l, err := packer.Metrics.Serve(metricsAddr)
+		if err != nil {
+			log.Printf("[WARN] Error serving the metrics on %s: %s", metricsAddr, err)
+		} else {
+			defer l.Close()
+		}
+	}
+
+	// The builds create their temporary directories in there
+	tempDir := config.TempDir
+	if v := os.Getenv("PACKER_TEMP_DIR"); v != "" {
+		tempDir = v
+	}
+
 	cacheDir := os.Getenv("PACKER_CACHE_DIR")
 	if cacheDir == "" {
 		cacheDir = "packer_cache"
@@ -165,6 +203,16 @@ func wrappedMain() int {
 	// the arguments...
 	args, machineReadable := extractMachineReadable(os.Args[1:])
 
+	// Translate the output for people, machine-readable output stays in
+	// English. Plugins send their output to the core, which translates it.
+	if !machineReadable && !inPlugin {
+		catalog, err := packer.LoadCatalog(packer.LocaleFromEnv(), packer.CatalogDirs())
+		if err != nil {
+			log.Printf("[WARN] Error loading the message catalog: %s", err)
+		}
+		packer.SetCatalog(catalog)
+	}
+
 	defer plugin.CleanupClients()
 
 	// Setup the UI if we're being machine-readable
@@ -172,6 +220,7 @@ func wrappedMain() int {
 		Reader:      os.Stdin,
 		Writer:      os.Stdout,
 		ErrorWriter: os.Stdout,
+		Terminal:    terminalOutput(),
 	}
 	if machineReadable {
 		ui = &packer.MachineReadableUi{
@@ -194,11 +243,15 @@ func wrappedMain() int {
 				Hook:          config.LoadHook,
 				PostProcessor: config.LoadPostProcessor,
 				Provisioner:   config.LoadProvisioner,
+				Fixer:         config.LoadFixer,
+				FixerNames:    config.FixerNames(),
 			},
 			Version: version.Version,
+			TempDir: tempDir,
 		},
-		Cache: cache,
-		Ui:    ui,
+		Cache:       cache,
+		Ui:          ui,
+		Interactive: stdinTerminal && !machineReadable,
 	}
 
 	cli := &cli.CLI{
@@ -245,6 +298,22 @@ func excludeHelpFunc(commands map[string]cli.CommandFactory, exclude []string) c
 	return cli.FilteredHelpFunc(helpCommands, cli.BasicHelpFunc("packer"))
 }
 
+// terminalOutput returns whether the output goes to an interactive
+// terminal, where the status of running tasks is drawn in place. CI
+// systems and dumb terminals get plain lines.
+func terminalOutput() bool {
+	if os.Getenv(EnvNoTTY) != "" || os.Getenv("CI") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	// The Windows console doesn't move the cursor with escape sequences
+	if runtime.GOOS == "windows" {
+		return false
+	}
+
+	return os.Getenv(envStdoutTerminal) != "" || isatty.IsTerminal(os.Stdout.Fd())
+}
+
 // extractMachineReadable checks the args for the machine readable
 // flag and returns whether or not it is on. It modifies the args
 // to remove this flag.
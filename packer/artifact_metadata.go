@@ -0,0 +1,107 @@
+package packer
+
+// The keys of the metadata that builders commonly know about their
+// artifacts. Components can use other keys, prefixed with their type, like
+// "shell.scripts".
+const (
+	MetadataBuildName   = "build_name"
+	MetadataBuilderType = "builder_type"
+	MetadataImageId     = "image_id"
+	MetadataRegion      = "region"
+	MetadataOSFamily    = "os_family"
+	MetadataOSVersion   = "os_version"
+	MetadataArch        = "arch"
+)
+
+// ArtifactMetadata is structured metadata about an artifact. Builders set
+// what they know about the artifact, provisioners add what they did to the
+// machine and every post-processor of the chain can read and extend it, so
+// that it isn't lost when a post-processor creates a new artifact. A key
+// can have several values, like the image IDs of several regions.
+type ArtifactMetadata map[string][]string
+
+// Get returns the first value of the key, or an empty string.
+func (m ArtifactMetadata) Get(key string) string {
+	if len(m[key]) == 0 {
+		return ""
+	}
+	return m[key][0]
+}
+
+// Set replaces the values of the key with the value.
+func (m ArtifactMetadata) Set(key, value string) {
+	m[key] = []string{value}
+}
+
+// Add adds the value to the values of the key.
+func (m ArtifactMetadata) Add(key, value string) {
+	m[key] = append(m[key], value)
+}
+
+// Merge replaces the values of the keys that are set in other.
+func (m ArtifactMetadata) Merge(other ArtifactMetadata) {
+	for k, v := range other {
+		m[k] = append([]string(nil), v...)
+	}
+}
+
+// MetadataArtifact is implemented by artifacts that have metadata.
+type MetadataArtifact interface {
+	Artifact
+
+	Metadata() ArtifactMetadata
+}
+
+// ArtifactMetadataOf returns a copy of the metadata of the artifact, which
+// is empty if it has none.
+func ArtifactMetadataOf(a Artifact) ArtifactMetadata {
+	m := make(ArtifactMetadata)
+	if a, ok := a.(MetadataArtifact); ok {
+		m.Merge(a.Metadata())
+	}
+	return m
+}
+
+// WithArtifactMetadata returns an artifact with the metadata of a, in
+// which the keys set in m replace the values of a.
+func WithArtifactMetadata(a Artifact, m ArtifactMetadata) Artifact {
+	if a == nil {
+		return nil
+	}
+
+	metadata := ArtifactMetadataOf(a)
+	metadata.Merge(m)
+	if w, ok := a.(*metadataArtifact); ok {
+		a = w.Artifact
+	}
+	return &metadataArtifact{Artifact: a, metadata: metadata}
+}
+
+type metadataArtifact struct {
+	Artifact
+	metadata ArtifactMetadata
+}
+
+func (a *metadataArtifact) Metadata() ArtifactMetadata {
+	m := make(ArtifactMetadata)
+	m.Merge(a.metadata)
+	return m
+}
+
+// MetadataProvisioner is implemented by provisioners that add metadata to
+// the artifact of the build, like the scripts they ran.
+type MetadataProvisioner interface {
+	Provisioner
+
+	Metadata() ArtifactMetadata
+}
+
+// ProvisionerMetadataOf returns a copy of the metadata the provisioner
+// adds to the artifact, which is empty if it adds none.
+func ProvisionerMetadataOf(p Provisioner) ArtifactMetadata {
+	m := make(ArtifactMetadata)
+	if p, ok := p.(MetadataProvisioner); ok {
+		m.Merge(p.Metadata())
+	}
+	return m
+}
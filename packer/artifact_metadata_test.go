@@ -0,0 +1,59 @@
+package packer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArtifactMetadata(t *testing.T) {
+	m := make(ArtifactMetadata)
+	if v := m.Get(MetadataRegion); v != "" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	m.Add(MetadataRegion, "us-east-1")
+	m.Add(MetadataRegion, "eu-west-1")
+	if v := m.Get(MetadataRegion); v != "us-east-1" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	m.Set(MetadataImageId, "ami-1")
+	m.Merge(ArtifactMetadata{MetadataImageId: {"ami-2"}})
+	expected := ArtifactMetadata{
+		MetadataRegion:  {"us-east-1", "eu-west-1"},
+		MetadataImageId: {"ami-2"},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("bad: %#v", m)
+	}
+}
+
+func TestWithArtifactMetadata(t *testing.T) {
+	a := new(MockArtifact)
+	if m := ArtifactMetadataOf(a); len(m) != 0 {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	withRegion := WithArtifactMetadata(a, ArtifactMetadata{MetadataRegion: {"us-east-1"}})
+	withImage := WithArtifactMetadata(withRegion, ArtifactMetadata{MetadataImageId: {"ami-1"}})
+	if withImage.Id() != a.Id() {
+		t.Fatalf("bad: %s", withImage.Id())
+	}
+	if _, ok := withImage.(*metadataArtifact).Artifact.(*MockArtifact); !ok {
+		t.Fatal("the wrappers shouldn't be nested")
+	}
+
+	expected := ArtifactMetadata{
+		MetadataRegion:  {"us-east-1"},
+		MetadataImageId: {"ami-1"},
+	}
+	if m := ArtifactMetadataOf(withImage); !reflect.DeepEqual(m, expected) {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	// The metadata of the artifact is a copy
+	ArtifactMetadataOf(withImage).Set(MetadataRegion, "eu-west-1")
+	if m := ArtifactMetadataOf(withImage); m.Get(MetadataRegion) != "us-east-1" {
+		t.Fatalf("bad: %#v", m)
+	}
+}
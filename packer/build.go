@@ -3,7 +3,15 @@ package packer
 import (
 	"fmt"
 	"log"
+	"os"
 	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/lock"
+	"github.com/hashicorp/packer/helper/registry"
+	"github.com/hashicorp/packer/template"
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
 const (
@@ -36,6 +44,35 @@ const (
 	// This key contains a map[string]string of the user variables for
 	// template processing.
 	UserVariablesConfigKey = "packer_user_variables"
+
+	// This key is set to the name the naming pattern of the template gives
+	// to the artifacts of the build, if the template has one.
+	ArtifactNameConfigKey = "packer_artifact_name"
+
+	// This key contains the IDs of the artifacts the configuration of the
+	// build looks up with "latest_artifact", by build name and builder
+	// ID, which the core reads from the artifact registry.
+	LatestArtifactsConfigKey = "packer_latest_artifacts"
+
+	// This key contains a map[string]string of the ids of the artifacts
+	// of the builds the build depends on, for "build_artifact".
+	BuildArtifactsConfigKey = "packer_build_artifacts"
+
+	// This key contains the checkpoints of the provisioners of the build,
+	// if any of them has one, for builders that can save the machine
+	// between the provisioners.
+	CheckpointsConfigKey = "packer_checkpoints"
+
+	// This key is set to the time of the run, in RFC 3339 format, that
+	// the time functions of the templates render, so that they render the
+	// same time in every component of the build.
+	BuildTimeConfigKey = "packer_build_time"
+
+	// This key is set to the temporary directory of the build. It is
+	// created when the build runs and removed when it ends, so that
+	// components keep their temporary files there instead of sharing the
+	// default directory with other builds.
+	TempDirConfigKey = "packer_temp_dir"
 )
 
 // A Build represents a single job within Packer that is responsible for
@@ -74,11 +111,30 @@ type Build interface {
 	// deleted prior to the build.
 	SetForce(bool)
 
+	// SetDevelop will enable/disable develop mode. In develop mode, the
+	// provisioners are run again whenever the local files they use
+	// change, until the build is cancelled. This must be called prior to
+	// Prepare.
+	SetDevelop(bool)
+
 	// SetOnError will determine what to do when a normal multistep step fails
 	// - "cleanup" - run cleanup steps
 	// - "abort" - exit without cleanup
 	// - "ask" - ask the user
 	SetOnError(string)
+
+	// SetBuildArtifacts sets the ids of the artifacts of the builds this
+	// build depends on, by build name, which its configuration reads with
+	// "build_artifact". This must be called prior to Prepare.
+	SetBuildArtifacts(map[string]string)
+
+	// Validate runs the additional checks of the components of the build
+	// that implement Validator. Prepare must be called first.
+	Validate(*ValidateContext) error
+
+	// Timings returns the wall-clock timings of the builder, its steps,
+	// the provisioners and the post-processors of the last Run.
+	Timings() []Timing
 }
 
 // A build struct represents a single build job, the result of which should
@@ -87,16 +143,46 @@ type Build interface {
 // (such as VirtualBox, EC2, etc.).
 type coreBuild struct {
 	name           string
+	artifactName   string
 	builder        Builder
 	builderConfig  interface{}
 	builderType    string
 	hooks          map[string][]Hook
+	notifier       *notifier
 	postProcessors [][]coreBuildPostProcessor
 	provisioners   []coreBuildProvisioner
 	templatePath   string
 	variables      map[string]string
 
+	registry         registry.Registry
+	latestArtifacts  interpolate.ResolvedArtifacts
+	registryMetadata map[string]string
+	templateHash     string
+
+	// The build holds the lock with lockName while it runs, if set.
+	locker      lock.Locker
+	lockName    string
+	lockTimeout time.Duration
+	lockCancel  chan struct{}
+
+	timings timingReport
+
+	buildArtifacts map[string]string
+
+	// buildTime is the time the time functions of the templates render.
+	buildTime time.Time
+
+	// tempRoot is the directory the temporary directory of the build,
+	// tempDir, is created in.
+	tempRoot string
+	tempDir  string
+
+	// packerConfig is the configuration Packer gives every component,
+	// set by Prepare.
+	packerConfig map[string]interface{}
+
 	debug         bool
+	develop       bool
 	force         bool
 	onError       string
 	l             sync.Mutex
@@ -118,6 +204,7 @@ type coreBuildProvisioner struct {
 	pType       string
 	provisioner Provisioner
 	config      []interface{}
+	checkpoint  string
 }
 
 // Returns the name of the build.
@@ -138,6 +225,11 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 
 	b.prepareCalled = true
 
+	b.tempDir, err = buildTempDir(b.tempRoot, b.name)
+	if err != nil {
+		return nil, err
+	}
+
 	packerConfig := map[string]interface{}{
 		BuildNameConfigKey:     b.name,
 		BuilderTypeConfigKey:   b.builderType,
@@ -145,23 +237,41 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 		ForceConfigKey:         b.force,
 		OnErrorConfigKey:       b.onError,
 		TemplatePathKey:        b.templatePath,
+		TempDirConfigKey:       b.tempDir,
 		UserVariablesConfigKey: b.variables,
 	}
+	if b.artifactName != "" {
+		packerConfig[ArtifactNameConfigKey] = b.artifactName
+	}
+	if b.latestArtifacts != nil {
+		packerConfig[LatestArtifactsConfigKey] = map[string]map[string]string(b.latestArtifacts)
+	}
+	if b.buildArtifacts != nil {
+		packerConfig[BuildArtifactsConfigKey] = b.buildArtifacts
+	}
+	if !b.buildTime.IsZero() {
+		packerConfig[BuildTimeConfigKey] = b.buildTime.Format(time.RFC3339Nano)
+	}
+	checkpoints, err := b.checkpoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) > 0 {
+		packerConfig[CheckpointsConfigKey] = checkpoints
+	}
+	b.packerConfig = packerConfig
 
 	// Prepare the builder
 	warn, err = b.builder.Prepare(b.builderConfig, packerConfig)
+	warn = append(b.deprecationWarnings(), warn...)
 	if err != nil {
 		log.Printf("Build '%s' prepare failure: %s\n", b.name, err)
 		return
 	}
 
 	// Prepare the provisioners
-	for _, coreProv := range b.provisioners {
-		configs := make([]interface{}, len(coreProv.config), len(coreProv.config)+1)
-		copy(configs, coreProv.config)
-		configs = append(configs, packerConfig)
-
-		if err = coreProv.provisioner.Prepare(configs...); err != nil {
+	for i := range b.provisioners {
+		if err = b.prepareProvisioner(i); err != nil {
 			return
 		}
 	}
@@ -179,12 +289,119 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 	return
 }
 
+// prepareProvisioner prepares the provisioner with the index. It is also
+// called by packer develop before running a provisioner again, so that it
+// reads the files it inlined while being prepared again.
+func (b *coreBuild) prepareProvisioner(i int) error {
+	coreProv := b.provisioners[i]
+	configs := make([]interface{}, len(coreProv.config), len(coreProv.config)+1)
+	copy(configs, coreProv.config)
+	configs = append(configs, b.packerConfig)
+
+	return coreProv.provisioner.Prepare(configs...)
+}
+
+// deprecationWarnings returns the warnings for the deprecated keys the
+// builder, provisioners and post-processors are configured with. The
+// components migrate them while decoding, but only builders can return
+// warnings.
+func (b *coreBuild) deprecationWarnings() []string {
+	var warnings []string
+	add := func(kind, typ string, raw interface{}) {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, w := range config.GetDeprecations(kind, typ).Warnings(m) {
+			warnings = append(warnings, fmt.Sprintf("%s '%s': %s", kind, typ, w))
+		}
+	}
+
+	add(config.KindBuilder, b.builderType, b.builderConfig)
+	for _, coreProv := range b.provisioners {
+		for _, raw := range coreProv.config {
+			add(config.KindProvisioner, coreProv.pType, raw)
+		}
+	}
+	for _, ppSeq := range b.postProcessors {
+		for _, corePP := range ppSeq {
+			add(config.KindPostProcessor, corePP.processorType, corePP.config)
+		}
+	}
+
+	return warnings
+}
+
+// Validate runs the additional checks of the builder, provisioners and
+// post-processors that implement Validator. Prepare must be called first.
+func (b *coreBuild) Validate(ctx *ValidateContext) error {
+	if !b.prepareCalled {
+		panic("Prepare must be called first")
+	}
+
+	var errs *MultiError
+	if err := ValidateComponent(b.builder, ctx); err != nil {
+		errs = MultiErrorAppend(errs, fmt.Errorf(
+			"builder '%s': %s", b.builderType, err))
+	}
+
+	for _, coreProv := range b.provisioners {
+		if err := ValidateComponent(coreProv.provisioner, ctx); err != nil {
+			errs = MultiErrorAppend(errs, fmt.Errorf(
+				"provisioner '%s': %s", coreProv.pType, err))
+		}
+	}
+
+	for _, ppSeq := range b.postProcessors {
+		for _, corePP := range ppSeq {
+			if err := ValidateComponent(corePP.processor, ctx); err != nil {
+				errs = MultiErrorAppend(errs, fmt.Errorf(
+					"post-processor '%s': %s", corePP.processorType, err))
+			}
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
 // Runs the actual build. Prepare must be called prior to running this.
 func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 	if !b.prepareCalled {
 		panic("Prepare must be called first")
 	}
 
+	if b.lockName != "" {
+		unlock, err := b.lock(originalUi)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	removeTempDir, err := b.createTempDir()
+	if err != nil {
+		return nil, err
+	}
+
+	done := Metrics.StartBuild(b.name)
+	b.timings.observe = func(t Timing) {
+		Metrics.ObserveTiming(b.name, t)
+		if t.Error != "" && stepFailureTypes[t.Type] {
+			b.notifyStep(t)
+		}
+	}
+	artifacts, err := b.run(originalUi, cache)
+	removeTempDir(err != nil)
+	done(err)
+	return artifacts, err
+}
+
+func (b *coreBuild) run(originalUi Ui, cache Cache) ([]Artifact, error) {
+
 	// Copy the hooks
 	hooks := make(map[string][]Hook)
 	for hookName, hookList := range b.hooks {
@@ -205,26 +422,55 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 			hooks[HookProvision] = make([]Hook, 0, 1)
 		}
 
-		hooks[HookProvision] = append(hooks[HookProvision], &ProvisionHook{
-			Provisioners:     provisioners,
-			ProvisionerTypes: provisionerTypes,
-		})
+		if b.develop {
+			files := make([][]string, len(b.provisioners))
+			for i, p := range b.provisioners {
+				files[i] = developFiles(p.config)
+			}
+
+			hooks[HookProvision] = append(hooks[HookProvision], &DevelopHook{
+				ProvisionHook: ProvisionHook{
+					Provisioners:     provisioners,
+					ProvisionerTypes: provisionerTypes,
+				},
+				Files:   files,
+				Prepare: b.prepareProvisioner,
+			})
+		} else {
+			hooks[HookProvision] = append(hooks[HookProvision], &ProvisionHook{
+				Provisioners:     provisioners,
+				ProvisionerTypes: provisionerTypes,
+			})
+		}
 	}
 
 	hook := &DispatchHook{Mapping: hooks}
 	artifacts := make([]Artifact, 0, 1)
 
-	// The builder just has a normal Ui, but targeted
-	builderUi := &TargetedUI{
-		Target: b.Name(),
-		Ui:     originalUi,
+	// The builder just has a normal Ui, but targeted. Steps and
+	// provisioners report their timings and metrics through it.
+	builderUi := &timingUi{
+		Ui: &metricsUi{
+			Ui: &TargetedUI{
+				Target: b.Name(),
+				Ui:     originalUi,
+			},
+			build:   b.name,
+			metrics: Metrics,
+		},
+		report: &b.timings,
 	}
 
+	b.notify(template.EventBuildStart, nil, nil)
+
 	log.Printf("Running builder: %s", b.builderType)
 	ts := CheckpointReporter.AddSpan(b.builderType, "builder")
+	b.timings.start("builder", b.builderType)
 	builderArtifact, err := b.builder.Run(builderUi, hook, cache)
+	b.timings.end("builder", b.builderType, errorString(err))
 	ts.End(err)
 	if err != nil {
+		b.notify(template.EventBuildFailure, nil, err)
 		return nil, err
 	}
 
@@ -234,6 +480,20 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 		return nil, nil
 	}
 
+	// Add what the build and the provisioners know to the metadata of the
+	// artifact, so that post-processors can read it
+	metadata := ArtifactMetadataOf(builderArtifact)
+	metadata.Set(MetadataBuildName, b.name)
+	metadata.Set(MetadataBuilderType, b.builderType)
+	for _, p := range b.provisioners {
+		for k, values := range ProvisionerMetadataOf(p.provisioner) {
+			for _, v := range values {
+				metadata.Add(k, v)
+			}
+		}
+	}
+	builderArtifact = WithArtifactMetadata(builderArtifact, metadata)
+
 	errors := make([]error, 0)
 	keepOriginalArtifact := len(b.postProcessors) == 0
 
@@ -242,14 +502,20 @@ PostProcessorRunSeqLoop:
 	for _, ppSeq := range b.postProcessors {
 		priorArtifact := builderArtifact
 		for i, corePP := range ppSeq {
-			ppUi := &TargetedUI{
-				Target: fmt.Sprintf("%s (%s)", b.Name(), corePP.processorType),
-				Ui:     originalUi,
+			ppUi := &metricsUi{
+				Ui: &TargetedUI{
+					Target: fmt.Sprintf("%s (%s)", b.Name(), corePP.processorType),
+					Ui:     originalUi,
+				},
+				build:   b.name,
+				metrics: Metrics,
 			}
 
 			builderUi.Say(fmt.Sprintf("Running post-processor: %s", corePP.processorType))
 			ts := CheckpointReporter.AddSpan(corePP.processorType, "post-processor")
+			b.timings.start("post-processor", corePP.processorType)
 			artifact, keep, err := corePP.processor.PostProcess(ppUi, priorArtifact)
+			b.timings.end("post-processor", corePP.processorType, errorString(err))
 			ts.End(err)
 			if err != nil {
 				errors = append(errors, fmt.Errorf("Post-processor failed: %s", err))
@@ -261,6 +527,11 @@ PostProcessorRunSeqLoop:
 				continue PostProcessorRunSeqLoop
 			}
 
+			// The new artifact keeps the metadata of the prior one
+			metadata := ArtifactMetadataOf(priorArtifact)
+			metadata.Merge(ArtifactMetadataOf(artifact))
+			artifact = WithArtifactMetadata(artifact, metadata)
+
 			keep = keep || corePP.keepInputArtifact
 			if i == 0 {
 				// This is the first post-processor. We handle deleting
@@ -305,13 +576,92 @@ PostProcessorRunSeqLoop:
 		}
 	}
 
+	// Only builds that fully succeeded are recorded, later builds must
+	// never pick up the artifacts of a partially failed one.
+	if len(errors) == 0 && b.registry != nil {
+		if err := b.record(artifacts); err != nil {
+			errors = append(errors, fmt.Errorf("Error recording artifacts: %s", err))
+		}
+	}
+
 	if len(errors) > 0 {
 		err = &MultiError{errors}
+		b.notify(template.EventBuildFailure, nil, err)
+	}
+
+	for _, a := range artifacts {
+		if a != nil {
+			b.notify(template.EventArtifact, a, nil)
+		}
 	}
 
 	return artifacts, err
 }
 
+// record stores the artifacts of the build in the artifact registry.
+func (b *coreBuild) record(artifacts []Artifact) error {
+	entry := &registry.Entry{
+		Name:         b.name,
+		BuilderType:  b.builderType,
+		TemplateHash: b.templateHash,
+		Time:         time.Now().UTC(),
+		Metadata:     b.registryMetadata,
+	}
+	for _, a := range artifacts {
+		if a == nil {
+			continue
+		}
+
+		entry.Artifacts = append(entry.Artifacts, registry.Artifact{
+			BuilderId: a.BuilderId(),
+			Id:        a.Id(),
+			String:    a.String(),
+		})
+	}
+
+	log.Printf("Recording %d artifacts for build '%s'", len(entry.Artifacts), b.name)
+	return b.registry.Record(entry)
+}
+
+// notify sends the notifications subscribed to the given event, if any.
+func (b *coreBuild) notify(event string, a Artifact, err error) {
+	data := &NotificationData{
+		Event:       event,
+		BuildName:   b.name,
+		BuilderType: b.builderType,
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	if a != nil {
+		data.ArtifactId = a.Id()
+		data.ArtifactString = a.String()
+	}
+
+	b.notifier.Notify(data)
+}
+
+// stepFailureTypes are the types of the timed sections whose failure
+// fires a step-failure notification. Scripts and communicator commands run
+// within them, and the builder failing is a build-failure.
+var stepFailureTypes = map[string]bool{
+	"step":           true,
+	"provisioner":    true,
+	"post-processor": true,
+}
+
+// notifyStep sends the step-failure notifications of a failed section.
+func (b *coreBuild) notifyStep(t Timing) {
+	b.notifier.Notify(&NotificationData{
+		Event:       template.EventStepFailure,
+		BuildName:   b.name,
+		BuilderType: b.builderType,
+		StepType:    t.Type,
+		Step:        t.Name,
+		Error:       t.Error,
+	})
+}
+
 func (b *coreBuild) SetDebug(val bool) {
 	if b.prepareCalled {
 		panic("prepare has already been called")
@@ -320,6 +670,14 @@ func (b *coreBuild) SetDebug(val bool) {
 	b.debug = val
 }
 
+func (b *coreBuild) SetDevelop(val bool) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.develop = val
+}
+
 func (b *coreBuild) SetForce(val bool) {
 	if b.prepareCalled {
 		panic("prepare has already been called")
@@ -336,7 +694,58 @@ func (b *coreBuild) SetOnError(val string) {
 	b.onError = val
 }
 
+func (b *coreBuild) SetBuildArtifacts(val map[string]string) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.buildArtifacts = val
+}
+
+// lock waits for the build lock and returns the function that releases
+// it.
+func (b *coreBuild) lock(ui Ui) (func(), error) {
+	ui = &TargetedUI{Target: b.Name(), Ui: ui}
+	owner := fmt.Sprintf("%s (pid %d)", b.name, os.Getpid())
+	if hostname, err := os.Hostname(); err == nil {
+		owner = fmt.Sprintf("%s (%s, pid %d)", b.name, hostname, os.Getpid())
+	}
+
+	b.l.Lock()
+	cancel := make(chan struct{})
+	b.lockCancel = cancel
+	b.l.Unlock()
+
+	err := lock.Acquire(b.locker, b.lockName, owner, b.lockTimeout, cancel, func(holder string) {
+		ui.Say(fmt.Sprintf("Waiting for build lock '%s', held by %s...", b.lockName, holder))
+	})
+
+	b.l.Lock()
+	b.lockCancel = nil
+	b.l.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("Error taking build lock '%s': %s", b.lockName, err)
+	}
+
+	return func() {
+		if err := b.locker.Unlock(b.lockName, owner); err != nil {
+			ui.Error(fmt.Sprintf("Error releasing build lock '%s': %s", b.lockName, err))
+		}
+	}, nil
+}
+
 // Cancels the build if it is running.
 func (b *coreBuild) Cancel() {
+	b.l.Lock()
+	if b.lockCancel != nil {
+		close(b.lockCancel)
+		b.lockCancel = nil
+	}
+	b.l.Unlock()
+
 	b.builder.Cancel()
 }
+
+func (b *coreBuild) Timings() []Timing {
+	return b.timings.Timings()
+}
@@ -0,0 +1,64 @@
+package packer
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// tempDirNameRe matches the characters of build names that aren't kept in
+// the names of their temporary directories.
+var tempDirNameRe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// buildTempDir returns the path of the temporary directory of a build in
+// the root directory, or in the default directory for temporary files if
+// root is empty. The path is unique, even for builds with the same name
+// in concurrent Packer processes.
+func buildTempDir(root, name string) (string, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	name = tempDirNameRe.ReplaceAllString(name, "-")
+	return filepath.Join(root, fmt.Sprintf("packer-%s-%x", name, suffix)), nil
+}
+
+// createTempDir creates the temporary directory of the build and returns
+// the function that removes it.
+func (b *coreBuild) createTempDir() (func(failed bool), error) {
+	if err := os.MkdirAll(filepath.Dir(b.tempDir), 0755); err != nil {
+		return nil, fmt.Errorf("Error creating the temporary directory root: %s", err)
+	}
+
+	// Mkdir fails if the directory exists, so the build never uses files
+	// it didn't create
+	if err := os.Mkdir(b.tempDir, 0700); err != nil {
+		return nil, fmt.Errorf("Error creating the temporary directory of build '%s': %s", b.name, err)
+	}
+	log.Printf("Build '%s' temporary directory: %s", b.name, b.tempDir)
+
+	return func(failed bool) {
+		// Aborting leaves everything for inspection, the temporary files
+		// of the build included
+		if failed && b.onError == "abort" {
+			log.Printf("Keeping the temporary directory of build '%s': %s", b.name, b.tempDir)
+			return
+		}
+
+		if err := os.RemoveAll(b.tempDir); err != nil {
+			log.Printf("[WARN] Error removing the temporary directory of build '%s': %s", b.name, err)
+		}
+	}, nil
+}
@@ -1,8 +1,19 @@
 package packer
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/lock"
+	"github.com/hashicorp/packer/helper/registry"
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
 func testBuild() *coreBuild {
@@ -15,7 +26,7 @@ func testBuild() *coreBuild {
 			"foo": {&MockHook{}},
 		},
 		provisioners: []coreBuildProvisioner{
-			{"mock-provisioner", &MockProvisioner{}, []interface{}{42}},
+			{"mock-provisioner", &MockProvisioner{}, []interface{}{42}, ""},
 		},
 		postProcessors: [][]coreBuildPostProcessor{
 			{
@@ -52,6 +63,7 @@ func TestBuild_Prepare(t *testing.T) {
 	builder := build.builder.(*MockBuilder)
 
 	build.Prepare()
+	packerConfig[TempDirConfigKey] = build.tempDir
 	if !builder.PrepareCalled {
 		t.Fatal("should be called")
 	}
@@ -78,6 +90,49 @@ func TestBuild_Prepare(t *testing.T) {
 	}
 }
 
+func TestBuild_Prepare_Checkpoints(t *testing.T) {
+	build := testBuild()
+	build.provisioners = []coreBuildProvisioner{
+		{"shell", &MockProvisioner{}, []interface{}{map[string]interface{}{"inline": "a"}}, "deps"},
+		{"shell", &MockProvisioner{}, []interface{}{map[string]interface{}{"inline": "b"}}, ""},
+		{"shell", &MockProvisioner{}, []interface{}{map[string]interface{}{"inline": "c"}}, "app"},
+	}
+	builder := build.builder.(*MockBuilder)
+
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	checkpoints := builder.PrepareConfig[1].(map[string]interface{})[CheckpointsConfigKey].([]interface{})
+	if len(checkpoints) != 2 {
+		t.Fatalf("bad: %#v", checkpoints)
+	}
+	deps := checkpoints[0].(map[string]interface{})
+	app := checkpoints[1].(map[string]interface{})
+	if deps["name"] != "deps" || deps["provisioners"] != 1 {
+		t.Fatalf("bad: %#v", deps)
+	}
+	if app["name"] != "app" || app["provisioners"] != 3 {
+		t.Fatalf("bad: %#v", app)
+	}
+
+	// Changing a provisioner changes the hash of the checkpoints after
+	// it, but not before
+	other := testBuild()
+	other.provisioners = build.provisioners
+	other.provisioners[1].config = []interface{}{map[string]interface{}{"inline": "changed"}}
+	otherCheckpoints, err := other.checkpoints()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if otherCheckpoints[0].(map[string]interface{})["hash"] != deps["hash"] {
+		t.Fatal("the hash of deps shouldn't change")
+	}
+	if otherCheckpoints[1].(map[string]interface{})["hash"] == app["hash"] {
+		t.Fatal("the hash of app should change")
+	}
+}
+
 func TestBuild_Prepare_Twice(t *testing.T) {
 	build := testBuild()
 	warn, err := build.Prepare()
@@ -118,6 +173,31 @@ func TestBuildPrepare_BuilderWarniings(t *testing.T) {
 	}
 }
 
+func TestBuildPrepare_DeprecationWarnings(t *testing.T) {
+	config.RegisterDeprecations(config.KindProvisioner, "deprecated-provisioner",
+		config.Deprecations{{Key: "old", NewKey: "new"}})
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+	builder.PrepareWarnings = []string{"foo"}
+	build.provisioners = append(build.provisioners, coreBuildProvisioner{
+		"deprecated-provisioner", &MockProvisioner{},
+		[]interface{}{map[string]interface{}{"old": "value"}}, "",
+	})
+
+	warn, err := build.Prepare()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{
+		`provisioner 'deprecated-provisioner': "old" is deprecated, use "new" instead`,
+		"foo",
+	}
+	if !reflect.DeepEqual(warn, expected) {
+		t.Fatalf("bad: %#v", warn)
+	}
+}
+
 func TestBuild_Prepare_Debug(t *testing.T) {
 	packerConfig := testDefaultPackerConfig()
 	packerConfig[DebugConfigKey] = true
@@ -127,6 +207,7 @@ func TestBuild_Prepare_Debug(t *testing.T) {
 
 	build.SetDebug(true)
 	build.Prepare()
+	packerConfig[TempDirConfigKey] = build.tempDir
 	if !builder.PrepareCalled {
 		t.Fatalf("should be called")
 	}
@@ -166,11 +247,26 @@ func TestBuildPrepare_variables_default(t *testing.T) {
 		t.Fatal("prepare should be called")
 	}
 
+	packerConfig[TempDirConfigKey] = build.tempDir
 	if !reflect.DeepEqual(builder.PrepareConfig[1], packerConfig) {
 		t.Fatalf("prepare bad: %#v", builder.PrepareConfig[1])
 	}
 }
 
+func TestBuildPrepare_buildTime(t *testing.T) {
+	build := testBuild()
+	build.buildTime = time.Date(2018, 6, 12, 9, 41, 7, 5e8, time.UTC)
+	builder := build.builder.(*MockBuilder)
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	packerConfig := builder.PrepareConfig[1].(map[string]interface{})
+	if v := packerConfig[BuildTimeConfigKey]; v != "2018-06-12T09:41:07.5Z" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
 func TestBuild_Run(t *testing.T) {
 	cache := &TestCache{}
 	ui := testUi()
@@ -217,6 +313,95 @@ func TestBuild_Run(t *testing.T) {
 	}
 }
 
+type testTempDirBuilder struct {
+	MockBuilder
+	tempDir string
+	exists  bool
+}
+
+func (b *testTempDirBuilder) Run(ui Ui, h Hook, c Cache) (Artifact, error) {
+	b.tempDir = b.PrepareConfig[1].(map[string]interface{})[TempDirConfigKey].(string)
+	if info, err := os.Stat(b.tempDir); err == nil && info.IsDir() {
+		b.exists = true
+	}
+	return b.MockBuilder.Run(ui, h, c)
+}
+
+func TestBuild_Run_TempDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	for _, tc := range []struct {
+		onError string
+		fail    bool
+		removed bool
+	}{
+		{"cleanup", false, true},
+		{"cleanup", true, true},
+		{"abort", false, true},
+		{"abort", true, false},
+	} {
+		builder := &testTempDirBuilder{MockBuilder: MockBuilder{RunErrResult: tc.fail}}
+		build := testBuild()
+		build.name = "test/1"
+		build.builder = builder
+		build.onError = tc.onError
+		build.tempRoot = filepath.Join(td, "builds")
+		if _, err := build.Prepare(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		build.Run(testUi(), &TestCache{})
+
+		if filepath.Dir(builder.tempDir) != build.tempRoot {
+			t.Fatalf("bad: %s", builder.tempDir)
+		}
+		if !strings.HasPrefix(filepath.Base(builder.tempDir), "packer-test-1-") {
+			t.Fatalf("bad: %s", builder.tempDir)
+		}
+		if !builder.exists {
+			t.Fatalf("%s should exist while the build runs", builder.tempDir)
+		}
+		if _, err := os.Stat(builder.tempDir); os.IsNotExist(err) != tc.removed {
+			t.Fatalf("%#v: bad: %s", tc, err)
+		}
+	}
+}
+
+type testMetadataProvisioner struct {
+	MockProvisioner
+}
+
+func (*testMetadataProvisioner) Metadata() ArtifactMetadata {
+	return ArtifactMetadata{"shell.scripts": {"setup.sh"}}
+}
+
+func TestBuild_Run_Metadata(t *testing.T) {
+	build := testBuild()
+	build.provisioners = []coreBuildProvisioner{
+		{"shell", &PausedProvisioner{Provisioner: new(testMetadataProvisioner)}, []interface{}{42}, ""},
+	}
+	build.Prepare()
+	artifacts, err := build.Run(testUi(), &TestCache{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The artifact of the post-processor carries the metadata of the build
+	expected := ArtifactMetadata{
+		MetadataBuildName:   {"test"},
+		MetadataBuilderType: {"foo"},
+		"shell.scripts":     {"setup.sh"},
+	}
+	for _, a := range artifacts {
+		if m := ArtifactMetadataOf(a); !reflect.DeepEqual(m, expected) {
+			t.Fatalf("bad metadata of %s: %#v", a.Id(), m)
+		}
+	}
+}
+
 func TestBuild_Run_Artifacts(t *testing.T) {
 	cache := &TestCache{}
 	ui := testUi()
@@ -353,6 +538,144 @@ func TestBuild_Run_Artifacts(t *testing.T) {
 	}
 }
 
+func TestBuild_Run_ArtifactRegistry(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	config := map[string]interface{}{
+		"type":      "file",
+		"directory": td,
+	}
+	r, err := registry.New(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	build := testBuild()
+	build.registry = r
+	build.latestArtifacts = interpolate.ResolvedArtifacts{"base": {"": "ami-1234"}}
+	build.registryMetadata = map[string]string{"foo": "bar"}
+	build.templateHash = "abcd"
+
+	build.Prepare()
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[LatestArtifactsConfigKey] = map[string]map[string]string{"base": {"": "ami-1234"}}
+	packerConfig[TempDirConfigKey] = build.tempDir
+	builder := build.builder.(*MockBuilder)
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+
+	if _, err := build.Run(testUi(), &TestCache{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	e, err := r.Latest("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e == nil {
+		t.Fatal("should record the build")
+	}
+	if e.BuilderType != "foo" || e.TemplateHash != "abcd" || e.Metadata["foo"] != "bar" {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	expected := []registry.Artifact{
+		{BuilderId: "bid", Id: "b", String: "string"},
+		{BuilderId: "bid", Id: "pp", String: "string"},
+	}
+	if !reflect.DeepEqual(e.Artifacts, expected) {
+		t.Fatalf("bad: %#v", e.Artifacts)
+	}
+
+	// Failed builds are not recorded
+	build = testBuild()
+	build.registry = r
+	build.builder = &MockBuilder{ArtifactId: "c", RunErrResult: true}
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err == nil {
+		t.Fatal("should error")
+	}
+
+	e, err = r.Latest("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if e.Artifacts[0].Id != "b" {
+		t.Fatalf("bad: %#v", e)
+	}
+}
+
+func TestBuild_Run_Lock(t *testing.T) {
+	defer func(d time.Duration) { lock.PollInterval = d }(lock.PollInterval)
+	lock.PollInterval = 10 * time.Millisecond
+
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	l, err := lock.New(map[string]interface{}{"type": "file", "directory": td})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	build := testBuild()
+	build.locker = l
+	build.lockName = "template"
+	build.lockTimeout = 50 * time.Millisecond
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The lock is released once the build is done
+	if holder, err := l.TryLock("template", "other"); err != nil || holder != "" {
+		t.Fatalf("should be released: %q %s", holder, err)
+	}
+
+	// so builds wait for others holding it
+	build = testBuild()
+	build.locker = l
+	build.lockName = "template"
+	build.lockTimeout = 50 * time.Millisecond
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err == nil {
+		t.Fatal("should time out")
+	}
+	if build.builder.(*MockBuilder).RunCalled {
+		t.Fatal("should not run the builder")
+	}
+}
+
+func TestBuild_Run_Timings(t *testing.T) {
+	build := testBuild()
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The mock builder runs the provisioners through the provision hook
+	timings := build.Timings()
+	if len(timings) != 3 {
+		t.Fatalf("bad: %#v", timings)
+	}
+	if timings[0].Type != "builder" || timings[0].Name != "foo" {
+		t.Fatalf("bad: %#v", timings[0])
+	}
+	if timings[1].Type != "provisioner" || timings[1].Name != "mock-provisioner" || timings[1].Depth != 1 {
+		t.Fatalf("bad: %#v", timings[1])
+	}
+	if timings[2].Type != "post-processor" || timings[2].Name != "testPP" || timings[2].Depth != 0 {
+		t.Fatalf("bad: %#v", timings[2])
+	}
+}
+
 func TestBuild_RunBeforePrepare(t *testing.T) {
 	defer func() {
 		p := recover()
@@ -377,3 +700,32 @@ func TestBuild_Cancel(t *testing.T) {
 		t.Fatal("cancel should be called")
 	}
 }
+
+func TestBuild_Validate(t *testing.T) {
+	build := testBuild()
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx := &ValidateContext{CheckRemote: true}
+	if err := build.Validate(ctx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	builder := build.builder.(*MockBuilder)
+	if !builder.ValidateCalled {
+		t.Fatal("validate should be called")
+	}
+	if builder.ValidateCtx != ctx {
+		t.Fatalf("bad: %#v", builder.ValidateCtx)
+	}
+
+	builder.ValidateErr = errors.New("no such image")
+	err := build.Validate(ctx)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "builder 'foo': no such image") {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -12,14 +12,17 @@ type MockBuilder struct {
 	PrepareWarnings []string
 	RunErrResult    bool
 	RunNilResult    bool
+	ValidateErr     error
 
-	PrepareCalled bool
-	PrepareConfig []interface{}
-	RunCalled     bool
-	RunCache      Cache
-	RunHook       Hook
-	RunUi         Ui
-	CancelCalled  bool
+	PrepareCalled  bool
+	PrepareConfig  []interface{}
+	RunCalled      bool
+	RunCache       Cache
+	RunHook        Hook
+	RunUi          Ui
+	ValidateCalled bool
+	ValidateCtx    *ValidateContext
+	CancelCalled   bool
 }
 
 func (tb *MockBuilder) Prepare(config ...interface{}) ([]string, error) {
@@ -53,6 +56,12 @@ func (tb *MockBuilder) Run(ui Ui, h Hook, c Cache) (Artifact, error) {
 	}, nil
 }
 
+func (tb *MockBuilder) Validate(ctx *ValidateContext) error {
+	tb.ValidateCalled = true
+	tb.ValidateCtx = ctx
+	return tb.ValidateErr
+}
+
 func (tb *MockBuilder) Cancel() {
 	tb.CancelCalled = true
 }
@@ -3,11 +3,16 @@ package packer
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/helper/lock"
 )
 
 // Cache implements a caching interface where files can be stored for
@@ -31,18 +36,71 @@ type Cache interface {
 	// The second return parameter is whether the key existed or not.
 	// This will block if any locks are held for writing. No lock will be
 	// held if the key doesn't exist.
+	//
+	// A read lock is a reference to the file: the file isn't pruned from
+	// the cache while it is held, but it can still be replaced by a writer.
 	RLock(string) (string, bool)
 
 	// RUnlock will unlock a key for reading.
 	RUnlock(string)
 }
 
+// The suffixes of the files that the cache keeps next to an entry: the
+// lock taken by writers, the lock taken by readers, and the partial
+// download and its state.
+const (
+	cacheLockSuffix     = ".lock"
+	cacheRefSuffix      = ".ref"
+	cachePartSuffix     = ".part"
+	cacheSegmentsSuffix = ".part.segments"
+)
+
+var cacheSuffixes = []string{
+	cacheSegmentsSuffix,
+	cachePartSuffix,
+	cacheLockSuffix,
+	cacheRefSuffix,
+}
+
+// errCacheLocked is returned when a file of the cache is locked by
+// another process and the lock isn't waited for.
+var errCacheLocked = lock.ErrFileLocked
+
 // FileCache implements a Cache by caching the data directly to a cache
 // directory.
+//
+// The cache directory can be shared by concurrent Packer processes.
+// Writers hold an exclusive file lock, and readers hold a shared file lock
+// for as long as they use the file, counted by key within a process, so
+// that pruning skips the files in use.
 type FileCache struct {
 	CacheDir string
 	l        sync.Mutex
 	rw       map[string]*sync.RWMutex
+	locks    map[string]*os.File
+	refs     map[string]*cacheRef
+}
+
+// cacheRef is the shared lock of the readers of a key in this process.
+type cacheRef struct {
+	file  *os.File
+	count int
+}
+
+// CacheEntry is a file in the cache directory, or a partial download of
+// one.
+type CacheEntry struct {
+	// Path is the path of the file.
+	Path string
+
+	// Size is the size of the file and of its partial download, if any.
+	Size int64
+
+	// LastUsed is the last time the file was written or read.
+	LastUsed time.Time
+
+	// Partial is true if the file was never completely downloaded.
+	Partial bool
 }
 
 func (f *FileCache) Lock(key string) string {
@@ -50,11 +108,34 @@ func (f *FileCache) Lock(key string) string {
 	rw := f.rwLock(hashKey)
 	rw.Lock()
 
-	return f.cachePath(key, hashKey)
+	path := f.cachePath(key, hashKey)
+	lock, err := lockCacheFile(path+cacheLockSuffix, true)
+	if err != nil {
+		log.Printf("[ERR] Error locking %s, other processes may write to it: %s", path, err)
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.locks == nil {
+		f.locks = make(map[string]*os.File)
+	}
+	f.locks[hashKey] = lock
+
+	return path
 }
 
 func (f *FileCache) Unlock(key string) {
 	hashKey := f.hashKey(key)
+
+	f.l.Lock()
+	lock := f.locks[hashKey]
+	delete(f.locks, hashKey)
+	f.l.Unlock()
+
+	if lock != nil {
+		lock.Close()
+	}
+
 	rw := f.rwLock(hashKey)
 	rw.Unlock()
 }
@@ -63,14 +144,184 @@ func (f *FileCache) RLock(key string) (string, bool) {
 	hashKey := f.hashKey(key)
 	rw := f.rwLock(hashKey)
 	rw.RLock()
+	defer rw.RUnlock()
 
-	return f.cachePath(key, hashKey), true
+	// Wait for the writers of other processes
+	path := f.cachePath(key, hashKey)
+	lock, err := lockCacheFile(path+cacheLockSuffix, false)
+	if err != nil {
+		log.Printf("[ERR] Error locking %s for reading: %s", path, err)
+	} else {
+		defer lock.Close()
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return path, false
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.refs == nil {
+		f.refs = make(map[string]*cacheRef)
+	}
+	if ref, ok := f.refs[hashKey]; ok {
+		ref.count++
+	} else {
+		file, err := lockCacheFile(path+cacheRefSuffix, false)
+		if err != nil {
+			log.Printf("[ERR] Error locking %s for reading, it may be pruned: %s", path, err)
+		}
+		f.refs[hashKey] = &cacheRef{file: file, count: 1}
+	}
+
+	// The modification time records the last use, which pruning is based on
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		log.Printf("[WARN] Error updating the last use of %s: %s", path, err)
+	}
+
+	return path, true
 }
 
 func (f *FileCache) RUnlock(key string) {
 	hashKey := f.hashKey(key)
-	rw := f.rwLock(hashKey)
-	rw.RUnlock()
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	ref, ok := f.refs[hashKey]
+	if !ok {
+		return
+	}
+
+	ref.count--
+	if ref.count == 0 {
+		if ref.file != nil {
+			ref.file.Close()
+		}
+		delete(f.refs, hashKey)
+	}
+}
+
+// Entries returns the files of the cache directory, from the least to the
+// most recently used.
+func (f *FileCache) Entries() ([]*CacheEntry, error) {
+	infos, err := ioutil.ReadDir(f.CacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := make(map[string]*CacheEntry)
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		name, suffix := info.Name(), ""
+		for _, s := range cacheSuffixes {
+			if strings.HasSuffix(name, s) {
+				name, suffix = strings.TrimSuffix(name, s), s
+				break
+			}
+		}
+
+		e, ok := entries[name]
+		if !ok {
+			e = &CacheEntry{Path: filepath.Join(f.CacheDir, name), Partial: true}
+			entries[name] = e
+		}
+		if suffix == cacheLockSuffix || suffix == cacheRefSuffix {
+			continue
+		}
+
+		e.Size += info.Size()
+		if info.ModTime().After(e.LastUsed) {
+			e.LastUsed = info.ModTime()
+		}
+		if suffix == "" {
+			e.Partial = false
+		}
+	}
+
+	result := make([]*CacheEntry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastUsed.Equal(result[j].LastUsed) {
+			return result[i].LastUsed.Before(result[j].LastUsed)
+		}
+		return result[i].Path < result[j].Path
+	})
+
+	return result, nil
+}
+
+// InUse returns whether a Packer process is writing or reading the entry.
+func (f *FileCache) InUse(e *CacheEntry) bool {
+	locks, err := f.lockEntry(e)
+	if err != nil {
+		return true
+	}
+	for _, l := range locks {
+		l.Close()
+	}
+	return false
+}
+
+// Remove removes the entry and its partial download, unless a Packer
+// process is writing or reading it. It returns whether the entry was
+// removed.
+func (f *FileCache) Remove(e *CacheEntry) (bool, error) {
+	locks, err := f.lockEntry(e)
+	if err == errCacheLocked {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		for _, l := range locks {
+			l.Close()
+		}
+	}()
+
+	for _, suffix := range []string{"", cachePartSuffix, cacheSegmentsSuffix} {
+		if err := os.Remove(e.Path + suffix); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+
+	// Processes waiting for the lock files notice they were removed, and
+	// create them again. Removing them fails on Windows while they are
+	// open, they are removed by the next prune.
+	for _, suffix := range []string{cacheRefSuffix, cacheLockSuffix} {
+		if err := os.Remove(e.Path + suffix); err != nil && !os.IsNotExist(err) {
+			log.Printf("[DEBUG] Error removing %s: %s", e.Path+suffix, err)
+		}
+	}
+
+	return true, nil
+}
+
+// lockEntry takes the exclusive locks of the writers and readers of the
+// entry without waiting. It returns errCacheLocked if they are held.
+func (f *FileCache) lockEntry(e *CacheEntry) ([]*os.File, error) {
+	var locks []*os.File
+	for _, suffix := range []string{cacheLockSuffix, cacheRefSuffix} {
+		l, err := openCacheFile(e.Path+suffix, true, false)
+		if err != nil {
+			for _, l := range locks {
+				l.Close()
+			}
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+
+	return locks, nil
 }
 
 func (f *FileCache) cachePath(key string, hashKey string) string {
@@ -118,3 +369,43 @@ func (f *FileCache) rwLock(hashKey string) *sync.RWMutex {
 	f.rw[hashKey] = &result
 	return &result
 }
+
+// lockCacheFile locks the lock file, waiting for other processes, and
+// logs when it has to wait.
+func lockCacheFile(path string, exclusive bool) (*os.File, error) {
+	f, err := openCacheFile(path, exclusive, false)
+	if err != errCacheLocked {
+		return f, err
+	}
+
+	log.Printf("Waiting for another Packer process to release %s", path)
+	return openCacheFile(path, exclusive, true)
+}
+
+// openCacheFile opens and locks the lock file. The file is created if it
+// doesn't exist, and opened again if it was removed by a prune while the
+// lock was being taken.
+func openCacheFile(path string, exclusive, wait bool) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := lock.Flock(f, exclusive, wait); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if pi, err := os.Stat(path); err == nil && os.SameFile(fi, pi) {
+			return f, nil
+		}
+
+		f.Close()
+	}
+}
@@ -3,8 +3,10 @@ package packer
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 type TestCache struct{}
@@ -80,3 +82,103 @@ func TestFileCache(t *testing.T) {
 		t.Fatalf("unknown data: %s", data)
 	}
 }
+
+func TestFileCache_readers(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("error creating temporary dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	cache := &FileCache{CacheDir: cacheDir}
+
+	if _, ok := cache.RLock("foo.iso"); ok {
+		t.Fatal("cache says missing key exists")
+	}
+
+	path := cache.Lock("foo.iso")
+	if err := ioutil.WriteFile(path, []byte("data"), 0666); err != nil {
+		t.Fatalf("error writing: %s", err)
+	}
+	cache.Unlock("foo.iso")
+
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 1 || entries[0].Path != path || entries[0].Size != 4 || entries[0].Partial {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+	if cache.InUse(entries[0]) {
+		t.Fatal("entry shouldn't be in use")
+	}
+
+	// Readers are counted, the entry is in use until the last one is done
+	cache.RLock("foo.iso")
+	cache.RLock("foo.iso")
+
+	// Writers don't wait for readers
+	cache.Lock("foo.iso")
+	if !cache.InUse(entries[0]) {
+		t.Fatal("entry should be in use by the writer")
+	}
+	cache.Unlock("foo.iso")
+
+	cache.RUnlock("foo.iso")
+	if removed, err := cache.Remove(entries[0]); err != nil || removed {
+		t.Fatalf("entry in use shouldn't be removed: %v", err)
+	}
+
+	cache.RUnlock("foo.iso")
+	if removed, err := cache.Remove(entries[0]); err != nil || !removed {
+		t.Fatalf("entry should be removed: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("entry should be removed: %s", err)
+	}
+	if entries, _ := cache.Entries(); len(entries) != 0 {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+}
+
+func TestFileCache_Entries(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("error creating temporary dir: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"old.iso":                   48 * time.Hour,
+		"new.iso":                   time.Hour,
+		"partial.iso.part":          2 * time.Hour,
+		"partial.iso.part.segments": 3 * time.Hour,
+	} {
+		path := filepath.Join(cacheDir, name)
+		if err := ioutil.WriteFile(path, []byte("data"), 0666); err != nil {
+			t.Fatalf("error writing: %s", err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	cache := &FileCache{CacheDir: cacheDir}
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, filepath.Base(e.Path))
+	}
+	if strings.Join(names, ",") != "old.iso,partial.iso,new.iso" {
+		t.Fatalf("bad entries: %v", names)
+	}
+	if !entries[1].Partial || entries[1].Size != 8 {
+		t.Fatalf("bad partial entry: %#v", entries[1])
+	}
+}
@@ -1,10 +1,12 @@
 package packer
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
 	"github.com/mitchellh/iochan"
@@ -40,6 +42,17 @@ type RemoteCmd struct {
 	// Once Exited is true, this will contain the exit code of the process.
 	ExitStatus int
 
+	// Reconnects is the number of times the communicator lost its
+	// connection to the machine and reconnected while the command ran.
+	// Some output of the command may be missing if it isn't zero.
+	Reconnects int
+
+	// OperationTimeout and ReceiveTimeout raise the timeouts of the
+	// communicator for this command, for long commands on slow machines.
+	// Communicators without such timeouts ignore them.
+	OperationTimeout time.Duration
+	ReceiveTimeout   time.Duration
+
 	// Internal fields
 	exitCh chan struct{}
 
@@ -88,6 +101,13 @@ type Communicator interface {
 // configured Writers for stdout/stderr, while also writing each line
 // as it comes to a Ui.
 func (r *RemoteCmd) StartWithUi(c Communicator, ui Ui) error {
+	return r.RunWithUi(context.Background(), c, ui)
+}
+
+// RunWithUi is StartWithUi that stops waiting for the command when the
+// context is cancelled and returns the error of the context. The remote
+// command isn't killed, but the caller can go on and clean up.
+func (r *RemoteCmd) RunWithUi(ctx context.Context, c Communicator, ui Ui) error {
 	stdout_r, stdout_w := io.Pipe()
 	stderr_r, stderr_w := io.Pipe()
 	defer stdout_w.Close()
@@ -117,6 +137,9 @@ func (r *RemoteCmd) StartWithUi(c Communicator, ui Ui) error {
 		r.Stderr = io.MultiWriter(r.Stderr, stderr_w)
 	}
 
+	endTiming := StartTiming(ui, "communicator", "command")
+	defer endTiming(nil)
+
 	// Start the command
 	if err := c.Start(r); err != nil {
 		return err
@@ -149,6 +172,17 @@ OutputLoop:
 			}
 		case <-exitCh:
 			break OutputLoop
+		case <-ctx.Done():
+			// Keep draining the output until the command exits
+			go func() {
+				for range stdoutCh {
+				}
+			}()
+			go func() {
+				for range stderrCh {
+				}
+			}()
+			return ctx.Err()
 		}
 	}
 
@@ -181,6 +215,15 @@ func (r *RemoteCmd) SetExited(status int) {
 	close(r.exitCh)
 }
 
+// Reconnected is called by communicators when they reconnected to the
+// machine while the command was running.
+func (r *RemoteCmd) Reconnected() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.Reconnects++
+}
+
 // Wait waits for the remote command to complete.
 func (r *RemoteCmd) Wait() {
 	// Make sure our condition variable is initialized.
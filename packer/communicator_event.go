@@ -0,0 +1,47 @@
+package packer
+
+import (
+	"log"
+)
+
+// CommunicatorMachineType is the machine readable message type of the
+// events of communicators, see CommunicatorEvent.
+const CommunicatorMachineType = "communicator"
+
+// The events of communicators.
+const (
+	// CommunicatorRetry is a failed attempt to connect to the machine.
+	CommunicatorRetry = "retry"
+
+	// CommunicatorReconnect is a lost connection that was established
+	// again.
+	CommunicatorReconnect = "reconnect"
+
+	// CommunicatorTimeout is a connection that couldn't be established
+	// in time.
+	CommunicatorTimeout = "timeout"
+)
+
+// communicatorEventMetrics are the metrics counting the events.
+var communicatorEventMetrics = map[string]string{
+	CommunicatorRetry:     MetricCommunicatorRetries,
+	CommunicatorReconnect: MetricCommunicatorReconnects,
+	CommunicatorTimeout:   MetricCommunicatorTimeouts,
+}
+
+// CommunicatorEvent logs an event of the communicator, like "ssh" or
+// "winrm", with its reason, reports it as a machine readable message and
+// counts it in the metrics of the build. It works through the Ui, so
+// plugins can use it as well. The Ui can be nil, then the event is only
+// logged.
+func CommunicatorEvent(ui Ui, event, communicator, reason string) {
+	log.Printf("[INFO] (communicator.%s) %s: %s", communicator, event, reason)
+	if ui == nil {
+		return
+	}
+
+	ui.Machine(CommunicatorMachineType, event, communicator, reason)
+	if metric, ok := communicatorEventMetrics[event]; ok {
+		AddMetric(ui, metric, 1)
+	}
+}
@@ -0,0 +1,30 @@
+package packer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCommunicatorEvent(t *testing.T) {
+	metrics := NewMetricsRegistry()
+	out := new(bytes.Buffer)
+	ui := &metricsUi{Ui: &MachineReadableUi{Writer: out}, build: "vbox", metrics: metrics}
+
+	CommunicatorEvent(ui, CommunicatorRetry, "ssh", "connection refused")
+	CommunicatorEvent(ui, CommunicatorReconnect, "winrm", "connection reset")
+	CommunicatorEvent(nil, CommunicatorTimeout, "ssh", "no connection")
+
+	if !strings.Contains(out.String(), ",communicator,retry,ssh,connection refused\n") {
+		t.Fatalf("bad: %s", out.String())
+	}
+	if !strings.Contains(out.String(), ",communicator,reconnect,winrm,connection reset\n") {
+		t.Fatalf("bad: %s", out.String())
+	}
+	if v := metrics.counters[metricKey{MetricCommunicatorRetries, "vbox"}]; v != 1 {
+		t.Fatalf("bad retries: %f", v)
+	}
+	if v := metrics.counters[metricKey{MetricCommunicatorReconnects, "vbox"}]; v != 1 {
+		t.Fatalf("bad reconnects: %f", v)
+	}
+}
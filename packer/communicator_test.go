@@ -2,6 +2,7 @@ package packer
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"testing"
 	"time"
@@ -42,6 +43,32 @@ func TestRemoteCmd_StartWithUi(t *testing.T) {
 	}
 }
 
+func TestRemoteCmd_RunWithUi_Cancel(t *testing.T) {
+	rc := &RemoteCmd{Command: "test"}
+	ui := &BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// The command never exits
+	err := rc.RunWithUi(ctx, new(startOnlyCommunicator), ui)
+	if err != context.Canceled {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+// startOnlyCommunicator starts commands that never exit.
+type startOnlyCommunicator struct {
+	MockCommunicator
+}
+
+func (c *startOnlyCommunicator) Start(*RemoteCmd) error {
+	return nil
+}
+
 func TestRemoteCmd_Wait(t *testing.T) {
 	var cmd RemoteCmd
 
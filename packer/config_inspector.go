@@ -0,0 +1,162 @@
+package packer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A ConfigInspector is a builder, provisioner or post-processor that can
+// return its configuration as it decoded it, with its defaults applied,
+// for `packer inspect`. InspectConfig is called after the component has
+// been prepared, and must not have any side effects.
+type ConfigInspector interface {
+	InspectConfig() (map[string]interface{}, error)
+}
+
+// InspectComponent returns the decoded configuration of the component. It
+// calls InspectConfig on components that implement ConfigInspector, and
+// reads the config field other components decode their configuration
+// into, keyed like the template. It returns nil if the component has
+// neither.
+func InspectComponent(raw interface{}) (map[string]interface{}, error) {
+	if i, ok := raw.(ConfigInspector); ok {
+		return i.InspectConfig()
+	}
+
+	v := reflect.ValueOf(raw)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	config := v.FieldByName("config")
+	for config.Kind() == reflect.Ptr {
+		if config.IsNil() {
+			return nil, nil
+		}
+		config = config.Elem()
+	}
+	if config.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	result := make(map[string]interface{})
+	inspectStruct(config, result, 0)
+	return result, nil
+}
+
+// inspectMaxDepth bounds the nesting of the inspected configurations, in
+// case a configuration refers to itself.
+const inspectMaxDepth = 16
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// inspectStruct adds the fields of a configuration struct to result, under
+// their mapstructure names. Squashed structs are merged in, and unexported
+// fields, which aren't decoded from the template, are left out.
+func inspectStruct(v reflect.Value, result map[string]interface{}, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		squash := false
+		for _, opt := range tag[1:] {
+			squash = squash || opt == "squash"
+		}
+		if squash {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				inspectStruct(fv, result, depth)
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if value, ok := inspectValue(fv, depth+1); ok {
+			result[name] = value
+		}
+	}
+}
+
+// inspectValue converts a value of a configuration to the types of JSON.
+// The values are read with reflection only, since the configuration is
+// usually in an unexported field. Durations are shown like in templates.
+// It returns false for values that aren't configuration, like functions.
+func inspectValue(v reflect.Value, depth int) (interface{}, bool) {
+	if depth > inspectMaxDepth {
+		return nil, false
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), true
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, true
+		}
+		return inspectValue(v.Elem(), depth)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, true
+		}
+		result := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if e, ok := inspectValue(v.Index(i), depth+1); ok {
+				result = append(result, e)
+			}
+		}
+		return result, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, true
+		}
+		result := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			key, ok := inspectValue(k, depth+1)
+			if !ok {
+				continue
+			}
+			if e, ok := inspectValue(v.MapIndex(k), depth+1); ok {
+				result[fmt.Sprint(key)] = e
+			}
+		}
+		return result, true
+	case reflect.Struct:
+		result := make(map[string]interface{})
+		inspectStruct(v, result, depth)
+		return result, true
+	default:
+		return nil, false
+	}
+}
@@ -1,11 +1,18 @@
 package packer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/lock"
+	"github.com/hashicorp/packer/helper/registry"
 	"github.com/hashicorp/packer/template"
 	"github.com/hashicorp/packer/template/interpolate"
 )
@@ -19,6 +26,37 @@ type Core struct {
 	variables  map[string]string
 	builds     map[string]*template.Builder
 	version    string
+
+	// buildVariables are the user variables of the builds expanded from
+	// a builder matrix, which include the values of their combination.
+	buildVariables map[string]map[string]string
+
+	// artifactNames are the names the naming pattern of the template
+	// gives to the artifacts of the builds.
+	artifactNames map[string]string
+
+	// dependencies are the builds whose artifacts the configuration of
+	// the builder of each build reads with "build_artifact".
+	dependencies map[string][]string
+
+	registry  registry.Registry
+	artifacts *artifactLookup
+
+	locker lock.Locker
+
+	tempDir string
+
+	// strict is set by the CoreConfig, the template can set it too.
+	strict bool
+
+	// secrets caches the values the templates read from secret stores
+	// while the core is used.
+	secrets *interpolate.SecretCache
+
+	// buildTime is the time the time functions of the templates render
+	// in the builds of the core, set when the core is created so that
+	// every run gets its own.
+	buildTime time.Time
 }
 
 // CoreConfig is the structure for initializing a new Core. Once a CoreConfig
@@ -28,6 +66,15 @@ type CoreConfig struct {
 	Template   *template.Template
 	Variables  map[string]string
 	Version    string
+
+	// TempDir is the directory the temporary directories of the builds
+	// are created in. Defaults to the directory for temporary files of
+	// the system.
+	TempDir string
+
+	// Strict rejects what is otherwise accepted with a warning or
+	// silently, like the template setting of the same name.
+	Strict bool
 }
 
 // The function type used to lookup Builder implementations.
@@ -42,6 +89,9 @@ type PostProcessorFunc func(name string) (PostProcessor, error)
 // The function type used to lookup Provisioner implementations.
 type ProvisionerFunc func(name string) (Provisioner, error)
 
+// The function type used to lookup Fixer implementations.
+type FixerFunc func(name string) (Fixer, error)
+
 // ComponentFinder is a struct that contains the various function
 // pointers necessary to look up components of Packer such as builders,
 // commands, etc.
@@ -50,6 +100,11 @@ type ComponentFinder struct {
 	Hook          HookFunc
 	PostProcessor PostProcessorFunc
 	Provisioner   ProvisionerFunc
+
+	// Fixer looks up the fixers of "packer fix" that are plugins, and
+	// FixerNames are their names.
+	Fixer      FixerFunc
+	FixerNames []string
 }
 
 // NewCore creates a new Core.
@@ -59,6 +114,10 @@ func NewCore(c *CoreConfig) (*Core, error) {
 		components: c.Components,
 		variables:  c.Variables,
 		version:    c.Version,
+		tempDir:    c.TempDir,
+		strict:     c.Strict,
+		secrets:    interpolate.NewSecretCache(),
+		buildTime:  time.Now().UTC(),
 	}
 	if err := result.validate(); err != nil {
 		return nil, err
@@ -70,7 +129,15 @@ func NewCore(c *CoreConfig) (*Core, error) {
 	// Go through and interpolate all the build names. We shuld be able
 	// to do this at this point with the variables.
 	result.builds = make(map[string]*template.Builder)
+	result.buildVariables = make(map[string]map[string]string)
 	for _, b := range c.Template.Builders {
+		if len(b.Matrix) > 0 {
+			if err := result.expandMatrix(b); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		v, err := interpolate.Render(b.Name, result.Context())
 		if err != nil {
 			return nil, fmt.Errorf(
@@ -81,9 +148,75 @@ func NewCore(c *CoreConfig) (*Core, error) {
 		result.builds[v] = b
 	}
 
+	if err := result.initNaming(); err != nil {
+		return nil, err
+	}
+
+	if err := result.initDependencies(); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
+// expandMatrix adds a build for every combination in the matrix of the
+// builder. The builds are named by interpolating the builder name with
+// the variables of their combination. If that doesn't give every build a
+// different name, the values of the combination are appended to it.
+func (c *Core) expandMatrix(b *template.Builder) error {
+	combinations := b.MatrixVariables()
+	variables := make([]map[string]string, len(combinations))
+	names := make([]string, len(combinations))
+	unique := make(map[string]struct{})
+	for i, combination := range combinations {
+		variables[i] = make(map[string]string, len(c.variables)+len(combination))
+		for k, v := range c.variables {
+			variables[i][k] = v
+		}
+		for k, v := range combination {
+			variables[i][k] = v
+		}
+
+		ctx := c.Context()
+		ctx.UserVariables = variables[i]
+		name, err := interpolate.Render(b.Name, ctx)
+		if err != nil {
+			return fmt.Errorf(
+				"Error interpolating builder '%s': %s",
+				b.Name, err)
+		}
+
+		names[i] = name
+		unique[name] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(b.Matrix))
+	for k := range b.Matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i, combination := range combinations {
+		name := names[i]
+		if len(unique) != len(combinations) {
+			for _, k := range keys {
+				name += "-" + combination[k]
+			}
+		}
+
+		if _, ok := c.builds[name]; ok {
+			return fmt.Errorf(
+				"Error expanding the matrix of builder '%s': build '%s' already exists",
+				b.Name, name)
+		}
+
+		c.builds[name] = b
+		c.buildVariables[name] = variables[i]
+	}
+
+	return nil
+}
+
 // BuildNames returns the builds that are available in this configured core.
 func (c *Core) BuildNames() []string {
 	r := make([]string, 0, len(c.builds))
@@ -95,6 +228,150 @@ func (c *Core) BuildNames() []string {
 	return r
 }
 
+// BuildDependencies returns the builds whose artifacts the build with the
+// given name uses as its source, which have to finish before it starts.
+func (c *Core) BuildDependencies(n string) []string {
+	return c.dependencies[n]
+}
+
+// OrderBuilds orders the names of builds so that every build comes after
+// the builds it depends on, keeping the order of the builds otherwise. It
+// is an error for a build to depend on a build that isn't in names.
+func (c *Core) OrderBuilds(names []string) ([]string, error) {
+	selected := make(map[string]bool, len(names))
+	for _, n := range names {
+		selected[n] = true
+	}
+
+	result := make([]string, 0, len(names))
+	added := make(map[string]bool, len(names))
+	var add func(n string) error
+	add = func(n string) error {
+		if added[n] {
+			return nil
+		}
+		for _, dep := range c.dependencies[n] {
+			if !selected[dep] {
+				return fmt.Errorf(
+					"build '%s' depends on build '%s', which isn't built", n, dep)
+			}
+			if err := add(dep); err != nil {
+				return err
+			}
+		}
+		added[n] = true
+		result = append(result, n)
+		return nil
+	}
+
+	for _, n := range names {
+		if err := add(n); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// initDependencies finds the builds every build depends on and checks
+// that the dependencies exist and have no cycle.
+func (c *Core) initDependencies() error {
+	c.dependencies = make(map[string][]string)
+	for n, b := range c.builds {
+		used := make(map[string]struct{})
+		err := walkStrings(b.Config, func(v string) error {
+			names, err := interpolate.BuildArtifactsUsed(v)
+			if err != nil {
+				// Invalid templates are reported by the builder.
+				return nil
+			}
+			for _, dep := range names {
+				if dep == n {
+					return fmt.Errorf("build '%s' can't use its own artifact", n)
+				}
+				if _, ok := c.builds[dep]; !ok {
+					return fmt.Errorf(
+						"build '%s' uses the artifact of build '%s', which doesn't exist",
+						n, dep)
+				}
+				used[dep] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(used) > 0 {
+			deps := make([]string, 0, len(used))
+			for dep := range used {
+				deps = append(deps, dep)
+			}
+			sort.Strings(deps)
+			c.dependencies[n] = deps
+		}
+	}
+
+	// Look for cycles, visiting the builds in a stable order
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int)
+	var visit func(n string, path []string) error
+	visit = func(n string, path []string) error {
+		switch marks[n] {
+		case visiting:
+			return fmt.Errorf("builds depend on each other: %s",
+				strings.Join(append(path, n), " -> "))
+		case visited:
+			return nil
+		}
+
+		marks[n] = visiting
+		for _, dep := range c.dependencies[n] {
+			if err := visit(dep, append(path, n)); err != nil {
+				return err
+			}
+		}
+		marks[n] = visited
+		return nil
+	}
+	for _, n := range c.BuildNames() {
+		if err := visit(n, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkStrings calls f with every string within the raw configuration v.
+func walkStrings(v interface{}, f func(string) error) error {
+	switch v := v.(type) {
+	case string:
+		return f(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := walkStrings(v[k], f); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := walkStrings(e, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Build returns the Build object for the given name.
 func (c *Core) Build(n string) (Build, error) {
 	// Setup the builder
@@ -145,10 +422,40 @@ func (c *Core) Build(n string) (Build, error) {
 			}
 		}
 
+		// Wrap the provisioner in the behavior requested by the template.
+		// The order matters: the timeout applies to every attempt, the
+		// output file covers all attempts and pauses wrap everything.
+		if rawP.Timeout > 0 {
+			provisioner = &TimeoutProvisioner{
+				Timeout:     rawP.Timeout,
+				Provisioner: provisioner,
+			}
+		}
+		if rawP.MaxRetries > 0 {
+			provisioner = &RetriedProvisioner{
+				MaxRetries:  rawP.MaxRetries,
+				Provisioner: provisioner,
+			}
+		}
+		if rawP.OutputFile != "" {
+			path, err := interpolate.Render(rawP.OutputFile, c.buildContext(n))
+			if err != nil {
+				return nil, fmt.Errorf(
+					"error interpolating output_file of provisioner '%s': %s",
+					rawP.Type, err)
+			}
+
+			provisioner = &OutputFileProvisioner{
+				Path:        path,
+				Provisioner: provisioner,
+			}
+		}
+
 		// If we're pausing, we wrap the provisioner in a special pauser.
-		if rawP.PauseBefore > 0 {
+		if rawP.PauseBefore > 0 || rawP.PauseAfter > 0 {
 			provisioner = &PausedProvisioner{
 				PauseBefore: rawP.PauseBefore,
+				PauseAfter:  rawP.PauseAfter,
 				Provisioner: provisioner,
 			}
 		}
@@ -157,6 +464,7 @@ func (c *Core) Build(n string) (Build, error) {
 			pType:       rawP.Type,
 			provisioner: provisioner,
 			config:      config,
+			checkpoint:  rawP.Checkpoint,
 		})
 	}
 
@@ -182,10 +490,17 @@ func (c *Core) Build(n string) (Build, error) {
 					"post-processor type not found: %s", rawP.Type)
 			}
 
+			config, err := c.enforceName(n,
+				fmt.Sprintf("post-processor '%s'", rawP.Type),
+				PostProcessorNameKeys[rawP.Type], rawP.Config)
+			if err != nil {
+				return nil, err
+			}
+
 			current = append(current, coreBuildPostProcessor{
 				processor:         postProcessor,
 				processorType:     rawP.Type,
-				config:            rawP.Config,
+				config:            config,
 				keepInputArtifact: rawP.KeepInputArtifact,
 			})
 		}
@@ -200,24 +515,129 @@ func (c *Core) Build(n string) (Build, error) {
 
 	// TODO hooks one day
 
+	// Setup the notifications
+	var buildNotifier *notifier
+	if len(c.Template.Notifications) > 0 {
+		buildNotifier = newNotifier(c.Template.Notifications, *c.buildContext(n))
+	}
+
+	// Setup the artifact registry metadata, which may refer to the build
+	var registryMetadata map[string]string
+	if c.registry != nil && len(c.Template.ArtifactRegistry.Metadata) > 0 {
+		ctx := c.buildContext(n)
+		registryMetadata = make(map[string]string)
+		for k, v := range c.Template.ArtifactRegistry.Metadata {
+			rendered, err := interpolate.Render(v, ctx)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"error interpolating artifact registry metadata '%s': %s",
+					k, err)
+			}
+
+			registryMetadata[k] = rendered
+		}
+	}
+
+	// The name of the build lock may refer to the build
+	var lockName string
+	var lockTimeout time.Duration
+	if configBuilder.Lock != "" {
+		lockTimeout = c.Template.BuildLock.Timeout
+		lockName, err = interpolate.Render(configBuilder.Lock, c.buildContext(n))
+		if err != nil {
+			return nil, fmt.Errorf(
+				"error interpolating lock of builder '%s': %s", rawName, err)
+		}
+	}
+
+	builderConfig, err := c.enforceName(n,
+		fmt.Sprintf("builder '%s'", rawName),
+		BuilderNameKeys[configBuilder.Type], configBuilder.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the artifacts the components use with latest_artifact
+	configs := []interface{}{builderConfig}
+	for _, p := range provisioners {
+		configs = append(configs, p.config...)
+	}
+	for _, ps := range postProcessors {
+		for _, p := range ps {
+			configs = append(configs, p.config)
+		}
+	}
+	latestArtifacts, err := c.resolveLatestArtifacts(n, configs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var templateHash string
+	if len(c.Template.RawContents) > 0 {
+		sum := sha256.Sum256(c.Template.RawContents)
+		templateHash = hex.EncodeToString(sum[:])
+	}
+
 	return &coreBuild{
 		name:           n,
+		artifactName:   c.artifactNames[n],
 		builder:        builder,
-		builderConfig:  configBuilder.Config,
+		builderConfig:  builderConfig,
 		builderType:    configBuilder.Type,
+		notifier:       buildNotifier,
 		postProcessors: postProcessors,
 		provisioners:   provisioners,
 		templatePath:   c.Template.Path,
-		variables:      c.variables,
+		variables:      c.buildUserVariables(n),
+
+		registry:         c.registry,
+		latestArtifacts:  latestArtifacts,
+		registryMetadata: registryMetadata,
+		templateHash:     templateHash,
+
+		locker:      c.locker,
+		lockName:    lockName,
+		lockTimeout: lockTimeout,
+
+		buildTime: c.buildTime,
+		tempRoot:  c.tempDir,
 	}, nil
 }
 
+// buildUserVariables returns the user variables of the build with the
+// given name.
+func (c *Core) buildUserVariables(n string) map[string]string {
+	if variables, ok := c.buildVariables[n]; ok {
+		return variables
+	}
+	return c.variables
+}
+
+// buildContext returns the interpolation context of the build with the
+// given name.
+func (c *Core) buildContext(n string) *interpolate.Context {
+	ctx := c.Context()
+	ctx.UserVariables = c.buildUserVariables(n)
+	ctx.BuildName = n
+	ctx.ArtifactName = c.artifactNames[n]
+	if b, ok := c.builds[n]; ok {
+		ctx.BuildType = b.Type
+	}
+	return ctx
+}
+
 // Context returns an interpolation context.
 func (c *Core) Context() *interpolate.Context {
-	return &interpolate.Context{
+	ctx := &interpolate.Context{
 		TemplatePath:  c.Template.Path,
 		UserVariables: c.variables,
+		Secrets:       c.secrets,
+		BuildTime:     c.buildTime,
+	}
+	if c.artifacts != nil {
+		ctx.LatestArtifacts = c.artifacts
 	}
+	return ctx
 }
 
 // validate does a full validation of the template.
@@ -258,12 +678,21 @@ func (c *Core) validate() error {
 	for n, v := range c.Template.Variables {
 		if v.Required {
 			if _, ok := c.variables[n]; !ok {
+				if v.Description != "" {
+					n = fmt.Sprintf("%s (%s)", n, v.Description)
+				}
 				err = multierror.Append(err, fmt.Errorf(
 					"required variable not set: %s", n))
 			}
 		}
 	}
 
+	if c.strict || c.Template.Strict {
+		if errs := c.validateStrict(); len(errs) > 0 {
+			err = multierror.Append(err, errs...)
+		}
+	}
+
 	// TODO: validate all builders exist
 	// TODO: ^^ provisioner
 	// TODO: ^^ post-processor
@@ -271,6 +700,80 @@ func (c *Core) validate() error {
 	return err
 }
 
+// validateStrict does the checks of strict mode, which rejects what is
+// otherwise accepted: variables the template doesn't declare, deprecated
+// keys and the keys Packer reserves for the ones it sets. Components
+// always reject the keys they don't know.
+func (c *Core) validateStrict() []error {
+	var errs []error
+
+	names := make([]string, 0, len(c.variables))
+	for n := range c.variables {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if _, ok := c.Template.Variables[n]; !ok {
+			errs = append(errs, fmt.Errorf(
+				"variable '%s' is set but not declared in the template", n))
+		}
+	}
+
+	check := func(kind, typ, where string, raw map[string]interface{}) {
+		keys := make([]string, 0, len(raw))
+		for k := range raw {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if strings.HasPrefix(k, "packer_") {
+				errs = append(errs, fmt.Errorf(
+					"%s: %q is reserved for the keys Packer sets", where, k))
+			}
+		}
+
+		for _, w := range config.GetDeprecations(kind, typ).Warnings(raw) {
+			errs = append(errs, fmt.Errorf("%s: %s", where, w))
+		}
+	}
+
+	builders := make([]string, 0, len(c.Template.Builders))
+	for n := range c.Template.Builders {
+		builders = append(builders, n)
+	}
+	sort.Strings(builders)
+	for _, n := range builders {
+		b := c.Template.Builders[n]
+		check(config.KindBuilder, b.Type, fmt.Sprintf("builder '%s'", n), b.Config)
+	}
+
+	for i, p := range c.Template.Provisioners {
+		where := fmt.Sprintf("provisioner %d (%s)", i+1, p.Type)
+		check(config.KindProvisioner, p.Type, where, p.Config)
+
+		overrides := make([]string, 0, len(p.Override))
+		for n := range p.Override {
+			overrides = append(overrides, n)
+		}
+		sort.Strings(overrides)
+		for _, n := range overrides {
+			if raw, ok := p.Override[n].(map[string]interface{}); ok {
+				check(config.KindProvisioner, p.Type,
+					fmt.Sprintf("%s, override '%s'", where, n), raw)
+			}
+		}
+	}
+
+	for i, seq := range c.Template.PostProcessors {
+		for j, pp := range seq {
+			where := fmt.Sprintf("post-processor %d.%d (%s)", i+1, j+1, pp.Type)
+			check(config.KindPostProcessor, pp.Type, where, pp.Config)
+		}
+	}
+
+	return errs
+}
+
 func (c *Core) init() error {
 	if c.variables == nil {
 		c.variables = make(map[string]string)
@@ -302,10 +805,89 @@ func (c *Core) init() error {
 		c.variables[k] = def
 	}
 
+	// Check the values against the declarations of the variables, so that
+	// a wrong value fails here rather than in the middle of a build
+	if err := c.checkVariables(); err != nil {
+		return err
+	}
+
+	// Setup the artifact registry. This happens after the variables are
+	// known so its configuration can use them, which also means that the
+	// variable defaults can't use latest_artifact.
+	if r := c.Template.ArtifactRegistry; r != nil {
+		config, err := interpolate.RenderMap(r.Config, c.Context(), nil)
+		if err != nil {
+			return fmt.Errorf("Error interpolating 'artifact_registry': %s", err)
+		}
+		if config == nil {
+			config = make(map[string]interface{})
+		}
+		config["type"] = r.Type
+
+		c.registry, err = registry.New(config)
+		if err != nil {
+			return err
+		}
+		c.artifacts = newArtifactLookup(c.registry)
+	}
+
+	// Setup the backend of the build locks
+	if l := c.Template.BuildLock; l != nil {
+		config, err := interpolate.RenderMap(l.Config, c.Context(), nil)
+		if err != nil {
+			return fmt.Errorf("Error interpolating 'build_lock': %s", err)
+		}
+		if config == nil {
+			config = make(map[string]interface{})
+		}
+		config["type"] = l.Type
+
+		c.locker, err = lock.New(config)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Interpolate the push configuration
 	if _, err := interpolate.RenderInterface(&c.Template.Push, c.Context()); err != nil {
 		return fmt.Errorf("Error interpolating 'push': %s", err)
 	}
 
+	// Interpolate the required plugins so sources can be relative to
+	// the template directory.
+	for name, p := range c.Template.RequiredPlugins {
+		if _, err := interpolate.RenderInterface(p, c.Context()); err != nil {
+			return fmt.Errorf("Error interpolating required plugin '%s': %s", name, err)
+		}
+	}
+
 	return nil
 }
+
+// checkVariables checks the values of the variables against their types
+// and validation rules, and normalizes them.
+func (c *Core) checkVariables() error {
+	names := make([]string, 0, len(c.Template.Variables))
+	for n := range c.Template.Variables {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var err error
+	for _, n := range names {
+		value, ok := c.variables[n]
+		if !ok {
+			continue
+		}
+
+		checked, cerr := c.Template.Variables[n].Check(value)
+		if cerr != nil {
+			err = multierror.Append(err, fmt.Errorf(
+				"invalid value for variable '%s': %s", n, cerr))
+			continue
+		}
+		c.variables[n] = checked
+	}
+
+	return err
+}
@@ -1,12 +1,16 @@
 package packer
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	configHelper "github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/registry"
 	"github.com/hashicorp/packer/template"
 )
 
@@ -27,6 +31,12 @@ func TestCoreBuildNames(t *testing.T) {
 			nil,
 			[]string{"TUBES"},
 		},
+
+		{
+			"build-names-matrix.json",
+			nil,
+			[]string{"linux-eu-west-1", "linux-us-east-1", "windows-2012", "windows-2016"},
+		},
 	}
 
 	for _, tc := range cases {
@@ -142,6 +152,119 @@ func TestCoreBuild_env(t *testing.T) {
 	}
 }
 
+func TestCoreBuild_matrix(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-matrix.json"))
+	b := TestBuilder(t, config, "test")
+	core := TestCore(t, config)
+
+	build, err := core.Build("windows-2016-eu-west-1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Interpolate the config
+	var result map[string]interface{}
+	err = configHelper.Decode(&result, nil, b.PrepareConfig...)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The matrix values override the variables of the template
+	if result["value"] != "2016 eu-west-1 large" {
+		t.Fatalf("bad: %#v", result)
+	}
+	if len(core.BuildNames()) != 4 {
+		t.Fatalf("bad: %#v", core.BuildNames())
+	}
+}
+
+func TestCoreBuild_lock(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-lock.json"))
+	TestBuilder(t, config, "test")
+	core := TestCore(t, config)
+
+	b, err := core.Build("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	build := b.(*coreBuild)
+	if build.locker == nil {
+		t.Fatal("should have a locker")
+	}
+	if build.lockName != "template-us-east-1" || build.lockTimeout != 10*time.Minute {
+		t.Fatalf("bad: %s %s", build.lockName, build.lockTimeout)
+	}
+}
+
+func TestCoreBuild_latestArtifact(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	r, err := registry.New(map[string]interface{}{"type": "file", "directory": td})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = r.Record(&registry.Entry{
+		Name: "base",
+		Artifacts: []registry.Artifact{
+			{BuilderId: "foo", Id: "foo-id"},
+			{BuilderId: "bar", Id: "bar-id"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-latest-artifact.json"))
+	config.Variables = map[string]string{"registry": td}
+	b := TestBuilder(t, config, "test")
+	p := TestProvisioner(t, config, "test")
+	core := TestCore(t, config)
+
+	build, err := core.Build("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The components only get the artifacts, not the registry
+	expected := map[string]map[string]string{"base": {"": "foo-id", "bar": "bar-id"}}
+	for _, raws := range [][]interface{}{b.PrepareConfig, p.PrepConfigs} {
+		packerConfig := raws[len(raws)-1].(map[string]interface{})
+		if !reflect.DeepEqual(packerConfig[LatestArtifactsConfigKey], expected) {
+			t.Fatalf("bad: %#v", packerConfig)
+		}
+	}
+
+	var result map[string]interface{}
+	if err := configHelper.Decode(&result, nil, p.PrepConfigs...); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result["value"] != "bar-id" {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// Builds fail early if an artifact is missing
+	config.Variables["base"] = "other"
+	core = TestCore(t, config)
+	if _, err := core.Build("test"); err == nil || !strings.Contains(err.Error(), "no build named 'other'") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
 func TestCoreBuild_buildNameVar(t *testing.T) {
 	config := TestCoreConfig(t)
 	testCoreTemplate(t, config, fixtureDir("build-var-build-name.json"))
@@ -200,6 +323,64 @@ func TestCoreBuild_buildTypeVar(t *testing.T) {
 	}
 }
 
+func TestCoreBuild_dependencies(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-dependencies.json"))
+	b := TestBuilder(t, config, "test")
+	core := TestCore(t, config)
+
+	if deps := core.BuildDependencies("role"); !reflect.DeepEqual(deps, []string{"base"}) {
+		t.Fatalf("bad: %#v", deps)
+	}
+	if deps := core.BuildDependencies("base"); len(deps) != 0 {
+		t.Fatalf("bad: %#v", deps)
+	}
+
+	order, err := core.OrderBuilds([]string{"app", "base", "role"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(order, []string{"base", "role", "app"}) {
+		t.Fatalf("bad: %#v", order)
+	}
+	if _, err := core.OrderBuilds([]string{"app", "role"}); err == nil {
+		t.Fatal("should error without the build a build depends on")
+	}
+
+	build, err := core.Build("role")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	build.SetBuildArtifacts(map[string]string{"base": "base-id"})
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var result map[string]interface{}
+	err = configHelper.Decode(&result, nil, b.PrepareConfig...)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result["source"] != "base-id" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestCore_dependencyErrors(t *testing.T) {
+	cases := []string{
+		"build-dependencies-cycle.json",
+		"build-dependencies-unknown.json",
+	}
+
+	for _, tc := range cases {
+		config := TestCoreConfig(t)
+		testCoreTemplate(t, config, fixtureDir(tc))
+		if _, err := NewCore(config); err == nil {
+			t.Fatalf("%s: should error", tc)
+		}
+	}
+}
+
 func TestCoreBuild_nonExist(t *testing.T) {
 	config := TestCoreConfig(t)
 	testCoreTemplate(t, config, fixtureDir("build-basic.json"))
@@ -426,6 +607,65 @@ func TestCoreBuild_templatePath(t *testing.T) {
 	}
 }
 
+func TestCoreBuild_naming(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-naming.json"))
+	b := TestBuilder(t, config, "test")
+	core := TestCore(t, config)
+
+	build, err := core.Build("centos")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Interpolate the config
+	var result map[string]interface{}
+	err = configHelper.Decode(&result, nil, b.PrepareConfig...)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result["value"] != "build-naming-centos-1.2" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestCoreBuild_namingEnforced(t *testing.T) {
+	BuilderNameKeys["test"] = "image_name"
+	defer delete(BuilderNameKeys, "test")
+
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-naming-enforced.json"))
+	b := TestBuilder(t, config, "test")
+	core := TestCore(t, config)
+
+	build, err := core.Build("enforced")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var result map[string]interface{}
+	err = configHelper.Decode(&result, nil, b.PrepareConfig...)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result["image_name"] != "web-enforced" {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// The name setting can't be set when the pattern is enforced
+	if _, err := core.Build("overridden"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestCore_pushInterpolate(t *testing.T) {
 	cases := []struct {
 		File   string
@@ -461,6 +701,9 @@ func TestCore_pushInterpolate(t *testing.T) {
 }
 
 func TestCoreValidate(t *testing.T) {
+	os.Setenv("PACKER_TEST_DEBUG", "1")
+	defer os.Unsetenv("PACKER_TEST_DEBUG")
+
 	cases := []struct {
 		File string
 		Vars map[string]string
@@ -485,6 +728,31 @@ func TestCoreValidate(t *testing.T) {
 			false,
 		},
 
+		// Typed variables
+		{
+			"validate-typed-variables.json",
+			map[string]string{"region": "us-east-1"},
+			false,
+		},
+
+		{
+			"validate-typed-variables.json",
+			map[string]string{"region": "us-east-1", "count": "10"},
+			true,
+		},
+
+		{
+			"validate-typed-variables.json",
+			map[string]string{"region": "the moon"},
+			true,
+		},
+
+		{
+			"validate-typed-variables.json",
+			map[string]string{"region": "us-east-1", "debug": "maybe"},
+			true,
+		},
+
 		// Min version good
 		{
 			"validate-min-version.json",
@@ -497,6 +765,19 @@ func TestCoreValidate(t *testing.T) {
 			map[string]string{"foo": "bar"},
 			true,
 		},
+
+		// Strict mode rejects undeclared variables
+		{
+			"validate-strict.json",
+			map[string]string{"foo": "bar"},
+			false,
+		},
+
+		{
+			"validate-strict.json",
+			map[string]string{"fo": "bar"},
+			true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -522,6 +803,67 @@ func TestCoreValidate(t *testing.T) {
 	}
 }
 
+func TestCoreValidate_strict(t *testing.T) {
+	configHelper.RegisterDeprecations(configHelper.KindProvisioner, "strict-deprecated",
+		configHelper.Deprecations{{Key: "old", NewKey: "new"}})
+
+	c := &CoreConfig{
+		Variables: map[string]string{"unused": "foo"},
+		Version:   "1.0.0",
+	}
+	testCoreTemplate(t, c, fixtureDir("validate-strict-keys.json"))
+	if _, err := NewCore(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.Strict = true
+	_, err := NewCore(c)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	for _, expected := range []string{
+		"variable 'unused' is set but not declared in the template",
+		`builder 'test': "packer_force" is reserved for the keys Packer sets`,
+		`provisioner 1 (strict-deprecated): "old" is deprecated, use "new" instead`,
+		`provisioner 1 (strict-deprecated), override 'test': "old" is deprecated`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("error should contain %q: %s", expected, err)
+		}
+	}
+}
+
+func TestCore_typedVariables(t *testing.T) {
+	os.Setenv("PACKER_TEST_DEBUG", "1")
+	defer os.Unsetenv("PACKER_TEST_DEBUG")
+
+	config := &CoreConfig{
+		Variables: map[string]string{"region": "us-east-1", "count": "3.0"},
+		Version:   "1.0.0",
+	}
+	testCoreTemplate(t, config, fixtureDir("validate-typed-variables.json"))
+	core, err := NewCore(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"count":  "3.0",
+		"debug":  "true",
+		"region": "us-east-1",
+	}
+	if !reflect.DeepEqual(core.variables, expected) {
+		t.Fatalf("bad: %#v", core.variables)
+	}
+
+	// The error message of the rule replaces the default one
+	config.Variables["region"] = "moon"
+	_, err = NewCore(config)
+	if err == nil || !strings.Contains(err.Error(), "region must be like us-east-1") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
 func testComponentFinder() *ComponentFinder {
 	builderFactory := func(n string) (Builder, error) { return new(MockBuilder), nil }
 	ppFactory := func(n string) (PostProcessor, error) { return new(MockPostProcessor), nil }
@@ -0,0 +1,200 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// developFilePrefix starts the strings that provisioners replace with the
+// contents of a file when they are prepared, like the inline commands of
+// the powershell provisioner.
+const developFilePrefix = "@file:"
+
+// DevelopHook is a Hook that runs the provisioners, then keeps the
+// machine running and runs a provisioner again whenever one of the local
+// files it uses changes, until it is cancelled. It is used by
+// `packer develop` to iterate on provisioning scripts against a live
+// machine.
+type DevelopHook struct {
+	ProvisionHook
+
+	// Files are the local files and directories each provisioner uses.
+	Files [][]string
+
+	// Prepare prepares the provisioner with the index again before it
+	// runs again, so that it reads the files it inlined while being
+	// prepared. It can be nil.
+	Prepare func(i int) error
+
+	// Interval is how often the files are checked, every second by
+	// default.
+	Interval time.Duration
+
+	stopLock sync.Mutex
+	stop     chan struct{}
+	stopped  bool
+}
+
+func (h *DevelopHook) Run(name string, ui Ui, comm Communicator, data interface{}) error {
+	if len(h.Provisioners) == 0 || comm == nil {
+		return h.ProvisionHook.Run(name, ui, comm, data)
+	}
+
+	stop := h.stopCh()
+	interval := h.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	// Failed provisioners don't end the session, they are fixed and
+	// run again
+	fingerprints := h.fingerprints()
+	if err := h.ProvisionHook.Run(name, ui, comm, data); err != nil && err != context.Canceled {
+		ui.Error(fmt.Sprintf("Provisioning failed: %s", err))
+	}
+
+	ui.Say("Watching the files of the provisioners. Press Ctrl-C to stop and clean up...")
+	for {
+		select {
+		case <-stop:
+			return context.Canceled
+		case <-time.After(interval):
+		}
+
+		current := h.fingerprints()
+		for i := range h.Provisioners {
+			if current[i] == fingerprints[i] {
+				continue
+			}
+
+			ui.Say(fmt.Sprintf("The files of provisioner %d (%s) changed, running it again...",
+				i+1, h.ProvisionerTypes[i]))
+			if h.Prepare != nil {
+				if err := h.Prepare(i); err != nil {
+					ui.Error(fmt.Sprintf("Provisioner %d (%s) failed: %s",
+						i+1, h.ProvisionerTypes[i], err))
+					continue
+				}
+			}
+			err := h.ProvisionHook.Run(name, ui, comm, ProvisionRangeData(i, i+1))
+			select {
+			case <-stop:
+				return context.Canceled
+			default:
+			}
+			if err != nil {
+				ui.Error(fmt.Sprintf("Provisioner %d (%s) failed: %s",
+					i+1, h.ProvisionerTypes[i], err))
+			} else {
+				ui.Say(fmt.Sprintf("Provisioner %d (%s) finished.", i+1, h.ProvisionerTypes[i]))
+			}
+		}
+		fingerprints = current
+	}
+}
+
+// Cancel stops watching the files and cancels the running provisioner.
+func (h *DevelopHook) Cancel() {
+	stop := h.stopCh()
+	h.stopLock.Lock()
+	if !h.stopped {
+		h.stopped = true
+		close(stop)
+	}
+	h.stopLock.Unlock()
+
+	h.ProvisionHook.Cancel()
+}
+
+func (h *DevelopHook) stopCh() chan struct{} {
+	h.stopLock.Lock()
+	defer h.stopLock.Unlock()
+
+	if h.stop == nil {
+		h.stop = make(chan struct{})
+	}
+	return h.stop
+}
+
+// fingerprints returns, for each provisioner, the sizes and modification
+// times of its files, which change when the files are saved.
+func (h *DevelopHook) fingerprints() []string {
+	result := make([]string, len(h.Provisioners))
+	for i := range h.Provisioners {
+		if i >= len(h.Files) {
+			continue
+		}
+
+		var b bytes.Buffer
+		for _, f := range h.Files[i] {
+			err := filepath.Walk(f, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					fmt.Fprintf(&b, "%s missing\n", path)
+					return nil
+				}
+				if !info.IsDir() {
+					fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+				}
+				return nil
+			})
+			if err != nil {
+				log.Printf("Error checking %s: %s", f, err)
+			}
+		}
+		result[i] = b.String()
+	}
+	return result
+}
+
+// developFiles returns the local files and directories a provisioner
+// uses, which are the strings in its configuration that are paths of
+// existing files, also after developFilePrefix. Directories only count
+// with relative paths, absolute ones are usually paths on the machine that
+// happen to exist locally.
+func developFiles(configs []interface{}) []string {
+	var files []string
+	seen := make(map[string]bool)
+
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			v = strings.TrimPrefix(v, developFilePrefix)
+			if seen[v] || v == "" || v == "." || v == ".." {
+				return
+			}
+			fi, err := os.Stat(v)
+			if err != nil || (fi.IsDir() && filepath.IsAbs(v)) {
+				return
+			}
+			seen[v] = true
+			files = append(files, v)
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case []string:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	for _, c := range configs {
+		walk(c)
+	}
+
+	sort.Strings(files)
+	return files
+}
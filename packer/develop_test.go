@@ -0,0 +1,133 @@
+package packer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDevelopHook_impl(t *testing.T) {
+	var _ Hook = new(DevelopHook)
+}
+
+func TestDevelopHook(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	script := filepath.Join(td, "script.sh")
+	if err := ioutil.WriteFile(script, []byte("echo one"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	runs := make(chan string, 10)
+	pA := &MockProvisioner{ProvFunc: func(context.Context) error {
+		runs <- "a"
+		return nil
+	}}
+	pB := &MockProvisioner{ProvFunc: func(context.Context) error {
+		runs <- "b"
+		return nil
+	}}
+
+	prepared := make(chan int, 10)
+	hook := &DevelopHook{
+		ProvisionHook: ProvisionHook{
+			Provisioners:     []Provisioner{pA, pB},
+			ProvisionerTypes: []string{"a", "b"},
+		},
+		Files: [][]string{{script}, nil},
+		Prepare: func(i int) error {
+			prepared <- i
+			return nil
+		},
+		Interval: 10 * time.Millisecond,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hook.Run(HookProvision, testUi(), new(MockCommunicator), nil)
+	}()
+
+	expectRun := func(expected string) {
+		select {
+		case name := <-runs:
+			if name != expected {
+				t.Fatalf("expected %s to run, not %s", expected, name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s should run", expected)
+		}
+	}
+	expectRun("a")
+	expectRun("b")
+
+	// Only the provisioner of the changed file runs again, after being
+	// prepared again
+	if err := ioutil.WriteFile(script, []byte("echo two, longer"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expectRun("a")
+	if len(prepared) != 1 || <-prepared != 0 {
+		t.Fatal("should prepare the provisioner again")
+	}
+
+	hook.Cancel()
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Fatalf("bad: %#v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("should stop")
+	}
+	if len(runs) > 0 {
+		t.Fatalf("unexpected run: %s", <-runs)
+	}
+}
+
+func TestDevelopFiles(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	script := filepath.Join(td, "script.sh")
+	if err := ioutil.WriteFile(script, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	configs := []interface{}{
+		map[string]interface{}{
+			"scripts":     []interface{}{script, "missing.sh"},
+			"inline":      []interface{}{"echo hello"},
+			"destination": td,
+		},
+		map[string]interface{}{
+			"script": script,
+		},
+	}
+
+	files := developFiles(configs)
+	if !reflect.DeepEqual(files, []string{script}) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	// Files read by the provisioner when it is prepared
+	configs = []interface{}{
+		map[string]interface{}{
+			"inline": []interface{}{"@file:" + script},
+		},
+	}
+	files = developFiles(configs)
+	if !reflect.DeepEqual(files, []string{script}) {
+		t.Fatalf("bad: %#v", files)
+	}
+}
@@ -0,0 +1,15 @@
+package packer
+
+// A Fixer fixes the backwards incompatibilities of templates for "packer
+// fix". Fixers are built into Packer, or are plugins for the templates
+// using other plugins.
+type Fixer interface {
+	// Fix takes the raw map structure of a template, potentially
+	// transforms it in some way, and returns the new, transformed
+	// structure. The Fix method is allowed to mutate the input.
+	Fix(input map[string]interface{}) (map[string]interface{}, error)
+
+	// Synopsis returns a string description of what the fixer actually
+	// does.
+	Synopsis() string
+}
@@ -0,0 +1,26 @@
+package packer
+
+// MockFixer is an implementation of Fixer that can be used for tests.
+type MockFixer struct {
+	FixCalled bool
+	FixInput  map[string]interface{}
+	FixOutput map[string]interface{}
+	FixError  error
+
+	SynopsisValue string
+}
+
+func (f *MockFixer) Fix(input map[string]interface{}) (map[string]interface{}, error) {
+	f.FixCalled = true
+	f.FixInput = input
+
+	output := f.FixOutput
+	if output == nil {
+		output = input
+	}
+	return output, f.FixError
+}
+
+func (f *MockFixer) Synopsis() string {
+	return f.SynopsisValue
+}
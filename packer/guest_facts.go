@@ -0,0 +1,135 @@
+package packer
+
+import (
+	"strings"
+)
+
+// GuestFacts are facts about the operating system of the machine being
+// built. Builders and communicators publish what they know about it so
+// that provisioners don't have to guess. Facts that aren't known are
+// empty.
+type GuestFacts struct {
+	// OSFamily is the family of the operating system, named like GOOS:
+	// "windows", "linux", "darwin" or "freebsd".
+	OSFamily string
+
+	// OSVersion is the version of the operating system, as reported by
+	// the builder.
+	OSVersion string
+
+	// Arch is the architecture of the machine, named like GOARCH: "amd64",
+	// "386" or "arm64".
+	Arch string
+
+	// TempDir is a directory where provisioners can upload temporary
+	// files.
+	TempDir string
+
+	// Shell is the shell running the commands of the communicator:
+	// "powershell", "cmd" or "sh".
+	Shell string
+}
+
+// Merge sets the facts that are unknown from other.
+func (f *GuestFacts) Merge(other *GuestFacts) {
+	if other == nil {
+		return
+	}
+	if f.OSFamily == "" {
+		f.OSFamily = other.OSFamily
+	}
+	if f.OSVersion == "" {
+		f.OSVersion = other.OSVersion
+	}
+	if f.Arch == "" {
+		f.Arch = other.Arch
+	}
+	if f.TempDir == "" {
+		f.TempDir = other.TempDir
+	}
+	if f.Shell == "" {
+		f.Shell = other.Shell
+	}
+}
+
+// IsWindows returns true if the guest is known to run Windows.
+func (f *GuestFacts) IsWindows() bool {
+	return f.OSFamily == "windows"
+}
+
+// GuestFactsCommunicator is implemented by communicators that publish
+// facts about the guest.
+type GuestFactsCommunicator interface {
+	Communicator
+
+	GuestFacts() *GuestFacts
+}
+
+// GuestFactsOf returns the facts published by the communicator. They are
+// all unknown if it doesn't publish any.
+func GuestFactsOf(comm Communicator) *GuestFacts {
+	facts := new(GuestFacts)
+	if c, ok := comm.(GuestFactsCommunicator); ok {
+		facts.Merge(c.GuestFacts())
+	}
+	return facts
+}
+
+// WithGuestFacts returns a communicator that publishes the facts, in
+// addition to the ones published by comm.
+func WithGuestFacts(comm Communicator, facts *GuestFacts) Communicator {
+	if comm == nil || facts == nil {
+		return comm
+	}
+	return &guestFactsCommunicator{Communicator: comm, facts: facts}
+}
+
+type guestFactsCommunicator struct {
+	Communicator
+	facts *GuestFacts
+}
+
+func (c *guestFactsCommunicator) GuestFacts() *GuestFacts {
+	facts := *c.facts
+	facts.Merge(GuestFactsOf(c.Communicator))
+	return &facts
+}
+
+// GuestFactsForOSType guesses the facts from the type of guest OS given to
+// a hypervisor, like the guest_os_type of the VirtualBox and VMware
+// builders, e.g. "Ubuntu_64", "Windows2012_64" or "windows9srv-64".
+func GuestFactsForOSType(osType string) *GuestFacts {
+	facts := new(GuestFacts)
+	t := strings.ToLower(osType)
+
+	switch {
+	case strings.HasPrefix(t, "win"):
+		facts.OSFamily = "windows"
+	case strings.Contains(t, "freebsd"):
+		facts.OSFamily = "freebsd"
+	case strings.HasPrefix(t, "mac") || strings.HasPrefix(t, "darwin"):
+		facts.OSFamily = "darwin"
+	default:
+		for _, distro := range []string{
+			"linux", "ubuntu", "debian", "redhat", "rhel", "centos", "fedora",
+			"suse", "sles", "oracle", "gentoo", "mandriva", "turbolinux", "xandros",
+			"coreos",
+		} {
+			if strings.Contains(t, distro) {
+				facts.OSFamily = "linux"
+				break
+			}
+		}
+	}
+
+	switch {
+	case strings.Contains(t, "arm64") || strings.Contains(t, "aarch64"):
+		facts.Arch = "arm64"
+	case strings.HasSuffix(t, "64"):
+		facts.Arch = "amd64"
+	case facts.OSFamily != "":
+		facts.Arch = "386"
+	}
+
+	return facts
+}
@@ -0,0 +1,47 @@
+package packer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGuestFactsOf(t *testing.T) {
+	comm := new(MockCommunicator)
+	if facts := GuestFactsOf(comm); !reflect.DeepEqual(facts, new(GuestFacts)) {
+		t.Fatalf("bad: %#v", facts)
+	}
+
+	// Facts added to a communicator complete the ones it publishes.
+	inner := WithGuestFacts(comm, &GuestFacts{OSFamily: "linux", Shell: "sh"})
+	outer := WithGuestFacts(inner, &GuestFacts{TempDir: "/var/tmp", Shell: "bash"})
+	expected := &GuestFacts{OSFamily: "linux", TempDir: "/var/tmp", Shell: "bash"}
+	if facts := GuestFactsOf(outer); !reflect.DeepEqual(facts, expected) {
+		t.Fatalf("bad: %#v", facts)
+	}
+}
+
+func TestGuestFactsForOSType(t *testing.T) {
+	cases := []struct {
+		OSType   string
+		OSFamily string
+		Arch     string
+	}{
+		{"Ubuntu_64", "linux", "amd64"},
+		{"RedHat", "linux", "386"},
+		{"Windows2012_64", "windows", "amd64"},
+		{"windows9srv-64", "windows", "amd64"},
+		{"other26xlinux-64", "linux", "amd64"},
+		{"FreeBSD_64", "freebsd", "amd64"},
+		{"darwin12-64", "darwin", "amd64"},
+		{"otherLinux", "linux", "386"},
+		{"other", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tc := range cases {
+		facts := GuestFactsForOSType(tc.OSType)
+		if facts.OSFamily != tc.OSFamily || facts.Arch != tc.Arch {
+			t.Fatalf("%s: bad: %#v", tc.OSType, facts)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Catalog translates the messages of the UI to a locale. Its entries
+// map English messages to their translations. The messages are fmt
+// formats like the ones they are written with: their verbs, like "%s" or
+// "%d", match any text, which is substituted for the verbs of the
+// translation, in order or by index like "%[2]s".
+type Catalog struct {
+	Locale string
+
+	exact    map[string]string
+	patterns []catalogPattern
+}
+
+type catalogPattern struct {
+	re          *regexp.Regexp
+	translation string
+}
+
+// formatVerbRe matches the verbs of fmt formats and the escaped percent
+// signs.
+var formatVerbRe = regexp.MustCompile(`%%|%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z]`)
+
+// NewCatalog creates the catalog of a locale from its entries.
+func NewCatalog(locale string, messages map[string]string) (*Catalog, error) {
+	c := &Catalog{
+		Locale: locale,
+		exact:  make(map[string]string),
+	}
+
+	// The longest messages are matched first, so that the more specific
+	// ones win
+	keys := make([]string, 0, len(messages))
+	for k := range messages {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, message := range keys {
+		translation := messages[message]
+		if !formatVerbRe.MatchString(message) {
+			c.exact[message] = translation
+			continue
+		}
+
+		re, err := formatRegexp(message)
+		if err != nil {
+			return nil, fmt.Errorf("Error in message %q: %s", message, err)
+		}
+		c.patterns = append(c.patterns, catalogPattern{
+			re: re,
+			// The arguments are the matched text
+			translation: formatVerbRe.ReplaceAllStringFunc(translation, func(verb string) string {
+				if verb == "%%" {
+					return verb
+				}
+				return formatVerbRe.ReplaceAllString(verb, "%${1}s")
+			}),
+		})
+	}
+
+	return c, nil
+}
+
+// formatRegexp returns the regular expression matching the messages
+// written with a fmt format.
+func formatRegexp(format string) (*regexp.Regexp, error) {
+	var buf bytes.Buffer
+	buf.WriteString("(?s)^")
+	last := 0
+	for _, loc := range formatVerbRe.FindAllStringIndex(format, -1) {
+		buf.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		if format[loc[0]:loc[1]] == "%%" {
+			buf.WriteString("%")
+		} else {
+			buf.WriteString("(.*?)")
+		}
+		last = loc[1]
+	}
+	buf.WriteString(regexp.QuoteMeta(format[last:]))
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
+
+// Translate returns the translation of a message, or the message if the
+// catalog doesn't have it.
+func (c *Catalog) Translate(message string) string {
+	if c == nil || message == "" {
+		return message
+	}
+
+	if translation, ok := c.exact[message]; ok {
+		return translation
+	}
+
+	for _, p := range c.patterns {
+		matches := p.re.FindStringSubmatch(message)
+		if matches == nil {
+			continue
+		}
+
+		args := make([]interface{}, len(matches)-1)
+		for i, m := range matches[1:] {
+			args[i] = m
+		}
+		return fmt.Sprintf(p.translation, args...)
+	}
+
+	return message
+}
+
+// LocaleFromEnv returns the locale of the messages of the UI, from the
+// PACKER_LOCALE environment variable, or from the locale of the system.
+// It is empty for the default locale, English.
+func LocaleFromEnv() string {
+	for _, key := range []string{"PACKER_LOCALE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := os.Getenv(key)
+		if locale == "" {
+			continue
+		}
+
+		// Like "fr_FR.UTF-8" or "de_DE@euro"
+		if i := strings.IndexAny(locale, ".@"); i >= 0 {
+			locale = locale[:i]
+		}
+		if locale == "C" || locale == "POSIX" {
+			return ""
+		}
+		return locale
+	}
+
+	return ""
+}
+
+// LoadCatalog loads the catalog of a locale from the first of the
+// directories that has one. The catalog of "fr_CA" is "fr_CA.json", or
+// "fr.json" if there is none. It returns nil if there is no catalog.
+func LoadCatalog(locale string, dirs []string) (*Catalog, error) {
+	if locale == "" {
+		return nil, nil
+	}
+
+	names := []string{locale}
+	if i := strings.IndexAny(locale, "_-"); i > 0 {
+		names = append(names, locale[:i])
+	}
+
+	for _, name := range names {
+		for _, dir := range dirs {
+			path := filepath.Join(dir, name+".json")
+			data, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			var messages map[string]string
+			if err := json.Unmarshal(data, &messages); err != nil {
+				return nil, fmt.Errorf("Error parsing message catalog %s: %s", path, err)
+			}
+			log.Printf("Loaded the message catalog of %s: %s", locale, path)
+			return NewCatalog(locale, messages)
+		}
+	}
+
+	log.Printf("No message catalog for %s", locale)
+	return nil, nil
+}
+
+// CatalogDirs returns the directories message catalogs are loaded from:
+// the directories in PACKER_LOCALE_PATH, then the "locales" directory of
+// the configuration directory.
+func CatalogDirs() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv("PACKER_LOCALE_PATH")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if dir, err := ConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "locales"))
+	}
+	return dirs
+}
+
+var (
+	catalog     *Catalog
+	catalogLock sync.RWMutex
+)
+
+// SetCatalog sets the catalog the UI messages are translated with, nil
+// for none.
+func SetCatalog(c *Catalog) {
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	catalog = c
+}
+
+// Localize translates a message of the UI with the catalog, if one is
+// set.
+func Localize(message string) string {
+	catalogLock.RLock()
+	defer catalogLock.RUnlock()
+
+	return catalog.Translate(message)
+}
@@ -0,0 +1,160 @@
+package packer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogTranslate(t *testing.T) {
+	c, err := NewCatalog("fr", map[string]string{
+		"Waiting for SSH to become available...": "Attente de la disponibilité de SSH...",
+		"Creating temporary keypair: %s":         "Création de la paire de clés temporaire : %s",
+		"Build '%s' finished after %d minutes.":  "Le build '%[1]s' s'est terminé en %[2]d minutes.",
+		"Copying %s to %s":                       "Copie vers %[2]s de %[1]s",
+		"Progress: 100%%":                        "Progression : 100 %%",
+		"Error: %s":                              "Erreur : %s",
+		"Error: %s not found":                    "Erreur : %s introuvable",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := map[string]string{
+		"Waiting for SSH to become available...": "Attente de la disponibilité de SSH...",
+		"Creating temporary keypair: packer_1":   "Création de la paire de clés temporaire : packer_1",
+		"Build 'vm' finished after 5 minutes.":   "Le build 'vm' s'est terminé en 5 minutes.",
+		"Copying a.txt to /tmp":                  "Copie vers /tmp de a.txt",
+		"Progress: 100%":                         "Progression : 100 %",
+		"Error: line 1\nline 2":                  "Erreur : line 1\nline 2",
+		"Error: ovftool not found":               "Erreur : ovftool introuvable",
+		"Not translated":                         "Not translated",
+	}
+	for message, expected := range cases {
+		if actual := c.Translate(message); actual != expected {
+			t.Fatalf("bad translation of %q: %q", message, actual)
+		}
+	}
+
+	// No catalog
+	var nilCatalog *Catalog
+	if actual := nilCatalog.Translate("foo"); actual != "foo" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestLocaleFromEnv(t *testing.T) {
+	keys := []string{"PACKER_LOCALE", "LC_ALL", "LC_MESSAGES", "LANG"}
+	old := make(map[string]string)
+	for _, key := range keys {
+		old[key] = os.Getenv(key)
+		os.Unsetenv(key)
+	}
+	defer func() {
+		for key, value := range old {
+			os.Setenv(key, value)
+		}
+	}()
+
+	if locale := LocaleFromEnv(); locale != "" {
+		t.Fatalf("bad: %s", locale)
+	}
+
+	os.Setenv("LANG", "C.UTF-8")
+	if locale := LocaleFromEnv(); locale != "" {
+		t.Fatalf("bad: %s", locale)
+	}
+
+	os.Setenv("LC_ALL", "de_DE@euro")
+	if locale := LocaleFromEnv(); locale != "de_DE" {
+		t.Fatalf("bad: %s", locale)
+	}
+
+	os.Setenv("PACKER_LOCALE", "fr_FR.UTF-8")
+	if locale := LocaleFromEnv(); locale != "fr_FR" {
+		t.Fatalf("bad: %s", locale)
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	catalog := `{"Build finished.": "Build terminé."}`
+	if err := ioutil.WriteFile(filepath.Join(td, "fr.json"), []byte(catalog), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c, err := LoadCatalog("fr_CA", []string{filepath.Join(td, "missing"), td})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c == nil || c.Translate("Build finished.") != "Build terminé." {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	if c, err := LoadCatalog("de", []string{td}); err != nil || c != nil {
+		t.Fatalf("bad: %#v %s", c, err)
+	}
+	if c, err := LoadCatalog("", []string{td}); err != nil || c != nil {
+		t.Fatalf("bad: %#v %s", c, err)
+	}
+}
+
+func TestLocalizedOutput(t *testing.T) {
+	c, err := NewCatalog("fr", map[string]string{
+		"Build finished.":  "Build terminé.",
+		"Uploading %s...":  "Envoi de %s...",
+		"Build '%s' done.": "Build '%s' fini.",
+		"Continue?":        "Continuer ?",
+
+		// The translations must not be translated again
+		"%s: Envoi de %s...": "bad",
+		"Build '%s' fini.":   "bad",
+		"%s: Continuer ?":    "bad",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	SetCatalog(c)
+	defer SetCatalog(nil)
+
+	oldenv := os.Getenv("PACKER_NO_COLOR")
+	os.Unsetenv("PACKER_NO_COLOR")
+	defer os.Setenv("PACKER_NO_COLOR", oldenv)
+
+	var buf bytes.Buffer
+	var record []string
+	bufferUi := &BasicUi{Reader: bytes.NewBufferString("y\n"), Writer: &buf}
+	coloredUi := &ColoredUi{Color: UiColorGreen, Ui: bufferUi}
+	recordedUi := &RecordedUi{
+		Ui:     &FilteredUi{Ui: coloredUi},
+		Record: func(message string) { record = append(record, message) },
+	}
+	ui := &TargetedUI{Target: "vm", Ui: recordedUi}
+
+	ui.Say("Uploading script.sh...")
+	if _, err := ui.Ask("Continue?"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	recordedUi.Say("Build 'vm' done.")
+	bufferUi.Say("Build finished.")
+
+	expected := "\033[1;32m==> vm: Envoi de script.sh...\033[0m\n" +
+		"\033[1;32m==> vm: Continuer ?\033[0m " +
+		"\033[1;32mBuild 'vm' fini.\033[0m\n" +
+		"Build terminé.\n"
+	if actual := buf.String(); actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// The history is kept in English, like the log
+	if len(record) != 2 || record[0] != "==> vm: Uploading script.sh..." {
+		t.Fatalf("bad: %#v", record)
+	}
+}
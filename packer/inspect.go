@@ -0,0 +1,294 @@
+package packer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// RedactedValue replaces sensitive values in inspected configurations.
+const RedactedValue = "<sensitive>"
+
+// InspectedBuild is the configuration of a build, as decoded by its
+// components when it is prepared: the configuration written in the
+// template, resolved for the build, with the defaults of the components
+// applied. The values of sensitive variables and of secrets are redacted
+// wherever they appear.
+type InspectedBuild struct {
+	Name           string                  `json:"name"`
+	Type           string                  `json:"type"`
+	Config         map[string]interface{}  `json:"config"`
+	Decoded        bool                    `json:"decoded"`
+	Provisioners   []*InspectedComponent   `json:"provisioners"`
+	PostProcessors [][]*InspectedComponent `json:"post-processors"`
+}
+
+// InspectedComponent is the configuration of a provisioner or
+// post-processor of an InspectedBuild.
+type InspectedComponent struct {
+	Type    string                 `json:"type"`
+	Config  map[string]interface{} `json:"config"`
+	Decoded bool                   `json:"decoded"`
+}
+
+// InspectVariables returns the user variables, with sensitive values
+// redacted.
+func (c *Core) InspectVariables() map[string]string {
+	result := make(map[string]string)
+	for k, v := range c.variables {
+		if c.sensitiveVariable(k) {
+			v = RedactedValue
+		}
+		result[k] = v
+	}
+
+	return result
+}
+
+// Inspect prepares the build with the given name and returns the
+// configuration its builder, provisioners and post-processors decoded.
+// The configuration of components that can't report it, such as plugins
+// built before packer inspect could decode configurations, is the one
+// written in the template, resolved for the build: user variables and
+// template functions are interpolated, only/except are applied and
+// overrides are merged. Values that can only be interpolated by the
+// component itself, such as those that use template variables like
+// `{{ .Path }}`, are left as is.
+func (c *Core) Inspect(n string) (*InspectedBuild, error) {
+	configBuilder, ok := c.builds[n]
+	if !ok {
+		return nil, fmt.Errorf("no such build found: %s", n)
+	}
+
+	i := &inspector{ctx: c.buildContext(n), secrets: c.sensitiveValues}
+
+	// rawName is the uninterpolated name that we use for various lookups
+	rawName := configBuilder.Name
+
+	result := &InspectedBuild{
+		Name:           n,
+		Type:           configBuilder.Type,
+		Config:         i.resolve(configBuilder.Config),
+		Provisioners:   make([]*InspectedComponent, 0, len(c.Template.Provisioners)),
+		PostProcessors: make([][]*InspectedComponent, 0, len(c.Template.PostProcessors)),
+	}
+
+	for _, rawP := range c.Template.Provisioners {
+		if rawP.Skip(rawName) {
+			continue
+		}
+
+		// Overrides are merged on top of the configuration
+		config := make(map[string]interface{})
+		for k, v := range rawP.Config {
+			config[k] = v
+		}
+		if override, ok := rawP.Override[rawName]; ok {
+			if m, ok := override.(map[string]interface{}); ok {
+				for k, v := range m {
+					config[k] = v
+				}
+			}
+		}
+
+		result.Provisioners = append(result.Provisioners, &InspectedComponent{
+			Type:   rawP.Type,
+			Config: i.resolve(config),
+		})
+	}
+
+	for _, rawPs := range c.Template.PostProcessors {
+		current := make([]*InspectedComponent, 0, len(rawPs))
+		for _, rawP := range rawPs {
+			if rawP.Skip(rawName) {
+				continue
+			}
+
+			current = append(current, &InspectedComponent{
+				Type:   rawP.Type,
+				Config: i.resolve(rawP.Config),
+			})
+		}
+
+		if len(current) > 0 {
+			result.PostProcessors = append(result.PostProcessors, current)
+		}
+	}
+
+	// The components decode their configuration when the build is
+	// prepared, like for packer validate
+	build, err := c.Build(n)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := build.Prepare(); err != nil {
+		return nil, err
+	}
+	b := build.(*coreBuild)
+
+	if err := i.decode(b.builder, &result.Config, &result.Decoded); err != nil {
+		return nil, fmt.Errorf("builder '%s': %s", b.builderType, err)
+	}
+	for idx, coreProv := range b.provisioners {
+		p := result.Provisioners[idx]
+		if err := i.decode(coreProv.provisioner, &p.Config, &p.Decoded); err != nil {
+			return nil, fmt.Errorf("provisioner '%s': %s", coreProv.pType, err)
+		}
+	}
+	for idx, ppSeq := range b.postProcessors {
+		for jdx, corePP := range ppSeq {
+			p := result.PostProcessors[idx][jdx]
+			if err := i.decode(corePP.processor, &p.Config, &p.Decoded); err != nil {
+				return nil, fmt.Errorf("post-processor '%s': %s", corePP.processorType, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Redact replaces the values of the sensitive user variables and the
+// values read from secret stores in s, like Inspect does, so that s can be
+// stored.
+func (c *Core) Redact(s string) string {
+	for _, secret := range c.sensitiveValues() {
+		s = strings.Replace(s, secret, RedactedValue, -1)
+	}
+	return s
+}
+
+// sensitiveValues returns the values of the user variables that are
+// sensitive and the values read from secret stores, longest first so that
+// they are replaced greedily.
+func (c *Core) sensitiveValues() []string {
+	result := c.secrets.Values()
+	for k, v := range c.variables {
+		if v != "" && c.sensitiveVariable(k) {
+			result = append(result, v)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return len(result[i]) > len(result[j])
+	})
+	return result
+}
+
+// sensitiveVariable returns true if the user variable is declared
+// sensitive or has a value read from a secret store.
+func (c *Core) sensitiveVariable(k string) bool {
+	if v, ok := c.Template.Variables[k]; ok && v.Sensitive {
+		return true
+	}
+	if v := c.variables[k]; v != "" {
+		for _, secret := range c.secrets.Values() {
+			if strings.Contains(v, secret) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// inspector interpolates a raw configuration and redacts the sensitive
+// values in it. Values written as is in the template aren't redacted,
+// whatever their key: secrets belong in sensitive variables.
+type inspector struct {
+	ctx *interpolate.Context
+
+	// secrets returns the values to redact. It is called for every value
+	// because rendering may read new ones from secret stores.
+	secrets func() []string
+}
+
+// decode replaces the configuration with the one the component decoded,
+// if it reports it. The configuration Packer gives every component, such
+// as the name of the build, is left out.
+func (i *inspector) decode(component interface{}, config *map[string]interface{}, decoded *bool) error {
+	raw, err := InspectComponent(component)
+	if err != nil || raw == nil {
+		return err
+	}
+
+	result := make(map[string]interface{})
+	for k, v := range raw {
+		if strings.HasPrefix(k, "packer_") {
+			continue
+		}
+		result[k] = i.redact(v)
+	}
+	*config = result
+	*decoded = true
+	return nil
+}
+
+// redact redacts the sensitive values of a decoded configuration, which is
+// already interpolated.
+func (i *inspector) redact(raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for k, e := range v {
+			result[k] = i.redact(e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for idx, e := range v {
+			result[idx] = i.redact(e)
+		}
+		return result
+	case string:
+		for _, secret := range i.secrets() {
+			v = strings.Replace(v, secret, RedactedValue, -1)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (i *inspector) resolve(raw map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range raw {
+		result[k] = i.value(v)
+	}
+
+	return result
+}
+
+func (i *inspector) value(raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		return i.resolve(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for idx, e := range v {
+			result[idx] = i.value(e)
+		}
+		return result
+	case string:
+		return i.string(v)
+	default:
+		return v
+	}
+}
+
+func (i *inspector) string(v string) string {
+	// Template variables belong to the component, so the empty data makes
+	// rendering fail and the value is kept as is.
+	ctx := *i.ctx
+	ctx.Data = struct{}{}
+	if rendered, err := interpolate.Render(v, &ctx); err == nil {
+		v = rendered
+	}
+
+	for _, secret := range i.secrets() {
+		v = strings.Replace(v, secret, RedactedValue, -1)
+	}
+
+	return v
+}
@@ -0,0 +1,197 @@
+package packer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	configHelper "github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/helper/secrets"
+	"github.com/hashicorp/packer/template"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type inspectPackerConfig struct {
+	PackerBuildName string `mapstructure:"packer_build_name"`
+}
+
+type inspectConfig struct {
+	Packer            inspectPackerConfig `mapstructure:",squash"`
+	Region            string              `mapstructure:"region"`
+	WinRMPassword     string              `mapstructure:"winrm_password"`
+	WinRMPass         string              `mapstructure:"winrm_pass"`
+	ClientCert        string              `mapstructure:"client_cert"`
+	SSHPrivateKeyFile string              `mapstructure:"ssh_private_key_file"`
+	Tags              map[string]string   `mapstructure:"tags"`
+	Timeout           time.Duration       `mapstructure:"timeout"`
+	Callback          func()              `mapstructure:"-"`
+
+	ctx interpolate.Context
+}
+
+// inspectBuilder decodes its configuration into its config field, like
+// the builders do.
+type inspectBuilder struct {
+	MockBuilder
+	config inspectConfig
+}
+
+func (b *inspectBuilder) Prepare(raws ...interface{}) ([]string, error) {
+	err := configHelper.Decode(&b.config, &configHelper.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &b.config.ctx,
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+	if b.config.Timeout == 0 {
+		b.config.Timeout = 5 * time.Minute
+	}
+	return nil, nil
+}
+
+func inspectCoreConfig(tpl *template.Template) *CoreConfig {
+	return &CoreConfig{
+		Template: tpl,
+		Components: ComponentFinder{
+			Builder: func(n string) (Builder, error) {
+				return &inspectBuilder{}, nil
+			},
+			Provisioner: func(n string) (Provisioner, error) {
+				return &MockProvisioner{}, nil
+			},
+			PostProcessor: func(n string) (PostProcessor, error) {
+				return &MockPostProcessor{}, nil
+			},
+		},
+	}
+}
+
+func TestCoreInspect(t *testing.T) {
+	tpl, err := template.ParseFile(fixtureDir("inspect.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	core, err := NewCore(inspectCoreConfig(tpl))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b, err := core.Inspect("main")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &InspectedBuild{
+		Name: "main",
+		Type: "test",
+		// The configuration decoded by the builder, with its defaults
+		Config: map[string]interface{}{
+			"region":               "us-east-1",
+			"winrm_password":       RedactedValue,
+			"winrm_pass":           RedactedValue,
+			"client_cert":          RedactedValue,
+			"ssh_private_key_file": "keys/id_rsa",
+			"tags": map[string]interface{}{
+				"built-by": "main-test",
+			},
+			"timeout": "5m0s",
+		},
+		Decoded: true,
+
+		// The mock components can't report their configuration, it is
+		// the one of the template
+		Provisioners: []*InspectedComponent{
+			{
+				Type: "shell",
+				Config: map[string]interface{}{
+					"execute_command": "echo '{{ user `admin_password` }}' | sudo -S sh '{{ .Path }}'",
+					"inline":          []interface{}{"echo overridden"},
+				},
+			},
+		},
+		PostProcessors: [][]*InspectedComponent{
+			{
+				{Type: "test", Config: map[string]interface{}{}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(b, expected) {
+		t.Fatalf("bad: %#v", b)
+	}
+
+	b, err = core.Inspect("other")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(b.Provisioners) != 2 {
+		t.Fatalf("bad: %#v", b.Provisioners)
+	}
+	inline := b.Provisioners[0].Config["inline"].([]interface{})
+	if inline[0] != "echo "+RedactedValue+"-other" {
+		t.Fatalf("bad: %#v", inline)
+	}
+
+	vars := core.InspectVariables()
+	expectedVars := map[string]string{
+		"admin_password": RedactedValue,
+		"api_token":      "not-declared-sensitive",
+		"region":         "us-east-1",
+		"vpn_psk":        RedactedValue,
+	}
+	if !reflect.DeepEqual(vars, expectedVars) {
+		t.Fatalf("bad: %#v", vars)
+	}
+
+	if v := core.Redact("login hunter2, psk s3cr3t, region us-east-1"); v != "login "+RedactedValue+", psk "+RedactedValue+", region us-east-1" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	if _, err := core.Inspect("nope"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreInspect_secrets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/kv/packer/license":
+			w.Write([]byte("ABCD-1234"))
+		case "/v1/kv/packer/motd":
+			w.Write([]byte("welcome"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	os.Setenv(secrets.EnvConsulAddr, ts.URL)
+	defer os.Unsetenv(secrets.EnvConsulAddr)
+
+	tpl, err := template.ParseFile(fixtureDir("inspect-secrets.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	core, err := NewCore(inspectCoreConfig(tpl))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if v := core.InspectVariables()["license"]; v != RedactedValue {
+		t.Fatalf("bad: %s", v)
+	}
+
+	b, err := core.Inspect("main")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	inline := b.Provisioners[0].Config["inline"]
+	expected := []interface{}{"register " + RedactedValue, "echo " + RedactedValue}
+	if !reflect.DeepEqual(inline, expected) {
+		t.Fatalf("bad: %#v", inline)
+	}
+}
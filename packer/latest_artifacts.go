@@ -0,0 +1,107 @@
+package packer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer/helper/registry"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// artifactLookup looks up the artifacts of previous builds in the
+// artifact registry of the template. It reads the entry of every build
+// name once, so that all the builds of a run see the same artifacts.
+type artifactLookup struct {
+	registry registry.Registry
+
+	l       sync.Mutex
+	entries map[string]*registry.Entry
+}
+
+func newArtifactLookup(r registry.Registry) *artifactLookup {
+	return &artifactLookup{
+		registry: r,
+		entries:  make(map[string]*registry.Entry),
+	}
+}
+
+func (a *artifactLookup) LatestArtifact(name, builderId string) (string, error) {
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	e, ok := a.entries[name]
+	if !ok {
+		var err error
+		e, err = a.registry.Latest(name)
+		if err != nil {
+			return "", err
+		}
+		a.entries[name] = e
+	}
+	if e == nil {
+		return "", fmt.Errorf("no build named '%s' has been recorded", name)
+	}
+
+	return e.ArtifactId(builderId)
+}
+
+// recordingLookup records the artifacts looked up through it, and the
+// first error.
+type recordingLookup struct {
+	lookup   interpolate.ArtifactLookup
+	resolved interpolate.ResolvedArtifacts
+	err      error
+}
+
+func (r *recordingLookup) LatestArtifact(name, builderId string) (string, error) {
+	id, err := r.lookup.LatestArtifact(name, builderId)
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return "", err
+	}
+
+	if r.resolved[name] == nil {
+		r.resolved[name] = make(map[string]string)
+	}
+	r.resolved[name][builderId] = id
+	return id, nil
+}
+
+// resolveLatestArtifacts looks up the artifacts that the configurations
+// of the components of a build use with "latest_artifact". The components
+// get these rather than the registry, whose configuration may hold
+// credentials.
+func (c *Core) resolveLatestArtifacts(n string, configs ...interface{}) (interpolate.ResolvedArtifacts, error) {
+	if c.artifacts == nil {
+		return nil, nil
+	}
+
+	recorder := &recordingLookup{
+		lookup:   c.artifacts,
+		resolved: make(interpolate.ResolvedArtifacts),
+	}
+	ctx := c.buildContext(n)
+	ctx.LatestArtifacts = recorder
+	for _, config := range configs {
+		walkStrings(config, func(v string) error {
+			if strings.Contains(v, "latest_artifact") {
+				// Only the lookups matter here. The components report
+				// the errors of the rest, such as of the data only they
+				// have.
+				interpolate.Render(v, ctx)
+			}
+			return nil
+		})
+		if recorder.err != nil {
+			return nil, fmt.Errorf("error looking up latest_artifact: %s", recorder.err)
+		}
+	}
+
+	if len(recorder.resolved) == 0 {
+		return nil, nil
+	}
+	return recorder.resolved, nil
+}
@@ -0,0 +1,264 @@
+package packer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricsMachineType is the machine readable message type plugins use to
+// add to the counters of the build's metrics, see AddMetric. These
+// messages are consumed by the core and never shown.
+const MetricsMachineType = "packer-metrics"
+
+// The counters plugins add to.
+const (
+	MetricCommunicatorRetries    = "communicator_retries_total"
+	MetricCommunicatorReconnects = "communicator_reconnects_total"
+	MetricCommunicatorTimeouts   = "communicator_timeouts_total"
+	MetricUploadBytes            = "upload_bytes_total"
+	MetricDownloadBytes          = "download_bytes_total"
+)
+
+// metricsHelp describes the counters plugins add to.
+var metricsHelp = map[string]string{
+	MetricCommunicatorRetries:    "Failed attempts to connect to the machine.",
+	MetricCommunicatorReconnects: "Lost connections to the machine that were established again.",
+	MetricCommunicatorTimeouts:   "Connections to the machine that timed out.",
+	MetricUploadBytes:            "Bytes uploaded to the machine by the communicator.",
+	MetricDownloadBytes:          "Bytes downloaded from the machine by the communicator.",
+}
+
+// Metrics are the metrics of the builds of this process. The core serves
+// them in the Prometheus text format when a metrics address is
+// configured.
+var Metrics = NewMetricsRegistry()
+
+// AddMetric adds value to the counter of the build. It works through the
+// Ui, so plugins can use it as well.
+func AddMetric(ui Ui, name string, value int64) {
+	if ui == nil || value == 0 {
+		return
+	}
+	ui.Machine(MetricsMachineType, name, strconv.FormatInt(value, 10))
+}
+
+type metricKey struct {
+	name  string
+	build string
+}
+
+type durationKey struct {
+	build string
+	kind  string
+	name  string
+}
+
+type durationSummary struct {
+	sum   float64
+	count int64
+}
+
+// MetricsRegistry collects the metrics of builds.
+type MetricsRegistry struct {
+	l         sync.Mutex
+	running   map[string]int
+	results   map[string]int64
+	counters  map[metricKey]float64
+	durations map[durationKey]*durationSummary
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		running:   make(map[string]int),
+		results:   make(map[string]int64),
+		counters:  make(map[metricKey]float64),
+		durations: make(map[durationKey]*durationSummary),
+	}
+}
+
+// StartBuild counts the build as running and returns the function to call
+// with its result once it ends.
+func (r *MetricsRegistry) StartBuild(build string) func(error) {
+	r.l.Lock()
+	r.running[build]++
+	r.l.Unlock()
+
+	return func(err error) {
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+
+		r.l.Lock()
+		defer r.l.Unlock()
+		if r.running[build]--; r.running[build] <= 0 {
+			delete(r.running, build)
+		}
+		r.results[result]++
+	}
+}
+
+// Add adds value to the counter of the build.
+func (r *MetricsRegistry) Add(build, name string, value float64) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.counters[metricKey{name: name, build: build}] += value
+}
+
+// ObserveTiming records the duration of a finished part of the build.
+func (r *MetricsRegistry) ObserveTiming(build string, t Timing) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	k := durationKey{build: build, kind: t.Type, name: t.Name}
+	s, ok := r.durations[k]
+	if !ok {
+		s = new(durationSummary)
+		r.durations[k] = s
+	}
+	s.sum += t.Duration.Seconds()
+	s.count++
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (r *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	var b bytes.Buffer
+	running := 0
+	for _, n := range r.running {
+		running += n
+	}
+	writeMetricHeader(&b, "packer_builds_running", "gauge", "Builds that are running.")
+	fmt.Fprintf(&b, "packer_builds_running %d\n", running)
+
+	writeMetricHeader(&b, "packer_builds_total", "counter", "Builds that ended, by result.")
+	for _, result := range []string{"failure", "success"} {
+		fmt.Fprintf(&b, "packer_builds_total{result=%s} %d\n",
+			quoteLabel(result), r.results[result])
+	}
+
+	durations := make([]durationKey, 0, len(r.durations))
+	for k := range r.durations {
+		durations = append(durations, k)
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		a, b := durations[i], durations[j]
+		if a.build != b.build {
+			return a.build < b.build
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.name < b.name
+	})
+	writeMetricHeader(&b, "packer_step_duration_seconds", "summary",
+		"Duration of the builders, their steps, the provisioners and the post-processors.")
+	for _, k := range durations {
+		labels := fmt.Sprintf("build=%s,type=%s,name=%s",
+			quoteLabel(k.build), quoteLabel(k.kind), quoteLabel(k.name))
+		s := r.durations[k]
+		fmt.Fprintf(&b, "packer_step_duration_seconds_sum{%s} %s\n",
+			labels, strconv.FormatFloat(s.sum, 'f', -1, 64))
+		fmt.Fprintf(&b, "packer_step_duration_seconds_count{%s} %d\n", labels, s.count)
+	}
+
+	names := make([]string, 0, len(metricsHelp))
+	for name := range metricsHelp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	counters := make([]metricKey, 0, len(r.counters))
+	for k := range r.counters {
+		counters = append(counters, k)
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		return counters[i].build < counters[j].build
+	})
+	for _, name := range names {
+		writeMetricHeader(&b, "packer_"+name, "counter", metricsHelp[name])
+		for _, k := range counters {
+			if k.name == name {
+				fmt.Fprintf(&b, "packer_%s{build=%s} %s\n", name, quoteLabel(k.build),
+					strconv.FormatFloat(r.counters[k], 'f', -1, 64))
+			}
+		}
+	}
+
+	return b.WriteTo(w)
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// quoteLabel quotes the value of a label, with the escapes of the text
+// format.
+func quoteLabel(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return `"` + v + `"`
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := r.WriteTo(w); err != nil {
+		log.Printf("[WARN] Error writing the metrics: %s", err)
+	}
+}
+
+// Serve serves the metrics on /metrics at the address, like
+// "127.0.0.1:9290", until the returned listener is closed.
+func (r *MetricsRegistry) Serve(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			log.Printf("[DEBUG] Stopped serving the metrics: %s", err)
+		}
+	}()
+
+	log.Printf("[INFO] Serving the metrics on http://%s/metrics", l.Addr())
+	return l, nil
+}
+
+// metricsUi adds the counters plugins report through the Ui to the
+// metrics of the build and passes everything else on.
+type metricsUi struct {
+	Ui
+	build   string
+	metrics *MetricsRegistry
+}
+
+func (u *metricsUi) Machine(t string, args ...string) {
+	if t != MetricsMachineType {
+		u.Ui.Machine(t, args...)
+		return
+	}
+	if len(args) < 2 {
+		return
+	}
+
+	value, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		log.Printf("[WARN] Invalid value of metric %s: %s", args[0], args[1])
+		return
+	}
+	u.metrics.Add(u.build, args[0], value)
+}
@@ -0,0 +1,102 @@
+package packer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsUi(t *testing.T) {
+	metrics := NewMetricsRegistry()
+	out := new(bytes.Buffer)
+	ui := &metricsUi{Ui: &MachineReadableUi{Writer: out}, build: "vbox", metrics: metrics}
+
+	AddMetric(ui, MetricUploadBytes, 1024)
+	AddMetric(ui, MetricUploadBytes, 1024)
+	AddMetric(ui, MetricCommunicatorRetries, 1)
+	ui.Machine(MetricsMachineType, MetricDownloadBytes, "nope")
+	ui.Machine("artifact", "0", "id", "foo")
+
+	if v := metrics.counters[metricKey{MetricUploadBytes, "vbox"}]; v != 2048 {
+		t.Fatalf("bad upload bytes: %f", v)
+	}
+	if v := metrics.counters[metricKey{MetricCommunicatorRetries, "vbox"}]; v != 1 {
+		t.Fatalf("bad retries: %f", v)
+	}
+	if _, ok := metrics.counters[metricKey{MetricDownloadBytes, "vbox"}]; ok {
+		t.Fatal("invalid values should not be counted")
+	}
+
+	// Only other machine readable messages are passed on
+	if strings.Contains(out.String(), MetricsMachineType) || !strings.Contains(out.String(), ",artifact,0,id,foo") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestMetricsRegistry_WriteTo(t *testing.T) {
+	metrics := NewMetricsRegistry()
+	done := metrics.StartBuild("vbox")
+	metrics.StartBuild(`my "build"`)
+	metrics.ObserveTiming("vbox", Timing{Type: "step", Name: "StepProvision", Duration: 1500 * time.Millisecond})
+	metrics.ObserveTiming("vbox", Timing{Type: "step", Name: "StepProvision", Duration: time.Second})
+	metrics.Add("vbox", MetricUploadBytes, 2048)
+	done(errors.New("failed"))
+
+	var out bytes.Buffer
+	if _, err := metrics.WriteTo(&out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"# TYPE packer_builds_running gauge\npacker_builds_running 1\n",
+		`packer_builds_total{result="failure"} 1`,
+		`packer_builds_total{result="success"} 0`,
+		`packer_step_duration_seconds_sum{build="vbox",type="step",name="StepProvision"} 2.5`,
+		`packer_step_duration_seconds_count{build="vbox",type="step",name="StepProvision"} 2`,
+		`packer_upload_bytes_total{build="vbox"} 2048`,
+		"# TYPE packer_communicator_retries_total counter\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Fatalf("missing %q in:\n%s", e, out.String())
+		}
+	}
+}
+
+func TestMetricsRegistry_Serve(t *testing.T) {
+	metrics := NewMetricsRegistry()
+	metrics.StartBuild("vbox")
+
+	l, err := metrics.Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("bad content type: %s", ct)
+	}
+	if !strings.Contains(string(body), "packer_builds_running 1\n") {
+		t.Fatalf("bad: %s", body)
+	}
+}
+
+func TestQuoteLabel(t *testing.T) {
+	if v := quoteLabel("a\"b\\c\nd"); v != `"a\"b\\c\nd"` {
+		t.Fatalf("bad: %s", v)
+	}
+}
@@ -0,0 +1,108 @@
+package packer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// BuilderNameKeys are the configuration keys that name the artifacts of
+// builders, by builder type. A naming pattern that is enforced sets them
+// to the artifact name.
+var BuilderNameKeys = map[string]string{
+	"alicloud-ecs":        "image_name",
+	"amazon-chroot":       "ami_name",
+	"amazon-ebs":          "ami_name",
+	"amazon-ebssurrogate": "ami_name",
+	"amazon-instance":     "ami_name",
+	"azure-arm":           "managed_image_name",
+	"cloudstack":          "template_name",
+	"digitalocean":        "snapshot_name",
+	"googlecompute":       "image_name",
+	"hyperv-iso":          "vm_name",
+	"oneandone":           "image_name",
+	"openstack":           "image_name",
+	"oracle-oci":          "image_name",
+	"parallels-iso":       "vm_name",
+	"parallels-pvm":       "vm_name",
+	"profitbricks":        "snapshot_name",
+	"qemu":                "vm_name",
+	"triton":              "image_name",
+	"virtualbox-iso":      "vm_name",
+	"virtualbox-ovf":      "vm_name",
+	"vmware-iso":          "vm_name",
+	"vmware-vmx":          "vm_name",
+}
+
+// PostProcessorNameKeys are the configuration keys that name the artifacts
+// of post-processors, by post-processor type.
+var PostProcessorNameKeys = map[string]string{
+	"alicloud-import": "image_name",
+	"amazon-import":   "ami_name",
+	"vsphere":         "vm_name",
+}
+
+// initNaming interpolates the naming pattern of the template for every
+// build, with the fields of the pattern available as functions.
+func (c *Core) initNaming() error {
+	c.artifactNames = make(map[string]string)
+	naming := c.Template.Naming
+	if naming == nil {
+		return nil
+	}
+
+	fields := make([]string, 0, len(naming.Fields))
+	for k := range naming.Fields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	for n := range c.builds {
+		ctx := c.buildContext(n)
+		ctx.Funcs = make(map[string]interface{})
+		for _, k := range fields {
+			v, err := interpolate.Render(naming.Fields[k], ctx)
+			if err != nil {
+				return fmt.Errorf(
+					"Error interpolating naming field '%s' for build '%s': %s",
+					k, n, err)
+			}
+			ctx.Funcs[k] = func() string { return v }
+		}
+
+		name, err := interpolate.Render(naming.Pattern, ctx)
+		if err != nil {
+			return fmt.Errorf(
+				"Error interpolating naming pattern for build '%s': %s", n, err)
+		}
+		if name == "" {
+			return fmt.Errorf("The naming pattern gives build '%s' an empty name", n)
+		}
+
+		c.artifactNames[n] = name
+	}
+
+	return nil
+}
+
+// enforceName returns the configuration of a component with its name
+// setting set to the artifact name of the build, if the naming pattern of
+// the template is enforced and the component has a name setting.
+func (c *Core) enforceName(n, component string, key string, config map[string]interface{}) (map[string]interface{}, error) {
+	if c.Template.Naming == nil || !c.Template.Naming.Enforce || key == "" {
+		return config, nil
+	}
+
+	if _, ok := config[key]; ok {
+		return nil, fmt.Errorf(
+			"%s: '%s' can't be set, the enforced naming pattern sets it", component, key)
+	}
+
+	result := make(map[string]interface{}, len(config)+1)
+	for k, v := range config {
+		result[k] = v
+	}
+	result[key] = c.artifactNames[n]
+	return result, nil
+}
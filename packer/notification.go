@@ -0,0 +1,144 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/packer/template"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// NotificationTimeout is how long we wait for a single notification
+// endpoint to respond before giving up on it.
+var NotificationTimeout = 10 * time.Second
+
+// NotificationData is the data available to the payload template of a
+// notification, e.g. `{{ .BuildName }}`.
+type NotificationData struct {
+	Event          string `json:"event"`
+	BuildName      string `json:"build_name"`
+	BuilderType    string `json:"builder_type"`
+	StepType       string `json:"step_type,omitempty"`
+	Step           string `json:"step,omitempty"`
+	Error          string `json:"error,omitempty"`
+	ArtifactId     string `json:"artifact_id,omitempty"`
+	ArtifactString string `json:"artifact,omitempty"`
+}
+
+// notifier sends the notifications configured in a template for a
+// single build. Failing to deliver a notification never fails the build,
+// it is only logged.
+type notifier struct {
+	notifications []*template.Notification
+	ctx           interpolate.Context
+	client        *http.Client
+}
+
+func newNotifier(ns []*template.Notification, ctx interpolate.Context) *notifier {
+	return &notifier{
+		notifications: ns,
+		ctx:           ctx,
+		client:        &http.Client{Timeout: NotificationTimeout},
+	}
+}
+
+// Notify fires all notifications subscribed to the event in data.
+func (n *notifier) Notify(data *NotificationData) {
+	if n == nil {
+		return
+	}
+
+	for _, notification := range n.notifications {
+		if !notification.Notifies(data.Event) {
+			continue
+		}
+
+		if err := n.send(notification, data); err != nil {
+			log.Printf("[WARN] Error sending %s notification for '%s': %s",
+				notification.Type, data.Event, err)
+		}
+	}
+}
+
+func (n *notifier) send(notification *template.Notification, data *NotificationData) error {
+	body, err := n.payload(notification, data)
+	if err != nil {
+		return err
+	}
+
+	if notification.Type == template.NotificationTypeSNS {
+		log.Printf("Publishing %s notification for '%s' to %s",
+			notification.Type, data.Event, notification.TopicARN)
+		return publishSNS(notification.TopicARN, string(body))
+	}
+
+	req, err := http.NewRequest("POST", notification.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range notification.Headers {
+		req.Header.Set(k, v)
+	}
+
+	log.Printf("Sending %s notification for '%s' to %s",
+		notification.Type, data.Event, notification.URL)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (n *notifier) payload(notification *template.Notification, data *NotificationData) ([]byte, error) {
+	ctx := n.ctx
+	ctx.Data = data
+
+	var rendered string
+	if notification.Payload != "" {
+		var err error
+		rendered, err = interpolate.Render(notification.Payload, &ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error rendering payload: %s", err)
+		}
+	}
+
+	switch notification.Type {
+	case template.NotificationTypeSlack:
+		if rendered == "" {
+			rendered = data.summary()
+		}
+		return json.Marshal(map[string]string{"text": rendered})
+	default:
+		if rendered == "" {
+			return json.Marshal(data)
+		}
+		return []byte(rendered), nil
+	}
+}
+
+// summary is a human readable description of the event.
+func (d *NotificationData) summary() string {
+	switch d.Event {
+	case template.EventBuildStart:
+		return fmt.Sprintf("Build '%s' started.", d.BuildName)
+	case template.EventStepFailure:
+		return fmt.Sprintf("Build '%s' %s %s errored: %s", d.BuildName, d.StepType, d.Step, d.Error)
+	case template.EventBuildFailure:
+		return fmt.Sprintf("Build '%s' errored: %s", d.BuildName, d.Error)
+	case template.EventArtifact:
+		return fmt.Sprintf("Build '%s' created artifact: %s", d.BuildName, d.ArtifactString)
+	default:
+		return fmt.Sprintf("Build '%s': %s", d.BuildName, d.Event)
+	}
+}
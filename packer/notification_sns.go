@@ -0,0 +1,83 @@
+package packer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+)
+
+// snsEndpoint overrides the endpoint of the SNS API, for tests.
+var snsEndpoint string
+
+type snsPublishInput struct {
+	_ struct{} `type:"structure"`
+
+	Message  *string `type:"string"`
+	TopicArn *string `type:"string"`
+}
+
+type snsPublishOutput struct {
+	_ struct{} `type:"structure"`
+
+	MessageId *string `type:"string"`
+}
+
+// publishSNS publishes the message to the SNS topic. The region is the one
+// of the topic, and the credentials are read like the Amazon builders do,
+// from the environment or the shared configuration.
+func publishSNS(topicARN, message string) error {
+	parts := strings.Split(topicARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" {
+		return fmt.Errorf("invalid SNS topic ARN: %s", topicARN)
+	}
+
+	config := aws.NewConfig().
+		WithHTTPClient(&http.Client{Timeout: NotificationTimeout}).
+		WithRegion(parts[3])
+	if snsEndpoint != "" {
+		config = config.WithEndpoint(snsEndpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The SNS client of the SDK isn't vendored, and this is the only call
+	// we need, so the client is set up the way the SDK does it.
+	c := sess.ClientConfig("sns")
+	svc := client.New(*c.Config, metadata.ClientInfo{
+		ServiceName:   "sns",
+		SigningName:   c.SigningName,
+		SigningRegion: c.SigningRegion,
+		Endpoint:      c.Endpoint,
+		APIVersion:    "2010-03-31",
+	}, c.Handlers)
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(query.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+
+	op := &request.Operation{
+		Name:       "Publish",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+	input := &snsPublishInput{
+		Message:  aws.String(message),
+		TopicArn: aws.String(topicARN),
+	}
+	return svc.NewRequest(op, input, &snsPublishOutput{}).Send()
+}
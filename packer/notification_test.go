@@ -0,0 +1,217 @@
+package packer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer/template"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type testNotificationServer struct {
+	sync.Mutex
+	Bodies  []string
+	Headers []http.Header
+}
+
+func (s *testNotificationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.Lock()
+	defer s.Unlock()
+	s.Bodies = append(s.Bodies, string(body))
+	s.Headers = append(s.Headers, r.Header)
+}
+
+func TestBuild_Run_Notifications(t *testing.T) {
+	handler := new(testNotificationServer)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	build := testBuild()
+	build.postProcessors = [][]coreBuildPostProcessor{}
+	build.notifier = newNotifier([]*template.Notification{
+		{
+			Type:    template.NotificationTypeWebhook,
+			URL:     ts.URL,
+			Events:  []string{template.EventArtifact},
+			Headers: map[string]string{"X-Token": "foo"},
+		},
+	}, interpolate.Context{})
+
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(handler.Bodies) != 1 {
+		t.Fatalf("bad: %#v", handler.Bodies)
+	}
+
+	var data NotificationData
+	if err := json.Unmarshal([]byte(handler.Bodies[0]), &data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := NotificationData{
+		Event:          template.EventArtifact,
+		BuildName:      "test",
+		BuilderType:    "foo",
+		ArtifactId:     "b",
+		ArtifactString: "string",
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("bad: %#v", data)
+	}
+	if v := handler.Headers[0].Get("X-Token"); v != "foo" {
+		t.Fatalf("bad: %s", v)
+	}
+}
+
+func TestBuild_Run_NotificationsFailure(t *testing.T) {
+	handler := new(testNotificationServer)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	build := testBuild()
+	build.builder = &MockBuilder{RunErrResult: true}
+	build.notifier = newNotifier([]*template.Notification{
+		{
+			Type:    template.NotificationTypeSlack,
+			URL:     ts.URL,
+			Events:  template.NotificationEvents,
+			Payload: "{{ .BuildName }} {{ .Event }}: {{ .Error }}",
+		},
+	}, interpolate.Context{})
+
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err == nil {
+		t.Fatal("should error")
+	}
+
+	expected := []string{
+		`{"text":"test build-start: "}`,
+		`{"text":"test build-failure: foo"}`,
+	}
+	if !reflect.DeepEqual(handler.Bodies, expected) {
+		t.Fatalf("bad: %#v", handler.Bodies)
+	}
+}
+
+// stepFailureBuilder fails like builders whose step fails, reporting the
+// step through the Ui.
+type stepFailureBuilder struct {
+	MockBuilder
+}
+
+func (b *stepFailureBuilder) Run(ui Ui, h Hook, c Cache) (Artifact, error) {
+	err := errors.New("bar")
+	StartTiming(ui, "step", "StepCreateInstance")(err)
+	return nil, err
+}
+
+func TestBuild_Run_NotificationsStepFailure(t *testing.T) {
+	handler := new(testNotificationServer)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	build := testBuild()
+	build.builder = new(stepFailureBuilder)
+	build.notifier = newNotifier([]*template.Notification{
+		{
+			Type:   template.NotificationTypeWebhook,
+			URL:    ts.URL,
+			Events: []string{template.EventStepFailure, template.EventBuildFailure},
+		},
+	}, interpolate.Context{})
+
+	build.Prepare()
+	if _, err := build.Run(testUi(), &TestCache{}); err == nil {
+		t.Fatal("should error")
+	}
+
+	if len(handler.Bodies) != 2 {
+		t.Fatalf("bad: %#v", handler.Bodies)
+	}
+
+	var data NotificationData
+	if err := json.Unmarshal([]byte(handler.Bodies[0]), &data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := NotificationData{
+		Event:       template.EventStepFailure,
+		BuildName:   "test",
+		BuilderType: "foo",
+		StepType:    "step",
+		Step:        "StepCreateInstance",
+		Error:       "bar",
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("bad: %#v", data)
+	}
+	if !strings.Contains(handler.Bodies[1], template.EventBuildFailure) {
+		t.Fatalf("bad: %s", handler.Bodies[1])
+	}
+}
+
+func TestNotifierSNS(t *testing.T) {
+	var messages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "Publish" ||
+			r.Form.Get("TopicArn") != "arn:aws:sns:eu-west-1:123456789012:builds" ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/sns/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		messages = append(messages, r.Form.Get("Message"))
+		w.Write([]byte(`<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>`))
+	}))
+	defer ts.Close()
+
+	snsEndpoint = ts.URL
+	defer func() { snsEndpoint = "" }()
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID":           "AKID",
+		"AWS_SECRET_ACCESS_KEY":       "SECRET",
+		"AWS_CONFIG_FILE":             "/nonexistent",
+		"AWS_SHARED_CREDENTIALS_FILE": "/nonexistent",
+	} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	n := newNotifier([]*template.Notification{
+		{
+			Type:     template.NotificationTypeSNS,
+			TopicARN: "arn:aws:sns:eu-west-1:123456789012:builds",
+			Events:   []string{template.EventArtifact},
+			Payload:  "{{ .BuildName }}: {{ .ArtifactId }}",
+		},
+	}, interpolate.Context{})
+	notification := n.notifications[0]
+	data := &NotificationData{
+		Event:      template.EventArtifact,
+		BuildName:  "test",
+		ArtifactId: "ami-1234",
+	}
+	if err := n.send(notification, data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(messages, []string{"test: ami-1234"}) {
+		t.Fatalf("bad: %#v", messages)
+	}
+
+	notification.TopicARN = "builds"
+	if err := n.send(notification, data); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -138,6 +139,17 @@ func (c *Client) Builder() (packer.Builder, error) {
 	return &cmdBuilder{client.Builder(), c}, nil
 }
 
+// Returns a fixer implementation that is communicating over this
+// client. If the client hasn't been started, this will start it.
+func (c *Client) Fixer() (packer.Fixer, error) {
+	client, err := c.packrpcClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cmdFixer{client.Fixer(), c}, nil
+}
+
 // Returns a hook implementation that is communicating over this
 // client. If the client hasn't been started, this will start it.
 func (c *Client) Hook() (packer.Hook, error) {
@@ -317,9 +329,10 @@ func (c *Client) Start() (addr net.Addr, err error) {
 		}
 
 		// Test the API version
-		if parts[0] != APIVersion {
+		if !apiVersionSupported(parts[0]) {
 			err = fmt.Errorf("Incompatible API version with plugin. "+
-				"Plugin version: %s, Ours: %s", parts[0], APIVersion)
+				"Plugin version: %s, Ours: %s (compatible with %s and up)",
+				parts[0], APIVersion, APIVersionMin)
 			return
 		}
 
@@ -337,6 +350,19 @@ func (c *Client) Start() (addr net.Addr, err error) {
 	return
 }
 
+// apiVersionSupported checks whether the API version reported by a plugin
+// is within the range of versions this client can speak.
+func apiVersionSupported(v string) bool {
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return false
+	}
+
+	min, _ := strconv.Atoi(APIVersionMin)
+	max, _ := strconv.Atoi(APIVersion)
+	return version >= min && version <= max
+}
+
 func (c *Client) logStderr(r io.Reader) {
 	bufR := bufio.NewReader(r)
 	for {
@@ -345,7 +371,7 @@ func (c *Client) logStderr(r io.Reader) {
 			c.config.Stderr.Write([]byte(line))
 
 			line = strings.TrimRightFunc(line, unicode.IsSpace)
-			log.Printf("%s: %s", filepath.Base(c.config.Cmd.Path), line)
+			log.Printf("%s: %s", c.logName(), line)
 		}
 
 		if err == io.EOF {
@@ -357,6 +383,19 @@ func (c *Client) logStderr(r io.Reader) {
 	close(c.doneLogging)
 }
 
+// logName is the name the lines the plugin logs are prefixed with. It is
+// the name of the plugin, e.g. packer-provisioner-shell, which lets the
+// log filters tell plugins apart, even when they are built into packer.
+func (c *Client) logName() string {
+	args := c.config.Cmd.Args
+	if len(args) > 0 {
+		if name := args[len(args)-1]; strings.HasPrefix(name, "packer-") {
+			return name
+		}
+	}
+	return filepath.Base(c.config.Cmd.Path)
+}
+
 func (c *Client) packrpcClient() (*packrpc.Client, error) {
 	addr, err := c.Start()
 	if err != nil {
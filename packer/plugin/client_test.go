@@ -143,3 +143,23 @@ func TestClient_Stdin(t *testing.T) {
 		t.Fatal("process didn't exit cleanly")
 	}
 }
+
+func TestClient_apiVersionSupported(t *testing.T) {
+	cases := []struct {
+		Version  string
+		Expected bool
+	}{
+		{APIVersion, true},
+		{APIVersionMin, true},
+		{"0", false},
+		{"4", false},
+		{"999", false},
+		{"foo", false},
+	}
+
+	for _, tc := range cases {
+		if actual := apiVersionSupported(tc.Version); actual != tc.Expected {
+			t.Fatalf("%s: expected %t, got %t", tc.Version, tc.Expected, actual)
+		}
+	}
+}
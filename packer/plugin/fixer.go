@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"log"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+type cmdFixer struct {
+	fixer  packer.Fixer
+	client *Client
+}
+
+func (c *cmdFixer) Fix(input map[string]interface{}) (map[string]interface{}, error) {
+	defer func() {
+		r := recover()
+		c.checkExit(r, nil)
+	}()
+
+	return c.fixer.Fix(input)
+}
+
+func (c *cmdFixer) Synopsis() string {
+	defer func() {
+		r := recover()
+		c.checkExit(r, nil)
+	}()
+
+	return c.fixer.Synopsis()
+}
+
+func (c *cmdFixer) checkExit(p interface{}, cb func()) {
+	if c.client.Exited() && cb != nil {
+		cb()
+	} else if p != nil && !Killed {
+		log.Panic(p)
+	}
+}
@@ -0,0 +1,31 @@
+package plugin
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestFixer_NoExist(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: exec.Command("i-should-not-exist")})
+	defer c.Kill()
+
+	_, err := c.Fixer()
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestFixer_Good(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("fixer")})
+	defer c.Kill()
+
+	fixer, err := c.Fixer()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test that we can call it just to make sure it works
+	if synopsis := fixer.Synopsis(); synopsis != "helper fixer" {
+		t.Fatalf("bad: %s", synopsis)
+	}
+}
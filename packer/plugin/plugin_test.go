@@ -62,6 +62,14 @@ func TestHelperProcess(*testing.T) {
 		}
 		server.RegisterBuilder(new(packer.MockBuilder))
 		server.Serve()
+	case "fixer":
+		server, err := Server()
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			os.Exit(1)
+		}
+		server.RegisterFixer(&packer.MockFixer{SynopsisValue: "helper fixer"})
+		server.Serve()
 	case "hook":
 		server, err := Server()
 		if err != nil {
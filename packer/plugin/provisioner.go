@@ -1,8 +1,10 @@
 package plugin
 
 import (
-	"github.com/hashicorp/packer/packer"
+	"context"
 	"log"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 type cmdProvisioner struct {
@@ -19,22 +21,13 @@ func (c *cmdProvisioner) Prepare(configs ...interface{}) error {
 	return c.p.Prepare(configs...)
 }
 
-func (c *cmdProvisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
-	defer func() {
-		r := recover()
-		c.checkExit(r, nil)
-	}()
-
-	return c.p.Provision(ui, comm)
-}
-
-func (c *cmdProvisioner) Cancel() {
+func (c *cmdProvisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	defer func() {
 		r := recover()
 		c.checkExit(r, nil)
 	}()
 
-	c.p.Cancel()
+	return c.p.Provision(ctx, ui, comm)
 }
 
 func (c *cmdProvisioner) checkExit(p interface{}, cb func()) {
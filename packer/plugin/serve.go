@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"github.com/hashicorp/packer/packer"
+)
+
+// ServeBuilder serves a single builder over the plugin protocol. This is
+// all the main function of a third-party builder plugin needs to call:
+//
+//	func main() {
+//	    plugin.ServeBuilder(new(mybuilder.Builder))
+//	}
+//
+// The binary must be named packer-builder-NAME to be discovered.
+func ServeBuilder(b packer.Builder) {
+	server, err := Server()
+	if err != nil {
+		panic(err)
+	}
+	server.RegisterBuilder(b)
+	server.Serve()
+}
+
+// ServeProvisioner serves a single provisioner over the plugin protocol.
+// The binary must be named packer-provisioner-NAME to be discovered.
+func ServeProvisioner(p packer.Provisioner) {
+	server, err := Server()
+	if err != nil {
+		panic(err)
+	}
+	server.RegisterProvisioner(p)
+	server.Serve()
+}
+
+// ServePostProcessor serves a single post-processor over the plugin
+// protocol. The binary must be named packer-post-processor-NAME to be
+// discovered.
+func ServePostProcessor(p packer.PostProcessor) {
+	server, err := Server()
+	if err != nil {
+		panic(err)
+	}
+	server.RegisterPostProcessor(p)
+	server.Serve()
+}
+
+// ServeFixer serves a single fixer of "packer fix" over the plugin
+// protocol. The binary must be named packer-fixer-NAME to be discovered.
+func ServeFixer(f packer.Fixer) {
+	server, err := Server()
+	if err != nil {
+		panic(err)
+	}
+	server.RegisterFixer(f)
+	server.Serve()
+}
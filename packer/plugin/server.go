@@ -34,7 +34,22 @@ const MagicCookieValue = "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d69
 // The APIVersion is outputted along with the RPC address. The plugin
 // client validates this API version and will show an error if it doesn't
 // know how to speak it.
-const APIVersion = "4"
+//
+// Version 5 cancels provisioners through the context of Provision instead
+// of Cancel, and adds the Validate checks of components, timings, guest
+// facts, the WinRM timeouts and reconnects of remote commands, artifact
+// metadata, build sources and fixers.
+const APIVersion = "5"
+
+// APIVersionMin is the oldest API version that the plugin client still
+// knows how to speak. Plugins built against any version between this and
+// APIVersion (inclusive) can be loaded. Bump APIVersion for every change
+// to the RPC protocol, and only bump APIVersionMin when an older protocol
+// can no longer be supported.
+//
+// Version 4 plugins wait for a Cancel call that Packer no longer makes to
+// stop their provisioners.
+const APIVersionMin = "5"
 
 // Server waits for a connection to this plugin and returns a Packer
 // RPC server that you can use to register components and serve them.
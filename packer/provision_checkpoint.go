@@ -0,0 +1,107 @@
+package packer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// provisionRangeFrom and provisionRangeTo are the keys of the data of the
+// provision hook that select the provisioners to run.
+const (
+	provisionRangeFrom = "provision_from"
+	provisionRangeTo   = "provision_to"
+)
+
+// ProvisionRangeData returns the data of the provision hook that only runs
+// the provisioners from from up to, but not including, to. A negative to
+// runs the provisioners up to the last one. Builders use it to save the
+// machine at the checkpoints between the provisioners.
+func ProvisionRangeData(from, to int) map[string]interface{} {
+	return map[string]interface{}{
+		provisionRangeFrom: from,
+		provisionRangeTo:   to,
+	}
+}
+
+// provisionRange returns the provisioners to run for the data of the
+// provision hook, all of them if the data doesn't select a range.
+func provisionRange(data interface{}, n int) (int, int, error) {
+	var m map[string]interface{}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		m = d
+	case *map[string]interface{}:
+		if d != nil {
+			m = *d
+		}
+	}
+
+	from, to := 0, n
+	if m == nil {
+		return from, to, nil
+	}
+	if v, ok := toInt(m[provisionRangeFrom]); ok {
+		from = v
+	}
+	if v, ok := toInt(m[provisionRangeTo]); ok && v >= 0 {
+		to = v
+	}
+	if from < 0 || from > to || to > n {
+		return 0, 0, fmt.Errorf(
+			"Invalid range of provisioners to run: %d to %d of %d", from, to, n)
+	}
+
+	return from, to, nil
+}
+
+// toInt converts the numbers that come out of the RPC encodings.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// checkpoints returns the checkpoints of the provisioners of the build,
+// as maps with the name of the checkpoint, the number of provisioners run
+// before it and a hash of their configuration. The hash also covers the
+// name and the variables of the build, so that builders only reuse a saved
+// checkpoint when nothing that configures the provisioners changed.
+func (b *coreBuild) checkpoints() ([]interface{}, error) {
+	hash := sha256.New()
+	vars, err := json.Marshal(b.variables)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(hash, "%s\n%s\n", b.name, vars)
+
+	var result []interface{}
+	for i, p := range b.provisioners {
+		config, err := json.Marshal(p.config)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error hashing the configuration of provisioner '%s': %s", p.pType, err)
+		}
+		fmt.Fprintf(hash, "%s\n%s\n", p.pType, config)
+
+		if p.checkpoint == "" {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"name":         p.checkpoint,
+			"provisioners": i + 1,
+			"hash":         hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+
+	return result, nil
+}
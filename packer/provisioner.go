@@ -1,7 +1,10 @@
 package packer
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"time"
 )
@@ -18,12 +21,11 @@ type Provisioner interface {
 	// given to communicate with the user, and a communicator is given that
 	// is guaranteed to be connected to some machine so that provisioning
 	// can be done.
-	Provision(Ui, Communicator) error
-
-	// Cancel is called to cancel the provisioning. This is usually called
-	// while Provision is still being called. The Provisioner should act
-	// to stop its execution as quickly as possible in a race-free way.
-	Cancel()
+	//
+	// The context is cancelled when the build is cancelled. The
+	// Provisioner should then stop its execution as quickly as possible
+	// and return, so the builder can clean up.
+	Provision(context.Context, Ui, Communicator) error
 }
 
 // A Hook implementation that runs the given provisioners.
@@ -33,11 +35,12 @@ type ProvisionHook struct {
 	Provisioners     []Provisioner
 	ProvisionerTypes []string
 
-	lock               sync.Mutex
-	runningProvisioner Provisioner
+	lock   sync.Mutex
+	cancel context.CancelFunc
 }
 
-// Runs the provisioners in order.
+// Runs the provisioners in order, or the range of them given by the data
+// of ProvisionRangeData.
 func (h *ProvisionHook) Run(name string, ui Ui, comm Communicator, data interface{}) error {
 	// Shortcut
 	if len(h.Provisioners) == 0 {
@@ -51,20 +54,34 @@ func (h *ProvisionHook) Run(name string, ui Ui, comm Communicator, data interfac
 				"then a communicator is required. Please fix this to continue.")
 	}
 
+	from, to, err := provisionRange(data, len(h.Provisioners))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.lock.Lock()
+	h.cancel = cancel
+	h.lock.Unlock()
+
 	defer func() {
 		h.lock.Lock()
 		defer h.lock.Unlock()
 
-		h.runningProvisioner = nil
+		h.cancel = nil
+		cancel()
 	}()
 
-	for i, p := range h.Provisioners {
-		h.lock.Lock()
-		h.runningProvisioner = p
-		h.lock.Unlock()
+	for i := from; i < to; i++ {
+		p := h.Provisioners[i]
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
 		ts := CheckpointReporter.AddSpan(h.ProvisionerTypes[i], "provisioner")
-		err := p.Provision(ui, comm)
+		end := StartTiming(ui, "provisioner", h.ProvisionerTypes[i])
+		err := p.Provision(ctx, ui, comm)
+		end(err)
 		ts.End(err)
 		if err != nil {
 			return err
@@ -79,84 +96,197 @@ func (h *ProvisionHook) Cancel() {
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
-	if h.runningProvisioner != nil {
-		h.runningProvisioner.Cancel()
+	if h.cancel != nil {
+		h.cancel()
 	}
 }
 
 // PausedProvisioner is a Provisioner implementation that pauses before
-// the provisioner is actually run.
+// and/or after the provisioner is actually run.
 type PausedProvisioner struct {
 	PauseBefore time.Duration
+	PauseAfter  time.Duration
 	Provisioner Provisioner
-
-	cancelCh chan struct{}
-	doneCh   chan struct{}
-	lock     sync.Mutex
 }
 
 func (p *PausedProvisioner) Prepare(raws ...interface{}) error {
 	return p.Provisioner.Prepare(raws...)
 }
 
-func (p *PausedProvisioner) Provision(ui Ui, comm Communicator) error {
-	p.lock.Lock()
-	cancelCh := make(chan struct{})
-	p.cancelCh = cancelCh
+func (p *PausedProvisioner) Validate(ctx *ValidateContext) error {
+	return ValidateComponent(p.Provisioner, ctx)
+}
 
-	// Setup the done channel, which is trigger when we're done
-	doneCh := make(chan struct{})
-	defer close(doneCh)
-	p.doneCh = doneCh
-	p.lock.Unlock()
+func (p *PausedProvisioner) InspectConfig() (map[string]interface{}, error) {
+	return InspectComponent(p.Provisioner)
+}
 
-	defer func() {
-		p.lock.Lock()
-		defer p.lock.Unlock()
-		if p.cancelCh == cancelCh {
-			p.cancelCh = nil
-		}
-		if p.doneCh == doneCh {
-			p.doneCh = nil
-		}
-	}()
+func (p *PausedProvisioner) Metadata() ArtifactMetadata {
+	return ProvisionerMetadataOf(p.Provisioner)
+}
 
+func (p *PausedProvisioner) Provision(ctx context.Context, ui Ui, comm Communicator) error {
 	// Use a select to determine if we get cancelled during the wait
-	ui.Say(fmt.Sprintf("Pausing %s before the next provisioner...", p.PauseBefore))
-	select {
-	case <-time.After(p.PauseBefore):
-	case <-cancelCh:
-		return nil
+	if p.PauseBefore > 0 {
+		ui.Say(fmt.Sprintf("Pausing %s before the next provisioner...", p.PauseBefore))
+		select {
+		case <-time.After(p.PauseBefore):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
-	provDoneCh := make(chan error, 1)
-	go p.provision(provDoneCh, ui, comm)
+	if err := p.Provisioner.Provision(ctx, ui, comm); err != nil || p.PauseAfter <= 0 {
+		return err
+	}
 
+	ui.Say(fmt.Sprintf("Pausing %s after the provisioner...", p.PauseAfter))
 	select {
-	case err := <-provDoneCh:
-		return err
-	case <-cancelCh:
-		p.Provisioner.Cancel()
-		return <-provDoneCh
+	case <-time.After(p.PauseAfter):
+	case <-ctx.Done():
 	}
+
+	return nil
+}
+
+// TimeoutProvisioner is a Provisioner implementation that cancels the
+// provisioner if it runs for longer than the timeout.
+type TimeoutProvisioner struct {
+	Timeout     time.Duration
+	Provisioner Provisioner
+}
+
+func (p *TimeoutProvisioner) Prepare(raws ...interface{}) error {
+	return p.Provisioner.Prepare(raws...)
 }
 
-func (p *PausedProvisioner) Cancel() {
-	var doneCh chan struct{}
+func (p *TimeoutProvisioner) Validate(ctx *ValidateContext) error {
+	return ValidateComponent(p.Provisioner, ctx)
+}
+
+func (p *TimeoutProvisioner) InspectConfig() (map[string]interface{}, error) {
+	return InspectComponent(p.Provisioner)
+}
+
+func (p *TimeoutProvisioner) Metadata() ArtifactMetadata {
+	return ProvisionerMetadataOf(p.Provisioner)
+}
 
-	p.lock.Lock()
-	if p.cancelCh != nil {
-		close(p.cancelCh)
-		p.cancelCh = nil
+func (p *TimeoutProvisioner) Provision(ctx context.Context, ui Ui, comm Communicator) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	timer := time.AfterFunc(p.Timeout, func() {
+		ui.Error(fmt.Sprintf("Cancelling provisioner after a timeout of %s...", p.Timeout))
+	})
+	defer timer.Stop()
+
+	err := p.Provisioner.Provision(ctx, ui, comm)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("provisioner timed out after %s", p.Timeout)
 	}
-	if p.doneCh != nil {
-		doneCh = p.doneCh
+	return err
+}
+
+// RetriedProvisioner is a Provisioner implementation that runs the
+// provisioner again whenever it fails, up to MaxRetries times.
+type RetriedProvisioner struct {
+	MaxRetries  int
+	Provisioner Provisioner
+}
+
+func (p *RetriedProvisioner) Prepare(raws ...interface{}) error {
+	return p.Provisioner.Prepare(raws...)
+}
+
+func (p *RetriedProvisioner) Validate(ctx *ValidateContext) error {
+	return ValidateComponent(p.Provisioner, ctx)
+}
+
+func (p *RetriedProvisioner) InspectConfig() (map[string]interface{}, error) {
+	return InspectComponent(p.Provisioner)
+}
+
+func (p *RetriedProvisioner) Metadata() ArtifactMetadata {
+	return ProvisionerMetadataOf(p.Provisioner)
+}
+
+func (p *RetriedProvisioner) Provision(ctx context.Context, ui Ui, comm Communicator) error {
+	var err error
+	for i := 0; i <= p.MaxRetries; i++ {
+		if i > 0 {
+			ui.Error(fmt.Sprintf("Provisioner failed: %s", err))
+			ui.Say(fmt.Sprintf("Retrying provisioner (%d/%d)...", i, p.MaxRetries))
+		}
+
+		budget := RetryBudget{Attempt: i + 1, MaxRetries: p.MaxRetries}
+		err = p.Provisioner.Provision(WithRetryBudget(ctx, budget), ui, comm)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
 	}
-	p.lock.Unlock()
 
-	<-doneCh
+	return err
+}
+
+// OutputFileProvisioner is a Provisioner implementation that writes
+// all of the output of the provisioner to a file on the host, in addition
+// to showing it to the user.
+type OutputFileProvisioner struct {
+	Path        string
+	Provisioner Provisioner
+}
+
+func (p *OutputFileProvisioner) Prepare(raws ...interface{}) error {
+	return p.Provisioner.Prepare(raws...)
+}
+
+func (p *OutputFileProvisioner) Validate(ctx *ValidateContext) error {
+	return ValidateComponent(p.Provisioner, ctx)
+}
+
+func (p *OutputFileProvisioner) InspectConfig() (map[string]interface{}, error) {
+	return InspectComponent(p.Provisioner)
+}
+
+func (p *OutputFileProvisioner) Metadata() ArtifactMetadata {
+	return ProvisionerMetadataOf(p.Provisioner)
+}
+
+func (p *OutputFileProvisioner) Provision(ctx context.Context, ui Ui, comm Communicator) error {
+	f, err := os.Create(p.Path)
+	if err != nil {
+		return fmt.Errorf("Error creating output file: %s", err)
+	}
+	defer f.Close()
+
+	return p.Provisioner.Provision(ctx, &teeUi{Ui: ui, w: f}, comm)
+}
+
+// teeUi is a Ui that also writes every message it shows to a writer.
+type teeUi struct {
+	Ui
+	w io.Writer
+	l sync.Mutex
+}
+
+func (u *teeUi) Say(message string) {
+	u.write(message)
+	u.Ui.Say(message)
+}
+
+func (u *teeUi) Message(message string) {
+	u.write(message)
+	u.Ui.Message(message)
+}
+
+func (u *teeUi) Error(message string) {
+	u.write(message)
+	u.Ui.Error(message)
 }
 
-func (p *PausedProvisioner) provision(result chan<- error, ui Ui, comm Communicator) {
-	result <- p.Provisioner.Provision(ui, comm)
+func (u *teeUi) write(message string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+	fmt.Fprintln(u.w, message)
 }
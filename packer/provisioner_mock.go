@@ -1,16 +1,19 @@
 package packer
 
+import (
+	"context"
+)
+
 // MockProvisioner is an implementation of Provisioner that can be
 // used for tests.
 type MockProvisioner struct {
-	ProvFunc func() error
+	ProvFunc func(context.Context) error
 
 	PrepCalled       bool
 	PrepConfigs      []interface{}
 	ProvCalled       bool
 	ProvCommunicator Communicator
 	ProvUi           Ui
-	CancelCalled     bool
 }
 
 func (t *MockProvisioner) Prepare(configs ...interface{}) error {
@@ -19,7 +22,7 @@ func (t *MockProvisioner) Prepare(configs ...interface{}) error {
 	return nil
 }
 
-func (t *MockProvisioner) Provision(ui Ui, comm Communicator) error {
+func (t *MockProvisioner) Provision(ctx context.Context, ui Ui, comm Communicator) error {
 	t.ProvCalled = true
 	t.ProvCommunicator = comm
 	t.ProvUi = ui
@@ -28,9 +31,5 @@ func (t *MockProvisioner) Provision(ui Ui, comm Communicator) error {
 		return nil
 	}
 
-	return t.ProvFunc()
-}
-
-func (t *MockProvisioner) Cancel() {
-	t.CancelCalled = true
+	return t.ProvFunc(ctx)
 }
@@ -1,7 +1,11 @@
 package packer
 
 import (
-	"sync"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -38,6 +42,40 @@ func TestProvisionHook(t *testing.T) {
 	}
 }
 
+func TestProvisionHook_range(t *testing.T) {
+	pA := &MockProvisioner{}
+	pB := &MockProvisioner{}
+	pC := &MockProvisioner{}
+
+	ui := testUi()
+	var comm Communicator = new(MockCommunicator)
+
+	hook := &ProvisionHook{
+		Provisioners:     []Provisioner{pA, pB, pC},
+		ProvisionerTypes: []string{"", "", ""},
+	}
+
+	if err := hook.Run("foo", ui, comm, ProvisionRangeData(1, 2)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if pA.ProvCalled || !pB.ProvCalled || pC.ProvCalled {
+		t.Fatalf("only pB should be called: %t %t %t", pA.ProvCalled, pB.ProvCalled, pC.ProvCalled)
+	}
+
+	// Ranges come back as pointers with 64-bit numbers over RPC
+	data := map[string]interface{}{"provision_from": int64(2), "provision_to": int64(-1)}
+	if err := hook.Run("foo", ui, comm, &data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !pC.ProvCalled {
+		t.Fatal("provision should be called on pC")
+	}
+
+	if err := hook.Run("foo", ui, comm, ProvisionRangeData(2, 4)); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestProvisionHook_nilComm(t *testing.T) {
 	pA := &MockProvisioner{}
 	pB := &MockProvisioner{}
@@ -58,45 +96,40 @@ func TestProvisionHook_nilComm(t *testing.T) {
 }
 
 func TestProvisionHook_cancel(t *testing.T) {
-	var lock sync.Mutex
-	order := make([]string, 0, 2)
-
+	started := make(chan struct{})
 	p := &MockProvisioner{
-		ProvFunc: func() error {
-			time.Sleep(50 * time.Millisecond)
-
-			lock.Lock()
-			defer lock.Unlock()
-			order = append(order, "prov")
-
-			return nil
+		ProvFunc: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
 		},
 	}
+	second := new(MockProvisioner)
 
 	hook := &ProvisionHook{
-		Provisioners:     []Provisioner{p},
-		ProvisionerTypes: []string{""},
+		Provisioners:     []Provisioner{p, second},
+		ProvisionerTypes: []string{"", ""},
 	}
 
-	finished := make(chan struct{})
+	finished := make(chan error)
 	go func() {
-		hook.Run("foo", nil, new(MockCommunicator), nil)
-		close(finished)
+		finished <- hook.Run("foo", nil, new(MockCommunicator), nil)
 	}()
 
 	// Cancel it while it is running
-	time.Sleep(10 * time.Millisecond)
+	<-started
 	hook.Cancel()
-	lock.Lock()
-	order = append(order, "cancel")
-	lock.Unlock()
 
-	// Wait
-	<-finished
-
-	// Verify order
-	if len(order) != 2 || order[0] != "cancel" || order[1] != "prov" {
-		t.Fatalf("bad: %#v", order)
+	select {
+	case err := <-finished:
+		if err != context.Canceled {
+			t.Fatalf("bad: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("provisioner wasn't cancelled")
+	}
+	if second.ProvCalled {
+		t.Fatal("provisioners after the cancelled one shouldn't run")
 	}
 }
 
@@ -129,7 +162,7 @@ func TestPausedProvisionerProvision(t *testing.T) {
 
 	ui := testUi()
 	comm := new(MockCommunicator)
-	prov.Provision(ui, comm)
+	prov.Provision(context.Background(), ui, comm)
 	if !mock.ProvCalled {
 		t.Fatal("prov should be called")
 	}
@@ -149,12 +182,12 @@ func TestPausedProvisionerProvision_waits(t *testing.T) {
 	}
 
 	dataCh := make(chan struct{})
-	mock.ProvFunc = func() error {
+	mock.ProvFunc = func(context.Context) error {
 		close(dataCh)
 		return nil
 	}
 
-	go prov.Provision(testUi(), new(MockCommunicator))
+	go prov.Provision(context.Background(), testUi(), new(MockCommunicator))
 
 	select {
 	case <-time.After(10 * time.Millisecond):
@@ -172,23 +205,136 @@ func TestPausedProvisionerProvision_waits(t *testing.T) {
 func TestPausedProvisionerCancel(t *testing.T) {
 	mock := new(MockProvisioner)
 	prov := &PausedProvisioner{
+		PauseBefore: time.Minute,
+		Provisioner: mock,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := prov.Provision(ctx, testUi(), new(MockCommunicator)); err != context.Canceled {
+		t.Fatalf("bad: %v", err)
+	}
+	if mock.ProvCalled {
+		t.Fatal("prov shouldn't be called")
+	}
+}
+
+func TestPausedProvisionerProvision_pauseAfter(t *testing.T) {
+	mock := new(MockProvisioner)
+	prov := &PausedProvisioner{
+		PauseAfter:  50 * time.Millisecond,
 		Provisioner: mock,
 	}
 
-	provCh := make(chan struct{})
-	mock.ProvFunc = func() error {
-		close(provCh)
-		time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	if err := prov.Provision(context.Background(), testUi(), new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !mock.ProvCalled {
+		t.Fatal("prov should be called")
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("should pause after provisioning")
+	}
+}
+
+func TestTimeoutProvisioner_impl(t *testing.T) {
+	var _ Provisioner = new(TimeoutProvisioner)
+}
+
+func TestTimeoutProvisionerProvision(t *testing.T) {
+	mock := new(MockProvisioner)
+	mock.ProvFunc = func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("cancelled")
+	}
+
+	prov := &TimeoutProvisioner{
+		Timeout:     10 * time.Millisecond,
+		Provisioner: mock,
+	}
+
+	err := prov.Provision(context.Background(), testUi(), new(MockCommunicator))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestRetriedProvisioner_impl(t *testing.T) {
+	var _ Provisioner = new(RetriedProvisioner)
+}
+
+func TestRetriedProvisionerProvision(t *testing.T) {
+	count := 0
+	mock := new(MockProvisioner)
+	mock.ProvFunc = func(ctx context.Context) error {
+		count++
+		if b := RetryBudgetOf(ctx); b.Attempt != count || b.Remaining() != 3-count {
+			t.Fatalf("bad budget on attempt %d: %#v", count, b)
+		}
+		if count < 3 {
+			return errors.New("fail")
+		}
 		return nil
 	}
 
-	// Start provisioning and wait for it to start
-	go prov.Provision(testUi(), new(MockCommunicator))
-	<-provCh
+	prov := &RetriedProvisioner{
+		MaxRetries:  2,
+		Provisioner: mock,
+	}
+	if err := prov.Provision(context.Background(), testUi(), new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if count != 3 {
+		t.Fatalf("bad: %d", count)
+	}
 
-	// Cancel it
-	prov.Cancel()
-	if !mock.CancelCalled {
-		t.Fatal("cancel should be called")
+	count = 0
+	prov.MaxRetries = 1
+	mock.ProvFunc = func(context.Context) error {
+		count++
+		return errors.New("fail")
+	}
+	if err := prov.Provision(context.Background(), testUi(), new(MockCommunicator)); err == nil {
+		t.Fatal("should error")
+	}
+	if count != 2 {
+		t.Fatalf("bad: %d", count)
+	}
+}
+
+func TestOutputFileProvisioner_impl(t *testing.T) {
+	var _ Provisioner = new(OutputFileProvisioner)
+}
+
+func TestOutputFileProvisionerProvision(t *testing.T) {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	mock := new(MockProvisioner)
+	prov := &OutputFileProvisioner{
+		Path:        tf.Name(),
+		Provisioner: mock,
+	}
+	mock.ProvFunc = func(context.Context) error {
+		mock.ProvUi.Say("hello")
+		mock.ProvUi.Error("world")
+		return nil
+	}
+
+	if err := prov.Provision(context.Background(), testUi(), new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents, err := ioutil.ReadFile(tf.Name())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(contents) != "hello\nworld\n" {
+		t.Fatalf("bad: %q", contents)
 	}
 }
@@ -0,0 +1,53 @@
+package packer
+
+import (
+	"context"
+	"time"
+)
+
+// RetryBudget is what is left of the attempts and time of a provisioner.
+// Provisioners find it in the context they run with, see RetryBudgetOf,
+// to behave differently on their last attempt or when they are short of
+// time.
+type RetryBudget struct {
+	// Attempt is the number of the current attempt, starting at 1.
+	Attempt int
+
+	// MaxRetries is how many times the provisioner is retried at most.
+	MaxRetries int
+
+	// Deadline is when the current attempt times out, zero if it
+	// doesn't.
+	Deadline time.Time
+}
+
+// Remaining returns how many more times the provisioner is retried if
+// the current attempt fails.
+func (b RetryBudget) Remaining() int {
+	if r := b.MaxRetries - b.Attempt + 1; r > 0 {
+		return r
+	}
+	return 0
+}
+
+type retryBudgetKey struct{}
+
+// WithRetryBudget returns a copy of the context with the retry budget.
+func WithRetryBudget(ctx context.Context, b RetryBudget) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, b)
+}
+
+// RetryBudgetOf returns the retry budget of a provisioner running with
+// the context. Provisioners that aren't retried are on their first and
+// only attempt. The deadline of the context is used unless the budget
+// has one.
+func RetryBudgetOf(ctx context.Context) RetryBudget {
+	b, ok := ctx.Value(retryBudgetKey{}).(RetryBudget)
+	if !ok {
+		b = RetryBudget{Attempt: 1}
+	}
+	if deadline, ok := ctx.Deadline(); ok && b.Deadline.IsZero() {
+		b.Deadline = deadline
+	}
+	return b
+}
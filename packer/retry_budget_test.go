@@ -0,0 +1,22 @@
+package packer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetryBudgetOf(t *testing.T) {
+	b := RetryBudgetOf(context.Background())
+	if b.Attempt != 1 || b.Remaining() != 0 || !b.Deadline.IsZero() {
+		t.Fatalf("bad: %#v", b)
+	}
+
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	b = RetryBudgetOf(WithRetryBudget(ctx, RetryBudget{Attempt: 2, MaxRetries: 4}))
+	if b.Attempt != 2 || b.Remaining() != 3 || !b.Deadline.Equal(deadline) {
+		t.Fatalf("bad: %#v", b)
+	}
+}
@@ -1,8 +1,10 @@
 package rpc
 
 import (
-	"github.com/hashicorp/packer/packer"
+	"log"
 	"net/rpc"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 // An implementation of packer.Artifact where the artifact is actually
@@ -43,6 +45,14 @@ func (a *artifact) State(name string) (result interface{}) {
 	return
 }
 
+func (a *artifact) Metadata() packer.ArtifactMetadata {
+	result := make(packer.ArtifactMetadata)
+	if err := a.client.Call(a.endpoint+".Metadata", new(interface{}), &result); err != nil {
+		log.Printf("Artifact.Metadata err: %s", err)
+	}
+	return result
+}
+
 func (a *artifact) Destroy() error {
 	var result error
 	if err := a.client.Call(a.endpoint+".Destroy", new(interface{}), &result); err != nil {
@@ -77,6 +87,11 @@ func (s *ArtifactServer) State(name string, reply *interface{}) error {
 	return nil
 }
 
+func (s *ArtifactServer) Metadata(args *interface{}, reply *packer.ArtifactMetadata) error {
+	*reply = packer.ArtifactMetadataOf(s.artifact)
+	return nil
+}
+
 func (s *ArtifactServer) Destroy(args *interface{}, reply *error) error {
 	err := s.artifact.Destroy()
 	if err != nil {
@@ -36,6 +36,23 @@ func TestArtifactRPC(t *testing.T) {
 	}
 }
 
+func TestArtifactRPC_metadata(t *testing.T) {
+	metadata := packer.ArtifactMetadata{
+		packer.MetadataRegion:  {"us-east-1", "eu-west-1"},
+		packer.MetadataImageId: {"ami-1", "ami-2"},
+	}
+	a := packer.WithArtifactMetadata(new(packer.MockArtifact), metadata)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	if result := packer.ArtifactMetadataOf(client.Artifact()); !reflect.DeepEqual(result, metadata) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
 func TestArtifact_Implements(t *testing.T) {
 	var _ packer.Artifact = new(artifact)
 }
@@ -74,6 +74,12 @@ func (b *build) SetDebug(val bool) {
 	}
 }
 
+func (b *build) SetDevelop(val bool) {
+	if err := b.client.Call("Build.SetDevelop", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
 func (b *build) SetForce(val bool) {
 	if err := b.client.Call("Build.SetForce", val, new(interface{})); err != nil {
 		panic(err)
@@ -86,6 +92,25 @@ func (b *build) SetOnError(val string) {
 	}
 }
 
+func (b *build) SetBuildArtifacts(val map[string]string) {
+	if err := b.client.Call("Build.SetBuildArtifacts", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) Validate(ctx *packer.ValidateContext) error {
+	return validateCall(b.client, "Build.Validate", ctx)
+}
+
+func (b *build) Timings() []packer.Timing {
+	var result []packer.Timing
+	if err := b.client.Call("Build.Timings", new(interface{}), &result); err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
 func (b *build) Cancel() {
 	if err := b.client.Call("Build.Cancel", new(interface{}), new(interface{})); err != nil {
 		panic(err)
@@ -136,6 +161,11 @@ func (b *BuildServer) SetDebug(val *bool, reply *interface{}) error {
 	return nil
 }
 
+func (b *BuildServer) SetDevelop(val *bool, reply *interface{}) error {
+	b.build.SetDevelop(*val)
+	return nil
+}
+
 func (b *BuildServer) SetForce(val *bool, reply *interface{}) error {
 	b.build.SetForce(*val)
 	return nil
@@ -146,6 +176,24 @@ func (b *BuildServer) SetOnError(val *string, reply *interface{}) error {
 	return nil
 }
 
+func (b *BuildServer) SetBuildArtifacts(val map[string]string, reply *interface{}) error {
+	b.build.SetBuildArtifacts(val)
+	return nil
+}
+
+func (b *BuildServer) Timings(args *interface{}, reply *[]packer.Timing) error {
+	*reply = b.build.Timings()
+	return nil
+}
+
+func (b *BuildServer) Validate(ctx *packer.ValidateContext, reply *interface{}) error {
+	if err := b.build.Validate(ctx); err != nil {
+		return NewBasicError(err)
+	}
+
+	return nil
+}
+
 func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.build.Cancel()
 	return nil
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/packer"
 )
@@ -18,8 +19,13 @@ type testBuild struct {
 	runCache         packer.Cache
 	runUi            packer.Ui
 	setDebugCalled   bool
+	setDevelopCalled bool
 	setForceCalled   bool
 	setOnErrorCalled bool
+	buildArtifacts   map[string]string
+	validateCalled   bool
+	validateCtx      *packer.ValidateContext
+	timingsCalled    bool
 	cancelCalled     bool
 
 	errRunResult bool
@@ -51,6 +57,10 @@ func (b *testBuild) SetDebug(bool) {
 	b.setDebugCalled = true
 }
 
+func (b *testBuild) SetDevelop(bool) {
+	b.setDevelopCalled = true
+}
+
 func (b *testBuild) SetForce(bool) {
 	b.setForceCalled = true
 }
@@ -59,6 +69,21 @@ func (b *testBuild) SetOnError(string) {
 	b.setOnErrorCalled = true
 }
 
+func (b *testBuild) SetBuildArtifacts(val map[string]string) {
+	b.buildArtifacts = val
+}
+
+func (b *testBuild) Validate(ctx *packer.ValidateContext) error {
+	b.validateCalled = true
+	b.validateCtx = ctx
+	return nil
+}
+
+func (b *testBuild) Timings() []packer.Timing {
+	b.timingsCalled = true
+	return []packer.Timing{{Type: "builder", Name: "foo", Duration: time.Minute}}
+}
+
 func (b *testBuild) Cancel() {
 	b.cancelCalled = true
 }
@@ -116,6 +141,12 @@ func TestBuild(t *testing.T) {
 		t.Fatal("should be called")
 	}
 
+	// Test SetDevelop
+	bClient.SetDevelop(true)
+	if !b.setDevelopCalled {
+		t.Fatal("should be called")
+	}
+
 	// Test SetForce
 	bClient.SetForce(true)
 	if !b.setForceCalled {
@@ -128,6 +159,29 @@ func TestBuild(t *testing.T) {
 		t.Fatal("should be called")
 	}
 
+	// Test SetBuildArtifacts
+	bClient.SetBuildArtifacts(map[string]string{"base": "id"})
+	if b.buildArtifacts["base"] != "id" {
+		t.Fatalf("bad: %#v", b.buildArtifacts)
+	}
+
+	// Test Validate
+	if err := bClient.Validate(&packer.ValidateContext{CheckRemote: true}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !b.validateCalled || !b.validateCtx.CheckRemote {
+		t.Fatalf("bad: %#v", b.validateCtx)
+	}
+
+	// Test Timings
+	timings := bClient.Timings()
+	if !b.timingsCalled {
+		t.Fatal("should be called")
+	}
+	if len(timings) != 1 || timings[0].Name != "foo" || timings[0].Duration != time.Minute {
+		t.Fatalf("bad: %#v", timings)
+	}
+
 	// Test Cancel
 	bClient.Cancel()
 	if !b.cancelCalled {
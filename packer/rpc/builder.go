@@ -68,6 +68,14 @@ func (b *builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return client.Artifact(), nil
 }
 
+func (b *builder) Validate(ctx *packer.ValidateContext) error {
+	return validateCall(b.client, "Builder.Validate", ctx)
+}
+
+func (b *builder) InspectConfig() (map[string]interface{}, error) {
+	return inspectConfigCall(b.client, "Builder.InspectConfig")
+}
+
 func (b *builder) Cancel() {
 	if err := b.client.Call("Builder.Cancel", new(interface{}), new(interface{})); err != nil {
 		log.Printf("Error cancelling builder: %s", err)
@@ -107,6 +115,18 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 	return nil
 }
 
+func (b *BuilderServer) Validate(ctx *packer.ValidateContext, reply *interface{}) error {
+	if err := packer.ValidateComponent(b.builder, ctx); err != nil {
+		return NewBasicError(err)
+	}
+
+	return nil
+}
+
+func (b *BuilderServer) InspectConfig(args *interface{}, reply *[]byte) error {
+	return inspectConfigReply(b.builder, reply)
+}
+
 func (b *BuilderServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.builder.Cancel()
 	return nil
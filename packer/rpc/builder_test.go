@@ -1,9 +1,11 @@
 package rpc
 
 import (
-	"github.com/hashicorp/packer/packer"
+	"errors"
 	"reflect"
 	"testing"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 var testBuilderArtifact = &packer.MockArtifact{}
@@ -144,3 +146,25 @@ func TestBuilderCancel(t *testing.T) {
 func TestBuilder_ImplementsBuilder(t *testing.T) {
 	var _ packer.Builder = new(builder)
 }
+
+func TestBuilderValidate(t *testing.T) {
+	b := new(packer.MockBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if err := bClient.(packer.Validator).Validate(&packer.ValidateContext{CheckRemote: true}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !b.ValidateCalled || !b.ValidateCtx.CheckRemote {
+		t.Fatalf("bad: %#v", b.ValidateCtx)
+	}
+
+	b.ValidateErr = errors.New("bad image")
+	err := bClient.(packer.Validator).Validate(&packer.ValidateContext{})
+	if err == nil || err.Error() != "bad image" {
+		t.Fatalf("bad: %s", err)
+	}
+}
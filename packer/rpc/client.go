@@ -101,6 +101,12 @@ func (c *Client) Communicator() packer.Communicator {
 	}
 }
 
+func (c *Client) Fixer() packer.Fixer {
+	return &fixer{
+		client: c.client,
+	}
+}
+
 func (c *Client) Hook() packer.Hook {
 	return &hook{
 		client: c.client,
@@ -7,6 +7,7 @@ import (
 	"net/rpc"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/packer/packer"
 )
@@ -27,6 +28,7 @@ type CommunicatorServer struct {
 
 type CommandFinished struct {
 	ExitStatus int
+	Reconnects int
 }
 
 type CommunicatorStartArgs struct {
@@ -35,6 +37,8 @@ type CommunicatorStartArgs struct {
 	StdoutStreamId   uint32
 	StderrStreamId   uint32
 	ResponseStreamId uint32
+	OperationTimeout time.Duration
+	ReceiveTimeout   time.Duration
 }
 
 type CommunicatorDownloadArgs struct {
@@ -67,6 +71,8 @@ func Communicator(client *rpc.Client) *communicator {
 func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 	var args CommunicatorStartArgs
 	args.Command = cmd.Command
+	args.OperationTimeout = cmd.OperationTimeout
+	args.ReceiveTimeout = cmd.ReceiveTimeout
 
 	var wg sync.WaitGroup
 
@@ -119,6 +125,9 @@ func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 		}
 
 		log.Printf("[INFO] RPC client: Communicator ended with: %d", finished.ExitStatus)
+		cmd.Lock()
+		cmd.Reconnects = finished.Reconnects
+		cmd.Unlock()
 		cmd.SetExited(finished.ExitStatus)
 	}()
 
@@ -176,6 +185,14 @@ func (c *communicator) DownloadDir(src string, dst string, exclude []string) err
 	return err
 }
 
+func (c *communicator) GuestFacts() *packer.GuestFacts {
+	facts := new(packer.GuestFacts)
+	if err := c.client.Call("Communicator.GuestFacts", new(interface{}), facts); err != nil {
+		log.Printf("Error getting the guest facts: %s", err)
+	}
+	return facts
+}
+
 func (c *communicator) Download(path string, w io.Writer) (err error) {
 	// Serve a single connection and a single copy
 	streamId := c.mux.NextId()
@@ -205,6 +222,8 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	// to the remote side.
 	var cmd packer.RemoteCmd
 	cmd.Command = args.Command
+	cmd.OperationTimeout = args.OperationTimeout
+	cmd.ReceiveTimeout = args.ReceiveTimeout
 
 	// Create a channel to signal we're done so that we can close
 	// our stdin/stdout/stderr streams
@@ -273,7 +292,7 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 		defer responseC.Close()
 		cmd.Wait()
 		log.Printf("[INFO] RPC endpoint: Communicator ended with: %d", cmd.ExitStatus)
-		responseWriter.Encode(&CommandFinished{cmd.ExitStatus})
+		responseWriter.Encode(&CommandFinished{cmd.ExitStatus, cmd.Reconnects})
 	}()
 
 	return nil
@@ -303,6 +322,11 @@ func (c *CommunicatorServer) DownloadDir(args *CommunicatorUploadDirArgs, reply
 	return c.c.DownloadDir(args.Src, args.Dst, args.Exclude)
 }
 
+func (c *CommunicatorServer) GuestFacts(args *interface{}, reply *packer.GuestFacts) error {
+	*reply = *packer.GuestFactsOf(c.c)
+	return nil
+}
+
 func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *interface{}) (err error) {
 	writerC, err := c.mux.Dial(args.WriterStreamId)
 	if err != nil {
@@ -163,3 +163,18 @@ func TestCommunicator_ImplementsCommunicator(t *testing.T) {
 		t.Fatal("should be a Communicator")
 	}
 }
+
+func TestCommunicatorRPC_guestFacts(t *testing.T) {
+	facts := &packer.GuestFacts{OSFamily: "windows", TempDir: `c:\Windows\Temp`}
+	c := packer.WithGuestFacts(new(packer.MockCommunicator), facts)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	if result := packer.GuestFactsOf(remote); !reflect.DeepEqual(result, facts) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
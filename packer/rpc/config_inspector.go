@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"encoding/json"
+	"log"
+	"net/rpc"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// inspectConfigCall calls the InspectConfig method of a remote component.
+// The configuration is sent as JSON, which keeps the types of its values.
+// Plugins built before InspectConfig existed don't have the method, so
+// they have no decoded configuration to return.
+func inspectConfigCall(client *rpc.Client, method string) (map[string]interface{}, error) {
+	var data []byte
+	err := client.Call(method, new(interface{}), &data)
+	if err != nil && strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		log.Printf("Plugin doesn't support %s, skipping", method)
+		return nil, nil
+	}
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// inspectConfigReply encodes the decoded configuration of a component for
+// inspectConfigCall.
+func inspectConfigReply(raw interface{}, reply *[]byte) error {
+	config, err := packer.InspectComponent(raw)
+	if err != nil {
+		return NewBasicError(err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return NewBasicError(err)
+	}
+	*reply = data
+	return nil
+}
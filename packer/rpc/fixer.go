@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/rpc"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// An implementation of packer.Fixer where the Fixer is actually executed
+// over an RPC connection.
+type fixer struct {
+	client *rpc.Client
+}
+
+// FixerServer wraps a packer.Fixer implementation and makes it exportable
+// as part of a Golang RPC server.
+type FixerServer struct {
+	f packer.Fixer
+}
+
+// The templates are sent as JSON, which they are decoded from, since the
+// codec of the RPC connection decodes nested objects as maps of
+// interface{} keys.
+type FixerFixArgs struct {
+	Input []byte
+}
+
+type FixerFixResponse struct {
+	Output []byte
+	Err    *BasicError
+}
+
+func (f *fixer) Fix(input map[string]interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var response FixerFixResponse
+	if err := f.client.Call("Fixer.Fix", &FixerFixArgs{Input: raw}, &response); err != nil {
+		return nil, err
+	}
+
+	if response.Err != nil {
+		return nil, response.Err
+	}
+
+	return decodeFixerTemplate(response.Output)
+}
+
+func (f *fixer) Synopsis() (result string) {
+	if err := f.client.Call("Fixer.Synopsis", new(interface{}), &result); err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+func (f *FixerServer) Fix(args *FixerFixArgs, reply *FixerFixResponse) error {
+	input, err := decodeFixerTemplate(args.Input)
+	if err != nil {
+		return NewBasicError(err)
+	}
+
+	output, err := f.f.Fix(input)
+	if err != nil {
+		*reply = FixerFixResponse{Err: NewBasicError(err)}
+		return nil
+	}
+
+	raw, err := json.Marshal(output)
+	*reply = FixerFixResponse{
+		Output: raw,
+		Err:    NewBasicError(err),
+	}
+
+	return nil
+}
+
+func (f *FixerServer) Synopsis(args *interface{}, reply *string) error {
+	*reply = f.f.Synopsis()
+	return nil
+}
+
+// decodeFixerTemplate decodes a template keeping its numbers as they are
+// written, so that they are encoded the same way once fixed.
+func decodeFixerTemplate(raw []byte) (map[string]interface{}, error) {
+	var tpl map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&tpl); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
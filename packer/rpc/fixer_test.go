@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestFixerRPC(t *testing.T) {
+	// Create the interface to test
+	f := &packer.MockFixer{
+		FixOutput: map[string]interface{}{
+			"builders": []interface{}{
+				map[string]interface{}{"type": "bar"},
+			},
+		},
+		SynopsisValue: "foo",
+	}
+
+	// Start the server
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterFixer(f)
+	fClient := client.Fixer()
+
+	// Test Fix
+	input := map[string]interface{}{
+		"builders": []interface{}{
+			map[string]interface{}{"type": "foo"},
+		},
+	}
+	output, err := fClient.Fix(input)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !f.FixCalled {
+		t.Fatal("should be called")
+	}
+	if !reflect.DeepEqual(f.FixInput, input) {
+		t.Fatalf("bad: %#v", f.FixInput)
+	}
+	if !reflect.DeepEqual(output, f.FixOutput) {
+		t.Fatalf("bad: %#v", output)
+	}
+
+	// Test Fix errors
+	f.FixError = errors.New("bad")
+	if _, err := fClient.Fix(input); err == nil || err.Error() != "bad" {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Test Synopsis
+	if synopsis := fClient.Synopsis(); synopsis != "foo" {
+		t.Fatalf("bad: %s", synopsis)
+	}
+}
+
+func TestFixer_Implements(t *testing.T) {
+	var _ packer.Fixer = new(fixer)
+}
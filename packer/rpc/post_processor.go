@@ -67,6 +67,14 @@ func (p *postProcessor) PostProcess(ui packer.Ui, a packer.Artifact) (packer.Art
 	return client.Artifact(), response.Keep, nil
 }
 
+func (p *postProcessor) Validate(ctx *packer.ValidateContext) error {
+	return validateCall(p.client, "PostProcessor.Validate", ctx)
+}
+
+func (p *postProcessor) InspectConfig() (map[string]interface{}, error) {
+	return inspectConfigCall(p.client, "PostProcessor.InspectConfig")
+}
+
 func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply *interface{}) error {
 	err := p.p.Configure(args.Configs...)
 	return err
@@ -96,3 +104,15 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 
 	return nil
 }
+
+func (p *PostProcessorServer) Validate(ctx *packer.ValidateContext, reply *interface{}) error {
+	if err := packer.ValidateComponent(p.p, ctx); err != nil {
+		return NewBasicError(err)
+	}
+
+	return nil
+}
+
+func (p *PostProcessorServer) InspectConfig(args *interface{}, reply *[]byte) error {
+	return inspectConfigReply(p.p, reply)
+}
@@ -1,9 +1,12 @@
 package rpc
 
 import (
-	"github.com/hashicorp/packer/packer"
+	"context"
 	"log"
 	"net/rpc"
+	"sync"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 // An implementation of packer.Provisioner where the provisioner is actually
@@ -18,6 +21,17 @@ type provisioner struct {
 type ProvisionerServer struct {
 	p   packer.Provisioner
 	mux *muxBroker
+
+	lock   sync.Mutex
+	cancel context.CancelFunc
+}
+
+// ProvisionerProvisionArgs are the arguments of Provision: the stream of
+// the Ui and communicator, and the retry budget of the context, which
+// doesn't cross the connection otherwise.
+type ProvisionerProvisionArgs struct {
+	StreamId    uint32
+	RetryBudget packer.RetryBudget
 }
 
 type ProvisionerPrepareArgs struct {
@@ -33,42 +47,97 @@ func (p *provisioner) Prepare(configs ...interface{}) (err error) {
 	return
 }
 
-func (p *provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	nextId := p.mux.NextId()
 	server := newServerWithMux(p.mux, nextId)
 	server.RegisterCommunicator(comm)
 	server.RegisterUi(ui)
 	go server.Serve()
 
-	return p.client.Call("Provisioner.Provision", nextId, new(interface{}))
+	args := &ProvisionerProvisionArgs{
+		StreamId:    nextId,
+		RetryBudget: packer.RetryBudgetOf(ctx),
+	}
+	call := p.client.Go("Provisioner.Provision", args, new(interface{}), nil)
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		// Cancel the context of the provisioner in the plugin and wait
+		// for it to return
+		err := p.client.Call("Provisioner.Cancel", new(interface{}), new(interface{}))
+		if err != nil {
+			log.Printf("Provisioner.Cancel err: %s", err)
+		}
+		<-call.Done
+	}
+
+	return call.Error
 }
 
-func (p *provisioner) Cancel() {
-	err := p.client.Call("Provisioner.Cancel", new(interface{}), new(interface{}))
-	if err != nil {
-		log.Printf("Provisioner.Cancel err: %s", err)
+func (p *provisioner) Validate(ctx *packer.ValidateContext) error {
+	return validateCall(p.client, "Provisioner.Validate", ctx)
+}
+
+func (p *provisioner) InspectConfig() (map[string]interface{}, error) {
+	return inspectConfigCall(p.client, "Provisioner.InspectConfig")
+}
+
+func (p *provisioner) Metadata() packer.ArtifactMetadata {
+	result := make(packer.ArtifactMetadata)
+	if err := p.client.Call("Provisioner.Metadata", new(interface{}), &result); err != nil {
+		log.Printf("Provisioner.Metadata err: %s", err)
 	}
+	return result
 }
 
 func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *interface{}) error {
 	return p.p.Prepare(args.Configs...)
 }
 
-func (p *ProvisionerServer) Provision(streamId uint32, reply *interface{}) error {
-	client, err := newClientWithMux(p.mux, streamId)
+func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *interface{}) error {
+	client, err := newClientWithMux(p.mux, args.StreamId)
 	if err != nil {
 		return NewBasicError(err)
 	}
 	defer client.Close()
 
-	if err := p.p.Provision(client.Ui(), client.Communicator()); err != nil {
+	ctx, cancel := context.WithCancel(
+		packer.WithRetryBudget(context.Background(), args.RetryBudget))
+	defer cancel()
+	p.lock.Lock()
+	p.cancel = cancel
+	p.lock.Unlock()
+
+	if err := p.p.Provision(ctx, client.Ui(), client.Communicator()); err != nil {
+		return NewBasicError(err)
+	}
+
+	return nil
+}
+
+func (p *ProvisionerServer) Validate(ctx *packer.ValidateContext, reply *interface{}) error {
+	if err := packer.ValidateComponent(p.p, ctx); err != nil {
 		return NewBasicError(err)
 	}
 
 	return nil
 }
 
+func (p *ProvisionerServer) InspectConfig(args *interface{}, reply *[]byte) error {
+	return inspectConfigReply(p.p, reply)
+}
+
+func (p *ProvisionerServer) Metadata(args *interface{}, reply *packer.ArtifactMetadata) error {
+	*reply = packer.ProvisionerMetadataOf(p.p)
+	return nil
+}
+
 func (p *ProvisionerServer) Cancel(args *interface{}, reply *interface{}) error {
-	p.p.Cancel()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+	}
 	return nil
 }
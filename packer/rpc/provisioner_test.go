@@ -1,9 +1,13 @@
 package rpc
 
 import (
-	"github.com/hashicorp/packer/packer"
+	"context"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 func TestProvisionerRPC(t *testing.T) {
@@ -31,15 +35,36 @@ func TestProvisionerRPC(t *testing.T) {
 	// Test Provision
 	ui := &testUi{}
 	comm := &packer.MockCommunicator{}
-	pClient.Provision(ui, comm)
+	pClient.Provision(context.Background(), ui, comm)
 	if !p.ProvCalled {
 		t.Fatal("should be called")
 	}
 
-	// Test Cancel
-	pClient.Cancel()
-	if !p.CancelCalled {
-		t.Fatal("cancel should be called")
+	// Test the retry budget crossing the connection
+	var budget packer.RetryBudget
+	p.ProvFunc = func(ctx context.Context) error {
+		budget = packer.RetryBudgetOf(ctx)
+		return nil
+	}
+	deadline := time.Now().Add(time.Hour).UTC()
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	ctx = packer.WithRetryBudget(ctx, packer.RetryBudget{Attempt: 2, MaxRetries: 3})
+	pClient.Provision(ctx, ui, comm)
+	cancel()
+	if budget.Attempt != 2 || budget.Remaining() != 2 || !budget.Deadline.Equal(deadline) {
+		t.Fatalf("bad: %#v", budget)
+	}
+
+	// Test cancelling the context
+	p.ProvFunc = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := pClient.Provision(ctx, ui, comm)
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Fatalf("bad: %v", err)
 	}
 }
 
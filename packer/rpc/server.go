@@ -16,6 +16,7 @@ const (
 	DefaultCacheEndpoint                = "Cache"
 	DefaultCommandEndpoint              = "Command"
 	DefaultCommunicatorEndpoint         = "Communicator"
+	DefaultFixerEndpoint                = "Fixer"
 	DefaultHookEndpoint                 = "Hook"
 	DefaultPostProcessorEndpoint        = "PostProcessor"
 	DefaultProvisionerEndpoint          = "Provisioner"
@@ -91,6 +92,12 @@ func (s *Server) RegisterCommunicator(c packer.Communicator) {
 	})
 }
 
+func (s *Server) RegisterFixer(f packer.Fixer) {
+	s.server.RegisterName(DefaultFixerEndpoint, &FixerServer{
+		f: f,
+	})
+}
+
 func (s *Server) RegisterHook(h packer.Hook) {
 	s.server.RegisterName(DefaultHookEndpoint, &HookServer{
 		hook: h,
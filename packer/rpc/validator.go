@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"log"
+	"net/rpc"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// validateCall calls the Validate method of a remote component. Plugins
+// built before Validate existed don't have the method, so they are treated
+// as having nothing more to check.
+func validateCall(client *rpc.Client, method string, ctx *packer.ValidateContext) error {
+	err := client.Call(method, ctx, new(interface{}))
+	if err != nil && strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		log.Printf("Plugin doesn't support %s, skipping", method)
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,164 @@
+package packer
+
+import (
+	"sync"
+	"time"
+)
+
+// TimingMachineType is the machine readable message type plugins use to
+// report timed sections to the core, see StartTiming. These messages are
+// consumed by the core and never shown.
+const TimingMachineType = "packer-timing"
+
+// Timing is the wall-clock duration of a part of a build, such as the
+// builder, one of its steps, a provisioner or a post-processor.
+type Timing struct {
+	// Type is what was timed: builder, step, provisioner, script or
+	// post-processor.
+	Type string
+
+	// Name is the name of what was timed, e.g. the type of the provisioner.
+	Name string
+
+	// Depth is the nesting level of the timing. Steps are nested in the
+	// builder and provisioners in the step that runs them.
+	Depth int
+
+	Start    time.Time
+	Duration time.Duration
+
+	// Error is the error the timed part failed with, if any.
+	Error string
+
+	running bool
+}
+
+// StartTiming reports the start of a timed section to the timing report
+// of the build and returns a function that reports its end. It works
+// through the Ui, so plugins can use it as well.
+func StartTiming(ui Ui, kind, name string) func(error) {
+	if ui == nil {
+		return func(error) {}
+	}
+
+	ui.Machine(TimingMachineType, "start", kind, name)
+	return func(err error) {
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		ui.Machine(TimingMachineType, "end", kind, name, msg)
+	}
+}
+
+// timingReport collects the timings of a single build. Sections nest, so
+// the report keeps a stack of the ones that are still running.
+type timingReport struct {
+	l       sync.Mutex
+	timings []*Timing
+	running []*Timing
+
+	// observe, if set, is called with each section that ends.
+	observe func(Timing)
+}
+
+func (r *timingReport) start(kind, name string) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	t := &Timing{
+		Type:    kind,
+		Name:    name,
+		Depth:   len(r.running),
+		Start:   time.Now(),
+		running: true,
+	}
+	r.timings = append(r.timings, t)
+	r.running = append(r.running, t)
+}
+
+// end finishes the innermost running section with the given type and
+// name. Sections started after it that were never ended, for example
+// because a plugin crashed, end with it.
+//
+// The sections are observed once the report is unlocked, so that observers
+// can take their time.
+func (r *timingReport) end(kind, name, err string) {
+	var ended []Timing
+
+	r.l.Lock()
+	for i := len(r.running) - 1; i >= 0; i-- {
+		t := r.running[i]
+		if t.Type != kind || t.Name != name {
+			continue
+		}
+
+		now := time.Now()
+		t.Error = err
+		for _, open := range r.running[i:] {
+			open.Duration = now.Sub(open.Start)
+			open.running = false
+			ended = append(ended, *open)
+		}
+		r.running = r.running[:i]
+		break
+	}
+	r.l.Unlock()
+
+	if r.observe != nil {
+		for _, t := range ended {
+			r.observe(t)
+		}
+	}
+}
+
+// Timings returns a copy of the collected timings. Sections that are
+// still running report their duration so far.
+func (r *timingReport) Timings() []Timing {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	result := make([]Timing, len(r.timings))
+	for i, t := range r.timings {
+		result[i] = *t
+		if t.running {
+			result[i].Duration = time.Since(t.Start)
+		}
+	}
+	return result
+}
+
+// timingUi records the timed sections plugins report through the Ui and
+// passes everything else on.
+type timingUi struct {
+	Ui
+	report *timingReport
+}
+
+func (u *timingUi) Machine(t string, args ...string) {
+	if t != TimingMachineType {
+		u.Ui.Machine(t, args...)
+		return
+	}
+	if len(args) < 3 {
+		return
+	}
+
+	switch args[0] {
+	case "start":
+		u.report.start(args[1], args[2])
+	case "end":
+		err := ""
+		if len(args) > 3 {
+			err = args[3]
+		}
+		u.report.end(args[1], args[2], err)
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
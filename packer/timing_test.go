@@ -0,0 +1,82 @@
+package packer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTimingUi(t *testing.T) {
+	report := new(timingReport)
+	out := new(bytes.Buffer)
+	ui := &timingUi{Ui: &MachineReadableUi{Writer: out}, report: report}
+
+	endStep := StartTiming(ui, "step", "StepProvision")
+	endProvisioner := StartTiming(ui, "provisioner", "powershell")
+	StartTiming(ui, "script", "updates.ps1")
+	// The script never ends, it ends with the provisioner
+	endProvisioner(errors.New("exit status 1"))
+	endStep(nil)
+	ui.Machine("artifact", "0", "id", "foo")
+
+	timings := report.Timings()
+	expected := []struct {
+		Type  string
+		Name  string
+		Depth int
+		Error string
+	}{
+		{"step", "StepProvision", 0, ""},
+		{"provisioner", "powershell", 1, "exit status 1"},
+		{"script", "updates.ps1", 2, ""},
+	}
+	if len(timings) != len(expected) {
+		t.Fatalf("bad: %#v", timings)
+	}
+	for i, e := range expected {
+		timing := timings[i]
+		if timing.Type != e.Type || timing.Name != e.Name || timing.Depth != e.Depth || timing.Error != e.Error {
+			t.Fatalf("bad timing %d: %#v", i, timing)
+		}
+		if timing.running {
+			t.Fatalf("timing %d is still running", i)
+		}
+	}
+
+	// Only other machine readable messages are passed on
+	if strings.Contains(out.String(), TimingMachineType) || !strings.Contains(out.String(), ",artifact,0,id,foo") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestTimingReport_Running(t *testing.T) {
+	report := new(timingReport)
+	report.start("builder", "null")
+	report.end("step", "StepUnknown", "")
+
+	timings := report.Timings()
+	if len(timings) != 1 || !timings[0].running {
+		t.Fatalf("bad: %#v", timings)
+	}
+}
+
+func TestTimingReport_Observe(t *testing.T) {
+	var observed []string
+	report := &timingReport{
+		observe: func(t Timing) {
+			observed = append(observed, t.Name)
+		},
+	}
+	report.start("builder", "null")
+	report.start("step", "StepProvision")
+	report.end("builder", "null", "")
+
+	if strings.Join(observed, ",") != "null,StepProvision" {
+		t.Fatalf("bad: %#v", observed)
+	}
+}
+
+func TestStartTiming_NilUi(t *testing.T) {
+	StartTiming(nil, "provisioner", "shell")(nil)
+}
@@ -0,0 +1,279 @@
+package packer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressInterval is how often the progress of long transfers is
+// reported. Transfers shorter than that aren't reported at all.
+var ProgressInterval = 5 * time.Second
+
+// sizeUnits are the units of sizes, in powers of 1024.
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB"}
+
+// ParseSize parses a size in bytes, or with a unit, like 50GB or 1.5G.
+func ParseSize(v string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	multiplier := float64(1)
+	for i := len(sizeUnits) - 1; i > 0; i-- {
+		unit := sizeUnits[i]
+		if strings.HasSuffix(s, unit) || strings.HasSuffix(s, unit[:1]) {
+			s = strings.TrimSuffix(strings.TrimSuffix(s, unit), unit[:1])
+			for j := 0; j < i; j++ {
+				multiplier *= 1024
+			}
+			break
+		}
+	}
+	s = strings.TrimSuffix(s, "B")
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q isn't a size, like 500MB or 50GB", v)
+	}
+	return int64(n * multiplier), nil
+}
+
+// FormatSize formats a size in bytes with the largest unit it has.
+func FormatSize(size int64) string {
+	v := float64(size)
+	unit := 0
+	for v >= 1024 && unit < len(sizeUnits)-1 {
+		v /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", v, sizeUnits[unit])
+}
+
+// DirSize returns the size of the regular files of a directory, as the
+// total of its transfer. It is zero if the directory can't be read.
+func DirSize(dir string) int64 {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Transfer limits the bandwidth of a file transfer and reports its
+// progress. The readers and writers it wraps share its count of bytes,
+// so a transfer of several files is limited and reported as a whole,
+// also when they are read or written concurrently.
+type Transfer struct {
+	// Limit is the maximum bandwidth in bytes per second. Zero means
+	// unlimited.
+	Limit int64
+
+	// Total is the size of the transfer in bytes, zero if unknown.
+	Total int64
+
+	// Progress, if set, is called with the bytes transferred so far and
+	// Total after each chunk.
+	Progress func(done, total int64)
+
+	l     sync.Mutex
+	done  int64
+	start time.Time
+}
+
+// Reader returns a reader of r counted by the transfer.
+func (t *Transfer) Reader(r io.Reader) io.Reader {
+	return &transferReader{r: r, t: t}
+}
+
+// Writer returns a writer to w counted by the transfer.
+func (t *Transfer) Writer(w io.Writer) io.Writer {
+	return &transferWriter{w: w, t: t}
+}
+
+// WriteCloser is Writer for writers that must be closed, like the
+// stdin of remote commands.
+func (t *Transfer) WriteCloser(w io.WriteCloser) io.WriteCloser {
+	return &transferWriteCloser{transferWriter{w: w, t: t}, w}
+}
+
+// Done returns the bytes transferred so far.
+func (t *Transfer) Done() int64 {
+	t.l.Lock()
+	defer t.l.Unlock()
+	return t.done
+}
+
+// chunk returns how many of n bytes can be transferred at once, so that
+// limited transfers are paced smoothly.
+func (t *Transfer) chunk(n int) int {
+	if t.Limit > 0 && int64(n) > t.Limit {
+		return int(t.Limit)
+	}
+	return n
+}
+
+// add counts n transferred bytes, then sleeps for as long as the
+// transfer is ahead of its limit.
+func (t *Transfer) add(n int) {
+	if n <= 0 {
+		return
+	}
+
+	t.l.Lock()
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	t.done += int64(n)
+	if t.Progress != nil {
+		t.Progress(t.done, t.Total)
+	}
+	var d time.Duration
+	if t.Limit > 0 {
+		expected := time.Duration(float64(t.done) / float64(t.Limit) * float64(time.Second))
+		d = expected - time.Since(t.start)
+	}
+	t.l.Unlock()
+
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+type transferReader struct {
+	r io.Reader
+	t *Transfer
+}
+
+func (r *transferReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p[:r.t.chunk(len(p))])
+	r.t.add(n)
+	return n, err
+}
+
+type transferWriter struct {
+	w io.Writer
+	t *Transfer
+}
+
+func (w *transferWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n, err := w.w.Write(p[written : written+w.t.chunk(len(p)-written)])
+		written += n
+		w.t.add(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+type transferWriteCloser struct {
+	transferWriter
+	c io.Closer
+}
+
+func (w *transferWriteCloser) Close() error {
+	return w.c.Close()
+}
+
+// ProgressBar reports the progress of a transfer. Interactive terminals
+// show it in place as it goes, other Uis print it every ProgressInterval.
+// Its Update method is meant as the Progress of a Transfer.
+type ProgressBar struct {
+	ui          Ui
+	id          string
+	description string
+
+	start    time.Time
+	updated  time.Time
+	shown    time.Time
+	logged   time.Time
+	done     int64
+	total    int64
+	reported bool
+}
+
+// NewProgressBar returns a progress bar of a transfer, described like
+// "Uploading foo.iso".
+func NewProgressBar(ui Ui, description string) *ProgressBar {
+	return &ProgressBar{ui: ui, id: NewStatusID(), description: description}
+}
+
+// Update records the progress of the transfer. It is shown every
+// StatusInterval, and logged if the last log is older than
+// ProgressInterval.
+func (p *ProgressBar) Update(done, total int64) {
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start, p.logged = now, now
+	}
+	p.updated = now
+	p.done, p.total = done, total
+
+	if now.Sub(p.logged) >= ProgressInterval {
+		p.shown, p.logged = now, now
+		p.reported = true
+		LogStatus(p.ui, p.id, p.String())
+	} else if now.Sub(p.shown) >= StatusInterval {
+		p.shown = now
+		SetStatus(p.ui, p.id, p.String())
+	}
+}
+
+// Finish removes the progress bar. The final progress of the transfer is
+// printed if its progress was logged before, so that short transfers
+// don't add to the output.
+func (p *ProgressBar) Finish() {
+	line := ""
+	if p.reported {
+		p.updated = time.Now()
+		line = p.String()
+	}
+	ClearStatus(p.ui, p.id, line)
+}
+
+const progressBarWidth = 20
+
+// String formats the progress like:
+//
+//	Uploading foo.iso: [=========>          ] 45% (1.2 GB of 2.7 GB, 10.0 MB/s)
+//
+// or without the bar and percentage if the total is unknown.
+func (p *ProgressBar) String() string {
+	rate := ""
+	if elapsed := p.updated.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = fmt.Sprintf(", %s/s", FormatSize(int64(float64(p.done)/elapsed)))
+	}
+
+	if p.total <= 0 {
+		return fmt.Sprintf("%s: %s%s", p.description, FormatSize(p.done), rate)
+	}
+
+	done := p.done
+	if done > p.total {
+		done = p.total
+	}
+	filled := int(done * progressBarWidth / p.total)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("%s: [%s] %d%% (%s of %s%s)", p.description, bar,
+		done*100/p.total, FormatSize(p.done), FormatSize(p.total), rate)
+}
@@ -0,0 +1,139 @@
+package packer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"2KB":   2048,
+		"1.5k":  1536,
+		"500MB": 500 * 1024 * 1024,
+		"50G":   50 * 1024 * 1024 * 1024,
+	}
+	for v, expected := range cases {
+		size, err := ParseSize(v)
+		if err != nil {
+			t.Fatalf("%s: err: %s", v, err)
+		}
+		if size != expected {
+			t.Fatalf("%s: got %d, expected %d", v, size, expected)
+		}
+	}
+
+	for _, v := range []string{"", "GB", "-1GB", "lots"} {
+		if _, err := ParseSize(v); err == nil {
+			t.Fatalf("%q: should error", v)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := map[int64]string{
+		0:                             "0 B",
+		1023:                          "1023 B",
+		1536:                          "1.5 KB",
+		2900 * 1024 * 1024:            "2.8 GB",
+		3 * 1024 * 1024 * 1024 * 1024: "3.0 TB",
+	}
+	for size, expected := range cases {
+		if actual := FormatSize(size); actual != expected {
+			t.Fatalf("%d: got %s, expected %s", size, actual, expected)
+		}
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	var calls int
+	var last int64
+	tr := &Transfer{
+		Total: 100,
+		Progress: func(done, total int64) {
+			calls++
+			last = done
+			if total != 100 {
+				t.Fatalf("bad total: %d", total)
+			}
+		},
+	}
+
+	var out bytes.Buffer
+	if _, err := io.Copy(tr.Writer(&out), tr.Reader(strings.NewReader(strings.Repeat("x", 50)))); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.Len() != 50 {
+		t.Fatalf("bad output: %d", out.Len())
+	}
+
+	// Both the reader and the writer count the bytes
+	if last != 100 || calls < 2 {
+		t.Fatalf("bad progress: %d bytes in %d calls", last, calls)
+	}
+	if tr.Done() != 100 {
+		t.Fatalf("bad done: %d", tr.Done())
+	}
+}
+
+func TestTransfer_limit(t *testing.T) {
+	tr := &Transfer{Limit: 4096}
+
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, tr.Reader(bytes.NewReader(make([]byte, 6144))))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != 6144 {
+		t.Fatalf("bad count: %d", n)
+	}
+
+	// 6 KB at 4 KB/s takes at least a second and a half
+	if elapsed := time.Since(start); elapsed < 1400*time.Millisecond {
+		t.Fatalf("transfer wasn't limited: %s", elapsed)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	defer func(i time.Duration) { ProgressInterval = i }(ProgressInterval)
+	ProgressInterval = 0
+
+	ui := testUi()
+	bar := NewProgressBar(ui, "Uploading foo.iso")
+	bar.Update(0, 2048)
+	bar.Update(1024, 2048)
+	bar.Finish()
+
+	out := readWriter(ui)
+	for _, expected := range []string{
+		"Uploading foo.iso: [==========>         ] 50% (1.0 KB of 2.0 KB",
+		"Uploading foo.iso: [>                   ] 0% (0 B of 2.0 KB",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("output should contain %q:\n%s", expected, out)
+		}
+	}
+
+	ui = testUi()
+	bar = NewProgressBar(ui, "Uploading dir")
+	bar.Update(2048, 0)
+	if out := readWriter(ui); !strings.Contains(out, "Uploading dir: 2.0 KB") {
+		t.Fatalf("bad output:\n%s", out)
+	}
+}
+
+func TestProgressBar_short(t *testing.T) {
+	ui := testUi()
+	bar := NewProgressBar(ui, "Uploading foo.iso")
+	bar.Update(1024, 2048)
+	bar.Update(2048, 2048)
+	bar.Finish()
+
+	if out := readWriter(ui); out != "" {
+		t.Fatalf("short transfers shouldn't be reported:\n%s", out)
+	}
+}
@@ -39,6 +39,65 @@ type Ui interface {
 	Machine(string, ...string)
 }
 
+// uiOutput is the kind of output of a Ui.
+type uiOutput int
+
+const (
+	uiSay uiOutput = iota
+	uiMessage
+	uiError
+	uiAsk
+)
+
+// uiSection is output of a target, or of the command itself if the
+// target is empty. The Uis pass it on as is, adding their decorations,
+// so that the BasicUi writing it translates the message only once,
+// before it is prefixed with the target and decorated.
+type uiSection struct {
+	target   string
+	kind     uiOutput
+	message  string
+	decorate []func(string) string
+}
+
+// format prefixes the message with the target, then decorates it.
+func (s uiSection) format(message string) string {
+	if s.target != "" {
+		message = prefixLines(s.target, s.kind != uiMessage, message)
+	}
+	for _, d := range s.decorate {
+		message = d(message)
+	}
+	return message
+}
+
+// sectionUi is implemented by Uis that pass output on as sections, and by
+// the BasicUi, which groups the output of each target in interactive
+// terminals.
+type sectionUi interface {
+	section(s uiSection)
+	askSection(s uiSection) (string, error)
+}
+
+// sectionWrite passes output on to a Ui, formatted if it doesn't handle
+// sections.
+func sectionWrite(ui Ui, s uiSection) {
+	if su, ok := ui.(sectionUi); ok {
+		su.section(s)
+		return
+	}
+	uiWrite(ui, s.kind, s.format(s.message))
+}
+
+// sectionAsk asks a question to a Ui, formatted if it doesn't handle
+// sections.
+func sectionAsk(ui Ui, s uiSection) (string, error) {
+	if su, ok := ui.(sectionUi); ok {
+		return su.askSection(s)
+	}
+	return ui.Ask(s.format(s.message))
+}
+
 // ColoredUi is a UI that is colored using terminal colors.
 type ColoredUi struct {
 	Color      UiColor
@@ -58,14 +117,35 @@ type TargetedUI struct {
 
 // The BasicUI is a UI that reads and writes from a standard Go reader
 // and writer. It is safe to be called from multiple goroutines. Machine
-// readable output is simply logged for this UI.
+// readable output is simply logged for this UI, except for the status of
+// running tasks.
 type BasicUi struct {
 	Reader      io.Reader
 	Writer      io.Writer
 	ErrorWriter io.Writer
+
+	// Terminal, if true, means that Writer is an interactive terminal.
+	// The status of running tasks, like progress bars, is then drawn in
+	// place below the output, grouped by build.
+	Terminal bool
+
+	// Collapse, if true, collapses the messages of each step of builds in
+	// interactive terminals: only their last lines are shown while the
+	// step runs, and they are printed only if the step fails.
+	Collapse bool
+
 	l           sync.Mutex
 	interrupted bool
 	scanner     *bufio.Scanner
+	area        *statusArea
+}
+
+// RecordedUi passes its output to Record, such as to keep it in the
+// history of builds, and then to Ui. Machine-readable output isn't
+// recorded.
+type RecordedUi struct {
+	Ui     Ui
+	Record func(message string)
 }
 
 // MachineReadableUi is a UI that only outputs machine-readable output
@@ -75,24 +155,19 @@ type MachineReadableUi struct {
 }
 
 func (u *ColoredUi) Ask(query string) (string, error) {
-	return u.Ui.Ask(u.colorize(query, u.Color, true))
+	return u.askSection(uiSection{kind: uiAsk, message: query})
 }
 
 func (u *ColoredUi) Say(message string) {
-	u.Ui.Say(u.colorize(message, u.Color, true))
+	u.section(uiSection{kind: uiSay, message: message})
 }
 
 func (u *ColoredUi) Message(message string) {
-	u.Ui.Message(u.colorize(message, u.Color, false))
+	u.section(uiSection{kind: uiMessage, message: message})
 }
 
 func (u *ColoredUi) Error(message string) {
-	color := u.ErrorColor
-	if color == 0 {
-		color = UiColorRed
-	}
-
-	u.Ui.Error(u.colorize(message, color, true))
+	u.section(uiSection{kind: uiError, message: message})
 }
 
 func (u *ColoredUi) Machine(t string, args ...string) {
@@ -100,6 +175,38 @@ func (u *ColoredUi) Machine(t string, args ...string) {
 	u.Ui.Machine(t, args...)
 }
 
+func (u *ColoredUi) section(s uiSection) {
+	s.decorate = append(s.decorate, u.decoration(s.kind))
+	sectionWrite(u.Ui, s)
+}
+
+func (u *ColoredUi) askSection(s uiSection) (string, error) {
+	s.decorate = append(s.decorate, u.decoration(s.kind))
+	return sectionAsk(u.Ui, s)
+}
+
+// decoration returns the decoration coloring output of the kind.
+func (u *ColoredUi) decoration(kind uiOutput) func(string) string {
+	switch kind {
+	case uiMessage:
+		return func(message string) string {
+			return u.colorize(message, u.Color, false)
+		}
+	case uiError:
+		color := u.ErrorColor
+		if color == 0 {
+			color = UiColorRed
+		}
+		return func(message string) string {
+			return u.colorize(message, color, true)
+		}
+	default:
+		return func(message string) string {
+			return u.colorize(message, u.Color, true)
+		}
+	}
+}
+
 func (u *ColoredUi) colorize(message string, color UiColor, bold bool) string {
 	if !u.supportsColors() {
 		return message
@@ -133,19 +240,31 @@ func (u *ColoredUi) supportsColors() bool {
 }
 
 func (u *TargetedUI) Ask(query string) (string, error) {
-	return u.Ui.Ask(u.prefixLines(true, query))
+	return sectionAsk(u.Ui, uiSection{target: u.Target, kind: uiAsk, message: query})
 }
 
 func (u *TargetedUI) Say(message string) {
-	u.Ui.Say(u.prefixLines(true, message))
+	sectionWrite(u.Ui, uiSection{target: u.Target, kind: uiSay, message: message})
 }
 
 func (u *TargetedUI) Message(message string) {
-	u.Ui.Message(u.prefixLines(false, message))
+	sectionWrite(u.Ui, uiSection{target: u.Target, kind: uiMessage, message: message})
 }
 
 func (u *TargetedUI) Error(message string) {
-	u.Ui.Error(u.prefixLines(true, message))
+	sectionWrite(u.Ui, uiSection{target: u.Target, kind: uiError, message: message})
+}
+
+// uiWrite writes output of the kind to a Ui.
+func uiWrite(ui Ui, kind uiOutput, message string) {
+	switch kind {
+	case uiMessage:
+		ui.Message(message)
+	case uiError:
+		ui.Error(message)
+	default:
+		ui.Say(message)
+	}
 }
 
 func (u *TargetedUI) Machine(t string, args ...string) {
@@ -153,7 +272,9 @@ func (u *TargetedUI) Machine(t string, args ...string) {
 	u.Ui.Machine(fmt.Sprintf("%s,%s", u.Target, t), args...)
 }
 
-func (u *TargetedUI) prefixLines(arrow bool, message string) string {
+// prefixLines prefixes the lines of a message with the target, with an
+// arrow or lined up with the lines that have one.
+func prefixLines(target string, arrow bool, message string) string {
 	arrowText := "==>"
 	if !arrow {
 		arrowText = strings.Repeat(" ", len(arrowText))
@@ -162,13 +283,49 @@ func (u *TargetedUI) prefixLines(arrow bool, message string) string {
 	var result bytes.Buffer
 
 	for _, line := range strings.Split(message, "\n") {
-		result.WriteString(fmt.Sprintf("%s %s: %s\n", arrowText, u.Target, line))
+		result.WriteString(fmt.Sprintf("%s %s: %s\n", arrowText, target, line))
 	}
 
 	return strings.TrimRightFunc(result.String(), unicode.IsSpace)
 }
 
+func (u *RecordedUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(query)
+}
+
+func (u *RecordedUi) Say(message string) {
+	u.Record(message)
+	u.Ui.Say(message)
+}
+
+func (u *RecordedUi) Message(message string) {
+	u.Record(message)
+	u.Ui.Message(message)
+}
+
+func (u *RecordedUi) Error(message string) {
+	u.Record(message)
+	u.Ui.Error(message)
+}
+
+func (u *RecordedUi) Machine(t string, args ...string) {
+	u.Ui.Machine(t, args...)
+}
+
+func (u *RecordedUi) section(s uiSection) {
+	u.Record(s.format(s.message))
+	sectionWrite(u.Ui, s)
+}
+
+func (u *RecordedUi) askSection(s uiSection) (string, error) {
+	return sectionAsk(u.Ui, s)
+}
+
 func (rw *BasicUi) Ask(query string) (string, error) {
+	return rw.askSection(uiSection{kind: uiAsk, message: query})
+}
+
+func (rw *BasicUi) askSection(s uiSection) (string, error) {
 	rw.l.Lock()
 	defer rw.l.Unlock()
 
@@ -183,7 +340,9 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	signal.Notify(sigCh, os.Interrupt)
 	defer signal.Stop(sigCh)
 
+	query := s.format(Localize(s.message))
 	log.Printf("ui: ask: %s", query)
+	rw.clearStatus()
 	if query != "" {
 		if _, err := fmt.Fprint(rw.Writer, query+" "); err != nil {
 			return "", err
@@ -219,45 +378,54 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 }
 
 func (rw *BasicUi) Say(message string) {
-	rw.l.Lock()
-	defer rw.l.Unlock()
-
-	log.Printf("ui: %s", message)
-	_, err := fmt.Fprint(rw.Writer, message+"\n")
-	if err != nil {
-		log.Printf("[ERR] Failed to write to UI: %s", err)
-	}
+	rw.section(uiSection{kind: uiSay, message: message})
 }
 
 func (rw *BasicUi) Message(message string) {
-	rw.l.Lock()
-	defer rw.l.Unlock()
-
-	log.Printf("ui: %s", message)
-	_, err := fmt.Fprint(rw.Writer, message+"\n")
-	if err != nil {
-		log.Printf("[ERR] Failed to write to UI: %s", err)
-	}
+	rw.section(uiSection{kind: uiMessage, message: message})
 }
 
 func (rw *BasicUi) Error(message string) {
-	rw.l.Lock()
-	defer rw.l.Unlock()
+	rw.section(uiSection{kind: uiError, message: message})
+}
 
-	writer := rw.ErrorWriter
-	if writer == nil {
-		writer = rw.Writer
+func (rw *BasicUi) Machine(t string, args ...string) {
+	target, category := splitTarget(t)
+	if category == StatusMachineType {
+		rw.l.Lock()
+		defer rw.l.Unlock()
+
+		rw.status(target, args)
+		return
 	}
 
-	log.Printf("ui error: %s", message)
-	_, err := fmt.Fprint(writer, message+"\n")
-	if err != nil {
-		log.Printf("[ERR] Failed to write to UI: %s", err)
+	log.Printf("machine readable: %s %#v", t, args)
+}
+
+// output writes output of the kind. The lock must be held.
+func (rw *BasicUi) output(kind uiOutput, message string) {
+	writer := rw.Writer
+	if kind == uiError {
+		if rw.ErrorWriter != nil {
+			writer = rw.ErrorWriter
+		}
+		log.Printf("ui error: %s", message)
+	} else {
+		log.Printf("ui: %s", message)
 	}
+
+	rw.write(writer, message)
 }
 
-func (rw *BasicUi) Machine(t string, args ...string) {
-	log.Printf("machine readable: %s %#v", t, args)
+// write writes a line of output above the status area. The lock must be
+// held.
+func (rw *BasicUi) write(w io.Writer, message string) {
+	rw.clearStatus()
+	_, err := fmt.Fprint(w, message+"\n")
+	if err != nil {
+		log.Printf("[ERR] Failed to write to UI: %s", err)
+	}
+	rw.drawStatus()
 }
 
 func (u *MachineReadableUi) Ask(query string) (string, error) {
@@ -279,6 +447,18 @@ func (u *MachineReadableUi) Error(message string) {
 func (u *MachineReadableUi) Machine(category string, args ...string) {
 	now := time.Now().UTC()
 
+	// The status of running tasks is only output when it is logged, as
+	// messages
+	if target, t := splitTarget(category); t == StatusMachineType {
+		if len(args) < 3 || args[0] == statusLive || args[2] == "" {
+			return
+		}
+		category, args = "ui", []string{"message", args[2]}
+		if target != "" {
+			category = target + ",ui"
+		}
+	}
+
 	// Determine if we have a target, and set it
 	target := ""
 	commaIdx := strings.Index(category, ",")
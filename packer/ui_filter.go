@@ -0,0 +1,168 @@
+package packer
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RepeatInterval is how often a run of identical messages that goes on
+// is noted by a FilteredUi.
+var RepeatInterval = 30 * time.Second
+
+// FilteredUi keeps chatty output, like the progress lines installers
+// print many times a second, from flooding logs. Runs of identical
+// messages are collapsed into one line noting how many times it was
+// repeated, and messages over Limit per second are dropped, noting how
+// many were. Dropped messages are still logged. Say and Error output is
+// never filtered.
+type FilteredUi struct {
+	Ui
+
+	// Limit is the number of messages per second over which messages are
+	// dropped. Bursts of up to thirty seconds worth of messages are allowed.
+	// Zero means unlimited.
+	Limit int
+
+	l        sync.Mutex
+	target   string
+	last     string
+	repeated int
+	since    time.Time
+	tokens   float64
+	refilled time.Time
+	dropped  int
+}
+
+func (u *FilteredUi) Ask(query string) (string, error) {
+	u.flush()
+	return u.Ui.Ask(query)
+}
+
+func (u *FilteredUi) Say(message string) {
+	u.flush()
+	u.Ui.Say(message)
+}
+
+func (u *FilteredUi) Message(message string) {
+	u.message("", message)
+}
+
+func (u *FilteredUi) Error(message string) {
+	u.flush()
+	u.Ui.Error(message)
+}
+
+func (u *FilteredUi) section(s uiSection) {
+	if s.kind == uiMessage {
+		u.message(s.target, s.message)
+		return
+	}
+
+	u.flush()
+	sectionWrite(u.Ui, s)
+}
+
+func (u *FilteredUi) askSection(s uiSection) (string, error) {
+	u.flush()
+	return sectionAsk(u.Ui, s)
+}
+
+// message filters a message of the target, which the TargetedUI passes
+// on with its output.
+func (u *FilteredUi) message(target, message string) {
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	if target != u.target {
+		u.flushLocked()
+		u.target = target
+	}
+	u.filter(message)
+}
+
+// write writes a message of the current target.
+func (u *FilteredUi) write(message string) {
+	if u.target == "" {
+		u.Ui.Message(message)
+		return
+	}
+	sectionWrite(u.Ui, uiSection{target: u.target, kind: uiMessage, message: message})
+}
+
+// filter writes the message, unless it repeats the last message or is
+// over the limit. The lock must be held.
+func (u *FilteredUi) filter(message string) {
+	now := time.Now()
+	if message == u.last {
+		log.Printf("ui (repeated): %s", message)
+		if u.repeated == 0 {
+			u.since = now
+		}
+		u.repeated++
+
+		// Long runs are noted as they go, not only once they end
+		if now.Sub(u.since) >= RepeatInterval {
+			u.flushRepeated()
+		}
+		return
+	}
+	u.flushRepeated()
+	u.last = message
+
+	if u.Limit > 0 {
+		burst := float64(u.Limit * 30)
+		if u.refilled.IsZero() {
+			u.tokens = burst
+		} else {
+			u.tokens += now.Sub(u.refilled).Seconds() * float64(u.Limit)
+			if u.tokens > burst {
+				u.tokens = burst
+			}
+		}
+		u.refilled = now
+
+		if u.tokens < 1 {
+			log.Printf("ui (dropped): %s", message)
+			u.dropped++
+			return
+		}
+		u.tokens--
+	}
+
+	u.flushDropped()
+	u.write(message)
+}
+
+// flush writes the notes of repeated and dropped messages, so that they
+// come before other output.
+func (u *FilteredUi) flush() {
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	u.flushLocked()
+}
+
+func (u *FilteredUi) flushLocked() {
+	u.flushRepeated()
+	u.flushDropped()
+	u.last = ""
+}
+
+func (u *FilteredUi) flushRepeated() {
+	if u.repeated == 0 {
+		return
+	}
+	u.write(fmt.Sprintf("%s (repeated %d times)", u.last, u.repeated))
+	u.repeated = 0
+}
+
+func (u *FilteredUi) flushDropped() {
+	if u.dropped == 0 {
+		return
+	}
+	u.write(fmt.Sprintf("(dropped %d lines of output over %d lines per second, see the log)",
+		u.dropped, u.Limit))
+	u.dropped = 0
+}
@@ -0,0 +1,76 @@
+package packer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilteredUi_ImplUi(t *testing.T) {
+	var raw interface{}
+	raw = &FilteredUi{}
+	if _, ok := raw.(Ui); !ok {
+		t.Fatalf("FilteredUi must implement Ui")
+	}
+}
+
+func TestFilteredUi_repeated(t *testing.T) {
+	bufferUi := testUi()
+	ui := &TargetedUI{Target: "foo", Ui: &FilteredUi{Ui: bufferUi}}
+
+	ui.Message("Waiting...")
+	for i := 0; i < 600; i++ {
+		ui.Message("Waiting...")
+	}
+	ui.Message("Done")
+	ui.Say("next")
+
+	expected := "    foo: Waiting...\n" +
+		"    foo: Waiting... (repeated 600 times)\n" +
+		"    foo: Done\n" +
+		"==> foo: next\n"
+	if out := readWriter(bufferUi); out != expected {
+		t.Fatalf("bad output: %q", out)
+	}
+}
+
+func TestFilteredUi_repeatedInterval(t *testing.T) {
+	defer func(i time.Duration) { RepeatInterval = i }(RepeatInterval)
+	RepeatInterval = 0
+
+	bufferUi := testUi()
+	ui := &FilteredUi{Ui: bufferUi}
+
+	ui.Message("Waiting...")
+	ui.Message("Waiting...")
+	ui.Message("Waiting...")
+
+	expected := "Waiting...\nWaiting... (repeated 1 times)\nWaiting... (repeated 1 times)\n"
+	if out := readWriter(bufferUi); out != expected {
+		t.Fatalf("bad output: %q", out)
+	}
+}
+
+func TestFilteredUi_limit(t *testing.T) {
+	bufferUi := testUi()
+	ui := &TargetedUI{Target: "foo", Ui: &FilteredUi{Ui: bufferUi, Limit: 1}}
+
+	// The burst allows 30 messages at once
+	for i := 0; i < 100; i++ {
+		ui.Message(strings.Repeat("x", i+1))
+	}
+	ui.Error("failed")
+
+	out := readWriter(bufferUi)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 31 {
+		t.Fatalf("bad output: %d lines\n%s", len(lines), out)
+	}
+	expected := "    foo: (dropped 70 lines of output over 1 lines per second, see the log)"
+	if lines[30] != expected {
+		t.Fatalf("bad note: %q", lines[30])
+	}
+	if out := readErrorWriter(bufferUi); out != "==> foo: failed\n" {
+		t.Fatalf("bad error: %q", out)
+	}
+}
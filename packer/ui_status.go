@@ -0,0 +1,361 @@
+package packer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// StatusMachineType is the machine-readable type of the status of running
+// tasks, like the progress of transfers and the spinners of long waits.
+// The status travels as machine-readable output, so that plugins report
+// it through any Ui. Interactive terminals show the status of each build
+// in place below the output, other Uis only print the status lines marked
+// to be logged, so that CI logs keep plain lines.
+const StatusMachineType = "ui-status"
+
+// The modes of status lines.
+const (
+	// statusLive lines are only shown in interactive terminals.
+	statusLive = "live"
+
+	// statusLog lines are also printed as messages by other Uis.
+	statusLog = "log"
+
+	// statusDone removes the status of a task. Its line, if any, is
+	// printed as a final message by every Ui.
+	statusDone = "done"
+)
+
+// StatusInterval is how often the status of running tasks is updated in
+// interactive terminals.
+var StatusInterval = 250 * time.Millisecond
+
+// collapsedLines is how many of the last lines of collapsed output are
+// shown below a build in interactive terminals.
+const collapsedLines = 3
+
+// collapsedHistory is how many lines of collapsed output are kept, to be
+// printed if the step fails.
+const collapsedHistory = 1000
+
+var statusIDs uint64
+
+// NewStatusID returns an ID for the status of a task, unique across the
+// processes of plugins reporting to the same build.
+func NewStatusID() string {
+	return fmt.Sprintf("%d-%d", os.Getpid(), atomic.AddUint64(&statusIDs, 1))
+}
+
+// SetStatus shows line as the status of the task with the ID, in
+// interactive terminals only.
+func SetStatus(ui Ui, id, line string) {
+	ui.Machine(StatusMachineType, statusLive, id, line)
+}
+
+// LogStatus is SetStatus for lines that other Uis print as messages, like
+// the periodic progress of transfers.
+func LogStatus(ui Ui, id, line string) {
+	ui.Machine(StatusMachineType, statusLog, id, line)
+}
+
+// ClearStatus removes the status of the task with the ID. If line isn't
+// empty, every Ui prints it as the final message of the task.
+func ClearStatus(ui Ui, id, line string) {
+	ui.Machine(StatusMachineType, statusDone, id, line)
+}
+
+// spinnerFrames are the frames of spinners.
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// Spinner shows that a long wait, like waiting for SSH, is still going on
+// in interactive terminals, with the time it has been waiting. It shows
+// nothing on other Uis.
+type Spinner struct {
+	ui      Ui
+	id      string
+	message string
+
+	once    sync.Once
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// StartSpinner starts a spinner with the message, like "Waiting for SSH
+// to become available...". It must be stopped once the wait is over.
+func StartSpinner(ui Ui, message string) *Spinner {
+	s := &Spinner{
+		ui:      ui,
+		id:      NewStatusID(),
+		message: message,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *Spinner) run() {
+	defer close(s.stopped)
+
+	start := time.Now()
+	ticker := time.NewTicker(StatusInterval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		elapsed := time.Since(start) / time.Second * time.Second
+		SetStatus(s.ui, s.id, fmt.Sprintf("%s %s (%s)",
+			spinnerFrames[frame%len(spinnerFrames)], s.message, elapsed))
+
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			ClearStatus(s.ui, s.id, "")
+			return
+		}
+	}
+}
+
+// Stop stops the spinner and removes it. It can be called more than once.
+func (s *Spinner) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.stopped
+}
+
+// statusLine is the status of a running task.
+type statusLine struct {
+	id   string
+	line string
+}
+
+// statusArea is drawn by a BasicUi below its output in interactive
+// terminals. It has the status of the running tasks of each target, and
+// the collapsed output of their current steps.
+type statusArea struct {
+	targets []string
+	status  map[string][]statusLine
+	output  map[string][]string
+	drawn   int
+}
+
+func newStatusArea() *statusArea {
+	return &statusArea{
+		status: make(map[string][]statusLine),
+		output: make(map[string][]string),
+	}
+}
+
+// addTarget makes sure that the target has a section, in the order
+// targets were seen.
+func (a *statusArea) addTarget(target string) {
+	for _, t := range a.targets {
+		if t == target {
+			return
+		}
+	}
+	a.targets = append(a.targets, target)
+}
+
+func (a *statusArea) set(target, id, line string) {
+	a.addTarget(target)
+	for i, s := range a.status[target] {
+		if s.id == id {
+			a.status[target][i].line = line
+			return
+		}
+	}
+	a.status[target] = append(a.status[target], statusLine{id: id, line: line})
+}
+
+func (a *statusArea) remove(target, id string) {
+	lines := a.status[target]
+	for i, s := range lines {
+		if s.id == id {
+			a.status[target] = append(lines[:i:i], lines[i+1:]...)
+			return
+		}
+	}
+}
+
+// lines returns the lines to draw, grouped by target: the last lines of
+// the collapsed output of a target, then the status of its tasks.
+func (a *statusArea) lines() []string {
+	var lines []string
+	for _, target := range a.targets {
+		output := a.output[target]
+		if len(output) > collapsedLines {
+			output = output[len(output)-collapsedLines:]
+		}
+		lines = append(lines, output...)
+
+		for _, s := range a.status[target] {
+			lines = append(lines, targetPrefix(target)+s.line)
+		}
+	}
+	return lines
+}
+
+// targetPrefix returns the prefix of messages of a target, lined up with
+// the messages of the TargetedUI.
+func targetPrefix(target string) string {
+	if target == "" {
+		return ""
+	}
+	return fmt.Sprintf("    %s: ", target)
+}
+
+// splitTarget splits the target a TargetedUI adds to the category of
+// machine-readable output.
+func splitTarget(category string) (string, string) {
+	if i := strings.Index(category, ","); i > -1 {
+		return category[:i], category[i+1:]
+	}
+	return "", category
+}
+
+// status handles the status of a task reported to the Ui.
+func (rw *BasicUi) status(target string, args []string) {
+	if len(args) < 3 {
+		return
+	}
+	mode, id, line := args[0], args[1], args[2]
+
+	if !rw.Terminal {
+		if mode != statusLive && line != "" {
+			log.Printf("ui: %s", line)
+			rw.write(rw.Writer, targetPrefix(target)+line)
+		}
+		return
+	}
+
+	if rw.area == nil {
+		rw.area = newStatusArea()
+	}
+	rw.clearStatus()
+	if mode == statusDone {
+		rw.area.remove(target, id)
+		if line != "" {
+			log.Printf("ui: %s", line)
+			fmt.Fprint(rw.Writer, targetPrefix(target)+line+"\n")
+		}
+	} else {
+		rw.area.set(target, id, line)
+	}
+	rw.drawStatus()
+}
+
+// section handles output, translated with the catalog before it is
+// formatted. The output of targets is collapsed by step if Collapse is
+// set: a step starts with each Say, which discards the collapsed output of
+// the previous step, and the output of a failing step is printed before
+// its error.
+func (rw *BasicUi) section(s uiSection) {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+
+	target, kind := s.target, s.kind
+	message := s.format(Localize(s.message))
+	if target == "" {
+		// The output of the command itself ends the collapsed steps of
+		// builds
+		if kind == uiSay && rw.area != nil {
+			rw.area.output = make(map[string][]string)
+		}
+		rw.output(kind, message)
+		return
+	}
+
+	if !rw.Terminal || !rw.Collapse {
+		rw.output(kind, message)
+		return
+	}
+
+	if rw.area == nil {
+		rw.area = newStatusArea()
+	}
+	a := rw.area
+	a.addTarget(target)
+
+	switch kind {
+	case uiMessage:
+		log.Printf("ui: %s", message)
+		rw.clearStatus()
+		output := append(a.output[target], strings.Split(message, "\n")...)
+		if len(output) > collapsedHistory {
+			output = output[len(output)-collapsedHistory:]
+		}
+		a.output[target] = output
+		rw.drawStatus()
+	case uiError:
+		rw.clearStatus()
+		for _, line := range a.output[target] {
+			fmt.Fprint(rw.Writer, line+"\n")
+		}
+		a.output[target] = nil
+		rw.output(kind, message)
+	default:
+		a.output[target] = nil
+		rw.output(kind, message)
+	}
+}
+
+// clearStatus erases the status area, so that output can be written in
+// its place.
+func (rw *BasicUi) clearStatus() {
+	if rw.area == nil || rw.area.drawn == 0 {
+		return
+	}
+	fmt.Fprint(rw.Writer, strings.Repeat("\033[1A\033[2K", rw.area.drawn))
+	rw.area.drawn = 0
+}
+
+// drawStatus draws the status area below the output. Lines are cut to
+// the width of the terminal, so that they can be erased line by line.
+func (rw *BasicUi) drawStatus() {
+	if rw.area == nil {
+		return
+	}
+	width := terminalWidth(rw.Writer)
+	if width <= 0 {
+		width = 80
+	}
+
+	lines := rw.area.lines()
+	for _, line := range lines {
+		fmt.Fprint(rw.Writer, truncateLine(line, width-1)+"\n")
+	}
+	rw.area.drawn = len(lines)
+}
+
+// truncateLine cuts a line to width visible characters, not counting the
+// escape sequences of colors.
+func truncateLine(line string, width int) string {
+	visible := 0
+	escaped := false
+	for i := 0; i < len(line); {
+		if strings.HasPrefix(line[i:], "\033[") {
+			end := strings.IndexByte(line[i:], 'm')
+			if end < 0 {
+				break
+			}
+			escaped = true
+			i += end + 1
+			continue
+		}
+		if visible == width {
+			if escaped {
+				return line[:i] + "\033[0m"
+			}
+			return line[:i]
+		}
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+		visible++
+	}
+	return line
+}
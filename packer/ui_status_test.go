@@ -0,0 +1,135 @@
+package packer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBasicUi_status(t *testing.T) {
+	bufferUi := testUi()
+	ui := &TargetedUI{Target: "foo", Ui: bufferUi}
+
+	SetStatus(ui, "1", "live")
+	LogStatus(ui, "1", "logged")
+	ClearStatus(ui, "1", "done")
+	ClearStatus(ui, "2", "")
+
+	expected := "    foo: logged\n    foo: done\n"
+	if out := readWriter(bufferUi); out != expected {
+		t.Fatalf("bad output: %q", out)
+	}
+}
+
+func TestBasicUi_statusTerminal(t *testing.T) {
+	bufferUi := testUi()
+	bufferUi.Terminal = true
+	ui := &TargetedUI{Target: "foo", Ui: bufferUi}
+
+	SetStatus(ui, "1", "working")
+	if out := readWriter(bufferUi); out != "    foo: working\n" {
+		t.Fatalf("bad output: %q", out)
+	}
+
+	// Output is written in place of the status, which is drawn again
+	ui.Say("step")
+	expected := "\033[1A\033[2K==> foo: step\n    foo: working\n"
+	if out := readWriter(bufferUi); out != expected {
+		t.Fatalf("bad output: %q", out)
+	}
+
+	SetStatus(&TargetedUI{Target: "bar", Ui: bufferUi}, "1", "waiting")
+	LogStatus(ui, "2", "50%")
+	expected = "\033[1A\033[2K    foo: working\n    foo: 50%\n    bar: waiting\n"
+	if out := readWriter(bufferUi); !strings.HasSuffix(out, expected) {
+		t.Fatalf("bad output: %q", out)
+	}
+
+	ClearStatus(ui, "2", "100%")
+	expected = "\033[1A\033[2K\033[1A\033[2K\033[1A\033[2K    foo: 100%\n    foo: working\n    bar: waiting\n"
+	if out := readWriter(bufferUi); out != expected {
+		t.Fatalf("bad output: %q", out)
+	}
+}
+
+func TestBasicUi_collapse(t *testing.T) {
+	bufferUi := testUi()
+	bufferUi.Terminal = true
+	bufferUi.Collapse = true
+	ui := &TargetedUI{Target: "foo", Ui: bufferUi}
+
+	ui.Say("first step")
+	for _, line := range []string{"a", "b", "c", "d"} {
+		ui.Message(line)
+	}
+	out := readWriter(bufferUi)
+	if !strings.HasSuffix(out, "    foo: b\n    foo: c\n    foo: d\n") {
+		t.Fatalf("the last lines should be shown: %q", out)
+	}
+
+	// The next step collapses the output of the first
+	ui.Say("second step")
+	ui.Message("e")
+	ui.Error("failed")
+	out = readWriter(bufferUi)
+	if strings.Contains(out, "foo: a") {
+		t.Fatalf("the first step should be collapsed: %q", out)
+	}
+	if !strings.HasSuffix(out, "    foo: e\n") {
+		t.Fatalf("the output of the failed step should be printed: %q", out)
+	}
+	if out := readErrorWriter(bufferUi); out != "==> foo: failed\n" {
+		t.Fatalf("bad error: %q", out)
+	}
+	if len(bufferUi.area.lines()) != 0 {
+		t.Fatalf("bad status: %#v", bufferUi.area.lines())
+	}
+}
+
+func TestMachineReadableUi_status(t *testing.T) {
+	var data bytes.Buffer
+	ui := &TargetedUI{Target: "foo", Ui: &MachineReadableUi{Writer: &data}}
+
+	SetStatus(ui, "1", "live")
+	LogStatus(ui, "1", "logged")
+	ClearStatus(ui, "1", "")
+
+	lines := strings.Split(strings.TrimSpace(data.String()), "\n")
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], ",foo,ui,message,logged") {
+		t.Fatalf("bad output: %q", data.String())
+	}
+}
+
+func TestSpinner(t *testing.T) {
+	bufferUi := testUi()
+	bufferUi.Terminal = true
+
+	s := StartSpinner(bufferUi, "Waiting for SSH...")
+	s.Stop()
+	s.Stop()
+
+	if out := readWriter(bufferUi); !strings.Contains(out, "| Waiting for SSH... (0s)\n") {
+		t.Fatalf("bad output: %q", out)
+	}
+	if len(bufferUi.area.lines()) != 0 {
+		t.Fatalf("the spinner should be removed: %#v", bufferUi.area.lines())
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	cases := []struct {
+		Line     string
+		Width    int
+		Expected string
+	}{
+		{"short", 10, "short"},
+		{"a long line", 6, "a long"},
+		{"\033[0;33mcolored\033[0m", 5, "\033[0;33mcolor\033[0m"},
+		{"héllo", 2, "hé"},
+	}
+	for _, tc := range cases {
+		if actual := truncateLine(tc.Line, tc.Width); actual != tc.Expected {
+			t.Fatalf("%q: got %q, expected %q", tc.Line, actual, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// +build !darwin,!freebsd,!linux,!solaris
+
+package packer
+
+import "io"
+
+// terminalWidth returns the number of columns of the terminal w writes
+// to, or zero if it isn't known.
+func terminalWidth(w io.Writer) int {
+	return 0
+}
@@ -3,6 +3,7 @@ package packer
 import (
 	"bytes"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -134,6 +135,31 @@ func TestTargetedUI(t *testing.T) {
 	}
 }
 
+func TestRecordedUi(t *testing.T) {
+	bufferUi := testUi()
+	var recorded []string
+	ui := &TargetedUI{
+		Target: "foo",
+		Ui: &RecordedUi{
+			Ui: bufferUi,
+			Record: func(message string) {
+				recorded = append(recorded, message)
+			},
+		},
+	}
+
+	ui.Say("foo")
+	ui.Error("bar")
+	ui.Machine("artifact", "0")
+	if actual := readWriter(bufferUi); actual != "==> foo: foo\n" {
+		t.Fatalf("bad: %#v", actual)
+	}
+	expected := []string{"==> foo: foo", "==> foo: bar"}
+	if !reflect.DeepEqual(recorded, expected) {
+		t.Fatalf("bad: %#v", recorded)
+	}
+}
+
 func TestColoredUi_ImplUi(t *testing.T) {
 	var raw interface{}
 	raw = &ColoredUi{}
@@ -0,0 +1,27 @@
+package packer
+
+// ValidateContext is given to the Validate method of components.
+type ValidateContext struct {
+	// CheckRemote enables checks that call remote services, such as
+	// resolving cloud credentials or looking up a source image.
+	CheckRemote bool
+}
+
+// A Validator is a builder, provisioner or post-processor that can check
+// its configuration more thoroughly than Prepare does, for example that
+// its scripts parse or that its source image exists. Validate is only
+// called by `packer validate`, after the component has been prepared, and
+// must not have any side effects.
+type Validator interface {
+	Validate(*ValidateContext) error
+}
+
+// ValidateComponent calls Validate on the component if it implements
+// Validator. Other components have nothing more to check.
+func ValidateComponent(raw interface{}, ctx *ValidateContext) error {
+	if v, ok := raw.(Validator); ok {
+		return v.Validate(ctx)
+	}
+
+	return nil
+}
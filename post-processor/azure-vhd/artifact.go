@@ -0,0 +1,49 @@
+package azurevhd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+type Artifact struct {
+	Paths []string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Id() string {
+	return strings.Join(a.Paths, ",")
+}
+
+func (a *Artifact) Files() []string {
+	return a.Paths
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("VHDs for Azure: %s", strings.Join(a.Paths, ", "))
+}
+
+func (*Artifact) State(name string) interface{} {
+	return nil
+}
+
+// Metadata returns the format of the disk images.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	m := make(packer.ArtifactMetadata)
+	m.Set("disk_format", "vhd")
+	return m
+}
+
+func (a *Artifact) Destroy() error {
+	for _, path := range a.Paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
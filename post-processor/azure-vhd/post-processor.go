@@ -0,0 +1,78 @@
+// azurevhd implements the packer.PostProcessor interface and converts the
+// disk images of an artifact to the fixed size VHDs Azure requires.
+package azurevhd
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/post-processor/disk-convert"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+const BuilderId = "packer.post-processor.azure-vhd"
+
+// The size of the VHDs uploaded to Azure must be a whole number of
+// megabytes.
+const alignSize = 1024 * 1024
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	OutputPath        string `mapstructure:"output"`
+	QemuImgPath       string `mapstructure:"qemu_img_path"`
+	KeepInputArtifact bool   `mapstructure:"keep_input_artifact"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config  Config
+	convert diskconvert.PostProcessor
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"output"},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	// The conversion is the one of disk-convert, with the options Azure
+	// requires
+	return p.convert.Configure(map[string]interface{}{
+		"format":              "vhd",
+		"subformat":           "fixed",
+		"align_size":          alignSize,
+		"output":              p.config.OutputPath,
+		"qemu_img_path":       p.config.QemuImgPath,
+		"keep_input_artifact": p.config.KeepInputArtifact,
+		"packer_build_name":   p.config.PackerBuildName,
+		"packer_builder_type": p.config.PackerBuilderType,
+	})
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	converted, keep, err := p.convert.PostProcess(ui, artifact)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := &Artifact{Paths: converted.Files()}
+	for _, path := range result.Paths {
+		ui.Message(fmt.Sprintf("Validating %s for Azure...", path))
+		if err := validateVHD(path); err != nil {
+			result.Destroy()
+			return nil, false, fmt.Errorf("%s is not a valid VHD for Azure: %s", path, err)
+		}
+	}
+
+	return result, keep, nil
+}
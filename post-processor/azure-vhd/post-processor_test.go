@@ -0,0 +1,85 @@
+package azurevhd
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{"keep_input_artifact": true}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The format is always the one of Azure
+	p = PostProcessor{}
+	if err := p.Configure(map[string]interface{}{"format": "vmdk"}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// testVHD writes a VHD with a disk of the size and type, and extra bytes
+// between the data and the footer.
+func testVHD(t *testing.T, dir string, size int64, diskType uint32, extra int64) string {
+	footer := make([]byte, footerSize)
+	copy(footer, footerCookie)
+	binary.BigEndian.PutUint64(footer[footerCurrentSize:], uint64(size))
+	binary.BigEndian.PutUint32(footer[footerDiskType:], diskType)
+
+	path := filepath.Join(dir, "disk.vhd")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(footer, size+extra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path
+}
+
+func TestValidateVHD(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	cases := []struct {
+		Size     int64
+		DiskType uint32
+		Extra    int64
+		Err      bool
+	}{
+		{2 * alignSize, diskTypeFixed, 0, false},
+		{2*alignSize + 512, diskTypeFixed, 0, true},
+		{2 * alignSize, 3, 0, true},
+		{2 * alignSize, diskTypeFixed, 512, true},
+	}
+
+	for _, tc := range cases {
+		path := testVHD(t, td, tc.Size, tc.DiskType, tc.Extra)
+		err := validateVHD(path)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d %d %d: bad: %s", tc.Size, tc.DiskType, tc.Extra, err)
+		}
+		os.Remove(path)
+	}
+
+	path := filepath.Join(td, "disk.raw")
+	if err := ioutil.WriteFile(path, make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := validateVHD(path); err == nil {
+		t.Fatal("should error without a footer")
+	}
+}
@@ -0,0 +1,61 @@
+package azurevhd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A VHD ends with a footer of 512 bytes that describes the disk.
+const (
+	footerSize = 512
+
+	footerCookie      = "conectix"
+	footerCurrentSize = 48
+	footerDiskType    = 60
+
+	diskTypeFixed = 2
+)
+
+// validateVHD checks that the file is a VHD that Azure accepts: a fixed
+// size disk of a whole number of megabytes, whose data is followed by the
+// footer.
+func validateVHD(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < footerSize {
+		return fmt.Errorf("the file is too small to be a VHD")
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := f.ReadAt(footer, fi.Size()-footerSize); err != nil && err != io.EOF {
+		return err
+	}
+
+	if string(footer[:len(footerCookie)]) != footerCookie {
+		return fmt.Errorf("the file has no VHD footer")
+	}
+	if t := binary.BigEndian.Uint32(footer[footerDiskType:]); t != diskTypeFixed {
+		return fmt.Errorf("the disk is not of fixed size (type %d)", t)
+	}
+
+	size := int64(binary.BigEndian.Uint64(footer[footerCurrentSize:]))
+	if size%alignSize != 0 {
+		return fmt.Errorf("the size of the disk, %d bytes, is not a whole number of megabytes", size)
+	}
+	if fi.Size() != size+footerSize {
+		return fmt.Errorf("the file is %d bytes, not the %d bytes of the disk and its footer",
+			fi.Size(), size+footerSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package diskconvert
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+const BuilderId = "packer.post-processor.disk-convert"
+
+type Artifact struct {
+	Format string
+	Paths  []string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Id() string {
+	return strings.Join(a.Paths, ",")
+}
+
+func (a *Artifact) Files() []string {
+	return a.Paths
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("%s disk images: %s", a.Format, strings.Join(a.Paths, ", "))
+}
+
+func (*Artifact) State(name string) interface{} {
+	return nil
+}
+
+// Metadata returns the format of the disk images.
+func (a *Artifact) Metadata() packer.ArtifactMetadata {
+	m := make(packer.ArtifactMetadata)
+	m.Set("disk_format", a.Format)
+	return m
+}
+
+func (a *Artifact) Destroy() error {
+	for _, path := range a.Paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+// diskconvert implements the packer.PostProcessor interface and converts
+// the disk images of an artifact to another format with qemu-img.
+package diskconvert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/builder/qemu"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// diskFormat is a format qemu-img can write.
+type diskFormat struct {
+	// Name is the name of the format for qemu-img.
+	Name string
+
+	// Ext is the extension of the disk images.
+	Ext string
+
+	// BlockSizeOption is the option of the size of the blocks of the
+	// format, if it has one.
+	BlockSizeOption string
+}
+
+var formats = map[string]diskFormat{
+	"qcow2": {Name: "qcow2", Ext: "qcow2", BlockSizeOption: "cluster_size"},
+	"raw":   {Name: "raw", Ext: "raw"},
+	"vhd":   {Name: "vpc", Ext: "vhd"},
+	"vhdx":  {Name: "vhdx", Ext: "vhdx", BlockSizeOption: "block_size"},
+	"vmdk":  {Name: "vmdk", Ext: "vmdk"},
+}
+
+// inputFormats are the formats of the disk images of other builders, by
+// extension.
+var inputFormats = map[string]string{
+	".img":   "raw",
+	".qcow2": "qcow2",
+	".raw":   "raw",
+	".vhd":   "vpc",
+	".vhdx":  "vhdx",
+	".vmdk":  "vmdk",
+}
+
+// extentRe matches the files of the extents of split VMDK disks, which are
+// converted with their descriptor.
+var extentRe = regexp.MustCompile(`-[sf]\d{3}\.vmdk$`)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	Format            string `mapstructure:"format"`
+	OutputPath        string `mapstructure:"output"`
+	QemuImgPath       string `mapstructure:"qemu_img_path"`
+	Subformat         string `mapstructure:"subformat"`
+	Compress          bool   `mapstructure:"compress"`
+	Sparse            *bool  `mapstructure:"sparse"`
+	BlockSize         int64  `mapstructure:"block_size"`
+	AlignSize         int64  `mapstructure:"align_size"`
+	KeepInputArtifact bool   `mapstructure:"keep_input_artifact"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+
+	// run runs qemu-img with the arguments and returns its output, it is
+	// replaced in tests.
+	run func(args ...string) ([]byte, error)
+}
+
+type outputPathTemplate struct {
+	BuildName   string
+	BuilderType string
+	Dir         string
+	Name        string
+	Extension   string
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"output"},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.OutputPath == "" {
+		p.config.OutputPath = "{{.Dir}}/{{.Name}}.{{.Extension}}"
+	}
+	if p.config.QemuImgPath == "" {
+		p.config.QemuImgPath = "qemu-img"
+	}
+
+	var errs *packer.MultiError
+	format, ok := formats[p.config.Format]
+	if !ok {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"format must be one of qcow2, raw, vhd, vhdx or vmdk"))
+	}
+	if p.config.Compress && p.config.Format != "qcow2" && p.config.Format != "vmdk" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"compress is only supported for the qcow2 and vmdk formats"))
+	}
+	if p.config.Compress && p.config.Format == "vmdk" &&
+		p.config.Subformat != "" && p.config.Subformat != "streamOptimized" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Compressed vmdk disks are streamOptimized, not %s", p.config.Subformat))
+	}
+	if p.config.Subformat != "" && (p.config.Format == "qcow2" || p.config.Format == "raw") {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"subformat is not supported for the %s format", p.config.Format))
+	}
+	if p.config.BlockSize < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("block_size can't be negative"))
+	} else if p.config.BlockSize > 0 && ok && format.BlockSizeOption == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"block_size is only supported for the qcow2 and vhdx formats"))
+	}
+	if p.config.AlignSize < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("align_size can't be negative"))
+	}
+	if err = interpolate.Validate(p.config.OutputPath, &p.config.ctx); err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error parsing output template: %s", err))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	disks := diskFiles(artifact)
+	if len(disks) == 0 {
+		return nil, false, fmt.Errorf(
+			"No disk image found in the artifact of %s to convert.", artifact.BuilderId())
+	}
+
+	format := formats[p.config.Format]
+	result := &Artifact{Format: p.config.Format}
+	for _, disk := range disks {
+		name := filepath.Base(disk)
+		p.config.ctx.Data = &outputPathTemplate{
+			BuildName:   p.config.PackerBuildName,
+			BuilderType: p.config.PackerBuilderType,
+			Dir:         filepath.Dir(disk),
+			Name:        strings.TrimSuffix(name, filepath.Ext(name)),
+			Extension:   format.Ext,
+		}
+		output, err := interpolate.Render(p.config.OutputPath, &p.config.ctx)
+		if err != nil {
+			return nil, false, fmt.Errorf("Error interpolating output: %s", err)
+		}
+		if filepath.Clean(output) == filepath.Clean(disk) {
+			return nil, false, fmt.Errorf(
+				"The output of the conversion of %s is the disk image itself", disk)
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return nil, false, fmt.Errorf("Error creating directory for %s: %s", output, err)
+		}
+
+		ui.Say(fmt.Sprintf("Converting %s to %s...", disk, output))
+		if err := p.convert(disk, output); err != nil {
+			result.Paths = append(result.Paths, output)
+			result.Destroy()
+			return nil, false, err
+		}
+		result.Paths = append(result.Paths, output)
+	}
+
+	return result, p.config.KeepInputArtifact, nil
+}
+
+// convert converts the disk image. When the size of the disk has to be
+// aligned, it is converted to a raw image first, which can be resized.
+func (p *PostProcessor) convert(disk, output string) error {
+	inputFormat := inputFormats[strings.ToLower(filepath.Ext(disk))]
+	if p.config.AlignSize > 0 {
+		size, err := p.virtualSize(disk, inputFormat)
+		if err != nil {
+			return err
+		}
+
+		if aligned := alignSize(size, p.config.AlignSize); aligned != size {
+			tmp := output + ".tmp"
+			defer os.Remove(tmp)
+
+			args := []string{"convert", "-O", "raw"}
+			if inputFormat != "" {
+				args = append(args, "-f", inputFormat)
+			}
+			if _, err := p.qemuImg(append(args, disk, tmp)...); err != nil {
+				return err
+			}
+			log.Printf("Resizing %s from %d to %d bytes", tmp, size, aligned)
+			if err := os.Truncate(tmp, aligned); err != nil {
+				return fmt.Errorf("Error resizing the disk image: %s", err)
+			}
+			disk, inputFormat = tmp, "raw"
+		}
+	}
+
+	_, err := p.qemuImg(p.convertArgs(disk, inputFormat, output)...)
+	return err
+}
+
+// convertArgs returns the arguments of qemu-img that convert the disk.
+func (p *PostProcessor) convertArgs(disk, inputFormat, output string) []string {
+	format := formats[p.config.Format]
+	args := []string{"convert", "-O", format.Name}
+	if inputFormat != "" {
+		args = append(args, "-f", inputFormat)
+	}
+	if p.config.Compress && p.config.Format == "qcow2" {
+		args = append(args, "-c")
+	}
+	if p.config.Sparse != nil && !*p.config.Sparse {
+		args = append(args, "-S", "0")
+	}
+
+	var options []string
+	subformat := p.config.Subformat
+	if p.config.Compress && p.config.Format == "vmdk" {
+		subformat = "streamOptimized"
+	}
+	if subformat != "" {
+		options = append(options, "subformat="+subformat)
+	}
+	if p.config.BlockSize > 0 {
+		options = append(options, fmt.Sprintf("%s=%d", format.BlockSizeOption, p.config.BlockSize))
+	}
+	if p.config.AlignSize > 0 && p.config.Format == "vhd" {
+		// Otherwise the size is rounded to the geometry of the disk
+		options = append(options, "force_size")
+	}
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+
+	return append(args, disk, output)
+}
+
+// virtualSize returns the size of the disk seen by the machine.
+func (p *PostProcessor) virtualSize(disk, inputFormat string) (int64, error) {
+	args := []string{"info", "--output=json"}
+	if inputFormat != "" {
+		args = append(args, "-f", inputFormat)
+	}
+	out, err := p.qemuImg(append(args, disk)...)
+	if err != nil {
+		return 0, err
+	}
+
+	var info struct {
+		VirtualSize int64 `json:"virtual-size"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return 0, fmt.Errorf("Error parsing the information of %s: %s", disk, err)
+	}
+	return info.VirtualSize, nil
+}
+
+func (p *PostProcessor) qemuImg(args ...string) ([]byte, error) {
+	if p.run != nil {
+		return p.run(args...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	log.Printf("Executing qemu-img: %#v", args)
+	cmd := exec.Command(p.config.QemuImgPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("qemu-img error: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// alignSize rounds the size up to a multiple of align.
+func alignSize(size, align int64) int64 {
+	if size%align == 0 {
+		return size
+	}
+	return (size/align + 1) * align
+}
+
+// diskFiles returns the disk images of the artifact. All the files of the
+// QEMU builder are disks, which don't always have an extension.
+func diskFiles(artifact packer.Artifact) []string {
+	if artifact.BuilderId() == qemu.BuilderId {
+		return artifact.Files()
+	}
+
+	var disks []string
+	for _, f := range artifact.Files() {
+		if _, ok := inputFormats[strings.ToLower(filepath.Ext(f))]; ok && !extentRe.MatchString(f) {
+			disks = append(disks, f)
+		}
+	}
+	return disks
+}
@@ -0,0 +1,178 @@
+package diskconvert
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/qemu"
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"format": "qcow2",
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestArtifact_Impl(t *testing.T) {
+	var _ packer.MetadataArtifact = new(Artifact)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	cases := []struct {
+		Config map[string]interface{}
+		Err    bool
+	}{
+		{map[string]interface{}{"format": "vhdx", "block_size": 1048576}, false},
+		{map[string]interface{}{"format": "vmdk", "compress": true}, false},
+		{map[string]interface{}{"format": "vhd", "subformat": "fixed", "align_size": 1048576}, false},
+		{map[string]interface{}{}, true},
+		{map[string]interface{}{"format": "vdi"}, true},
+		{map[string]interface{}{"format": "vhd", "compress": true}, true},
+		{map[string]interface{}{"format": "vmdk", "compress": true, "subformat": "monolithicFlat"}, true},
+		{map[string]interface{}{"format": "raw", "subformat": "fixed"}, true},
+		{map[string]interface{}{"format": "vmdk", "block_size": 65536}, true},
+		{map[string]interface{}{"format": "raw", "align_size": -1}, true},
+	}
+
+	for _, tc := range cases {
+		var p PostProcessor
+		err := p.Configure(tc.Config)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: bad: %s", tc.Config, err)
+		}
+	}
+}
+
+func TestPostProcessor_convertArgs(t *testing.T) {
+	cases := []struct {
+		Config   map[string]interface{}
+		Expected string
+	}{
+		{
+			map[string]interface{}{"format": "qcow2", "compress": true, "block_size": 65536},
+			"convert -O qcow2 -f vmdk -c -o cluster_size=65536 disk.vmdk out",
+		},
+		{
+			map[string]interface{}{"format": "vmdk", "compress": true},
+			"convert -O vmdk -f vmdk -o subformat=streamOptimized disk.vmdk out",
+		},
+		{
+			map[string]interface{}{"format": "vhd", "subformat": "fixed", "align_size": 1048576, "sparse": false},
+			"convert -O vpc -f vmdk -S 0 -o subformat=fixed,force_size disk.vmdk out",
+		},
+	}
+
+	for _, tc := range cases {
+		var p PostProcessor
+		if err := p.Configure(tc.Config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		args := strings.Join(p.convertArgs("disk.vmdk", "vmdk", "out"), " ")
+		if args != tc.Expected {
+			t.Fatalf("bad: %s", args)
+		}
+	}
+}
+
+func TestAlignSize(t *testing.T) {
+	mb := int64(1024 * 1024)
+	cases := [][3]int64{
+		{10 * mb, mb, 10 * mb},
+		{10*mb + 1, mb, 11 * mb},
+		{mb - 512, mb, mb},
+	}
+	for _, tc := range cases {
+		if actual := alignSize(tc[0], tc[1]); actual != tc[2] {
+			t.Fatalf("%d: bad: %d", tc[0], actual)
+		}
+	}
+}
+
+func TestDiskFiles(t *testing.T) {
+	artifact := &packer.MockArtifact{
+		BuilderIdValue: "mitchellh.vmware",
+		FilesValue: []string{
+			"out/disk.vmx", "out/disk.vmdk", "out/disk-s001.vmdk", "out/disk2.vmdk", "out/disk.nvram",
+		},
+	}
+	if disks := diskFiles(artifact); !reflect.DeepEqual(disks, []string{"out/disk.vmdk", "out/disk2.vmdk"}) {
+		t.Fatalf("bad: %#v", disks)
+	}
+
+	artifact = &packer.MockArtifact{
+		BuilderIdValue: qemu.BuilderId,
+		FilesValue:     []string{"out/packer-qemu"},
+	}
+	if disks := diskFiles(artifact); !reflect.DeepEqual(disks, []string{"out/packer-qemu"}) {
+		t.Fatalf("bad: %#v", disks)
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var p PostProcessor
+	c := testConfig()
+	c["align_size"] = 1024
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var calls []string
+	p.run = func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		if args[0] == "info" {
+			return []byte(`{"virtual-size": 1000}`), nil
+		}
+		// The raw image the disk is converted to first
+		output := args[len(args)-1]
+		return nil, ioutil.WriteFile(output, make([]byte, 1000), 0644)
+	}
+
+	disk := filepath.Join(td, "disk.raw")
+	artifact := &packer.MockArtifact{FilesValue: []string{disk}}
+	result, keep, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keep {
+		t.Fatal("should not keep the input artifact")
+	}
+
+	output := filepath.Join(td, "disk.qcow2")
+	if !reflect.DeepEqual(result.Files(), []string{output}) {
+		t.Fatalf("bad: %#v", result.Files())
+	}
+	expected := []string{
+		"info --output=json -f raw " + disk,
+		"convert -O raw -f raw " + disk + " " + output + ".tmp",
+		"convert -O qcow2 -f raw " + output + ".tmp " + output,
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad: %#v", calls)
+	}
+	if _, err := os.Stat(output + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("the temporary image should be removed")
+	}
+}
@@ -0,0 +1,59 @@
+package imageretention
+
+import (
+	"path"
+	"sort"
+	"time"
+)
+
+// image is an image that can be pruned.
+type image struct {
+	Id      string
+	Name    string
+	Created time.Time
+}
+
+// imageStore lists and deletes the images of a cloud.
+type imageStore interface {
+	// Images returns the images that belong to the account, it can return
+	// more than the ones matching the pattern.
+	Images() ([]*image, error)
+
+	// Delete deletes the image and the storage it uses.
+	Delete(*image) error
+}
+
+// expiredImages returns the images that match the pattern and that the
+// policy doesn't keep, the oldest first. The newest keepCount images are
+// kept, and so are the images younger than keepAge. The current image is
+// never deleted.
+func expiredImages(images []*image, pattern, current string, keepCount int, keepAge time.Duration, now time.Time) []*image {
+	var matching []*image
+	for _, i := range images {
+		if ok, _ := path.Match(pattern, i.Name); ok {
+			matching = append(matching, i)
+		}
+	}
+
+	sort.SliceStable(matching, func(a, b int) bool {
+		return matching[a].Created.After(matching[b].Created)
+	})
+
+	var expired []*image
+	for n, i := range matching {
+		if i.Id == current || i.Name == current || n < keepCount {
+			continue
+		}
+		if keepAge > 0 && now.Sub(i.Created) < keepAge {
+			continue
+		}
+		expired = append(expired, i)
+	}
+
+	// Delete the oldest first, so that an interrupted run keeps the newer
+	// images
+	for a, b := 0, len(expired)-1; a < b; a, b = a+1, b-1 {
+		expired[a], expired[b] = expired[b], expired[a]
+	}
+	return expired
+}
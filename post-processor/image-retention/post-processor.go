@@ -0,0 +1,257 @@
+// imageretention implements the packer.PostProcessor interface and deletes
+// the older images of the artifact's cloud that match a name pattern,
+// beyond the number or the age of the images to keep.
+package imageretention
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/builder/amazon/chroot"
+	awscommon "github.com/hashicorp/packer/builder/amazon/common"
+	"github.com/hashicorp/packer/builder/amazon/ebs"
+	"github.com/hashicorp/packer/builder/amazon/ebssurrogate"
+	"github.com/hashicorp/packer/builder/amazon/instance"
+	"github.com/hashicorp/packer/builder/azure/arm"
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/post-processor/amazon-import"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// vsphereBuilderId is the builder of the VMs the vsphere-template
+// post-processor turns into templates.
+const vsphereBuilderId = "mitchellh.vmware-esx"
+
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	awscommon.AccessConfig `mapstructure:",squash"`
+
+	NamePattern string        `mapstructure:"name_pattern"`
+	KeepCount   int           `mapstructure:"keep_count"`
+	KeepAge     time.Duration `mapstructure:"keep_age"`
+	DryRun      bool          `mapstructure:"dry_run"`
+
+	// The connection to vSphere, like for the vsphere-template
+	// post-processor.
+	Host       string `mapstructure:"host"`
+	Insecure   bool   `mapstructure:"insecure"`
+	Username   string `mapstructure:"username"`
+	Password   string `mapstructure:"password"`
+	Datacenter string `mapstructure:"datacenter"`
+	Folder     string `mapstructure:"folder"`
+
+	// The service principal of the azure-arm builder.
+	ClientID             string `mapstructure:"client_id"`
+	ClientSecret         string `mapstructure:"client_secret"`
+	TenantID             string `mapstructure:"tenant_id"`
+	SubscriptionID       string `mapstructure:"subscription_id"`
+	CloudEnvironmentName string `mapstructure:"cloud_environment_name"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+
+	// targets returns where to prune images for the artifact, it is
+	// replaced in tests.
+	targets func(ui packer.Ui, artifact packer.Artifact) ([]*target, error)
+}
+
+// target is a place with images of the same kind, like the AMIs of a
+// region, and the image of the artifact in it.
+type target struct {
+	Name    string
+	Store   imageStore
+	Current string
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+	errs = packer.MultiErrorAppend(errs, p.config.AccessConfig.Prepare(&p.config.ctx)...)
+
+	if p.config.NamePattern == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("name_pattern must be set"))
+	} else if _, err := path.Match(p.config.NamePattern, ""); err != nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Invalid name_pattern: %s", err))
+	}
+	if p.config.KeepCount < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("keep_count can't be negative"))
+	}
+	if p.config.KeepAge < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("keep_age can't be negative"))
+	}
+	if p.config.KeepCount == 0 && p.config.KeepAge == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("keep_count or keep_age must be set"))
+	}
+	if p.config.Folder != "" && !strings.HasPrefix(p.config.Folder, "/") {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Folder must be bound to the root"))
+	}
+	if p.config.CloudEnvironmentName == "" {
+		p.config.CloudEnvironmentName = arm.DefaultCloudEnvironmentName
+	}
+	if _, err := arm.CloudEnvironment(p.config.CloudEnvironmentName); err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	targets := p.targets
+	if targets == nil {
+		targets = p.artifactTargets
+	}
+	ts, err := targets(ui, artifact)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	for _, t := range ts {
+		if c, ok := t.Store.(io.Closer); ok {
+			defer c.Close()
+		}
+
+		ui.Say(fmt.Sprintf("Looking for images matching '%s' to prune in %s...",
+			p.config.NamePattern, t.Name))
+		images, err := t.Store.Images()
+		if err != nil {
+			return nil, false, fmt.Errorf("Error listing the images in %s: %s", t.Name, err)
+		}
+
+		expired := expiredImages(images, p.config.NamePattern, t.Current,
+			p.config.KeepCount, p.config.KeepAge, now)
+		if len(expired) == 0 {
+			ui.Message("No images to prune.")
+			continue
+		}
+
+		for _, i := range expired {
+			if p.config.DryRun {
+				ui.Message(fmt.Sprintf("Would delete %s (%s), created %s",
+					i.Name, i.Id, i.Created.Format(time.RFC3339)))
+				continue
+			}
+
+			ui.Message(fmt.Sprintf("Deleting %s (%s), created %s",
+				i.Name, i.Id, i.Created.Format(time.RFC3339)))
+			if err := t.Store.Delete(i); err != nil {
+				return nil, false, fmt.Errorf("Error deleting %s: %s", i.Id, err)
+			}
+		}
+	}
+
+	return artifact, true, nil
+}
+
+// artifactTargets returns the targets of the cloud the artifact was built
+// in. Only the artifact's ID and state are used, since post-processors
+// get the artifact over RPC.
+func (p *PostProcessor) artifactTargets(ui packer.Ui, artifact packer.Artifact) ([]*target, error) {
+	switch artifact.BuilderId() {
+	case chroot.BuilderId, ebs.BuilderId, ebssurrogate.BuilderId, instance.BuilderId,
+		amazonimport.BuilderId:
+		var targets []*target
+		for _, ami := range strings.Split(artifact.Id(), ",") {
+			parts := strings.SplitN(ami, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Unexpected ID of an AMI artifact: %s", artifact.Id())
+			}
+
+			access := p.config.AccessConfig
+			access.RawRegion = parts[0]
+			session, err := access.Session()
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, &target{
+				Name:    "the AMIs of " + parts[0],
+				Store:   newAmazonStore(session),
+				Current: parts[1],
+			})
+		}
+		return targets, nil
+
+	case googlecompute.BuilderId:
+		project, _ := artifact.State("ProjectId").(string)
+		accountFile, _ := artifact.State("AccountFilePath").(string)
+		store, err := newGoogleComputeStore(ui, project, accountFile)
+		if err != nil {
+			return nil, err
+		}
+		return []*target{{
+			Name:    "the images of project " + project,
+			Store:   store,
+			Current: artifact.Id(),
+		}}, nil
+
+	case vsphereBuilderId:
+		if p.config.Host == "" || p.config.Username == "" || p.config.Password == "" {
+			return nil, fmt.Errorf(
+				"host, username and password must be set to prune vSphere templates")
+		}
+		sdk, err := url.Parse(fmt.Sprintf("https://%v/sdk", p.config.Host))
+		if err != nil {
+			return nil, fmt.Errorf("Error invalid vSphere sdk endpoint: %s", err)
+		}
+		sdk.User = url.UserPassword(p.config.Username, p.config.Password)
+		return []*target{{
+			Name: "the vSphere templates of " + p.config.Host,
+			Store: &vsphereStore{
+				url:        sdk,
+				insecure:   p.config.Insecure,
+				datacenter: p.config.Datacenter,
+				folder:     p.config.Folder,
+			},
+			Current: artifact.Id(),
+		}}, nil
+
+	case arm.BuilderId:
+		resourceGroup, _ := artifact.State("ManagedImageResourceGroupName").(string)
+		if resourceGroup == "" {
+			return nil, fmt.Errorf("Can only prune Azure managed images, not VHDs")
+		}
+		if p.config.ClientID == "" || p.config.ClientSecret == "" || p.config.SubscriptionID == "" {
+			return nil, fmt.Errorf(
+				"client_id, client_secret and subscription_id must be set to prune Azure images")
+		}
+		store, err := newAzureStore(&p.config, resourceGroup)
+		if err != nil {
+			return nil, err
+		}
+		current, _ := artifact.State("ManagedImageName").(string)
+		return []*target{{
+			Name:    "the managed images of resource group " + resourceGroup,
+			Store:   store,
+			Current: current,
+		}}, nil
+	}
+
+	return nil, fmt.Errorf(
+		"Unknown artifact type: %s\nCan only prune AMIs, Azure managed images, "+
+			"Google Compute Engine images and vSphere templates.", artifact.BuilderId())
+}
@@ -0,0 +1,264 @@
+package imageretention
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/hashicorp/packer/builder/azure/arm"
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/packer"
+)
+
+type mockStore struct {
+	images  []*image
+	deleted []string
+}
+
+func (s *mockStore) Images() ([]*image, error) {
+	return s.images, nil
+}
+
+func (s *mockStore) Delete(i *image) error {
+	s.deleted = append(s.deleted, i.Id)
+	return nil
+}
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"name_pattern": "app-*",
+		"keep_count":   2,
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func testImages(now time.Time) []*image {
+	day := 24 * time.Hour
+	return []*image{
+		{Id: "i-1", Name: "app-1", Created: now.Add(-10 * day)},
+		{Id: "i-4", Name: "app-4", Created: now},
+		{Id: "i-2", Name: "app-2", Created: now.Add(-5 * day)},
+		{Id: "i-3", Name: "app-3", Created: now.Add(-1 * day)},
+		{Id: "i-0", Name: "base-0", Created: now.Add(-20 * day)},
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	cases := []struct {
+		Key   string
+		Value interface{}
+		Err   bool
+	}{
+		{"keep_age", "168h", false},
+		{"name_pattern", "", true},
+		{"name_pattern", "app-[", true},
+		{"keep_count", -1, true},
+		{"keep_count", 0, true},
+		{"folder", "templates", true},
+		{"cloud_environment_name", "China", false},
+		{"cloud_environment_name", "Mars", true},
+	}
+
+	for _, tc := range cases {
+		c := testConfig()
+		c[tc.Key] = tc.Value
+
+		var p PostProcessor
+		err := p.Configure(c)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s=%v: bad: %s", tc.Key, tc.Value, err)
+		}
+	}
+}
+
+func TestExpiredImages(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	cases := []struct {
+		KeepCount int
+		KeepAge   time.Duration
+		Current   string
+		Expected  []string
+	}{
+		{2, 0, "i-4", []string{"i-1", "i-2"}},
+		{1, 0, "i-2", []string{"i-1", "i-3"}},
+		{0, 2 * day, "i-4", []string{"i-1", "i-2"}},
+		{3, 2 * day, "i-4", []string{"i-1"}},
+		{1, 7 * day, "i-4", []string{"i-1"}},
+		{10, 0, "i-4", nil},
+	}
+
+	for _, tc := range cases {
+		var ids []string
+		for _, i := range expiredImages(testImages(now), "app-*", tc.Current, tc.KeepCount, tc.KeepAge, now) {
+			ids = append(ids, i.Id)
+		}
+		if !reflect.DeepEqual(ids, tc.Expected) {
+			t.Fatalf("%d %s: bad: %#v", tc.KeepCount, tc.KeepAge, ids)
+		}
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	store := &mockStore{images: testImages(time.Now())}
+
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	p.targets = func(packer.Ui, packer.Artifact) ([]*target, error) {
+		return []*target{{Name: "test", Store: store, Current: "i-4"}}, nil
+	}
+
+	artifact := &packer.MockArtifact{IdValue: "i-4"}
+	result, keep, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !keep || result != artifact {
+		t.Fatalf("bad: %#v", result)
+	}
+	if !reflect.DeepEqual(store.deleted, []string{"i-1", "i-2"}) {
+		t.Fatalf("bad: %#v", store.deleted)
+	}
+}
+
+func TestPostProcessorPostProcess_dryRun(t *testing.T) {
+	store := &mockStore{images: testImages(time.Now())}
+
+	var p PostProcessor
+	c := testConfig()
+	c["dry_run"] = true
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	p.targets = func(packer.Ui, packer.Artifact) ([]*target, error) {
+		return []*target{{Name: "test", Store: store, Current: "i-4"}}, nil
+	}
+
+	if _, _, err := p.PostProcess(testUi(), &packer.MockArtifact{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(store.deleted) > 0 {
+		t.Fatalf("bad: %#v", store.deleted)
+	}
+}
+
+func TestGoogleComputeStore(t *testing.T) {
+	created := time.Now()
+	driver := &googlecompute.DriverMock{
+		ListImagesResult: []*googlecompute.Image{{Name: "app-1", Created: created}},
+	}
+	store := &googleComputeStore{driver: driver}
+
+	images, err := store.Images()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []*image{{Id: "app-1", Name: "app-1", Created: created}}
+	if !reflect.DeepEqual(images, expected) {
+		t.Fatalf("bad: %#v", images)
+	}
+
+	if err := store.Delete(images[0]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if driver.DeleteImageName != "app-1" {
+		t.Fatalf("bad: %s", driver.DeleteImageName)
+	}
+}
+
+func TestAzureStore(t *testing.T) {
+	var deleted []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/subscriptions/sub/resourceGroups/images/providers/Microsoft.Compute/images"
+		switch {
+		case r.Method == "GET" && r.URL.Path == prefix:
+			fmt.Fprintf(w, `{"value": [
+				{"id": "%[1]s/app-1", "name": "app-1", "tags": {"packer_created": "2018-01-02T15:04:05Z"}},
+				{"id": "%[1]s/app-0", "name": "app-0"}
+			], "nextLink": "%[2]s/next"}`, prefix, ts.URL)
+		case r.Method == "GET" && r.URL.Path == "/next":
+			fmt.Fprintf(w, `{"value": [
+				{"id": "%[1]s/app-2", "name": "app-2", "tags": {"packer_created": "yesterday"}}
+			]}`, prefix)
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, prefix+"/"):
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, prefix+"/"))
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	store := &azureStore{
+		client:        compute.NewImagesClientWithBaseURI(ts.URL, "sub"),
+		resourceGroup: "images",
+	}
+
+	images, err := store.Images()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Images without the creation tag are listed without a time, and
+	// images with a malformed tag are left out
+	prefix := "/subscriptions/sub/resourceGroups/images/providers/Microsoft.Compute/images"
+	expected := []*image{
+		{Id: prefix + "/app-1", Name: "app-1", Created: time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{Id: prefix + "/app-0", Name: "app-0"},
+	}
+	if !reflect.DeepEqual(images, expected) {
+		t.Fatalf("bad: %#v", images)
+	}
+
+	if err := store.Delete(images[1]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"app-0"}) {
+		t.Fatalf("bad: %#v", deleted)
+	}
+}
+
+func TestPostProcessorArtifactTargets_azure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// VHDs can't be pruned
+	vhd := &packer.MockArtifact{BuilderIdValue: arm.BuilderId}
+	if _, err := p.artifactTargets(testUi(), vhd); err == nil {
+		t.Fatal("should fail")
+	}
+
+	// The service principal is required
+	managed := &packer.MockArtifact{
+		BuilderIdValue: arm.BuilderId,
+		StateValues: map[string]interface{}{
+			"ManagedImageResourceGroupName": "images",
+			"ManagedImageName":              "app-2",
+		},
+	}
+	if _, err := p.artifactTargets(testUi(), managed); err == nil {
+		t.Fatal("should fail")
+	}
+}
@@ -0,0 +1,78 @@
+package imageretention
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// amazonStore prunes the AMIs of a region owned by the account, with
+// their snapshots.
+type amazonStore struct {
+	conn *ec2.EC2
+
+	// snapshots are the snapshots of the listed AMIs.
+	snapshots map[string][]string
+}
+
+func newAmazonStore(s *session.Session) *amazonStore {
+	return &amazonStore{conn: ec2.New(s)}
+}
+
+func (s *amazonStore) Images() ([]*image, error) {
+	resp, err := s.conn.DescribeImages(&ec2.DescribeImagesInput{
+		Owners: []*string{aws.String("self")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.snapshots = make(map[string][]string)
+	images := make([]*image, 0, len(resp.Images))
+	for _, i := range resp.Images {
+		created, err := time.Parse(time.RFC3339, aws.StringValue(i.CreationDate))
+		if err != nil {
+			log.Printf("Error parsing the creation date of %s: %s",
+				aws.StringValue(i.ImageId), err)
+			continue
+		}
+
+		for _, b := range i.BlockDeviceMappings {
+			if b.Ebs != nil && b.Ebs.SnapshotId != nil {
+				s.snapshots[*i.ImageId] = append(s.snapshots[*i.ImageId], *b.Ebs.SnapshotId)
+			}
+		}
+		images = append(images, &image{
+			Id:      aws.StringValue(i.ImageId),
+			Name:    aws.StringValue(i.Name),
+			Created: created,
+		})
+	}
+
+	return images, nil
+}
+
+func (s *amazonStore) Delete(i *image) error {
+	_, err := s.conn.DeregisterImage(&ec2.DeregisterImageInput{
+		ImageId: aws.String(i.Id),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The snapshots can only be deleted once the AMI is deregistered
+	for _, id := range s.snapshots[i.Id] {
+		_, err := s.conn.DeleteSnapshot(&ec2.DeleteSnapshotInput{
+			SnapshotId: aws.String(id),
+		})
+		if err != nil {
+			return fmt.Errorf("Error deleting snapshot %s: %s", id, err)
+		}
+	}
+
+	return nil
+}
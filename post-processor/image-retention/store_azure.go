@@ -0,0 +1,101 @@
+package imageretention
+
+import (
+	"log"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/hashicorp/packer/builder/azure/arm"
+	azurecommon "github.com/hashicorp/packer/builder/azure/common"
+)
+
+// azureStore prunes the managed images of a resource group. Azure doesn't
+// report when images were created, the azure-arm builder tags the managed
+// images it builds with the time instead. Images without the tag are
+// considered older than any tagged image.
+type azureStore struct {
+	client        compute.ImagesClient
+	resourceGroup string
+}
+
+func newAzureStore(c *Config, resourceGroup string) (*azureStore, error) {
+	env, err := arm.CloudEnvironment(c.CloudEnvironmentName)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := c.TenantID
+	if tenantID == "" {
+		tenantID, err = azurecommon.FindTenantID(*env, c.SubscriptionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	oauthConfig, err := adal.NewOAuthConfig(env.ActiveDirectoryEndpoint, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := adal.NewServicePrincipalToken(
+		*oauthConfig, c.ClientID, c.ClientSecret, env.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	client := compute.NewImagesClientWithBaseURI(env.ResourceManagerEndpoint, c.SubscriptionID)
+	client.Authorizer = autorest.NewBearerAuthorizer(token)
+	return &azureStore{client: client, resourceGroup: resourceGroup}, nil
+}
+
+func (s *azureStore) Images() ([]*image, error) {
+	var images []*image
+	result, err := s.client.ListByResourceGroup(s.resourceGroup)
+	for {
+		if err != nil {
+			return nil, err
+		}
+		if result.Value != nil {
+			for _, i := range *result.Value {
+				if img := azureImage(i); img != nil {
+					images = append(images, img)
+				}
+			}
+		}
+		if result.NextLink == nil || *result.NextLink == "" {
+			return images, nil
+		}
+		result, err = s.client.ListByResourceGroupNextResults(result)
+	}
+}
+
+func (s *azureStore) Delete(i *image) error {
+	_, errCh := s.client.Delete(s.resourceGroup, i.Name, nil)
+	return <-errCh
+}
+
+// azureImage returns the image with the creation time the azure-arm
+// builder tagged it with, or nil if the tag can't be parsed.
+func azureImage(i compute.Image) *image {
+	result := &image{}
+	if i.ID != nil {
+		result.Id = *i.ID
+	}
+	if i.Name != nil {
+		result.Name = *i.Name
+	}
+
+	if i.Tags == nil {
+		return result
+	}
+	if created := (*i.Tags)[arm.ManagedImageCreatedTag]; created != nil {
+		t, err := time.Parse(time.RFC3339, *created)
+		if err != nil {
+			log.Printf("Error parsing the creation time of %s: %s", result.Id, err)
+			return nil
+		}
+		result.Created = t
+	}
+	return result
+}
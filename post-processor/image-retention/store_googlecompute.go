@@ -0,0 +1,49 @@
+package imageretention
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/builder/googlecompute"
+	"github.com/hashicorp/packer/packer"
+)
+
+// googleComputeStore prunes the images of a Google Compute Engine project.
+type googleComputeStore struct {
+	driver googlecompute.Driver
+}
+
+func newGoogleComputeStore(ui packer.Ui, project, accountFile string) (*googleComputeStore, error) {
+	var account googlecompute.AccountFile
+	if accountFile != "" {
+		if err := googlecompute.ProcessAccountFile(&account, accountFile); err != nil {
+			return nil, fmt.Errorf("Error fetching account credentials: %s", err)
+		}
+	}
+
+	driver, err := googlecompute.NewDriverGCE(ui, project, &account)
+	if err != nil {
+		return nil, err
+	}
+	return &googleComputeStore{driver: driver}, nil
+}
+
+func (s *googleComputeStore) Images() ([]*image, error) {
+	list, err := s.driver.ListImages()
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]*image, 0, len(list))
+	for _, i := range list {
+		images = append(images, &image{
+			Id:      i.Name,
+			Name:    i.Name,
+			Created: i.Created,
+		})
+	}
+	return images, nil
+}
+
+func (s *googleComputeStore) Delete(i *image) error {
+	return <-s.driver.DeleteImage(i.Id)
+}
@@ -0,0 +1,104 @@
+package imageretention
+
+import (
+	"context"
+	"net/url"
+	"path"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// vsphereStore prunes the templates of a vSphere folder.
+type vsphereStore struct {
+	url        *url.URL
+	insecure   bool
+	datacenter string
+	folder     string
+
+	client *govmomi.Client
+	vms    map[string]*object.VirtualMachine
+}
+
+func (s *vsphereStore) connect(ctx context.Context) error {
+	if s.client != nil {
+		return nil
+	}
+
+	c, err := govmomi.NewClient(ctx, s.url, s.insecure)
+	if err != nil {
+		return err
+	}
+	s.client = c
+	return nil
+}
+
+func (s *vsphereStore) Images() ([]*image, error) {
+	ctx := context.Background()
+	if err := s.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	finder := find.NewFinder(s.client.Client, false)
+	dc, err := finder.DatacenterOrDefault(ctx, s.datacenter)
+	if err != nil {
+		return nil, err
+	}
+
+	vms, err := finder.VirtualMachineList(ctx, path.Join(dc.InventoryPath, "vm", s.folder, "*"))
+	if err != nil {
+		if _, ok := err.(*find.NotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(vms))
+	s.vms = make(map[string]*object.VirtualMachine)
+	for _, vm := range vms {
+		refs = append(refs, vm.Reference())
+		s.vms[vm.Reference().Value] = vm
+	}
+
+	var props []mo.VirtualMachine
+	pc := property.DefaultCollector(s.client.Client)
+	if err := pc.Retrieve(ctx, refs, []string{"name", "config.template", "config.modified"}, &props); err != nil {
+		return nil, err
+	}
+
+	// Only templates are pruned, the VMs of the folder may be in use. A
+	// template isn't modified after it is created.
+	var images []*image
+	for _, p := range props {
+		if p.Config == nil || !p.Config.Template {
+			continue
+		}
+		images = append(images, &image{
+			Id:      p.Reference().Value,
+			Name:    p.Name,
+			Created: p.Config.Modified,
+		})
+	}
+	return images, nil
+}
+
+// Close logs out of vSphere.
+func (s *vsphereStore) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Logout(context.Background())
+}
+
+func (s *vsphereStore) Delete(i *image) error {
+	ctx := context.Background()
+	task, err := s.vms[i.Id].Destroy(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
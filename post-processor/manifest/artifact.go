@@ -1,6 +1,11 @@
 package manifest
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/packer/packer"
+)
 
 const BuilderId = "packer.post-processor.manifest"
 
@@ -16,6 +21,9 @@ type Artifact struct {
 	ArtifactFiles []ArtifactFile `json:"files"`
 	ArtifactId    string         `json:"artifact_id"`
 	PackerRunUUID string         `json:"packer_run_uuid"`
+
+	CustomData map[string]json.RawMessage `json:"custom_data,omitempty"`
+	Metadata   packer.ArtifactMetadata    `json:"metadata,omitempty"`
 }
 
 func (a *Artifact) BuilderId() string {
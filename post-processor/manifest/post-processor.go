@@ -20,7 +20,12 @@ type Config struct {
 
 	OutputPath string `mapstructure:"output"`
 	StripPath  bool   `mapstructure:"strip_path"`
-	ctx        interpolate.Context
+
+	// JSON files written during the build, like the summaries of
+	// provisioners, that are added to the custom data of the build by key.
+	CustomDataFiles map[string]string `mapstructure:"custom_data_files"`
+
+	ctx interpolate.Context
 }
 
 type PostProcessor struct {
@@ -78,6 +83,12 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, source packer.Artifact) (packe
 	artifact.BuilderType = p.config.PackerBuilderType
 	artifact.BuildName = p.config.PackerBuildName
 	artifact.BuildTime = time.Now().Unix()
+	if metadata := packer.ArtifactMetadataOf(source); len(metadata) > 0 {
+		artifact.Metadata = metadata
+	}
+	if artifact.CustomData, err = p.customData(); err != nil {
+		return source, true, err
+	}
 	// Since each post-processor runs in a different process we need a way to
 	// coordinate between various post-processors in a single packer run. We do
 	// this by setting a UUID per run and tracking this in the manifest file.
@@ -136,3 +147,23 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, source packer.Artifact) (packe
 
 	return source, true, nil
 }
+
+// customData reads the custom_data_files.
+func (p *PostProcessor) customData() (map[string]json.RawMessage, error) {
+	if len(p.config.CustomDataFiles) == 0 {
+		return nil, nil
+	}
+
+	data := make(map[string]json.RawMessage)
+	for key, path := range p.config.CustomDataFiles {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read custom data %s: %s", key, err)
+		}
+		if !json.Valid(contents) {
+			return nil, fmt.Errorf("Custom data %s in %s isn't valid JSON", key, path)
+		}
+		data[key] = json.RawMessage(contents)
+	}
+	return data, nil
+}
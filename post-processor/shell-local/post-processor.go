@@ -136,7 +136,7 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 	// If we have an inline script, then turn that into a temporary
 	// shell script and use that.
 	if p.config.Inline != nil {
-		tf, err := ioutil.TempFile("", "packer-shell")
+		tf, err := ioutil.TempFile(p.config.PackerTempDir, "packer-shell")
 		if err != nil {
 			return nil, false, fmt.Errorf("Error preparing shell script: %s", err)
 		}
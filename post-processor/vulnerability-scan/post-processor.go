@@ -0,0 +1,221 @@
+// vulnerabilityscan implements the packer.PostProcessor interface and scans
+// the image of the artifact for vulnerabilities with Trivy, failing the
+// build if it has findings at or above a severity.
+package vulnerabilityscan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/builder/amazon/chroot"
+	"github.com/hashicorp/packer/builder/amazon/ebs"
+	"github.com/hashicorp/packer/builder/amazon/ebssurrogate"
+	"github.com/hashicorp/packer/builder/amazon/instance"
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/post-processor/docker-import"
+	"github.com/hashicorp/packer/post-processor/docker-tag"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	TrivyPath             string        `mapstructure:"trivy_path"`
+	Severity              string        `mapstructure:"severity"`
+	IgnoreUnfixed         bool          `mapstructure:"ignore_unfixed"`
+	IgnoreVulnerabilities []string      `mapstructure:"ignore_vulnerabilities"`
+	ReportPath            string        `mapstructure:"report_path"`
+	ExtraArgs             []string      `mapstructure:"extra_args"`
+	Timeout               time.Duration `mapstructure:"timeout"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+
+	// run runs Trivy with the arguments and returns its JSON report, it is
+	// replaced in tests.
+	run func(ctx context.Context, args []string) ([]byte, error)
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.TrivyPath == "" {
+		p.config.TrivyPath = "trivy"
+	}
+	if p.config.Severity == "" {
+		p.config.Severity = "CRITICAL"
+	}
+	if p.config.Timeout == 0 {
+		p.config.Timeout = 30 * time.Minute
+	}
+
+	var errs *packer.MultiError
+	p.config.Severity = strings.ToUpper(p.config.Severity)
+	if severityLevel(p.config.Severity) < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"severity must be one of %s", strings.Join(severities, ", ")))
+	}
+	if p.config.Timeout < 0 {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("timeout can't be negative"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	target, err := scanTarget(artifact)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ui.Say(fmt.Sprintf("Scanning %s for vulnerabilities...", target.Name))
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	run := p.run
+	if run == nil {
+		run = p.runTrivy
+	}
+	args := append(target.Args, p.config.ExtraArgs...)
+	if p.config.IgnoreUnfixed {
+		args = append(args, "--ignore-unfixed")
+	}
+	report, err := run(ctx, args)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, false, fmt.Errorf(
+			"Timeout after %s waiting for the scan of %s", p.config.Timeout, target.Name)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("Error scanning %s: %s", target.Name, err)
+	}
+
+	if p.config.ReportPath != "" {
+		if err := ioutil.WriteFile(p.config.ReportPath, report, 0644); err != nil {
+			return nil, false, fmt.Errorf("Error writing the scan report: %s", err)
+		}
+		ui.Message(fmt.Sprintf("Wrote the scan report to %s", p.config.ReportPath))
+	}
+
+	findings, err := parseReport(report)
+	if err != nil {
+		return nil, false, err
+	}
+	findings = ignoreFindings(findings, p.config.IgnoreVulnerabilities)
+
+	counts := countSeverities(findings)
+	metadata := make(packer.ArtifactMetadata)
+	for _, s := range severities {
+		metadata.Set("vulnerability_scan."+strings.ToLower(s), fmt.Sprintf("%d", counts[s]))
+	}
+	ui.Message(fmt.Sprintf("Found %d vulnerabilities: %s", len(findings), formatCounts(counts)))
+
+	failed := failingFindings(findings, p.config.Severity)
+	if len(failed) > 0 {
+		for _, f := range failed {
+			ui.Error(f.String())
+		}
+		return nil, false, fmt.Errorf(
+			"%d vulnerabilities of severity %s or higher found in %s",
+			len(failed), p.config.Severity, target.Name)
+	}
+
+	return packer.WithArtifactMetadata(artifact, metadata), true, nil
+}
+
+// runTrivy runs Trivy, logging its progress, and returns its JSON report.
+func (p *PostProcessor) runTrivy(ctx context.Context, args []string) ([]byte, error) {
+	td, err := ioutil.TempDir("", "packer-vulnerability-scan")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(td)
+
+	output := filepath.Join(td, "report.json")
+	args = append(args, "--format", "json", "--output", output, "--quiet")
+	log.Printf("Running %s %s", p.config.TrivyPath, strings.Join(args, " "))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.config.TrivyPath, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	log.Printf("Trivy output: %s", stderr.String())
+
+	return ioutil.ReadFile(output)
+}
+
+// target is what Trivy scans for an artifact.
+type target struct {
+	Name string
+	Args []string
+}
+
+// diskImageExts are the extensions of the disk images Trivy can scan.
+var diskImageExts = []string{".img", ".raw", ".vmdk"}
+
+// scanTarget returns the Trivy arguments that scan the image of the
+// artifact: Docker images by ID, AMIs in their region and the disk images
+// of the artifact files.
+func scanTarget(artifact packer.Artifact) (*target, error) {
+	switch artifact.BuilderId() {
+	case dockerimport.BuilderId, dockertag.BuilderId:
+		return &target{
+			Name: "image " + artifact.Id(),
+			Args: []string{"image", artifact.Id()},
+		}, nil
+	case chroot.BuilderId, ebs.BuilderId, ebssurrogate.BuilderId, instance.BuilderId:
+		// The ID is a list of region:ami, the AMIs are copies of the
+		// same image so scanning the first is enough
+		parts := strings.SplitN(strings.Split(artifact.Id(), ",")[0], ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Unexpected ID of an AMI artifact: %s", artifact.Id())
+		}
+		return &target{
+			Name: fmt.Sprintf("AMI %s in %s", parts[1], parts[0]),
+			Args: []string{"vm", "--aws-region", parts[0], "ami:" + parts[1]},
+		}, nil
+	}
+
+	for _, f := range artifact.Files() {
+		for _, ext := range diskImageExts {
+			if strings.EqualFold(filepath.Ext(f), ext) {
+				return &target{
+					Name: "disk image " + f,
+					Args: []string{"vm", f},
+				}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"Unknown artifact type: %s\nCan only scan Docker images, AMIs and "+
+			"raw or VMDK disk images.", artifact.BuilderId())
+}
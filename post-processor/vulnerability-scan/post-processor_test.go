@@ -0,0 +1,202 @@
+package vulnerabilityscan
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/builder/amazon/ebs"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/post-processor/docker-import"
+)
+
+const testReport = `{
+  "SchemaVersion": 2,
+  "Results": [
+    {
+      "Target": "alpine:3.7 (alpine 3.7.3)",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2019-1", "PkgName": "musl", "InstalledVersion": "1.1.18", "FixedVersion": "1.1.19", "Severity": "CRITICAL"},
+        {"VulnerabilityID": "CVE-2019-2", "PkgName": "openssl", "InstalledVersion": "1.0.2", "Severity": "HIGH"},
+        {"VulnerabilityID": "CVE-2019-3", "PkgName": "zlib", "InstalledVersion": "1.2.11", "Severity": "low"}
+      ]
+    },
+    {
+      "Target": "app/Gemfile.lock"
+    }
+  ]
+}`
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.TrivyPath != "trivy" || p.config.Severity != "CRITICAL" {
+		t.Fatalf("bad: %#v", p.config)
+	}
+
+	c := testConfig()
+	c["severity"] = "high"
+	p = PostProcessor{}
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.Severity != "HIGH" {
+		t.Fatalf("bad: %s", p.config.Severity)
+	}
+
+	c["severity"] = "severe"
+	p = PostProcessor{}
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestScanTarget(t *testing.T) {
+	cases := []struct {
+		Artifact packer.Artifact
+		Args     []string
+	}{
+		{
+			&packer.MockArtifact{BuilderIdValue: dockerimport.BuilderId, IdValue: "sha256:abc"},
+			[]string{"image", "sha256:abc"},
+		},
+		{
+			&packer.MockArtifact{BuilderIdValue: ebs.BuilderId, IdValue: "us-east-1:ami-1,eu-west-1:ami-2"},
+			[]string{"vm", "--aws-region", "us-east-1", "ami:ami-1"},
+		},
+		{
+			&packer.MockArtifact{BuilderIdValue: "mitchellh.vmware", FilesValue: []string{"out/disk.vmx", "out/disk.vmdk"}},
+			[]string{"vm", "out/disk.vmdk"},
+		},
+		{
+			&packer.MockArtifact{BuilderIdValue: "packer.docker", FilesValue: []string{"image.tar"}},
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		target, err := scanTarget(tc.Artifact)
+		if tc.Args == nil {
+			if err == nil {
+				t.Fatalf("%s: should error", tc.Artifact.BuilderId())
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Artifact.BuilderId(), err)
+		}
+		if !reflect.DeepEqual(target.Args, tc.Args) {
+			t.Fatalf("%s: bad: %#v", tc.Artifact.BuilderId(), target.Args)
+		}
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	findings, err := parseReport([]byte(testReport))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(findings) != 3 {
+		t.Fatalf("bad: %#v", findings)
+	}
+	if findings[2].Severity != "LOW" || findings[0].Target != "alpine:3.7 (alpine 3.7.3)" {
+		t.Fatalf("bad: %#v", findings)
+	}
+
+	// The report of older versions of Trivy
+	findings, err = parseReport([]byte(`[{"Target": "a", "Vulnerabilities": [{"VulnerabilityID": "CVE-1", "Severity": "MEDIUM"}]}]`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(findings) != 1 || findings[0].VulnerabilityID != "CVE-1" {
+		t.Fatalf("bad: %#v", findings)
+	}
+
+	if _, err := parseReport([]byte("not json")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestFailingFindings(t *testing.T) {
+	findings, err := parseReport([]byte(testReport))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Severity string
+		Ignored  []string
+		Expected []string
+	}{
+		{"CRITICAL", nil, []string{"CVE-2019-1"}},
+		{"HIGH", nil, []string{"CVE-2019-1", "CVE-2019-2"}},
+		{"HIGH", []string{"cve-2019-1"}, []string{"CVE-2019-2"}},
+		{"UNKNOWN", nil, []string{"CVE-2019-1", "CVE-2019-2", "CVE-2019-3"}},
+	}
+
+	for _, tc := range cases {
+		var ids []string
+		for _, f := range failingFindings(ignoreFindings(findings, tc.Ignored), tc.Severity) {
+			ids = append(ids, f.VulnerabilityID)
+		}
+		if !reflect.DeepEqual(ids, tc.Expected) {
+			t.Fatalf("%s %v: bad: %#v", tc.Severity, tc.Ignored, ids)
+		}
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	var p PostProcessor
+	c := testConfig()
+	c["severity"] = "HIGH"
+	c["ignore_unfixed"] = true
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var args []string
+	report := testReport
+	p.run = func(_ context.Context, a []string) ([]byte, error) {
+		args = a
+		return []byte(report), nil
+	}
+
+	artifact := &packer.MockArtifact{BuilderIdValue: dockerimport.BuilderId, IdValue: "sha256:abc"}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should fail on the critical and high findings")
+	}
+	if !reflect.DeepEqual(args, []string{"image", "sha256:abc", "--ignore-unfixed"}) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	report = `{"Results": [{"Target": "a", "Vulnerabilities": [{"VulnerabilityID": "CVE-1", "Severity": "LOW"}]}]}`
+	result, keep, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !keep || result.Id() != "sha256:abc" {
+		t.Fatalf("bad: %#v", result)
+	}
+	metadata := packer.ArtifactMetadataOf(result)
+	if metadata.Get("vulnerability_scan.low") != "1" || metadata.Get("vulnerability_scan.critical") != "0" {
+		t.Fatalf("bad: %#v", metadata)
+	}
+}
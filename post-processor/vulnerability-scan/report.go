@@ -0,0 +1,137 @@
+package vulnerabilityscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// severities are the severities of Trivy, from the lowest.
+var severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// severityLevel returns the rank of the severity, or -1 if it is unknown
+// to Trivy.
+func severityLevel(s string) int {
+	for i, v := range severities {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// finding is a vulnerability of a package of the scanned image.
+type finding struct {
+	Target           string `json:"-"`
+	VulnerabilityID  string `json:"VulnerabilityID"`
+	PkgName          string `json:"PkgName"`
+	InstalledVersion string `json:"InstalledVersion"`
+	FixedVersion     string `json:"FixedVersion"`
+	Severity         string `json:"Severity"`
+	Title            string `json:"Title"`
+}
+
+func (f *finding) String() string {
+	s := fmt.Sprintf("%s %s: %s %s", f.Severity, f.VulnerabilityID, f.PkgName, f.InstalledVersion)
+	if f.FixedVersion != "" {
+		s += fmt.Sprintf(" (fixed in %s)", f.FixedVersion)
+	}
+	if f.Title != "" {
+		s += " - " + f.Title
+	}
+	return s
+}
+
+type reportResult struct {
+	Target          string    `json:"Target"`
+	Vulnerabilities []finding `json:"Vulnerabilities"`
+}
+
+// parseReport returns the findings of a JSON report of Trivy. Older
+// versions of Trivy write the list of results, newer ones an object with
+// the results.
+func parseReport(report []byte) ([]finding, error) {
+	var results []reportResult
+	trimmed := strings.TrimSpace(string(report))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(report, &results); err != nil {
+			return nil, fmt.Errorf("Error parsing the scan report: %s", err)
+		}
+	} else {
+		var r struct {
+			Results []reportResult `json:"Results"`
+		}
+		if err := json.Unmarshal(report, &r); err != nil {
+			return nil, fmt.Errorf("Error parsing the scan report: %s", err)
+		}
+		results = r.Results
+	}
+
+	var findings []finding
+	for _, r := range results {
+		for _, f := range r.Vulnerabilities {
+			f.Target = r.Target
+			f.Severity = strings.ToUpper(f.Severity)
+			if severityLevel(f.Severity) < 0 {
+				f.Severity = "UNKNOWN"
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings, nil
+}
+
+// ignoreFindings returns the findings without the ignored vulnerabilities.
+func ignoreFindings(findings []finding, ignored []string) []finding {
+	if len(ignored) == 0 {
+		return findings
+	}
+
+	ignore := make(map[string]bool)
+	for _, id := range ignored {
+		ignore[strings.ToUpper(id)] = true
+	}
+
+	var result []finding
+	for _, f := range findings {
+		if !ignore[strings.ToUpper(f.VulnerabilityID)] {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// failingFindings returns the findings of the severity or higher, the
+// most severe first.
+func failingFindings(findings []finding, severity string) []finding {
+	threshold := severityLevel(severity)
+
+	var result []finding
+	for _, f := range findings {
+		if severityLevel(f.Severity) >= threshold {
+			result = append(result, f)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return severityLevel(result[i].Severity) > severityLevel(result[j].Severity)
+	})
+	return result
+}
+
+// countSeverities returns the number of findings of each severity.
+func countSeverities(findings []finding) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	return counts
+}
+
+func formatCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(severities))
+	for i := len(severities) - 1; i >= 0; i-- {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[severities[i]], severities[i]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,10 +1,13 @@
 package ansiblelocal
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/hashicorp/packer/common"
@@ -59,12 +62,39 @@ type Config struct {
 
 	// The command to run ansible-galaxy
 	GalaxyCommand string
+
+	// The optional local directory of role and collection archives
+	// uploaded for installing the galaxy file offline.
+	GalaxyOfflineBundle string `mapstructure:"galaxy_offline_bundle"`
+
+	// The optional version of Ansible to install in a virtualenv before
+	// running it.
+	AnsibleVersion string `mapstructure:"ansible_version"`
+
+	// The command to run Python when installing Ansible
+	PythonCommand string `mapstructure:"python_command"`
 }
 
 type Provisioner struct {
 	config Config
+
+	// Whether the staging directory is the default one, which depends on
+	// the guest.
+	defaultStagingDir bool
+
+	// What the galaxy file installs.
+	galaxyRoles       bool
+	galaxyCollections bool
+
+	// Whether the guest runs Windows, where Ansible runs under WSL.
+	wsl bool
 }
 
+var (
+	galaxyRolesRe       = regexp.MustCompile(`(?m)^roles:`)
+	galaxyCollectionsRe = regexp.MustCompile(`(?m)^collections:`)
+)
+
 func (p *Provisioner) Prepare(raws ...interface{}) error {
 	err := config.Decode(&p.config, &config.DecodeOpts{
 		Interpolate:        true,
@@ -84,9 +114,13 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	if p.config.GalaxyCommand == "" {
 		p.config.GalaxyCommand = "ansible-galaxy"
 	}
+	if p.config.PythonCommand == "" {
+		p.config.PythonCommand = "python3"
+	}
 
 	if p.config.StagingDir == "" {
 		p.config.StagingDir = filepath.ToSlash(filepath.Join(DefaultStagingDir, uuid.TimeOrderedUUID()))
+		p.defaultStagingDir = true
 	}
 
 	// Validation
@@ -109,9 +143,27 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		err = validateFileConfig(p.config.GalaxyFile, "galaxy_file", true)
 		if err != nil {
 			errs = packer.MultiErrorAppend(errs, err)
+		} else if err := p.readGalaxyFile(); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	// Check that the galaxy_offline_bundle directory exists, if configured
+	if len(p.config.GalaxyOfflineBundle) > 0 {
+		if len(p.config.GalaxyFile) == 0 {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("galaxy_offline_bundle requires galaxy_file to be specified."))
+		}
+		if err := validateDirConfig(p.config.GalaxyOfflineBundle, "galaxy_offline_bundle"); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
 		}
 	}
 
+	if strings.ContainsAny(p.config.AnsibleVersion, " '\"") {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("ansible_version: %s is invalid", p.config.AnsibleVersion))
+	}
+
 	// Check that the playbook_dir directory exists, if configured
 	if len(p.config.PlaybookDir) > 0 {
 		if err := validateDirConfig(p.config.PlaybookDir, "playbook_dir"); err != nil {
@@ -151,17 +203,19 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	ui.Say("Provisioning with Ansible...")
 
+	p.useGuestFacts(packer.GuestFactsOf(comm))
+
 	if len(p.config.PlaybookDir) > 0 {
 		ui.Message("Uploading Playbook directory to Ansible staging directory...")
-		if err := p.uploadDir(ui, comm, p.config.StagingDir, p.config.PlaybookDir); err != nil {
+		if err := p.uploadDir(ctx, ui, comm, p.config.StagingDir, p.config.PlaybookDir); err != nil {
 			return fmt.Errorf("Error uploading playbook_dir directory: %s", err)
 		}
 	} else {
 		ui.Message("Creating Ansible staging directory...")
-		if err := p.createDir(ui, comm, p.config.StagingDir); err != nil {
+		if err := p.createDir(ctx, ui, comm, p.config.StagingDir); err != nil {
 			return fmt.Errorf("Error creating staging directory: %s", err)
 		}
 	}
@@ -174,7 +228,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	}
 
 	if len(p.config.InventoryFile) == 0 {
-		tf, err := ioutil.TempFile("", "packer-provisioner-ansible-local")
+		tf, err := ioutil.TempFile(p.config.PackerTempDir, "packer-provisioner-ansible-local")
 		if err != nil {
 			return fmt.Errorf("Error preparing inventory file: %s", err)
 		}
@@ -208,6 +262,15 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		}
 	}
 
+	if len(p.config.GalaxyOfflineBundle) > 0 {
+		ui.Message("Uploading galaxy offline bundle...")
+		src := p.config.GalaxyOfflineBundle
+		dst := filepath.ToSlash(filepath.Join(p.config.StagingDir, "galaxy-bundle"))
+		if err := p.uploadDir(ctx, ui, comm, dst, src); err != nil {
+			return fmt.Errorf("Error uploading galaxy offline bundle: %s", err)
+		}
+	}
+
 	ui.Message("Uploading inventory file...")
 	src = p.config.InventoryFile
 	dst = filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(src)))
@@ -219,7 +282,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		ui.Message("Uploading group_vars directory...")
 		src := p.config.GroupVars
 		dst := filepath.ToSlash(filepath.Join(p.config.StagingDir, "group_vars"))
-		if err := p.uploadDir(ui, comm, dst, src); err != nil {
+		if err := p.uploadDir(ctx, ui, comm, dst, src); err != nil {
 			return fmt.Errorf("Error uploading group_vars directory: %s", err)
 		}
 	}
@@ -228,7 +291,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		ui.Message("Uploading host_vars directory...")
 		src := p.config.HostVars
 		dst := filepath.ToSlash(filepath.Join(p.config.StagingDir, "host_vars"))
-		if err := p.uploadDir(ui, comm, dst, src); err != nil {
+		if err := p.uploadDir(ctx, ui, comm, dst, src); err != nil {
 			return fmt.Errorf("Error uploading host_vars directory: %s", err)
 		}
 	}
@@ -237,7 +300,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		ui.Message("Uploading role directories...")
 		for _, src := range p.config.RolePaths {
 			dst := filepath.ToSlash(filepath.Join(p.config.StagingDir, "roles", filepath.Base(src)))
-			if err := p.uploadDir(ui, comm, dst, src); err != nil {
+			if err := p.uploadDir(ctx, ui, comm, dst, src); err != nil {
 				return fmt.Errorf("Error uploading roles: %s", err)
 			}
 		}
@@ -246,53 +309,113 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	if len(p.config.PlaybookPaths) > 0 {
 		ui.Message("Uploading additional Playbooks...")
 		playbookDir := filepath.ToSlash(filepath.Join(p.config.StagingDir, "playbooks"))
-		if err := p.createDir(ui, comm, playbookDir); err != nil {
+		if err := p.createDir(ctx, ui, comm, playbookDir); err != nil {
 			return fmt.Errorf("Error creating playbooks directory: %s", err)
 		}
 		for _, src := range p.config.PlaybookPaths {
 			dst := filepath.ToSlash(filepath.Join(playbookDir, filepath.Base(src)))
-			if err := p.uploadDir(ui, comm, dst, src); err != nil {
+			if err := p.uploadDir(ctx, ui, comm, dst, src); err != nil {
 				return fmt.Errorf("Error uploading playbooks: %s", err)
 			}
 		}
 	}
 
-	if err := p.executeAnsible(ui, comm); err != nil {
+	if len(p.config.AnsibleVersion) > 0 {
+		if err := p.installAnsible(ctx, ui, comm); err != nil {
+			return fmt.Errorf("Error installing Ansible: %s", err)
+		}
+	}
+
+	if err := p.executeAnsible(ctx, ui, comm); err != nil {
 		return fmt.Errorf("Error executing Ansible: %s", err)
 	}
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
+// readGalaxyFile finds out whether the galaxy file installs roles,
+// collections or both. Requirements files listing only roles don't have
+// a roles key.
+func (p *Provisioner) readGalaxyFile() error {
+	contents, err := ioutil.ReadFile(p.config.GalaxyFile)
+	if err != nil {
+		return fmt.Errorf("galaxy_file: %s", err)
+	}
+	p.galaxyCollections = galaxyCollectionsRe.Match(contents)
+	p.galaxyRoles = !p.galaxyCollections || galaxyRolesRe.Match(contents)
+	return nil
 }
 
-func (p *Provisioner) executeGalaxy(ui packer.Ui, comm packer.Communicator) error {
-	rolesDir := filepath.ToSlash(filepath.Join(p.config.StagingDir, "roles"))
-	galaxyFile := filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(p.config.GalaxyFile)))
+// venvDir is where Ansible is installed when ansible_version is set.
+func (p *Provisioner) venvDir() string {
+	if p.wsl {
+		// Virtualenvs don't work well on the Windows file system
+		return "/tmp/packer-ansible-venv-" + path.Base(p.config.StagingDir)
+	}
+	return path.Join(p.config.StagingDir, "venv")
+}
 
-	// ansible-galaxy install -r requirements.yml -p roles/
-	command := fmt.Sprintf("cd %s && %s install -r %s -p %s",
-		p.config.StagingDir, p.config.GalaxyCommand, galaxyFile, rolesDir)
-	ui.Message(fmt.Sprintf("Executing Ansible Galaxy: %s", command))
-	cmd := &packer.RemoteCmd{
-		Command: command,
+// commandPrefix is prepended to the ansible and ansible-galaxy commands.
+func (p *Provisioner) commandPrefix() string {
+	prefix := fmt.Sprintf("cd %s && ", p.guestPath(p.config.StagingDir))
+	if len(p.config.AnsibleVersion) > 0 {
+		prefix += fmt.Sprintf(". '%s/bin/activate' && ", p.venvDir())
 	}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	return prefix
+}
+
+func (p *Provisioner) installAnsible(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	venv := p.venvDir()
+	command := fmt.Sprintf("%s -m venv '%s' && '%s/bin/pip' install --upgrade pip && '%s/bin/pip' install 'ansible==%s'",
+		p.config.PythonCommand, venv, venv, venv, p.config.AnsibleVersion)
+	ui.Message(fmt.Sprintf("Installing Ansible %s: %s", p.config.AnsibleVersion, command))
+	cmd, err := p.runCommand(ctx, ui, comm, command)
+	if err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
-		// ansible-galaxy version 2.0.0.2 doesn't return exit codes on error..
 		return fmt.Errorf("Non-zero exit status: %d", cmd.ExitStatus)
 	}
 	return nil
 }
 
-func (p *Provisioner) executeAnsible(ui packer.Ui, comm packer.Communicator) error {
-	playbook := filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(p.config.PlaybookFile)))
-	inventory := filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(p.config.InventoryFile)))
+func (p *Provisioner) executeGalaxy(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	rolesDir := p.guestPath(filepath.ToSlash(filepath.Join(p.config.StagingDir, "roles")))
+	collectionsDir := p.guestPath(p.collectionsDir())
+	galaxyFile := p.guestPath(filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(p.config.GalaxyFile))))
+
+	var commands []string
+	if p.galaxyRoles {
+		// ansible-galaxy install -r requirements.yml -p roles/
+		commands = append(commands, fmt.Sprintf("%s%s install -r %s -p %s",
+			p.commandPrefix(), p.config.GalaxyCommand, galaxyFile, rolesDir))
+	}
+	if p.galaxyCollections {
+		// ansible-galaxy collection install -r requirements.yml -p collections/
+		commands = append(commands, fmt.Sprintf("%s%s collection install -r %s -p %s",
+			p.commandPrefix(), p.config.GalaxyCommand, galaxyFile, collectionsDir))
+	}
+
+	for _, command := range commands {
+		ui.Message(fmt.Sprintf("Executing Ansible Galaxy: %s", command))
+		cmd, err := p.runCommand(ctx, ui, comm, command)
+		if err != nil {
+			return err
+		}
+		if cmd.ExitStatus != 0 {
+			// ansible-galaxy version 2.0.0.2 doesn't return exit codes on error..
+			return fmt.Errorf("Non-zero exit status: %d", cmd.ExitStatus)
+		}
+	}
+	return nil
+}
+
+func (p *Provisioner) collectionsDir() string {
+	return filepath.ToSlash(filepath.Join(p.config.StagingDir, "collections"))
+}
+
+func (p *Provisioner) executeAnsible(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	playbook := p.guestPath(filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(p.config.PlaybookFile))))
+	inventory := p.guestPath(filepath.ToSlash(filepath.Join(p.config.StagingDir, filepath.Base(p.config.InventoryFile))))
 
 	extraArgs := fmt.Sprintf(" --extra-vars \"packer_build_name=%s packer_builder_type=%s packer_http_addr=%s\" ",
 		p.config.PackerBuildName, p.config.PackerBuilderType, common.GetHTTPAddr())
@@ -302,18 +425,21 @@ func (p *Provisioner) executeAnsible(ui packer.Ui, comm packer.Communicator) err
 
 	// Fetch external dependencies
 	if len(p.config.GalaxyFile) > 0 {
-		if err := p.executeGalaxy(ui, comm); err != nil {
+		if err := p.executeGalaxy(ctx, ui, comm); err != nil {
 			return fmt.Errorf("Error executing Ansible Galaxy: %s", err)
 		}
 	}
 
-	command := fmt.Sprintf("cd %s && %s %s%s -c local -i %s",
-		p.config.StagingDir, p.config.Command, playbook, extraArgs, inventory)
-	ui.Message(fmt.Sprintf("Executing Ansible: %s", command))
-	cmd := &packer.RemoteCmd{
-		Command: command,
+	prefix := p.commandPrefix()
+	if p.galaxyCollections {
+		prefix += fmt.Sprintf("ANSIBLE_COLLECTIONS_PATHS='%s' ", p.guestPath(p.collectionsDir()))
 	}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+
+	command := fmt.Sprintf("%s%s %s%s -c local -i %s",
+		prefix, p.config.Command, playbook, extraArgs, inventory)
+	ui.Message(fmt.Sprintf("Executing Ansible: %s", command))
+	cmd, err := p.runCommand(ctx, ui, comm, command)
+	if err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -366,12 +492,10 @@ func (p *Provisioner) uploadFile(ui packer.Ui, comm packer.Communicator, dst, sr
 	return nil
 }
 
-func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir string) error {
+func (p *Provisioner) createDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dir string) error {
 	ui.Message(fmt.Sprintf("Creating directory: %s", dir))
-	cmd := &packer.RemoteCmd{
-		Command: fmt.Sprintf("mkdir -p '%s'", dir),
-	}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	cmd, err := p.runCommand(ctx, ui, comm, fmt.Sprintf("mkdir -p '%s'", p.guestPath(dir)))
+	if err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -380,8 +504,8 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 	return nil
 }
 
-func (p *Provisioner) uploadDir(ui packer.Ui, comm packer.Communicator, dst, src string) error {
-	if err := p.createDir(ui, comm, dst); err != nil {
+func (p *Provisioner) uploadDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dst, src string) error {
+	if err := p.createDir(ctx, ui, comm, dst); err != nil {
 		return err
 	}
 
@@ -1,6 +1,7 @@
 package ansiblelocal
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -188,3 +189,196 @@ func TestProvisionerPrepare_Dirs(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestProvisionerPrepare_GalaxyFile(t *testing.T) {
+	cases := []struct {
+		Contents    string
+		Roles       bool
+		Collections bool
+	}{
+		{"- src: geerlingguy.nginx\n", true, false},
+		{"roles:\n  - src: geerlingguy.nginx\n", true, false},
+		{"collections:\n  - community.general\n", false, true},
+		{"roles:\n  - src: geerlingguy.nginx\ncollections:\n  - community.general\n", true, true},
+	}
+
+	playbook_file, err := ioutil.TempFile("", "playbook")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(playbook_file.Name())
+
+	galaxy_file, err := ioutil.TempFile("", "requirements")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(galaxy_file.Name())
+
+	for _, tc := range cases {
+		if err := ioutil.WriteFile(galaxy_file.Name(), []byte(tc.Contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var p Provisioner
+		config := testConfig()
+		config["playbook_file"] = playbook_file.Name()
+		config["galaxy_file"] = galaxy_file.Name()
+		if err := p.Prepare(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if p.galaxyRoles != tc.Roles || p.galaxyCollections != tc.Collections {
+			t.Fatalf("%q: bad roles %t or collections %t", tc.Contents, p.galaxyRoles, p.galaxyCollections)
+		}
+	}
+}
+
+func TestProvisionerPrepare_GalaxyOfflineBundle(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+
+	playbook_file, err := ioutil.TempFile("", "playbook")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(playbook_file.Name())
+
+	config["playbook_file"] = playbook_file.Name()
+	config["galaxy_offline_bundle"] = os.TempDir()
+	err = p.Prepare(config)
+	if err == nil {
+		t.Fatal("should require galaxy_file")
+	}
+
+	config["galaxy_file"] = playbook_file.Name()
+	err = p.Prepare(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config["galaxy_offline_bundle"] = playbook_file.Name()
+	err = p.Prepare(config)
+	if err == nil {
+		t.Fatal("should error if galaxy_offline_bundle is not a dir")
+	}
+}
+
+// commandRecorder records the commands it runs.
+type commandRecorder struct {
+	packer.MockCommunicator
+	commands []string
+}
+
+func (c *commandRecorder) Start(cmd *packer.RemoteCmd) error {
+	c.commands = append(c.commands, cmd.Command)
+	return c.MockCommunicator.Start(cmd)
+}
+
+func TestProvisionerProvision_AnsibleVersion(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+
+	playbook_file, err := ioutil.TempFile("", "playbook")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(playbook_file.Name())
+
+	galaxy_file, err := ioutil.TempFile("", "requirements")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(galaxy_file.Name())
+	if _, err := galaxy_file.WriteString("collections:\n  - community.general\n"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	galaxy_file.Close()
+
+	config["playbook_file"] = playbook_file.Name()
+	config["galaxy_file"] = galaxy_file.Name()
+	config["ansible_version"] = "2.9.27"
+	config["staging_directory"] = "/tmp/staging"
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(commandRecorder)
+	if err := p.Provision(context.Background(), packer.TestUi(t), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"mkdir -p '/tmp/staging'",
+		"python3 -m venv '/tmp/staging/venv' && '/tmp/staging/venv/bin/pip' install --upgrade pip && '/tmp/staging/venv/bin/pip' install 'ansible==2.9.27'",
+		"cd /tmp/staging && . '/tmp/staging/venv/bin/activate' && ansible-galaxy collection install -r /tmp/staging/" +
+			filepath.Base(galaxy_file.Name()) + " -p /tmp/staging/collections",
+	}
+	if len(comm.commands) != 4 {
+		t.Fatalf("bad commands: %#v", comm.commands)
+	}
+	for i, command := range expected {
+		if comm.commands[i] != command {
+			t.Fatalf("bad command %d: %s\n\nexpected: %s", i, comm.commands[i], command)
+		}
+	}
+	if !strings.HasPrefix(comm.commands[3], "cd /tmp/staging && . '/tmp/staging/venv/bin/activate' && ANSIBLE_COLLECTIONS_PATHS='/tmp/staging/collections' ") {
+		t.Fatalf("bad ansible command: %s", comm.commands[3])
+	}
+}
+
+func TestProvisionerProvision_WSL(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+
+	playbook_file, err := ioutil.TempFile("", "playbook")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(playbook_file.Name())
+
+	config["playbook_file"] = playbook_file.Name()
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(commandRecorder)
+	facts := &packer.GuestFacts{OSFamily: "windows", TempDir: `D:\Temp`}
+	if err := p.Provision(context.Background(), packer.TestUi(t), packer.WithGuestFacts(comm, facts)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	stagingDir := p.config.StagingDir
+	if !strings.HasPrefix(stagingDir, "D:/Temp/packer-provisioner-ansible-local/") {
+		t.Fatalf("bad staging dir: %s", stagingDir)
+	}
+
+	script := "/mnt/d" + strings.TrimPrefix(stagingDir, "D:") + "/packer-ansible-command.sh"
+	for _, command := range comm.commands {
+		if command != "wsl bash '"+script+"'" {
+			t.Fatalf("bad command: %s", command)
+		}
+	}
+	if comm.UploadPath != stagingDir+"/packer-ansible-command.sh" {
+		t.Fatalf("bad upload path: %s", comm.UploadPath)
+	}
+	if !strings.HasPrefix(comm.UploadData, "cd /mnt/d/Temp/packer-provisioner-ansible-local/") {
+		t.Fatalf("bad script: %s", comm.UploadData)
+	}
+}
+
+func TestWSLPath(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{"C:/Windows/Temp", "/mnt/c/Windows/Temp"},
+		{`C:\Windows\Temp`, "/mnt/c/Windows/Temp"},
+		{"/tmp/foo", "/tmp/foo"},
+	}
+
+	for _, tc := range cases {
+		if actual := wslPath(tc.Input); actual != tc.Expected {
+			t.Fatalf("%s: got %s, expected %s", tc.Input, actual, tc.Expected)
+		}
+	}
+}
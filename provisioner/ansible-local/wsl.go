@@ -0,0 +1,70 @@
+package ansiblelocal
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// DefaultWindowsTempDir is where the default staging directory is created
+// on Windows guests whose temporary directory isn't known.
+const DefaultWindowsTempDir = "C:/Windows/Temp"
+
+// useGuestFacts runs Ansible under WSL on Windows guests. Files are
+// uploaded to the Windows file system, and the default staging directory
+// is moved to the temporary directory of Windows.
+func (p *Provisioner) useGuestFacts(facts *packer.GuestFacts) {
+	p.wsl = facts.IsWindows()
+	if !p.wsl || !p.defaultStagingDir {
+		return
+	}
+
+	tempDir := strings.TrimRight(strings.Replace(facts.TempDir, `\`, "/", -1), "/")
+	if tempDir == "" {
+		tempDir = DefaultWindowsTempDir
+	}
+	p.config.StagingDir = path.Join(tempDir, path.Base(DefaultStagingDir), path.Base(p.config.StagingDir))
+}
+
+// guestPath returns the path commands use for a path files are uploaded
+// to.
+func (p *Provisioner) guestPath(dir string) string {
+	if !p.wsl {
+		return dir
+	}
+	return wslPath(dir)
+}
+
+// wslPath returns the path of a Windows file under WSL, where drives are
+// mounted in /mnt.
+func wslPath(dir string) string {
+	dir = strings.Replace(dir, `\`, "/", -1)
+	if len(dir) < 2 || dir[1] != ':' {
+		return dir
+	}
+	return "/mnt/" + strings.ToLower(dir[:1]) + dir[2:]
+}
+
+// runCommand runs a shell command on the guest. Under WSL, the command is
+// uploaded in a script that is run by bash, so that it isn't mangled by
+// the quoting of cmd.exe.
+func (p *Provisioner) runCommand(ctx context.Context, ui packer.Ui, comm packer.Communicator, command string) (*packer.RemoteCmd, error) {
+	cmd := &packer.RemoteCmd{
+		Command: command,
+	}
+	if p.wsl {
+		script := path.Join(p.config.StagingDir, "packer-ansible-command.sh")
+		if err := comm.Upload(script, strings.NewReader(command+"\n"), nil); err != nil {
+			return nil, fmt.Errorf("Error uploading command script: %s", err)
+		}
+		cmd.Command = fmt.Sprintf("wsl bash '%s'", wslPath(script))
+	}
+
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
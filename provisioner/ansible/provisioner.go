@@ -3,6 +3,7 @@ package ansible
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -180,7 +181,7 @@ func (p *Provisioner) getVersion() error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	ui.Say("Provisioning with Ansible...")
 
 	k, err := newUserKey(p.config.SSHAuthorizedKeyFile)
@@ -295,24 +296,14 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		}()
 	}
 
-	if err := p.executeAnsible(ui, comm, k.privKeyFile); err != nil {
+	if err := p.executeAnsible(ctx, ui, comm, k.privKeyFile); err != nil {
 		return fmt.Errorf("Error executing Ansible: %s", err)
 	}
 
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	if p.done != nil {
-		close(p.done)
-	}
-	if p.adapter != nil {
-		p.adapter.Shutdown()
-	}
-	os.Exit(0)
-}
-
-func (p *Provisioner) executeAnsible(ui packer.Ui, comm packer.Communicator, privKeyFile string) error {
+func (p *Provisioner) executeAnsible(ctx context.Context, ui packer.Ui, comm packer.Communicator, privKeyFile string) error {
 	playbook, _ := filepath.Abs(p.config.PlaybookFile)
 	inventory := p.config.inventoryFile
 	var envvars []string
@@ -328,7 +319,7 @@ func (p *Provisioner) executeAnsible(ui packer.Ui, comm packer.Communicator, pri
 		envvars = append(envvars, p.config.AnsibleEnvVars...)
 	}
 
-	cmd := exec.Command(p.config.Command, args...)
+	cmd := exec.CommandContext(ctx, p.config.Command, args...)
 
 	cmd.Env = os.Environ()
 	if len(envvars) > 0 {
@@ -0,0 +1,305 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/provisioner"
+	"golang.org/x/crypto/pkcs12"
+)
+
+// Certificate is a certificate, or a bundle of them, to install.
+type Certificate struct {
+	// The local path of a PEM or PFX file.
+	Source string
+
+	// The certificate itself instead of a source file: PEM text, or a
+	// base64 encoded PFX. This allows certificates to be passed in via
+	// user variables.
+	Content string
+
+	// Either "pem" or "pfx". By default this is "pfx" for sources ending
+	// in .pfx or .p12 and "pem" otherwise.
+	Format string
+
+	// The password of a PFX file.
+	Password string
+
+	// The name of the file in the trust store on unix guests.
+	Name string
+
+	// The store to install into on Windows guests, e.g. LocalMachine\Root.
+	Store string
+
+	// The expected SHA-1 thumbprint. One of the certificates has to
+	// match it, both locally and in the store after installation.
+	Thumbprint string
+
+	// If true, private keys imported on Windows are marked exportable.
+	Exportable bool
+
+	data          []byte
+	certs         []*x509.Certificate
+	storeLocation string
+	storeName     string
+}
+
+var (
+	nonHex    = regexp.MustCompile("[^0-9A-F]")
+	validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+func (c *Certificate) prepare(index int, windows bool) error {
+	if c.Source == "" && c.Content == "" {
+		return errors.New("either source or content must be specified")
+	}
+	if c.Source != "" && c.Content != "" {
+		return errors.New("only one of source or content can be specified")
+	}
+
+	if c.Format == "" {
+		switch strings.ToLower(filepath.Ext(c.Source)) {
+		case ".pfx", ".p12":
+			c.Format = "pfx"
+		default:
+			c.Format = "pem"
+		}
+	}
+	c.Format = strings.ToLower(c.Format)
+
+	var err error
+	if c.Source != "" {
+		if c.data, err = ioutil.ReadFile(c.Source); err != nil {
+			return fmt.Errorf("Bad source '%s': %s", c.Source, err)
+		}
+	} else if c.Format == "pfx" {
+		if c.data, err = base64.StdEncoding.DecodeString(c.Content); err != nil {
+			return fmt.Errorf("PFX content is not base64 encoded: %s", err)
+		}
+	} else {
+		c.data = []byte(c.Content)
+	}
+
+	switch c.Format {
+	case "pem":
+		c.certs, err = parsePEM(c.data)
+	case "pfx":
+		c.certs, err = parsePFX(c.data, c.Password)
+	default:
+		return fmt.Errorf("format must be pem or pfx, got %q", c.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.Thumbprint != "" {
+		c.Thumbprint = nonHex.ReplaceAllString(strings.ToUpper(c.Thumbprint), "")
+		found := false
+		for _, cert := range c.certs {
+			if thumbprint(cert) == c.Thumbprint {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no certificate matches thumbprint %s", c.Thumbprint)
+		}
+	}
+
+	if c.Name == "" {
+		if c.Source != "" {
+			base := filepath.Base(c.Source)
+			c.Name = strings.TrimSuffix(base, filepath.Ext(base))
+		} else {
+			c.Name = fmt.Sprintf("packer-%d", index)
+		}
+	}
+	if !validName.MatchString(c.Name) {
+		return fmt.Errorf("name %q may only contain letters, digits, dots, dashes and underscores", c.Name)
+	}
+
+	if c.Store == "" {
+		if c.Format == "pfx" {
+			c.Store = `LocalMachine\My`
+		} else {
+			c.Store = `LocalMachine\Root`
+		}
+	}
+	if windows {
+		parts := strings.SplitN(c.Store, `\`, 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return fmt.Errorf("store %q must be in the form Location\\Name", c.Store)
+		}
+		switch strings.ToLower(parts[0]) {
+		case "localmachine":
+			c.storeLocation = "LocalMachine"
+		case "currentuser":
+			c.storeLocation = "CurrentUser"
+		default:
+			return fmt.Errorf("store location must be LocalMachine or CurrentUser, got %q", parts[0])
+		}
+		c.storeName = parts[1]
+	}
+
+	return nil
+}
+
+func parsePEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing certificate: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+	return certs, nil
+}
+
+func parsePFX(data []byte, password string) ([]*x509.Certificate, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading PFX, check the password: %s", err)
+	}
+
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing certificate: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found in PFX")
+	}
+	return certs, nil
+}
+
+// thumbprint returns the SHA-1 thumbprint of the certificate the way
+// Windows displays it.
+func thumbprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+const windowsHeader = `$ErrorActionPreference = 'Stop'
+Remove-Item -LiteralPath $MyInvocation.MyCommand.Path -Force -ErrorAction SilentlyContinue
+
+function Add-PackerCertificates($location, $name, $certs, $thumbprint) {
+    $store = New-Object System.Security.Cryptography.X509Certificates.X509Store($name, $location)
+    $store.Open('ReadWrite')
+    try {
+        foreach ($cert in $certs) {
+            if ($store.Certificates.Find('FindByThumbprint', $cert.Thumbprint, $false).Count -gt 0) {
+                Write-Host "$($cert.Thumbprint) is already in $location\$name"
+                continue
+            }
+            $store.Add($cert)
+            Write-Host "Imported $($cert.Thumbprint) into $location\$name"
+        }
+        if ($thumbprint -and $store.Certificates.Find('FindByThumbprint', $thumbprint, $false).Count -eq 0) {
+            throw "Certificate $thumbprint was not found in $location\$name"
+        }
+    } finally {
+        $store.Close()
+    }
+}
+
+`
+
+// windowsScript imports the certificates using the .NET certificate
+// store API, which works on every PowerShell version.
+func windowsScript(certs []*Certificate) string {
+	var buf bytes.Buffer
+	buf.WriteString(windowsHeader)
+
+	for _, c := range certs {
+		flags := "MachineKeySet,PersistKeySet"
+		if c.storeLocation == "CurrentUser" {
+			flags = "UserKeySet,PersistKeySet"
+		}
+		if c.Exportable {
+			flags += ",Exportable"
+		}
+
+		buf.WriteString("$certs = New-Object System.Security.Cryptography.X509Certificates.X509Certificate2Collection\n")
+		if c.Format == "pfx" {
+			fmt.Fprintf(&buf, "$certs.Import([Convert]::FromBase64String('%s'), %s, '%s')\n",
+				base64.StdEncoding.EncodeToString(c.data), provisioner.PowerShellQuote(c.Password), flags)
+		} else {
+			for _, cert := range c.certs {
+				fmt.Fprintf(&buf, "$certs.Import([Convert]::FromBase64String('%s'))\n",
+					base64.StdEncoding.EncodeToString(cert.Raw))
+			}
+		}
+		fmt.Fprintf(&buf, "Add-PackerCertificates '%s' %s $certs '%s'\n",
+			c.storeLocation, provisioner.PowerShellQuote(c.storeName), c.Thumbprint)
+	}
+
+	return buf.String()
+}
+
+const unixHeader = `set -e
+rm -f "$0"
+
+if [ -d /etc/pki/ca-trust/source/anchors ]; then
+    dir=/etc/pki/ca-trust/source/anchors
+    update="update-ca-trust extract"
+elif [ -d /etc/pki/trust/anchors ]; then
+    dir=/etc/pki/trust/anchors
+    update=update-ca-certificates
+elif [ -d /usr/local/share/ca-certificates ] || command -v update-ca-certificates >/dev/null 2>&1; then
+    dir=/usr/local/share/ca-certificates
+    update=update-ca-certificates
+else
+    echo "No supported CA trust store found" >&2
+    exit 1
+fi
+mkdir -p "$dir"
+
+`
+
+// unixScript writes the certificates to the distribution's CA trust
+// store and refreshes it. Private keys are never installed.
+func unixScript(certs []*Certificate) string {
+	var buf bytes.Buffer
+	buf.WriteString(unixHeader)
+
+	for _, c := range certs {
+		fmt.Fprintf(&buf, "echo \"Installing %s.crt into $dir\"\n", c.Name)
+		fmt.Fprintf(&buf, "cat > \"$dir/%s.crt\" <<'PACKER_CERTIFICATE'\n", c.Name)
+		for _, cert := range c.certs {
+			pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+		buf.WriteString("PACKER_CERTIFICATE\n")
+	}
+
+	buf.WriteString("$update\n")
+	return buf.String()
+}
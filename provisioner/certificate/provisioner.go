@@ -0,0 +1,157 @@
+// This package implements a provisioner for Packer that installs
+// certificates into the certificate stores of the remote machine.
+package certificate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type guestOSTypeConfig struct {
+	executeCommand string
+	remotePath     string
+	script         func([]*Certificate) string
+}
+
+var guestOSTypeConfigs = map[string]guestOSTypeConfig{
+	provisioner.UnixOSType: {
+		executeCommand: "{{if .Sudo}}sudo {{end}}sh '{{.Path}}'",
+		remotePath:     "/tmp/packer-certificate-%s.sh",
+		script:         unixScript,
+	},
+	provisioner.WindowsOSType: {
+		executeCommand: `powershell -executionpolicy bypass -noprofile -file "{{.Path}}"`,
+		remotePath:     "c:/Windows/Temp/packer-certificate-%s.ps1",
+		script:         windowsScript,
+	},
+}
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The certificates to install.
+	Certificates []*Certificate `mapstructure:"certificates"`
+
+	// The operating system of the remote machine, unix or windows.
+	GuestOSType string `mapstructure:"guest_os_type"`
+
+	// The command used to run the generated install script.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// If true, the install script isn't run with sudo on unix guests.
+	PreventSudo bool `mapstructure:"prevent_sudo"`
+
+	// The remote path the install script is uploaded to. The script
+	// removes itself when it runs since it may contain passwords.
+	RemotePath string `mapstructure:"remote_path"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config            Config
+	guestOSTypeConfig guestOSTypeConfig
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+	Sudo bool
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.GuestOSType == "" {
+		p.config.GuestOSType = provisioner.DefaultOSType
+	}
+	p.config.GuestOSType = strings.ToLower(p.config.GuestOSType)
+
+	var ok bool
+	p.guestOSTypeConfig, ok = guestOSTypeConfigs[p.config.GuestOSType]
+	if !ok {
+		return fmt.Errorf("Invalid guest_os_type: \"%s\"", p.config.GuestOSType)
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = p.guestOSTypeConfig.executeCommand
+	}
+
+	if p.config.RemotePath == "" {
+		p.config.RemotePath = fmt.Sprintf(p.guestOSTypeConfig.remotePath, uuid.TimeOrderedUUID())
+	}
+
+	var errs *packer.MultiError
+	if len(p.config.Certificates) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("At least one certificate must be specified."))
+	}
+
+	windows := p.config.GuestOSType == provisioner.WindowsOSType
+	for i, c := range p.config.Certificates {
+		if err := c.prepare(i, windows); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("certificates[%d]: %s", i, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say("Provisioning with certificate...")
+	for _, c := range p.config.Certificates {
+		for _, cert := range c.certs {
+			ui.Message(fmt.Sprintf("Installing %s (%s)", cert.Subject.CommonName, thumbprint(cert)))
+		}
+	}
+
+	script := p.guestOSTypeConfig.script(p.config.Certificates)
+
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: p.config.RemotePath,
+		Sudo: !p.config.PreventSudo,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	log.Printf("Uploading certificate script to %s", p.config.RemotePath)
+	if err := comm.Upload(p.config.RemotePath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading script: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Installing certificates failed with exit status: %d", cmd.ExitStatus)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package certificate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+const (
+	caThumbprint     = "48E11FFD1A1E62AF8C015B92C35849754E8AD5BC"
+	serverThumbprint = "A429EF42E87225975C51DD4F76A3DCF4451EF7D8"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"certificates": []map[string]interface{}{
+			{"source": "test-fixtures/ca.pem"},
+		},
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.GuestOSType != "unix" {
+		t.Fatalf("unexpected guest_os_type: %s", p.config.GuestOSType)
+	}
+	if !strings.HasPrefix(p.config.RemotePath, "/tmp/packer-certificate-") {
+		t.Fatalf("unexpected remote_path: %s", p.config.RemotePath)
+	}
+
+	c := p.config.Certificates[0]
+	if c.Format != "pem" || c.Name != "ca" || c.Store != `LocalMachine\Root` {
+		t.Fatalf("unexpected certificate defaults: %#v", c)
+	}
+}
+
+func TestProvisionerPrepare_Certificates(t *testing.T) {
+	pem, err := ioutil.ReadFile("test-fixtures/ca.pem")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pfx, err := ioutil.ReadFile("test-fixtures/server.pfx")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Certificate map[string]interface{}
+		Err         bool
+	}{
+		{map[string]interface{}{"content": string(pem), "name": "corp-ca"}, false},
+		{map[string]interface{}{"source": "test-fixtures/ca.pem", "thumbprint": "48:e1:1f:fd:1a:1e:62:af:8c:01:5b:92:c3:58:49:75:4e:8a:d5:bc"}, false},
+		{map[string]interface{}{"source": "test-fixtures/ca.pem", "thumbprint": serverThumbprint}, true},
+		{map[string]interface{}{"source": "test-fixtures/server.pfx", "password": "packer", "thumbprint": serverThumbprint}, false},
+		{map[string]interface{}{"content": base64.StdEncoding.EncodeToString(pfx), "format": "pfx", "password": "packer"}, false},
+		{map[string]interface{}{"source": "test-fixtures/server.pfx", "password": "wrong"}, true},
+		{map[string]interface{}{"source": "test-fixtures/ca.pem", "content": string(pem)}, true},
+		{map[string]interface{}{"source": "test-fixtures/missing.pem"}, true},
+		{map[string]interface{}{"content": "not a certificate"}, true},
+		{map[string]interface{}{"content": string(pem), "format": "der"}, true},
+		{map[string]interface{}{"content": string(pem), "name": "../etc/passwd"}, true},
+		{map[string]interface{}{}, true},
+	}
+
+	for _, tc := range cases {
+		var p Provisioner
+		err := p.Prepare(map[string]interface{}{
+			"certificates": []map[string]interface{}{tc.Certificate},
+		})
+		if (err != nil) != tc.Err {
+			t.Fatalf("%v: unexpected error state: %s", tc.Certificate, err)
+		}
+	}
+}
+
+func TestProvisionerPrepare_Store(t *testing.T) {
+	cases := []struct {
+		Store    string
+		Location string
+		Name     string
+		Err      bool
+	}{
+		{"", "LocalMachine", "My", false},
+		{`currentuser\Root`, "CurrentUser", "Root", false},
+		{`LocalMachine\TrustedPublisher`, "LocalMachine", "TrustedPublisher", false},
+		{"Root", "", "", true},
+		{`Service\My`, "", "", true},
+	}
+
+	for _, tc := range cases {
+		var p Provisioner
+		err := p.Prepare(map[string]interface{}{
+			"guest_os_type": "windows",
+			"certificates": []map[string]interface{}{
+				{"source": "test-fixtures/server.pfx", "password": "packer", "store": tc.Store},
+			},
+		})
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: unexpected error state: %s", tc.Store, err)
+		}
+		if err != nil {
+			continue
+		}
+		c := p.config.Certificates[0]
+		if c.storeLocation != tc.Location || c.storeName != tc.Name {
+			t.Fatalf("%s: bad store %s\\%s", tc.Store, c.storeLocation, c.storeName)
+		}
+	}
+}
+
+func TestProvisionerProvision_Unix(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["remote_path"] = "/tmp/certs.sh"
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if comm.StartCmd.Command != "sudo sh '/tmp/certs.sh'" {
+		t.Fatalf("unexpected command: %s", comm.StartCmd.Command)
+	}
+	if !strings.Contains(comm.UploadData, "cat > \"$dir/ca.crt\" <<'PACKER_CERTIFICATE'\n-----BEGIN CERTIFICATE-----") {
+		t.Fatalf("unexpected script:\n%s", comm.UploadData)
+	}
+}
+
+func TestProvisionerProvision_Windows(t *testing.T) {
+	var p Provisioner
+	err := p.Prepare(map[string]interface{}{
+		"guest_os_type": "windows",
+		"remote_path":   "c:/Windows/Temp/certs.ps1",
+		"certificates": []map[string]interface{}{
+			{"source": "test-fixtures/ca.pem"},
+			{"source": "test-fixtures/server.pfx", "password": "it's", "store": `CurrentUser\My`, "thumbprint": serverThumbprint},
+		},
+	})
+	if err == nil {
+		t.Fatal("should have error for the wrong PFX password")
+	}
+
+	p = Provisioner{}
+	err = p.Prepare(map[string]interface{}{
+		"guest_os_type": "windows",
+		"remote_path":   "c:/Windows/Temp/certs.ps1",
+		"certificates": []map[string]interface{}{
+			{"source": "test-fixtures/ca.pem"},
+			{"source": "test-fixtures/server.pfx", "password": "packer", "store": `CurrentUser\My`, "thumbprint": serverThumbprint, "exportable": true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `powershell -executionpolicy bypass -noprofile -file "c:/Windows/Temp/certs.ps1"`
+	if comm.StartCmd.Command != expected {
+		t.Fatalf("Expect command to be: %s, got %s", expected, comm.StartCmd.Command)
+	}
+	for _, line := range []string{
+		"Add-PackerCertificates 'LocalMachine' 'Root' $certs ''\n",
+		"'packer', 'UserKeySet,PersistKeySet,Exportable')\n",
+		"Add-PackerCertificates 'CurrentUser' 'My' $certs '" + serverThumbprint + "'\n",
+	} {
+		if !strings.Contains(comm.UploadData, line) {
+			t.Fatalf("script is missing %q:\n%s", line, comm.UploadData)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package chefclient
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -199,7 +200,7 @@ func TestProvisioner_createDir(t *testing.T) {
 			t.Fatalf("err: %s", err)
 		}
 
-		if err := p.createDir(ui, comm, "/tmp/foo"); err != nil {
+		if err := p.createDir(context.Background(), ui, comm, "/tmp/foo"); err != nil {
 			t.Fatalf("err: %s", err)
 		}
 
@@ -230,7 +231,7 @@ func TestProvisioner_removeDir(t *testing.T) {
 			t.Fatalf("err: %s", err)
 		}
 
-		if err := p.removeDir(ui, comm, "/tmp/foo"); err != nil {
+		if err := p.removeDir(context.Background(), ui, comm, "/tmp/foo"); err != nil {
 			t.Fatalf("err: %s", err)
 		}
 
@@ -5,6 +5,7 @@ package chefsolo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -227,23 +228,23 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	ui.Say("Provisioning with chef-solo")
 
 	if !p.config.SkipInstall {
-		if err := p.installChef(ui, comm, p.config.Version); err != nil {
+		if err := p.installChef(ctx, ui, comm, p.config.Version); err != nil {
 			return fmt.Errorf("Error installing Chef: %s", err)
 		}
 	}
 
-	if err := p.createDir(ui, comm, p.config.StagingDir); err != nil {
+	if err := p.createDir(ctx, ui, comm, p.config.StagingDir); err != nil {
 		return fmt.Errorf("Error creating staging directory: %s", err)
 	}
 
 	cookbookPaths := make([]string, 0, len(p.config.CookbookPaths))
 	for i, path := range p.config.CookbookPaths {
 		targetPath := fmt.Sprintf("%s/cookbooks-%d", p.config.StagingDir, i)
-		if err := p.uploadDirectory(ui, comm, targetPath, path); err != nil {
+		if err := p.uploadDirectory(ctx, ui, comm, targetPath, path); err != nil {
 			return fmt.Errorf("Error uploading cookbooks: %s", err)
 		}
 
@@ -253,7 +254,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	rolesPath := ""
 	if p.config.RolesPath != "" {
 		rolesPath = fmt.Sprintf("%s/roles", p.config.StagingDir)
-		if err := p.uploadDirectory(ui, comm, rolesPath, p.config.RolesPath); err != nil {
+		if err := p.uploadDirectory(ctx, ui, comm, rolesPath, p.config.RolesPath); err != nil {
 			return fmt.Errorf("Error uploading roles: %s", err)
 		}
 	}
@@ -261,7 +262,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	dataBagsPath := ""
 	if p.config.DataBagsPath != "" {
 		dataBagsPath = fmt.Sprintf("%s/data_bags", p.config.StagingDir)
-		if err := p.uploadDirectory(ui, comm, dataBagsPath, p.config.DataBagsPath); err != nil {
+		if err := p.uploadDirectory(ctx, ui, comm, dataBagsPath, p.config.DataBagsPath); err != nil {
 			return fmt.Errorf("Error uploading data bags: %s", err)
 		}
 	}
@@ -277,7 +278,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	environmentsPath := ""
 	if p.config.EnvironmentsPath != "" {
 		environmentsPath = fmt.Sprintf("%s/environments", p.config.StagingDir)
-		if err := p.uploadDirectory(ui, comm, environmentsPath, p.config.EnvironmentsPath); err != nil {
+		if err := p.uploadDirectory(ctx, ui, comm, environmentsPath, p.config.EnvironmentsPath); err != nil {
 			return fmt.Errorf("Error uploading environments: %s", err)
 		}
 	}
@@ -292,21 +293,15 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		return fmt.Errorf("Error creating JSON attributes: %s", err)
 	}
 
-	if err := p.executeChef(ui, comm, configPath, jsonPath); err != nil {
+	if err := p.executeChef(ctx, ui, comm, configPath, jsonPath); err != nil {
 		return fmt.Errorf("Error executing Chef: %s", err)
 	}
 
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
-}
-
-func (p *Provisioner) uploadDirectory(ui packer.Ui, comm packer.Communicator, dst string, src string) error {
-	if err := p.createDir(ui, comm, dst); err != nil {
+func (p *Provisioner) uploadDirectory(ctx context.Context, ui packer.Ui, comm packer.Communicator, dst string, src string) error {
+	if err := p.createDir(ctx, ui, comm, dst); err != nil {
 		return err
 	}
 
@@ -413,11 +408,11 @@ func (p *Provisioner) createJson(ui packer.Ui, comm packer.Communicator) (string
 	return remotePath, nil
 }
 
-func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir string) error {
+func (p *Provisioner) createDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dir string) error {
 	ui.Message(fmt.Sprintf("Creating directory: %s", dir))
 
 	cmd := &packer.RemoteCmd{Command: p.guestCommands.CreateDir(dir)}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -426,7 +421,7 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 
 	// Chmod the directory to 0777 just so that we can access it as our user
 	cmd = &packer.RemoteCmd{Command: p.guestCommands.Chmod(dir, "0777")}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -436,7 +431,7 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 	return nil
 }
 
-func (p *Provisioner) executeChef(ui packer.Ui, comm packer.Communicator, config string, json string) error {
+func (p *Provisioner) executeChef(ctx context.Context, ui packer.Ui, comm packer.Communicator, config string, json string) error {
 	p.config.ctx.Data = &ExecuteTemplate{
 		ConfigPath: config,
 		JsonPath:   json,
@@ -453,7 +448,7 @@ func (p *Provisioner) executeChef(ui packer.Ui, comm packer.Communicator, config
 		Command: command,
 	}
 
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 
@@ -464,7 +459,7 @@ func (p *Provisioner) executeChef(ui packer.Ui, comm packer.Communicator, config
 	return nil
 }
 
-func (p *Provisioner) installChef(ui packer.Ui, comm packer.Communicator, version string) error {
+func (p *Provisioner) installChef(ctx context.Context, ui packer.Ui, comm packer.Communicator, version string) error {
 	ui.Message("Installing Chef...")
 
 	p.config.ctx.Data = &InstallChefTemplate{
@@ -477,7 +472,7 @@ func (p *Provisioner) installChef(ui packer.Ui, comm packer.Communicator, versio
 	}
 
 	cmd := &packer.RemoteCmd{Command: command}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 
@@ -0,0 +1,94 @@
+package containerimages
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/packer/builder/docker"
+)
+
+// RegistryAuth are the credentials of a registry. They are either given,
+// or fetched when the images are pulled from AWS ECR or from a docker
+// credential helper on the machine running Packer.
+type RegistryAuth struct {
+	// The registry, as a host or a URL.
+	Server string
+
+	Username string
+	Password string
+
+	// The name of a docker credential helper, e.g. "gcr" to run
+	// docker-credential-gcr.
+	CredentialHelper string `mapstructure:"credential_helper"`
+
+	// If true, a token is fetched from AWS ECR.
+	EcrLogin               bool `mapstructure:"ecr_login"`
+	docker.AwsAccessConfig `mapstructure:",squash"`
+
+	registry string
+}
+
+func (a *RegistryAuth) prepare() error {
+	if a.Server == "" {
+		return errors.New("server must be specified")
+	}
+	a.registry = normalizeRegistry(a.Server)
+
+	sources := 0
+	if a.Username != "" || a.Password != "" {
+		if a.Username == "" || a.Password == "" {
+			return errors.New("both username and password must be specified")
+		}
+		sources++
+	}
+	if a.CredentialHelper != "" {
+		sources++
+	}
+	if a.EcrLogin {
+		sources++
+	}
+	if sources != 1 {
+		return errors.New("exactly one of username and password, credential_helper or ecr_login must be specified")
+	}
+	return nil
+}
+
+// credentials returns the username and password to log in to the
+// registry with.
+func (a *RegistryAuth) credentials() (string, string, error) {
+	switch {
+	case a.EcrLogin:
+		return a.EcrGetLogin(a.Server)
+	case a.CredentialHelper != "":
+		return credentialHelperGet(a.CredentialHelper, a.Server)
+	default:
+		return a.Username, a.Password, nil
+	}
+}
+
+// credentialHelperGet gets credentials from a docker credential helper,
+// which reads the server on stdin and writes the credentials as JSON.
+func credentialHelperGet(helper string, server string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("Error running docker-credential-%s: %s\n%s%s",
+			helper, err, stderr.String(), stdout.String())
+	}
+
+	var creds struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return "", "", fmt.Errorf("Error parsing the output of docker-credential-%s: %s", helper, err)
+	}
+	return creds.Username, creds.Secret, nil
+}
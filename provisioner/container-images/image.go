@@ -0,0 +1,123 @@
+package containerimages
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistry is the registry of images whose name doesn't start with
+// a registry host.
+const DefaultRegistry = "docker.io"
+
+var digestRe = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// image is a reference to an image, like
+// quay.io/coreos/etcd:v3.3.1@sha256:<hex>.
+type image struct {
+	Ref string
+
+	// The name without tag or digest, the registry it is pulled from and
+	// the path of the name in the registry.
+	Name     string
+	Registry string
+	Path     string
+
+	Tag    string
+	Digest string
+}
+
+func parseImage(ref string) (*image, error) {
+	img := &image{Ref: ref, Name: ref}
+	if ref == "" || strings.ContainsAny(ref, " \t\n'\"") {
+		return nil, fmt.Errorf("invalid image reference %q", ref)
+	}
+
+	if i := strings.Index(img.Name, "@"); i >= 0 {
+		img.Digest = img.Name[i+1:]
+		img.Name = img.Name[:i]
+		if !digestRe.MatchString(img.Digest) {
+			return nil, fmt.Errorf("invalid digest in %q, must be sha256:<64 hex digits>", ref)
+		}
+	}
+
+	// A colon after the last slash separates the tag, others the port of
+	// the registry
+	if i := strings.LastIndex(img.Name, ":"); i > strings.LastIndex(img.Name, "/") {
+		img.Tag = img.Name[i+1:]
+		img.Name = img.Name[:i]
+	}
+
+	img.Registry = DefaultRegistry
+	img.Path = img.Name
+	if i := strings.Index(img.Name, "/"); i >= 0 {
+		host := img.Name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			img.Registry = normalizeRegistry(host)
+			img.Path = img.Name[i+1:]
+		}
+	}
+
+	if img.Name == "" || (img.Tag == "" && strings.HasSuffix(ref, ":")) {
+		return nil, fmt.Errorf("invalid image reference %q", ref)
+	}
+	return img, nil
+}
+
+// PullRef is the reference the image is pulled by: its digest if it is
+// pinned, which the runtime verifies.
+func (img *image) PullRef() string {
+	if img.Digest != "" {
+		return img.Name + "@" + img.Digest
+	}
+	return img.Ref
+}
+
+// TagRef is the reference the image is tagged with after pulling it by
+// digest, so that it is found by its tag too. It is empty if the image
+// doesn't need to be tagged.
+func (img *image) TagRef() string {
+	if img.Digest == "" || img.Tag == "" {
+		return ""
+	}
+	return img.Name + ":" + img.Tag
+}
+
+// fullyQualified returns the image with the registry in its name, the
+// library namespace of official Docker Hub images, and the latest tag if
+// it has neither tag nor digest.
+func (img *image) fullyQualified() *image {
+	full := *img
+	path := img.Path
+	if img.Registry == DefaultRegistry && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	full.Name = img.Registry + "/" + path
+	if full.Tag == "" && full.Digest == "" {
+		full.Tag = "latest"
+	}
+
+	full.Ref = full.Name
+	if full.Tag != "" {
+		full.Ref += ":" + full.Tag
+	}
+	if full.Digest != "" {
+		full.Ref += "@" + full.Digest
+	}
+	return &full
+}
+
+// normalizeRegistry returns the host of a registry given as a host or a
+// URL, like in docker login.
+func normalizeRegistry(server string) string {
+	server = strings.TrimPrefix(server, "https://")
+	server = strings.TrimPrefix(server, "http://")
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+	switch server {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DefaultRegistry
+	}
+	return server
+}
@@ -0,0 +1,79 @@
+package containerimages
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParseImage(t *testing.T) {
+	cases := []struct {
+		Ref      string
+		Name     string
+		Registry string
+		Tag      string
+		Digest   string
+		FullRef  string
+	}{
+		{"nginx", "nginx", "docker.io", "", "", "docker.io/library/nginx:latest"},
+		{"nginx:1.15", "nginx", "docker.io", "1.15", "", "docker.io/library/nginx:1.15"},
+		{"prom/prometheus:v2.3.0", "prom/prometheus", "docker.io", "v2.3.0", "", "docker.io/prom/prometheus:v2.3.0"},
+		{"index.docker.io/nginx", "index.docker.io/nginx", "docker.io", "", "", "docker.io/library/nginx:latest"},
+		{"localhost:5000/app", "localhost:5000/app", "localhost:5000", "", "", "localhost:5000/app:latest"},
+		{"localhost/app:1", "localhost/app", "localhost", "1", "", "localhost/app:1"},
+		{"quay.io/coreos/etcd:v3.3.1@" + testDigest, "quay.io/coreos/etcd", "quay.io", "v3.3.1", testDigest,
+			"quay.io/coreos/etcd:v3.3.1@" + testDigest},
+		{"k8s.gcr.io/pause@" + testDigest, "k8s.gcr.io/pause", "k8s.gcr.io", "", testDigest,
+			"k8s.gcr.io/pause@" + testDigest},
+	}
+
+	for _, tc := range cases {
+		img, err := parseImage(tc.Ref)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Ref, err)
+		}
+		if img.Name != tc.Name || img.Registry != tc.Registry || img.Tag != tc.Tag || img.Digest != tc.Digest {
+			t.Fatalf("%s: bad image: %#v", tc.Ref, img)
+		}
+		if full := img.fullyQualified(); full.Ref != tc.FullRef {
+			t.Fatalf("%s: bad fully qualified reference: %s", tc.Ref, full.Ref)
+		}
+	}
+}
+
+func TestParseImage_Errors(t *testing.T) {
+	for _, ref := range []string{
+		"",
+		"nginx:",
+		"nginx@",
+		"nginx@sha256:abc",
+		"nginx@md5:" + strings.Repeat("0", 32),
+		"it's",
+	} {
+		if _, err := parseImage(ref); err == nil {
+			t.Fatalf("%q: should have error", ref)
+		}
+	}
+}
+
+func TestImageRefs(t *testing.T) {
+	img, err := parseImage("nginx:1.15@" + testDigest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if img.PullRef() != "nginx@"+testDigest {
+		t.Fatalf("bad pull reference: %s", img.PullRef())
+	}
+	if img.TagRef() != "nginx:1.15" {
+		t.Fatalf("bad tag reference: %s", img.TagRef())
+	}
+
+	img, err = parseImage("nginx:1.15")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if img.PullRef() != "nginx:1.15" || img.TagRef() != "" {
+		t.Fatalf("bad references: %s, %s", img.PullRef(), img.TagRef())
+	}
+}
@@ -0,0 +1,311 @@
+// This package implements a provisioner for Packer that pulls container
+// images into the store of the container runtime of the remote machine.
+package containerimages
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The container runtimes images can be pulled into.
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+)
+
+// digestMismatch is the exit status of the script when a pulled image
+// doesn't have the digest it is pinned to.
+const digestMismatch = 19
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The images to pull. Images pinned by digest are verified, and
+	// tagged if they have a tag too.
+	Images []string `mapstructure:"images"`
+
+	// If true, every image must be pinned by digest.
+	RequireDigests bool `mapstructure:"require_digests"`
+
+	// The container runtime, docker or containerd.
+	Runtime string `mapstructure:"runtime"`
+
+	// The containerd namespace images are pulled into. Kubernetes uses
+	// k8s.io.
+	ContainerdNamespace string `mapstructure:"containerd_namespace"`
+
+	// The credentials of private registries.
+	RegistryAuths []*RegistryAuth `mapstructure:"registry_auths"`
+
+	// The command used to run the generated script.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// If true, the script isn't run with sudo.
+	PreventSudo bool `mapstructure:"prevent_sudo"`
+
+	// The remote path the script is uploaded to. The script removes
+	// itself when it runs since it contains credentials.
+	RemotePath string `mapstructure:"remote_path"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+	images []*image
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+	Sudo bool
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.Runtime == "" {
+		p.config.Runtime = RuntimeDocker
+	}
+
+	if p.config.ContainerdNamespace == "" {
+		p.config.ContainerdNamespace = "k8s.io"
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = "{{if .Sudo}}sudo {{end}}sh '{{.Path}}'"
+	}
+
+	if p.config.RemotePath == "" {
+		p.config.RemotePath = fmt.Sprintf("/tmp/packer-container-images-%s.sh", uuid.TimeOrderedUUID())
+	}
+
+	var errs *packer.MultiError
+	if p.config.Runtime != RuntimeDocker && p.config.Runtime != RuntimeContainerd {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("runtime must be docker or containerd, got %q", p.config.Runtime))
+	}
+
+	if strings.ContainsAny(p.config.ContainerdNamespace, " '\"") {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("Invalid containerd_namespace %q", p.config.ContainerdNamespace))
+	}
+
+	if len(p.config.Images) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("At least one image must be specified."))
+	}
+
+	p.images = nil
+	for i, ref := range p.config.Images {
+		img, err := parseImage(ref)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("images[%d]: %s", i, err))
+			continue
+		}
+		if p.config.RequireDigests && img.Digest == "" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("images[%d]: %s must be pinned by digest", i, ref))
+		}
+		p.images = append(p.images, img)
+	}
+
+	registries := make(map[string]bool)
+	for i, auth := range p.config.RegistryAuths {
+		if err := auth.prepare(); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("registry_auths[%d]: %s", i, err))
+			continue
+		}
+		if registries[auth.registry] {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("registry_auths[%d]: %s is specified more than once", i, auth.Server))
+		}
+		registries[auth.registry] = true
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Provisioning with container-images: pulling %d image(s) with %s",
+		len(p.images), p.config.Runtime))
+
+	creds, err := p.credentials(ui)
+	if err != nil {
+		return err
+	}
+
+	var script string
+	if p.config.Runtime == RuntimeContainerd {
+		script = containerdScript(p.config.ContainerdNamespace, p.images, creds)
+	} else {
+		script = dockerScript(p.images, creds)
+	}
+
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: p.config.RemotePath,
+		Sudo: !p.config.PreventSudo,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	log.Printf("Uploading container images script to %s", p.config.RemotePath)
+	if err := comm.Upload(p.config.RemotePath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading script: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	switch cmd.ExitStatus {
+	case 0:
+	case digestMismatch:
+		return errors.New("An image doesn't have the digest it is pinned to, see the output above")
+	default:
+		return fmt.Errorf("Pulling images failed with exit status: %d", cmd.ExitStatus)
+	}
+
+	return nil
+}
+
+// credentials fetches the credentials of the registries images are pulled
+// from, by registry.
+func (p *Provisioner) credentials(ui packer.Ui) (map[string][2]string, error) {
+	used := make(map[string]bool)
+	for _, img := range p.images {
+		used[img.Registry] = true
+	}
+
+	creds := make(map[string][2]string)
+	for _, auth := range p.config.RegistryAuths {
+		if !used[auth.registry] {
+			log.Printf("No image is pulled from %s, skipping its credentials", auth.Server)
+			continue
+		}
+		ui.Message(fmt.Sprintf("Getting credentials for %s...", auth.Server))
+		username, password, err := auth.credentials()
+		if err != nil {
+			return nil, fmt.Errorf("Error getting credentials for %s: %s", auth.Server, err)
+		}
+		creds[auth.registry] = [2]string{username, password}
+	}
+	return creds, nil
+}
+
+const dockerHeader = `set -e
+rm -f "$0"
+
+# Credentials are stored in a temporary configuration, so that they don't
+# end up in the image
+DOCKER_CONFIG=$(mktemp -d)
+export DOCKER_CONFIG
+trap 'rm -rf "$DOCKER_CONFIG"' EXIT
+
+packer_login() {
+    printf '%%s' "$3" | docker login --username "$2" --password-stdin "$1" >/dev/null
+    echo "Logged in to $1"
+}
+
+packer_pull() {
+    echo "Pulling $1"
+    docker pull "$1"
+    if [ -n "$2" ] && ! docker image inspect --format '{{range .RepoDigests}}{{println .}}{{end}}' "$1" | grep -q "@$2\$"; then
+        echo "The digest of $1 isn't $2" >&2
+        exit %d
+    fi
+    if [ -n "$3" ]; then
+        docker tag "$1" "$3"
+        echo "Tagged $3"
+    fi
+}
+
+`
+
+func dockerScript(images []*image, creds map[string][2]string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, dockerHeader, digestMismatch)
+
+	registries := make([]string, 0, len(creds))
+	for registry := range creds {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	for _, registry := range registries {
+		c := creds[registry]
+		fmt.Fprintf(&buf, "packer_login %s %s %s\n", provisioner.ShellQuote(registry), provisioner.ShellQuote(c[0]), provisioner.ShellQuote(c[1]))
+	}
+	for _, img := range images {
+		fmt.Fprintf(&buf, "packer_pull %s %s %s\n",
+			provisioner.ShellQuote(img.PullRef()), provisioner.ShellQuote(img.Digest), provisioner.ShellQuote(img.TagRef()))
+	}
+	return buf.String()
+}
+
+const containerdHeader = `set -e
+rm -f "$0"
+
+packer_pull() {
+    echo "Pulling $1"
+    if [ -n "$4" ]; then
+        ctr -n %[1]s images pull --user "$4" "$1" >/dev/null
+    else
+        ctr -n %[1]s images pull "$1" >/dev/null
+    fi
+    if [ -n "$2" ] && ! ctr -n %[1]s images ls "name==$1" | grep -q "$2"; then
+        echo "The digest of $1 isn't $2" >&2
+        exit %[2]d
+    fi
+    if [ -n "$3" ]; then
+        ctr -n %[1]s images tag --force "$1" "$3" >/dev/null
+        echo "Tagged $3"
+    fi
+}
+
+`
+
+// containerdScript pulls the images with ctr, which doesn't expand the
+// short names of Docker Hub images like docker does.
+func containerdScript(namespace string, images []*image, creds map[string][2]string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, containerdHeader, provisioner.ShellQuote(namespace), digestMismatch)
+
+	for _, img := range images {
+		user := ""
+		if c, ok := creds[img.Registry]; ok {
+			user = c[0] + ":" + c[1]
+		}
+		full := img.fullyQualified()
+		fmt.Fprintf(&buf, "packer_pull %s %s %s %s\n",
+			provisioner.ShellQuote(full.PullRef()), provisioner.ShellQuote(full.Digest), provisioner.ShellQuote(full.TagRef()), provisioner.ShellQuote(user))
+	}
+	return buf.String()
+}
@@ -0,0 +1,265 @@
+package containerimages
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"images": []string{"nginx:1.15", "registry.example.com/app:1@" + testDigest},
+		"registry_auths": []map[string]interface{}{
+			{"server": "https://registry.example.com/v2/", "username": "packer", "password": "it's secret"},
+		},
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+// testPath puts executables with the given shell scripts first in PATH,
+// and returns a function restoring it.
+func testPath(t *testing.T, scripts map[string]string) func() {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for name, script := range scripts {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.Runtime != "docker" {
+		t.Fatalf("unexpected runtime: %s", p.config.Runtime)
+	}
+	if p.config.ContainerdNamespace != "k8s.io" {
+		t.Fatalf("unexpected containerd_namespace: %s", p.config.ContainerdNamespace)
+	}
+	if !strings.HasPrefix(p.config.RemotePath, "/tmp/packer-container-images-") {
+		t.Fatalf("unexpected remote_path: %s", p.config.RemotePath)
+	}
+	if p.config.RegistryAuths[0].registry != "registry.example.com" {
+		t.Fatalf("unexpected registry: %s", p.config.RegistryAuths[0].registry)
+	}
+}
+
+func TestProvisionerPrepare_Errors(t *testing.T) {
+	cases := []map[string]interface{}{
+		{},
+		{"images": []string{"nginx:"}},
+		{"images": []string{"nginx"}, "runtime": "rkt"},
+		{"images": []string{"nginx"}, "require_digests": true},
+		{"images": []string{"nginx"}, "registry_auths": []map[string]interface{}{
+			{"username": "packer", "password": "secret"},
+		}},
+		{"images": []string{"nginx"}, "registry_auths": []map[string]interface{}{
+			{"server": "docker.io", "username": "packer"},
+		}},
+		{"images": []string{"nginx"}, "registry_auths": []map[string]interface{}{
+			{"server": "docker.io", "username": "packer", "password": "secret", "credential_helper": "pass"},
+		}},
+		{"images": []string{"nginx"}, "registry_auths": []map[string]interface{}{
+			{"server": "docker.io", "credential_helper": "pass"},
+			{"server": "index.docker.io", "credential_helper": "pass"},
+		}},
+	}
+
+	for _, config := range cases {
+		var p Provisioner
+		if err := p.Prepare(config); err == nil {
+			t.Fatalf("%v: should have error", config)
+		}
+	}
+}
+
+func TestProvisionerProvision(t *testing.T) {
+	cases := []struct {
+		Runtime  string
+		Expected []string
+	}{
+		{
+			"docker",
+			[]string{
+				`rm -f "$0"`,
+				"packer_login 'registry.example.com' 'packer' 'it'\\''s secret'\n",
+				"packer_pull 'nginx:1.15' '' ''\n",
+				"packer_pull 'registry.example.com/app@" + testDigest + "' '" + testDigest + "' 'registry.example.com/app:1'\n",
+			},
+		},
+		{
+			"containerd",
+			[]string{
+				`rm -f "$0"`,
+				"ctr -n 'k8s.io' images pull",
+				"packer_pull 'docker.io/library/nginx:1.15' '' '' ''\n",
+				"packer_pull 'registry.example.com/app@" + testDigest + "' '" + testDigest +
+					"' 'registry.example.com/app:1' 'packer:it'\\''s secret'\n",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		config := testConfig()
+		config["runtime"] = tc.Runtime
+		config["remote_path"] = "/tmp/images.sh"
+
+		var p Provisioner
+		if err := p.Prepare(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		comm := new(packer.MockCommunicator)
+		if err := p.Provision(context.Background(), testUi(), comm); err != nil {
+			t.Fatalf("%s: err: %s", tc.Runtime, err)
+		}
+
+		if comm.UploadPath != "/tmp/images.sh" {
+			t.Fatalf("%s: bad upload path: %s", tc.Runtime, comm.UploadPath)
+		}
+		if comm.StartCmd.Command != "sudo sh '/tmp/images.sh'" {
+			t.Fatalf("%s: bad command: %s", tc.Runtime, comm.StartCmd.Command)
+		}
+		for _, expected := range tc.Expected {
+			if !strings.Contains(comm.UploadData, expected) {
+				t.Fatalf("%s: script should contain %q:\n%s", tc.Runtime, expected, comm.UploadData)
+			}
+		}
+	}
+}
+
+func TestProvisionerProvision_DigestMismatch(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packer.MockCommunicator{StartExitStatus: digestMismatch}
+	err := p.Provision(context.Background(), testUi(), comm)
+	if err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestProvisionerProvision_CredentialHelper(t *testing.T) {
+	defer testPath(t, map[string]string{
+		"docker-credential-test": `read server
+echo "{\"ServerURL\": \"$server\", \"Username\": \"helper\", \"Secret\": \"from $server\"}"
+`,
+	})()
+
+	config := testConfig()
+	config["registry_auths"] = []map[string]interface{}{
+		{"server": "registry.example.com", "credential_helper": "test"},
+		{"server": "unused.example.com", "credential_helper": "missing"},
+	}
+
+	var p Provisioner
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), testUi(), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "packer_login 'registry.example.com' 'helper' 'from registry.example.com'\n"
+	if !strings.Contains(comm.UploadData, expected) {
+		t.Fatalf("script should contain %q:\n%s", expected, comm.UploadData)
+	}
+}
+
+func TestDockerScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	log := filepath.Join(dir, "docker.log")
+	defer testPath(t, map[string]string{
+		"docker": `if [ "$1" = login ]; then
+    echo "$@ $(cat) $DOCKER_CONFIG" >> ` + provisioner.ShellQuote(log) + `
+elif [ "$1" = image ]; then
+    echo "${5%@*}@` + testDigest + `"
+else
+    echo "$@" >> ` + provisioner.ShellQuote(log) + `
+fi
+`,
+	})()
+
+	img, err := parseImage("nginx:1.15@" + testDigest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	script := filepath.Join(dir, "images.sh")
+	creds := map[string][2]string{"docker.io": {"packer", "secret"}}
+	if err := ioutil.WriteFile(script, []byte(dockerScript([]*image{img}, creds)), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out, err := exec.Command("sh", script).CombinedOutput(); err != nil {
+		t.Fatalf("err: %s\n%s", err, out)
+	}
+
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Fatal("the script should remove itself")
+	}
+
+	data, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 ||
+		!strings.HasPrefix(lines[0], "login --username packer --password-stdin docker.io secret ") ||
+		lines[1] != "pull nginx@"+testDigest ||
+		lines[2] != "tag nginx@"+testDigest+" nginx:1.15" {
+		t.Fatalf("bad docker commands:\n%s", data)
+	}
+
+	config := strings.TrimPrefix(lines[0], "login --username packer --password-stdin docker.io secret ")
+	if _, err := os.Stat(config); !os.IsNotExist(err) {
+		t.Fatalf("the docker configuration %s should be removed", config)
+	}
+}
@@ -1,8 +1,11 @@
 package file
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,6 +23,14 @@ type Config struct {
 	Source  string
 	Sources []string
 
+	// The contents of the file to upload, instead of a local file. It is
+	// a configuration template rendered at upload time.
+	Content string
+
+	// If true, the sources are configuration templates rendered at upload
+	// time.
+	Template bool
+
 	// The remote path where the local file will be uploaded to.
 	Destination string
 
@@ -38,7 +49,9 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		Interpolate:        true,
 		InterpolateContext: &p.config.ctx,
 		InterpolateFilter: &interpolate.RenderFilter{
-			Exclude: []string{},
+			Exclude: []string{
+				"content",
+			},
 		},
 	}, raws...)
 	if err != nil {
@@ -68,11 +81,33 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		}
 	}
 
-	if len(p.config.Sources) < 1 {
+	if p.config.Content != "" {
+		if len(p.config.Sources) > 0 {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("Only one of source, sources or content can be specified."))
+		}
+		if p.config.Direction != "upload" {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("Content can only be uploaded."))
+		}
+		if strings.HasSuffix(p.config.Destination, "/") {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("Destination must be a file when content is specified."))
+		}
+		if _, err := interpolate.Render(p.config.Content, &p.config.ctx); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Error parsing content: %s", err))
+		}
+	} else if len(p.config.Sources) < 1 {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("Source must be specified."))
 	}
 
+	if p.config.Template && p.config.Direction != "upload" {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("Only uploaded files can be templates."))
+	}
+
 	if p.config.Destination == "" {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("Destination must be specified."))
@@ -85,7 +120,7 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	if p.config.Direction == "download" {
 		return p.ProvisionDownload(ui, comm)
 	} else {
@@ -132,6 +167,10 @@ func (p *Provisioner) ProvisionDownload(ui packer.Ui, comm packer.Communicator)
 }
 
 func (p *Provisioner) ProvisionUpload(ui packer.Ui, comm packer.Communicator) error {
+	if p.config.Content != "" {
+		return p.uploadContent(ui, comm)
+	}
+
 	for _, src := range p.config.Sources {
 		dst := p.config.Destination
 
@@ -163,7 +202,21 @@ func (p *Provisioner) ProvisionUpload(ui packer.Ui, comm packer.Communicator) er
 			dst = filepath.Join(dst, filepath.Base(src))
 		}
 
-		err = comm.Upload(dst, f, &fi)
+		var r io.Reader = f
+		if p.config.Template {
+			contents, err := ioutil.ReadAll(f)
+			if err != nil {
+				return err
+			}
+			rendered, err := interpolate.Render(string(contents), &p.config.ctx)
+			if err != nil {
+				return fmt.Errorf("Error rendering template %s: %s", src, err)
+			}
+			r = strings.NewReader(rendered)
+			fi = &renderedFileInfo{FileInfo: fi, size: int64(len(rendered))}
+		}
+
+		err = comm.Upload(dst, r, &fi)
 		if err != nil {
 			ui.Error(fmt.Sprintf("Upload failed: %s", err))
 			return err
@@ -172,8 +225,30 @@ func (p *Provisioner) ProvisionUpload(ui packer.Ui, comm packer.Communicator) er
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
+// uploadContent renders content and uploads it to the destination.
+func (p *Provisioner) uploadContent(ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Uploading content => %s", p.config.Destination))
+
+	rendered, err := interpolate.Render(p.config.Content, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error rendering content: %s", err)
+	}
+
+	err = comm.Upload(p.config.Destination, strings.NewReader(rendered), nil)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Upload failed: %s", err))
+		return err
+	}
+	return nil
+}
+
+// renderedFileInfo is the FileInfo of a template file, with the size of
+// the rendered template.
+type renderedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi *renderedFileInfo) Size() int64 {
+	return fi.size
 }
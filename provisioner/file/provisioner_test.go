@@ -1,6 +1,7 @@
 package file
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -76,6 +77,46 @@ func TestProvisionerPrepare_EmptyDestination(t *testing.T) {
 	}
 }
 
+func TestProvisionerPrepare_Content(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Config map[string]interface{}
+		Err    bool
+	}{
+		{"content", map[string]interface{}{}, false},
+		{"source", map[string]interface{}{"source": "foo"}, true},
+		{"download", map[string]interface{}{"direction": "download"}, true},
+		{"directory", map[string]interface{}{"destination": "dir/"}, true},
+		{"bad template", map[string]interface{}{"content": "{{bad}}"}, true},
+	}
+
+	for _, tc := range cases {
+		var p Provisioner
+		config := testConfig()
+		config["content"] = "{{timestamp}}"
+		for k, v := range tc.Config {
+			config[k] = v
+		}
+
+		err := p.Prepare(config)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: bad error: %s", tc.Name, err)
+		}
+	}
+}
+
+func TestProvisionerPrepare_TemplateDownload(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["source"] = "foo"
+	config["direction"] = "download"
+	config["template"] = true
+
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should not allow downloading templates")
+	}
+}
+
 type stubUi struct {
 	sayMessages string
 }
@@ -120,7 +161,7 @@ func TestProvisionerProvision_SendsFile(t *testing.T) {
 
 	ui := &stubUi{}
 	comm := &packer.MockCommunicator{}
-	err = p.Provision(ui, comm)
+	err = p.Provision(context.Background(), ui, comm)
 	if err != nil {
 		t.Fatalf("should successfully provision: %s", err)
 	}
@@ -142,6 +183,65 @@ func TestProvisionerProvision_SendsFile(t *testing.T) {
 	}
 }
 
+func TestProvisionerProvision_Content(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["content"] = "foo={{user `foo`}}"
+	config[packer.UserVariablesConfigKey] = map[string]string{
+		"foo": "bar",
+	}
+
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packer.MockCommunicator{}
+	if err := p.Provision(context.Background(), &stubUi{}, comm); err != nil {
+		t.Fatalf("should successfully provision: %s", err)
+	}
+
+	if comm.UploadPath != "something" {
+		t.Fatalf("bad upload path: %s", comm.UploadPath)
+	}
+	if comm.UploadData != "foo=bar" {
+		t.Fatalf("bad upload data: %s", comm.UploadData)
+	}
+}
+
+func TestProvisionerProvision_Template(t *testing.T) {
+	var p Provisioner
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("error tempfile: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	if _, err = tf.Write([]byte("foo={{user `foo`}}")); err != nil {
+		t.Fatalf("error writing tempfile: %s", err)
+	}
+	tf.Close()
+
+	config := testConfig()
+	config["source"] = tf.Name()
+	config["template"] = true
+	config[packer.UserVariablesConfigKey] = map[string]string{
+		"foo": "bar",
+	}
+
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packer.MockCommunicator{}
+	if err := p.Provision(context.Background(), &stubUi{}, comm); err != nil {
+		t.Fatalf("should successfully provision: %s", err)
+	}
+
+	if comm.UploadData != "foo=bar" {
+		t.Fatalf("bad upload data: %s", comm.UploadData)
+	}
+}
+
 func TestProvisionDownloadMkdirAll(t *testing.T) {
 	tests := []struct {
 		path string
@@ -0,0 +1,422 @@
+// This package implements a provisioner for Packer that hardens the
+// remote machine with bundled hardening profiles.
+package hardening
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type guestOSTypeConfig struct {
+	executeCommand string
+	stagingDir     string
+	scriptName     string
+	script         func(*scriptConfig) string
+}
+
+var guestOSTypeConfigs = map[string]guestOSTypeConfig{
+	provisioner.UnixOSType: {
+		executeCommand: "{{if .Sudo}}sudo {{end}}sh '{{.Path}}'",
+		stagingDir:     "/tmp/packer-hardening-%s",
+		scriptName:     "hardening.sh",
+		script:         unixScript,
+	},
+	provisioner.WindowsOSType: {
+		executeCommand: `powershell -executionpolicy bypass -noprofile -file "{{.Path}}"`,
+		stagingDir:     "c:/Windows/Temp/packer-hardening-%s",
+		scriptName:     "hardening.ps1",
+		script:         windowsScript,
+	},
+}
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The bundled profiles to apply: baseline or strict.
+	Profiles []string `mapstructure:"profiles"`
+
+	// The IDs of rules that aren't applied.
+	SkipRules []string `mapstructure:"skip_rules"`
+
+	// If true, the rules are checked but the machine isn't changed.
+	CheckOnly bool `mapstructure:"check_only"`
+
+	// If true, the build fails when a rule isn't complied with after
+	// remediation.
+	FailOnNoncompliance bool `mapstructure:"fail_on_noncompliance"`
+
+	// Windows only: the local path of LGPO.exe and the local GPO backup
+	// directories it imports.
+	LGPOPath   string   `mapstructure:"lgpo_path"`
+	GPOBackups []string `mapstructure:"gpo_backups"`
+
+	// The local file the compliance report is written to.
+	ReportOutput string `mapstructure:"report_output"`
+
+	// The operating system of the remote machine, unix or windows.
+	GuestOSType string `mapstructure:"guest_os_type"`
+
+	// The command used to run the generated script.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// If true, the script isn't run with sudo on unix guests.
+	PreventSudo bool `mapstructure:"prevent_sudo"`
+
+	// The remote directory the script, its results and the GPO backups
+	// are uploaded to. It is removed afterwards.
+	StagingDir string `mapstructure:"staging_directory"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config            Config
+	guestOSTypeConfig guestOSTypeConfig
+	rules             []*rule
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+	Sudo bool
+}
+
+// Report is the content of the report_output file.
+type Report struct {
+	BuildName   string         `json:"build_name"`
+	BuilderType string         `json:"builder_type"`
+	GuestOSType string         `json:"guest_os_type"`
+	Profiles    []string       `json:"profiles"`
+	CheckOnly   bool           `json:"check_only"`
+	Rules       []*RuleResult  `json:"rules"`
+	Summary     map[string]int `json:"summary"`
+}
+
+// RuleResult is the status of a rule in the report.
+type RuleResult struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Profiles []string `json:"profiles,omitempty"`
+	Status   string   `json:"status"`
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.GuestOSType == "" {
+		p.config.GuestOSType = provisioner.DefaultOSType
+	}
+	p.config.GuestOSType = strings.ToLower(p.config.GuestOSType)
+
+	var ok bool
+	p.guestOSTypeConfig, ok = guestOSTypeConfigs[p.config.GuestOSType]
+	if !ok {
+		return fmt.Errorf("Invalid guest_os_type: \"%s\"", p.config.GuestOSType)
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = p.guestOSTypeConfig.executeCommand
+	}
+
+	if p.config.StagingDir == "" {
+		p.config.StagingDir = fmt.Sprintf(p.guestOSTypeConfig.stagingDir, uuid.TimeOrderedUUID())
+	}
+
+	if p.config.ReportOutput == "" {
+		p.config.ReportOutput = "hardening-report.json"
+		if p.config.PackerBuildName != "" {
+			p.config.ReportOutput = fmt.Sprintf("hardening-report-%s.json", p.config.PackerBuildName)
+		}
+	}
+
+	var errs *packer.MultiError
+	if len(p.config.Profiles) == 0 && len(p.config.GPOBackups) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("At least one of profiles or gpo_backups must be specified."))
+	}
+
+	for _, profile := range p.config.Profiles {
+		if !isProfile(profile) {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Unknown profile %q, must be one of %s", profile, strings.Join(allProfiles, ", ")))
+		}
+	}
+
+	if p.config.GuestOSType == provisioner.WindowsOSType {
+		if len(p.config.GPOBackups) > 0 && p.config.LGPOPath == "" {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("lgpo_path must be specified to import gpo_backups."))
+		}
+		if p.config.LGPOPath != "" {
+			if _, err := os.Stat(p.config.LGPOPath); err != nil {
+				errs = packer.MultiErrorAppend(errs,
+					fmt.Errorf("Bad lgpo_path '%s': %s", p.config.LGPOPath, err))
+			}
+		}
+		for _, backup := range p.config.GPOBackups {
+			if info, err := os.Stat(backup); err != nil {
+				errs = packer.MultiErrorAppend(errs,
+					fmt.Errorf("Bad gpo_backups path '%s': %s", backup, err))
+			} else if !info.IsDir() {
+				errs = packer.MultiErrorAppend(errs,
+					fmt.Errorf("gpo_backups path '%s' must be a directory", backup))
+			}
+		}
+	} else if p.config.LGPOPath != "" || len(p.config.GPOBackups) > 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("lgpo_path and gpo_backups can only be used on windows guests."))
+	}
+
+	// Rule IDs are checked, so that a typo doesn't apply a rule that
+	// breaks the image
+	known := make(map[string]bool)
+	for _, r := range rulesFor(p.config.GuestOSType) {
+		known[r.ID] = true
+	}
+	for _, backup := range p.config.GPOBackups {
+		known[gpoRuleID(backup)] = true
+	}
+	skipped := make(map[string]bool)
+	for _, id := range p.config.SkipRules {
+		if !known[id] {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Unknown rule %q in skip_rules", id))
+		}
+		skipped[id] = true
+	}
+
+	p.rules = nil
+	for _, r := range selectRules(p.config.GuestOSType, p.config.Profiles) {
+		if !skipped[r.ID] {
+			p.rules = append(p.rules, r)
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// gpoRuleID returns the rule ID of a GPO backup in the report and in
+// skip_rules.
+func gpoRuleID(backup string) string {
+	return "gpo:" + filepath.Base(filepath.Clean(backup))
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Provisioning with hardening: %s", strings.Join(p.config.Profiles, ", ")))
+
+	// The directory is created as the user, so that files can be uploaded
+	// to it, and removed with sudo, since the script writes to it
+	user := &provisioner.GuestCommands{GuestOSType: p.config.GuestOSType}
+	guest := &provisioner.GuestCommands{GuestOSType: p.config.GuestOSType, Sudo: !p.config.PreventSudo}
+
+	mkdir := &packer.RemoteCmd{Command: user.CreateDir(p.config.StagingDir)}
+	if err := mkdir.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if mkdir.ExitStatus != 0 {
+		return fmt.Errorf("Error creating %s: exit status %d", p.config.StagingDir, mkdir.ExitStatus)
+	}
+	defer func() {
+		cmd := &packer.RemoteCmd{Command: guest.RemoveDir(p.config.StagingDir)}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil || cmd.ExitStatus != 0 {
+			log.Printf("Error removing %s: %v, exit status %d", p.config.StagingDir, err, cmd.ExitStatus)
+		}
+	}()
+
+	sc, err := p.uploadGPOBackups(ui, comm)
+	if err != nil {
+		return err
+	}
+	sc.Rules = p.rules
+	sc.CheckOnly = p.config.CheckOnly
+	sc.ResultsPath = p.remotePath("results.tsv")
+
+	scriptPath := p.remotePath(p.guestOSTypeConfig.scriptName)
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: scriptPath,
+		Sudo: !p.config.PreventSudo,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	log.Printf("Uploading hardening script to %s", scriptPath)
+	script := p.guestOSTypeConfig.script(sc)
+	if err := comm.Upload(scriptPath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading script: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Hardening script exited with non-zero exit status: %d", cmd.ExitStatus)
+	}
+
+	var results bytes.Buffer
+	if err := comm.Download(sc.ResultsPath, &results); err != nil {
+		return fmt.Errorf("Error downloading results: %s", err)
+	}
+
+	report := p.report(parseResults(results.String()))
+	if err := p.writeReport(report); err != nil {
+		return err
+	}
+	ui.Message(fmt.Sprintf("%d rule(s) passed, %d failed, %d errored, %d skipped. Wrote the compliance report to %s",
+		report.Summary[StatusPass], report.Summary[StatusFail], report.Summary[StatusError],
+		report.Summary[StatusSkipped], p.config.ReportOutput))
+
+	if report.Summary[StatusError] > 0 {
+		return fmt.Errorf("%d rule(s) couldn't be applied, see the report in %s",
+			report.Summary[StatusError], p.config.ReportOutput)
+	}
+	if p.config.FailOnNoncompliance && report.Summary[StatusFail] > 0 {
+		return fmt.Errorf("%d rule(s) aren't complied with, see the report in %s",
+			report.Summary[StatusFail], p.config.ReportOutput)
+	}
+	return nil
+}
+
+func (p *Provisioner) remotePath(name string) string {
+	return p.config.StagingDir + "/" + name
+}
+
+// uploadGPOBackups uploads LGPO.exe and the GPO backups that aren't
+// skipped.
+func (p *Provisioner) uploadGPOBackups(ui packer.Ui, comm packer.Communicator) (*scriptConfig, error) {
+	sc := new(scriptConfig)
+	if p.config.LGPOPath == "" || p.config.CheckOnly {
+		return sc, nil
+	}
+
+	sc.LGPOPath = p.remotePath("LGPO.exe")
+	f, err := os.Open(p.config.LGPOPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening lgpo_path: %s", err)
+	}
+	defer f.Close()
+	if err := comm.Upload(sc.LGPOPath, f, nil); err != nil {
+		return nil, fmt.Errorf("Error uploading LGPO.exe: %s", err)
+	}
+
+	for i, backup := range p.config.GPOBackups {
+		id := gpoRuleID(backup)
+		if p.isSkipped(id) {
+			continue
+		}
+
+		dst := p.remotePath(fmt.Sprintf("gpo-%d", i))
+		ui.Message(fmt.Sprintf("Uploading GPO backup %s...", backup))
+		src := strings.TrimRight(filepath.ToSlash(backup), "/") + "/"
+		if err := comm.UploadDir(dst, src, nil); err != nil {
+			return nil, fmt.Errorf("Error uploading GPO backup %s: %s", backup, err)
+		}
+		sc.GPOBackups = append(sc.GPOBackups, [2]string{id, dst})
+	}
+	return sc, nil
+}
+
+func (p *Provisioner) isSkipped(id string) bool {
+	for _, skipped := range p.config.SkipRules {
+		if skipped == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parseResults parses the results file written by the script.
+func parseResults(results string) map[string]string {
+	statuses := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(results))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if len(fields) == 2 {
+			statuses[fields[0]] = fields[1]
+		}
+	}
+	return statuses
+}
+
+// report builds the compliance report of all the rules of the selected
+// profiles. Rules that the script didn't report are errors.
+func (p *Provisioner) report(statuses map[string]string) *Report {
+	report := &Report{
+		BuildName:   p.config.PackerBuildName,
+		BuilderType: p.config.PackerBuilderType,
+		GuestOSType: p.config.GuestOSType,
+		Profiles:    p.config.Profiles,
+		CheckOnly:   p.config.CheckOnly,
+		Summary:     make(map[string]int),
+	}
+
+	add := func(id, title string, profiles []string) {
+		status := StatusSkipped
+		if !p.isSkipped(id) {
+			var ok bool
+			if status, ok = statuses[id]; !ok {
+				status = StatusError
+			}
+		}
+		report.Rules = append(report.Rules, &RuleResult{
+			ID:       id,
+			Title:    title,
+			Profiles: profiles,
+			Status:   status,
+		})
+		report.Summary[status]++
+	}
+
+	if !p.config.CheckOnly {
+		for _, backup := range p.config.GPOBackups {
+			add(gpoRuleID(backup), fmt.Sprintf("The GPO backup %s is imported", filepath.Base(backup)), nil)
+		}
+	}
+	for _, r := range selectRules(p.config.GuestOSType, p.config.Profiles) {
+		add(r.ID, r.Title, r.Profiles)
+	}
+	return report
+}
+
+func (p *Provisioner) writeReport(report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(p.config.ReportOutput, data, 0644); err != nil {
+		return fmt.Errorf("Error writing the compliance report: %s", err)
+	}
+	return nil
+}
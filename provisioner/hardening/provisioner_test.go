@@ -0,0 +1,294 @@
+package hardening
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"profiles": []string{"baseline"},
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.HasPrefix(p.config.StagingDir, "/tmp/packer-hardening-") {
+		t.Fatalf("unexpected staging_directory: %s", p.config.StagingDir)
+	}
+	if p.config.ReportOutput != "hardening-report.json" {
+		t.Fatalf("unexpected report_output: %s", p.config.ReportOutput)
+	}
+}
+
+func TestProvisionerPrepare_Errors(t *testing.T) {
+	cases := []map[string]interface{}{
+		{},
+		{"profiles": []string{"cis-level1"}},
+		{"profiles": []string{"baseline"}, "guest_os_type": "beos"},
+		{"profiles": []string{"baseline"}, "skip_rules": []string{"no-such-rule"}},
+		{"profiles": []string{"baseline"}, "skip_rules": []string{"no-lm-hash"}},
+		{"profiles": []string{"baseline"}, "lgpo_path": "LGPO.exe"},
+		{"guest_os_type": "windows", "gpo_backups": []string{os.TempDir()}},
+		{"guest_os_type": "windows", "gpo_backups": []string{"/no/such/backup"}, "lgpo_path": "provisioner.go"},
+	}
+
+	for _, config := range cases {
+		var p Provisioner
+		if err := p.Prepare(config); err == nil {
+			t.Fatalf("%v: should have error", config)
+		}
+	}
+}
+
+func TestProvisionerPrepare_Rules(t *testing.T) {
+	cases := []struct {
+		Config   map[string]interface{}
+		Included []string
+		Excluded []string
+	}{
+		{
+			map[string]interface{}{"profiles": []string{"baseline"}},
+			[]string{"ssh-permit-root-login", "password-max-days"},
+			[]string{"module-udf", "auditd-enabled", "password-max-days-60"},
+		},
+		{
+			map[string]interface{}{"profiles": []string{"strict"}},
+			[]string{"ssh-permit-root-login", "module-udf", "auditd-enabled", "password-max-days-60"},
+			[]string{},
+		},
+		{
+			map[string]interface{}{"profiles": []string{"strict"}, "skip_rules": []string{"sysctl-ip-forward"}},
+			[]string{"password-max-days-60", "module-usb-storage"},
+			[]string{"sysctl-ip-forward"},
+		},
+		{
+			map[string]interface{}{"profiles": []string{"baseline"}, "guest_os_type": "windows"},
+			[]string{"no-lm-hash", "guest-account-disabled"},
+			[]string{"ssh-permit-root-login", "rdp-drive-redirection-disabled"},
+		},
+	}
+
+	for _, tc := range cases {
+		var p Provisioner
+		if err := p.Prepare(tc.Config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		ids := make(map[string]bool)
+		for _, r := range p.rules {
+			ids[r.ID] = true
+		}
+		for _, id := range tc.Included {
+			if !ids[id] {
+				t.Fatalf("%v: %s should be included", tc.Config, id)
+			}
+		}
+		for _, id := range tc.Excluded {
+			if ids[id] {
+				t.Fatalf("%v: %s should be excluded", tc.Config, id)
+			}
+		}
+	}
+}
+
+func TestProvisionerProvision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		Name     string
+		Config   map[string]interface{}
+		Statuses map[string]string
+		Err      bool
+	}{
+		{
+			"compliant",
+			map[string]interface{}{},
+			map[string]string{"sysctl-ip-forward": StatusFail},
+			false,
+		},
+		{
+			"noncompliant",
+			map[string]interface{}{"fail_on_noncompliance": true},
+			map[string]string{"sysctl-ip-forward": StatusFail},
+			true,
+		},
+		{
+			"error",
+			map[string]interface{}{},
+			map[string]string{"ssh-permit-root-login": StatusError},
+			true,
+		},
+		{
+			"missing",
+			map[string]interface{}{},
+			map[string]string{"ssh-permit-root-login": ""},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		report := filepath.Join(dir, tc.Name+".json")
+		config := testConfig()
+		config["staging_directory"] = "/tmp/staging"
+		config["report_output"] = report
+		for k, v := range tc.Config {
+			config[k] = v
+		}
+
+		var p Provisioner
+		if err := p.Prepare(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		results := ""
+		for _, r := range p.rules {
+			status, ok := tc.Statuses[r.ID]
+			if !ok {
+				status = StatusPass
+			}
+			if status != "" {
+				results += r.ID + "\t" + status + "\n"
+			}
+		}
+
+		comm := &packer.MockCommunicator{DownloadData: results}
+		err := p.Provision(context.Background(), testUi(), comm)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: bad error: %v", tc.Name, err)
+		}
+
+		if comm.UploadPath != "/tmp/staging/hardening.sh" {
+			t.Fatalf("%s: bad upload path: %s", tc.Name, comm.UploadPath)
+		}
+		if comm.DownloadPath != "/tmp/staging/results.tsv" {
+			t.Fatalf("%s: bad download path: %s", tc.Name, comm.DownloadPath)
+		}
+		if comm.StartCmd.Command != "sudo rm -rf '/tmp/staging'" {
+			t.Fatalf("%s: bad last command: %s", tc.Name, comm.StartCmd.Command)
+		}
+
+		data, err := ioutil.ReadFile(report)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		if len(r.Rules) != len(p.rules) {
+			t.Fatalf("%s: bad rules: %#v", tc.Name, r.Rules)
+		}
+		for _, result := range r.Rules {
+			expected, ok := tc.Statuses[result.ID]
+			if !ok {
+				expected = StatusPass
+			} else if expected == "" {
+				expected = StatusError
+			}
+			if result.Status != expected {
+				t.Fatalf("%s: bad status of %s: %s", tc.Name, result.ID, result.Status)
+			}
+		}
+	}
+}
+
+func TestUnixScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	marker := filepath.Join(dir, "it's remediated")
+	results := filepath.Join(dir, "results.tsv")
+	rules := []*rule{
+		{ID: "remediated", Check: "[ -f " + provisioner.ShellQuote(marker) + " ]", Remediate: "touch " + provisioner.ShellQuote(marker)},
+		{ID: "check-only", Check: "false"},
+		{ID: "broken", Check: "false", Remediate: "exit 3"},
+	}
+
+	script := filepath.Join(dir, "hardening.sh")
+	err = ioutil.WriteFile(script, []byte(unixScript(&scriptConfig{Rules: rules, ResultsPath: results})), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out, err := exec.Command("sh", script).CombinedOutput(); err != nil {
+		t.Fatalf("err: %s\n%s", err, out)
+	}
+
+	data, err := ioutil.ReadFile(results)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]string{
+		"remediated": StatusPass,
+		"check-only": StatusFail,
+		"broken":     StatusError,
+	}
+	actual := parseResults(string(data))
+	for id, status := range expected {
+		if actual[id] != status {
+			t.Fatalf("%s: got %q, expected %q\n%s", id, actual[id], status, data)
+		}
+	}
+}
+
+func TestWindowsScript(t *testing.T) {
+	script := windowsScript(&scriptConfig{
+		Rules:       windowsRules[:1],
+		ResultsPath: "c:/staging/results.tsv",
+		LGPOPath:    "c:/staging/LGPO.exe",
+		GPOBackups:  [][2]string{{"gpo:baseline", "c:/staging/gpo-0"}},
+	})
+
+	for _, expected := range []string{
+		"$results = 'c:/staging/results.tsv'",
+		"Import-PackerGPO 'gpo:baseline' 'c:/staging/LGPO.exe' 'c:/staging/gpo-0'",
+		"Invoke-PackerRule 'smb1-server-disabled' { (Get-ItemProperty",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Fatalf("script should contain %q:\n%s", expected, script)
+		}
+	}
+	if strings.Index(script, "Import-PackerGPO 'gpo") > strings.Index(script, "Invoke-PackerRule 'smb1") {
+		t.Fatalf("policies should be imported before the rules are checked:\n%s", script)
+	}
+}
@@ -0,0 +1,223 @@
+package hardening
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/provisioner"
+)
+
+// The bundled profiles. The strict profile includes the baseline one.
+// They are a selection of settings, not benchmarks: they don't claim
+// compliance with the CIS benchmarks or the DISA STIGs.
+const (
+	ProfileBaseline = "baseline"
+	ProfileStrict   = "strict"
+)
+
+// The profiles of the rules.
+var (
+	allProfiles    = []string{ProfileBaseline, ProfileStrict}
+	strictProfiles = []string{ProfileStrict}
+)
+
+// rule is a bundled hardening rule. Check is run after Remediate, and
+// before it to skip compliant rules. Both are shell commands on unix and
+// PowerShell script blocks on Windows, Check succeeding or returning
+// $true when the machine complies.
+type rule struct {
+	ID       string
+	Title    string
+	Profiles []string
+
+	Check     string
+	Remediate string
+}
+
+func (r *rule) inProfile(profile string) bool {
+	for _, p := range r.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// sshdRule sets an sshd option. sshd uses the first value of an option,
+// so it is written at the top of sshd_config, before any Match block. The
+// running sshd isn't restarted, so that the connection of the
+// communicator isn't dropped.
+func sshdRule(id, title string, profiles []string, keyword, value string) *rule {
+	const config = "/etc/ssh/sshd_config"
+	return &rule{
+		ID:       id,
+		Title:    title,
+		Profiles: profiles,
+		Check: fmt.Sprintf(`[ ! -f %s ] || [ "$(awk 'tolower($1) == "%s" { print tolower($2); exit }' %s)" = "%s" ]`,
+			config, strings.ToLower(keyword), config, strings.ToLower(value)),
+		Remediate: fmt.Sprintf(`{ echo '%s %s'; grep -Eiv '^[[:space:]]*%s[[:space:]]' %s; } > %s.packer && cat %s.packer > %s && rm -f %s.packer`,
+			keyword, value, keyword, config, config, config, config, config),
+	}
+}
+
+// sysctlRule sets a kernel parameter now and on boot.
+func sysctlRule(id, title string, profiles []string, key, value string) *rule {
+	return &rule{
+		ID:       id,
+		Title:    title,
+		Profiles: profiles,
+		Check:    fmt.Sprintf(`[ "$(sysctl -n %s 2>/dev/null)" = "%s" ]`, key, value),
+		Remediate: fmt.Sprintf(`sysctl -w %s=%s >/dev/null && echo '%s = %s' >> /etc/sysctl.d/60-packer-hardening.conf`,
+			key, value, key, value),
+	}
+}
+
+// modprobeRule prevents a kernel module from being loaded.
+func modprobeRule(id, title string, profiles []string, module string) *rule {
+	return &rule{
+		ID:       id,
+		Title:    title,
+		Profiles: profiles,
+		Check: fmt.Sprintf(`grep -Eqs '^[[:space:]]*install[[:space:]]+%s[[:space:]]+/bin/(true|false)' /etc/modprobe.d/*.conf`,
+			module),
+		Remediate: fmt.Sprintf(`echo 'install %s /bin/true' >> /etc/modprobe.d/packer-hardening.conf`, module),
+	}
+}
+
+// passMaxDaysRule limits the age of the passwords of new users.
+func passMaxDaysRule(id, title string, profiles []string, days int) *rule {
+	const config = "/etc/login.defs"
+	return &rule{
+		ID:       id,
+		Title:    title,
+		Profiles: profiles,
+		Check: fmt.Sprintf(`d=$(awk '$1 == "PASS_MAX_DAYS" { print $2 }' %s | tail -n 1); [ -n "$d" ] && [ "$d" -le %d ]`,
+			config, days),
+		Remediate: fmt.Sprintf(`{ grep -v '^[[:space:]]*PASS_MAX_DAYS[[:space:]]' %s; echo 'PASS_MAX_DAYS %d'; } > %s.packer && cat %s.packer > %s && rm -f %s.packer`,
+			config, days, config, config, config, config),
+	}
+}
+
+// registryRule sets a DWORD value in the registry.
+func registryRule(id, title string, profiles []string, key, name string, value int) *rule {
+	return &rule{
+		ID:       id,
+		Title:    title,
+		Profiles: profiles,
+		Check: fmt.Sprintf(`(Get-ItemProperty -LiteralPath %s -ErrorAction SilentlyContinue).%s -eq %d`,
+			provisioner.PowerShellQuote(key), provisioner.PowerShellQuote(name), value),
+		Remediate: fmt.Sprintf(`if (-not (Test-Path -LiteralPath %s)) { New-Item -Path %s -Force | Out-Null }; Set-ItemProperty -LiteralPath %s -Name %s -Value %d -Type DWord`,
+			provisioner.PowerShellQuote(key), provisioner.PowerShellQuote(key), provisioner.PowerShellQuote(key), provisioner.PowerShellQuote(name), value),
+	}
+}
+
+var unixRules = []*rule{
+	sshdRule("ssh-permit-root-login", "SSH root login is disabled",
+		allProfiles, "PermitRootLogin", "no"),
+	sshdRule("ssh-permit-empty-passwords", "SSH doesn't accept empty passwords",
+		allProfiles, "PermitEmptyPasswords", "no"),
+	sshdRule("ssh-x11-forwarding", "SSH X11 forwarding is disabled",
+		allProfiles, "X11Forwarding", "no"),
+	sshdRule("ssh-max-auth-tries", "SSH allows at most 4 authentication attempts",
+		allProfiles, "MaxAuthTries", "4"),
+	sysctlRule("sysctl-ip-forward", "IP forwarding is disabled",
+		allProfiles, "net.ipv4.ip_forward", "0"),
+	sysctlRule("sysctl-accept-redirects", "ICMP redirects aren't accepted",
+		allProfiles, "net.ipv4.conf.all.accept_redirects", "0"),
+	sysctlRule("sysctl-randomize-va-space", "Address space layout randomization is enabled",
+		allProfiles, "kernel.randomize_va_space", "2"),
+	sysctlRule("sysctl-suid-dumpable", "Setuid programs don't dump core",
+		allProfiles, "fs.suid_dumpable", "0"),
+	{
+		ID:        "core-dumps-restricted",
+		Title:     "Core dumps are restricted",
+		Profiles:  allProfiles,
+		Check:     `grep -Eqs '^[[:space:]]*\*[[:space:]]+hard[[:space:]]+core[[:space:]]+0' /etc/security/limits.conf /etc/security/limits.d/*.conf`,
+		Remediate: `mkdir -p /etc/security/limits.d && echo '* hard core 0' >> /etc/security/limits.d/60-packer-hardening.conf`,
+	},
+	{
+		ID:        "shadow-permissions",
+		Title:     "The shadow files aren't accessible by other users",
+		Profiles:  allProfiles,
+		Check:     `[ -z "$(find /etc/shadow /etc/gshadow -prune \( -perm -o=r -o -perm -o=w -o -perm -o=x \) 2>/dev/null)" ]`,
+		Remediate: `chmod o-rwx /etc/shadow && { [ ! -f /etc/gshadow ] || chmod o-rwx /etc/gshadow; }`,
+	},
+	modprobeRule("module-cramfs", "Mounting cramfs filesystems is disabled",
+		allProfiles, "cramfs"),
+	modprobeRule("module-udf", "Mounting udf filesystems is disabled",
+		strictProfiles, "udf"),
+	modprobeRule("module-usb-storage", "USB storage is disabled",
+		strictProfiles, "usb-storage"),
+	passMaxDaysRule("password-max-days", "Passwords expire within 365 days",
+		allProfiles, 365),
+	passMaxDaysRule("password-max-days-60", "Passwords expire within 60 days",
+		strictProfiles, 60),
+	{
+		ID:       "auditd-enabled",
+		Title:    "The audit daemon is enabled, it must be installed beforehand",
+		Profiles: strictProfiles,
+		Check:    `systemctl is-enabled auditd >/dev/null 2>&1`,
+	},
+}
+
+var windowsRules = []*rule{
+	registryRule("smb1-server-disabled", "The SMBv1 server is disabled",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Services\LanmanServer\Parameters`, "SMB1", 0),
+	registryRule("lm-compatibility-level", "Only NTLMv2 is sent, LM and NTLM are refused",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Control\Lsa`, "LmCompatibilityLevel", 5),
+	registryRule("no-lm-hash", "LAN Manager hashes aren't stored",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Control\Lsa`, "NoLMHash", 1),
+	registryRule("restrict-anonymous", "Anonymous enumeration of shares is restricted",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Control\Lsa`, "RestrictAnonymous", 1),
+	registryRule("restrict-anonymous-sam", "Anonymous enumeration of accounts is restricted",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Control\Lsa`, "RestrictAnonymousSAM", 1),
+	registryRule("autorun-disabled", "AutoRun is disabled on all drives",
+		allProfiles, `HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\Explorer`, "NoDriveTypeAutoRun", 255),
+	registryRule("wdigest-disabled", "WDigest doesn't store credentials in clear text",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Control\SecurityProviders\WDigest`, "UseLogonCredential", 0),
+	registryRule("rdp-nla-required", "Remote Desktop requires Network Level Authentication",
+		allProfiles, `HKLM:\SYSTEM\CurrentControlSet\Control\Terminal Server\WinStations\RDP-Tcp`, "UserAuthentication", 1),
+	registryRule("llmnr-disabled", "Multicast name resolution is disabled",
+		allProfiles, `HKLM:\SOFTWARE\Policies\Microsoft\Windows NT\DNSClient`, "EnableMulticast", 0),
+	registryRule("rdp-drive-redirection-disabled", "Remote Desktop doesn't redirect drives",
+		strictProfiles, `HKLM:\SOFTWARE\Policies\Microsoft\Windows NT\Terminal Services`, "fDisableCdm", 1),
+	{
+		ID:        "guest-account-disabled",
+		Title:     "The Guest account is disabled",
+		Profiles:  allProfiles,
+		Check:     `-not (Get-CimInstance Win32_UserAccount -Filter "LocalAccount = True AND SID LIKE '%-501'" | Where-Object { -not $_.Disabled })`,
+		Remediate: `Get-CimInstance Win32_UserAccount -Filter "LocalAccount = True AND SID LIKE '%-501'" | Set-CimInstance -Property @{ Disabled = $true }`,
+	},
+}
+
+// rulesFor returns the bundled rules of the guest OS type.
+func rulesFor(osType string) []*rule {
+	if osType == provisioner.WindowsOSType {
+		return windowsRules
+	}
+	return unixRules
+}
+
+// selectRules returns the rules that are in one of the profiles, in
+// their bundled order.
+func selectRules(osType string, selected []string) []*rule {
+	var rules []*rule
+	for _, r := range rulesFor(osType) {
+		for _, profile := range selected {
+			if r.inProfile(profile) {
+				rules = append(rules, r)
+				break
+			}
+		}
+	}
+	return rules
+}
+
+func isProfile(name string) bool {
+	for _, p := range allProfiles {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package hardening
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/packer/provisioner"
+)
+
+// The statuses of rules in the results file written by the scripts, and
+// in the report.
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusError   = "error"
+	StatusApplied = "applied"
+	StatusSkipped = "skipped"
+)
+
+// scriptConfig is what the generated scripts do.
+type scriptConfig struct {
+	Rules     []*rule
+	CheckOnly bool
+
+	// The remote file the results are written to, as tab separated rule
+	// IDs and statuses.
+	ResultsPath string
+
+	// Windows only: the remote path of LGPO.exe and the remote GPO
+	// backup directories it imports, by rule ID.
+	LGPOPath   string
+	GPOBackups [][2]string
+}
+
+const unixHeader = `results=%s
+check_only=%d
+: > "$results"
+
+packer_rule() {
+    if [ -n "$3" ] && [ "$check_only" != 1 ] && ! sh -c "$2" >/dev/null 2>&1; then
+        echo "Remediating $1"
+        if ! sh -c "$3"; then
+            echo "$1: error"
+            printf '%%s\t%%s\n' "$1" error >> "$results"
+            return
+        fi
+    fi
+    if sh -c "$2" >/dev/null 2>&1; then
+        status=pass
+    else
+        status=fail
+    fi
+    echo "$1: $status"
+    printf '%%s\t%%s\n' "$1" "$status" >> "$results"
+}
+
+`
+
+func unixScript(c *scriptConfig) string {
+	var buf bytes.Buffer
+	checkOnly := 0
+	if c.CheckOnly {
+		checkOnly = 1
+	}
+	fmt.Fprintf(&buf, unixHeader, provisioner.ShellQuote(c.ResultsPath), checkOnly)
+
+	for _, r := range c.Rules {
+		fmt.Fprintf(&buf, "packer_rule %s %s %s\n",
+			provisioner.ShellQuote(r.ID), provisioner.ShellQuote(r.Check), provisioner.ShellQuote(r.Remediate))
+	}
+	return buf.String()
+}
+
+const windowsHeader = `$ErrorActionPreference = 'Stop'
+$results = %s
+$checkOnly = %s
+Set-Content -LiteralPath $results -Value $null
+
+function Write-PackerResult($id, $status) {
+    Write-Host "${id}: $status"
+    Add-Content -LiteralPath $results -Value "$id` + "`t" + `$status"
+}
+
+function Invoke-PackerRule($id, [scriptblock]$check, [scriptblock]$remediate) {
+    try {
+        if ($remediate -and -not $checkOnly -and -not (& $check)) {
+            Write-Host "Remediating $id"
+            & $remediate | Out-Null
+        }
+        if (& $check) {
+            Write-PackerResult $id 'pass'
+        } else {
+            Write-PackerResult $id 'fail'
+        }
+    } catch {
+        Write-Host "Error in ${id}: $_"
+        Write-PackerResult $id 'error'
+    }
+}
+
+function Import-PackerGPO($id, $lgpo, $backup) {
+    if ($checkOnly) {
+        return
+    }
+    Write-Host "Importing $backup"
+    & $lgpo /g $backup | Out-Host
+    if ($LASTEXITCODE -eq 0) {
+        Write-PackerResult $id 'applied'
+    } else {
+        Write-Host "LGPO exited with $LASTEXITCODE"
+        Write-PackerResult $id 'error'
+    }
+}
+
+`
+
+func windowsScript(c *scriptConfig) string {
+	var buf bytes.Buffer
+	checkOnly := "$false"
+	if c.CheckOnly {
+		checkOnly = "$true"
+	}
+	fmt.Fprintf(&buf, windowsHeader, provisioner.PowerShellQuote(c.ResultsPath), checkOnly)
+
+	// Policies are imported first, so that the rules check them
+	for _, gpo := range c.GPOBackups {
+		fmt.Fprintf(&buf, "Import-PackerGPO %s %s %s\n",
+			provisioner.PowerShellQuote(gpo[0]), provisioner.PowerShellQuote(c.LGPOPath), provisioner.PowerShellQuote(gpo[1]))
+	}
+
+	for _, r := range c.Rules {
+		remediate := "$null"
+		if r.Remediate != "" {
+			remediate = "{ " + r.Remediate + " }"
+		}
+		fmt.Fprintf(&buf, "Invoke-PackerRule %s { %s } %s\n", provisioner.PowerShellQuote(r.ID), r.Check, remediate)
+	}
+	return buf.String()
+}
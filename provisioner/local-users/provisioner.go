@@ -0,0 +1,268 @@
+// This package implements a provisioner for Packer that declaratively
+// manages local users and groups on the remote machine.
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type guestOSTypeConfig struct {
+	executeCommand string
+	remotePath     string
+	script         func([]*Group, []*User) string
+}
+
+var guestOSTypeConfigs = map[string]guestOSTypeConfig{
+	provisioner.UnixOSType: {
+		executeCommand: "{{if .Sudo}}sudo {{end}}sh '{{.Path}}'",
+		remotePath:     "/tmp/packer-users-%s.sh",
+		script:         unixScript,
+	},
+	provisioner.WindowsOSType: {
+		executeCommand: `powershell -executionpolicy bypass -noprofile -file "{{.Path}}"`,
+		remotePath:     "c:/Windows/Temp/packer-users-%s.ps1",
+		script:         windowsScript,
+	},
+}
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The local groups to create or remove.
+	Groups []*Group `mapstructure:"groups"`
+
+	// The local users to create or remove.
+	Users []*User `mapstructure:"users"`
+
+	// The operating system of the remote machine, unix or windows.
+	GuestOSType string `mapstructure:"guest_os_type"`
+
+	// The length of generated passwords.
+	PasswordLength int `mapstructure:"password_length"`
+
+	// The local file generated passwords are written to. Required when
+	// a password is generated.
+	CredentialsOutput string `mapstructure:"credentials_output"`
+
+	// The command used to run the generated script.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// If true, the script isn't run with sudo on unix guests.
+	PreventSudo bool `mapstructure:"prevent_sudo"`
+
+	// The remote path the script is uploaded to. The script removes
+	// itself when it runs since it contains passwords.
+	RemotePath string `mapstructure:"remote_path"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config            Config
+	guestOSTypeConfig guestOSTypeConfig
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+	Sudo bool
+}
+
+// Credentials is the content of the credentials_output file.
+type Credentials struct {
+	BuildName   string            `json:"build_name"`
+	BuilderType string            `json:"builder_type"`
+	Users       map[string]string `json:"users"`
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.GuestOSType == "" {
+		p.config.GuestOSType = provisioner.DefaultOSType
+	}
+	p.config.GuestOSType = strings.ToLower(p.config.GuestOSType)
+
+	var ok bool
+	p.guestOSTypeConfig, ok = guestOSTypeConfigs[p.config.GuestOSType]
+	if !ok {
+		return fmt.Errorf("Invalid guest_os_type: \"%s\"", p.config.GuestOSType)
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = p.guestOSTypeConfig.executeCommand
+	}
+
+	if p.config.RemotePath == "" {
+		p.config.RemotePath = fmt.Sprintf(p.guestOSTypeConfig.remotePath, uuid.TimeOrderedUUID())
+	}
+
+	if p.config.PasswordLength == 0 {
+		p.config.PasswordLength = 20
+	}
+
+	var errs *packer.MultiError
+	if len(p.config.Groups) == 0 && len(p.config.Users) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("At least one of users or groups must be specified."))
+	}
+
+	if p.config.PasswordLength < 8 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("password_length must be at least 8."))
+	}
+
+	for i, g := range p.config.Groups {
+		if err := g.prepare(); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("groups[%d]: %s", i, err))
+		}
+	}
+
+	generated := false
+	for i, u := range p.config.Users {
+		if err := u.prepare(); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("users[%d]: %s", i, err))
+		}
+		generated = generated || u.GeneratePassword
+	}
+
+	if generated && p.config.CredentialsOutput == "" {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("credentials_output must be specified to generate passwords."))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Provisioning with local-users: %d user(s), %d group(s)",
+		len(p.config.Users), len(p.config.Groups)))
+
+	// Passwords are generated for every build, never reused
+	for _, u := range p.config.Users {
+		if u.GeneratePassword && u.present {
+			password, err := generatePassword(p.config.PasswordLength)
+			if err != nil {
+				return fmt.Errorf("Error generating password for %s: %s", u.Name, err)
+			}
+			u.Password = password
+		}
+	}
+
+	script := p.guestOSTypeConfig.script(p.config.Groups, p.config.Users)
+
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: p.config.RemotePath,
+		Sudo: !p.config.PreventSudo,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	log.Printf("Uploading users script to %s", p.config.RemotePath)
+	if err := comm.Upload(p.config.RemotePath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading script: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Managing users failed with exit status: %d", cmd.ExitStatus)
+	}
+
+	return p.writeCredentials(ui)
+}
+
+// writeCredentials records the generated passwords in credentials_output,
+// merging them with the passwords other provisioners of the same build
+// have written.
+func (p *Provisioner) writeCredentials(ui packer.Ui) error {
+	users := make(map[string]string)
+	for _, u := range p.config.Users {
+		if u.GeneratePassword && u.present {
+			users[u.Name] = u.Password
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	path := p.config.CredentialsOutput
+	creds := &Credentials{
+		BuildName:   p.config.PackerBuildName,
+		BuilderType: p.config.PackerBuilderType,
+		Users:       make(map[string]string),
+	}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		var existing Credentials
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return fmt.Errorf("Error reading %s: %s", path, err)
+		}
+		if existing.BuildName == creds.BuildName && existing.Users != nil {
+			creds.Users = existing.Users
+		}
+	}
+	for name, password := range users {
+		creds.Users[name] = password
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".packer-credentials")
+	if err != nil {
+		return fmt.Errorf("Error writing credentials: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Error writing credentials: %s", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Error writing credentials: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Error writing credentials: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Error writing credentials: %s", err)
+	}
+
+	ui.Message(fmt.Sprintf("Wrote generated passwords to %s", path))
+	return nil
+}
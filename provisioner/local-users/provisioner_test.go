@@ -0,0 +1,191 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"groups": []map[string]interface{}{
+			{"name": "deploy"},
+		},
+		"users": []map[string]interface{}{
+			{"name": "packer", "password": "it's secret", "groups": []string{"deploy"}, "admin": true},
+		},
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.PasswordLength != 20 {
+		t.Fatalf("unexpected password_length: %d", p.config.PasswordLength)
+	}
+	if !strings.HasPrefix(p.config.RemotePath, "/tmp/packer-users-") {
+		t.Fatalf("unexpected remote_path: %s", p.config.RemotePath)
+	}
+}
+
+func TestProvisionerPrepare_Errors(t *testing.T) {
+	cases := []map[string]interface{}{
+		{},
+		{"guest_os_type": "beos", "users": []map[string]interface{}{{"name": "a"}}},
+		{"users": []map[string]interface{}{{"name": ""}}},
+		{"users": []map[string]interface{}{{"name": "a", "state": "gone"}}},
+		{"groups": []map[string]interface{}{{"name": "a", "state": "gone"}}},
+		{"users": []map[string]interface{}{{"name": "a", "password": "x", "generate_password": true}}},
+		{"users": []map[string]interface{}{{"name": "a", "password": "x\ny"}}},
+		{"users": []map[string]interface{}{{"name": "a", "generate_password": true}}},
+		{"users": []map[string]interface{}{{"name": "a"}}, "password_length": 4},
+	}
+
+	for _, config := range cases {
+		var p Provisioner
+		if err := p.Prepare(config); err == nil {
+			t.Fatalf("%v: should have error", config)
+		}
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password, err := generatePassword(8)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if len(password) != 8 ||
+			!strings.ContainsAny(password, "abcdefghijklmnopqrstuvwxyz") ||
+			!strings.ContainsAny(password, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") ||
+			!strings.ContainsAny(password, "0123456789") {
+			t.Fatalf("bad password: %s", password)
+		}
+	}
+}
+
+func TestProvisionerProvision_Unix(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["remote_path"] = "/tmp/users.sh"
+	config["groups"] = []map[string]interface{}{
+		{"name": "deploy"},
+		{"name": "legacy", "state": "absent"},
+	}
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), testUi(), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if comm.StartCmd.Command != "sudo sh '/tmp/users.sh'" {
+		t.Fatalf("unexpected command: %s", comm.StartCmd.Command)
+	}
+
+	expected := []string{
+		"packer_group 'deploy' present\n",
+		"packer_user 'packer' '' ''\n",
+		`printf '%s\n' 'packer:it'\''s secret' | chpasswd` + "\n",
+		"usermod -a -G 'deploy' 'packer'\n",
+		"packer_sudo 'packer' ''\n",
+		"packer_group 'legacy' absent\n",
+	}
+	last := -1
+	for _, line := range expected {
+		i := strings.Index(comm.UploadData, line)
+		if i <= last {
+			t.Fatalf("script is missing %q in order:\n%s", line, comm.UploadData)
+		}
+		last = i
+	}
+}
+
+func TestProvisionerProvision_Windows(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["guest_os_type"] = "windows"
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), testUi(), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "Set-PackerUser 'packer' 'it''s secret' '' @($administrators,'deploy')\n"
+	if !strings.Contains(comm.UploadData, expected) {
+		t.Fatalf("script is missing %q:\n%s", expected, comm.UploadData)
+	}
+}
+
+func TestProvisionerProvision_GeneratePassword(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "credentials.json")
+
+	for _, name := range []string{"first", "second"} {
+		var p Provisioner
+		err := p.Prepare(map[string]interface{}{
+			"packer_build_name":  "vm",
+			"credentials_output": path,
+			"users": []map[string]interface{}{
+				{"name": name, "generate_password": true},
+			},
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		comm := new(packer.MockCommunicator)
+		if err := p.Provision(context.Background(), testUi(), comm); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.Contains(comm.UploadData, p.config.Users[0].Password) {
+			t.Fatalf("script doesn't set the generated password:\n%s", comm.UploadData)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.BuildName != "vm" || len(creds.Users) != 2 || len(creds.Users["first"]) != 20 {
+		t.Fatalf("bad credentials: %s", data)
+	}
+}
@@ -0,0 +1,308 @@
+package users
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// Group is a local group that is created or removed.
+type Group struct {
+	// The name of the group.
+	Name string
+
+	// Either "present" (default) or "absent".
+	State string
+
+	present bool
+}
+
+// User is a local user that is created or removed.
+type User struct {
+	// The name of the user.
+	Name string
+
+	// Either "present" (default) or "absent". Removing a user on unix
+	// removes its home directory too.
+	State string
+
+	// The password to set. The password of an existing user is reset.
+	Password string
+
+	// If true, a random password is generated for every build and
+	// written to credentials_output.
+	GeneratePassword bool `mapstructure:"generate_password"`
+
+	// The full name of the user, stored as the GECOS comment on unix.
+	FullName string `mapstructure:"full_name"`
+
+	// Groups the user is added to. Existing memberships are kept.
+	Groups []string
+
+	// If true, the user is added to Administrators on Windows or given
+	// sudo rights on unix.
+	Admin bool
+
+	// If true, admins on unix can use sudo without a password.
+	PasswordlessSudo bool `mapstructure:"passwordless_sudo"`
+
+	// The login shell of new users on unix.
+	Shell string
+
+	present bool
+}
+
+func parseState(state string) (bool, error) {
+	switch state {
+	case "", "present":
+		return true, nil
+	case "absent":
+		return false, nil
+	default:
+		return false, fmt.Errorf("state must be present or absent, got %q", state)
+	}
+}
+
+func (g *Group) prepare() error {
+	if g.Name == "" {
+		return errors.New("name must be specified")
+	}
+
+	var err error
+	g.present, err = parseState(g.State)
+	return err
+}
+
+func (u *User) prepare() error {
+	if u.Name == "" {
+		return errors.New("name must be specified")
+	}
+
+	var err error
+	if u.present, err = parseState(u.State); err != nil {
+		return err
+	}
+
+	if u.Password != "" && u.GeneratePassword {
+		return fmt.Errorf("%s: only one of password or generate_password can be specified", u.Name)
+	}
+	if strings.ContainsAny(u.Password, "\r\n") {
+		return fmt.Errorf("%s: password must not contain line breaks", u.Name)
+	}
+
+	return nil
+}
+
+// generatePassword returns a random alphanumeric password that contains
+// upper and lower case letters and digits, which satisfies the default
+// password complexity rules of Windows.
+func generatePassword(length int) (string, error) {
+	chars := []rune(interpolate.DefaultPasswordCharset)
+	max := big.NewInt(int64(len(chars)))
+	for {
+		result := make([]rune, length)
+		for i := range result {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			result[i] = chars[n.Int64()]
+		}
+
+		password := string(result)
+		if strings.ContainsAny(password, "abcdefghijklmnopqrstuvwxyz") &&
+			strings.ContainsAny(password, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+			strings.ContainsAny(password, "0123456789") {
+			return password, nil
+		}
+	}
+}
+
+const unixHeader = `set -e
+rm -f "$0"
+
+packer_group() {
+    if [ "$2" = present ]; then
+        if ! getent group "$1" >/dev/null; then
+            groupadd "$1"
+            echo "Created group $1"
+        fi
+    elif getent group "$1" >/dev/null; then
+        groupdel "$1"
+        echo "Removed group $1"
+    fi
+}
+
+# sudo skips files in sudoers.d whose names contain a dot
+packer_sudoers() {
+    echo "/etc/sudoers.d/packer-$(echo "$1" | tr . _)"
+}
+
+packer_remove_user() {
+    if id -u "$1" >/dev/null 2>&1; then
+        userdel -r "$1" 2>/dev/null || userdel "$1"
+        echo "Removed user $1"
+    fi
+    rm -f "$(packer_sudoers "$1")"
+}
+
+packer_user() {
+    name=$1; shell=$2; comment=$3
+    if ! id -u "$name" >/dev/null 2>&1; then
+        if [ -n "$shell" ]; then
+            useradd -m -s "$shell" -c "$comment" "$name"
+        else
+            useradd -m -c "$comment" "$name"
+        fi
+        echo "Created user $name"
+    fi
+}
+
+packer_sudo() {
+    file=$(packer_sudoers "$1")
+    echo "$1 ALL=(ALL) $2ALL" > "$file"
+    chmod 0440 "$file"
+    echo "Granted sudo to $1"
+}
+
+`
+
+// unixScript creates groups first so users can be added to them, and
+// removes groups last so their members are gone already.
+func unixScript(groups []*Group, users []*User) string {
+	var buf bytes.Buffer
+	buf.WriteString(unixHeader)
+
+	for _, g := range groups {
+		if g.present {
+			fmt.Fprintf(&buf, "packer_group %s present\n", provisioner.ShellQuote(g.Name))
+		}
+	}
+
+	for _, u := range users {
+		if !u.present {
+			fmt.Fprintf(&buf, "packer_remove_user %s\n", provisioner.ShellQuote(u.Name))
+			continue
+		}
+
+		fmt.Fprintf(&buf, "packer_user %s %s %s\n", provisioner.ShellQuote(u.Name), provisioner.ShellQuote(u.Shell), provisioner.ShellQuote(u.FullName))
+		if u.Password != "" {
+			fmt.Fprintf(&buf, "printf '%%s\\n' %s | chpasswd\n", provisioner.ShellQuote(u.Name+":"+u.Password))
+		}
+		if len(u.Groups) > 0 {
+			fmt.Fprintf(&buf, "usermod -a -G %s %s\n", provisioner.ShellQuote(strings.Join(u.Groups, ",")), provisioner.ShellQuote(u.Name))
+		}
+		if u.Admin {
+			nopasswd := ""
+			if u.PasswordlessSudo {
+				nopasswd = "NOPASSWD:"
+			}
+			fmt.Fprintf(&buf, "packer_sudo %s %s\n", provisioner.ShellQuote(u.Name), provisioner.ShellQuote(nopasswd))
+		}
+	}
+
+	for _, g := range groups {
+		if !g.present {
+			fmt.Fprintf(&buf, "packer_group %s absent\n", provisioner.ShellQuote(g.Name))
+		}
+	}
+
+	return buf.String()
+}
+
+const windowsHeader = `$ErrorActionPreference = 'Stop'
+Remove-Item -LiteralPath $MyInvocation.MyCommand.Path -Force -ErrorAction SilentlyContinue
+
+$computer = [ADSI]"WinNT://$env:COMPUTERNAME,computer"
+# The name of the Administrators group depends on the language of Windows
+$administrators = (New-Object System.Security.Principal.SecurityIdentifier('S-1-5-32-544')).Translate([System.Security.Principal.NTAccount]).Value.Split('\')[-1]
+
+function Find-PackerAccount($name, $class) {
+    $computer.Children | Where-Object { $_.SchemaClassName -eq $class -and $_.Name -eq $name } | ForEach-Object { [ADSI]$_.Path }
+}
+
+function Set-PackerGroup($name, $present) {
+    $group = Find-PackerAccount $name 'Group'
+    if ($present -and -not $group) {
+        $group = $computer.Create('Group', $name)
+        $group.SetInfo()
+        Write-Host "Created group $name"
+    } elseif (-not $present -and $group) {
+        $computer.Delete('Group', $name)
+        Write-Host "Removed group $name"
+    }
+}
+
+function Remove-PackerUser($name) {
+    if (Find-PackerAccount $name 'User') {
+        $computer.Delete('User', $name)
+        Write-Host "Removed user $name"
+    }
+}
+
+function Set-PackerUser($name, $password, $fullName, $groups) {
+    $user = Find-PackerAccount $name 'User'
+    if (-not $user) {
+        $user = $computer.Create('User', $name)
+        Write-Host "Created user $name"
+    }
+    if ($password) {
+        $user.SetPassword($password)
+    }
+    if ($fullName) {
+        $user.Put('FullName', $fullName)
+    }
+    $user.SetInfo()
+
+    foreach ($groupName in $groups) {
+        $group = [ADSI]"WinNT://$env:COMPUTERNAME/$groupName,group"
+        if (-not $group.IsMember($user.ADsPath)) {
+            $group.Add($user.ADsPath)
+            Write-Host "Added $name to $groupName"
+        }
+    }
+}
+
+`
+
+func windowsScript(groups []*Group, users []*User) string {
+	var buf bytes.Buffer
+	buf.WriteString(windowsHeader)
+
+	for _, g := range groups {
+		if g.present {
+			fmt.Fprintf(&buf, "Set-PackerGroup %s $true\n", provisioner.PowerShellQuote(g.Name))
+		}
+	}
+
+	for _, u := range users {
+		if !u.present {
+			fmt.Fprintf(&buf, "Remove-PackerUser %s\n", provisioner.PowerShellQuote(u.Name))
+			continue
+		}
+
+		quoted := make([]string, 0, len(u.Groups)+1)
+		if u.Admin {
+			quoted = append(quoted, "$administrators")
+		}
+		for _, g := range u.Groups {
+			quoted = append(quoted, provisioner.PowerShellQuote(g))
+		}
+		fmt.Fprintf(&buf, "Set-PackerUser %s %s %s @(%s)\n",
+			provisioner.PowerShellQuote(u.Name), provisioner.PowerShellQuote(u.Password), provisioner.PowerShellQuote(u.FullName), strings.Join(quoted, ","))
+	}
+
+	for _, g := range groups {
+		if !g.present {
+			fmt.Fprintf(&buf, "Set-PackerGroup %s $false\n", provisioner.PowerShellQuote(g.Name))
+		}
+	}
+
+	return buf.String()
+}
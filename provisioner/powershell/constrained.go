@@ -0,0 +1,90 @@
+package powershell
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer/common/uuid"
+)
+
+type endpointOptions struct {
+	ConfigurationName string
+	Name              string
+}
+
+// runnerCleanup removes the runner script it starts, which PowerShell
+// already read, so that runners don't pile up in the temporary directory.
+const runnerCleanup = "Remove-Item -LiteralPath $MyInvocation.MyCommand.Path -Force -ErrorAction SilentlyContinue\n"
+
+// endpointTemplate runs a script within the session configuration (for
+// example a JEA endpoint) on the machine itself, from a loopback session
+// since the communicator can't connect to it. The exit code of the script
+// is read back from the session since it isn't propagated by
+// Invoke-Command, which only works in endpoints whose language mode
+// allows scripts and variables: the others fail with a clear error.
+var endpointTemplate = template.Must(template.New("EndpointCommand").Parse(runnerCleanup + `$session = New-PSSession -ComputerName localhost -ConfigurationName '{{.ConfigurationName}}'
+try {
+  $mode = Invoke-Command -Session $session -ScriptBlock { $ExecutionContext.SessionState.LanguageMode } -ErrorAction SilentlyContinue
+  if (@('FullLanguage', 'ConstrainedLanguage') -notcontains "$mode") {
+    throw "The session configuration can't run scripts: its language mode must be FullLanguage or ConstrainedLanguage"
+  }
+  Invoke-Command -Session $session -FilePath "$env:TEMP\{{.Name}}" -ErrorAction Stop
+  $code = Invoke-Command -Session $session -ScriptBlock { $LASTEXITCODE }
+} catch {
+  Write-Error $_
+  $code = 1
+} finally {
+  Remove-PSSession $session
+  Remove-Item -LiteralPath "$env:TEMP\{{.Name}}" -Force -ErrorAction SilentlyContinue
+}
+exit $code
+`))
+
+// generateFileRunner uploads the command as a script and returns the
+// command line that runs it with -file. This is used instead of
+// -encodedCommand, which constrained guests reject.
+func (p *Provisioner) generateFileRunner(command string) (string, error) {
+	// The runner of an endpoint removes the script it runs
+	if p.config.ConfigurationName == "" {
+		command = runnerCleanup + command
+	}
+
+	name, err := p.uploadRunner("packer-ps-command", command)
+	if err != nil {
+		return "", err
+	}
+
+	if p.config.ConfigurationName != "" {
+		var buffer bytes.Buffer
+		err := endpointTemplate.Execute(&buffer, &endpointOptions{
+			ConfigurationName: strings.Replace(p.config.ConfigurationName, "'", "''", -1),
+			Name:              name,
+		})
+		if err != nil {
+			return "", fmt.Errorf("Error creating endpoint runner: %s", err)
+		}
+
+		name, err = p.uploadRunner("packer-ps-endpoint", buffer.String())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("powershell -executionpolicy bypass -file \"%%TEMP%%\\%s\"", name), nil
+}
+
+// uploadRunner uploads the script to the temporary directory of the
+// remote user and returns its file name.
+func (p *Provisioner) uploadRunner(prefix string, script string) (string, error) {
+	name := fmt.Sprintf("%s-%s.ps1", prefix, uuid.TimeOrderedUUID())
+	log.Printf("Uploading command runner to %s", name)
+	err := p.communicator.Upload(`${env:TEMP}\`+name, strings.NewReader(script), nil)
+	if err != nil {
+		return "", fmt.Errorf("Error uploading command runner: %s", err)
+	}
+
+	return name, nil
+}
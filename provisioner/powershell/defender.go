@@ -0,0 +1,143 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+)
+
+// cleanupTimeout bounds the scripts that undo the changes of the
+// provisioner to the machine. They run even if the provisioning was
+// cancelled.
+const cleanupTimeout = 5 * time.Minute
+
+// defenderAddedPrefix prefixes the lines defenderAddTemplate outputs for
+// every exclusion it adds.
+const defenderAddedPrefix = "Added Windows Defender exclusion: "
+
+type defenderOptions struct {
+	Paths       []string
+	StatePath   string
+	AddedPrefix string
+}
+
+// defenderAddTemplate excludes the paths from Windows Defender scanning
+// and remembers which of them weren't excluded already, so that only
+// those are removed again afterwards.
+var defenderAddTemplate = template.Must(template.New("DefenderAdd").Parse(`if (-not (Get-Command Add-MpPreference -ErrorAction SilentlyContinue)) {
+  Write-Warning 'Windows Defender is not available, not adding exclusions'
+  exit 0
+}
+$existing = @((Get-MpPreference).ExclusionPath)
+$added = @()
+foreach ($p in @({{range $i, $p := .Paths}}{{if $i}}, {{end}}'{{$p}}'{{end}})) {
+  $p = [Environment]::ExpandEnvironmentVariables($p)
+  if ($existing -notcontains $p) {
+    Add-MpPreference -ExclusionPath $p -ErrorAction Stop
+    Write-Output "{{.AddedPrefix}}$p"
+    $added += $p
+  }
+}
+Set-Content -Path '{{.StatePath}}' -Value $added
+exit 0
+`))
+
+// defenderRemoveTemplate removes the exclusions that were added.
+var defenderRemoveTemplate = template.Must(template.New("DefenderRemove").Parse(`if (-not (Test-Path '{{.StatePath}}')) {
+  exit 0
+}
+foreach ($p in @(Get-Content -Path '{{.StatePath}}')) {
+  if ($p) {
+    Remove-MpPreference -ExclusionPath $p -ErrorAction Stop
+    Write-Output "Removed Windows Defender exclusion: $p"
+  }
+}
+Remove-Item -Path '{{.StatePath}}'
+exit 0
+`))
+
+// scriptDirs returns the directories scripts are uploaded to: the one of
+// remote_path and the temporary directory of the remote user.
+func (p *Provisioner) scriptDirs() []string {
+	// remote_path was checked in Prepare
+	remotePath, _ := p.renderRemotePath("defender-exclusion")
+	dir := path.Dir(strings.Replace(remotePath, `\`, "/", -1))
+	return []string{
+		strings.Replace(dir, "'", "''", -1),
+		"%TEMP%",
+	}
+}
+
+func (p *Provisioner) defenderExclusions() *defenderOptions {
+	return &defenderOptions{
+		Paths: p.scriptDirs(),
+		StatePath: fmt.Sprintf(`%s/packer-defender-%s.txt`,
+			p.remoteTempDir(), uuid.TimeOrderedUUID()),
+		AddedPrefix: defenderAddedPrefix,
+	}
+}
+
+// addDefenderExclusions excludes the script directories from Windows
+// Defender and records the added exclusions in the metadata of the
+// artifact. The returned function removes the exclusions again.
+func (p *Provisioner) addDefenderExclusions(ctx context.Context, ui packer.Ui, comm packer.Communicator) (func() error, error) {
+	opts := p.defenderExclusions()
+	ui.Say("Adding Windows Defender exclusions for provisioning scripts...")
+	var stdout bytes.Buffer
+	if err := p.runTemplateOutput(ctx, ui, comm, "defender-exclusion", defenderAddTemplate, opts, &stdout); err != nil {
+		return nil, fmt.Errorf("Error adding Windows Defender exclusions: %s", err)
+	}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if strings.HasPrefix(line, defenderAddedPrefix) {
+			p.addMetadata("powershell.defender_exclusions",
+				strings.TrimSpace(strings.TrimPrefix(line, defenderAddedPrefix)))
+		}
+	}
+
+	return func() error {
+		// The exclusions are removed even if the provisioning was
+		// cancelled, so its context can't be used.
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+
+		ui.Say("Removing Windows Defender exclusions for provisioning scripts...")
+		if err := p.runTemplate(ctx, ui, comm, "defender-exclusion", defenderRemoveTemplate, opts); err != nil {
+			return fmt.Errorf("Error removing Windows Defender exclusions: %s", err)
+		}
+
+		return nil
+	}, nil
+}
+
+// runTemplate runs the script of the template on the machine, uploaded
+// with the given name. It fails if the script exits with another status
+// than 0.
+func (p *Provisioner) runTemplate(ctx context.Context, ui packer.Ui, comm packer.Communicator, name string, t *template.Template, data interface{}) error {
+	return p.runTemplateOutput(ctx, ui, comm, name, t, data, nil)
+}
+
+// runTemplateOutput is runTemplate, also writing the standard output of
+// the script to stdout, if not nil.
+func (p *Provisioner) runTemplateOutput(ctx context.Context, ui packer.Ui, comm packer.Communicator, name string, t *template.Template, data interface{}, stdout *bytes.Buffer) error {
+	var script bytes.Buffer
+	if err := t.Execute(&script, data); err != nil {
+		return err
+	}
+
+	cmd, err := p.runScriptOutput(ctx, ui, comm, name, script.String(), stdout)
+	if err != nil {
+		return err
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("exit status %d", cmd.ExitStatus)
+	}
+
+	return nil
+}
@@ -4,6 +4,21 @@ import (
 	"text/template"
 )
 
+const (
+	// ElevationScheduledTask runs the command as a scheduled task of the
+	// elevated user, which gets a full administrator token.
+	ElevationScheduledTask = "scheduled_task"
+
+	// ElevationRunAsProcess starts the command as a process of the elevated
+	// user, with the full administrator token of a batch logon, which UAC
+	// doesn't filter.
+	ElevationRunAsProcess = "runas_process"
+
+	// ElevationPsExecSystem runs the command as SYSTEM with PsExec, which
+	// must be on the PATH of the machine.
+	ElevationPsExecSystem = "psexec_system"
+)
+
 type elevatedOptions struct {
 	User            string
 	Password        string
@@ -86,3 +101,119 @@ if (Test-Path $log) {
 }
 [System.Runtime.Interopservices.Marshal]::ReleaseComObject($s) | Out-Null
 exit $result`))
+
+// runAsProcessTemplate logs the elevated user on like the task scheduler
+// does, as a batch job, since UAC filters the token of the interactive
+// logon of Start-Process -Credential unless the user is the built-in
+// Administrator. Starting a process with the token needs the
+// SeImpersonatePrivilege of administrators.
+var runAsProcessTemplate = template.Must(template.New("RunAsProcessCommand").Parse(`
+$name = "{{.TaskName}}"
+$log = "$env:SystemRoot\Temp\$name.out"
+Add-Type -TypeDefinition @'
+using System;
+using System.ComponentModel;
+using System.Runtime.InteropServices;
+
+public static class PackerRunAsProcess
+{
+    [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+    struct StartupInfo
+    {
+        public int cb;
+        public string lpReserved, lpDesktop, lpTitle;
+        public int dwX, dwY, dwXSize, dwYSize, dwXCountChars, dwYCountChars, dwFillAttribute, dwFlags;
+        public short wShowWindow, cbReserved2;
+        public IntPtr lpReserved2, hStdInput, hStdOutput, hStdError;
+    }
+
+    [StructLayout(LayoutKind.Sequential)]
+    struct ProcessInformation
+    {
+        public IntPtr hProcess, hThread;
+        public int dwProcessId, dwThreadId;
+    }
+
+    [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+    static extern bool LogonUser(string user, string domain, string password, int logonType, int logonProvider, out IntPtr token);
+
+    [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+    static extern bool CreateProcessWithTokenW(IntPtr token, int logonFlags, string application, string commandLine, int creationFlags, IntPtr environment, string directory, ref StartupInfo startupInfo, out ProcessInformation processInformation);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    static extern uint WaitForSingleObject(IntPtr handle, uint milliseconds);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    static extern bool GetExitCodeProcess(IntPtr process, out int exitCode);
+
+    [DllImport("kernel32.dll")]
+    static extern bool CloseHandle(IntPtr handle);
+
+    const int LogonBatch = 4;
+    const int LogonWithProfile = 1;
+    const int CreateNoWindow = 0x08000000;
+
+    public static int Run(string user, string domain, string password, string commandLine, string directory)
+    {
+        IntPtr token;
+        if (!LogonUser(user, domain, password, LogonBatch, 0, out token))
+            throw new Win32Exception(Marshal.GetLastWin32Error(), "Error logging on " + user + " as a batch job");
+        try
+        {
+            StartupInfo si = new StartupInfo();
+            si.cb = Marshal.SizeOf(si);
+            ProcessInformation pi;
+            if (!CreateProcessWithTokenW(token, LogonWithProfile, null, commandLine, CreateNoWindow, IntPtr.Zero, directory, ref si, out pi))
+                throw new Win32Exception(Marshal.GetLastWin32Error(), "Error starting the process of " + user);
+            try
+            {
+                WaitForSingleObject(pi.hProcess, 0xFFFFFFFF);
+                int exitCode;
+                GetExitCodeProcess(pi.hProcess, out exitCode);
+                return exitCode;
+            }
+            finally
+            {
+                CloseHandle(pi.hThread);
+                CloseHandle(pi.hProcess);
+            }
+        }
+        finally
+        {
+            CloseHandle(token);
+        }
+    }
+}
+'@
+$user = '{{.User}}'
+$domain = $null
+if ($user.Contains('\')) {
+  $domain, $user = $user.Split('\', 2)
+}
+if (Test-Path variable:global:ProgressPreference){$ProgressPreference="SilentlyContinue"}
+$result = [PackerRunAsProcess]::Run($user, $domain, '{{.Password}}', "cmd /c powershell.exe -EncodedCommand {{.EncodedCommand}} > $log 2>&1", $env:SystemRoot)
+if (Test-Path $log) {
+  Get-Content $log | ForEach {
+    Write-Output "$_"
+  }
+  Remove-Item $log -Force -ErrorAction SilentlyContinue | Out-Null
+}
+exit $result`))
+
+var psExecSystemTemplate = template.Must(template.New("PsExecSystemCommand").Parse(`
+$name = "{{.TaskName}}"
+$log = "$env:SystemRoot\Temp\$name.out"
+if (-not (Get-Command psexec.exe -ErrorAction SilentlyContinue)) {
+  Write-Error "psexec.exe must be on the PATH to use the psexec_system elevation method"
+  exit 1
+}
+if (Test-Path variable:global:ProgressPreference){$ProgressPreference="SilentlyContinue"}
+& psexec.exe -accepteula -nobanner -s cmd /c "powershell.exe -EncodedCommand {{.EncodedCommand}} > $log 2>&1" 2>&1 | Out-Null
+$result = $LASTEXITCODE
+if (Test-Path $log) {
+  Get-Content $log | ForEach {
+    Write-Output "$_"
+  }
+  Remove-Item $log -Force -ErrorAction SilentlyContinue | Out-Null
+}
+exit $result`))
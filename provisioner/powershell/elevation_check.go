@@ -0,0 +1,82 @@
+package powershell
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// elevationProbe exits with elevationProbeUnelevated if it isn't running
+// with administrator privileges. The exit code is unusual on purpose so
+// it can't be confused with powershell itself failing to start.
+const elevationProbe = `$principal = New-Object Security.Principal.WindowsPrincipal([Security.Principal.WindowsIdentity]::GetCurrent())
+if (-not $principal.IsInRole([Security.Principal.WindowsBuiltInRole]::Administrator)) { exit 17 }
+exit 0
+`
+
+const elevationProbeUnelevated = 17
+
+var requiresAdministratorRe = regexp.MustCompile(`(?i)^\s*#requires\s.*-RunAsAdministrator\b`)
+
+// requiresAdministrator says whether or not the script at path contains
+// a `#requires -RunAsAdministrator` statement.
+func requiresAdministrator(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if requiresAdministratorRe.MatchString(scanner.Text()) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// checkElevation runs the elevation probe the same way the scripts will
+// be run and fails if any of them requires administrator privileges that
+// the probe doesn't have.
+func (p *Provisioner) checkElevation(ctx context.Context, ui packer.Ui, comm packer.Communicator, scripts []string) error {
+	var required []string
+	for _, path := range scripts {
+		ok, err := requiresAdministrator(path)
+		if err != nil {
+			return fmt.Errorf("Error reading powershell script: %s", err)
+		}
+		if ok {
+			required = append(required, path)
+		}
+	}
+	if len(required) == 0 {
+		log.Printf("No script requires -RunAsAdministrator, skipping elevation check")
+		return nil
+	}
+
+	ui.Say("Checking that scripts will run elevated...")
+	cmd, err := p.runScript(ctx, ui, comm, "elevation-check", elevationProbe)
+	if err != nil {
+		return fmt.Errorf("Error running elevation check: %s", err)
+	}
+
+	switch cmd.ExitStatus {
+	case 0:
+		return nil
+	case elevationProbeUnelevated:
+		return fmt.Errorf(
+			"Scripts require -RunAsAdministrator but would not run elevated: %s. "+
+				"Connect as an administrator or set 'elevated_user' and 'elevated_password'.",
+			strings.Join(required, ", "))
+	default:
+		return fmt.Errorf("Elevation check exited with unexpected status: %d", cmd.ExitStatus)
+	}
+}
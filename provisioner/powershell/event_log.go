@@ -0,0 +1,99 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+)
+
+// defaultEventLogs are the event logs exported when a script fails, if
+// event_logs isn't set.
+var defaultEventLogs = []string{"Application", "System"}
+
+type eventLogOptions struct {
+	Logs       []string
+	Lookback   int
+	OutputFile string
+}
+
+// eventLogTemplate writes the events of the logs, which can have
+// wildcards, newer than the lookback window to a text file, oldest
+// first. Logs that don't exist or can't be read are skipped.
+var eventLogTemplate = template.Must(template.New("EventLog").Parse(`$ErrorActionPreference = 'Continue'
+$since = (Get-Date).AddSeconds(-{{.Lookback}})
+$logs = Get-WinEvent -ListLog @({{range $i, $l := .Logs}}{{if $i}}, {{end}}'{{$l}}'{{end}}) -ErrorAction SilentlyContinue |
+  Where-Object { $_.IsEnabled -and $_.RecordCount -gt 0 }
+$events = foreach ($log in $logs) {
+  Get-WinEvent -FilterHashtable @{LogName=$log.LogName; StartTime=$since} -ErrorAction SilentlyContinue
+}
+$events | Sort-Object TimeCreated | ForEach-Object {
+  "{0:yyyy-MM-dd HH:mm:ss} [{1}] {2} {3} ({4})` + "`r`n" + `{5}` + "`r`n" + `" -f $_.TimeCreated, $_.LevelDisplayName, $_.LogName, $_.ProviderName, $_.Id, $_.Message
+} | Out-File -Encoding UTF8 -FilePath '{{.OutputFile}}'
+exit 0
+`))
+
+// exportEventLogs downloads the recent events of the event logs of the
+// machine to event_log_output, since failed installers often only explain
+// themselves there. It is called once a script failed, so its errors are
+// only reported.
+func (p *Provisioner) exportEventLogs(ctx context.Context, ui packer.Ui, comm packer.Communicator) {
+	remote := fmt.Sprintf(`%s/events-%s.txt`, p.remoteTempDir(), uuid.TimeOrderedUUID())
+
+	logs := make([]string, len(p.config.EventLogs))
+	for i, l := range p.config.EventLogs {
+		logs[i] = strings.Replace(l, "'", "''", -1)
+	}
+
+	var script bytes.Buffer
+	err := eventLogTemplate.Execute(&script, &eventLogOptions{
+		Logs:       logs,
+		Lookback:   int(p.config.EventLogLookback.Seconds()),
+		OutputFile: remote,
+	})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error generating event log export: %s", err))
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Exporting the events of the last %s of the event logs: %s",
+		p.config.EventLogLookback, strings.Join(p.config.EventLogs, ", ")))
+	cmd, err := p.runScript(ctx, ui, comm, "event-log", script.String())
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error exporting the event logs: %s", err))
+		return
+	}
+	if cmd.ExitStatus != 0 {
+		ui.Error(fmt.Sprintf("Exporting the event logs exited with status %d", cmd.ExitStatus))
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Downloading the events to %s", p.config.EventLogOutput))
+	if err := p.downloadEventLogs(comm, remote); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (p *Provisioner) downloadEventLogs(comm packer.Communicator, remote string) error {
+	if err := os.MkdirAll(filepath.Dir(p.config.EventLogOutput), 0755); err != nil {
+		return fmt.Errorf("Error creating directory for the events: %s", err)
+	}
+
+	f, err := os.Create(p.config.EventLogOutput)
+	if err != nil {
+		return fmt.Errorf("Error creating the events file: %s", err)
+	}
+	defer f.Close()
+
+	if err := comm.Download(remote, f); err != nil {
+		return fmt.Errorf("Error downloading the events: %s", err)
+	}
+
+	return nil
+}
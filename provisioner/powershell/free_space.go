@@ -0,0 +1,69 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// freeSpaceLow is the exit status of the check if there isn't enough
+// free space.
+const freeSpaceLow = 18
+
+type freeSpaceOptions struct {
+	MinFreeSpace int
+	Paths        []string
+	LowStatus    int
+}
+
+// freeSpaceTemplate checks the space available to the remote user on
+// the drives of the paths. AvailableFreeSpace takes disk quotas into
+// account, so this also catches a full TEMP quota.
+var freeSpaceTemplate = template.Must(template.New("FreeSpace").Parse(`$min = {{.MinFreeSpace}} * 1MB
+$low = $false
+foreach ($p in @({{range $i, $p := .Paths}}{{if $i}}, {{end}}'{{$p}}'{{end}})) {
+  $p = [Environment]::ExpandEnvironmentVariables($p)
+  $drive = New-Object System.IO.DriveInfo([System.IO.Path]::GetPathRoot($p))
+  $free = [math]::Floor($drive.AvailableFreeSpace / 1MB)
+  if ($drive.AvailableFreeSpace -lt $min) {
+    Write-Output "Only $free MB available for $p"
+    $low = $true
+  }
+}
+if ($low) { exit {{.LowStatus}} }
+exit 0
+`))
+
+// checkFreeSpace fails if there is less than min_free_space available
+// for the scripts and the temporary directory of the remote user.
+func (p *Provisioner) checkFreeSpace(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	var script bytes.Buffer
+	err := freeSpaceTemplate.Execute(&script, &freeSpaceOptions{
+		MinFreeSpace: p.config.MinFreeSpace,
+		Paths:        p.scriptDirs(),
+		LowStatus:    freeSpaceLow,
+	})
+	if err != nil {
+		return fmt.Errorf("Error generating free space check: %s", err)
+	}
+
+	ui.Say(fmt.Sprintf("Checking for at least %d MB of free space...", p.config.MinFreeSpace))
+	cmd, err := p.runScript(ctx, ui, comm, "free-space", script.String())
+	if err != nil {
+		return fmt.Errorf("Error checking free space: %s", err)
+	}
+
+	switch cmd.ExitStatus {
+	case 0:
+		return nil
+	case freeSpaceLow:
+		return fmt.Errorf(
+			"Not enough free space on the machine, min_free_space is %d MB. See the output above.",
+			p.config.MinFreeSpace)
+	default:
+		return fmt.Errorf("Free space check exited with unexpected status: %d", cmd.ExitStatus)
+	}
+}
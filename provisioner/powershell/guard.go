@@ -0,0 +1,68 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// guardApplied is the exit status of the guard if the scripts were
+// already applied.
+const guardApplied = 19
+
+type guardOptions struct {
+	Creates       string
+	Unless        string
+	AppliedStatus int
+}
+
+// guardTemplate exits with AppliedStatus if the path of creates exists or
+// the expression of unless is true.
+var guardTemplate = template.Must(template.New("Guard").Parse(`$ErrorActionPreference = 'Stop'
+{{if .Creates}}if (Test-Path -Path ([Environment]::ExpandEnvironmentVariables('{{.Creates}}'))) {
+  Write-Output "{{.Creates}} exists"
+  exit {{.AppliedStatus}}
+}
+{{end}}{{if .Unless}}if ({{.Unless}}) {
+  Write-Output "unless is true"
+  exit {{.AppliedStatus}}
+}
+{{end}}exit 0
+`))
+
+// alreadyApplied runs the guard of creates and unless on the machine. It
+// returns true if the scripts were already applied and must be skipped.
+func (p *Provisioner) alreadyApplied(ctx context.Context, ui packer.Ui, comm packer.Communicator) (bool, error) {
+	if p.config.Creates == "" && p.config.Unless == "" {
+		return false, nil
+	}
+
+	var script bytes.Buffer
+	err := guardTemplate.Execute(&script, &guardOptions{
+		Creates:       strings.Replace(p.config.Creates, "'", "''", -1),
+		Unless:        p.config.Unless,
+		AppliedStatus: guardApplied,
+	})
+	if err != nil {
+		return false, fmt.Errorf("Error generating guard: %s", err)
+	}
+
+	ui.Say("Checking whether the scripts were already applied...")
+	cmd, err := p.runScript(ctx, ui, comm, "guard", script.String())
+	if err != nil {
+		return false, fmt.Errorf("Error running guard: %s", err)
+	}
+
+	switch cmd.ExitStatus {
+	case 0:
+		return false, nil
+	case guardApplied:
+		return true, nil
+	default:
+		return false, fmt.Errorf("Guard exited with unexpected status: %d", cmd.ExitStatus)
+	}
+}
@@ -0,0 +1,260 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+)
+
+// The exit statuses of the installer script if the installer failed, if
+// it succeeded but the product isn't installed, and if the product was
+// already installed.
+const (
+	installerFailed     = 20
+	installerNotApplied = 21
+	installerInstalled  = 22
+)
+
+// defaultInstallerExitCodes are the exit codes of successful installers:
+// 3010 is the code of installs that need a restart.
+var defaultInstallerExitCodes = []int{0, 3010}
+
+// defaultMSIArgs install MSI packages silently, without restarting.
+const defaultMSIArgs = "/qn /norestart"
+
+var productCodeRe = regexp.MustCompile(`^\{[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}$`)
+
+// Installer is an MSI or EXE installer run by the provisioner.
+type Installer struct {
+	// Source is the local path of the installer, which is uploaded.
+	// RemoteSource is the path of an installer already on the machine.
+	Source       string `mapstructure:"source"`
+	RemoteSource string `mapstructure:"remote_source"`
+
+	// Args are the arguments of the installer, /qn /norestart by default
+	// for MSI packages.
+	Args string `mapstructure:"args"`
+
+	// ProductCode is the product code the installer installs. If set, the
+	// installer is skipped if the product is already installed, and fails
+	// if it isn't installed afterwards.
+	ProductCode string `mapstructure:"product_code"`
+
+	// ValidExitCodes are the exit codes of a successful install, 0 and
+	// 3010 by default.
+	ValidExitCodes []int `mapstructure:"valid_exit_codes"`
+
+	// LogOutput is a local path to download the verbose log of MSI
+	// packages to.
+	LogOutput string `mapstructure:"log_output"`
+}
+
+// name returns the file name of the installer.
+func (i *Installer) name() string {
+	if i.Source != "" {
+		return filepath.Base(i.Source)
+	}
+	return filepath.Base(strings.Replace(i.RemoteSource, `\`, "/", -1))
+}
+
+// msi returns true if the installer is an MSI package, run by msiexec.
+func (i *Installer) msi() bool {
+	return strings.EqualFold(filepath.Ext(i.name()), ".msi")
+}
+
+// prepare sets the defaults of the installer and validates it.
+func (i *Installer) prepare(n int) []error {
+	if i.Args == "" && i.msi() {
+		i.Args = defaultMSIArgs
+	}
+	if i.ValidExitCodes == nil {
+		i.ValidExitCodes = defaultInstallerExitCodes
+	}
+
+	var errs []error
+	if (i.Source == "") == (i.RemoteSource == "") {
+		errs = append(errs, fmt.Errorf("installers[%d]: exactly one of source or remote_source must be specified", n))
+	} else if i.Source != "" {
+		if _, err := os.Stat(i.Source); err != nil {
+			errs = append(errs, fmt.Errorf("installers[%d]: bad source '%s': %s", n, i.Source, err))
+		}
+	}
+	if i.ProductCode != "" && !productCodeRe.MatchString(i.ProductCode) {
+		errs = append(errs, fmt.Errorf(
+			"installers[%d]: product_code must be a GUID in braces, like {12345678-1234-1234-1234-123456789012}", n))
+	}
+	if i.LogOutput != "" && !i.msi() {
+		errs = append(errs, fmt.Errorf("installers[%d]: log_output can only be used with MSI packages", n))
+	}
+	return errs
+}
+
+type installerOptions struct {
+	Path             string
+	Args             string
+	MSI              bool
+	Log              string
+	ProductCode      string
+	ValidExitCodes   string
+	FailedStatus     int
+	NotAppliedStatus int
+	InstalledStatus  int
+}
+
+// installerTemplate runs an installer and waits for it. The product is
+// looked up in the uninstall keys of the registry, of 32 and 64-bit
+// programs.
+var installerTemplate = template.Must(template.New("Installer").Parse(`$ErrorActionPreference = 'Stop'
+{{if .ProductCode}}function Test-Product {
+  foreach ($key in 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall', 'HKLM:\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall') {
+    if (Test-Path -LiteralPath "$key\{{.ProductCode}}") { return $true }
+  }
+  return $false
+}
+if (Test-Product) {
+  exit {{.InstalledStatus}}
+}
+{{end}}{{if .MSI}}$p = Start-Process -FilePath msiexec.exe -ArgumentList '/i "{{.Path}}" {{.Args}} /l*v "{{.Log}}"' -Wait -PassThru
+{{else}}$p = Start-Process -FilePath '{{.Path}}'{{if .Args}} -ArgumentList '{{.Args}}'{{end}} -Wait -PassThru
+{{end}}Write-Output "The installer exited with $($p.ExitCode)"
+if (@({{.ValidExitCodes}}) -notcontains $p.ExitCode) {
+{{if .MSI}}  if (Test-Path -LiteralPath '{{.Log}}') {
+    Write-Output 'The end of the installer log:'
+    Get-Content -LiteralPath '{{.Log}}' | Select-Object -Last 30
+  }
+{{end}}  exit {{.FailedStatus}}
+}
+{{if .ProductCode}}if (-not (Test-Product)) {
+  Write-Output "{{.ProductCode}} isn't installed"
+  exit {{.NotAppliedStatus}}
+}
+{{end}}exit 0
+`))
+
+// runInstallers runs the installers in order.
+func (p *Provisioner) runInstallers(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	for n, i := range p.config.Installers {
+		ui.Say(fmt.Sprintf("Running installer: %s", i.name()))
+		endTiming := packer.StartTiming(ui, "installer", i.name())
+		if err := p.runInstaller(ctx, ui, comm, n+1, &i); err != nil {
+			return err
+		}
+		endTiming(nil)
+	}
+	return nil
+}
+
+// runInstaller runs the installer, the nth of the provisioner, whose
+// script is named after its number.
+func (p *Provisioner) runInstaller(ctx context.Context, ui packer.Ui, comm packer.Communicator, n int, i *Installer) error {
+	id := uuid.TimeOrderedUUID()
+	path := i.RemoteSource
+	if i.Source != "" {
+		path = fmt.Sprintf(`%s/installer-%s-%s`, p.remoteTempDir(), id, i.name())
+		if err := p.uploadInstaller(ui, comm, i.Source, path); err != nil {
+			return err
+		}
+	}
+	remoteLog := fmt.Sprintf(`%s/installer-%s.log`, p.remoteTempDir(), id)
+
+	codes := make([]string, len(i.ValidExitCodes))
+	for n, c := range i.ValidExitCodes {
+		codes[n] = fmt.Sprint(c)
+	}
+
+	// msiexec doesn't accept forward slashes in paths
+	var script bytes.Buffer
+	err := installerTemplate.Execute(&script, &installerOptions{
+		Path:             strings.Replace(strings.Replace(path, "/", `\`, -1), "'", "''", -1),
+		Args:             strings.Replace(i.Args, "'", "''", -1),
+		MSI:              i.msi(),
+		Log:              strings.Replace(strings.Replace(remoteLog, "/", `\`, -1), "'", "''", -1),
+		ProductCode:      i.ProductCode,
+		ValidExitCodes:   strings.Join(codes, ", "),
+		FailedStatus:     installerFailed,
+		NotAppliedStatus: installerNotApplied,
+		InstalledStatus:  installerInstalled,
+	})
+	if err != nil {
+		return fmt.Errorf("Error generating installer script: %s", err)
+	}
+
+	cmd, err := p.runScript(ctx, ui, comm, fmt.Sprintf("installer-%d", n), script.String())
+	if err != nil {
+		return fmt.Errorf("Error running installer %s: %s", i.name(), err)
+	}
+
+	if cmd.ExitStatus == installerInstalled {
+		ui.Message(fmt.Sprintf("The product %s is already installed, skipping the installer.", i.ProductCode))
+		return nil
+	}
+
+	// The log is downloaded before looking at the exit status, it is the
+	// most useful when the installer failed
+	if i.LogOutput != "" {
+		if err := p.downloadInstallerLog(ui, comm, remoteLog, i.LogOutput); err != nil {
+			ui.Error(err.Error())
+		}
+	}
+
+	switch cmd.ExitStatus {
+	case 0:
+		return nil
+	case installerFailed:
+		return fmt.Errorf(
+			"Installer %s failed. Allowed exit codes are: %v. See the output above.",
+			i.name(), i.ValidExitCodes)
+	case installerNotApplied:
+		return fmt.Errorf(
+			"Installer %s succeeded, but the product %s isn't installed.", i.name(), i.ProductCode)
+	default:
+		return fmt.Errorf("Installer script of %s exited with unexpected status: %d", i.name(), cmd.ExitStatus)
+	}
+}
+
+func (p *Provisioner) uploadInstaller(ui packer.Ui, comm packer.Communicator, src, dst string) error {
+	log.Printf("Opening %s for reading", src)
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("Error opening installer: %s", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Error stating installer: %s", err)
+	}
+
+	ui.Message(fmt.Sprintf("Uploading %s => %s", src, dst))
+	if err := comm.Upload(dst, f, &fi); err != nil {
+		return fmt.Errorf("Error uploading installer: %s", err)
+	}
+	return nil
+}
+
+func (p *Provisioner) downloadInstallerLog(ui packer.Ui, comm packer.Communicator, remote, local string) error {
+	ui.Message(fmt.Sprintf("Downloading the installer log to %s", local))
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return fmt.Errorf("Error creating directory for the installer log: %s", err)
+	}
+
+	f, err := os.Create(local)
+	if err != nil {
+		return fmt.Errorf("Error creating the installer log: %s", err)
+	}
+	defer f.Close()
+
+	if err := comm.Download(remote, f); err != nil {
+		return fmt.Errorf("Error downloading the installer log: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package powershell
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// utf8OutputPrelude makes PowerShell decode the output of native commands
+// and write its own output as UTF-8, instead of in the OEM code page of
+// the machine. The encoding has no byte order mark, which would end up at
+// the start of the output.
+const utf8OutputPrelude = "$packerUtf8 = New-Object System.Text.UTF8Encoding $false; " +
+	"[Console]::OutputEncoding = $packerUtf8; $OutputEncoding = $packerUtf8; "
+
+// utf8Command returns the command with the prelude setting the output
+// encoding, if utf8_output is set.
+func (p *Provisioner) utf8Command(command string) string {
+	if !p.config.UTF8Output {
+		return command
+	}
+	return utf8OutputPrelude + command
+}
+
+// utf8Ui cleans up the UTF-8 output of the machine: byte order marks that
+// programs write anyway are removed, and the bytes of programs that write
+// in another encoding are replaced, instead of garbling the terminal.
+type utf8Ui struct {
+	packer.Ui
+}
+
+func (u *utf8Ui) Say(message string) {
+	u.Ui.Say(cleanUTF8(message))
+}
+
+func (u *utf8Ui) Message(message string) {
+	u.Ui.Message(cleanUTF8(message))
+}
+
+func (u *utf8Ui) Error(message string) {
+	u.Ui.Error(cleanUTF8(message))
+}
+
+// cleanUTF8 removes byte order marks from s and replaces its invalid
+// UTF-8 bytes with the replacement character.
+func cleanUTF8(s string) string {
+	s = strings.Replace(s, "\ufeff", "", -1)
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var b bytes.Buffer
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		i += size
+	}
+	return b.String()
+}
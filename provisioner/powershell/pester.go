@@ -0,0 +1,125 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+)
+
+const (
+	PesterOutputFormatNUnit = "NUnitXml"
+	PesterOutputFormatJUnit = "JUnitXml"
+)
+
+type pesterOptions struct {
+	Path         string
+	OutputFile   string
+	OutputFormat string
+}
+
+// pesterTemplate runs the tests and exits with the number of failed
+// tests, so that any failure shows up as a non-zero exit status.
+var pesterTemplate = template.Must(template.New("Pester").Parse(`$ErrorActionPreference = 'Stop'
+if (-not (Get-Module -ListAvailable -Name Pester)) {
+  Write-Error 'Pester is not installed on the machine'
+  exit 1
+}
+$result = Invoke-Pester -Script '{{.Path}}' -OutputFile '{{.OutputFile}}' -OutputFormat {{.OutputFormat}} -PassThru
+exit $result.FailedCount
+`))
+
+// runPester uploads the Pester tests, runs them and downloads the
+// results, if requested. It fails if any test fails.
+func (p *Provisioner) runPester(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	dir, err := p.pesterDir(ctx, ui, comm)
+	if err != nil {
+		return err
+	}
+	id := uuid.TimeOrderedUUID()
+	remoteDir := fmt.Sprintf(`%s/pester-%s`, dir, id)
+	remoteOutput := fmt.Sprintf(`%s/pester-%s.xml`, dir, id)
+
+	ui.Say(fmt.Sprintf("Uploading Pester tests: %s", p.config.PesterTests))
+	src := filepath.Clean(p.config.PesterTests) + string(filepath.Separator)
+	if err := comm.UploadDir(remoteDir, src, nil); err != nil {
+		return fmt.Errorf("Error uploading Pester tests: %s", err)
+	}
+
+	var script bytes.Buffer
+	err = pesterTemplate.Execute(&script, &pesterOptions{
+		Path:         remoteDir,
+		OutputFile:   remoteOutput,
+		OutputFormat: p.config.PesterOutputFormat,
+	})
+	if err != nil {
+		return fmt.Errorf("Error generating Pester script: %s", err)
+	}
+
+	ui.Say("Running Pester tests...")
+	cmd, err := p.runScript(ctx, ui, comm, "pester", script.String())
+	if err != nil {
+		return fmt.Errorf("Error running Pester tests: %s", err)
+	}
+
+	// Download the results before looking at the exit status, they are
+	// the most useful when tests failed.
+	if p.config.PesterOutput != "" {
+		if err := p.downloadPesterOutput(ui, comm, remoteOutput); err != nil {
+			return err
+		}
+	}
+
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf(
+			"Pester tests failed with exit status %d, which is the number of failed tests.",
+			cmd.ExitStatus)
+	}
+
+	return nil
+}
+
+// pesterDir returns the remote directory the Pester tests and their results
+// are uploaded to, next to the scripts: the directory of remote_path, which
+// is in the temporary directory of the guest unless remote_path is set.
+func (p *Provisioner) pesterDir(ctx context.Context, ui packer.Ui, comm packer.Communicator) (string, error) {
+	remotePath, err := p.renderRemotePath("pester")
+	if err != nil {
+		return "", fmt.Errorf("Error processing remote_path: %s", err)
+	}
+
+	dir := path.Dir(strings.Replace(remotePath, `\`, "/", -1))
+	if dir == "." {
+		return p.remoteTempDir(), nil
+	}
+	if !p.defaultRemotePath {
+		p.createRemoteDir(ctx, ui, comm, remotePath)
+	}
+	return dir, nil
+}
+
+func (p *Provisioner) downloadPesterOutput(ui packer.Ui, comm packer.Communicator, remote string) error {
+	ui.Say(fmt.Sprintf("Downloading Pester results to %s", p.config.PesterOutput))
+	if err := os.MkdirAll(filepath.Dir(p.config.PesterOutput), 0755); err != nil {
+		return fmt.Errorf("Error creating directory for Pester results: %s", err)
+	}
+
+	f, err := os.Create(p.config.PesterOutput)
+	if err != nil {
+		return fmt.Errorf("Error creating Pester results file: %s", err)
+	}
+	defer f.Close()
+
+	if err := comm.Download(remote, f); err != nil {
+		return fmt.Errorf("Error downloading Pester results: %s", err)
+	}
+
+	return nil
+}
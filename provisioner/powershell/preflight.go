@@ -0,0 +1,218 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// preflightFailed is the exit status of the preflight if a host can't be
+// resolved or an endpoint can't be connected to.
+const preflightFailed = 23
+
+type preflightOptions struct {
+	Clock        bool
+	Hosts        []string
+	URLs         []string
+	FailedStatus int
+}
+
+// preflightTemplate prints the UTC time of the machine, then resolves the
+// hosts and connects to the endpoints. The endpoints are connected to like
+// the scripts would, through the proxy of the machine. Any response, even
+// an error status, means the certificate of the endpoint is trusted.
+var preflightTemplate = template.Must(template.New("Preflight").Parse(`function Get-InnerMessage($e) {
+  while ($e.InnerException) { $e = $e.InnerException }
+  $e.Message
+}
+{{if .Clock}}Write-Output "Machine clock (UTC): $([DateTime]::UtcNow.ToString('o'))"
+{{end}}$failed = $false
+{{if .Hosts}}foreach ($h in @({{range $i, $h := .Hosts}}{{if $i}}, {{end}}'{{$h}}'{{end}})) {
+  try {
+    $addresses = [System.Net.Dns]::GetHostAddresses($h)
+    Write-Output "Resolved ${h}: $($addresses -join ', ')"
+  } catch {
+    Write-Output "Can't resolve ${h}: $(Get-InnerMessage $_.Exception) Check the DNS servers of the machine."
+    $failed = $true
+  }
+}
+{{end}}{{if .URLs}}[Net.ServicePointManager]::SecurityProtocol = [Net.ServicePointManager]::SecurityProtocol -bor [Net.SecurityProtocolType]::Tls12
+foreach ($u in @({{range $i, $u := .URLs}}{{if $i}}, {{end}}'{{$u}}'{{end}})) {
+  try {
+    $request = [System.Net.WebRequest]::Create($u)
+    $request.Method = 'HEAD'
+    $request.Timeout = 30000
+    $request.GetResponse().Close()
+    Write-Output "Connected to $u"
+  } catch {
+    $e = $_.Exception
+    while ($e -and -not ($e -is [System.Net.WebException])) { $e = $e.InnerException }
+    if ($e -and $e.Response) {
+      $e.Response.Close()
+      Write-Output "Connected to $u"
+    } elseif ($e -and $e.Status -eq 'TrustFailure') {
+      Write-Output "The certificate of $u isn't trusted: $(Get-InnerMessage $_.Exception) Install the certificate of its CA, or of the TLS inspecting proxy, in the trusted root certificates of the machine."
+      $failed = $true
+    } else {
+      Write-Output "Can't connect to ${u}: $(Get-InnerMessage $_.Exception) Check the network, firewall and proxy of the machine."
+      $failed = $true
+    }
+  }
+}
+{{end}}if ($failed) { exit {{.FailedStatus}} }
+exit 0
+`))
+
+var preflightClockRe = regexp.MustCompile(`Machine clock \(UTC\): (\S+)`)
+
+// hasPreflight returns true if any preflight check is configured.
+func (p *Provisioner) hasPreflight() bool {
+	return p.config.PreflightMaxClockSkew > 0 ||
+		len(p.config.PreflightDNSHosts) > 0 ||
+		len(p.config.PreflightTLSURLs) > 0
+}
+
+// checkPreflightConfig validates the preflight checks.
+func (p *Provisioner) checkPreflightConfig() []error {
+	var errs []error
+	if p.config.PreflightMaxClockSkew < 0 {
+		errs = append(errs, fmt.Errorf("preflight_max_clock_skew must not be negative"))
+	}
+	for _, h := range p.config.PreflightDNSHosts {
+		if h == "" || strings.ContainsAny(h, " \t/:") {
+			errs = append(errs, fmt.Errorf("preflight_dns_hosts: bad host name '%s'", h))
+		}
+	}
+	for _, raw := range p.config.PreflightTLSURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("preflight_tls_urls: bad URL '%s': %s", raw, err))
+		} else if u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("preflight_tls_urls: '%s' must be an https URL", raw))
+		}
+	}
+	return errs
+}
+
+// preflight checks the clock, the DNS resolution and the TLS connections
+// of the machine before running the scripts, so that these problems are
+// reported as such instead of failing downloads in the scripts.
+func (p *Provisioner) preflight(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	escape := func(values []string) []string {
+		escaped := make([]string, len(values))
+		for i, v := range values {
+			escaped[i] = strings.Replace(v, "'", "''", -1)
+		}
+		return escaped
+	}
+
+	var script bytes.Buffer
+	err := preflightTemplate.Execute(&script, &preflightOptions{
+		Clock:        p.config.PreflightMaxClockSkew > 0,
+		Hosts:        escape(p.config.PreflightDNSHosts),
+		URLs:         escape(p.config.PreflightTLSURLs),
+		FailedStatus: preflightFailed,
+	})
+	if err != nil {
+		return fmt.Errorf("Error generating preflight checks: %s", err)
+	}
+
+	ui.Say("Running preflight checks...")
+	var stdout bytes.Buffer
+	start := time.Now()
+	cmd, err := p.runScriptOutput(ctx, ui, comm, "preflight", script.String(), &stdout)
+	end := time.Now()
+	if err != nil {
+		return fmt.Errorf("Error running preflight checks: %s", err)
+	}
+
+	var errs *packer.MultiError
+	if p.config.PreflightMaxClockSkew > 0 {
+		if err := checkClockSkew(stdout.String(), start, end, p.config.PreflightMaxClockSkew); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	switch cmd.ExitStatus {
+	case 0:
+	case preflightFailed:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"The machine can't resolve or connect to the hosts the scripts need. See the output above."))
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"Preflight checks exited with unexpected status: %d", cmd.ExitStatus))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkPsExec checks that PsExec is on the PATH of the machine, for the
+// psexec_system elevation method. It doesn't run elevated.
+func (p *Provisioner) checkPsExec(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	command, err := p.generateCommandLineRunner(
+		"if (Get-Command psexec.exe -ErrorAction SilentlyContinue) { exit 0 }; exit 1")
+	if err != nil {
+		return fmt.Errorf("Error generating command line runner: %s", err)
+	}
+
+	var cmd *packer.RemoteCmd
+	err = p.retryable(func() error {
+		cmd = p.newRemoteCmd(command)
+		return cmd.RunWithUi(ctx, comm, ui)
+	})
+	if err != nil {
+		return fmt.Errorf("Error looking for PsExec: %s", err)
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf(
+			"psexec.exe isn't on the PATH of the machine, which the %s elevation "+
+				"method needs. Install PsExec on the machine, for example in the "+
+				"image, or use the %s or %s elevation method.",
+			ElevationPsExecSystem, ElevationScheduledTask, ElevationRunAsProcess)
+	}
+
+	return nil
+}
+
+// checkClockSkew checks the clock of the machine, read from the output of
+// the preflight, against the clock of this machine while it ran.
+func checkClockSkew(output string, start, end time.Time, max time.Duration) error {
+	matches := preflightClockRe.FindStringSubmatch(output)
+	if matches == nil {
+		return fmt.Errorf("The preflight checks didn't output the clock of the machine")
+	}
+	clock, err := time.Parse(time.RFC3339Nano, matches[1])
+	if err != nil {
+		return fmt.Errorf("Error parsing the clock of the machine: %s", err)
+	}
+
+	var skew time.Duration
+	direction := "ahead of"
+	switch {
+	case clock.Before(start):
+		skew = start.Sub(clock)
+		direction = "behind"
+	case clock.After(end):
+		skew = clock.Sub(end)
+	}
+	if skew <= max {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"The clock of the machine is %s %s the clock of the host running Packer, "+
+			"more than preflight_max_clock_skew (%s). Certificates and signed downloads "+
+			"fail to validate with a wrong clock: sync it, for example with "+
+			"\"w32tm /resync\", or fix the clock of the hypervisor.",
+		skew.Round(time.Second), direction, max)
+}
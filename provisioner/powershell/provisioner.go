@@ -5,12 +5,16 @@ package powershell
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +27,15 @@ import (
 
 var retryableSleep = 2 * time.Second
 
+// deprecations are the renamed and removed keys of the configuration.
+var deprecations = config.Deprecations{
+	{Key: "EnvVarFormat", NewKey: "env_var_format"},
+}
+
+func init() {
+	config.RegisterDeprecations(config.KindProvisioner, "powershell", deprecations)
+}
+
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
@@ -31,7 +44,8 @@ type Config struct {
 	Binary bool
 
 	// An inline script to execute. Multiple strings are all executed
-	// in the context of a single shell.
+	// in the context of a single shell. Strings like @file:path are
+	// replaced by the contents of the file.
 	Inline []string
 
 	// The local path of the powershell script to upload and execute.
@@ -46,6 +60,8 @@ type Config struct {
 
 	// The remote path where the local powershell script will be uploaded to.
 	// This should be set to a writable file that is in a pre-existing directory.
+	// The '{{ .ScriptName }}' variable can be used to tell the uploaded
+	// scripts apart.
 	RemotePath string `mapstructure:"remote_path"`
 
 	// The command used to execute the script. The '{{ .Path }}' variable
@@ -65,7 +81,7 @@ type Config struct {
 
 	// This is used in the template generation to format environment variables
 	// inside the `ExecuteCommand` template.
-	EnvVarFormat string
+	EnvVarFormat string `mapstructure:"env_var_format"`
 
 	// This is used in the template generation to format environment variables
 	// inside the `ElevatedExecuteCommand` template.
@@ -77,22 +93,155 @@ type Config struct {
 	ElevatedUser     string `mapstructure:"elevated_user"`
 	ElevatedPassword string `mapstructure:"elevated_password"`
 
+	// How the elevated command is run: scheduled_task (the default),
+	// runas_process or psexec_system. psexec_system runs as SYSTEM and
+	// doesn't need the elevated user.
+	ElevationMethod string `mapstructure:"elevation_method"`
+
 	// Valid Exit Codes - 0 is not always the only valid error code!
 	// See http://www.symantec.com/connect/articles/windows-system-error-codes-exit-codes-description for examples
 	// such as 3010 - "The requested operation is successful. Changes will not be effective until the system is rebooted."
 	ValidExitCodes []int `mapstructure:"valid_exit_codes"`
 
+	// If true, check that the scripts will run elevated before running
+	// them, if any of them contains `#requires -RunAsAdministrator`.
+	RequireElevationCheck bool `mapstructure:"require_elevation_check"`
+
+	// A local directory of Pester tests to run after the scripts. The
+	// results are downloaded to PesterOutput, if set, in the format
+	// given by PesterOutputFormat.
+	PesterTests        string `mapstructure:"pester_tests"`
+	PesterOutput       string `mapstructure:"pester_output"`
+	PesterOutputFormat string `mapstructure:"pester_output_format"`
+
+	// MSI or EXE installers to run before the scripts.
+	Installers []Installer `mapstructure:"installers"`
+
+	// The PowerShell session configuration, such as a JEA endpoint, to
+	// run the scripts in.
+	ConfigurationName string `mapstructure:"configuration_name"`
+
+	// If true, commands are uploaded and run with -file instead of being
+	// passed with -encodedCommand, which constrained guests reject.
+	ConstrainedLanguage bool `mapstructure:"constrained_language"`
+
+	// The number of megabytes that must be free for the scripts and in
+	// the temporary directory of the remote user before uploading them.
+	MinFreeSpace int `mapstructure:"min_free_space"`
+
+	// Lines to feed to the standard input of the scripts, such as the
+	// answers to the prompts of an installer.
+	Answers []string `mapstructure:"answers"`
+
+	// If true, the directories scripts are uploaded to are excluded from
+	// Windows Defender scanning while provisioning.
+	DefenderExclusion bool `mapstructure:"defender_exclusion"`
+
+	// The proxies of the scripts. They are set as environment variables,
+	// and as the WinHTTP and Internet Explorer proxy of the machine while
+	// provisioning.
+	HTTPProxy  string `mapstructure:"http_proxy"`
+	HTTPSProxy string `mapstructure:"https_proxy"`
+	NoProxy    string `mapstructure:"no_proxy"`
+
+	// A path on the machine, and a PowerShell expression evaluated on the
+	// machine. If the path exists or the expression is true, the scripts
+	// were already applied and are skipped.
+	Creates string `mapstructure:"creates"`
+	Unless  string `mapstructure:"unless"`
+
+	// The $ErrorActionPreference of inline scripts, Stop by default so
+	// that they fail on the first error.
+	ErrorAction string `mapstructure:"error_action"`
+
+	// The version given to Set-StrictMode at the top of inline scripts,
+	// if any.
+	StrictMode string `mapstructure:"strict_mode"`
+
+	// If true, anything native commands of inline scripts write to their
+	// standard error fails the script.
+	FailOnStderr bool `mapstructure:"fail_on_stderr"`
+
+	// If true, the commands write their output as UTF-8, so that the
+	// output of machines with a non-English code page isn't garbled.
+	UTF8Output bool `mapstructure:"utf8_output"`
+
+	// A local path to download the recent events of the event logs of the
+	// machine to when a script fails. EventLogs are the names of the logs,
+	// which can have wildcards, and EventLogLookback how far back events
+	// are exported.
+	EventLogOutput   string        `mapstructure:"event_log_output"`
+	EventLogs        []string      `mapstructure:"event_logs"`
+	EventLogLookback time.Duration `mapstructure:"event_log_lookback"`
+
+	// The WinRM operation and receive timeouts of the commands of this
+	// provisioner, if they should be longer than the ones of the build.
+	WinRMOperationTimeout time.Duration `mapstructure:"winrm_operation_timeout"`
+	WinRMReceiveTimeout   time.Duration `mapstructure:"winrm_receive_timeout"`
+
+	// The checks run before the scripts: the maximum difference between
+	// the clocks of the machine and of the host, the hosts the machine
+	// must resolve, and the https URLs it must connect to with a trusted
+	// certificate.
+	PreflightMaxClockSkew time.Duration `mapstructure:"preflight_max_clock_skew"`
+	PreflightDNSHosts     []string      `mapstructure:"preflight_dns_hosts"`
+	PreflightTLSURLs      []string      `mapstructure:"preflight_tls_urls"`
+
 	ctx interpolate.Context
 }
 
+// inlineFilePrefix starts the inline commands that are replaced by the
+// contents of a file.
+const inlineFilePrefix = "@file:"
+
+// defaultTempDir is where files are uploaded to on guests that don't
+// publish their temporary directory.
+const defaultTempDir = `c:/Windows/Temp`
+
 type Provisioner struct {
 	config       Config
 	communicator packer.Communicator
+
+	// answersPath is where the answers were uploaded to, if any.
+	answersPath string
+
+	// defaultRemotePath is true if remote_path wasn't set, so that the
+	// script is uploaded to the temporary directory of the guest.
+	defaultRemotePath bool
+
+	// remotePath is remote_path rendered for the script being run.
+	remotePath string
+
+	// tempDir is the temporary directory published by the guest, if any.
+	tempDir string
+
+	// sequence is the number of scripts uploaded so far.
+	sequence int
+
+	// remoteDirs are the directories of remote_path that were created.
+	remoteDirs map[string]bool
+
+	// metadata are the changes to the machine the provisioner adds to
+	// the metadata of the artifact.
+	metadata packer.ArtifactMetadata
 }
 
 type ExecuteCommandTemplate struct {
-	Vars string
-	Path string
+	Vars  string
+	Path  string
+	Stdin string
+
+	// SharedFolder is where the builder mounted a folder of the host in
+	// the machine, if it shares one.
+	SharedFolder string
+}
+
+type RemotePathTemplate struct {
+	ScriptName string
+
+	// SequenceNumber counts the scripts uploaded by the provisioner,
+	// starting at 1.
+	SequenceNumber int
 }
 
 func (p *Provisioner) Prepare(raws ...interface{}) error {
@@ -103,8 +252,10 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 			Exclude: []string{
 				"execute_command",
 				"elevated_execute_command",
+				"remote_path",
 			},
 		},
+		Deprecations: deprecations,
 	}, raws...)
 
 	if err != nil {
@@ -119,12 +270,22 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		p.config.ElevatedEnvVarFormat = `$env:%s="%s"; `
 	}
 
+	// With answers the script runs in a new powershell process whose
+	// standard input is redirected from the uploaded answers.
 	if p.config.ExecuteCommand == "" {
-		p.config.ExecuteCommand = `if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'};{{.Vars}}&'{{.Path}}';exit $LastExitCode`
+		if len(p.config.Answers) > 0 {
+			p.config.ExecuteCommand = `if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'};{{.Vars}}cmd /c 'powershell -executionpolicy bypass -file "{{.Path}}" < "{{.Stdin}}"';exit $LastExitCode`
+		} else {
+			p.config.ExecuteCommand = `if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'};{{.Vars}}&'{{.Path}}';exit $LastExitCode`
+		}
 	}
 
 	if p.config.ElevatedExecuteCommand == "" {
-		p.config.ElevatedExecuteCommand = `if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'}; . {{.Vars}}; &'{{.Path}}'; exit $LastExitCode`
+		if len(p.config.Answers) > 0 {
+			p.config.ElevatedExecuteCommand = `if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'}; . {{.Vars}}; cmd /c 'powershell -executionpolicy bypass -file "{{.Path}}" < "{{.Stdin}}"'; exit $LastExitCode`
+		} else {
+			p.config.ElevatedExecuteCommand = `if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'}; . {{.Vars}}; &'{{.Path}}'; exit $LastExitCode`
+		}
 	}
 
 	if p.config.Inline != nil && len(p.config.Inline) == 0 {
@@ -135,9 +296,23 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		p.config.StartRetryTimeout = 5 * time.Minute
 	}
 
+	// Scripts uploaded to a directory keep their names
+	if strings.HasSuffix(p.config.RemotePath, "/") || strings.HasSuffix(p.config.RemotePath, `\`) {
+		p.config.RemotePath += "{{.ScriptName}}.ps1"
+	}
+
+	// The default remote path is unique to each upload of the provisioner,
+	// even if parallel builds upload their scripts to shared storage, and
+	// if it runs a script again, when retried or rerun.
 	if p.config.RemotePath == "" {
-		uuid := uuid.TimeOrderedUUID()
-		p.config.RemotePath = fmt.Sprintf(`c:/Windows/Temp/script-%s.ps1`, uuid)
+		name := []string{"script"}
+		if p.config.PackerBuildName != "" {
+			name = append(name, safeFileName(p.config.PackerBuildName))
+		}
+		name = append(name, strconv.Itoa(os.Getpid()), uuid.TimeOrderedUUID(),
+			"{{.SequenceNumber}}", "{{.ScriptName}}")
+		p.config.RemotePath = fmt.Sprintf(`%s/%s.ps1`, defaultTempDir, strings.Join(name, "-"))
+		p.defaultRemotePath = true
 	}
 
 	if p.config.Scripts == nil {
@@ -152,6 +327,26 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		p.config.ValidExitCodes = []int{0}
 	}
 
+	if p.config.ElevationMethod == "" {
+		p.config.ElevationMethod = ElevationScheduledTask
+	}
+
+	if p.config.ErrorAction == "" {
+		p.config.ErrorAction = "Stop"
+	}
+
+	if p.config.PesterOutputFormat == "" {
+		p.config.PesterOutputFormat = PesterOutputFormatNUnit
+	}
+
+	if len(p.config.EventLogs) == 0 {
+		p.config.EventLogs = defaultEventLogs
+	}
+
+	if p.config.EventLogLookback == 0 {
+		p.config.EventLogLookback = time.Hour
+	}
+
 	var errs error
 	if p.config.Script != "" && len(p.config.Scripts) > 0 {
 		errs = packer.MultiErrorAppend(errs,
@@ -168,25 +363,136 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 			errors.New("Must supply an 'elevated_user' if 'elevated_password' provided"))
 	}
 
+	if p.remotePath, err = p.renderRemotePath("script"); err != nil {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("Error parsing remote_path: %s", err))
+	}
+
+	switch p.config.ErrorAction {
+	case "Stop", "Continue", "SilentlyContinue", "Ignore":
+	default:
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("error_action must be one of Stop, Continue, SilentlyContinue or Ignore"))
+	}
+
+	if p.config.StrictMode != "" && p.config.StrictMode != "Latest" {
+		if _, err := strconv.ParseFloat(p.config.StrictMode, 64); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("strict_mode must be Latest or a version like 2.0"))
+		}
+	}
+
+	if p.config.WinRMOperationTimeout < 0 || p.config.WinRMReceiveTimeout < 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("winrm_operation_timeout and winrm_receive_timeout must not be negative"))
+	}
+
+	if p.config.EventLogLookback < 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("event_log_lookback must not be negative"))
+	}
+
+	for _, err := range []error{
+		checkProxy("http_proxy", p.config.HTTPProxy),
+		checkProxy("https_proxy", p.config.HTTPSProxy),
+	} {
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if p.config.MinFreeSpace < 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("min_free_space must not be negative"))
+	}
+
+	if preflightErrs := p.checkPreflightConfig(); len(preflightErrs) > 0 {
+		errs = packer.MultiErrorAppend(errs, preflightErrs...)
+	}
+
+	switch p.config.ElevationMethod {
+	case ElevationScheduledTask, ElevationRunAsProcess:
+	case ElevationPsExecSystem:
+		if p.config.ElevatedUser != "" {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("'elevated_user' can't be used with the psexec_system elevation method, it runs as SYSTEM"))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"elevation_method must be one of %s, %s or %s",
+			ElevationScheduledTask, ElevationRunAsProcess, ElevationPsExecSystem))
+	}
+
+	if p.elevated() && (p.config.ConfigurationName != "" || p.config.ConstrainedLanguage) {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("Elevated execution can't be used with 'configuration_name' or 'constrained_language'"))
+	}
+
+	// Constrained language mode doesn't allow setting the encoding, nor the
+	// .NET calls of the scripts the other options run
+	if p.config.ConstrainedLanguage {
+		for _, option := range p.fullLanguageOptions() {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("%s can't be used with 'constrained_language'", option))
+		}
+	}
+
 	if p.config.Script != "" {
 		p.config.Scripts = []string{p.config.Script}
 	}
 
-	if len(p.config.Scripts) == 0 && p.config.Inline == nil {
+	if len(p.config.Scripts) == 0 && p.config.Inline == nil && p.config.PesterTests == "" && len(p.config.Installers) == 0 {
 		errs = packer.MultiErrorAppend(errs,
-			errors.New("Either a script file or inline script must be specified."))
+			errors.New("Either a script file, inline script, installers or pester_tests must be specified."))
 	} else if len(p.config.Scripts) > 0 && p.config.Inline != nil {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("Only a script file or an inline script can be specified, not both."))
 	}
 
-	for _, path := range p.config.Scripts {
-		if _, err := os.Stat(path); err != nil {
+	scripts, scriptErrs := expandScripts(p.config.Scripts)
+	if len(scriptErrs) > 0 {
+		errs = packer.MultiErrorAppend(errs, scriptErrs...)
+	}
+	p.config.Scripts = scripts
+
+	for i, command := range p.config.Inline {
+		if !strings.HasPrefix(command, inlineFilePrefix) {
+			continue
+		}
+		path := strings.TrimPrefix(command, inlineFilePrefix)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
 			errs = packer.MultiErrorAppend(errs,
-				fmt.Errorf("Bad script '%s': %s", path, err))
+				fmt.Errorf("Bad inline file '%s': %s", path, err))
+			continue
+		}
+		p.config.Inline[i] = strings.TrimRight(string(contents), "\r\n")
+	}
+
+	for i := range p.config.Installers {
+		if ierrs := p.config.Installers[i].prepare(i); len(ierrs) > 0 {
+			errs = packer.MultiErrorAppend(errs, ierrs...)
+		}
+	}
+
+	if p.config.PesterTests != "" {
+		if fi, err := os.Stat(p.config.PesterTests); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad pester_tests '%s': %s", p.config.PesterTests, err))
+		} else if !fi.IsDir() {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("pester_tests must be a directory: %s", p.config.PesterTests))
 		}
 	}
 
+	switch p.config.PesterOutputFormat {
+	case PesterOutputFormatNUnit, PesterOutputFormatJUnit:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"pester_output_format must be '%s' or '%s'",
+			PesterOutputFormatNUnit, PesterOutputFormatJUnit))
+	}
+
 	// Do a check for bad environment variables, such as '=foo', 'foobar'
 	for _, kv := range p.config.Vars {
 		vs := strings.SplitN(kv, "=", 2)
@@ -207,18 +513,26 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 // into a temporary file and returns a string containing the location
 // of said file.
 func extractScript(p *Provisioner) (string, error) {
-	temp, err := ioutil.TempFile(os.TempDir(), "packer-powershell-provisioner")
+	temp, err := ioutil.TempFile(p.config.PackerTempDir, "packer-powershell-provisioner")
 	if err != nil {
 		return "", err
 	}
 	defer temp.Close()
 	writer := bufio.NewWriter(temp)
+	if _, err := writer.WriteString(p.inlinePrelude()); err != nil {
+		return "", fmt.Errorf("Error preparing powershell script: %s", err)
+	}
 	for _, command := range p.config.Inline {
 		log.Printf("Found command: %s", command)
 		if _, err := writer.WriteString(command + "\n"); err != nil {
 			return "", fmt.Errorf("Error preparing powershell script: %s", err)
 		}
 	}
+	if p.config.FailOnStderr {
+		if _, err := writer.WriteString(inlineStderrEpilogue); err != nil {
+			return "", fmt.Errorf("Error preparing powershell script: %s", err)
+		}
+	}
 
 	if err := writer.Flush(); err != nil {
 		return "", fmt.Errorf("Error preparing powershell script: %s", err)
@@ -227,23 +541,216 @@ func extractScript(p *Provisioner) (string, error) {
 	return temp.Name(), nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+// inlinePrelude returns the lines put before the inline commands. With
+// Stop, a terminating error makes the script exit with 1, so that it fails
+// whatever the execute_command does afterwards. With fail_on_stderr the
+// commands run in a script block whose error records, including the
+// standard error of native commands, are thrown.
+func (p *Provisioner) inlinePrelude() string {
+	prelude := fmt.Sprintf("$ErrorActionPreference = '%s'\n", p.config.ErrorAction)
+	if p.config.StrictMode != "" {
+		prelude += fmt.Sprintf("Set-StrictMode -Version %s\n", p.config.StrictMode)
+	}
+	if p.config.ErrorAction == "Stop" || p.config.FailOnStderr {
+		prelude += "trap { Write-Error -ErrorRecord $_ -ErrorAction Continue; exit 1 }\n"
+	}
+	if p.config.FailOnStderr {
+		prelude += "& {\n"
+	}
+	return prelude
+}
+
+// inlineStderrEpilogue closes the script block opened by the prelude with
+// fail_on_stderr.
+const inlineStderrEpilogue = `} 2>&1 | ForEach-Object {
+  if ($_ -is [System.Management.Automation.ErrorRecord]) { throw $_ }
+  $_
+}
+`
+
+// expandScripts expands the glob patterns of the scripts to the files they
+// match, sorted, so that numbered scripts run in order. A pattern must
+// match at least one file.
+func expandScripts(paths []string) ([]string, []error) {
+	var errs []error
+	scripts := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "*?[") {
+			if _, err := os.Stat(path); err != nil {
+				errs = append(errs, fmt.Errorf("Bad script '%s': %s", path, err))
+			}
+			scripts = append(scripts, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Bad script pattern '%s': %s", path, err))
+			continue
+		}
+
+		var files []string
+		for _, match := range matches {
+			if fi, err := os.Stat(match); err == nil && !fi.IsDir() {
+				files = append(files, match)
+			}
+		}
+		if len(files) == 0 {
+			errs = append(errs, fmt.Errorf("Script pattern '%s' doesn't match any file", path))
+			continue
+		}
+
+		sort.Strings(files)
+		scripts = append(scripts, files...)
+	}
+
+	return scripts, errs
+}
+
+// Validate checks that the scripts can be read and, when PowerShell is
+// available locally, that they parse.
+func (p *Provisioner) Validate(ctx *packer.ValidateContext) error {
+	var errs *packer.MultiError
+	if p.config.Inline != nil {
+		path, err := extractScript(p)
+		if err == nil {
+			err = checkSyntax(path)
+			os.Remove(path)
+		}
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Inline script doesn't parse: %s", err))
+		}
+	}
+
+	for _, path := range p.config.Scripts {
+		f, err := os.Open(path)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad script '%s': %s", path, err))
+			continue
+		}
+		f.Close()
+
+		if err := checkSyntax(path); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Script '%s' doesn't parse: %s", path, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) (err error) {
+	if p.config.UTF8Output {
+		ui = &utf8Ui{Ui: ui}
+	}
 	ui.Say(fmt.Sprintf("Provisioning with Powershell..."))
 	p.communicator = comm
 
+	if err := p.useGuestFacts(packer.GuestFactsOf(comm)); err != nil {
+		return err
+	}
+
+	if applied, err := p.alreadyApplied(ctx, ui, comm); err != nil {
+		return err
+	} else if applied {
+		ui.Say("The scripts were already applied, skipping them.")
+		return nil
+	}
+
 	scripts := make([]string, len(p.config.Scripts))
 	copy(scripts, p.config.Scripts)
 
+	var inline string
 	if p.config.Inline != nil {
 		temp, err := extractScript(p)
 		if err != nil {
 			ui.Error(fmt.Sprintf("Unable to extract inline scripts into a file: %s", err))
 		}
 		scripts = append(scripts, temp)
+		inline = temp
+	}
+
+	// Every other script runs elevated with PsExec
+	if p.config.ElevationMethod == ElevationPsExecSystem {
+		if err := p.checkPsExec(ctx, ui, comm); err != nil {
+			return err
+		}
+	}
+
+	if p.config.MinFreeSpace > 0 {
+		if err := p.checkFreeSpace(ctx, ui, comm); err != nil {
+			return err
+		}
+	}
+
+	if len(p.config.Answers) > 0 {
+		if err := p.uploadAnswers(comm); err != nil {
+			return err
+		}
+	}
+
+	if p.config.DefenderExclusion {
+		var remove func() error
+		if remove, err = p.addDefenderExclusions(ctx, ui, comm); err != nil {
+			return err
+		}
+
+		// Always remove the exclusions, but don't hide a provisioning
+		// error behind an error removing them.
+		defer func() {
+			if rerr := remove(); rerr != nil {
+				if err == nil {
+					err = rerr
+				} else {
+					ui.Error(rerr.Error())
+				}
+			}
+		}()
+	}
+
+	if p.config.HTTPProxy != "" || p.config.HTTPSProxy != "" {
+		var restore func() error
+		if restore, err = p.setProxy(ctx, ui, comm); err != nil {
+			return err
+		}
+
+		defer func() {
+			if rerr := restore(); rerr != nil {
+				if err == nil {
+					err = rerr
+				} else {
+					ui.Error(rerr.Error())
+				}
+			}
+		}()
+	}
+
+	if p.hasPreflight() {
+		if err := p.preflight(ctx, ui, comm); err != nil {
+			return err
+		}
+	}
+
+	if p.config.RequireElevationCheck {
+		if err := p.checkElevation(ctx, ui, comm, scripts); err != nil {
+			return err
+		}
+	}
+
+	if err := p.runInstallers(ctx, ui, comm); err != nil {
+		return err
 	}
 
 	for _, path := range scripts {
 		ui.Say(fmt.Sprintf("Provisioning with powershell script: %s", path))
+		// Failed scripts end with the provisioner in the timing report
+		endTiming := packer.StartTiming(ui, "script", path)
 
 		log.Printf("Opening %s for reading", path)
 		f, err := os.Open(path)
@@ -252,6 +759,20 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		}
 		defer f.Close()
 
+		// The size of the script is reported with the progress of its upload
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("Error stating powershell script: %s", err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if path == inline {
+			name = "inline"
+		}
+		if err := p.useRemotePath(ctx, ui, comm, name); err != nil {
+			return err
+		}
+
 		command, err := p.createCommandText()
 		if err != nil {
 			return fmt.Errorf("Error processing command: %s", err)
@@ -267,12 +788,12 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 			if _, err := f.Seek(0, 0); err != nil {
 				return err
 			}
-			if err := comm.Upload(p.config.RemotePath, f, nil); err != nil {
+			if err := comm.Upload(p.remotePath, f, &fi); err != nil {
 				return fmt.Errorf("Error uploading script: %s", err)
 			}
 
-			cmd = &packer.RemoteCmd{Command: command}
-			return cmd.StartWithUi(comm, ui)
+			cmd = p.newRemoteCmd(command)
+			return cmd.RunWithUi(ctx, comm, ui)
 		})
 		if err != nil {
 			return err
@@ -281,6 +802,15 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		// Close the original file since we copied it
 		f.Close()
 
+		// The command, or the elevated runner polling its scheduled task,
+		// kept running on the machine while the communicator reconnected,
+		// so only output can be missing.
+		if cmd.Reconnects > 0 {
+			ui.Message(fmt.Sprintf(
+				"The connection to the machine was re-established %d time(s) while "+
+					"the script ran, some of its output may be missing.", cmd.Reconnects))
+		}
+
 		// Check exit code against allowed codes (likely just 0)
 		validExitCode := false
 		for _, v := range p.config.ValidExitCodes {
@@ -289,19 +819,228 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 			}
 		}
 		if !validExitCode {
+			if p.config.EventLogOutput != "" {
+				p.exportEventLogs(ctx, ui, comm)
+			}
 			return fmt.Errorf(
 				"Script exited with non-zero exit status: %d. Allowed exit codes are: %v",
 				cmd.ExitStatus, p.config.ValidExitCodes)
 		}
+
+		endTiming(nil)
+	}
+
+	if p.config.PesterTests != "" {
+		return p.runPester(ctx, ui, comm)
 	}
 
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
+// useGuestFacts refuses guests that are known not to run Windows, and
+// uploads files to the temporary directory of the guest if it is known.
+func (p *Provisioner) useGuestFacts(facts *packer.GuestFacts) error {
+	if facts.OSFamily != "" && !facts.IsWindows() {
+		return fmt.Errorf(
+			"The powershell provisioner only supports Windows guests, but this guest runs %s. "+
+				"Use the shell provisioner for it instead.", facts.OSFamily)
+	}
+
+	p.tempDir = strings.TrimRight(strings.Replace(facts.TempDir, `\`, "/", -1), "/")
+	if p.defaultRemotePath {
+		p.config.RemotePath = fmt.Sprintf("%s/%s", p.remoteTempDir(), path.Base(p.config.RemotePath))
+	}
+	return nil
+}
+
+// renderRemotePath renders remote_path for the script with the given name.
+func (p *Provisioner) renderRemotePath(name string) (string, error) {
+	ctx := p.config.ctx
+	ctx.Data = &RemotePathTemplate{
+		ScriptName:     safeFileName(name),
+		SequenceNumber: p.sequence,
+	}
+	return interpolate.Render(p.config.RemotePath, &ctx)
+}
+
+// useRemotePath sets the path the script with the given name is uploaded
+// to. The default remote path is unique, so a file that is already there
+// belongs to another build sharing the storage of the guest and the
+// provisioner refuses to overwrite it. The directory of other remote paths
+// is created if it doesn't exist.
+func (p *Provisioner) useRemotePath(ctx context.Context, ui packer.Ui, comm packer.Communicator, name string) error {
+	p.sequence++
+	remotePath, err := p.renderRemotePath(name)
+	if err != nil {
+		return fmt.Errorf("Error processing remote_path: %s", err)
+	}
+	p.remotePath = remotePath
+
+	if !p.defaultRemotePath {
+		p.createRemoteDir(ctx, ui, comm, remotePath)
+		return nil
+	}
+
+	command, err := p.generateCommandLineRunner(fmt.Sprintf(
+		"if (Test-Path -LiteralPath '%s') { exit 1 }; exit 0", strings.Replace(remotePath, "'", "''", -1)))
+	if err != nil {
+		return fmt.Errorf("Error generating command line runner: %s", err)
+	}
+
+	var cmd *packer.RemoteCmd
+	err = p.retryable(func() error {
+		cmd = p.newRemoteCmd(command)
+		return cmd.RunWithUi(ctx, comm, ui)
+	})
+	if err != nil {
+		return fmt.Errorf("Error checking remote path %s: %s", remotePath, err)
+	}
+	if cmd.ExitStatus == 1 {
+		return fmt.Errorf(
+			"The remote path %s is already in use, probably by another build sharing "+
+				"the storage of this machine. Set remote_path to a unique path.", remotePath)
+	}
+	return nil
+}
+
+// createRemoteDir creates the directory of the remote path, unless it was
+// created for a previous script. Failing to create it is only logged, the
+// upload then fails with the error of the communicator.
+func (p *Provisioner) createRemoteDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, remotePath string) {
+	dir := path.Dir(strings.Replace(remotePath, `\`, "/", -1))
+	if dir == "." || dir == "/" || strings.HasSuffix(dir, ":") || p.remoteDirs[dir] {
+		return
+	}
+
+	command, err := p.generateCommandLineRunner(fmt.Sprintf(
+		"New-Item -ItemType Directory -Force -Path '%s' | Out-Null", strings.Replace(dir, "'", "''", -1)))
+	if err != nil {
+		log.Printf("Error generating command line runner: %s", err)
+		return
+	}
+
+	var cmd *packer.RemoteCmd
+	err = p.retryable(func() error {
+		cmd = p.newRemoteCmd(command)
+		return cmd.RunWithUi(ctx, comm, ui)
+	})
+	if err != nil || cmd.ExitStatus != 0 {
+		log.Printf("Error creating the directory %s of remote_path: %v", dir, err)
+		return
+	}
+
+	if p.remoteDirs == nil {
+		p.remoteDirs = make(map[string]bool)
+	}
+	p.remoteDirs[dir] = true
+}
+
+// safeFileName replaces the characters of s that aren't safe in a file
+// name on Windows.
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// remoteTempDir returns the temporary directory on the guest, with forward
+// slashes.
+func (p *Provisioner) remoteTempDir() string {
+	if p.tempDir != "" {
+		return p.tempDir
+	}
+	return defaultTempDir
+}
+
+// uploadAnswers uploads the answers that are redirected to the standard
+// input of the scripts.
+func (p *Provisioner) uploadAnswers(comm packer.Communicator) error {
+	p.answersPath = strings.Replace(
+		fmt.Sprintf(`%s/packer-answers-%s.txt`, p.remoteTempDir(), uuid.TimeOrderedUUID()), "/", `\`, -1)
+	answers := strings.Join(p.config.Answers, "\r\n") + "\r\n"
+
+	log.Printf("Uploading answers to %s", p.answersPath)
+	if err := comm.Upload(p.answersPath, strings.NewReader(answers), nil); err != nil {
+		return fmt.Errorf("Error uploading answers: %s", err)
+	}
+
+	return nil
+}
+
+// runScript uploads the script to remote_path, using name as its script
+// name, and runs it the same way as the configured scripts.
+func (p *Provisioner) runScript(ctx context.Context, ui packer.Ui, comm packer.Communicator, name string, script string) (*packer.RemoteCmd, error) {
+	return p.runScriptOutput(ctx, ui, comm, name, script, nil)
+}
+
+// runScriptOutput is runScript, also writing the standard output of the
+// script to stdout, if not nil.
+func (p *Provisioner) runScriptOutput(ctx context.Context, ui packer.Ui, comm packer.Communicator, name string, script string, stdout *bytes.Buffer) (*packer.RemoteCmd, error) {
+	if err := p.useRemotePath(ctx, ui, comm, name); err != nil {
+		return nil, err
+	}
+
+	command, err := p.createCommandText()
+	if err != nil {
+		return nil, fmt.Errorf("Error processing command: %s", err)
+	}
+
+	var cmd *packer.RemoteCmd
+	err = p.retryable(func() error {
+		if err := comm.Upload(p.remotePath, strings.NewReader(script), nil); err != nil {
+			return fmt.Errorf("Error uploading script: %s", err)
+		}
+
+		cmd = p.newRemoteCmd(command)
+		if stdout != nil {
+			// Only the output of the last attempt
+			stdout.Reset()
+			cmd.Stdout = stdout
+		}
+		return cmd.RunWithUi(ctx, comm, ui)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// Metadata returns the changes to the machine to record with the
+// artifact, like the Windows Defender exclusions that were added as
+// "powershell.defender_exclusions".
+func (p *Provisioner) Metadata() packer.ArtifactMetadata {
+	m := make(packer.ArtifactMetadata)
+	m.Merge(p.metadata)
+	return m
+}
+
+// addMetadata adds the value to the key of the metadata, once.
+func (p *Provisioner) addMetadata(key, value string) {
+	if p.metadata == nil {
+		p.metadata = make(packer.ArtifactMetadata)
+	}
+	for _, v := range p.metadata[key] {
+		if v == value {
+			return
+		}
+	}
+	p.metadata.Add(key, value)
+}
+
+// newRemoteCmd returns a command with the timeouts of this provisioner.
+func (p *Provisioner) newRemoteCmd(command string) *packer.RemoteCmd {
+	return &packer.RemoteCmd{
+		Command:          command,
+		OperationTimeout: p.config.WinRMOperationTimeout,
+		ReceiveTimeout:   p.config.WinRMReceiveTimeout,
+	}
 }
 
 // retryable will retry the given function over and over until a
@@ -314,6 +1053,11 @@ func (p *Provisioner) retryable(f func() error) error {
 			return nil
 		}
 
+		// Commands of cancelled provisioners aren't retried
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+
 		// Create an error and log it
 		err = fmt.Errorf("Retryable error: %s", err)
 		log.Print(err.Error())
@@ -337,9 +1081,14 @@ func (p *Provisioner) createFlattenedEnvVars(elevated bool) (flattened string) {
 	// Always available Packer provided env vars
 	envVars["PACKER_BUILD_NAME"] = p.config.PackerBuildName
 	envVars["PACKER_BUILDER_TYPE"] = p.config.PackerBuilderType
-	httpAddr := common.GetHTTPAddr()
-	if httpAddr != "" {
-		envVars["PACKER_HTTP_ADDR"] = httpAddr
+	for k, v := range common.GetHTTPEnvVars() {
+		envVars[k] = v
+	}
+	if path := common.GetSharedFolderPath(); path != "" {
+		envVars["PACKER_SHARED_FOLDER"] = path
+	}
+	for k, v := range p.proxyEnvVars() {
+		envVars[k] = v
 	}
 
 	// Split vars into key/value components
@@ -366,9 +1115,40 @@ func (p *Provisioner) createFlattenedEnvVars(elevated bool) (flattened string) {
 	return
 }
 
+// fullLanguageOptions returns the options that are set and run scripts
+// calling .NET, which constrained language mode blocks. The expression of
+// unless is the user's, it only needs to respect the language mode.
+func (p *Provisioner) fullLanguageOptions() []string {
+	var options []string
+	if p.config.UTF8Output {
+		options = append(options, "utf8_output")
+	}
+	if p.config.Creates != "" {
+		options = append(options, "creates")
+	}
+	if p.config.MinFreeSpace > 0 {
+		options = append(options, "min_free_space")
+	}
+	if p.config.RequireElevationCheck {
+		options = append(options, "require_elevation_check")
+	}
+	if p.config.DefenderExclusion {
+		options = append(options, "defender_exclusion")
+	}
+	if p.hasPreflight() {
+		options = append(options, "the preflight checks")
+	}
+	return options
+}
+
+// elevated says whether or not commands are run elevated.
+func (p *Provisioner) elevated() bool {
+	return p.config.ElevatedUser != "" || p.config.ElevationMethod == ElevationPsExecSystem
+}
+
 func (p *Provisioner) createCommandText() (command string, err error) {
 	// Return the interpolated command
-	if p.config.ElevatedUser == "" {
+	if !p.elevated() {
 		return p.createCommandTextNonPrivileged()
 	} else {
 		return p.createCommandTextPrivileged()
@@ -380,8 +1160,10 @@ func (p *Provisioner) createCommandTextNonPrivileged() (command string, err erro
 	flattenedEnvVars := p.createFlattenedEnvVars(false)
 
 	p.config.ctx.Data = &ExecuteCommandTemplate{
-		Vars: flattenedEnvVars,
-		Path: p.config.RemotePath,
+		Vars:         flattenedEnvVars,
+		Path:         p.remotePath,
+		Stdin:        p.answersPath,
+		SharedFolder: common.GetSharedFolderPath(),
 	}
 	command, err = interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
 
@@ -399,6 +1181,12 @@ func (p *Provisioner) createCommandTextNonPrivileged() (command string, err erro
 
 func (p *Provisioner) generateCommandLineRunner(command string) (commandText string, err error) {
 	log.Printf("Building command line for: %s", command)
+	command = p.utf8Command(command)
+
+	// Constrained guests reject -encodedCommand, so run a script instead
+	if p.config.ConstrainedLanguage || p.config.ConfigurationName != "" {
+		return p.generateFileRunner(command)
+	}
 
 	base64EncodedCommand, err := powershellEncode(command)
 	if err != nil {
@@ -411,22 +1199,16 @@ func (p *Provisioner) generateCommandLineRunner(command string) (commandText str
 }
 
 func (p *Provisioner) createCommandTextPrivileged() (command string, err error) {
-	// Can't double escape the env vars, lets create shiny new ones
-	flattenedEnvVars := p.createFlattenedEnvVars(true)
-	// Need to create a mini ps1 script containing all of the environment variables we want;
-	// we'll be dot-sourcing this later
-	envVarReader := strings.NewReader(flattenedEnvVars)
-	uuid := uuid.TimeOrderedUUID()
-	envVarPath := fmt.Sprintf(`${env:TEMP}\packer-env-vars-%s.ps1`, uuid)
-	log.Printf("Uploading env vars to %s", envVarPath)
-	err = p.communicator.Upload(envVarPath, envVarReader, nil)
-	if err != nil {
-		return "", fmt.Errorf("Error preparing elevated powershell script: %s", err)
-	}
+	// Can't double escape the env vars, lets create shiny new ones. They
+	// are set by a script block that the command dot-sources, so they end
+	// up encoded in the elevated runner instead of in a file of their own.
+	envVarBlock := "{" + p.createFlattenedEnvVars(true) + "}"
 
 	p.config.ctx.Data = &ExecuteCommandTemplate{
-		Path: p.config.RemotePath,
-		Vars: envVarPath,
+		Path:         p.remotePath,
+		Vars:         envVarBlock,
+		Stdin:        p.answersPath,
+		SharedFolder: common.GetSharedFolderPath(),
 	}
 	command, err = interpolate.Render(p.config.ElevatedExecuteCommand, &p.config.ctx)
 	if err != nil {
@@ -448,6 +1230,7 @@ func (p *Provisioner) createCommandTextPrivileged() (command string, err error)
 
 func (p *Provisioner) generateElevatedRunner(command string) (uploadedPath string, err error) {
 	log.Printf("Building elevated command wrapper for: %s", command)
+	command = p.utf8Command(command)
 
 	// generate command
 	var buffer bytes.Buffer
@@ -457,9 +1240,21 @@ func (p *Provisioner) generateElevatedRunner(command string) (uploadedPath strin
 		return "", fmt.Errorf("Error encoding command: %s", err)
 	}
 
-	err = elevatedTemplate.Execute(&buffer, elevatedOptions{
-		User:            p.config.ElevatedUser,
-		Password:        p.config.ElevatedPassword,
+	tpl := elevatedTemplate
+	user := p.config.ElevatedUser
+	password := p.config.ElevatedPassword
+	switch p.config.ElevationMethod {
+	case ElevationRunAsProcess:
+		tpl = runAsProcessTemplate
+		user = strings.Replace(user, "'", "''", -1)
+		password = strings.Replace(password, "'", "''", -1)
+	case ElevationPsExecSystem:
+		tpl = psExecSystemTemplate
+	}
+
+	err = tpl.Execute(&buffer, elevatedOptions{
+		User:            user,
+		Password:        password,
 		TaskDescription: "Packer elevated task",
 		TaskName:        fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID()),
 		EncodedCommand:  base64EncodedCommand,
@@ -472,7 +1267,10 @@ func (p *Provisioner) generateElevatedRunner(command string) (uploadedPath strin
 	uuid := uuid.TimeOrderedUUID()
 	path := fmt.Sprintf(`${env:TEMP}\packer-elevated-shell-%s.ps1`, uuid)
 	log.Printf("Uploading elevated shell wrapper for command [%s] to [%s]", command, path)
-	err = p.communicator.Upload(path, &buffer, nil)
+
+	// The wrapper writes the output of the elevated command, so it needs
+	// the output encoding as well
+	err = p.communicator.Upload(path, strings.NewReader(p.utf8Command(buffer.String())), nil)
 	if err != nil {
 		return "", fmt.Errorf("Error preparing elevated powershell script: %s", err)
 	}
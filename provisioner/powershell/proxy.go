@@ -0,0 +1,162 @@
+package powershell
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+)
+
+type proxyOptions struct {
+	Server    string
+	Bypass    string
+	StatePath string
+}
+
+// proxySetTemplate saves the WinHTTP proxy and the Internet Explorer
+// proxy of the user running the scripts, then sets both.
+var proxySetTemplate = template.Must(template.New("ProxySet").Parse(`$ErrorActionPreference = 'Stop'
+$winhttp = 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Internet Settings\Connections'
+$ie = 'HKCU:\Software\Microsoft\Windows\CurrentVersion\Internet Settings'
+$saved = @{}
+$saved['WinHttpSettings'] = (Get-ItemProperty -Path $winhttp -Name WinHttpSettings -ErrorAction SilentlyContinue).WinHttpSettings
+foreach ($name in 'ProxyEnable', 'ProxyServer', 'ProxyOverride') {
+  $saved[$name] = (Get-ItemProperty -Path $ie -Name $name -ErrorAction SilentlyContinue).$name
+}
+$saved | Export-Clixml -Path '{{.StatePath}}'
+netsh winhttp set proxy proxy-server="{{.Server}}"{{if .Bypass}} bypass-list="{{.Bypass}}"{{end}} | Out-Null
+if ($LASTEXITCODE -ne 0) {
+  Write-Output 'Setting the WinHTTP proxy failed'
+  exit $LASTEXITCODE
+}
+New-ItemProperty -Path $ie -Name ProxyEnable -Value 1 -PropertyType DWord -Force | Out-Null
+New-ItemProperty -Path $ie -Name ProxyServer -Value '{{.Server}}' -PropertyType String -Force | Out-Null
+{{if .Bypass}}New-ItemProperty -Path $ie -Name ProxyOverride -Value '{{.Bypass}}' -PropertyType String -Force | Out-Null
+{{else}}Remove-ItemProperty -Path $ie -Name ProxyOverride -ErrorAction SilentlyContinue
+{{end}}Write-Output 'Set the WinHTTP and Internet Explorer proxy to {{.Server}}'
+exit 0
+`))
+
+// proxyRestoreTemplate restores the saved proxy settings.
+var proxyRestoreTemplate = template.Must(template.New("ProxyRestore").Parse(`$ErrorActionPreference = 'Stop'
+if (-not (Test-Path '{{.StatePath}}')) {
+  exit 0
+}
+$winhttp = 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Internet Settings\Connections'
+$ie = 'HKCU:\Software\Microsoft\Windows\CurrentVersion\Internet Settings'
+$saved = Import-Clixml -Path '{{.StatePath}}'
+if ($saved['WinHttpSettings']) {
+  New-ItemProperty -Path $winhttp -Name WinHttpSettings -Value ([byte[]]$saved['WinHttpSettings']) -PropertyType Binary -Force | Out-Null
+} else {
+  netsh winhttp reset proxy | Out-Null
+}
+foreach ($name in 'ProxyEnable', 'ProxyServer', 'ProxyOverride') {
+  if ($null -eq $saved[$name]) {
+    Remove-ItemProperty -Path $ie -Name $name -ErrorAction SilentlyContinue
+  } elseif ($name -eq 'ProxyEnable') {
+    New-ItemProperty -Path $ie -Name $name -Value $saved[$name] -PropertyType DWord -Force | Out-Null
+  } else {
+    New-ItemProperty -Path $ie -Name $name -Value $saved[$name] -PropertyType String -Force | Out-Null
+  }
+}
+Remove-Item -Path '{{.StatePath}}'
+Write-Output 'Restored the WinHTTP and Internet Explorer proxy'
+exit 0
+`))
+
+// proxyEnvVars returns the environment variables of the proxies.
+func (p *Provisioner) proxyEnvVars() map[string]string {
+	envVars := make(map[string]string)
+	if p.config.HTTPProxy != "" {
+		envVars["HTTP_PROXY"] = p.config.HTTPProxy
+	}
+	if p.config.HTTPSProxy != "" {
+		envVars["HTTPS_PROXY"] = p.config.HTTPSProxy
+	}
+	if p.config.NoProxy != "" {
+		envVars["NO_PROXY"] = p.config.NoProxy
+	}
+	return envVars
+}
+
+// checkProxy checks that a proxy is a URL like http://proxy:3128.
+func checkProxy(key, proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%s must be a URL like http://proxy:3128", key)
+	}
+	return nil
+}
+
+// proxyServer returns the proxies in the format of WinHTTP and Internet
+// Explorer. They don't support credentials, which are only given to the
+// scripts in the environment variables.
+func (p *Provisioner) proxyServer() string {
+	var servers []string
+	for _, proxy := range []struct{ scheme, url string }{
+		{"http", p.config.HTTPProxy},
+		{"https", p.config.HTTPSProxy},
+	} {
+		if proxy.url == "" {
+			continue
+		}
+		// The proxies were checked in Prepare
+		u, _ := url.Parse(proxy.url)
+		servers = append(servers, fmt.Sprintf("%s=%s", proxy.scheme, u.Host))
+	}
+	return strings.Join(servers, ";")
+}
+
+// proxyBypass returns no_proxy in the format of WinHTTP and Internet
+// Explorer, with wildcards for domains like .example.com.
+func (p *Provisioner) proxyBypass() string {
+	var hosts []string
+	for _, h := range strings.Split(p.config.NoProxy, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if strings.HasPrefix(h, ".") {
+			h = "*" + h
+		}
+		hosts = append(hosts, h)
+	}
+	return strings.Join(hosts, ";")
+}
+
+// setProxy sets the WinHTTP and Internet Explorer proxy of the machine.
+// The returned function restores the previous settings.
+func (p *Provisioner) setProxy(ctx context.Context, ui packer.Ui, comm packer.Communicator) (func() error, error) {
+	opts := &proxyOptions{
+		Server: strings.Replace(p.proxyServer(), "'", "''", -1),
+		Bypass: strings.Replace(p.proxyBypass(), "'", "''", -1),
+		StatePath: fmt.Sprintf(`%s/packer-proxy-%s.xml`,
+			p.remoteTempDir(), uuid.TimeOrderedUUID()),
+	}
+
+	ui.Say("Setting the proxy of the machine for provisioning scripts...")
+	if err := p.runTemplate(ctx, ui, comm, "proxy", proxySetTemplate, opts); err != nil {
+		return nil, fmt.Errorf("Error setting the proxy: %s", err)
+	}
+
+	return func() error {
+		// The proxy is restored even if the provisioning was cancelled,
+		// so its context can't be used.
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+
+		ui.Say("Restoring the proxy of the machine...")
+		if err := p.runTemplate(ctx, ui, comm, "proxy", proxyRestoreTemplate, opts); err != nil {
+			return fmt.Errorf("Error restoring the proxy: %s", err)
+		}
+
+		return nil
+	}, nil
+}
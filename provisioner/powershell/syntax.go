@@ -0,0 +1,58 @@
+package powershell
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// syntaxCheckers are the local PowerShell executables that can parse a
+// script, in order of preference.
+var syntaxCheckers = []string{"powershell", "pwsh"}
+
+// parseScript parses the script in $env:PACKER_SYNTAX_PATH with the
+// PowerShell parser, without running it, and prints its errors.
+const parseScript = `$errors = $null
+[System.Management.Automation.Language.Parser]::ParseFile($env:PACKER_SYNTAX_PATH, [ref]$null, [ref]$errors) | Out-Null
+foreach ($e in $errors) {
+    [Console]::Error.WriteLine(('line {0}: {1}' -f $e.Extent.StartLineNumber, $e.Message))
+}
+if ($errors.Count -gt 0) { exit 1 }`
+
+// checkSyntax checks that the script at path parses, with the local
+// PowerShell. The check is skipped if PowerShell isn't available locally.
+func checkSyntax(path string) error {
+	var shell string
+	for _, name := range syntaxCheckers {
+		if p, err := exec.LookPath(name); err == nil {
+			shell = p
+			break
+		}
+	}
+	if shell == "" {
+		log.Printf("Not checking syntax of %s, PowerShell isn't available", path)
+		return nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(shell, "-NoProfile", "-NonInteractive", "-Command", parseScript)
+	cmd.Env = append(os.Environ(), "PACKER_SYNTAX_PATH="+path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@
 package puppetmasterless
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -227,10 +228,10 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	ui.Say("Provisioning with Puppet...")
 	ui.Message("Creating Puppet staging directory...")
-	if err := p.createDir(ui, comm, p.config.StagingDir); err != nil {
+	if err := p.createDir(ctx, ui, comm, p.config.StagingDir); err != nil {
 		return fmt.Errorf("Error creating staging directory: %s", err)
 	}
 
@@ -250,7 +251,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		ui.Message(fmt.Sprintf(
 			"Uploading manifest directory from: %s", p.config.ManifestDir))
 		remoteManifestDir = fmt.Sprintf("%s/manifests", p.config.StagingDir)
-		err := p.uploadDirectory(ui, comm, remoteManifestDir, p.config.ManifestDir)
+		err := p.uploadDirectory(ctx, ui, comm, remoteManifestDir, p.config.ManifestDir)
 		if err != nil {
 			return fmt.Errorf("Error uploading manifest dir: %s", err)
 		}
@@ -261,7 +262,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	for i, path := range p.config.ModulePaths {
 		ui.Message(fmt.Sprintf("Uploading local modules from: %s", path))
 		targetPath := fmt.Sprintf("%s/module-%d", p.config.StagingDir, i)
-		if err := p.uploadDirectory(ui, comm, targetPath, path); err != nil {
+		if err := p.uploadDirectory(ctx, ui, comm, targetPath, path); err != nil {
 			return fmt.Errorf("Error uploading modules: %s", err)
 		}
 
@@ -269,7 +270,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	}
 
 	// Upload manifests
-	remoteManifestFile, err := p.uploadManifests(ui, comm)
+	remoteManifestFile, err := p.uploadManifests(ctx, ui, comm)
 	if err != nil {
 		return fmt.Errorf("Error uploading manifests: %s", err)
 	}
@@ -302,7 +303,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	}
 
 	ui.Message(fmt.Sprintf("Running Puppet: %s", command))
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return fmt.Errorf("Got an error starting command: %s", err)
 	}
 
@@ -311,7 +312,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	}
 
 	if p.config.CleanStagingDir {
-		if err := p.removeDir(ui, comm, p.config.StagingDir); err != nil {
+		if err := p.removeDir(ctx, ui, comm, p.config.StagingDir); err != nil {
 			return fmt.Errorf("Error removing staging directory: %s", err)
 		}
 	}
@@ -319,12 +320,6 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
-}
-
 func (p *Provisioner) uploadHieraConfig(ui packer.Ui, comm packer.Communicator) (string, error) {
 	ui.Message("Uploading hiera configuration...")
 	f, err := os.Open(p.config.HieraConfigPath)
@@ -341,11 +336,11 @@ func (p *Provisioner) uploadHieraConfig(ui packer.Ui, comm packer.Communicator)
 	return path, nil
 }
 
-func (p *Provisioner) uploadManifests(ui packer.Ui, comm packer.Communicator) (string, error) {
+func (p *Provisioner) uploadManifests(ctx context.Context, ui packer.Ui, comm packer.Communicator) (string, error) {
 	// Create the remote manifests directory...
 	ui.Message("Uploading manifests...")
 	remoteManifestsPath := fmt.Sprintf("%s/manifests", p.config.StagingDir)
-	if err := p.createDir(ui, comm, remoteManifestsPath); err != nil {
+	if err := p.createDir(ctx, ui, comm, remoteManifestsPath); err != nil {
 		return "", fmt.Errorf("Error creating manifests directory: %s", err)
 	}
 
@@ -363,7 +358,7 @@ func (p *Provisioner) uploadManifests(ui packer.Ui, comm packer.Communicator) (s
 			"Uploading manifest directory from: %s", p.config.ManifestFile))
 
 		remoteManifestDir := fmt.Sprintf("%s/manifests", p.config.StagingDir)
-		err := p.uploadDirectory(ui, comm, remoteManifestDir, p.config.ManifestFile)
+		err := p.uploadDirectory(ctx, ui, comm, remoteManifestDir, p.config.ManifestFile)
 		if err != nil {
 			return "", fmt.Errorf("Error uploading manifest dir: %s", err)
 		}
@@ -387,12 +382,12 @@ func (p *Provisioner) uploadManifests(ui packer.Ui, comm packer.Communicator) (s
 	return remoteManifestFile, nil
 }
 
-func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir string) error {
+func (p *Provisioner) createDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dir string) error {
 	ui.Message(fmt.Sprintf("Creating directory: %s", dir))
 
 	cmd := &packer.RemoteCmd{Command: p.guestCommands.CreateDir(dir)}
 
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 
@@ -402,7 +397,7 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 
 	// Chmod the directory to 0777 just so that we can access it as our user
 	cmd = &packer.RemoteCmd{Command: p.guestCommands.Chmod(dir, "0777")}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -412,12 +407,12 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 	return nil
 }
 
-func (p *Provisioner) removeDir(ui packer.Ui, comm packer.Communicator, dir string) error {
+func (p *Provisioner) removeDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dir string) error {
 	cmd := &packer.RemoteCmd{
 		Command: fmt.Sprintf("rm -fr '%s'", dir),
 	}
 
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 
@@ -428,8 +423,8 @@ func (p *Provisioner) removeDir(ui packer.Ui, comm packer.Communicator, dir stri
 	return nil
 }
 
-func (p *Provisioner) uploadDirectory(ui packer.Ui, comm packer.Communicator, dst string, src string) error {
-	if err := p.createDir(ui, comm, dst); err != nil {
+func (p *Provisioner) uploadDirectory(ctx context.Context, ui packer.Ui, comm packer.Communicator, dst string, src string) error {
+	if err := p.createDir(ctx, ui, comm, dst); err != nil {
 		return err
 	}
 
@@ -1,6 +1,7 @@
 package puppetmasterless
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -328,7 +329,7 @@ func TestProvisionerProvision_extraArguments(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
-	err = p.Provision(ui, comm)
+	err = p.Provision(context.Background(), ui, comm)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -348,7 +349,7 @@ func TestProvisionerProvision_extraArguments(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
-	err = p.Provision(ui, comm)
+	err = p.Provision(context.Background(), ui, comm)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
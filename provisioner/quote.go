@@ -0,0 +1,13 @@
+package provisioner
+
+import "strings"
+
+// ShellQuote returns s as a single quoted shell word.
+func ShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// PowerShellQuote returns s as a single quoted PowerShell string.
+func PowerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,37 @@
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"":            "''",
+		"foo bar":     "'foo bar'",
+		"it's":        `'it'\''s'`,
+		"$HOME `id`":  "'$HOME `id`'",
+		`"quoted" \n`: `'"quoted" \n'`,
+	}
+
+	for input, expected := range cases {
+		if actual := ShellQuote(input); actual != expected {
+			t.Fatalf("%q: bad: %s", input, actual)
+		}
+	}
+}
+
+func TestPowerShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"":                 "''",
+		`C:\Program Files`: `'C:\Program Files'`,
+		"it's":             "'it''s'",
+		"$env:TEMP":        "'$env:TEMP'",
+		"`n \"x\"":         "'`n \"x\"'",
+	}
+
+	for input, expected := range cases {
+		if actual := PowerShellQuote(input); actual != expected {
+			t.Fatalf("%q: bad: %s", input, actual)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package saltmasterless
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -171,7 +172,7 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	var err error
 	var src, dst string
 
@@ -182,20 +183,20 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 			Command: fmt.Sprintf("curl -L https://bootstrap.saltstack.com -o /tmp/install_salt.sh || wget -O /tmp/install_salt.sh https://bootstrap.saltstack.com"),
 		}
 		ui.Message(fmt.Sprintf("Downloading saltstack bootstrap to /tmp/install_salt.sh"))
-		if err = cmd.StartWithUi(comm, ui); err != nil {
+		if err = cmd.RunWithUi(ctx, comm, ui); err != nil {
 			return fmt.Errorf("Unable to download Salt: %s", err)
 		}
 		cmd = &packer.RemoteCmd{
 			Command: fmt.Sprintf("%s /tmp/install_salt.sh %s", p.sudo("sh"), p.config.BootstrapArgs),
 		}
 		ui.Message(fmt.Sprintf("Installing Salt with command %s", cmd.Command))
-		if err = cmd.StartWithUi(comm, ui); err != nil {
+		if err = cmd.RunWithUi(ctx, comm, ui); err != nil {
 			return fmt.Errorf("Unable to install Salt: %s", err)
 		}
 	}
 
 	ui.Message(fmt.Sprintf("Creating remote temporary directory: %s", p.config.TempConfigDir))
-	if err := p.createDir(ui, comm, p.config.TempConfigDir); err != nil {
+	if err := p.createDir(ctx, ui, comm, p.config.TempConfigDir); err != nil {
 		return fmt.Errorf("Error creating remote temporary directory: %s", err)
 	}
 
@@ -209,12 +210,12 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 
 		// move minion config into /etc/salt
 		ui.Message(fmt.Sprintf("Make sure directory %s exists", "/etc/salt"))
-		if err := p.createDir(ui, comm, "/etc/salt"); err != nil {
+		if err := p.createDir(ctx, ui, comm, "/etc/salt"); err != nil {
 			return fmt.Errorf("Error creating remote salt configuration directory: %s", err)
 		}
 		src = filepath.ToSlash(filepath.Join(p.config.TempConfigDir, "minion"))
 		dst = "/etc/salt/minion"
-		if err = p.moveFile(ui, comm, dst, src); err != nil {
+		if err = p.moveFile(ctx, ui, comm, dst, src); err != nil {
 			return fmt.Errorf("Unable to move %s/minion to /etc/salt/minion: %s", p.config.TempConfigDir, err)
 		}
 	}
@@ -229,12 +230,12 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 
 		// move grains file into /etc/salt
 		ui.Message(fmt.Sprintf("Make sure directory %s exists", "/etc/salt"))
-		if err := p.createDir(ui, comm, "/etc/salt"); err != nil {
+		if err := p.createDir(ctx, ui, comm, "/etc/salt"); err != nil {
 			return fmt.Errorf("Error creating remote salt configuration directory: %s", err)
 		}
 		src = filepath.ToSlash(filepath.Join(p.config.TempConfigDir, "grains"))
 		dst = "/etc/salt/grains"
-		if err = p.moveFile(ui, comm, dst, src); err != nil {
+		if err = p.moveFile(ctx, ui, comm, dst, src); err != nil {
 			return fmt.Errorf("Unable to move %s/grains to /etc/salt/grains: %s", p.config.TempConfigDir, err)
 		}
 	}
@@ -242,7 +243,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	ui.Message(fmt.Sprintf("Uploading local state tree: %s", p.config.LocalStateTree))
 	src = p.config.LocalStateTree
 	dst = filepath.ToSlash(filepath.Join(p.config.TempConfigDir, "states"))
-	if err = p.uploadDir(ui, comm, dst, src, []string{".git"}); err != nil {
+	if err = p.uploadDir(ctx, ui, comm, dst, src, []string{".git"}); err != nil {
 		return fmt.Errorf("Error uploading local state tree to remote: %s", err)
 	}
 
@@ -253,10 +254,10 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	} else {
 		dst = DefaultStateTreeDir
 	}
-	if err = p.removeDir(ui, comm, dst); err != nil {
+	if err = p.removeDir(ctx, ui, comm, dst); err != nil {
 		return fmt.Errorf("Unable to clear salt tree: %s", err)
 	}
-	if err = p.moveFile(ui, comm, dst, src); err != nil {
+	if err = p.moveFile(ctx, ui, comm, dst, src); err != nil {
 		return fmt.Errorf("Unable to move %s/states to %s: %s", p.config.TempConfigDir, dst, err)
 	}
 
@@ -264,7 +265,7 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		ui.Message(fmt.Sprintf("Uploading local pillar roots: %s", p.config.LocalPillarRoots))
 		src = p.config.LocalPillarRoots
 		dst = filepath.ToSlash(filepath.Join(p.config.TempConfigDir, "pillar"))
-		if err = p.uploadDir(ui, comm, dst, src, []string{".git"}); err != nil {
+		if err = p.uploadDir(ctx, ui, comm, dst, src, []string{".git"}); err != nil {
 			return fmt.Errorf("Error uploading local pillar roots to remote: %s", err)
 		}
 
@@ -275,17 +276,17 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		} else {
 			dst = DefaultPillarRootDir
 		}
-		if err = p.removeDir(ui, comm, dst); err != nil {
+		if err = p.removeDir(ctx, ui, comm, dst); err != nil {
 			return fmt.Errorf("Unable to clear pillar root: %s", err)
 		}
-		if err = p.moveFile(ui, comm, dst, src); err != nil {
+		if err = p.moveFile(ctx, ui, comm, dst, src); err != nil {
 			return fmt.Errorf("Unable to move %s/pillar to %s: %s", p.config.TempConfigDir, dst, err)
 		}
 	}
 
 	ui.Message(fmt.Sprintf("Running: salt-call --local %s", p.config.CmdArgs))
 	cmd := &packer.RemoteCmd{Command: p.sudo(fmt.Sprintf("%s --local %s", filepath.Join(p.config.SaltBinDir, "salt-call"), p.config.CmdArgs))}
-	if err = cmd.StartWithUi(comm, ui); err != nil || cmd.ExitStatus != 0 {
+	if err = cmd.RunWithUi(ctx, comm, ui); err != nil || cmd.ExitStatus != 0 {
 		if err == nil {
 			err = fmt.Errorf("Bad exit status: %d", cmd.ExitStatus)
 		}
@@ -296,12 +297,6 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
-}
-
 // Prepends sudo to supplied command if config says to
 func (p *Provisioner) sudo(cmd string) string {
 	if p.config.DisableSudo {
@@ -354,10 +349,10 @@ func (p *Provisioner) uploadFile(ui packer.Ui, comm packer.Communicator, dst, sr
 	return nil
 }
 
-func (p *Provisioner) moveFile(ui packer.Ui, comm packer.Communicator, dst, src string) error {
+func (p *Provisioner) moveFile(ctx context.Context, ui packer.Ui, comm packer.Communicator, dst, src string) error {
 	ui.Message(fmt.Sprintf("Moving %s to %s", src, dst))
 	cmd := &packer.RemoteCmd{Command: fmt.Sprintf(p.sudo("mv %s %s"), src, dst)}
-	if err := cmd.StartWithUi(comm, ui); err != nil || cmd.ExitStatus != 0 {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil || cmd.ExitStatus != 0 {
 		if err == nil {
 			err = fmt.Errorf("Bad exit status: %d", cmd.ExitStatus)
 		}
@@ -367,12 +362,12 @@ func (p *Provisioner) moveFile(ui packer.Ui, comm packer.Communicator, dst, src
 	return nil
 }
 
-func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir string) error {
+func (p *Provisioner) createDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dir string) error {
 	ui.Message(fmt.Sprintf("Creating directory: %s", dir))
 	cmd := &packer.RemoteCmd{
 		Command: fmt.Sprintf("mkdir -p '%s'", dir),
 	}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -381,12 +376,12 @@ func (p *Provisioner) createDir(ui packer.Ui, comm packer.Communicator, dir stri
 	return nil
 }
 
-func (p *Provisioner) removeDir(ui packer.Ui, comm packer.Communicator, dir string) error {
+func (p *Provisioner) removeDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dir string) error {
 	ui.Message(fmt.Sprintf("Removing directory: %s", dir))
 	cmd := &packer.RemoteCmd{
 		Command: fmt.Sprintf(p.sudo("rm -rf '%s'"), dir),
 	}
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return err
 	}
 	if cmd.ExitStatus != 0 {
@@ -395,8 +390,8 @@ func (p *Provisioner) removeDir(ui packer.Ui, comm packer.Communicator, dir stri
 	return nil
 }
 
-func (p *Provisioner) uploadDir(ui packer.Ui, comm packer.Communicator, dst, src string, ignore []string) error {
-	if err := p.createDir(ui, comm, dst); err != nil {
+func (p *Provisioner) uploadDir(ctx context.Context, ui packer.Ui, comm packer.Communicator, dst, src string, ignore []string) error {
+	if err := p.createDir(ctx, ui, comm, dst); err != nil {
 		return err
 	}
 
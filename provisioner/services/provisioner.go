@@ -0,0 +1,367 @@
+// This package implements a provisioner for Packer that configures the
+// startup type and the state of the services of the remote machine.
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The states services can be put in.
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+)
+
+type guestOSTypeConfig struct {
+	executeCommand string
+	stagingDir     string
+	scriptName     string
+	script         func([]*Service, string) string
+
+	// The startup types of services.
+	startupTypes []string
+}
+
+var guestOSTypeConfigs = map[string]guestOSTypeConfig{
+	provisioner.UnixOSType: {
+		executeCommand: "{{if .Sudo}}sudo {{end}}sh '{{.Path}}'",
+		stagingDir:     "/tmp/packer-services-%s",
+		scriptName:     "services.sh",
+		script:         systemdScript,
+		startupTypes:   []string{"enabled", "disabled", "masked"},
+	},
+	provisioner.WindowsOSType: {
+		executeCommand: `powershell -executionpolicy bypass -noprofile -file "{{.Path}}"`,
+		stagingDir:     "c:/Windows/Temp/packer-services-%s",
+		scriptName:     "services.ps1",
+		script:         windowsScript,
+		startupTypes:   []string{"automatic", "delayed", "manual", "disabled"},
+	},
+}
+
+// Service is the desired configuration of a service: a systemd unit on
+// unix guests, and a Windows service on windows guests.
+type Service struct {
+	Name string
+
+	// The startup type, enabled, disabled or masked on unix guests, and
+	// automatic, delayed, manual or disabled on windows guests. The
+	// startup type isn't changed if it is empty.
+	Startup string
+
+	// running or stopped. The service isn't started or stopped if it is
+	// empty.
+	State string
+}
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The services to configure, in order.
+	Services []*Service `mapstructure:"services"`
+
+	// The local file the summary of the services is written to.
+	SummaryOutput string `mapstructure:"summary_output"`
+
+	// The operating system of the remote machine, unix or windows.
+	GuestOSType string `mapstructure:"guest_os_type"`
+
+	// The command used to run the generated script.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// If true, the script isn't run with sudo on unix guests.
+	PreventSudo bool `mapstructure:"prevent_sudo"`
+
+	// The remote directory the script and its results are uploaded to. It
+	// is removed afterwards.
+	StagingDir string `mapstructure:"staging_directory"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config            Config
+	guestOSTypeConfig guestOSTypeConfig
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+	Sudo bool
+}
+
+// Summary is the content of the summary_output file.
+type Summary struct {
+	BuildName   string           `json:"build_name"`
+	BuilderType string           `json:"builder_type"`
+	GuestOSType string           `json:"guest_os_type"`
+	Services    []*ServiceResult `json:"services"`
+
+	// The names of the services whose startup type or state changed.
+	Modified []string `json:"modified"`
+}
+
+// ServiceResult is the desired, previous and actual configuration of a
+// service in the summary.
+type ServiceResult struct {
+	Name            string `json:"name"`
+	Startup         string `json:"startup,omitempty"`
+	State           string `json:"state,omitempty"`
+	PreviousStartup string `json:"previous_startup"`
+	PreviousState   string `json:"previous_state"`
+	ActualStartup   string `json:"actual_startup"`
+	ActualState     string `json:"actual_state"`
+	Modified        bool   `json:"modified"`
+	Verified        bool   `json:"verified"`
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.GuestOSType == "" {
+		p.config.GuestOSType = provisioner.DefaultOSType
+	}
+	p.config.GuestOSType = strings.ToLower(p.config.GuestOSType)
+
+	var ok bool
+	p.guestOSTypeConfig, ok = guestOSTypeConfigs[p.config.GuestOSType]
+	if !ok {
+		return fmt.Errorf("Invalid guest_os_type: \"%s\"", p.config.GuestOSType)
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = p.guestOSTypeConfig.executeCommand
+	}
+
+	if p.config.StagingDir == "" {
+		p.config.StagingDir = fmt.Sprintf(p.guestOSTypeConfig.stagingDir, uuid.TimeOrderedUUID())
+	}
+
+	if p.config.SummaryOutput == "" {
+		p.config.SummaryOutput = "services-summary.json"
+		if p.config.PackerBuildName != "" {
+			p.config.SummaryOutput = fmt.Sprintf("services-summary-%s.json", p.config.PackerBuildName)
+		}
+	}
+
+	var errs *packer.MultiError
+	if len(p.config.Services) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("At least one service must be specified."))
+	}
+
+	names := make(map[string]bool)
+	for i, s := range p.config.Services {
+		if err := p.prepareService(s); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("services[%d]: %s", i, err))
+			continue
+		}
+		if names[s.Name] {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("services[%d]: %s is specified more than once", i, s.Name))
+		}
+		names[s.Name] = true
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) prepareService(s *Service) error {
+	if s.Name == "" {
+		return errors.New("name must be specified")
+	}
+	if strings.ContainsAny(s.Name, "*?[]\n") {
+		return fmt.Errorf("invalid name %q", s.Name)
+	}
+
+	s.Startup = strings.ToLower(s.Startup)
+	s.State = strings.ToLower(s.State)
+	if s.Startup == "" && s.State == "" {
+		return errors.New("at least one of startup or state must be specified")
+	}
+
+	if s.Startup != "" && !isOneOf(s.Startup, p.guestOSTypeConfig.startupTypes) {
+		return fmt.Errorf("startup must be one of %s, got %q",
+			strings.Join(p.guestOSTypeConfig.startupTypes, ", "), s.Startup)
+	}
+	if s.State != "" && s.State != StateRunning && s.State != StateStopped {
+		return fmt.Errorf("state must be running or stopped, got %q", s.State)
+	}
+
+	// Masked units can't run, so masking stops them
+	if s.Startup == "masked" {
+		if s.State == StateRunning {
+			return errors.New("masked services can't be running")
+		}
+		s.State = StateStopped
+	}
+	return nil
+}
+
+func isOneOf(s string, values []string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Provisioning with services: configuring %d service(s)", len(p.config.Services)))
+
+	// The directory is created as the user, so that the script can be
+	// uploaded to it, and removed with sudo, since the script writes to it
+	user := &provisioner.GuestCommands{GuestOSType: p.config.GuestOSType}
+	guest := &provisioner.GuestCommands{GuestOSType: p.config.GuestOSType, Sudo: !p.config.PreventSudo}
+
+	mkdir := &packer.RemoteCmd{Command: user.CreateDir(p.config.StagingDir)}
+	if err := mkdir.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if mkdir.ExitStatus != 0 {
+		return fmt.Errorf("Error creating %s: exit status %d", p.config.StagingDir, mkdir.ExitStatus)
+	}
+	defer func() {
+		cmd := &packer.RemoteCmd{Command: guest.RemoveDir(p.config.StagingDir)}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil || cmd.ExitStatus != 0 {
+			log.Printf("Error removing %s: %v, exit status %d", p.config.StagingDir, err, cmd.ExitStatus)
+		}
+	}()
+
+	resultsPath := p.config.StagingDir + "/results.tsv"
+	scriptPath := p.config.StagingDir + "/" + p.guestOSTypeConfig.scriptName
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: scriptPath,
+		Sudo: !p.config.PreventSudo,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	log.Printf("Uploading services script to %s", scriptPath)
+	script := p.guestOSTypeConfig.script(p.config.Services, resultsPath)
+	if err := comm.Upload(scriptPath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading script: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Services script exited with non-zero exit status: %d", cmd.ExitStatus)
+	}
+
+	var results bytes.Buffer
+	if err := comm.Download(resultsPath, &results); err != nil {
+		return fmt.Errorf("Error downloading results: %s", err)
+	}
+
+	summary := p.summary(parseResults(results.String()))
+	if err := p.writeSummary(summary); err != nil {
+		return err
+	}
+
+	var unverified []string
+	for _, r := range summary.Services {
+		if !r.Verified {
+			unverified = append(unverified, r.Name)
+		}
+	}
+	ui.Message(fmt.Sprintf("Modified %d service(s). Wrote the summary to %s",
+		len(summary.Modified), p.config.SummaryOutput))
+
+	if len(unverified) > 0 {
+		return fmt.Errorf("Service(s) didn't reach their desired state: %s, see the summary in %s",
+			strings.Join(unverified, ", "), p.config.SummaryOutput)
+	}
+	return nil
+}
+
+// parseResults parses the results file written by the script, which has
+// the previous and actual startup types and states of services by name.
+func parseResults(results string) map[string][4]string {
+	services := make(map[string][4]string)
+	scanner := bufio.NewScanner(strings.NewReader(results))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if len(fields) == 5 {
+			services[fields[0]] = [4]string{fields[1], fields[2], fields[3], fields[4]}
+		}
+	}
+	return services
+}
+
+// summary builds the summary of the services. Services that the script
+// didn't report aren't verified.
+func (p *Provisioner) summary(results map[string][4]string) *Summary {
+	summary := &Summary{
+		BuildName:   p.config.PackerBuildName,
+		BuilderType: p.config.PackerBuilderType,
+		GuestOSType: p.config.GuestOSType,
+		Modified:    []string{},
+	}
+
+	for _, s := range p.config.Services {
+		r := &ServiceResult{
+			Name:    s.Name,
+			Startup: s.Startup,
+			State:   s.State,
+		}
+		if result, ok := results[s.Name]; ok {
+			r.PreviousStartup, r.PreviousState = result[0], result[1]
+			r.ActualStartup, r.ActualState = result[2], result[3]
+			r.Modified = r.ActualStartup != r.PreviousStartup || r.ActualState != r.PreviousState
+			r.Verified = r.ActualStartup != NotFound &&
+				(s.Startup == "" || s.Startup == r.ActualStartup) &&
+				(s.State == "" || s.State == r.ActualState)
+		}
+		if r.Modified {
+			summary.Modified = append(summary.Modified, s.Name)
+		}
+		summary.Services = append(summary.Services, r)
+	}
+	return summary
+}
+
+func (p *Provisioner) writeSummary(summary *Summary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(p.config.SummaryOutput, data, 0644); err != nil {
+		return fmt.Errorf("Error writing the services summary: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"services": []map[string]interface{}{
+			{"name": "sshd", "startup": "enabled", "state": "running"},
+			{"name": "cups", "startup": "masked"},
+		},
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.HasPrefix(p.config.StagingDir, "/tmp/packer-services-") {
+		t.Fatalf("unexpected staging_directory: %s", p.config.StagingDir)
+	}
+	if p.config.SummaryOutput != "services-summary.json" {
+		t.Fatalf("unexpected summary_output: %s", p.config.SummaryOutput)
+	}
+	if p.config.Services[1].State != StateStopped {
+		t.Fatalf("masked services should be stopped: %#v", p.config.Services[1])
+	}
+}
+
+func TestProvisionerPrepare_Errors(t *testing.T) {
+	cases := []map[string]interface{}{
+		{},
+		{"services": []map[string]interface{}{{"startup": "enabled"}}},
+		{"services": []map[string]interface{}{{"name": "sshd"}}},
+		{"services": []map[string]interface{}{{"name": "ssh*", "startup": "enabled"}}},
+		{"services": []map[string]interface{}{{"name": "sshd", "startup": "automatic"}}},
+		{"services": []map[string]interface{}{{"name": "sshd", "state": "paused"}}},
+		{"services": []map[string]interface{}{{"name": "sshd", "startup": "masked", "state": "running"}}},
+		{"services": []map[string]interface{}{
+			{"name": "sshd", "startup": "enabled"},
+			{"name": "sshd", "state": "running"},
+		}},
+		{"services": []map[string]interface{}{{"name": "W32Time", "startup": "masked"}}, "guest_os_type": "windows"},
+		{"services": []map[string]interface{}{{"name": "sshd", "startup": "enabled"}}, "guest_os_type": "beos"},
+	}
+
+	for _, config := range cases {
+		var p Provisioner
+		if err := p.Prepare(config); err == nil {
+			t.Fatalf("%v: should have error", config)
+		}
+	}
+}
+
+func TestProvisionerProvision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		Name     string
+		Results  string
+		Modified []string
+		Err      bool
+	}{
+		{
+			"modified",
+			"sshd\tdisabled\tstopped\tenabled\trunning\ncups\tenabled\trunning\tmasked\tstopped\n",
+			[]string{"sshd", "cups"},
+			false,
+		},
+		{
+			"unmodified",
+			"sshd\tenabled\trunning\tenabled\trunning\ncups\tmasked\tstopped\tmasked\tstopped\n",
+			[]string{},
+			false,
+		},
+		{
+			"failed",
+			"sshd\tdisabled\tstopped\tenabled\tstopped\ncups\tmasked\tstopped\tmasked\tstopped\n",
+			[]string{"sshd"},
+			true,
+		},
+		{
+			"missing",
+			"sshd\tenabled\trunning\tenabled\trunning\n",
+			[]string{},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		summary := filepath.Join(dir, tc.Name+".json")
+		config := testConfig()
+		config["staging_directory"] = "/tmp/staging"
+		config["summary_output"] = summary
+
+		var p Provisioner
+		if err := p.Prepare(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		comm := &packer.MockCommunicator{DownloadData: tc.Results}
+		err := p.Provision(context.Background(), testUi(), comm)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: bad error: %v", tc.Name, err)
+		}
+
+		if comm.UploadPath != "/tmp/staging/services.sh" {
+			t.Fatalf("%s: bad upload path: %s", tc.Name, comm.UploadPath)
+		}
+		if comm.DownloadPath != "/tmp/staging/results.tsv" {
+			t.Fatalf("%s: bad download path: %s", tc.Name, comm.DownloadPath)
+		}
+		if comm.StartCmd.Command != "sudo rm -rf '/tmp/staging'" {
+			t.Fatalf("%s: bad last command: %s", tc.Name, comm.StartCmd.Command)
+		}
+
+		data, err := ioutil.ReadFile(summary)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		var s Summary
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		if len(s.Services) != 2 {
+			t.Fatalf("%s: bad services: %#v", tc.Name, s.Services)
+		}
+		if strings.Join(s.Modified, ",") != strings.Join(tc.Modified, ",") {
+			t.Fatalf("%s: bad modified services: %#v", tc.Name, s.Modified)
+		}
+	}
+}
+
+func TestSystemdScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A fake systemctl keeping the startup types and states of units in
+	// files, and logging the commands changing them
+	units := filepath.Join(dir, "units")
+	if err := os.Mkdir(units, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for name, content := range map[string]string{
+		"sshd.startup": "disabled",
+		"sshd.state":   "stopped",
+		"cups.startup": "masked",
+		"cups.state":   "stopped",
+		"ntpd.startup": "enabled",
+		"ntpd.state":   "running",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(units, name), []byte(content+"\n"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(bin, "systemctl"), []byte(`#!/bin/sh
+units=`+provisioner.ShellQuote(units)+`
+case "$1" in
+    is-enabled) cat "$units/$2.startup" ;;
+    is-active) [ "$(cat "$units/$3.state")" = running ] ;;
+    *)
+        echo "$@" >> "$units/log"
+        case "$1" in
+            enable) echo enabled > "$units/$2.startup" ;;
+            disable|unmask) echo disabled > "$units/$2.startup" ;;
+            mask) echo masked > "$units/$2.startup" ;;
+            start) echo running > "$units/$2.state" ;;
+            stop) echo stopped > "$units/$2.state" ;;
+        esac
+        ;;
+esac
+`), 0755)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	results := filepath.Join(dir, "results.tsv")
+	services := []*Service{
+		{Name: "sshd", Startup: "enabled", State: StateRunning},
+		{Name: "cups", Startup: "enabled"},
+		{Name: "ntpd", Startup: "masked", State: StateStopped},
+		{Name: "missing", State: StateRunning},
+	}
+
+	script := filepath.Join(dir, "services.sh")
+	if err := ioutil.WriteFile(script, []byte(systemdScript(services, results)), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out, err := exec.Command("sh", script).CombinedOutput(); err != nil {
+		t.Fatalf("err: %s\n%s", err, out)
+	}
+
+	data, err := ioutil.ReadFile(results)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string][4]string{
+		"sshd":    {"disabled", "stopped", "enabled", "running"},
+		"cups":    {"masked", "stopped", "enabled", "stopped"},
+		"ntpd":    {"enabled", "running", "masked", "stopped"},
+		"missing": {NotFound, "stopped", NotFound, "stopped"},
+	}
+	actual := parseResults(string(data))
+	for name, e := range expected {
+		if actual[name] != e {
+			t.Fatalf("%s: got %q, expected %q\n%s", name, actual[name], e, data)
+		}
+	}
+
+	log, err := ioutil.ReadFile(filepath.Join(units, "log"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	commands := "enable sshd\nstart sshd\nunmask cups\nenable cups\nmask ntpd\nstop ntpd\n"
+	if string(log) != commands {
+		t.Fatalf("bad systemctl commands:\n%s", log)
+	}
+}
+
+func TestWindowsScript(t *testing.T) {
+	script := windowsScript([]*Service{
+		{Name: "W32Time", Startup: "automatic", State: StateRunning},
+		{Name: "Spooler", Startup: "disabled", State: StateStopped},
+	}, "c:/staging/results.tsv")
+
+	for _, expected := range []string{
+		"$results = 'c:/staging/results.tsv'",
+		"Set-PackerService 'W32Time' 'automatic' 'running'\n",
+		"Set-PackerService 'Spooler' 'disabled' 'stopped'\n",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Fatalf("script should contain %q:\n%s", expected, script)
+		}
+	}
+}
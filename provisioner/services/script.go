@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/packer/provisioner"
+)
+
+// NotFound is the startup type of services that don't exist on the
+// remote machine.
+const NotFound = "not-found"
+
+// The scripts configure the services, then write their startup type and
+// state before and after the changes to the results file, as tab
+// separated values. A service that can't be configured doesn't stop the
+// others from being configured, it just doesn't reach its desired state.
+
+const systemdHeader = `results=%s
+: > "$results"
+
+packer_startup() {
+    startup=$(systemctl is-enabled "$1" 2>/dev/null)
+    case "$startup" in
+        enabled-runtime) echo enabled ;;
+        masked-runtime) echo masked ;;
+        "") echo not-found ;;
+        *) echo "$startup" ;;
+    esac
+}
+
+packer_state() {
+    if systemctl is-active --quiet "$1"; then
+        echo running
+    else
+        echo stopped
+    fi
+}
+
+packer_service() {
+    before_startup=$(packer_startup "$1")
+    before_state=$(packer_state "$1")
+    if [ "$before_startup" = not-found ] && [ "$2" != masked ]; then
+        echo "$1: unit not found"
+    else
+        case "$2" in
+            enabled|disabled)
+                if [ "$before_startup" = masked ]; then
+                    systemctl unmask "$1"
+                fi
+                if [ "$(packer_startup "$1")" != "$2" ]; then
+                    systemctl "${2%%d}" "$1"
+                fi
+                ;;
+            masked)
+                if [ "$before_startup" != masked ]; then
+                    systemctl mask "$1"
+                fi
+                ;;
+        esac
+        case "$3" in
+            running)
+                if [ "$before_state" != running ]; then
+                    systemctl start "$1"
+                fi
+                ;;
+            stopped)
+                if [ "$before_state" != stopped ]; then
+                    systemctl stop "$1"
+                fi
+                ;;
+        esac
+    fi
+    after_startup=$(packer_startup "$1")
+    after_state=$(packer_state "$1")
+    echo "$1: $before_startup/$before_state -> $after_startup/$after_state"
+    printf '%%s\t%%s\t%%s\t%%s\t%%s\n' "$1" "$before_startup" "$before_state" "$after_startup" "$after_state" >> "$results"
+}
+
+`
+
+func systemdScript(services []*Service, results string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, systemdHeader, provisioner.ShellQuote(results))
+
+	for _, s := range services {
+		fmt.Fprintf(&buf, "packer_service %s %s %s\n",
+			provisioner.ShellQuote(s.Name), provisioner.ShellQuote(s.Startup), provisioner.ShellQuote(s.State))
+	}
+	return buf.String()
+}
+
+const windowsHeader = `$ErrorActionPreference = 'Stop'
+$results = %s
+Set-Content -LiteralPath $results -Value $null
+
+$startModes = @{
+    'automatic' = 'auto'
+    'delayed' = 'delayed-auto'
+    'manual' = 'demand'
+    'disabled' = 'disabled'
+}
+
+function Get-PackerStartup($name) {
+    if (-not (Get-Service -Name $name -ErrorAction SilentlyContinue)) {
+        return 'not-found'
+    }
+    $key = Get-ItemProperty -LiteralPath "HKLM:\SYSTEM\CurrentControlSet\Services\$name"
+    switch ($key.Start) {
+        2 {
+            if ($key.DelayedAutostart -eq 1) { 'delayed' } else { 'automatic' }
+        }
+        3 { 'manual' }
+        4 { 'disabled' }
+        default { 'other' }
+    }
+}
+
+function Get-PackerState($name) {
+    $service = Get-Service -Name $name -ErrorAction SilentlyContinue
+    if ($service -and $service.Status -eq 'Running') { 'running' } else { 'stopped' }
+}
+
+function Set-PackerService($name, $startup, $state) {
+    $beforeStartup = Get-PackerStartup $name
+    $beforeState = Get-PackerState $name
+    try {
+        if ($beforeStartup -eq 'not-found') {
+            Write-Host "${name}: service not found"
+        } else {
+            if ($startup -and $startup -ne $beforeStartup) {
+                & sc.exe config $name start= $startModes[$startup] | Out-Null
+                if ($LASTEXITCODE -ne 0) {
+                    throw "sc.exe exited with $LASTEXITCODE"
+                }
+            }
+            if ($state -eq 'running' -and $beforeState -ne 'running') {
+                Start-Service -Name $name
+            }
+            if ($state -eq 'stopped' -and $beforeState -ne 'stopped') {
+                Stop-Service -Name $name -Force
+            }
+        }
+    } catch {
+        Write-Host "Error configuring ${name}: $_"
+    }
+    $afterStartup = Get-PackerStartup $name
+    $afterState = Get-PackerState $name
+    Write-Host "${name}: $beforeStartup/$beforeState -> $afterStartup/$afterState"
+    Add-Content -LiteralPath $results -Value ("{0}` + "`t" + `{1}` + "`t" + `{2}` + "`t" + `{3}` + "`t" + `{4}" -f $name, $beforeStartup, $beforeState, $afterStartup, $afterState)
+}
+
+`
+
+func windowsScript(services []*Service, results string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, windowsHeader, provisioner.PowerShellQuote(results))
+
+	for _, s := range services {
+		fmt.Fprintf(&buf, "Set-PackerService %s %s %s\n",
+			provisioner.PowerShellQuote(s.Name), provisioner.PowerShellQuote(s.Startup), provisioner.PowerShellQuote(s.State))
+	}
+	return buf.String()
+}
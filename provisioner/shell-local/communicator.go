@@ -14,24 +14,35 @@ import (
 type Communicator struct {
 	ExecuteCommand []string
 	Ctx            interpolate.Context
+
+	// Env is added to the environment of Packer for the command.
+	Env []string
+
+	// Script is the path to the script containing the command, if any.
+	Script string
 }
 
 func (c *Communicator) Start(cmd *packer.RemoteCmd) error {
 	// Render the template so that we know how to execute the command
 	c.Ctx.Data = &ExecuteCommandTemplate{
 		Command: cmd.Command,
+		Script:  c.Script,
 	}
+	args := make([]string, len(c.ExecuteCommand))
 	for i, field := range c.ExecuteCommand {
 		command, err := interpolate.Render(field, &c.Ctx)
 		if err != nil {
 			return fmt.Errorf("Error processing command: %s", err)
 		}
 
-		c.ExecuteCommand[i] = command
+		args[i] = command
 	}
 
 	// Build the local command to execute
-	localCmd := exec.Command(c.ExecuteCommand[0], c.ExecuteCommand[1:]...)
+	localCmd := exec.Command(args[0], args[1:]...)
+	if len(c.Env) > 0 {
+		localCmd.Env = append(os.Environ(), c.Env...)
+	}
 	localCmd.Stdin = cmd.Stdin
 	localCmd.Stdout = cmd.Stdout
 	localCmd.Stderr = cmd.Stderr
@@ -82,4 +93,5 @@ func (c *Communicator) DownloadDir(string, string, []string) error {
 
 type ExecuteCommandTemplate struct {
 	Command string
+	Script  string
 }
@@ -1,9 +1,13 @@
 package shell
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"runtime"
+	"strings"
 
 	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/helper/config"
@@ -11,15 +15,33 @@ import (
 	"github.com/hashicorp/packer/template/interpolate"
 )
 
+const (
+	ShellSh         = "sh"
+	ShellCmd        = "cmd"
+	ShellPowershell = "powershell"
+	ShellPwsh       = "pwsh"
+)
+
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
 	// Command is the command to execute
 	Command string
 
+	// Shell is the interpreter the command is run with. It defaults to
+	// cmd on Windows and sh everywhere else.
+	Shell string
+
 	// ExecuteCommand is the command used to execute the command.
 	ExecuteCommand []string `mapstructure:"execute_command"`
 
+	// An array of environment variables that will be injected before
+	// the command is executed.
+	Vars []string `mapstructure:"environment_vars"`
+
+	// Valid exit codes, defaults to only 0.
+	ValidExitCodes []int `mapstructure:"valid_exit_codes"`
+
 	ctx interpolate.Context
 }
 
@@ -41,33 +63,73 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		return err
 	}
 
-	if len(p.config.ExecuteCommand) == 0 {
+	if p.config.Shell == "" {
 		if runtime.GOOS == "windows" {
-			p.config.ExecuteCommand = []string{
-				"cmd",
-				"/C",
-				"{{.Command}}",
-			}
+			p.config.Shell = ShellCmd
 		} else {
+			p.config.Shell = ShellSh
+		}
+	}
+
+	if len(p.config.ExecuteCommand) == 0 {
+		switch p.config.Shell {
+		case ShellSh:
 			p.config.ExecuteCommand = []string{
 				"/bin/sh",
 				"-c",
 				"{{.Command}}",
 			}
+		case ShellCmd:
+			p.config.ExecuteCommand = []string{
+				"cmd",
+				"/C",
+				"{{.Script}}",
+			}
+		case ShellPowershell, ShellPwsh:
+			p.config.ExecuteCommand = []string{
+				p.config.Shell,
+				"-NoProfile",
+				"-NonInteractive",
+				"-ExecutionPolicy",
+				"Bypass",
+				"-File",
+				"{{.Script}}",
+			}
 		}
 	}
 
+	if p.config.ValidExitCodes == nil {
+		p.config.ValidExitCodes = []int{0}
+	}
+
 	var errs *packer.MultiError
 	if p.config.Command == "" {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("command must be specified"))
 	}
 
+	switch p.config.Shell {
+	case ShellSh, ShellCmd, ShellPowershell, ShellPwsh:
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"shell must be one of %s, %s, %s or %s",
+			ShellSh, ShellCmd, ShellPowershell, ShellPwsh))
+	}
+
 	if len(p.config.ExecuteCommand) == 0 {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("execute_command must not be empty"))
 	}
 
+	// Do a check for bad environment variables, such as '=foo', 'foobar'
+	for _, kv := range p.config.Vars {
+		vs := strings.SplitN(kv, "=", 2)
+		if len(vs) != 2 || vs[0] == "" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Environment variable not in format 'key=value': %s", kv))
+		}
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return errs
 	}
@@ -75,11 +137,23 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, _ packer.Communicator) error {
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, _ packer.Communicator) error {
+	// The command is written to a script for the shells that can't take
+	// it as an argument without mangling its quotes.
+	script, err := p.writeScript()
+	if err != nil {
+		return fmt.Errorf("Error writing command to a script: %s", err)
+	}
+	if script != "" {
+		defer os.Remove(script)
+	}
+
 	// Make another communicator for local
 	comm := &Communicator{
 		Ctx:            p.config.ctx,
 		ExecuteCommand: p.config.ExecuteCommand,
+		Env:            p.env(),
+		Script:         script,
 	}
 
 	// Build the remote command
@@ -88,23 +162,70 @@ func (p *Provisioner) Provision(ui packer.Ui, _ packer.Communicator) error {
 	ui.Say(fmt.Sprintf(
 		"Executing local command: %s",
 		p.config.Command))
-	if err := cmd.StartWithUi(comm, ui); err != nil {
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		return fmt.Errorf(
 			"Error executing command: %s\n\n"+
 				"Please see output above for more information.",
 			p.config.Command)
 	}
-	if cmd.ExitStatus != 0 {
-		return fmt.Errorf(
-			"Erroneous exit code %d while executing command: %s\n\n"+
-				"Please see output above for more information.",
-			cmd.ExitStatus,
-			p.config.Command)
+
+	for _, v := range p.config.ValidExitCodes {
+		if cmd.ExitStatus == v {
+			return nil
+		}
 	}
 
-	return nil
+	return fmt.Errorf(
+		"Erroneous exit code %d while executing command: %s\n\n"+
+			"Please see output above for more information.",
+		cmd.ExitStatus,
+		p.config.Command)
 }
 
-func (p *Provisioner) Cancel() {
-	// Just do nothing. When the process ends, so will our provisioner
+// writeScript writes the command to a temporary script for cmd and
+// PowerShell and returns its path. The script makes sure the exit code
+// of the last program run is the exit code of the shell.
+func (p *Provisioner) writeScript() (string, error) {
+	var ext, contents string
+	switch p.config.Shell {
+	case ShellCmd:
+		ext = ".cmd"
+		contents = "@echo off\r\n" + p.config.Command + "\r\nexit /b %ERRORLEVEL%\r\n"
+	case ShellPowershell, ShellPwsh:
+		ext = ".ps1"
+		contents = p.config.Command + "\nexit $LASTEXITCODE\n"
+	default:
+		return "", nil
+	}
+
+	f, err := ioutil.TempFile(p.config.PackerTempDir, "packer-shell-local")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	// The interpreters pick what to do based on the extension
+	path := f.Name() + ext
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// env returns the environment variables added to the environment of
+// Packer for the command.
+func (p *Provisioner) env() []string {
+	env := []string{
+		fmt.Sprintf("PACKER_BUILD_NAME=%s", p.config.PackerBuildName),
+		fmt.Sprintf("PACKER_BUILDER_TYPE=%s", p.config.PackerBuilderType),
+	}
+
+	return append(env, p.config.Vars...)
 }
@@ -1,6 +1,13 @@
 package shell
 
 import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -27,6 +34,30 @@ func TestConfigPrepare(t *testing.T) {
 			nil,
 			true,
 		},
+
+		{
+			"shell",
+			"powershell",
+			false,
+		},
+
+		{
+			"shell",
+			"bash",
+			true,
+		},
+
+		{
+			"environment_vars",
+			[]string{"FOO=bar"},
+			false,
+		},
+
+		{
+			"environment_vars",
+			[]string{"=bar"},
+			true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -48,6 +79,108 @@ func TestConfigPrepare(t *testing.T) {
 	}
 }
 
+func TestConfigPrepare_shell(t *testing.T) {
+	cases := map[string][]string{
+		"sh":         {"/bin/sh", "-c", "{{.Command}}"},
+		"cmd":        {"cmd", "/C", "{{.Script}}"},
+		"pwsh":       {"pwsh", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", "{{.Script}}"},
+		"powershell": {"powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", "{{.Script}}"},
+	}
+
+	for shell, expected := range cases {
+		raw := testConfig(t)
+		raw["shell"] = shell
+
+		var p Provisioner
+		if err := p.Prepare(raw); err != nil {
+			t.Fatalf("%s: err: %s", shell, err)
+		}
+		if !reflect.DeepEqual(p.config.ExecuteCommand, expected) {
+			t.Fatalf("%s: bad: %#v", shell, p.config.ExecuteCommand)
+		}
+	}
+}
+
+func TestProvisioner_writeScript(t *testing.T) {
+	cases := []struct {
+		Shell    string
+		Ext      string
+		Contents string
+	}{
+		{"sh", "", ""},
+		{"cmd", ".cmd", "@echo off\r\necho \"foo\"\r\nexit /b %ERRORLEVEL%\r\n"},
+		{"pwsh", ".ps1", "echo \"foo\"\nexit $LASTEXITCODE\n"},
+	}
+
+	for _, tc := range cases {
+		raw := testConfig(t)
+		raw["command"] = `echo "foo"`
+		raw["shell"] = tc.Shell
+
+		var p Provisioner
+		if err := p.Prepare(raw); err != nil {
+			t.Fatalf("%s: err: %s", tc.Shell, err)
+		}
+
+		path, err := p.writeScript()
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Shell, err)
+		}
+		if tc.Ext == "" {
+			if path != "" {
+				t.Fatalf("%s: should not write a script: %s", tc.Shell, path)
+			}
+			continue
+		}
+		defer os.Remove(path)
+
+		if filepath.Ext(path) != tc.Ext {
+			t.Fatalf("%s: bad: %s", tc.Shell, path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Shell, err)
+		}
+		if string(data) != tc.Contents {
+			t.Fatalf("%s: bad: %q", tc.Shell, data)
+		}
+	}
+}
+
+func TestProvisioner_Provision(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+
+	raw := testConfig(t)
+	raw["command"] = `echo "$PACKER_BUILD_NAME $FOO"; exit 3`
+	raw["environment_vars"] = []string{"FOO=bar baz"}
+	raw["packer_build_name"] = "test"
+
+	var p Provisioner
+	if err := p.Prepare(raw); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var out bytes.Buffer
+	ui := &packer.BasicUi{
+		Reader:      new(bytes.Buffer),
+		Writer:      &out,
+		ErrorWriter: new(bytes.Buffer),
+	}
+	if err := p.Provision(context.Background(), ui, nil); err == nil {
+		t.Fatal("should error on exit code 3")
+	}
+	if !bytes.Contains(out.Bytes(), []byte("test bar baz")) {
+		t.Fatalf("bad: %s", out.String())
+	}
+
+	p.config.ValidExitCodes = []int{0, 3}
+	if err := p.Provision(context.Background(), ui, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func testConfig(t *testing.T) map[string]interface{} {
 	return map[string]interface{}{
 		"command": "echo foo",
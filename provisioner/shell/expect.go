@@ -0,0 +1,128 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+)
+
+// maxExpectBuffer is how much output not matched yet is kept, prompts are
+// expected to be shorter.
+const maxExpectBuffer = 4096
+
+// ExpectConfig is a prompt of the scripts and its response.
+type ExpectConfig struct {
+	// Pattern is a regular expression matching the prompt.
+	Pattern string `mapstructure:"pattern"`
+
+	// Response is written, followed by a newline, to the standard input
+	// of the script when the prompt is output.
+	Response string `mapstructure:"response"`
+
+	pattern *regexp.Regexp
+}
+
+func (c *ExpectConfig) Prepare() error {
+	if c.Pattern == "" {
+		return fmt.Errorf("expect pattern must be specified")
+	}
+
+	var err error
+	c.pattern, err = regexp.Compile(c.Pattern)
+	if err != nil {
+		return fmt.Errorf("Bad expect pattern '%s': %s", c.Pattern, err)
+	}
+	return nil
+}
+
+// expecter watches the output of a command and answers its prompts on its
+// standard input. It is written both the standard output and error of the
+// command, and Stdin is the standard input of the command.
+type expecter struct {
+	Stdin io.Reader
+
+	expects []ExpectConfig
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	answers chan string
+	done    chan struct{}
+
+	sync.Mutex
+	buf    []byte
+	closed bool
+}
+
+func newExpecter(expects []ExpectConfig) *expecter {
+	r, w := io.Pipe()
+	e := &expecter{
+		Stdin:   r,
+		expects: expects,
+		stdinR:  r,
+		stdinW:  w,
+		answers: make(chan string, 16),
+		done:    make(chan struct{}),
+	}
+
+	// The answers are written in their own goroutine, so that the output
+	// of the command is never blocked by its standard input.
+	go func() {
+		defer close(e.done)
+		for answer := range e.answers {
+			if _, err := io.WriteString(e.stdinW, answer); err != nil {
+				log.Printf("Error answering prompt: %s", err)
+			}
+		}
+	}()
+
+	return e
+}
+
+func (e *expecter) Write(p []byte) (int, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.closed {
+		return len(p), nil
+	}
+
+	e.buf = append(e.buf, p...)
+	for {
+		start, end, i := -1, 0, 0
+		for j, expect := range e.expects {
+			if loc := expect.pattern.FindIndex(e.buf); loc != nil && (start < 0 || loc[0] < start) {
+				start, end, i = loc[0], loc[1], j
+			}
+		}
+		if start < 0 {
+			break
+		}
+
+		log.Printf("Answering prompt matching '%s'", e.expects[i].Pattern)
+		e.answers <- e.expects[i].Response + "\n"
+		e.buf = e.buf[end:]
+	}
+
+	if len(e.buf) > maxExpectBuffer {
+		e.buf = e.buf[len(e.buf)-maxExpectBuffer:]
+	}
+	return len(p), nil
+}
+
+// Close closes the standard input of the command. Answers the command
+// didn't read anymore are dropped.
+func (e *expecter) Close() error {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	close(e.answers)
+	e.stdinR.Close()
+	<-e.done
+	return e.stdinW.Close()
+}
@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestExpectConfigPrepare(t *testing.T) {
+	cases := []struct {
+		Pattern string
+		Err     bool
+	}{
+		{"Accept the license\\? \\[y/N\\]", false},
+		{"", true},
+		{"(unclosed", true},
+	}
+
+	for _, tc := range cases {
+		c := &ExpectConfig{Pattern: tc.Pattern, Response: "y"}
+		if err := c.Prepare(); (err != nil) != tc.Err {
+			t.Fatalf("%q: err: %v", tc.Pattern, err)
+		}
+	}
+}
+
+func TestExpecter(t *testing.T) {
+	expects := []ExpectConfig{
+		{Pattern: "New password: ", Response: "secret"},
+		{Pattern: "Retype new password: ", Response: "secret"},
+		{Pattern: "\\[y/N\\]", Response: "y"},
+	}
+	for i := range expects {
+		if err := expects[i].Prepare(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	e := newExpecter(expects)
+	expected := "secret\nsecret\ny\n"
+	stdin := make(chan string)
+	go func() {
+		b := make([]byte, len(expected))
+		io.ReadFull(e.Stdin, b)
+		stdin <- string(b)
+	}()
+
+	// Prompts split across writes and several in one write
+	for _, s := range []string{
+		"Changing password\nNew pass", "word: ",
+		"Retype new password: Continue? [y/N]",
+		"done\n",
+	} {
+		if _, err := e.Write([]byte(s)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if actual := <-stdin; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// The standard input ends on Close, and later writes are ignored
+	e.Close()
+	if _, err := e.Write([]byte("New password: ")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, err := ioutil.ReadAll(e.Stdin); err == nil && len(b) > 0 {
+		t.Fatalf("bad: %q", b)
+	}
+}
@@ -4,6 +4,8 @@ package shell
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -73,17 +75,33 @@ type Config struct {
 
 	ExpectDisconnect *bool `mapstructure:"expect_disconnect"`
 
+	// Prompts of the scripts to answer on their standard input.
+	Expect []ExpectConfig `mapstructure:"expect"`
+
 	startRetryTimeout time.Duration
 	ctx               interpolate.Context
 }
 
 type Provisioner struct {
 	config Config
+
+	// defaultRemotePath is true if neither remote_folder nor remote_path
+	// were set, so that the script is uploaded to the temporary directory
+	// of the guest.
+	defaultRemotePath bool
+
+	// scriptsRun are the scripts the last run ran successfully, for the
+	// metadata of the artifact.
+	scriptsRun []string
 }
 
 type ExecuteCommandTemplate struct {
 	Vars string
 	Path string
+
+	// SharedFolder is where the builder mounted a folder of the host in
+	// the machine, if it shares one.
+	SharedFolder string
 }
 
 func (p *Provisioner) Prepare(raws ...interface{}) error {
@@ -121,6 +139,10 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		p.config.RawStartRetryTimeout = "5m"
 	}
 
+	if p.config.RemoteFolder == "" && p.config.RemotePath == "" {
+		p.defaultRemotePath = true
+	}
+
 	if p.config.RemoteFolder == "" {
 		p.config.RemoteFolder = "/tmp"
 	}
@@ -176,6 +198,12 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		}
 	}
 
+	for i := range p.config.Expect {
+		if err := p.config.Expect[i].Prepare(); err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+		}
+	}
+
 	if p.config.RawStartRetryTimeout != "" {
 		p.config.startRetryTimeout, err = time.ParseDuration(p.config.RawStartRetryTimeout)
 		if err != nil {
@@ -191,14 +219,61 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+// Validate checks that the scripts can be read and, when the shell named
+// by their shebang is available locally, that they parse.
+func (p *Provisioner) Validate(ctx *packer.ValidateContext) error {
+	var errs *packer.MultiError
+	if p.config.Inline != nil {
+		inline := strings.Join(p.config.Inline, "\n")
+		if err := checkSyntax(p.config.InlineShebang, strings.NewReader(inline)); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Inline script doesn't parse: %s", err))
+		}
+	}
+
+	for _, path := range p.config.Scripts {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad script '%s': %s", path, err))
+			continue
+		}
+
+		// Binary scripts aren't necessarily shell scripts
+		if p.config.Binary {
+			continue
+		}
+
+		r := &UnixReader{Reader: bytes.NewReader(contents)}
+		line, _ := bufio.NewReader(bytes.NewReader(contents)).ReadString('\n')
+		if err := checkSyntax(shebang(line), r); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Script '%s' doesn't parse: %s", path, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	if facts := packer.GuestFactsOf(comm); p.defaultRemotePath && facts.TempDir != "" && !facts.IsWindows() {
+		p.config.RemoteFolder = facts.TempDir
+		p.config.RemotePath = fmt.Sprintf(
+			"%s/%s", p.config.RemoteFolder, p.config.RemoteFile)
+	}
+
+	p.scriptsRun = nil
 	scripts := make([]string, len(p.config.Scripts))
 	copy(scripts, p.config.Scripts)
 
 	// If we have an inline script, then turn that into a temporary
 	// shell script and use that.
 	if p.config.Inline != nil {
-		tf, err := ioutil.TempFile("", "packer-shell")
+		tf, err := ioutil.TempFile(p.config.PackerTempDir, "packer-shell")
 		if err != nil {
 			return fmt.Errorf("Error preparing shell script: %s", err)
 		}
@@ -226,8 +301,10 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	// Create environment variables to set before executing the command
 	flattenedEnvVars := p.createFlattenedEnvVars()
 
-	for _, path := range scripts {
+	for i, path := range scripts {
 		ui.Say(fmt.Sprintf("Provisioning with shell script: %s", path))
+		// Failed scripts end with the provisioner in the timing report
+		endTiming := packer.StartTiming(ui, "script", path)
 
 		log.Printf("Opening %s for reading", path)
 		f, err := os.Open(path)
@@ -238,8 +315,9 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 
 		// Compile the command
 		p.config.ctx.Data = &ExecuteCommandTemplate{
-			Vars: flattenedEnvVars,
-			Path: p.config.RemotePath,
+			Vars:         flattenedEnvVars,
+			Path:         p.config.RemotePath,
+			SharedFolder: common.GetSharedFolderPath(),
 		}
 		command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
 		if err != nil {
@@ -277,7 +355,14 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 			cmd.Wait()
 
 			cmd = &packer.RemoteCmd{Command: command}
-			return cmd.StartWithUi(comm, ui)
+			if len(p.config.Expect) > 0 {
+				e := newExpecter(p.config.Expect)
+				defer e.Close()
+				cmd.Stdin = e.Stdin
+				cmd.Stdout = e
+				cmd.Stderr = e
+			}
+			return cmd.RunWithUi(ctx, comm, ui)
 		})
 
 		if err != nil {
@@ -325,15 +410,28 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 					p.config.RemotePath)
 			}
 		}
+
+		// Inline commands run from a temporary file
+		if i < len(p.config.Scripts) {
+			p.scriptsRun = append(p.scriptsRun, path)
+		} else {
+			p.scriptsRun = append(p.scriptsRun, "inline")
+		}
+
+		endTiming(nil)
 	}
 
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	// Just hard quit. It isn't a big deal if what we're doing keeps
-	// running on the other side.
-	os.Exit(0)
+// Metadata returns the scripts that ran as "shell.scripts", "inline" for
+// the inline commands.
+func (p *Provisioner) Metadata() packer.ArtifactMetadata {
+	m := make(packer.ArtifactMetadata)
+	if len(p.scriptsRun) > 0 {
+		m["shell.scripts"] = append([]string(nil), p.scriptsRun...)
+	}
+	return m
 }
 
 // retryable will retry the given function over and over until a
@@ -346,6 +444,11 @@ func (p *Provisioner) retryable(f func() error) error {
 			return nil
 		}
 
+		// Commands of cancelled provisioners aren't retried
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+
 		// Create an error and log it
 		err = fmt.Errorf("Retryable error: %s", err)
 		log.Print(err.Error())
@@ -369,9 +472,11 @@ func (p *Provisioner) createFlattenedEnvVars() (flattened string) {
 	// Always available Packer provided env vars
 	envVars["PACKER_BUILD_NAME"] = fmt.Sprintf("%s", p.config.PackerBuildName)
 	envVars["PACKER_BUILDER_TYPE"] = fmt.Sprintf("%s", p.config.PackerBuilderType)
-	httpAddr := common.GetHTTPAddr()
-	if httpAddr != "" {
-		envVars["PACKER_HTTP_ADDR"] = fmt.Sprintf("%s", httpAddr)
+	for k, v := range common.GetHTTPEnvVars() {
+		envVars[k] = v
+	}
+	if path := common.GetSharedFolderPath(); path != "" {
+		envVars["PACKER_SHARED_FOLDER"] = path
 	}
 
 	// Split vars into key/value components
@@ -1,12 +1,18 @@
 package shell
 
 import (
-	"github.com/hashicorp/packer/packer"
+	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/packer"
 )
 
 func testConfig() map[string]interface{} {
@@ -286,6 +292,22 @@ func TestProvisioner_createFlattenedEnvVars(t *testing.T) {
 	}
 }
 
+func TestProvisioner_createFlattenedEnvVars_sharedFolder(t *testing.T) {
+	p := new(Provisioner)
+	p.Prepare(testConfig())
+	p.config.PackerBuildName = "vmware"
+	p.config.PackerBuilderType = "iso"
+
+	// The path is normally set by the builder
+	common.SetSharedFolderPath("/mnt/packer")
+	defer common.RemoveSharedFolderPath()
+
+	expected := `PACKER_BUILDER_TYPE='iso' PACKER_BUILD_NAME='vmware' PACKER_SHARED_FOLDER='/mnt/packer' `
+	if vars := p.createFlattenedEnvVars(); vars != expected {
+		t.Fatalf("bad: %s", vars)
+	}
+}
+
 func TestProvisioner_RemoteFolderSetSuccessfully(t *testing.T) {
 	config := testConfig()
 
@@ -413,3 +435,99 @@ func TestProvisionerRemotePathDefaultsSuccessfully(t *testing.T) {
 		t.Fatalf("remote path does not match the expected default regex")
 	}
 }
+
+func TestProvisionerProvision_metadata(t *testing.T) {
+	p := new(Provisioner)
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m := p.Metadata(); len(m) != 0 {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	ui := &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := packer.ArtifactMetadata{"shell.scripts": {"inline"}}
+	if m := p.Metadata(); !reflect.DeepEqual(m, expected) {
+		t.Fatalf("bad: %#v", m)
+	}
+}
+
+func TestProvisionerValidate(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	cases := []struct {
+		Script string
+		Err    bool
+	}{
+		{"#!/bin/sh\necho hello\n", false},
+		{"#!/usr/bin/env sh\r\necho hello\r\n", false},
+		{"echo hello\nif true; then\n", true},
+		{"#!/usr/bin/python\nif true; then\n", false},
+	}
+
+	for _, tc := range cases {
+		tf, err := ioutil.TempFile("", "packer")
+		if err != nil {
+			t.Fatalf("error tempfile: %s", err)
+		}
+		defer os.Remove(tf.Name())
+		tf.WriteString(tc.Script)
+		tf.Close()
+
+		config := testConfig()
+		delete(config, "inline")
+		config["script"] = tf.Name()
+
+		p := new(Provisioner)
+		if err := p.Prepare(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err = p.Validate(&packer.ValidateContext{})
+		if (err != nil) != tc.Err {
+			t.Fatalf("bad: %q: %s", tc.Script, err)
+		}
+	}
+
+	config := testConfig()
+	config["inline"] = []interface{}{"if true; then"}
+	p := new(Provisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := p.Validate(&packer.ValidateContext{}); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestProvisionerPrepare_Expect(t *testing.T) {
+	config := testConfig()
+	config["expect"] = []map[string]interface{}{
+		{"pattern": "Accept\\? \\[y/N\\]", "response": "y"},
+	}
+	p := new(Provisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(p.config.Expect) != 1 || p.config.Expect[0].Response != "y" {
+		t.Fatalf("bad: %#v", p.config.Expect)
+	}
+
+	config["expect"] = []map[string]interface{}{
+		{"pattern": "(", "response": "y"},
+	}
+	p = new(Provisioner)
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
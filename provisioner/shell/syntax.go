@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// syntaxCheckers are the shells that can check the syntax of a script
+// with -n, without running it.
+var syntaxCheckers = map[string]bool{
+	"ash":  true,
+	"bash": true,
+	"dash": true,
+	"ksh":  true,
+	"sh":   true,
+	"zsh":  true,
+}
+
+// shebang returns the interpreter line of a script, without the "#!".
+func shebang(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(line, "#!"))
+}
+
+// checkSyntax checks the syntax of the script read from r with the shell
+// of the shebang, such as "/bin/sh -e" or "/usr/bin/env bash". Scripts
+// without a shebang are checked with sh. The check is skipped if the
+// shell isn't known or isn't available locally.
+func checkSyntax(shebang string, r io.Reader) error {
+	fields := strings.Fields(shebang)
+	if len(fields) > 1 && filepath.Base(fields[0]) == "env" {
+		fields = fields[1:]
+	}
+
+	shell := "sh"
+	if len(fields) > 0 {
+		shell = filepath.Base(fields[0])
+	}
+
+	if !syntaxCheckers[shell] {
+		log.Printf("Not checking syntax of %s script", shell)
+		return nil
+	}
+
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		log.Printf("Not checking syntax, %s isn't available: %s", shell, err)
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, "-n")
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+
+	return nil
+}
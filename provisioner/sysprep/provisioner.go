@@ -0,0 +1,263 @@
+// This package implements a provisioner for Packer that generalizes
+// Windows machines with sysprep.
+package sysprep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/provisioner"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+const DefaultUnattendPath = `C:\Windows\Panther\Unattend\unattend.xml`
+
+// sysprepFailed is the exit status of the script when sysprep didn't
+// finish generalizing the machine.
+const sysprepFailed = 19
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The local path of an unattend.xml template. It is rendered with
+	// the template engine before it is uploaded.
+	UnattendTemplate string `mapstructure:"unattend_template"`
+
+	// Variables available as {{ .Vars.name }} in the unattend template.
+	UnattendVars map[string]string `mapstructure:"unattend_vars"`
+
+	// The path the rendered answer file is placed at on the machine.
+	UnattendPath string `mapstructure:"unattend_path"`
+
+	// The system cleanup action, either "oobe" (default) or "audit".
+	Mode string `mapstructure:"mode"`
+
+	// If true, sysprep doesn't generalize the machine.
+	SkipGeneralize bool `mapstructure:"skip_generalize"`
+
+	// Extra arguments for sysprep, such as /mode:vm.
+	ExtraArguments []string `mapstructure:"extra_arguments"`
+
+	// The path of sysprep on the machine.
+	SysprepPath string `mapstructure:"sysprep_path"`
+
+	// The command used to run the generated script.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// The remote path the script is uploaded to.
+	RemotePath string `mapstructure:"remote_path"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+}
+
+type UnattendTemplate struct {
+	Vars map[string]string
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = `powershell -executionpolicy bypass -noprofile -file "{{.Path}}"`
+	}
+
+	if p.config.RemotePath == "" {
+		p.config.RemotePath = fmt.Sprintf(`c:/Windows/Temp/packer-sysprep-%s.ps1`, uuid.TimeOrderedUUID())
+	}
+
+	if p.config.UnattendPath == "" {
+		p.config.UnattendPath = DefaultUnattendPath
+	}
+
+	if p.config.SysprepPath == "" {
+		p.config.SysprepPath = `C:\Windows\System32\Sysprep\sysprep.exe`
+	}
+
+	if p.config.Mode == "" {
+		p.config.Mode = "oobe"
+	}
+
+	var errs *packer.MultiError
+	if p.config.Mode != "oobe" && p.config.Mode != "audit" {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("mode must be oobe or audit, got %q", p.config.Mode))
+	}
+
+	if p.config.UnattendTemplate != "" {
+		if _, err := os.Stat(p.config.UnattendTemplate); err != nil {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Bad unattend_template '%s': %s", p.config.UnattendTemplate, err))
+		}
+	} else if len(p.config.UnattendVars) > 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("unattend_vars can only be used with unattend_template."))
+	}
+
+	for _, arg := range p.config.ExtraArguments {
+		lower := strings.ToLower(arg)
+		if lower == "/shutdown" || lower == "/reboot" || lower == "/quit" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("extra_arguments can't contain %s, the builder shuts the machine down", arg))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say("Provisioning with sysprep...")
+
+	unattendUpload := ""
+	if p.config.UnattendTemplate != "" {
+		unattend, err := p.renderUnattend()
+		if err != nil {
+			return err
+		}
+
+		unattendUpload = fmt.Sprintf(`c:/Windows/Temp/packer-unattend-%s.xml`, uuid.TimeOrderedUUID())
+		log.Printf("Uploading answer file to %s", unattendUpload)
+		if err := comm.Upload(unattendUpload, strings.NewReader(unattend), nil); err != nil {
+			return fmt.Errorf("Error uploading answer file: %s", err)
+		}
+	}
+
+	script := p.script(unattendUpload)
+
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: p.config.RemotePath,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	log.Printf("Uploading sysprep script to %s", p.config.RemotePath)
+	if err := comm.Upload(p.config.RemotePath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading script: %s", err)
+	}
+
+	cmd := &packer.RemoteCmd{Command: command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+
+	switch cmd.ExitStatus {
+	case 0:
+	case sysprepFailed:
+		return errors.New("Sysprep didn't generalize the machine, see the sysprep logs above")
+	default:
+		return fmt.Errorf("Sysprep script exited with non-zero exit status: %d", cmd.ExitStatus)
+	}
+
+	ui.Message("Sysprep finished. The machine must not be provisioned any further and is left for the builder to shut down.")
+	return nil
+}
+
+func (p *Provisioner) renderUnattend() (string, error) {
+	raw, err := ioutil.ReadFile(p.config.UnattendTemplate)
+	if err != nil {
+		return "", fmt.Errorf("Error reading unattend_template: %s", err)
+	}
+
+	vars := p.config.UnattendVars
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	ctx := p.config.ctx
+	ctx.Data = &UnattendTemplate{Vars: vars}
+	unattend, err := interpolate.Render(string(raw), &ctx)
+	if err != nil {
+		return "", fmt.Errorf("Error rendering unattend_template: %s", err)
+	}
+
+	return unattend, nil
+}
+
+const scriptTemplate = `$ErrorActionPreference = 'Stop'
+$unattendUpload = %s
+$unattend = %s
+$arguments = @(%s)
+
+if ($unattendUpload) {
+    New-Item -ItemType Directory -Force -Path (Split-Path $unattend) | Out-Null
+    Move-Item -Force -LiteralPath $unattendUpload -Destination $unattend
+    $arguments += "/unattend:$unattend"
+}
+
+Write-Host "Running sysprep $arguments"
+$process = Start-Process -FilePath %s -ArgumentList $arguments -Wait -PassThru
+Write-Host "Sysprep exited with $($process.ExitCode)"
+
+# Sysprep reports success in the registry rather than its exit code
+if (%s) {
+    $state = (Get-ItemProperty 'HKLM:\SYSTEM\Setup\Status\SysprepStatus').GeneralizationState
+    if ($state -ne 7) {
+        Write-Host "Generalization state is $state instead of 7"
+        $log = "$env:SystemRoot\System32\Sysprep\Panther\setuperr.log"
+        if (Test-Path $log) {
+            Get-Content $log | Write-Host
+        }
+        exit %d
+    }
+}
+exit 0
+`
+
+func (p *Provisioner) script(unattendUpload string) string {
+	args := []string{}
+	if !p.config.SkipGeneralize {
+		args = append(args, "/generalize")
+	}
+	args = append(args, "/"+p.config.Mode, "/quiet", "/quit")
+	args = append(args, p.config.ExtraArguments...)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = provisioner.PowerShellQuote(arg)
+	}
+
+	generalize := "$true"
+	if p.config.SkipGeneralize {
+		generalize = "$false"
+	}
+
+	return fmt.Sprintf(scriptTemplate,
+		provisioner.PowerShellQuote(unattendUpload),
+		provisioner.PowerShellQuote(p.config.UnattendPath),
+		strings.Join(quoted, ","),
+		provisioner.PowerShellQuote(p.config.SysprepPath),
+		generalize,
+		sysprepFailed)
+}
@@ -0,0 +1,127 @@
+package sysprep
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"unattend_template": "test-fixtures/unattend.xml",
+		"unattend_vars": map[string]string{
+			"timezone": "UTC",
+		},
+		"packer_user_variables": map[string]string{
+			"organization": "Packer",
+		},
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.Mode != "oobe" {
+		t.Fatalf("unexpected mode: %s", p.config.Mode)
+	}
+	if p.config.UnattendPath != DefaultUnattendPath {
+		t.Fatalf("unexpected unattend_path: %s", p.config.UnattendPath)
+	}
+}
+
+func TestProvisionerPrepare_Errors(t *testing.T) {
+	cases := []map[string]interface{}{
+		{"mode": "generalize"},
+		{"unattend_template": "test-fixtures/missing.xml"},
+		{"unattend_vars": map[string]string{"a": "b"}},
+		{"extra_arguments": []string{"/mode:vm", "/Shutdown"}},
+	}
+
+	for _, config := range cases {
+		var p Provisioner
+		if err := p.Prepare(config); err == nil {
+			t.Fatalf("%v: should have error", config)
+		}
+	}
+}
+
+func TestProvisionerProvision(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["remote_path"] = "c:/Windows/Temp/sysprep.ps1"
+	config["extra_arguments"] = []string{"/mode:vm"}
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), testUi(), comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `powershell -executionpolicy bypass -noprofile -file "c:/Windows/Temp/sysprep.ps1"`
+	if comm.StartCmd.Command != expected {
+		t.Fatalf("Expect command to be: %s, got %s", expected, comm.StartCmd.Command)
+	}
+	if !strings.Contains(comm.UploadData, "$arguments = @('/generalize','/oobe','/quiet','/quit','/mode:vm')\n") {
+		t.Fatalf("unexpected script:\n%s", comm.UploadData)
+	}
+}
+
+func TestProvisionerRenderUnattend(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	unattend, err := p.renderUnattend()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, s := range []string{
+		"<TimeZone>UTC</TimeZone>",
+		"<RegisteredOrganization>Packer</RegisteredOrganization>",
+	} {
+		if !strings.Contains(unattend, s) {
+			t.Fatalf("answer file is missing %s:\n%s", s, unattend)
+		}
+	}
+}
+
+func TestProvisionerProvision_SysprepFailed(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(map[string]interface{}{"skip_generalize": true, "mode": "audit"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := new(packer.MockCommunicator)
+	comm.StartExitStatus = sysprepFailed
+	err := p.Provision(context.Background(), testUi(), comm)
+	if err == nil || !strings.Contains(err.Error(), "didn't generalize") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(comm.UploadData, "$arguments = @('/audit','/quiet','/quit')\n") {
+		t.Fatalf("unexpected script:\n%s", comm.UploadData)
+	}
+}
@@ -0,0 +1,219 @@
+// This package implements a provisioner for Packer that declaratively
+// manages registry keys and values on Windows guests.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+var retryableSleep = 2 * time.Second
+
+// Key is a registry key that is created or removed.
+type Key struct {
+	// The path of the key, including the hive, e.g. HKLM:\SOFTWARE\Foo
+	Path string
+
+	// Either "present" (default) or "absent". Removing a key removes
+	// all of its subkeys and values.
+	State string
+
+	// The registry view to use, "64" (default) or "32".
+	View string
+
+	hive    string
+	subkey  string
+	view    string
+	present bool
+}
+
+// Value is a registry value that is set or removed.
+type Value struct {
+	// The path of the key holding the value, including the hive.
+	Path string
+
+	// The name of the value. An empty name is the default value of
+	// the key.
+	Name string
+
+	// The type of the value: String (default), ExpandString, DWord,
+	// QWord, MultiString or Binary.
+	Type string
+
+	// The data of the value. Its format depends on the type.
+	Data interface{}
+
+	// Either "present" (default) or "absent".
+	State string
+
+	// The registry view to use, "64" (default) or "32".
+	View string
+
+	hive    string
+	subkey  string
+	view    string
+	kind    string
+	literal string
+	present bool
+}
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// The registry keys to create or remove.
+	Keys []Key `mapstructure:"keys"`
+
+	// The registry values to set or remove.
+	Values []Value `mapstructure:"values"`
+
+	// The remote path where the generated script will be uploaded to.
+	RemotePath string `mapstructure:"remote_path"`
+
+	// The command used to execute the script. The '{{ .Path }}' variable
+	// should be used to specify where the script goes.
+	ExecuteCommand string `mapstructure:"execute_command"`
+
+	// The timeout for retrying to start the process. Until this timeout
+	// is reached, if the provisioner can't start a process, it retries.
+	// This can be set high to allow for reboots.
+	StartRetryTimeout time.Duration `mapstructure:"start_retry_timeout"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+}
+
+type ExecuteCommandTemplate struct {
+	Path string
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"execute_command",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	if p.config.ExecuteCommand == "" {
+		p.config.ExecuteCommand = `powershell -executionpolicy bypass -noprofile -file "{{.Path}}"`
+	}
+
+	if p.config.StartRetryTimeout == 0 {
+		p.config.StartRetryTimeout = 5 * time.Minute
+	}
+
+	if p.config.RemotePath == "" {
+		p.config.RemotePath = fmt.Sprintf(`c:/Windows/Temp/packer-registry-%s.ps1`, uuid.TimeOrderedUUID())
+	}
+
+	var errs *packer.MultiError
+	if len(p.config.Keys) == 0 && len(p.config.Values) == 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("At least one of keys or values must be specified."))
+	}
+
+	for i := range p.config.Keys {
+		if err := p.config.Keys[i].prepare(); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("keys[%d]: %s", i, err))
+		}
+	}
+
+	for i := range p.config.Values {
+		if err := p.config.Values[i].prepare(); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("values[%d]: %s", i, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say(fmt.Sprintf("Provisioning with windows-registry: %d key(s), %d value(s)",
+		len(p.config.Keys), len(p.config.Values)))
+
+	script := generateScript(p.config.Keys, p.config.Values)
+
+	p.config.ctx.Data = &ExecuteCommandTemplate{
+		Path: p.config.RemotePath,
+	}
+	command, err := interpolate.Render(p.config.ExecuteCommand, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Error processing command: %s", err)
+	}
+
+	// Upload the script and run the command in a single retryable function
+	// so a restart between the two doesn't leave us without the script.
+	var cmd *packer.RemoteCmd
+	err = p.retryable(func() error {
+		log.Printf("Uploading registry script to %s", p.config.RemotePath)
+		if err := comm.Upload(p.config.RemotePath, strings.NewReader(script), nil); err != nil {
+			return fmt.Errorf("Error uploading script: %s", err)
+		}
+
+		cmd = &packer.RemoteCmd{Command: command}
+		return cmd.RunWithUi(ctx, comm, ui)
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("Registry script exited with non-zero exit status: %d", cmd.ExitStatus)
+	}
+
+	return nil
+}
+
+// retryable will retry the given function over and over until a
+// non-error is returned.
+func (p *Provisioner) retryable(f func() error) error {
+	startTimeout := time.After(p.config.StartRetryTimeout)
+	for {
+		var err error
+		if err = f(); err == nil {
+			return nil
+		}
+
+		// Commands of cancelled provisioners aren't retried
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+
+		// Create an error and log it
+		err = fmt.Errorf("Retryable error: %s", err)
+		log.Print(err.Error())
+
+		// Check if we timed out, otherwise we retry. It is safe to
+		// retry since the only error case above is if the command
+		// failed to START.
+		select {
+		case <-startTimeout:
+			return err
+		default:
+			time.Sleep(retryableSleep)
+		}
+	}
+}
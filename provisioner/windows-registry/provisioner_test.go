@@ -0,0 +1,223 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"path": `HKLM:\SOFTWARE\Packer`},
+		},
+		"values": []map[string]interface{}{
+			{"path": `HKLM:\SOFTWARE\Packer`, "name": "Version", "data": "1.0"},
+		},
+	}
+}
+
+func TestProvisioner_Impl(t *testing.T) {
+	var raw interface{}
+	raw = &Provisioner{}
+	if _, ok := raw.(packer.Provisioner); !ok {
+		t.Fatalf("must be a Provisioner")
+	}
+}
+
+func TestProvisionerPrepare_Defaults(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.HasPrefix(p.config.RemotePath, "c:/Windows/Temp/packer-registry-") {
+		t.Fatalf("unexpected remote path: %s", p.config.RemotePath)
+	}
+
+	v := p.config.Values[0]
+	if v.kind != "String" || v.view != "Registry64" || !v.present {
+		t.Fatalf("unexpected value defaults: %#v", v)
+	}
+	if v.hive != "LocalMachine" || v.subkey != `SOFTWARE\Packer` {
+		t.Fatalf("unexpected path: %s %s", v.hive, v.subkey)
+	}
+}
+
+func TestProvisionerPrepare_Empty(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(map[string]interface{}{}); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestProvisionerPrepare_Values(t *testing.T) {
+	cases := []struct {
+		Value   map[string]interface{}
+		Literal string
+		Err     bool
+	}{
+		{
+			map[string]interface{}{"path": `HKCU\Foo`, "name": "a", "data": "it's"},
+			`'it''s'`,
+			false,
+		},
+		{
+			map[string]interface{}{"path": `HKEY_LOCAL_MACHINE\Foo`, "name": "a", "type": "dword", "data": float64(4294967295)},
+			`([int32]-1)`,
+			false,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "DWord", "data": "0x10"},
+			`([int32]16)`,
+			false,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "DWord", "data": float64(4294967296)},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "QWord", "data": "-5"},
+			`([int64]-5)`,
+			false,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "MultiString", "data": []interface{}{"x", "y"}},
+			`([string[]]@('x','y'))`,
+			false,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "Binary", "data": "de ad 01"},
+			`([byte[]]@(0xde,0xad,0x01))`,
+			false,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "Binary", "data": []interface{}{float64(1), float64(300)}},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "Link"},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKXX\Foo`, "name": "a"},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKLM:\`, "name": "a"},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "view": "16"},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "state": "gone"},
+			"",
+			true,
+		},
+		{
+			map[string]interface{}{"path": `HKLM\Foo`, "name": "a", "type": "Link", "state": "absent"},
+			"",
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		var p Provisioner
+		err := p.Prepare(map[string]interface{}{
+			"values": []map[string]interface{}{tc.Value},
+		})
+		if (err != nil) != tc.Err {
+			t.Fatalf("%v: unexpected error state: %s", tc.Value, err)
+		}
+		if err != nil {
+			continue
+		}
+		if literal := p.config.Values[0].literal; literal != tc.Literal {
+			t.Fatalf("%v: bad literal %q, expected %q", tc.Value, literal, tc.Literal)
+		}
+	}
+}
+
+func TestGenerateScript(t *testing.T) {
+	var p Provisioner
+	err := p.Prepare(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"path": `HKLM:\SOFTWARE\Packer`, "view": "32"},
+			{"path": `HKCU:\Software\Old`, "state": "absent"},
+		},
+		"values": []map[string]interface{}{
+			{"path": `HKLM:\SOFTWARE\Packer`, "name": "Count", "type": "DWord", "data": float64(3)},
+			{"path": `HKLM:\SOFTWARE\Packer`, "name": "Stale", "state": "absent"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	script := generateScript(p.config.Keys, p.config.Values)
+	expected := []string{
+		`Set-PackerKey 'LocalMachine' 'Registry32' 'SOFTWARE\Packer'`,
+		`Remove-PackerKey 'CurrentUser' 'Registry64' 'Software\Old'`,
+		`Set-PackerValue 'LocalMachine' 'Registry64' 'SOFTWARE\Packer' 'Count' 'DWord' ([int32]3)`,
+		`Remove-PackerValue 'LocalMachine' 'Registry64' 'SOFTWARE\Packer' 'Stale'`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(script, line+"\n") {
+			t.Fatalf("script is missing %q:\n%s", line, script)
+		}
+	}
+}
+
+func TestProvisionerProvision(t *testing.T) {
+	var p Provisioner
+	config := testConfig()
+	config["remote_path"] = "c:/Windows/Temp/registry.ps1"
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(context.Background(), ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `powershell -executionpolicy bypass -noprofile -file "c:/Windows/Temp/registry.ps1"`
+	if comm.StartCmd.Command != expected {
+		t.Fatalf("Expect command to be: %s, got %s", expected, comm.StartCmd.Command)
+	}
+	if !strings.Contains(comm.UploadData, `Set-PackerValue 'LocalMachine' 'Registry64' 'SOFTWARE\Packer' 'Version' 'String' '1.0'`) {
+		t.Fatalf("unexpected script:\n%s", comm.UploadData)
+	}
+}
+
+func TestProvisionerProvision_ExitStatus(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	comm := new(packer.MockCommunicator)
+	comm.StartExitStatus = 1
+	if err := p.Provision(context.Background(), ui, comm); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,344 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer/provisioner"
+)
+
+var hives = map[string]string{
+	"HKLM":                "LocalMachine",
+	"HKEY_LOCAL_MACHINE":  "LocalMachine",
+	"HKCU":                "CurrentUser",
+	"HKEY_CURRENT_USER":   "CurrentUser",
+	"HKCR":                "ClassesRoot",
+	"HKEY_CLASSES_ROOT":   "ClassesRoot",
+	"HKU":                 "Users",
+	"HKEY_USERS":          "Users",
+	"HKCC":                "CurrentConfig",
+	"HKEY_CURRENT_CONFIG": "CurrentConfig",
+}
+
+var kinds = map[string]string{
+	"string":       "String",
+	"expandstring": "ExpandString",
+	"dword":        "DWord",
+	"qword":        "QWord",
+	"multistring":  "MultiString",
+	"binary":       "Binary",
+}
+
+// parsePath splits a registry path such as HKLM:\SOFTWARE\Foo into the
+// name of its hive and the path of the subkey.
+func parsePath(path string) (string, string, error) {
+	if path == "" {
+		return "", "", errors.New("path must be specified")
+	}
+
+	parts := strings.SplitN(path, `\`, 2)
+	hive, ok := hives[strings.ToUpper(strings.TrimSuffix(parts[0], ":"))]
+	if !ok {
+		return "", "", fmt.Errorf("unknown registry hive in path %q", path)
+	}
+
+	subkey := ""
+	if len(parts) == 2 {
+		subkey = strings.Trim(parts[1], `\`)
+	}
+	if subkey == "" {
+		return "", "", fmt.Errorf("path %q must name a key below the hive", path)
+	}
+
+	return hive, subkey, nil
+}
+
+func parseView(view string) (string, error) {
+	switch view {
+	case "", "64":
+		return "Registry64", nil
+	case "32":
+		return "Registry32", nil
+	default:
+		return "", fmt.Errorf("view must be 32 or 64, got %q", view)
+	}
+}
+
+func parseState(state string) (bool, error) {
+	switch state {
+	case "", "present":
+		return true, nil
+	case "absent":
+		return false, nil
+	default:
+		return false, fmt.Errorf("state must be present or absent, got %q", state)
+	}
+}
+
+func (k *Key) prepare() error {
+	var err error
+	if k.hive, k.subkey, err = parsePath(k.Path); err != nil {
+		return err
+	}
+	if k.view, err = parseView(k.View); err != nil {
+		return err
+	}
+	if k.present, err = parseState(k.State); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v *Value) prepare() error {
+	var err error
+	if v.hive, v.subkey, err = parsePath(v.Path); err != nil {
+		return err
+	}
+	if v.view, err = parseView(v.View); err != nil {
+		return err
+	}
+	if v.present, err = parseState(v.State); err != nil {
+		return err
+	}
+	if !v.present {
+		return nil
+	}
+
+	if v.Type == "" {
+		v.Type = "String"
+	}
+	var ok bool
+	if v.kind, ok = kinds[strings.ToLower(v.Type)]; !ok {
+		return fmt.Errorf("unknown value type %q", v.Type)
+	}
+
+	if v.literal, err = literal(v.kind, v.Data); err != nil {
+		return fmt.Errorf("bad %s data for %q: %s", v.kind, v.Name, err)
+	}
+
+	return nil
+}
+
+// literal returns the data as a PowerShell expression of the .NET type
+// RegistryKey.SetValue expects for the value kind.
+func literal(kind string, data interface{}) (string, error) {
+	switch kind {
+	case "String", "ExpandString":
+		s, err := toString(data)
+		if err != nil {
+			return "", err
+		}
+		return provisioner.PowerShellQuote(s), nil
+	case "DWord":
+		// DWORDs are unsigned, but the registry API takes and returns them
+		// as Int32, so values above MaxInt32 wrap around.
+		n, err := toInt(data, 32)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("([int32]%d)", int32(n)), nil
+	case "QWord":
+		n, err := toInt(data, 64)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("([int64]%d)", n), nil
+	case "MultiString":
+		var items []interface{}
+		switch d := data.(type) {
+		case nil:
+		case []interface{}:
+			items = d
+		default:
+			items = []interface{}{d}
+		}
+		quoted := make([]string, 0, len(items))
+		for _, item := range items {
+			s, err := toString(item)
+			if err != nil {
+				return "", err
+			}
+			quoted = append(quoted, provisioner.PowerShellQuote(s))
+		}
+		return fmt.Sprintf("([string[]]@(%s))", strings.Join(quoted, ",")), nil
+	case "Binary":
+		b, err := toBytes(data)
+		if err != nil {
+			return "", err
+		}
+		hexed := make([]string, len(b))
+		for i, c := range b {
+			hexed[i] = fmt.Sprintf("0x%02x", c)
+		}
+		return fmt.Sprintf("([byte[]]@(%s))", strings.Join(hexed, ",")), nil
+	}
+
+	return "", fmt.Errorf("unknown value type %q", kind)
+}
+
+func toString(data interface{}) (string, error) {
+	switch d := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return d, nil
+	case bool, int, int64, float64:
+		return fmt.Sprint(d), nil
+	default:
+		return "", fmt.Errorf("expected a string, got %v", data)
+	}
+}
+
+// toInt accepts numbers and strings such as "42" or "0xffffffff". Both
+// signed and unsigned values of the given size are allowed.
+func toInt(data interface{}, bits int) (int64, error) {
+	var s string
+	switch d := data.(type) {
+	case int:
+		s = strconv.Itoa(d)
+	case int64:
+		s = strconv.FormatInt(d, 10)
+	case float64:
+		if d != float64(int64(d)) {
+			return 0, fmt.Errorf("%v is not an integer", d)
+		}
+		s = strconv.FormatInt(int64(d), 10)
+	case string:
+		s = strings.TrimSpace(d)
+	default:
+		return 0, fmt.Errorf("expected a number, got %v", data)
+	}
+
+	if n, err := strconv.ParseInt(s, 0, bits); err == nil {
+		return n, nil
+	}
+	u, err := strconv.ParseUint(s, 0, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a %d-bit integer", s, bits)
+	}
+	return int64(u), nil
+}
+
+// toBytes accepts a hex string, optionally separated by spaces, commas or
+// colons, or a list of byte values.
+func toBytes(data interface{}) ([]byte, error) {
+	switch d := data.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s := strings.NewReplacer(" ", "", ",", "", ":", "").Replace(d)
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a hex string", d)
+		}
+		return b, nil
+	case []interface{}:
+		b := make([]byte, len(d))
+		for i, item := range d {
+			n, err := toInt(item, 64)
+			if err != nil || n < 0 || n > 255 {
+				return nil, fmt.Errorf("%v is not a byte", item)
+			}
+			b[i] = byte(n)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("expected a hex string or a list of bytes, got %v", data)
+	}
+}
+
+const scriptHeader = `$ErrorActionPreference = 'Stop'
+$changed = 0
+
+function Open-PackerBaseKey($hive, $view) {
+    [Microsoft.Win32.RegistryKey]::OpenBaseKey([Microsoft.Win32.RegistryHive]::$hive, [Microsoft.Win32.RegistryView]::$view)
+}
+
+function Set-PackerKey($hive, $view, $path) {
+    $base = Open-PackerBaseKey $hive $view
+    $key = $base.OpenSubKey($path)
+    if ($null -eq $key) {
+        $key = $base.CreateSubKey($path)
+        Write-Host "Created key $hive\$path ($view)"
+        $script:changed++
+    }
+    $key.Close()
+}
+
+function Remove-PackerKey($hive, $view, $path) {
+    $base = Open-PackerBaseKey $hive $view
+    $key = $base.OpenSubKey($path)
+    if ($null -ne $key) {
+        $key.Close()
+        $base.DeleteSubKeyTree($path)
+        Write-Host "Removed key $hive\$path ($view)"
+        $script:changed++
+    }
+}
+
+function Set-PackerValue($hive, $view, $path, $name, $kind, $data) {
+    $key = (Open-PackerBaseKey $hive $view).CreateSubKey($path)
+    $current = $key.GetValue($name, $null, 'DoNotExpandEnvironmentNames')
+    $same = $false
+    if ($null -ne $current -and $key.GetValueKind($name) -eq $kind) {
+        $same = (@($current) -join [char]0) -ceq (@($data) -join [char]0)
+    }
+    if (-not $same) {
+        $key.SetValue($name, $data, [Microsoft.Win32.RegistryValueKind]::$kind)
+        Write-Host "Set value $hive\$path\$name ($view)"
+        $script:changed++
+    }
+    $key.Close()
+}
+
+function Remove-PackerValue($hive, $view, $path, $name) {
+    $key = (Open-PackerBaseKey $hive $view).OpenSubKey($path, $true)
+    if ($null -eq $key) {
+        return
+    }
+    if ($key.GetValueNames() -contains $name) {
+        $key.DeleteValue($name)
+        Write-Host "Removed value $hive\$path\$name ($view)"
+        $script:changed++
+    }
+    $key.Close()
+}
+
+`
+
+const scriptFooter = `
+Write-Host "$changed registry change(s) made"
+exit 0
+`
+
+// generateScript renders all keys and values into a single script that
+// only touches the registry where it differs from the desired state.
+func generateScript(keys []Key, values []Value) string {
+	var buf bytes.Buffer
+	buf.WriteString(scriptHeader)
+
+	for _, k := range keys {
+		if k.present {
+			fmt.Fprintf(&buf, "Set-PackerKey '%s' '%s' %s\n", k.hive, k.view, provisioner.PowerShellQuote(k.subkey))
+		} else {
+			fmt.Fprintf(&buf, "Remove-PackerKey '%s' '%s' %s\n", k.hive, k.view, provisioner.PowerShellQuote(k.subkey))
+		}
+	}
+
+	for _, v := range values {
+		if v.present {
+			fmt.Fprintf(&buf, "Set-PackerValue '%s' '%s' %s %s '%s' %s\n",
+				v.hive, v.view, provisioner.PowerShellQuote(v.subkey), provisioner.PowerShellQuote(v.Name), v.kind, v.literal)
+		} else {
+			fmt.Fprintf(&buf, "Remove-PackerValue '%s' '%s' %s %s\n",
+				v.hive, v.view, provisioner.PowerShellQuote(v.subkey), provisioner.PowerShellQuote(v.Name))
+		}
+	}
+
+	buf.WriteString(scriptFooter)
+	return buf.String()
+}
@@ -2,11 +2,11 @@ package restart
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/hashicorp/packer/common"
@@ -18,6 +18,11 @@ import (
 
 var DefaultRestartCommand = "shutdown /r /f /t 0 /c \"packer restart\""
 var DefaultRestartCheckCommand = winrm.Powershell(`if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'}; echo "${env:COMPUTERNAME} restarted."`)
+var DefaultForceRestartCommand = "shutdown /r /f /t 0 /c \"packer forced restart\""
+
+// PendingRestartCommand exits with 3010, the status of installs that need
+// a restart, if Windows Update, servicing or file renames wait for one.
+var PendingRestartCommand = winrm.Powershell(`$pending = (Test-Path 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending') -or (Test-Path 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired') -or ($null -ne (Get-ItemProperty 'HKLM:\SYSTEM\CurrentControlSet\Control\Session Manager' -Name PendingFileRenameOperations -ErrorAction SilentlyContinue)); if ($pending) { exit 3010 }; exit 0`)
 var retryableSleep = 5 * time.Second
 var TryCheckReboot = "shutdown.exe -f -r -t 60"
 var AbortReboot = "shutdown.exe -a"
@@ -35,15 +40,24 @@ type Config struct {
 	// The timeout for waiting for the machine to restart
 	RestartTimeout time.Duration `mapstructure:"restart_timeout"`
 
+	// The command run if the machine didn't come back from the restart
+	// after ForceRestartTimeout, which must be shorter than RestartTimeout.
+	// Zero means the restart is never forced.
+	ForceRestartCommand string        `mapstructure:"force_restart_command"`
+	ForceRestartTimeout time.Duration `mapstructure:"force_restart_timeout"`
+
+	// The number of times the machine is restarted while a restart is
+	// still pending afterwards. Pending restarts are only checked if it
+	// is more than 1.
+	MaxRestarts int `mapstructure:"max_restarts"`
+
 	ctx interpolate.Context
 }
 
 type Provisioner struct {
-	config     Config
-	comm       packer.Communicator
-	ui         packer.Ui
-	cancel     chan struct{}
-	cancelLock sync.Mutex
+	config Config
+	comm   packer.Communicator
+	ui     packer.Ui
 }
 
 func (p *Provisioner) Prepare(raws ...interface{}) error {
@@ -72,23 +86,71 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		p.config.RestartTimeout = 5 * time.Minute
 	}
 
+	if p.config.ForceRestartCommand == "" {
+		p.config.ForceRestartCommand = DefaultForceRestartCommand
+	}
+
+	if p.config.MaxRestarts == 0 {
+		p.config.MaxRestarts = 1
+	}
+
+	var errs *packer.MultiError
+	if p.config.ForceRestartTimeout < 0 || p.config.ForceRestartTimeout >= p.config.RestartTimeout {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("force_restart_timeout must be shorter than restart_timeout"))
+	}
+
+	if p.config.MaxRestarts < 0 {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("max_restarts must not be negative"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
 	return nil
 }
 
-func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
-	p.cancelLock.Lock()
-	p.cancel = make(chan struct{})
-	p.cancelLock.Unlock()
-
-	ui.Say("Restarting Machine")
+func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	p.comm = comm
 	p.ui = ui
 
+	// Some changes, like chained updates, need more than one restart
+	for restarts := 1; ; restarts++ {
+		ui.Say("Restarting Machine")
+		if err := p.restart(ctx, ui, comm); err != nil {
+			return err
+		}
+		if err := waitForRestart(ctx, p, comm); err != nil {
+			return err
+		}
+
+		if p.config.MaxRestarts <= 1 {
+			return nil
+		}
+		pending, err := restartPending(ctx, p)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			return nil
+		}
+		if restarts >= p.config.MaxRestarts {
+			return fmt.Errorf("A restart is still pending after %d restarts, "+
+				"the machine may be in a restart loop", restarts)
+		}
+		ui.Say(fmt.Sprintf("A restart is still pending after restart %d of %d", restarts, p.config.MaxRestarts))
+	}
+}
+
+// restart runs the restart command.
+func (p *Provisioner) restart(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
 	var cmd *packer.RemoteCmd
 	command := p.config.RestartCommand
 	err := p.retryable(func() error {
 		cmd = &packer.RemoteCmd{Command: command}
-		return cmd.StartWithUi(comm, ui)
+		return cmd.RunWithUi(ctx, comm, ui)
 	})
 
 	if err != nil {
@@ -99,10 +161,10 @@ func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 		return fmt.Errorf("Restart script exited with non-zero exit status: %d", cmd.ExitStatus)
 	}
 
-	return waitForRestart(p, comm)
+	return nil
 }
 
-var waitForRestart = func(p *Provisioner, comm packer.Communicator) error {
+var waitForRestart = func(ctx context.Context, p *Provisioner, comm packer.Communicator) error {
 	ui := p.ui
 	ui.Say("Waiting for machine to restart...")
 	waitDone := make(chan bool, 1)
@@ -117,7 +179,7 @@ var waitForRestart = func(p *Provisioner, comm packer.Communicator) error {
 	for {
 		log.Printf("Check if machine is rebooting...")
 		cmd = &packer.RemoteCmd{Command: trycommand}
-		err = cmd.StartWithUi(comm, ui)
+		err = cmd.RunWithUi(ctx, comm, ui)
 		if err != nil {
 			// Couldn't execute, we assume machine is rebooting already
 			break
@@ -131,19 +193,30 @@ var waitForRestart = func(p *Provisioner, comm packer.Communicator) error {
 		if cmd.ExitStatus == 0 {
 			// Cancel reboot we created to test if machine was already rebooting
 			cmd = &packer.RemoteCmd{Command: abortcommand}
-			cmd.StartWithUi(comm, ui)
+			cmd.RunWithUi(ctx, comm, ui)
 			break
 		}
 	}
 
+	// Stops waiting for the communicator once we're done here
+	waitCtx, cancelWait := context.WithCancel(ctx)
+	defer cancelWait()
+
 	go func() {
 		log.Printf("Waiting for machine to become available...")
-		err = waitForCommunicator(p)
+		err = waitForCommunicator(waitCtx, p)
 		waitDone <- true
 	}()
 
 	log.Printf("Waiting for machine to reboot with timeout: %s", p.config.RestartTimeout)
 
+	// The restart is forced once if the machine isn't back in time, e.g.
+	// because an application blocked the shutdown
+	var force <-chan time.Time
+	if p.config.ForceRestartTimeout > 0 {
+		force = time.After(p.config.ForceRestartTimeout)
+	}
+
 WaitLoop:
 	for {
 		// Wait for either WinRM to become available, a timeout to occur,
@@ -156,15 +229,20 @@ WaitLoop:
 			}
 
 			ui.Say("Machine successfully restarted, moving on")
-			close(p.cancel)
 			break WaitLoop
+		case <-force:
+			force = nil
+			ui.Say(fmt.Sprintf("Machine didn't restart within %s, forcing a restart...", p.config.ForceRestartTimeout))
+			cmd := &packer.RemoteCmd{Command: p.config.ForceRestartCommand}
+			if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+				// The machine may be down already
+				log.Printf("Error forcing the restart: %s", err)
+			}
 		case <-timeout:
 			err := fmt.Errorf("Timeout waiting for machine to restart.")
 			ui.Error(err.Error())
-			close(p.cancel)
 			return err
-		case <-p.cancel:
-			close(waitDone)
+		case <-ctx.Done():
 			return fmt.Errorf("Interrupt detected, quitting waiting for machine to restart")
 		}
 	}
@@ -173,14 +251,14 @@ WaitLoop:
 
 }
 
-var waitForCommunicator = func(p *Provisioner) error {
+var waitForCommunicator = func(ctx context.Context, p *Provisioner) error {
 	for {
 		cmd := &packer.RemoteCmd{Command: p.config.RestartCheckCommand}
 		var buf, buf2 bytes.Buffer
 		cmd.Stdout = &buf
 		cmd.Stdout = io.MultiWriter(cmd.Stdout, &buf2)
 		select {
-		case <-p.cancel:
+		case <-ctx.Done():
 			log.Println("Communicator wait canceled, exiting loop")
 			return fmt.Errorf("Communicator wait canceled")
 		case <-time.After(retryableSleep):
@@ -188,7 +266,7 @@ var waitForCommunicator = func(p *Provisioner) error {
 
 		log.Printf("Checking that communicator is connected with: '%s'", cmd.Command)
 
-		err := cmd.StartWithUi(p.comm, p.ui)
+		err := cmd.RunWithUi(ctx, p.comm, p.ui)
 
 		if err != nil {
 			log.Printf("Communication connection err: %s", err)
@@ -207,13 +285,24 @@ var waitForCommunicator = func(p *Provisioner) error {
 	return nil
 }
 
-func (p *Provisioner) Cancel() {
-	log.Printf("Received interrupt Cancel()")
+// restartPending returns true if a restart is pending on the machine.
+var restartPending = func(ctx context.Context, p *Provisioner) (bool, error) {
+	var cmd *packer.RemoteCmd
+	err := p.retryable(func() error {
+		cmd = &packer.RemoteCmd{Command: PendingRestartCommand}
+		return cmd.RunWithUi(ctx, p.comm, p.ui)
+	})
+	if err != nil {
+		return false, fmt.Errorf("Error checking for a pending restart: %s", err)
+	}
 
-	p.cancelLock.Lock()
-	defer p.cancelLock.Unlock()
-	if p.cancel != nil {
-		close(p.cancel)
+	switch cmd.ExitStatus {
+	case 0:
+		return false, nil
+	case 3010:
+		return true, nil
+	default:
+		return false, fmt.Errorf("Checking for a pending restart exited with status: %d", cmd.ExitStatus)
 	}
 }
 
@@ -227,6 +316,11 @@ func (p *Provisioner) retryable(f func() error) error {
 			return nil
 		}
 
+		// Commands of cancelled provisioners aren't retried
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return err
+		}
+
 		// Create an error and log it
 		err = fmt.Errorf("Retryable error: %s", err)
 		log.Print(err.Error())
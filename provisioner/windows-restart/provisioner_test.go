@@ -2,6 +2,7 @@ package restart
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -66,6 +67,40 @@ func TestProvisionerPrepare_ConfigErrors(t *testing.T) {
 	}
 }
 
+func TestProvisionerPrepare_ForceRestart(t *testing.T) {
+	cases := []struct {
+		Timeout     string
+		MaxRestarts int
+		Err         bool
+	}{
+		{"", 0, false},
+		{"2m", 3, false},
+		{"5m", 0, true},
+		{"-1m", 0, true},
+		{"", -1, true},
+	}
+
+	for _, tc := range cases {
+		var p Provisioner
+		config := testConfig()
+		if tc.Timeout != "" {
+			config["force_restart_timeout"] = tc.Timeout
+		}
+		config["max_restarts"] = tc.MaxRestarts
+
+		err := p.Prepare(config)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc, err)
+		}
+		if err == nil && p.config.ForceRestartCommand != DefaultForceRestartCommand {
+			t.Fatalf("unexpected force restart command: %s", p.config.ForceRestartCommand)
+		}
+		if err == nil && tc.MaxRestarts == 0 && p.config.MaxRestarts != 1 {
+			t.Fatalf("unexpected max restarts: %d", p.config.MaxRestarts)
+		}
+	}
+}
+
 func TestProvisionerPrepare_InvalidKey(t *testing.T) {
 	var p Provisioner
 	config := testConfig()
@@ -97,14 +132,14 @@ func TestProvisionerProvision_Success(t *testing.T) {
 	comm := new(packer.MockCommunicator)
 	p.Prepare(config)
 	waitForCommunicatorOld := waitForCommunicator
-	waitForCommunicator = func(p *Provisioner) error {
+	waitForCommunicator = func(context.Context, *Provisioner) error {
 		return nil
 	}
 	waitForRestartOld := waitForRestart
-	waitForRestart = func(p *Provisioner, comm packer.Communicator) error {
+	waitForRestart = func(context.Context, *Provisioner, packer.Communicator) error {
 		return nil
 	}
-	err := p.Provision(ui, comm)
+	err := p.Provision(context.Background(), ui, comm)
 	if err != nil {
 		t.Fatal("should not have error")
 	}
@@ -133,14 +168,14 @@ func TestProvisionerProvision_CustomCommand(t *testing.T) {
 	comm := new(packer.MockCommunicator)
 	p.Prepare(config)
 	waitForCommunicatorOld := waitForCommunicator
-	waitForCommunicator = func(p *Provisioner) error {
+	waitForCommunicator = func(context.Context, *Provisioner) error {
 		return nil
 	}
 	waitForRestartOld := waitForRestart
-	waitForRestart = func(p *Provisioner, comm packer.Communicator) error {
+	waitForRestart = func(context.Context, *Provisioner, packer.Communicator) error {
 		return nil
 	}
-	err := p.Provision(ui, comm)
+	err := p.Provision(context.Background(), ui, comm)
 	if err != nil {
 		t.Fatal("should not have error")
 	}
@@ -163,7 +198,7 @@ func TestProvisionerProvision_RestartCommandFail(t *testing.T) {
 	comm.StartExitStatus = 1
 
 	p.Prepare(config)
-	err := p.Provision(ui, comm)
+	err := p.Provision(context.Background(), ui, comm)
 	if err == nil {
 		t.Fatal("should have error")
 	}
@@ -179,10 +214,10 @@ func TestProvisionerProvision_WaitForRestartFail(t *testing.T) {
 	comm := new(packer.MockCommunicator)
 	p.Prepare(config)
 	waitForCommunicatorOld := waitForCommunicator
-	waitForCommunicator = func(p *Provisioner) error {
+	waitForCommunicator = func(context.Context, *Provisioner) error {
 		return fmt.Errorf("Machine did not restart properly")
 	}
-	err := p.Provision(ui, comm)
+	err := p.Provision(context.Background(), ui, comm)
 	if err == nil {
 		t.Fatal("should have error")
 	}
@@ -191,6 +226,102 @@ func TestProvisionerProvision_WaitForRestartFail(t *testing.T) {
 	waitForCommunicator = waitForCommunicatorOld
 }
 
+func TestProvisionerProvision_MaxRestarts(t *testing.T) {
+	cases := []struct {
+		Pending  int
+		Restarts int
+		Err      bool
+	}{
+		{0, 1, false},
+		{2, 3, false},
+		{5, 3, true},
+	}
+
+	waitForRestartOld := waitForRestart
+	restartPendingOld := restartPending
+	defer func() {
+		waitForRestart = waitForRestartOld
+		restartPending = restartPendingOld
+	}()
+
+	for _, tc := range cases {
+		config := testConfig()
+		config["max_restarts"] = 3
+		p := new(Provisioner)
+		if err := p.Prepare(config); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		restarts := 0
+		waitForRestart = func(context.Context, *Provisioner, packer.Communicator) error {
+			restarts++
+			return nil
+		}
+		restartPending = func(context.Context, *Provisioner) (bool, error) {
+			return restarts <= tc.Pending, nil
+		}
+
+		err := p.Provision(context.Background(), testUi(), new(packer.MockCommunicator))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: err: %v", tc, err)
+		}
+		if restarts != tc.Restarts {
+			t.Fatalf("%#v: bad restarts: %d", tc, restarts)
+		}
+	}
+}
+
+func TestProvision_restartPending(t *testing.T) {
+	cases := map[int]bool{0: false, 3010: true, 1: false}
+
+	for status, expected := range cases {
+		p := new(Provisioner)
+		p.Prepare(testConfig())
+		comm := new(packer.MockCommunicator)
+		comm.StartExitStatus = status
+		p.comm = comm
+		p.ui = testUi()
+
+		pending, err := restartPending(context.Background(), p)
+		if (err != nil) != (status == 1) {
+			t.Fatalf("%d: err: %v", status, err)
+		}
+		if pending != expected {
+			t.Fatalf("%d: bad: %t", status, pending)
+		}
+		if comm.StartCmd.Command != PendingRestartCommand {
+			t.Fatalf("bad command: %s", comm.StartCmd.Command)
+		}
+	}
+}
+
+func TestProvision_waitForRestartForce(t *testing.T) {
+	config := testConfig()
+	config["force_restart_timeout"] = "10ms"
+	config["force_restart_command"] = "restart-computer -force"
+	p := new(Provisioner)
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	comm := new(packer.MockCommunicator)
+	p.ui = testUi()
+
+	// The machine comes back after the restart was forced
+	waitForCommunicatorOld := waitForCommunicator
+	waitForCommunicator = func(ctx context.Context, p *Provisioner) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}
+	defer func() { waitForCommunicator = waitForCommunicatorOld }()
+
+	if err := waitForRestart(context.Background(), p, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if comm.StartCmd.Command != "restart-computer -force" {
+		t.Fatalf("should force the restart, got: %s", comm.StartCmd.Command)
+	}
+}
+
 func TestProvision_waitForRestartTimeout(t *testing.T) {
 	retryableSleep = 10 * time.Millisecond
 	config := testConfig()
@@ -206,7 +337,7 @@ func TestProvision_waitForRestartTimeout(t *testing.T) {
 	waitContinue := make(chan bool)
 
 	// Block until cancel comes through
-	waitForCommunicator = func(p *Provisioner) error {
+	waitForCommunicator = func(context.Context, *Provisioner) error {
 		for {
 			select {
 			case <-waitDone:
@@ -216,7 +347,7 @@ func TestProvision_waitForRestartTimeout(t *testing.T) {
 	}
 
 	go func() {
-		err = p.Provision(ui, comm)
+		err = p.Provision(context.Background(), ui, comm)
 		waitDone <- true
 	}()
 	<-waitContinue
@@ -245,7 +376,7 @@ func TestProvision_waitForCommunicator(t *testing.T) {
 	comm.StartStdout = "WIN-V4CEJ7MC5SN restarted."
 	comm.StartExitStatus = 1
 	p.Prepare(config)
-	err := waitForCommunicator(p)
+	err := waitForCommunicator(context.Background(), p)
 
 	if err != nil {
 		t.Fatalf("should not have error, got: %s", err.Error())
@@ -271,7 +402,7 @@ func TestProvision_waitForCommunicatorWithCancel(t *testing.T) {
 	p.comm = comm
 	p.ui = ui
 	retryableSleep = 5 * time.Second
-	p.cancel = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
 	var err error
 
 	comm.StartStderr = "WinRM terminated"
@@ -285,14 +416,14 @@ func TestProvision_waitForCommunicatorWithCancel(t *testing.T) {
 	waitDone := make(chan bool)
 	go func() {
 		waitStart <- true
-		err = waitForCommunicator(p)
+		err = waitForCommunicator(ctx, p)
 		waitDone <- true
 	}()
 
 	go func() {
 		time.Sleep(10 * time.Millisecond)
 		<-waitStart
-		p.Cancel()
+		cancel()
 	}()
 	<-waitDone
 
@@ -345,27 +476,27 @@ func TestProvision_Cancel(t *testing.T) {
 	p.Prepare(config)
 	waitStart := make(chan bool)
 	waitDone := make(chan bool)
+	ctx, cancel := context.WithCancel(context.Background())
 
 	// Block until cancel comes through
-	waitForCommunicator = func(p *Provisioner) error {
+	waitForCommunicatorOld := waitForCommunicator
+	waitForCommunicator = func(ctx context.Context, p *Provisioner) error {
 		waitStart <- true
-		for {
-			select {
-			case <-p.cancel:
-			}
-		}
+		<-ctx.Done()
+		return ctx.Err()
 	}
+	defer func() { waitForCommunicator = waitForCommunicatorOld }()
 
 	// Create two go routines to provision and cancel in parallel
 	// Provision will block until cancel happens
 	go func() {
-		err = p.Provision(ui, comm)
+		err = p.Provision(ctx, ui, comm)
 		waitDone <- true
 	}()
 
 	go func() {
 		<-waitStart
-		p.Cancel()
+		cancel()
 	}()
 	<-waitDone
 
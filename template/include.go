@@ -0,0 +1,352 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/mapstructure"
+)
+
+// rawInclude is the format of the files listed in the "includes" of a
+// template. They can share variables, named blocks of provisioners and
+// post-processors, and the naming convention between templates.
+type rawInclude struct {
+	Includes            []string
+	Naming              map[string]interface{}
+	Variables           map[string]interface{}
+	ProvisionerBlocks   map[string][]map[string]interface{} `mapstructure:"provisioner_blocks"`
+	PostProcessorBlocks map[string][]interface{}            `mapstructure:"post_processor_blocks"`
+}
+
+// BlockKey is the key of a provisioner or post-processor that refers to a
+// named block instead of defining a component.
+const BlockKey = "block"
+
+// resolveIncludes reads the includes of the template, relative to dir,
+// and merges their variables and blocks into the template. The template
+// overrides its includes and later includes override earlier ones.
+func (r *rawTemplate) resolveIncludes(dir string) error {
+	merged := &rawInclude{
+		Variables:           make(map[string]interface{}),
+		ProvisionerBlocks:   make(map[string][]map[string]interface{}),
+		PostProcessorBlocks: make(map[string][]interface{}),
+	}
+
+	if err := readIncludes(merged, r.Includes, dir, nil); err != nil {
+		return err
+	}
+
+	// The template's own definitions win
+	for k, v := range r.Variables {
+		merged.Variables[k] = v
+	}
+	for k, v := range r.ProvisionerBlocks {
+		merged.ProvisionerBlocks[k] = v
+	}
+	for k, v := range r.PostProcessorBlocks {
+		merged.PostProcessorBlocks[k] = v
+	}
+	if len(r.Naming) > 0 {
+		merged.Naming = r.Naming
+	}
+
+	if len(merged.Variables) > 0 {
+		r.Variables = merged.Variables
+	}
+	r.ProvisionerBlocks = merged.ProvisionerBlocks
+	r.PostProcessorBlocks = merged.PostProcessorBlocks
+	r.Naming = merged.Naming
+	return nil
+}
+
+func readIncludes(result *rawInclude, paths []string, dir string, parents []string) error {
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("include %s: %s", path, err)
+		}
+
+		for _, parent := range parents {
+			if parent == path {
+				return fmt.Errorf("include %s: includes itself", path)
+			}
+		}
+
+		include, err := parseInclude(path)
+		if err != nil {
+			return err
+		}
+
+		// Nested includes are overridden by the file that includes them
+		err = readIncludes(result, include.Includes, filepath.Dir(path), append(parents, path))
+		if err != nil {
+			return err
+		}
+
+		for k, v := range include.Variables {
+			result.Variables[k] = v
+		}
+		for k, v := range include.ProvisionerBlocks {
+			result.ProvisionerBlocks[k] = v
+		}
+		for k, v := range include.PostProcessorBlocks {
+			result.PostProcessorBlocks[k] = v
+		}
+		if len(include.Naming) > 0 {
+			result.Naming = include.Naming
+		}
+	}
+
+	return nil
+}
+
+func parseInclude(path string) (*rawInclude, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("include %s: %s", path, err)
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(contents, &raw); err != nil {
+		return nil, fmt.Errorf("include %s: %s", path, err)
+	}
+
+	var md mapstructure.Metadata
+	var result rawInclude
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata: &md,
+		Result:   &result,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decoder.Decode(raw); err != nil {
+		return nil, fmt.Errorf("include %s: %s", path, err)
+	}
+
+	var errs error
+	sort.Strings(md.Unused)
+	for _, unused := range md.Unused {
+		// Ignore keys starting with '_' as comments
+		if unused[0] == '_' {
+			continue
+		}
+
+		errs = multierror.Append(errs, fmt.Errorf(
+			"include %s: unknown root level key: '%s'", path, unused))
+	}
+
+	return &result, errs
+}
+
+// expandBlocks replaces the provisioners and post-processors that refer
+// to a block with the contents of the block.
+func (r *rawTemplate) expandBlocks() error {
+	var errs error
+	if err := r.expandProvisionerBlocks(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if err := r.expandPostProcessorBlocks(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	return errs
+}
+
+// expandProvisionerBlocks replaces the provisioners that refer to a block
+// with the provisioners of the block, which can refer to other blocks. The
+// "only" and "except" of a reference apply to provisioners of the block
+// that don't set their own.
+func (r *rawTemplate) expandProvisionerBlocks() error {
+	result, err := r.expandProvisioners(r.Provisioners, func(i int) string {
+		return fmt.Sprintf("provisioner %d", i+1)
+	}, nil)
+
+	r.Provisioners = result
+	return err
+}
+
+// expandProvisioners expands the block references of the provisioners.
+// where names a provisioner in errors, and blocks are the names of the
+// blocks being expanded, to find blocks that refer to themselves.
+func (r *rawTemplate) expandProvisioners(ps []map[string]interface{}, where func(int) string, blocks []string) ([]map[string]interface{}, error) {
+	var errs error
+	result := make([]map[string]interface{}, 0, len(ps))
+	for i, v := range ps {
+		rawName, ok := v[BlockKey]
+		if !ok {
+			result = append(result, v)
+			continue
+		}
+
+		name := fmt.Sprint(rawName)
+		if cycle := blockCycle(blocks, name); cycle != "" {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"%s: block '%s' refers to itself: %s", where(i), name, cycle))
+			continue
+		}
+
+		block, ok := r.ProvisionerBlocks[name]
+		if !ok {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"%s: unknown block '%s'", where(i), name))
+			continue
+		}
+
+		for k := range v {
+			if k != BlockKey && k != "only" && k != "except" {
+				errs = multierror.Append(errs, fmt.Errorf(
+					"%s: unknown key for block reference: '%s'", where(i), k))
+			}
+		}
+
+		expanded, err := r.expandProvisioners(block, func(j int) string {
+			return fmt.Sprintf("%s: block '%s' provisioner %d", where(i), name, j+1)
+		}, append(blocks, name))
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+
+		for _, p := range expanded {
+			p := copyRaw(p).(map[string]interface{})
+			for _, k := range []string{"only", "except"} {
+				if _, ok := p[k]; !ok && v[k] != nil {
+					p[k] = copyRaw(v[k])
+				}
+			}
+
+			result = append(result, p)
+		}
+	}
+
+	return result, errs
+}
+
+// expandPostProcessorBlocks replaces the post-processors that refer to a
+// block with the post-processors of the block, which can refer to other
+// blocks. A reference in a sequence is replaced by the post-processors of
+// the block within the sequence, so the block can't have sequences.
+func (r *rawTemplate) expandPostProcessorBlocks() error {
+	result, err := r.expandPostProcessors(r.PostProcessors, func(i int) string {
+		return fmt.Sprintf("post-processor %d", i+1)
+	}, false, nil)
+
+	r.PostProcessors = result
+	return err
+}
+
+// expandPostProcessors expands the block references of the post-processors,
+// which are the elements of a sequence if inSequence is true. where and
+// blocks are like for expandProvisioners.
+func (r *rawTemplate) expandPostProcessors(pps []interface{}, where func(int) string, inSequence bool, blocks []string) ([]interface{}, error) {
+	var errs error
+	result := make([]interface{}, 0, len(pps))
+	for i, v := range pps {
+		switch v := v.(type) {
+		case []interface{}:
+			if inSequence {
+				// Nested sequences are rejected when parsing
+				result = append(result, v)
+				continue
+			}
+
+			sequence, err := r.expandPostProcessors(v, func(j int) string {
+				return fmt.Sprintf("%s.%d", where(i), j+1)
+			}, true, blocks)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			result = append(result, sequence)
+			continue
+		case map[string]interface{}:
+			rawName, ok := v[BlockKey]
+			if !ok {
+				break
+			}
+
+			name := fmt.Sprint(rawName)
+			if cycle := blockCycle(blocks, name); cycle != "" {
+				errs = multierror.Append(errs, fmt.Errorf(
+					"%s: block '%s' refers to itself: %s", where(i), name, cycle))
+				continue
+			}
+
+			block, ok := r.PostProcessorBlocks[name]
+			if !ok {
+				errs = multierror.Append(errs, fmt.Errorf(
+					"%s: unknown block '%s'", where(i), name))
+				continue
+			}
+
+			if len(v) > 1 {
+				errs = multierror.Append(errs, fmt.Errorf(
+					"%s: block references can't have other keys", where(i)))
+			}
+
+			expanded, err := r.expandPostProcessors(block, func(j int) string {
+				return fmt.Sprintf("%s: block '%s' post-processor %d", where(i), name, j+1)
+			}, inSequence, append(blocks, name))
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			}
+
+			for _, pp := range expanded {
+				if _, ok := pp.([]interface{}); ok && inSequence {
+					errs = multierror.Append(errs, fmt.Errorf(
+						"%s: block '%s' has a sequence, it can't be used in a sequence",
+						where(i), name))
+					break
+				}
+				result = append(result, copyRaw(pp))
+			}
+			continue
+		}
+
+		result = append(result, v)
+	}
+
+	return result, errs
+}
+
+// blockCycle returns how the block with the name refers to itself if it
+// is one of the blocks being expanded, or an empty string.
+func blockCycle(blocks []string, name string) string {
+	for i, b := range blocks {
+		if b == name {
+			return strings.Join(append(blocks[i:len(blocks):len(blocks)], name), " -> ")
+		}
+	}
+
+	return ""
+}
+
+// copyRaw deep copies decoded JSON so that blocks used several times
+// don't share their configuration.
+func copyRaw(raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			result[k] = copyRaw(e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = copyRaw(e)
+		}
+		return result
+	default:
+		return v
+	}
+}
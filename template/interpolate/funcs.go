@@ -1,16 +1,31 @@
 package interpolate
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
 	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/helper/secrets"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
 )
 
 // InitTime is the UTC time when this package was initialized. It is
@@ -24,20 +39,56 @@ func init() {
 
 // Funcs are the interpolation funcs that are available within interpolations.
 var FuncGens = map[string]FuncGenerator{
-	"build_name":   funcGenBuildName,
-	"build_type":   funcGenBuildType,
-	"env":          funcGenEnv,
-	"isotime":      funcGenIsotime,
-	"pwd":          funcGenPwd,
-	"template_dir": funcGenTemplateDir,
-	"timestamp":    funcGenTimestamp,
-	"uuid":         funcGenUuid,
-	"user":         funcGenUser,
+	"artifact_name": funcGenArtifactName,
+	"aws_ssm":       funcGenAwsSsm,
+	"build_name":    funcGenBuildName,
+	"build_type":    funcGenBuildType,
+	"consul_key":    funcGenConsulKey,
+	"date":          funcGenDate,
+	"env":           funcGenEnv,
+	"format_time":   funcGenFormatTime,
+	"gcp_secret":    funcGenGcpSecret,
+	"git_branch":    funcGenGitBranch,
+	"git_sha":       funcGenGitSha,
+	"isotime":       funcGenIsotime,
+	"pwd":           funcGenPwd,
+	"template_dir":  funcGenTemplateDir,
+	"template_name": funcGenTemplateName,
+	"timestamp":     funcGenTimestamp,
+	"uuid":          funcGenUuid,
+	"user":          funcGenUser,
+
+	"build_artifact":  funcGenBuildArtifact,
+	"latest_artifact": funcGenLatestArtifact,
 
 	"upper": funcGenPrimitive(strings.ToUpper),
 	"lower": funcGenPrimitive(strings.ToLower),
+
+	"base64decode":    funcGenPrimitive(funcBase64Decode),
+	"base64encode":    funcGenPrimitive(funcBase64Encode),
+	"bcrypt":          funcGenPrimitive(funcBcrypt),
+	"jsonencode":      funcGenPrimitive(funcJSONEncode),
+	"random_password": funcGenPrimitive(funcRandomPassword),
+	"rsa_keypair":     funcGenPrimitive(funcRSAKeypair),
+	"rsa_public_key":  funcGenPrimitive(funcRSAPublicKey),
+	"sha256":          funcGenPrimitive(funcSHA256),
+
+	"add": funcGenPrimitive(funcAdd),
+	"div": funcGenPrimitive(funcDiv),
+	"int": funcGenPrimitive(toInt),
+	"mod": funcGenPrimitive(funcMod),
+	"mul": funcGenPrimitive(funcMul),
+	"sub": funcGenPrimitive(funcSub),
 }
 
+// DefaultPasswordCharset is the charset used by random_password if none
+// is given.
+const DefaultPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// DefaultRSAKeyBits is the size of the keys generated by rsa_keypair if
+// none is given.
+const DefaultRSAKeyBits = 2048
+
 // FuncGenerator is a function that given a context generates a template
 // function for the template.
 type FuncGenerator func(*Context) interface{}
@@ -58,6 +109,16 @@ func Funcs(ctx *Context) template.FuncMap {
 	return template.FuncMap(result)
 }
 
+func funcGenArtifactName(ctx *Context) interface{} {
+	return func() (string, error) {
+		if ctx == nil || ctx.ArtifactName == "" {
+			return "", errors.New("artifact_name requires a naming pattern")
+		}
+
+		return ctx.ArtifactName, nil
+	}
+}
+
 func funcGenBuildName(ctx *Context) interface{} {
 	return func() (string, error) {
 		if ctx == nil || ctx.BuildName == "" {
@@ -90,17 +151,367 @@ func funcGenEnv(ctx *Context) interface{} {
 	}
 }
 
+// gitCache keeps the output of git commands by directory and arguments,
+// so that every configuration of a build gets the same values.
+var gitCache = struct {
+	sync.Mutex
+	values map[string]string
+}{values: make(map[string]string)}
+
+// gitRevParse runs git rev-parse with the given arguments in the
+// directory of the template, or the working directory.
+func gitRevParse(ctx *Context, args ...string) (string, error) {
+	dir := "."
+	if ctx != nil && ctx.TemplatePath != "" {
+		dir = filepath.Dir(ctx.TemplatePath)
+	}
+
+	args = append([]string{"rev-parse"}, args...)
+	key := dir + "\x00" + strings.Join(args, " ")
+
+	gitCache.Lock()
+	defer gitCache.Unlock()
+	if v, ok := gitCache.values[key]; ok {
+		return v, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s in %s: %s %s",
+			strings.Join(args, " "), dir, err, strings.TrimSpace(stderr.String()))
+	}
+
+	v := strings.TrimSpace(string(out))
+	gitCache.values[key] = v
+	return v, nil
+}
+
+func funcGenGitBranch(ctx *Context) interface{} {
+	return func() (string, error) {
+		branch, err := gitRevParse(ctx, "--abbrev-ref", "HEAD")
+		if err != nil {
+			return "", fmt.Errorf("git_branch: %s", err)
+		}
+		if branch == "HEAD" {
+			return "", errors.New("git_branch: HEAD is detached, no branch is checked out")
+		}
+
+		// Branches like feature/foo can't be part of most names
+		return strings.Replace(branch, "/", "-", -1), nil
+	}
+}
+
+func funcGenGitSha(ctx *Context) interface{} {
+	return func(length ...int) (string, error) {
+		if len(length) > 1 {
+			return "", fmt.Errorf("too many values, 1 needed: %v", length)
+		}
+
+		sha, err := gitRevParse(ctx, "HEAD")
+		if err != nil {
+			return "", fmt.Errorf("git_sha: %s", err)
+		}
+		if len(length) == 1 && length[0] > 0 && length[0] < len(sha) {
+			sha = sha[:length[0]]
+		}
+
+		return sha, nil
+	}
+}
+
+// SecretCache keeps the values read from secret stores by the consul_key,
+// aws_ssm and gcp_secret functions, by function and arguments, so that
+// every one is read once while the cache is used, and so that they can be
+// redacted. The core has one per run.
+type SecretCache struct {
+	l       sync.Mutex
+	entries map[string]*secretEntry
+}
+
+type secretEntry struct {
+	done  chan struct{}
+	value string
+	err   error
+}
+
+// NewSecretCache returns an empty SecretCache.
+func NewSecretCache() *SecretCache {
+	return &SecretCache{entries: make(map[string]*secretEntry)}
+}
+
+// read returns the cached value for the function and arguments, or reads
+// it. Concurrent reads of the same value wait for the first one, without
+// holding the lock of the cache. Failed reads aren't cached. Without a
+// cache, every value is read.
+func (c *SecretCache) read(fn string, args []string, read func() (string, error)) (string, error) {
+	if c == nil {
+		v, err := read()
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", fn, err)
+		}
+		return v, nil
+	}
+
+	key := fn + "\x00" + strings.Join(args, "\x00")
+	c.l.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.l.Unlock()
+		<-e.done
+		return e.value, e.err
+	}
+	e := &secretEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.l.Unlock()
+
+	e.value, e.err = read()
+	if e.err != nil {
+		e.err = fmt.Errorf("%s: %s", fn, e.err)
+		c.l.Lock()
+		delete(c.entries, key)
+		c.l.Unlock()
+	}
+	close(e.done)
+
+	return e.value, e.err
+}
+
+// Values returns the values read so far, so that they can be redacted.
+func (c *SecretCache) Values() []string {
+	if c == nil {
+		return nil
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	result := make([]string, 0, len(c.entries))
+	for _, e := range c.entries {
+		select {
+		case <-e.done:
+			if e.err == nil && e.value != "" {
+				result = append(result, e.value)
+			}
+		default:
+		}
+	}
+
+	return result
+}
+
+func funcGenAwsSsm(ctx *Context) interface{} {
+	return func(name string, region ...string) (string, error) {
+		if len(region) > 1 {
+			return "", fmt.Errorf("too many values, 1 needed: %v", region)
+		}
+
+		var r string
+		if len(region) == 1 {
+			r = region[0]
+		}
+
+		return ctx.secrets().read("aws_ssm", []string{name, r}, func() (string, error) {
+			return secrets.SSMParameter(name, r)
+		})
+	}
+}
+
+func funcGenConsulKey(ctx *Context) interface{} {
+	return func(key string) (string, error) {
+		return ctx.secrets().read("consul_key", []string{key}, func() (string, error) {
+			return secrets.ConsulKey(key)
+		})
+	}
+}
+
+func funcGenGcpSecret(ctx *Context) interface{} {
+	return func(project, secret string, version ...string) (string, error) {
+		if len(version) > 1 {
+			return "", fmt.Errorf("too many values, 1 needed: %v", version)
+		}
+
+		var v string
+		if len(version) == 1 {
+			v = version[0]
+		}
+
+		return ctx.secrets().read("gcp_secret", []string{project, secret, v}, func() (string, error) {
+			return secrets.GCPSecret(project, secret, v)
+		})
+	}
+}
+
+// buildTime returns the time that the time functions render.
+func buildTime(ctx *Context) time.Time {
+	if ctx != nil && !ctx.BuildTime.IsZero() {
+		return ctx.BuildTime.UTC()
+	}
+
+	return InitTime
+}
+
+// buildTimeIn returns the time that the time functions render in the
+// time zone with the given name, such as "Europe/Paris" or "Local", if
+// any, or in UTC.
+func buildTimeIn(ctx *Context, zone []string) (time.Time, error) {
+	if len(zone) > 1 {
+		return time.Time{}, fmt.Errorf("too many values, 1 needed: %v", zone)
+	}
+
+	t := buildTime(ctx)
+	if len(zone) == 0 || zone[0] == "" {
+		return t, nil
+	}
+
+	loc, err := time.LoadLocation(zone[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", zone[0])
+	}
+
+	return t.In(loc), nil
+}
+
+func funcGenDate(ctx *Context) interface{} {
+	return func(zone ...string) (string, error) {
+		t, err := buildTimeIn(ctx, zone)
+		if err != nil {
+			return "", fmt.Errorf("date: %s", err)
+		}
+
+		return t.Format("2006-01-02"), nil
+	}
+}
+
+func funcGenFormatTime(ctx *Context) interface{} {
+	return func(format string, zone ...string) (string, error) {
+		t, err := buildTimeIn(ctx, zone)
+		if err != nil {
+			return "", fmt.Errorf("format_time: %s", err)
+		}
+
+		result, err := strftime(t, format)
+		if err != nil {
+			return "", fmt.Errorf("format_time: %s", err)
+		}
+
+		return result, nil
+	}
+}
+
+// strftimeLayouts are the layouts of time.Format for the directives of
+// the format of format_time, the ones of strftime.
+var strftimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'F': "2006-01-02",
+	'H': "15",
+	'I': "03",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+// strftime formats the time with the directives of strftime, like
+// "%Y%m%d-%H%M", that are easier to write than the layouts of Go.
+func strftime(t time.Time, format string) (string, error) {
+	var result bytes.Buffer
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			result.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		if i == len(format) {
+			return "", errors.New("format ends with a lone %")
+		}
+
+		switch d := format[i]; d {
+		case '%':
+			result.WriteByte('%')
+		case 'j':
+			fmt.Fprintf(&result, "%03d", t.YearDay())
+		case 's':
+			result.WriteString(strconv.FormatInt(t.Unix(), 10))
+		default:
+			layout, ok := strftimeLayouts[d]
+			if !ok {
+				return "", fmt.Errorf("unknown directive %%%c", d)
+			}
+			result.WriteString(t.Format(layout))
+		}
+	}
+
+	return result.String(), nil
+}
+
 func funcGenIsotime(ctx *Context) interface{} {
 	return func(format ...string) (string, error) {
-		if len(format) == 0 {
-			return InitTime.Format(time.RFC3339), nil
+		if len(format) > 2 {
+			return "", fmt.Errorf("too many values, 2 needed: %v", format)
+		}
+
+		layout := time.RFC3339
+		if len(format) > 0 && format[0] != "" {
+			layout = format[0]
+		}
+
+		var zone []string
+		if len(format) == 2 {
+			zone = format[1:]
+		}
+
+		t, err := buildTimeIn(ctx, zone)
+		if err != nil {
+			return "", fmt.Errorf("isotime: %s", err)
+		}
+
+		return t.Format(layout), nil
+	}
+}
+
+func funcGenLatestArtifact(ctx *Context) interface{} {
+	return func(name string, builderId ...string) (string, error) {
+		if ctx == nil || ctx.LatestArtifacts == nil {
+			return "", errors.New("latest_artifact requires an artifact_registry")
+		}
+		if len(builderId) > 1 {
+			return "", errors.New("latest_artifact takes at most one builder ID")
+		}
+
+		var id string
+		if len(builderId) > 0 {
+			id = builderId[0]
+		}
+
+		return ctx.LatestArtifacts.LatestArtifact(name, id)
+	}
+}
+
+func funcGenBuildArtifact(ctx *Context) interface{} {
+	return func(name string) (string, error) {
+		if ctx == nil || ctx.BuildArtifacts == nil {
+			return "", errors.New("build_artifact is only available in the builds of a template")
 		}
 
-		if len(format) > 1 {
-			return "", fmt.Errorf("too many values, 1 needed: %v", format)
+		id, ok := ctx.BuildArtifacts[name]
+		if !ok {
+			return "", fmt.Errorf("build '%s' has no artifact", name)
 		}
 
-		return InitTime.Format(format[0]), nil
+		return id, nil
 	}
 }
 
@@ -131,9 +542,20 @@ func funcGenTemplateDir(ctx *Context) interface{} {
 	}
 }
 
+func funcGenTemplateName(ctx *Context) interface{} {
+	return func() (string, error) {
+		if ctx == nil || ctx.TemplatePath == "" {
+			return "", errors.New("template path not available")
+		}
+
+		name := filepath.Base(ctx.TemplatePath)
+		return strings.TrimSuffix(name, filepath.Ext(name)), nil
+	}
+}
+
 func funcGenTimestamp(ctx *Context) interface{} {
 	return func() string {
-		return strconv.FormatInt(InitTime.Unix(), 10)
+		return strconv.FormatInt(buildTime(ctx).Unix(), 10)
 	}
 }
 
@@ -152,3 +574,193 @@ func funcGenUuid(ctx *Context) interface{} {
 		return uuid.TimeOrderedUUID()
 	}
 }
+
+func funcBase64Decode(v string) (string, error) {
+	result, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return "", fmt.Errorf("base64decode: %s", err)
+	}
+
+	return string(result), nil
+}
+
+func funcBase64Encode(v string) string {
+	return base64.StdEncoding.EncodeToString([]byte(v))
+}
+
+func funcBcrypt(v string, cost ...int) (string, error) {
+	if len(cost) > 1 {
+		return "", fmt.Errorf("too many values, 1 needed: %v", cost)
+	}
+
+	c := bcrypt.DefaultCost
+	if len(cost) == 1 {
+		c = cost[0]
+	}
+
+	result, err := bcrypt.GenerateFromPassword([]byte(v), c)
+	if err != nil {
+		return "", fmt.Errorf("bcrypt: %s", err)
+	}
+
+	return string(result), nil
+}
+
+func funcJSONEncode(v interface{}) (string, error) {
+	result, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("jsonencode: %s", err)
+	}
+
+	return string(result), nil
+}
+
+func funcRandomPassword(length int, charset ...string) (string, error) {
+	if len(charset) > 1 {
+		return "", fmt.Errorf("too many values, 1 needed: %v", charset)
+	}
+
+	chars := DefaultPasswordCharset
+	if len(charset) == 1 {
+		chars = charset[0]
+	}
+
+	if length <= 0 {
+		return "", errors.New("random_password: length must be positive")
+	}
+	if chars == "" {
+		return "", errors.New("random_password: charset must not be empty")
+	}
+
+	runes := []rune(chars)
+	max := big.NewInt(int64(len(runes)))
+	result := make([]rune, length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("random_password: %s", err)
+		}
+		result[i] = runes[n.Int64()]
+	}
+
+	return string(result), nil
+}
+
+func funcRSAKeypair(bits ...int) (string, error) {
+	if len(bits) > 1 {
+		return "", fmt.Errorf("too many values, 1 needed: %v", bits)
+	}
+
+	size := DefaultRSAKeyBits
+	if len(bits) == 1 {
+		size = bits[0]
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, size)
+	if err != nil {
+		return "", fmt.Errorf("rsa_keypair: %s", err)
+	}
+
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+func funcRSAPublicKey(v string) (string, error) {
+	block, _ := pem.Decode([]byte(v))
+	if block == nil {
+		return "", errors.New("rsa_public_key: no PEM encoded key found")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("rsa_public_key: %s", err)
+	}
+
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("rsa_public_key: %s", err)
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))), nil
+}
+
+func funcSHA256(v string) string {
+	h := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(h[:])
+}
+
+// toInt converts the arguments of the arithmetic functions. Strings are
+// parsed so that user variables can be used as numbers.
+func toInt(v interface{}) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case string:
+		result, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil {
+			return 0, fmt.Errorf("not a number: %q", n)
+		}
+		return result, nil
+	default:
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+}
+
+func toInts(a, b interface{}) (int, int, error) {
+	x, err := toInt(a)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	y, err := toInt(b)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return x, y, nil
+}
+
+func funcAdd(a, b interface{}) (int, error) {
+	x, y, err := toInts(a, b)
+	return x + y, err
+}
+
+func funcSub(a, b interface{}) (int, error) {
+	x, y, err := toInts(a, b)
+	return x - y, err
+}
+
+func funcMul(a, b interface{}) (int, error) {
+	x, y, err := toInts(a, b)
+	return x * y, err
+}
+
+func funcDiv(a, b interface{}) (int, error) {
+	x, y, err := toInts(a, b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, errors.New("division by zero")
+	}
+
+	return x / y, nil
+}
+
+func funcMod(a, b interface{}) (int, error) {
+	x, y, err := toInts(a, b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, errors.New("division by zero")
+	}
+
+	return x % y, nil
+}
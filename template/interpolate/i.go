@@ -2,7 +2,9 @@ package interpolate
 
 import (
 	"bytes"
+	"fmt"
 	"text/template"
+	"time"
 )
 
 // Context is the context that an interpolation is done in. This defines
@@ -21,18 +23,71 @@ type Context struct {
 	// EnableEnv enables the env function
 	EnableEnv bool
 
+	// LatestArtifacts looks up the artifacts of previous builds for the
+	// "latest_artifact" function. The core looks them up in the artifact
+	// registry and gives the components of a build the artifacts their
+	// configuration uses, as ResolvedArtifacts.
+	LatestArtifacts ArtifactLookup
+
+	// Secrets caches the values read from secret stores. Without it,
+	// every value is read every time it is used.
+	Secrets *SecretCache
+
+	// BuildArtifacts are the ids of the artifacts of the builds of the
+	// same run that the build depends on, which "build_artifact" reads.
+	BuildArtifacts map[string]string
+
+	// BuildTime is the time the time functions, like "timestamp", render.
+	// The core sets it once per run and passes it to the components of
+	// the builds, which run in processes of their own, so that every
+	// configuration of a build renders the same time. Defaults to
+	// InitTime.
+	BuildTime time.Time
+
 	// All the fields below are used for built-in functions.
 	//
 	// BuildName and BuildType are the name and type, respectively,
 	// of the builder being used.
 	//
+	// ArtifactName is the name given to the artifacts of the build by
+	// the naming pattern of the template.
+	//
 	// TemplatePath is the path to the template that this is being
 	// rendered within.
 	BuildName    string
 	BuildType    string
+	ArtifactName string
 	TemplatePath string
 }
 
+// secrets returns the secret cache of the context, if any.
+func (ctx *Context) secrets() *SecretCache {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Secrets
+}
+
+// ArtifactLookup looks up the artifacts of previous builds.
+type ArtifactLookup interface {
+	// LatestArtifact returns the ID of the artifact created by the
+	// builder ID, or of the first artifact if it is empty, of the last
+	// recorded build with the name.
+	LatestArtifact(name, builderId string) (string, error)
+}
+
+// ResolvedArtifacts is an ArtifactLookup of artifacts looked up ahead of
+// time, with their IDs by build name and then by builder ID.
+type ResolvedArtifacts map[string]map[string]string
+
+func (r ResolvedArtifacts) LatestArtifact(name, builderId string) (string, error) {
+	if id, ok := r[name][builderId]; ok {
+		return id, nil
+	}
+
+	return "", fmt.Errorf("the artifact of build '%s' wasn't looked up by the core", name)
+}
+
 // Render is shorthand for constructing an I and calling Render.
 func Render(v string, ctx *Context) (string, error) {
 	return (&I{Value: v}).Render(ctx)
@@ -26,6 +26,14 @@ func functionsCalledWalk(raw parse.Node, r map[string]struct{}) {
 		for _, n := range node.Args[1:] {
 			functionsCalledWalk(n, r)
 		}
+	case *parse.IdentifierNode:
+		r[node.Ident] = struct{}{}
+	case *parse.IfNode:
+		functionsCalledWalk(node.Pipe, r)
+		functionsCalledWalk(node.List, r)
+		if node.ElseList != nil {
+			functionsCalledWalk(node.ElseList, r)
+		}
 	case *parse.ListNode:
 		for _, n := range node.Nodes {
 			functionsCalledWalk(n, r)
@@ -34,9 +42,54 @@ func functionsCalledWalk(raw parse.Node, r map[string]struct{}) {
 		for _, n := range node.Cmds {
 			functionsCalledWalk(n, r)
 		}
-	case *parse.StringNode, *parse.TextNode:
+	case *parse.BoolNode, *parse.DotNode, *parse.FieldNode, *parse.NumberNode,
+		*parse.StringNode, *parse.TextNode, *parse.VariableNode:
 		// Ignore
 	default:
 		panic(fmt.Sprintf("unknown type: %T", node))
 	}
 }
+
+// BuildArtifactsUsed returns the names of the builds whose artifacts the
+// given template reads with build_artifact, in the order they appear.
+func BuildArtifactsUsed(v string) ([]string, error) {
+	t, err := template.New("root").Funcs(Funcs(nil)).Parse(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	buildArtifactsUsedWalk(t.Tree.Root, &result)
+	return result, nil
+}
+
+func buildArtifactsUsedWalk(raw parse.Node, r *[]string) {
+	switch node := raw.(type) {
+	case *parse.ActionNode:
+		buildArtifactsUsedWalk(node.Pipe, r)
+	case *parse.CommandNode:
+		if in, ok := node.Args[0].(*parse.IdentifierNode); ok && in.Ident == "build_artifact" && len(node.Args) > 1 {
+			if s, ok := node.Args[1].(*parse.StringNode); ok {
+				*r = append(*r, s.Text)
+			}
+		}
+
+		for _, n := range node.Args[1:] {
+			buildArtifactsUsedWalk(n, r)
+		}
+	case *parse.IfNode:
+		buildArtifactsUsedWalk(node.Pipe, r)
+		buildArtifactsUsedWalk(node.List, r)
+		if node.ElseList != nil {
+			buildArtifactsUsedWalk(node.ElseList, r)
+		}
+	case *parse.ListNode:
+		for _, n := range node.Nodes {
+			buildArtifactsUsedWalk(n, r)
+		}
+	case *parse.PipeNode:
+		for _, n := range node.Cmds {
+			buildArtifactsUsedWalk(n, r)
+		}
+	}
+}
@@ -22,6 +22,16 @@ func TestFunctionsCalled(t *testing.T) {
 				"user": {},
 			},
 		},
+
+		{
+			"{{if eq build_type `qemu`}}{{add 1 .Foo}}{{else}}{{user `bar`}}{{end}}",
+			map[string]struct{}{
+				"add":        {},
+				"build_type": {},
+				"eq":         {},
+				"user":       {},
+			},
+		},
 	}
 
 	funcs := Funcs(&Context{})
@@ -37,3 +47,32 @@ func TestFunctionsCalled(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildArtifactsUsed(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Result []string
+	}{
+		{"foo", nil},
+		{"{{user `base`}}", nil},
+		{"{{build_artifact `base`}}", []string{"base"}},
+		{
+			"{{if eq build_type `qemu`}}{{build_artifact `base` | lower}}{{else}}{{build_artifact \"other\"}}{{end}}",
+			[]string{"base", "other"},
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := BuildArtifactsUsed(tc.Input)
+		if err != nil {
+			t.Fatalf("Input: %s\n\nerr: %s", tc.Input, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Result) {
+			t.Fatalf("Input: %s\n\nGot: %#v", tc.Input, actual)
+		}
+	}
+
+	if _, err := BuildArtifactsUsed("{{build_artifact"); err == nil {
+		t.Fatal("should error for a bad template")
+	}
+}
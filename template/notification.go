@@ -0,0 +1,61 @@
+package template
+
+const (
+	// NotificationTypeWebhook POSTs the rendered payload to the URL as-is.
+	NotificationTypeWebhook = "webhook"
+
+	// NotificationTypeSlack wraps the rendered payload in a Slack incoming
+	// webhook message.
+	NotificationTypeSlack = "slack"
+
+	// NotificationTypeSNS publishes the rendered payload to an AWS SNS
+	// topic.
+	NotificationTypeSNS = "sns"
+)
+
+const (
+	// EventBuildStart is fired when a build begins running.
+	EventBuildStart = "build-start"
+
+	// EventStepFailure is fired when a step of the builder, a provisioner
+	// or a post-processor returns an error, before the build fails.
+	EventStepFailure = "step-failure"
+
+	// EventBuildFailure is fired when a build stops because a step
+	// (or a post-processor) returned an error.
+	EventBuildFailure = "build-failure"
+
+	// EventArtifact is fired for every artifact a build produces.
+	EventArtifact = "artifact"
+)
+
+// NotificationEvents are all of the events that a notification can
+// subscribe to.
+var NotificationEvents = []string{
+	EventBuildStart,
+	EventStepFailure,
+	EventBuildFailure,
+	EventArtifact,
+}
+
+func validNotificationEvent(e string) bool {
+	for _, v := range NotificationEvents {
+		if v == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Notifies says whether or not the notification subscribes to the
+// given event.
+func (n *Notification) Notifies(event string) bool {
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
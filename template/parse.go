@@ -22,12 +22,24 @@ import (
 type rawTemplate struct {
 	MinVersion  string `mapstructure:"min_packer_version"`
 	Description string
+	Strict      bool
 
-	Builders       []map[string]interface{}
-	Push           map[string]interface{}
-	PostProcessors []interface{} `mapstructure:"post-processors"`
-	Provisioners   []map[string]interface{}
-	Variables      map[string]interface{}
+	ArtifactRegistry map[string]interface{} `mapstructure:"artifact_registry"`
+	BuildLock        map[string]interface{} `mapstructure:"build_lock"`
+	Builders         []map[string]interface{}
+	Notifications    []map[string]interface{}
+	Push             map[string]interface{}
+	PostProcessors   []interface{} `mapstructure:"post-processors"`
+	Provisioners     []map[string]interface{}
+	Variables        map[string]interface{}
+
+	RequiredPlugins map[string]map[string]interface{} `mapstructure:"required_plugins"`
+
+	Naming map[string]interface{}
+
+	Includes            []string
+	ProvisionerBlocks   map[string][]map[string]interface{} `mapstructure:"provisioner_blocks"`
+	PostProcessorBlocks map[string][]interface{}            `mapstructure:"post_processor_blocks"`
 
 	RawContents []byte
 }
@@ -41,6 +53,7 @@ func (r *rawTemplate) Template() (*Template, error) {
 	// Copy some literals
 	result.Description = r.Description
 	result.MinVersion = r.MinVersion
+	result.Strict = r.Strict
 	result.RawContents = r.RawContents
 
 	// Gather the variables
@@ -48,19 +61,14 @@ func (r *rawTemplate) Template() (*Template, error) {
 		result.Variables = make(map[string]*Variable, len(r.Variables))
 	}
 	for k, rawV := range r.Variables {
-		var v Variable
-
-		// Variable is required if the value is exactly nil
-		v.Required = rawV == nil
-
-		// Weak decode the default if we have one
-		if err := r.decoder(&v.Default, nil).Decode(rawV); err != nil {
+		v, err := r.parseVariable(rawV)
+		if err != nil {
 			errs = multierror.Append(errs, fmt.Errorf(
 				"variable %s: %s", k, err))
 			continue
 		}
 
-		result.Variables[k] = &v
+		result.Variables[k] = v
 	}
 
 	// Let's start by gathering all the builders
@@ -77,6 +85,8 @@ func (r *rawTemplate) Template() (*Template, error) {
 
 		// Set the raw configuration and delete any special keys
 		b.Config = rawB
+		delete(b.Config, "lock")
+		delete(b.Config, "matrix")
 		delete(b.Config, "name")
 		delete(b.Config, "type")
 		if len(b.Config) == 0 {
@@ -172,10 +182,15 @@ func (r *rawTemplate) Template() (*Template, error) {
 		}
 
 		// Copy the configuration
+		delete(v, "checkpoint")
 		delete(v, "except")
+		delete(v, "max_retries")
 		delete(v, "only")
+		delete(v, "output_file")
 		delete(v, "override")
+		delete(v, "pause_after")
 		delete(v, "pause_before")
+		delete(v, "timeout")
 		delete(v, "type")
 		if len(v) > 0 {
 			p.Config = v
@@ -185,6 +200,98 @@ func (r *rawTemplate) Template() (*Template, error) {
 		result.Provisioners = append(result.Provisioners, &p)
 	}
 
+	// Gather all the notifications
+	for i, v := range r.Notifications {
+		var n Notification
+		if err := r.decoder(&n, nil).Decode(v); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"notification %d: %s", i+1, err))
+			continue
+		}
+
+		if n.Type == "" {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"notification %d: missing 'type'", i+1))
+			continue
+		}
+
+		// With no events listed we notify on all of them
+		if len(n.Events) == 0 {
+			n.Events = append([]string(nil), NotificationEvents...)
+		}
+
+		result.Notifications = append(result.Notifications, &n)
+	}
+
+	// The artifact registry keeps "type" and "metadata" for itself, the
+	// rest of the configuration belongs to the backend.
+	if len(r.ArtifactRegistry) > 0 {
+		var a ArtifactRegistry
+		if err := r.decoder(&a, nil).Decode(r.ArtifactRegistry); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"artifact_registry: %s", err))
+		} else {
+			a.Config = make(map[string]interface{})
+			for k, v := range r.ArtifactRegistry {
+				if k != "type" && k != "metadata" {
+					a.Config[k] = v
+				}
+			}
+
+			result.ArtifactRegistry = &a
+		}
+	}
+
+	// The build lock keeps "type" and "timeout" for itself, the rest of
+	// the configuration belongs to the backend.
+	if len(r.BuildLock) > 0 {
+		var l BuildLock
+		if err := r.decoder(&l, nil).Decode(r.BuildLock); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"build_lock: %s", err))
+		} else {
+			l.Config = make(map[string]interface{})
+			for k, v := range r.BuildLock {
+				if k != "type" && k != "timeout" {
+					l.Config[k] = v
+				}
+			}
+
+			result.BuildLock = &l
+		}
+	}
+
+	// Naming
+	if len(r.Naming) > 0 {
+		var n Naming
+		if err := r.decoder(&n, nil).Decode(r.Naming); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"naming: %s", err))
+		} else {
+			result.Naming = &n
+		}
+	}
+
+	// Gather the required plugins
+	if len(r.RequiredPlugins) > 0 {
+		result.RequiredPlugins = make(map[string]*RequiredPlugin, len(r.RequiredPlugins))
+	}
+	for k, v := range r.RequiredPlugins {
+		var p RequiredPlugin
+		if err := r.decoder(&p, nil).Decode(v); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"required plugin %s: %s", k, err))
+			continue
+		}
+
+		p.Name = k
+		if p.ChecksumType == "" {
+			p.ChecksumType = "sha256"
+		}
+
+		result.RequiredPlugins[k] = &p
+	}
+
 	// Push
 	if len(r.Push) > 0 {
 		var p Push
@@ -204,6 +311,56 @@ func (r *rawTemplate) Template() (*Template, error) {
 	return &result, nil
 }
 
+// parseVariable parses a variable, given either as its default or as a
+// declaration object.
+func (r *rawTemplate) parseVariable(raw interface{}) (*Variable, error) {
+	var v Variable
+	decl, ok := raw.(map[string]interface{})
+	if !ok {
+		// Variable is required if the value is exactly nil
+		v.Required = raw == nil
+
+		// Weak decode the default if we have one
+		if err := r.decoder(&v.Default, nil).Decode(raw); err != nil {
+			return nil, err
+		}
+
+		return &v, nil
+	}
+
+	var d struct {
+		Type        string
+		Default     interface{}
+		Description string
+		Sensitive   bool
+		Validation  []*VariableValidation
+	}
+	var md mapstructure.Metadata
+	if err := r.decoder(&d, &md).Decode(decl); err != nil {
+		return nil, err
+	}
+	if len(md.Unused) > 0 {
+		sort.Strings(md.Unused)
+		return nil, fmt.Errorf("unknown key '%s'", md.Unused[0])
+	}
+
+	v.Type = d.Type
+	v.Description = d.Description
+	v.Sensitive = d.Sensitive
+	v.Validation = d.Validation
+
+	// Declared variables are required if they have no default
+	v.Required = d.Default == nil
+	if !v.Required {
+		var err error
+		if v.Default, err = variableDefault(d.Default); err != nil {
+			return nil, err
+		}
+	}
+
+	return &v, nil
+}
+
 func (r *rawTemplate) decoder(
 	result interface{},
 	md *mapstructure.Metadata) *mapstructure.Decoder {
@@ -261,7 +418,12 @@ func (r *rawTemplate) parsePostProcessor(
 }
 
 // Parse takes the given io.Reader and parses a Template object out of it.
+// Includes are relative to the working directory.
 func Parse(r io.Reader) (*Template, error) {
+	return parse(r, "")
+}
+
+func parse(r io.Reader, dir string) (*Template, error) {
 	// Create a buffer to copy what we read
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(r); err != nil {
@@ -310,6 +472,14 @@ func Parse(r io.Reader) (*Template, error) {
 		return nil, err
 	}
 
+	// Merge the includes and expand the blocks before anything else
+	if err := rawTpl.resolveIncludes(dir); err != nil {
+		return nil, err
+	}
+	if err := rawTpl.expandBlocks(); err != nil {
+		return nil, err
+	}
+
 	// Return the template parsed from the raw structure
 	return rawTpl.Template()
 }
@@ -319,6 +489,7 @@ func Parse(r io.Reader) (*Template, error) {
 func ParseFile(path string) (*Template, error) {
 	var f *os.File
 	var err error
+	var dir string
 	if path == "-" {
 		// Create a temp file for stdin in case of errors
 		f, err = ioutil.TempFile(os.TempDir(), "packer")
@@ -335,8 +506,9 @@ func ParseFile(path string) (*Template, error) {
 			return nil, err
 		}
 		defer f.Close()
+		dir = filepath.Dir(path)
 	}
-	tpl, err := Parse(f)
+	tpl, err := parse(f, dir)
 	if err != nil {
 		syntaxErr, ok := err.(*json.SyntaxError)
 		if !ok {
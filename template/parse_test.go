@@ -39,6 +39,25 @@ func TestParse(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"parse-builder-matrix.json",
+			&Template{
+				Builders: map[string]*Builder{
+					"windows-{{user `os_version`}}-{{user `region`}}": {
+						Name: "windows-{{user `os_version`}}-{{user `region`}}",
+						Type: "something",
+						Matrix: map[string][]string{
+							"os_version": {"2012", "2016"},
+							"region":     {"us-east-1", "eu-west-1"},
+						},
+						Config: map[string]interface{}{
+							"foo": "bar",
+						},
+					},
+				},
+			},
+			false,
+		},
 
 		/*
 		 * Provisioners
@@ -68,6 +87,23 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-provisioner-decorators.json",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type:       "something",
+						PauseAfter: 1 * time.Second,
+						Timeout:    5 * time.Minute,
+						MaxRetries: 3,
+						OutputFile: "out.log",
+						Checkpoint: "deps",
+					},
+				},
+			},
+			false,
+		},
+
 		{
 			"parse-provisioner-only.json",
 			&Template{
@@ -143,6 +179,33 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-variable-declaration.json",
+			&Template{
+				Variables: map[string]*Variable{
+					"zones": {
+						Default:     `["a","b"]`,
+						Type:        "list",
+						Description: "The availability zones",
+						Validation: []*VariableValidation{
+							{ErrorMessage: "at least one zone is needed", Min: floatPtr(1)},
+						},
+					},
+					"token": {
+						Required:  true,
+						Sensitive: true,
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-variable-declaration-unknown-key.json",
+			nil,
+			true,
+		},
+
 		{
 			"parse-pp-basic.json",
 			&Template{
@@ -295,6 +358,14 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-strict.json",
+			&Template{
+				Strict: true,
+			},
+			false,
+		},
+
 		{
 			"parse-push.json",
 			&Template{
@@ -305,6 +376,227 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-notification.json",
+			&Template{
+				Notifications: []*Notification{
+					{
+						Type:   "webhook",
+						URL:    "http://example.com/hook",
+						Events: []string{"build-start"},
+						Headers: map[string]string{
+							"X-Token": "foo",
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-notification-no-events.json",
+			&Template{
+				Notifications: []*Notification{
+					{
+						Type:   "slack",
+						URL:    "http://example.com/hook",
+						Events: []string{"build-start", "step-failure", "build-failure", "artifact"},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-artifact-registry.json",
+			&Template{
+				ArtifactRegistry: &ArtifactRegistry{
+					Type: "file",
+					Metadata: map[string]string{
+						"team": "images",
+					},
+					Config: map[string]interface{}{
+						"directory": "registry",
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-build-lock.json",
+			&Template{
+				Builders: map[string]*Builder{
+					"foo": {
+						Name: "foo",
+						Type: "foo",
+						Lock: "vsphere-template",
+						Config: map[string]interface{}{
+							"bar": "baz",
+						},
+					},
+				},
+				BuildLock: &BuildLock{
+					Type:    "http",
+					Timeout: time.Hour,
+					Config: map[string]interface{}{
+						"url": "http://locks.example.com",
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-required-plugins.json",
+			&Template{
+				RequiredPlugins: map[string]*RequiredPlugin{
+					"packer-provisioner-foo": {
+						Name:         "packer-provisioner-foo",
+						Source:       "https://example.com/packer-provisioner-foo",
+						Checksum:     "abcd",
+						ChecksumType: "sha256",
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-include.json",
+			&Template{
+				Variables: map[string]*Variable{
+					"region": {Default: "eu-west-1"},
+					"user":   {Default: "shared"},
+				},
+				Provisioners: []*Provisioner{
+					{
+						Type: "shell",
+						Config: map[string]interface{}{
+							"inline": []interface{}{"yum update"},
+						},
+					},
+					{
+						Type: "file",
+						Only: []string{"foo"},
+						Config: map[string]interface{}{
+							"source":      "a",
+							"destination": "b",
+						},
+					},
+					{
+						Type: "shell",
+						Only: []string{"bar"},
+						Config: map[string]interface{}{
+							"inline": []interface{}{"echo hi"},
+						},
+					},
+					{
+						Type: "shell",
+						Config: map[string]interface{}{
+							"inline": []interface{}{"echo done"},
+						},
+					},
+				},
+				PostProcessors: [][]*PostProcessor{
+					{
+						{Type: "compress"},
+					},
+					{
+						{Type: "upload"},
+						{Type: "notify"},
+					},
+				},
+				Naming: &Naming{
+					Pattern: "{{template_name}}-{{timestamp}}",
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-include-nested-blocks.json",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type: "shell",
+						Only: []string{"baz"},
+						Config: map[string]interface{}{
+							"inline": []interface{}{"yum update"},
+						},
+					},
+					{
+						Type: "shell",
+						Only: []string{"foo"},
+						Config: map[string]interface{}{
+							"inline": []interface{}{"echo base"},
+						},
+					},
+				},
+				PostProcessors: [][]*PostProcessor{
+					{
+						{Type: "compress"},
+						{Type: "upload"},
+						{Type: "notify"},
+					},
+					{
+						{Type: "notify"},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-include-provisioner-block-cycle.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-include-post-processor-block-cycle.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-include-block-sequence.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-naming.json",
+			&Template{
+				Naming: &Naming{
+					Pattern: "{{template_name}}-{{os}}-{{timestamp}}-{{git_sha 8}}",
+					Fields: map[string]string{
+						"os": "ubuntu-18.04",
+					},
+					Enforce: true,
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-include-unknown-block.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-include-cycle.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-include-missing.json",
+			nil,
+			true,
+		},
+
 		{
 			"parse-comment.json",
 			&Template{
@@ -371,3 +663,7 @@ func TestParse_bad(t *testing.T) {
 		}
 	}
 }
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,51 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// PluginPrefixes are the binary name prefixes Packer uses to discover
+// plugins. A required plugin must be named with one of these.
+var PluginPrefixes = []string{
+	"packer-builder-",
+	"packer-provisioner-",
+	"packer-post-processor-",
+}
+
+// Validate checks that the required plugin can be installed.
+func (p *RequiredPlugin) Validate() error {
+	var err error
+
+	validName := false
+	for _, prefix := range PluginPrefixes {
+		if strings.HasPrefix(p.Name, prefix) && len(p.Name) > len(prefix) {
+			validName = true
+			break
+		}
+	}
+	if !validName {
+		err = multierror.Append(err, fmt.Errorf(
+			"name must start with one of: %s", strings.Join(PluginPrefixes, ", ")))
+	}
+
+	if p.Source == "" {
+		err = multierror.Append(err, errors.New("'source' must be specified"))
+	}
+
+	if p.Checksum == "" {
+		err = multierror.Append(err, errors.New("'checksum' must be specified"))
+	}
+
+	switch p.ChecksumType {
+	case "md5", "sha1", "sha256", "sha512":
+	default:
+		err = multierror.Append(err, fmt.Errorf(
+			"unsupported checksum_type '%s'", p.ChecksumType))
+	}
+
+	return err
+}
@@ -3,11 +3,29 @@ package template
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
+// namingFieldRe matches the names that naming fields can have, since they
+// are called as functions in the pattern.
+var namingFieldRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// checkpointRe matches the names of provisioner checkpoints, which are
+// used in the tags of images.
+var checkpointRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// namingReservedFields are the keywords of the template language, which
+// can't be used as function names.
+var namingReservedFields = []string{
+	"block", "break", "continue", "define", "else", "end", "false", "if",
+	"nil", "range", "template", "true", "with",
+}
+
 // Template represents the parsed template that is used to configure
 // Packer builds.
 type Template struct {
@@ -18,11 +36,30 @@ type Template struct {
 	Description string
 	MinVersion  string
 
+	// Strict rejects what is otherwise accepted with a warning or
+	// silently, such as variables the template doesn't declare.
+	Strict bool
+
 	Variables      map[string]*Variable
 	Builders       map[string]*Builder
 	Provisioners   []*Provisioner
 	PostProcessors [][]*PostProcessor
 	Push           Push
+	Notifications  []*Notification
+
+	// ArtifactRegistry is where successful builds are recorded, if set.
+	ArtifactRegistry *ArtifactRegistry
+
+	// BuildLock is the backend of the locks builds take, if set.
+	BuildLock *BuildLock
+
+	// Naming is the pattern the artifacts of the builds are named with,
+	// if set.
+	Naming *Naming
+
+	// RequiredPlugins are the external plugin binaries this template
+	// needs, keyed by binary name (e.g. "packer-provisioner-foo").
+	RequiredPlugins map[string]*RequiredPlugin
 
 	// RawContents is just the raw data for this template
 	RawContents []byte
@@ -30,8 +67,19 @@ type Template struct {
 
 // Builder represents a builder configured in the template
 type Builder struct {
-	Name   string
-	Type   string
+	Name string
+	Type string
+
+	// Lock is the name of the build lock the build holds while it runs,
+	// if set. Builds with the same lock name run one at a time, across
+	// processes and machines sharing the BuildLock backend.
+	Lock string
+
+	// Matrix expands the builder into one build for every combination of
+	// the values of its variables. The variables are set as user variables
+	// of the build.
+	Matrix map[string][]string
+
 	Config map[string]interface{}
 }
 
@@ -52,6 +100,11 @@ type Provisioner struct {
 	Config      map[string]interface{}
 	Override    map[string]interface{}
 	PauseBefore time.Duration `mapstructure:"pause_before"`
+	PauseAfter  time.Duration `mapstructure:"pause_after"`
+	Timeout     time.Duration
+	MaxRetries  int    `mapstructure:"max_retries"`
+	OutputFile  string `mapstructure:"output_file"`
+	Checkpoint  string
 }
 
 // Push represents the configuration for pushing the template to Atlas.
@@ -65,10 +118,68 @@ type Push struct {
 	VCS     bool
 }
 
+// Notification represents a webhook or an SNS topic that is notified of
+// build events.
+type Notification struct {
+	Type     string
+	URL      string `mapstructure:"url"`
+	TopicARN string `mapstructure:"topic_arn"`
+	Events   []string
+	Payload  string
+	Headers  map[string]string
+}
+
+// ArtifactRegistry represents the backend that successful builds are
+// recorded to. Config is everything but the type and metadata and is
+// specific to the backend.
+type ArtifactRegistry struct {
+	Type     string
+	Metadata map[string]string
+	Config   map[string]interface{} `mapstructure:"-"`
+}
+
+// BuildLock represents the backend of the locks builds take. Config is
+// everything but the type and timeout and is specific to the backend.
+type BuildLock struct {
+	Type string
+
+	// Timeout is how long a build waits for its lock, forever if zero.
+	Timeout time.Duration
+
+	Config map[string]interface{} `mapstructure:"-"`
+}
+
+// Naming represents the convention the artifacts of the builds are named
+// with. The pattern is interpolated for every build, with the fields
+// available as functions, and the result is available as artifact_name.
+type Naming struct {
+	Pattern string
+	Fields  map[string]string
+
+	// Enforce sets the name setting of the builders and post-processors
+	// that have one to the artifact name.
+	Enforce bool
+}
+
+// RequiredPlugin represents an external plugin binary that must be
+// installed for the template to build. It is installed by `packer init`.
+type RequiredPlugin struct {
+	Name         string `mapstructure:"-"`
+	Source       string
+	Checksum     string
+	ChecksumType string `mapstructure:"checksum_type"`
+}
+
 // Variable represents a variable within the template
 type Variable struct {
 	Default  string
 	Required bool
+
+	// These are set by declaring the variable with an object.
+	Type        string
+	Description string
+	Sensitive   bool
+	Validation  []*VariableValidation
 }
 
 // OnlyExcept is a struct that is meant to be embedded that contains the
@@ -96,7 +207,43 @@ func (t *Template) Validate() error {
 			"at least one builder must be defined"))
 	}
 
+	// Verify the variable declarations
+	for name, v := range t.Variables {
+		if verr := v.Validate(); verr != nil {
+			for _, e := range multierror.Append(verr).Errors {
+				err = multierror.Append(err, fmt.Errorf(
+					"variable '%s': %s", name, e))
+			}
+		}
+	}
+
+	// Verify the naming convention
+	if t.Naming != nil {
+		if verr := t.Naming.Validate(); verr != nil {
+			for _, e := range multierror.Append(verr).Errors {
+				err = multierror.Append(err, fmt.Errorf("naming: %s", e))
+			}
+		}
+	}
+
+	// Verify the builder matrices and locks
+	for name, b := range t.Builders {
+		for k, values := range b.Matrix {
+			if len(values) == 0 {
+				err = multierror.Append(err, fmt.Errorf(
+					"builder '%s': matrix variable '%s' must have at least one value",
+					name, k))
+			}
+		}
+
+		if b.Lock != "" && t.BuildLock == nil {
+			err = multierror.Append(err, fmt.Errorf(
+				"builder '%s': 'lock' requires a 'build_lock' backend", name))
+		}
+	}
+
 	// Verify that the provisioner overrides target builders that exist
+	checkpoints := make(map[string]struct{})
 	for i, p := range t.Provisioners {
 		// Validate only/except
 		if verr := p.OnlyExcept.Validate(t); verr != nil {
@@ -106,6 +253,25 @@ func (t *Template) Validate() error {
 			}
 		}
 
+		if p.MaxRetries < 0 {
+			err = multierror.Append(err, fmt.Errorf(
+				"provisioner %d: max_retries can't be negative", i+1))
+		}
+
+		// Validate checkpoint names, which builders use in image tags
+		if p.Checkpoint != "" {
+			if !checkpointRe.MatchString(p.Checkpoint) {
+				err = multierror.Append(err, fmt.Errorf(
+					"provisioner %d: checkpoint '%s' can only contain letters, digits, '_', '.' and '-'",
+					i+1, p.Checkpoint))
+			}
+			if _, ok := checkpoints[p.Checkpoint]; ok {
+				err = multierror.Append(err, fmt.Errorf(
+					"provisioner %d: checkpoint '%s' is already used", i+1, p.Checkpoint))
+			}
+			checkpoints[p.Checkpoint] = struct{}{}
+		}
+
 		// Validate overrides
 		for name := range p.Override {
 			if _, ok := t.Builders[name]; !ok {
@@ -129,9 +295,91 @@ func (t *Template) Validate() error {
 		}
 	}
 
+	// Verify notifications
+	for i, n := range t.Notifications {
+		switch n.Type {
+		case NotificationTypeWebhook, NotificationTypeSlack:
+			if n.URL == "" {
+				err = multierror.Append(err, fmt.Errorf(
+					"notification %d: 'url' must be specified", i+1))
+			}
+		case NotificationTypeSNS:
+			if n.TopicARN == "" {
+				err = multierror.Append(err, fmt.Errorf(
+					"notification %d: 'topic_arn' must be specified", i+1))
+			}
+		default:
+			err = multierror.Append(err, fmt.Errorf(
+				"notification %d: unknown type '%s'", i+1, n.Type))
+		}
+
+		for _, e := range n.Events {
+			if !validNotificationEvent(e) {
+				err = multierror.Append(err, fmt.Errorf(
+					"notification %d: unknown event '%s'", i+1, e))
+			}
+		}
+	}
+
+	// Verify the artifact registry
+	if t.ArtifactRegistry != nil && t.ArtifactRegistry.Type == "" {
+		err = multierror.Append(err, errors.New(
+			"artifact_registry: 'type' must be specified"))
+	}
+
+	// Verify the build lock
+	if t.BuildLock != nil && t.BuildLock.Type == "" {
+		err = multierror.Append(err, errors.New(
+			"build_lock: 'type' must be specified"))
+	}
+
+	// Verify required plugins
+	for _, p := range t.RequiredPlugins {
+		if verr := p.Validate(); verr != nil {
+			for _, e := range multierror.Append(verr).Errors {
+				err = multierror.Append(err, fmt.Errorf(
+					"required plugin '%s': %s", p.Name, e))
+			}
+		}
+	}
+
 	return err
 }
 
+// MatrixVariables returns the variables of every combination in the
+// builder's matrix, or nil if it has no matrix. Combinations are ordered
+// by the variable names, with the values of the last variable changing
+// first, in the order they are listed.
+func (b *Builder) MatrixVariables() []map[string]string {
+	if len(b.Matrix) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(b.Matrix))
+	for k := range b.Matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := []map[string]string{{}}
+	for _, k := range keys {
+		expanded := make([]map[string]string, 0, len(result)*len(b.Matrix[k]))
+		for _, vars := range result {
+			for _, v := range b.Matrix[k] {
+				combination := make(map[string]string, len(vars)+1)
+				for vk, vv := range vars {
+					combination[vk] = vv
+				}
+				combination[k] = v
+				expanded = append(expanded, combination)
+			}
+		}
+		result = expanded
+	}
+
+	return result
+}
+
 // Skip says whether or not to skip the build with the given name.
 func (o *OnlyExcept) Skip(n string) bool {
 	if len(o.Only) > 0 {
@@ -196,6 +444,50 @@ func (p *PostProcessor) GoString() string {
 	return fmt.Sprintf("*%#v", *p)
 }
 
+func (n *Notification) GoString() string {
+	return fmt.Sprintf("*%#v", *n)
+}
+
+func (a *ArtifactRegistry) GoString() string {
+	return fmt.Sprintf("*%#v", *a)
+}
+
+func (l *BuildLock) GoString() string {
+	return fmt.Sprintf("*%#v", *l)
+}
+
+func (p *RequiredPlugin) GoString() string {
+	return fmt.Sprintf("*%#v", *p)
+}
+
 func (v *Variable) GoString() string {
 	return fmt.Sprintf("*%#v", *v)
 }
+
+// Validate checks that the naming convention has a pattern, and that its
+// fields can be called as functions.
+func (n *Naming) Validate() error {
+	var err error
+	if n.Pattern == "" {
+		err = multierror.Append(err, errors.New("pattern must be specified"))
+	}
+
+	names := make([]string, 0, len(n.Fields))
+	for name := range n.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		reserved := !namingFieldRe.MatchString(name)
+		for _, r := range namingReservedFields {
+			reserved = reserved || name == r
+		}
+		if _, ok := interpolate.FuncGens[name]; ok || reserved {
+			err = multierror.Append(err, fmt.Errorf(
+				"field '%s' can't be used as a function name", name))
+		}
+	}
+
+	return err
+}
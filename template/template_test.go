@@ -3,6 +3,7 @@ package template
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -72,6 +73,91 @@ func TestTemplateValidate(t *testing.T) {
 			"validate-good-pp-except.json",
 			false,
 		},
+
+		{
+			"validate-bad-notification-type.json",
+			true,
+		},
+
+		{
+			"validate-bad-notification-event.json",
+			true,
+		},
+
+		{
+			"validate-bad-notification-sns.json",
+			true,
+		},
+
+		{
+			"validate-good-notification.json",
+			false,
+		},
+
+		{
+			"validate-bad-required-plugin.json",
+			true,
+		},
+
+		{
+			"validate-bad-artifact-registry.json",
+			true,
+		},
+
+		{
+			"validate-bad-build-lock.json",
+			true,
+		},
+
+		{
+			"validate-bad-builder-lock.json",
+			true,
+		},
+
+		{
+			"validate-bad-matrix.json",
+			true,
+		},
+
+		{
+			"validate-bad-naming.json",
+			true,
+		},
+
+		{
+			"validate-good-naming.json",
+			false,
+		},
+
+		{
+			"validate-bad-variable-type.json",
+			true,
+		},
+
+		{
+			"validate-bad-variable-default.json",
+			true,
+		},
+
+		{
+			"validate-bad-variable-validation.json",
+			true,
+		},
+
+		{
+			"validate-good-variables.json",
+			false,
+		},
+
+		{
+			"validate-bad-checkpoint.json",
+			true,
+		},
+
+		{
+			"validate-good-checkpoint.json",
+			false,
+		},
 	}
 
 	for _, tc := range cases {
@@ -93,6 +179,29 @@ func TestTemplateValidate(t *testing.T) {
 	}
 }
 
+func TestBuilderMatrixVariables(t *testing.T) {
+	b := &Builder{
+		Matrix: map[string][]string{
+			"region":     {"us-east-1", "eu-west-1"},
+			"os_version": {"2012", "2016"},
+		},
+	}
+
+	expected := []map[string]string{
+		{"os_version": "2012", "region": "us-east-1"},
+		{"os_version": "2012", "region": "eu-west-1"},
+		{"os_version": "2016", "region": "us-east-1"},
+		{"os_version": "2016", "region": "eu-west-1"},
+	}
+	if actual := b.MatrixVariables(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if actual := (&Builder{}).MatrixVariables(); actual != nil {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestOnlyExceptSkip(t *testing.T) {
 	cases := []struct {
 		Only, Except []string
@@ -135,3 +244,67 @@ func TestOnlyExceptSkip(t *testing.T) {
 		}
 	}
 }
+
+func TestVariableCheck(t *testing.T) {
+	one, three := 1.0, 3.0
+	cases := []struct {
+		Variable *Variable
+		Value    string
+		Expected string
+		Err      string
+	}{
+		{&Variable{}, "anything", "anything", ""},
+		{&Variable{Type: VariableTypeNumber}, "1.5", "1.5", ""},
+		{&Variable{Type: VariableTypeNumber}, "one", "", "'one' isn't a number"},
+		{&Variable{Type: VariableTypeBool}, "1", "true", ""},
+		{&Variable{Type: VariableTypeBool}, "yes", "", "'yes' isn't a boolean, must be true or false"},
+		{&Variable{Type: VariableTypeList}, `[ "a", "b" ]`, `["a","b"]`, ""},
+		{&Variable{Type: VariableTypeList}, "a,b", "", "'a,b' isn't a list, must be a JSON array"},
+		{&Variable{Type: VariableTypeMap}, `{"a": 1}`, `{"a":1}`, ""},
+		{&Variable{Type: VariableTypeMap}, `["a"]`, "", `'["a"]' isn't a map, must be a JSON object`},
+		{
+			&Variable{Validation: []*VariableValidation{{Regex: "^ami-"}}},
+			"i-1234", "", "'i-1234' doesn't match '^ami-'",
+		},
+		{
+			&Variable{Validation: []*VariableValidation{{AllowedValues: []string{"web", "db"}}}},
+			"db", "db", "",
+		},
+		{
+			&Variable{Validation: []*VariableValidation{{AllowedValues: []string{"web", "db"}}}},
+			"cache", "", "'cache' must be one of: web, db",
+		},
+		{
+			&Variable{Type: VariableTypeNumber, Validation: []*VariableValidation{{Min: &one, Max: &three}}},
+			"4", "", "value must be at most 3",
+		},
+		{
+			&Variable{Type: VariableTypeList, Validation: []*VariableValidation{{Min: &one}}},
+			"[]", "", "length must be at least 1",
+		},
+		{
+			&Variable{Validation: []*VariableValidation{{Max: &three, ErrorMessage: "too long"}}},
+			"abcd", "", "too long",
+		},
+		{
+			&Variable{Type: VariableTypeNumber, Sensitive: true},
+			"s3cr3t", "", "the sensitive value isn't a number",
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := tc.Variable.Check(tc.Value)
+		if tc.Err != "" {
+			if err == nil || err.Error() != tc.Err {
+				t.Fatalf("%s: bad error: %v", tc.Value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Value, err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("%s: got %s, expected %s", tc.Value, actual, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	VariableTypeString = "string"
+	VariableTypeNumber = "number"
+	VariableTypeBool   = "bool"
+	VariableTypeList   = "list"
+	VariableTypeMap    = "map"
+)
+
+// VariableTypes are the types a variable can be declared with. Values
+// are still strings in the template: lists and maps are JSON.
+var VariableTypes = []string{
+	VariableTypeString,
+	VariableTypeNumber,
+	VariableTypeBool,
+	VariableTypeList,
+	VariableTypeMap,
+}
+
+// VariableValidation is a rule the value of a variable must follow. Min
+// and Max bound numbers, and the length of other values.
+type VariableValidation struct {
+	Regex         string
+	AllowedValues []string `mapstructure:"allowed_values"`
+	Min           *float64
+	Max           *float64
+
+	// ErrorMessage replaces the default error when the rule is broken.
+	ErrorMessage string `mapstructure:"error_message"`
+}
+
+// variableDefault returns the default of a variable declaration as a
+// string, with lists and maps encoded as JSON.
+func variableDefault(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err
+	default:
+		return "", fmt.Errorf("unsupported default %#v", raw)
+	}
+}
+
+// Validate checks the declaration of the variable, and that its default
+// is a valid value.
+func (v *Variable) Validate() error {
+	var err error
+
+	valid := v.Type == ""
+	for _, t := range VariableTypes {
+		valid = valid || v.Type == t
+	}
+	if !valid {
+		return fmt.Errorf("unknown type '%s', must be one of: %s",
+			v.Type, strings.Join(VariableTypes, ", "))
+	}
+
+	for i, rule := range v.Validation {
+		if rule.Regex == "" && len(rule.AllowedValues) == 0 && rule.Min == nil && rule.Max == nil {
+			err = multierror.Append(err, fmt.Errorf(
+				"validation %d: one of 'regex', 'allowed_values', 'min' or 'max' must be specified", i+1))
+		}
+		if rule.Regex != "" {
+			if _, rerr := regexp.Compile(rule.Regex); rerr != nil {
+				err = multierror.Append(err, fmt.Errorf(
+					"validation %d: invalid regex: %s", i+1, rerr))
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Defaults using template functions are checked once interpolated
+	if !v.Required && !strings.Contains(v.Default, "{{") {
+		if _, verr := v.Check(v.Default); verr != nil {
+			err = multierror.Append(err, fmt.Errorf("invalid default: %s", verr))
+		}
+	}
+
+	return err
+}
+
+// Check checks that the value has the type of the variable and follows
+// its validation rules. It returns the value normalized for its type:
+// booleans are true or false, lists and maps are compact JSON.
+func (v *Variable) Check(value string) (string, error) {
+	var length int
+	var number float64
+	switch v.Type {
+	case VariableTypeNumber:
+		var err error
+		if number, err = strconv.ParseFloat(value, 64); err != nil {
+			return "", fmt.Errorf("%s isn't a number", v.quote(value))
+		}
+	case VariableTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s isn't a boolean, must be true or false", v.quote(value))
+		}
+		value = strconv.FormatBool(b)
+	case VariableTypeList:
+		var list []interface{}
+		if err := json.Unmarshal([]byte(value), &list); err != nil || list == nil {
+			return "", fmt.Errorf("%s isn't a list, must be a JSON array", v.quote(value))
+		}
+		length = len(list)
+		value = compactJSON(value)
+	case VariableTypeMap:
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &m); err != nil || m == nil {
+			return "", fmt.Errorf("%s isn't a map, must be a JSON object", v.quote(value))
+		}
+		length = len(m)
+		value = compactJSON(value)
+	default:
+		length = utf8.RuneCountInString(value)
+	}
+
+	for _, rule := range v.Validation {
+		if err := rule.check(v, value, number, length); err != nil {
+			if rule.ErrorMessage != "" {
+				return "", errors.New(rule.ErrorMessage)
+			}
+			return "", err
+		}
+	}
+
+	return value, nil
+}
+
+func (r *VariableValidation) check(v *Variable, value string, number float64, length int) error {
+	if r.Regex != "" && !regexp.MustCompile(r.Regex).MatchString(value) {
+		return fmt.Errorf("%s doesn't match '%s'", v.quote(value), r.Regex)
+	}
+
+	if len(r.AllowedValues) > 0 {
+		allowed := false
+		for _, a := range r.AllowedValues {
+			allowed = allowed || a == value
+		}
+		if !allowed {
+			return fmt.Errorf("%s must be one of: %s", v.quote(value), strings.Join(r.AllowedValues, ", "))
+		}
+	}
+
+	what, n := "length", float64(length)
+	if v.Type == VariableTypeNumber {
+		what, n = "value", number
+	}
+	if r.Min != nil && n < *r.Min {
+		return fmt.Errorf("%s must be at least %s", what, strconv.FormatFloat(*r.Min, 'f', -1, 64))
+	}
+	if r.Max != nil && n > *r.Max {
+		return fmt.Errorf("%s must be at most %s", what, strconv.FormatFloat(*r.Max, 'f', -1, 64))
+	}
+
+	return nil
+}
+
+// quote quotes the value for errors, unless it is sensitive.
+func (v *Variable) quote(value string) string {
+	if v.Sensitive {
+		return "the sensitive value"
+	}
+	return "'" + value + "'"
+}
+
+// compactJSON removes the insignificant space of valid JSON.
+func compactJSON(value string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(value)); err != nil {
+		return value
+	}
+	return buf.String()
+}